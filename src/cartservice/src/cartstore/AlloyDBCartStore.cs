@@ -161,6 +161,46 @@ namespace cartservice.cartstore
             }
         }
 
+        public async Task<Hipstershop.Cart> MergeCartAsync(string fromUserId, string toUserId)
+        {
+            Console.WriteLine($"MergeCartAsync called for fromUserId={fromUserId}, toUserId={toUserId}");
+
+            try
+            {
+                await using var dataSource = NpgsqlDataSource.Create(connectionString);
+                await using var connection = await dataSource.OpenConnectionAsync();
+                await using var transaction = await connection.BeginTransactionAsync();
+
+                // Add the quantities of the source cart to those of the target
+                // one, product by product, then delete the source cart.
+                var mergeCmd = $@"
+                    INSERT INTO {tableName} (userId, productId, quantity)
+                    SELECT @toUserId, productId, SUM(quantity) FROM {tableName}
+                    WHERE userId = @fromUserId GROUP BY productId
+                    ON CONFLICT (userId, productId)
+                    DO UPDATE SET quantity = {tableName}.quantity + EXCLUDED.quantity;
+                ";
+                await using (var cmd = new NpgsqlCommand(mergeCmd, connection, transaction))
+                {
+                    cmd.Parameters.AddWithValue("fromUserId", fromUserId);
+                    cmd.Parameters.AddWithValue("toUserId", toUserId);
+                    await cmd.ExecuteNonQueryAsync();
+                }
+                await using (var cmd = new NpgsqlCommand($"DELETE FROM {tableName} WHERE userId = @fromUserId", connection, transaction))
+                {
+                    cmd.Parameters.AddWithValue("fromUserId", fromUserId);
+                    await cmd.ExecuteNonQueryAsync();
+                }
+                await transaction.CommitAsync();
+            }
+            catch (Exception ex)
+            {
+                throw new RpcException(
+                    new Status(StatusCode.FailedPrecondition, $"Unable to access cart storage due to an internal error. {ex}"));
+            }
+            return await GetCartAsync(toUserId);
+        }
+
         public bool Ping()
         {
             try
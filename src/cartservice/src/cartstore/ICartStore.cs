@@ -21,6 +21,9 @@ namespace cartservice.cartstore
         Task AddItemAsync(string userId, string productId, int quantity);
         Task EmptyCartAsync(string userId);
         Task<Hipstershop.Cart> GetCartAsync(string userId);
+        // Moves the items in fromUserId's cart into toUserId's, adding up the
+        // quantities of products in both, and returns the merged cart.
+        Task<Hipstershop.Cart> MergeCartAsync(string fromUserId, string toUserId);
         bool Ping();
     }
 }
\ No newline at end of file
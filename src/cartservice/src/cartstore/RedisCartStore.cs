@@ -103,6 +103,44 @@ namespace cartservice.cartstore
             }
         }
 
+        public async Task<Hipstershop.Cart> MergeCartAsync(string fromUserId, string toUserId)
+        {
+            Console.WriteLine($"MergeCartAsync called with fromUserId={fromUserId}, toUserId={toUserId}");
+
+            var from = await GetCartAsync(fromUserId);
+            var to = await GetCartAsync(toUserId);
+            if (from.Items.Count == 0)
+            {
+                return to;
+            }
+            to.UserId = toUserId;
+            foreach (var item in from.Items)
+            {
+                var existingItem = to.Items.FirstOrDefault(i => i.ProductId == item.ProductId);
+                if (existingItem == null)
+                {
+                    to.Items.Add(new Hipstershop.CartItem { ProductId = item.ProductId, Quantity = item.Quantity });
+                }
+                else
+                {
+                    existingItem.Quantity += item.Quantity;
+                }
+            }
+
+            try
+            {
+                // The merged cart is saved before the other is emptied, so
+                // that items are added twice rather than lost if emptying fails.
+                await _cache.SetAsync(toUserId, to.ToByteArray());
+                await _cache.SetAsync(fromUserId, new Hipstershop.Cart().ToByteArray());
+            }
+            catch (Exception ex)
+            {
+                throw new RpcException(new Status(StatusCode.FailedPrecondition, $"Can't access cart storage. {ex}"));
+            }
+            return to;
+        }
+
         public bool Ping()
         {
             try
@@ -13,6 +13,7 @@
 // limitations under the License.
 
 using System;
+using System.Collections.Generic;
 using Google.Cloud.Spanner.Data;
 using Grpc.Core;
 using Microsoft.Extensions.Configuration;
@@ -169,6 +170,74 @@ namespace cartservice.cartstore
             }
         }
 
+        public async Task<Hipstershop.Cart> MergeCartAsync(string fromUserId, string toUserId)
+        {
+            Console.WriteLine($"MergeCartAsync called for fromUserId={fromUserId}, toUserId={toUserId}");
+
+            try
+            {
+                using SpannerConnection spannerConnection = new(databaseString);
+                await spannerConnection.RunWithRetriableTransactionAsync(async transaction =>
+                {
+                    // Sum the quantities of both carts per product, then write
+                    // them to the target cart and delete the source one, all in
+                    // one transaction.
+                    var quantities = new Dictionary<string, long>();
+                    var lookup = spannerConnection.CreateSelectCommand(
+                        $"SELECT productId, quantity FROM {TableName} WHERE userId = @fromUserId OR userId = @toUserId",
+                        new SpannerParameterCollection
+                        {
+                            { "fromUserId", SpannerDbType.String },
+                            { "toUserId", SpannerDbType.String }
+                        });
+                    lookup.Parameters["fromUserId"].Value = fromUserId;
+                    lookup.Parameters["toUserId"].Value = toUserId;
+                    lookup.Transaction = transaction;
+                    using (var reader = await lookup.ExecuteReaderAsync())
+                    {
+                        while (await reader.ReadAsync())
+                        {
+                            var productId = reader.GetFieldValue<string>("productId");
+                            quantities.TryGetValue(productId, out var quantity);
+                            quantities[productId] = quantity + reader.GetFieldValue<long>("quantity");
+                        }
+                    }
+
+                    foreach (var (productId, quantity) in quantities)
+                    {
+                        var upsert = spannerConnection.CreateInsertOrUpdateCommand(TableName,
+                            new SpannerParameterCollection
+                            {
+                                { "userId", SpannerDbType.String },
+                                { "productId", SpannerDbType.String },
+                                { "quantity", SpannerDbType.Int64 }
+                            });
+                        upsert.Parameters["userId"].Value = toUserId;
+                        upsert.Parameters["productId"].Value = productId;
+                        upsert.Parameters["quantity"].Value = quantity;
+                        upsert.Transaction = transaction;
+                        await upsert.ExecuteNonQueryAsync();
+                    }
+
+                    var delete = spannerConnection.CreateDmlCommand(
+                        $"DELETE FROM {TableName} WHERE userId = @fromUserId",
+                        new SpannerParameterCollection
+                        {
+                            { "fromUserId", SpannerDbType.String }
+                        });
+                    delete.Parameters["fromUserId"].Value = fromUserId;
+                    delete.Transaction = transaction;
+                    await delete.ExecuteNonQueryAsync();
+                });
+            }
+            catch (Exception ex)
+            {
+                throw new RpcException(
+                    new Status(StatusCode.FailedPrecondition, $"Can't access cart storage at {databaseString}. {ex}"));
+            }
+            return await GetCartAsync(toUserId);
+        }
+
         public bool Ping()
         {
             try
@@ -47,5 +47,18 @@ namespace cartservice.services
             await _cartStore.EmptyCartAsync(request.UserId);
             return Empty;
         }
+
+        public override Task<Cart> MergeCart(MergeCartRequest request, ServerCallContext context)
+        {
+            if (string.IsNullOrEmpty(request.FromUserId) || string.IsNullOrEmpty(request.ToUserId))
+            {
+                throw new RpcException(new Status(StatusCode.InvalidArgument, "from_user_id and to_user_id are required"));
+            }
+            if (request.FromUserId == request.ToUserId)
+            {
+                throw new RpcException(new Status(StatusCode.InvalidArgument, "cannot merge a cart into itself"));
+            }
+            return _cartStore.MergeCartAsync(request.FromUserId, request.ToUserId);
+        }
     }
 }
\ No newline at end of file
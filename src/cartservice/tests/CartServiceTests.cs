@@ -13,7 +13,9 @@
 // limitations under the License.
 
 using System;
+using System.Linq;
 using System.Threading.Tasks;
+using Grpc.Core;
 using Grpc.Net.Client;
 using Hipstershop;
 using Microsoft.AspNetCore.Hosting;
@@ -156,5 +158,69 @@ namespace cartservice.tests
             cart = await client.GetCartAsync(getCartRequest);
             Assert.Empty(cart.Items);
         }
+
+        [Fact]
+        public async Task MergeCart_SharedProduct_QuantitiesAddedUp()
+        {
+            // Setup test server and client
+            using var server = await _host.StartAsync();
+            var httpClient = server.GetTestClient();
+
+            string fromUserId = Guid.NewGuid().ToString();
+            string toUserId = Guid.NewGuid().ToString();
+
+            // Create a GRPC communication channel between the client and the server
+            var channel = GrpcChannel.ForAddress(httpClient.BaseAddress, new GrpcChannelOptions
+            {
+                HttpClient = httpClient
+            });
+
+            var client = new CartServiceClient(channel);
+
+            await client.AddItemAsync(new AddItemRequest { UserId = fromUserId, Item = new CartItem { ProductId = "1", Quantity = 1 } });
+            await client.AddItemAsync(new AddItemRequest { UserId = fromUserId, Item = new CartItem { ProductId = "2", Quantity = 3 } });
+            await client.AddItemAsync(new AddItemRequest { UserId = toUserId, Item = new CartItem { ProductId = "1", Quantity = 2 } });
+
+            var merged = await client.MergeCartAsync(new MergeCartRequest { FromUserId = fromUserId, ToUserId = toUserId });
+            Assert.Equal(toUserId, merged.UserId);
+            Assert.Equal(2, merged.Items.Count);
+            Assert.Equal(3, merged.Items.Single(i => i.ProductId == "1").Quantity);
+            Assert.Equal(3, merged.Items.Single(i => i.ProductId == "2").Quantity);
+
+            // The source cart is emptied, and the merged one is stored
+            var from = await client.GetCartAsync(new GetCartRequest { UserId = fromUserId });
+            Assert.Empty(from.Items);
+            var to = await client.GetCartAsync(new GetCartRequest { UserId = toUserId });
+            Assert.Equal(merged, to);
+
+            // Merging an empty cart changes nothing
+            merged = await client.MergeCartAsync(new MergeCartRequest { FromUserId = fromUserId, ToUserId = toUserId });
+            Assert.Equal(to, merged);
+
+            // Cleanup
+            await client.EmptyCartAsync(new EmptyCartRequest { UserId = toUserId });
+        }
+
+        [Fact]
+        public async Task MergeCart_SameUser_InvalidArgument()
+        {
+            // Setup test server and client
+            using var server = await _host.StartAsync();
+            var httpClient = server.GetTestClient();
+
+            string userId = Guid.NewGuid().ToString();
+
+            // Create a GRPC communication channel between the client and the server
+            var channel = GrpcChannel.ForAddress(httpClient.BaseAddress, new GrpcChannelOptions
+            {
+                HttpClient = httpClient
+            });
+
+            var client = new CartServiceClient(channel);
+
+            var ex = await Assert.ThrowsAsync<RpcException>(async () =>
+                await client.MergeCartAsync(new MergeCartRequest { FromUserId = userId, ToUserId = userId }));
+            Assert.Equal(StatusCode.InvalidArgument, ex.StatusCode);
+        }
     }
 }
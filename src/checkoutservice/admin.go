@@ -0,0 +1,309 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/audit"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/lifecycle"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// hipstershop.v2.OrderAdminService serves the back office. Since it acts on
+// the orders of every user, it is only served when ADMIN_AUTH says how its
+// callers are authenticated:
+//
+//   - token: calls carry the shared secret ADMIN_TOKEN, which may come from
+//     a secret, in "authorization: Bearer <token>" metadata.
+//   - mtls: calls come over mTLS, which MTLS_MODE must enable, from a peer
+//     whose identity is one of ADMIN_PEERS if it is set.
+//
+// It is served on the gRPC port of the service unless ADMIN_PORT gives it a
+// port of its own, which serves nothing else. LoyaltyService.RedeemPoints,
+// which spends the points of any user, is authenticated the same way, and
+// rejected without ADMIN_AUTH.
+
+const (
+	adminAuthToken = "token"
+	adminAuthMTLS  = "mtls"
+
+	// adminMethodPrefix starts the full names of the admin methods.
+	adminMethodPrefix = "/hipstershop.v2.OrderAdminService/"
+)
+
+// adminAuth authenticates the callers of OrderAdminService. A nil *adminAuth
+// rejects every call.
+type adminAuth struct {
+	mode string
+	// token returns the shared secret, with mode adminAuthToken.
+	token func(ctx context.Context) (string, error)
+	// peers are the mTLS identities allowed, with mode adminAuthMTLS, or
+	// nil to allow every identity.
+	peers map[string]bool
+}
+
+// newAdminAuthFromEnv returns the authentication ADMIN_AUTH sets up, or nil
+// if OrderAdminService is not served.
+func newAdminAuthFromEnv(ctx context.Context, secretStore *secrets.Manager, creds *mtls.Credentials) (*adminAuth, error) {
+	switch mode := os.Getenv("ADMIN_AUTH"); mode {
+	case "":
+		return nil, nil
+	case adminAuthToken:
+		a := &adminAuth{mode: mode, token: func(ctx context.Context) (string, error) {
+			return secretStore.Value(ctx, "ADMIN_TOKEN")
+		}}
+		if token, err := a.token(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read ADMIN_TOKEN: %w", err)
+		} else if token == "" {
+			return nil, errors.New("ADMIN_AUTH=token requires ADMIN_TOKEN")
+		}
+		return a, nil
+	case adminAuthMTLS:
+		if creds.Mode() == mtls.Disabled {
+			return nil, errors.New("ADMIN_AUTH=mtls requires MTLS_MODE=permissive or strict")
+		}
+		a := &adminAuth{mode: mode}
+		for _, p := range strings.Split(os.Getenv("ADMIN_PEERS"), ",") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			if a.peers == nil {
+				a.peers = make(map[string]bool)
+			}
+			a.peers[p] = true
+		}
+		return a, nil
+	default:
+		return nil, fmt.Errorf("unknown ADMIN_AUTH %q", mode)
+	}
+}
+
+func (a *adminAuth) String() string {
+	if a.mode == adminAuthMTLS && a.peers != nil {
+		peers := make([]string, 0, len(a.peers))
+		for p := range a.peers {
+			peers = append(peers, p)
+		}
+		slices.Sort(peers)
+		return "mtls for " + strings.Join(peers, ", ")
+	}
+	return a.mode
+}
+
+// UnaryServerInterceptor rejects the calls to OrderAdminService and
+// LoyaltyService.RedeemPoints whose caller is not authenticated, and lets the
+// other calls through.
+func (a *adminAuth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if name, ok := adminMethod(info.FullMethod); ok {
+			if err := a.authorize(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// adminMethod reports whether the method is only for admins, and returns
+// the name its callers are told.
+func adminMethod(fullMethod string) (string, bool) {
+	switch {
+	case strings.HasPrefix(fullMethod, adminMethodPrefix):
+		return "OrderAdminService", true
+	case fullMethod == pbv2.LoyaltyService_RedeemPoints_FullMethodName:
+		return "LoyaltyService.RedeemPoints", true
+	}
+	return "", false
+}
+
+func (a *adminAuth) authorize(ctx context.Context, name string) error {
+	switch {
+	case a == nil:
+		return status.Errorf(codes.Unauthenticated, "%s is not served without ADMIN_AUTH", name)
+	case a.mode == adminAuthToken:
+		want, err := a.token(ctx)
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "failed to read the admin token: %v", err)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get("authorization") {
+			got, ok := strings.CutPrefix(v, "Bearer ")
+			if ok && want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+				return nil
+			}
+		}
+		return status.Errorf(codes.Unauthenticated, "%s requires the admin token", name)
+	default:
+		var id string
+		if p, ok := peer.FromContext(ctx); ok {
+			id = mtls.Identity(p.AuthInfo)
+		}
+		if id == "" {
+			return status.Errorf(codes.Unauthenticated, "%s requires mutual TLS", name)
+		}
+		if a.peers != nil && !a.peers[id] {
+			return status.Errorf(codes.PermissionDenied, "%s may not call %s", id, name)
+		}
+		return nil
+	}
+}
+
+// serveAdmin serves admin on port, alone, until life drains.
+func serveAdmin(life *lifecycle.Manager, admin *orderAdminService, auth *adminAuth, auditLog *audit.Log, port string) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(append([]grpc.ServerOption{
+		peerCreds.ServerOption(),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), auth.UnaryServerInterceptor(), auditLog.UnaryServerInterceptor(auditedOperations), rpcerrors.UnaryServerInterceptor(log)),
+	}, grpcSettings.ServerOptions()...)...)
+	pbv2.RegisterOrderAdminServiceServer(srv, admin)
+	life.OnDrain("admin grpc server", lifecycle.GRPCServer(srv))
+	log.Infof("serving OrderAdminService on tcp: %q", lis.Addr().String())
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	return nil
+}
+
+// orderAdminService serves hipstershop.v2.OrderAdminService on top of the
+// v2 API.
+type orderAdminService struct {
+	pbv2.UnimplementedOrderAdminServiceServer
+
+	v2 *checkoutServiceV2
+}
+
+func newOrderAdminService(v2 *checkoutServiceV2) *orderAdminService {
+	return &orderAdminService{v2: v2}
+}
+
+func (s *orderAdminService) ListAllOrders(ctx context.Context, req *pbv2.ListAllOrdersRequest) (*pbv2.ListAllOrdersResponse, error) {
+	q, err := ordersQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pbv2.ListAllOrdersResponse{}
+	resp.Orders, resp.NextPageToken, err = s.v2.searchOrders(ctx, OrderSearch{
+		OrderQuery:   q,
+		UserID:       req.GetUserId(),
+		Email:        strings.TrimSpace(req.GetEmail()),
+		CurrencyCode: req.GetCurrencyCode(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *orderAdminService) UpdateOrderStatus(ctx context.Context, req *pbv2.UpdateOrderStatusRequest) (*pbv2.Order, error) {
+	return s.v2.UpdateOrderStatus(ctx, req)
+}
+
+func (s *orderAdminService) AddOrderNote(ctx context.Context, req *pbv2.AddOrderNoteRequest) (*pbv2.AddOrderNoteResponse, error) {
+	log.WithContext(ctx).WithFields(logging.Fields{logging.OrderIDKey: req.GetOrderId(), "author": req.GetAuthor()}).Info("[admin.AddOrderNote]")
+
+	n := OrderNote{OrderID: req.GetOrderId(), Author: strings.TrimSpace(req.GetAuthor()), Text: strings.TrimSpace(req.GetText())}
+	switch {
+	case n.OrderID == "":
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	case n.Author == "":
+		return nil, status.Error(codes.InvalidArgument, "author is required")
+	case len(n.Author) > 255:
+		return nil, status.Error(codes.InvalidArgument, "author is longer than 255 bytes")
+	case n.Text == "":
+		return nil, status.Error(codes.InvalidArgument, "text is required")
+	case len(n.Text) > maxOrderNoteLen:
+		return nil, status.Errorf(codes.InvalidArgument, "text is longer than %d bytes", maxOrderNoteLen)
+	}
+	cs := s.v2.cs
+	if cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	if cs.readOnly {
+		return nil, errSchemaReadOnly()
+	}
+	notes, err := cs.orderStore.AddOrderNote(ctx, n)
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		return nil, status.Errorf(codes.NotFound, "order %s not found", n.OrderID)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to add order note: %v", err)
+	}
+	resp := &pbv2.AddOrderNoteResponse{}
+	for _, n := range notes {
+		resp.Notes = append(resp.Notes, &pbv2.OrderNote{Author: n.Author, Text: n.Text, CreatedAt: timestamppb.New(n.CreatedAt)})
+	}
+	return resp, nil
+}
+
+func (s *orderAdminService) ResendConfirmation(ctx context.Context, req *pbv2.ResendConfirmationRequest) (*pbv2.ResendConfirmationResponse, error) {
+	log.WithContext(ctx).WithField(logging.OrderIDKey, req.GetOrderId()).Info("[admin.ResendConfirmation]")
+
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+	cs := s.v2.cs
+	if cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	o, err := cs.orderStore.GetOrder(ctx, req.GetOrderId())
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		return nil, status.Errorf(codes.NotFound, "order %s not found", req.GetOrderId())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to get order: %v", err)
+	}
+	if slices.Contains(attemptStatuses, o.Status) {
+		return nil, status.Errorf(codes.FailedPrecondition, "order %s was not placed", o.OrderID)
+	}
+	email := strings.TrimSpace(req.GetEmail())
+	if email == "" {
+		email = o.Email
+	}
+	if email == "" {
+		// the user's data was erased
+		return nil, status.Errorf(codes.FailedPrecondition, "order %s has no email address", o.OrderID)
+	}
+	if err := cs.sendOrderConfirmation(ctx, email, req.GetLocale(), o.result(), o.totalPaid(), o.GiftMessage, o.CustomerNote); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to send order confirmation: %v", err)
+	}
+	return &pbv2.ResendConfirmationResponse{Email: email}, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/mtls"
+)
+
+func TestAdminAuth(t *testing.T) {
+	token := &adminAuth{mode: adminAuthToken, token: func(context.Context) (string, error) { return "s3cret", nil }}
+	certified := func(cn string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		info := credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+	}
+	bearer := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+	for _, tt := range []struct {
+		name   string
+		auth   *adminAuth
+		ctx    context.Context
+		method string
+		want   codes.Code
+	}{
+		{"token", token, bearer("s3cret"), pbv2.OrderAdminService_AddOrderNote_FullMethodName, codes.OK},
+		{"wrong token", token, bearer("guess"), pbv2.OrderAdminService_AddOrderNote_FullMethodName, codes.Unauthenticated},
+		{"no token", token, context.Background(), pbv2.OrderAdminService_AddOrderNote_FullMethodName, codes.Unauthenticated},
+		{"other service", token, context.Background(), pbv2.CheckoutService_GetOrder_FullMethodName, codes.OK},
+		{"mtls", &adminAuth{mode: adminAuthMTLS}, certified("frontend"), pbv2.OrderAdminService_ListAllOrders_FullMethodName, codes.OK},
+		{"mtls peer", &adminAuth{mode: adminAuthMTLS, peers: map[string]bool{"backoffice": true}}, certified("backoffice"), pbv2.OrderAdminService_ListAllOrders_FullMethodName, codes.OK},
+		{"mtls other peer", &adminAuth{mode: adminAuthMTLS, peers: map[string]bool{"backoffice": true}}, certified("frontend"), pbv2.OrderAdminService_ListAllOrders_FullMethodName, codes.PermissionDenied},
+		{"plaintext", &adminAuth{mode: adminAuthMTLS}, context.Background(), pbv2.OrderAdminService_ListAllOrders_FullMethodName, codes.Unauthenticated},
+		{"not served", nil, bearer("s3cret"), pbv2.OrderAdminService_ListAllOrders_FullMethodName, codes.Unauthenticated},
+		{"redeem points", token, bearer("s3cret"), pbv2.LoyaltyService_RedeemPoints_FullMethodName, codes.OK},
+		{"redeem points without token", token, context.Background(), pbv2.LoyaltyService_RedeemPoints_FullMethodName, codes.Unauthenticated},
+		{"redeem points from other peer", &adminAuth{mode: adminAuthMTLS, peers: map[string]bool{"backoffice": true}}, certified("frontend"), pbv2.LoyaltyService_RedeemPoints_FullMethodName, codes.PermissionDenied},
+		{"redeem points not served", nil, context.Background(), pbv2.LoyaltyService_RedeemPoints_FullMethodName, codes.Unauthenticated},
+		{"points balance", nil, context.Background(), pbv2.LoyaltyService_GetPointsBalance_FullMethodName, codes.OK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.auth.UnaryServerInterceptor()(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(context.Context, any) (any, error) {
+				return nil, nil
+			})
+			if status.Code(err) != tt.want {
+				t.Errorf("call to %s = %v, want %v", tt.method, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminAuthFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		creds   *mtls.Credentials
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, nil, "", false},
+		{"token", map[string]string{"ADMIN_AUTH": "token", "ADMIN_TOKEN": "s3cret"}, nil, "token", false},
+		{"no token", map[string]string{"ADMIN_AUTH": "token"}, nil, "", true},
+		{"mtls without mtls", map[string]string{"ADMIN_AUTH": "mtls"}, nil, "", true},
+		{"unknown", map[string]string{"ADMIN_AUTH": "password"}, nil, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"ADMIN_AUTH", "ADMIN_TOKEN", "ADMIN_TOKEN_SECRET", "ADMIN_PEERS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			// without ADMIN_TOKEN_SECRET the token is read from the
+			// environment, so no secret store is needed
+			a, err := newAdminAuthFromEnv(context.Background(), nil, tt.creds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newAdminAuthFromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			got := ""
+			if a != nil {
+				got = a.String()
+			}
+			if got != tt.want {
+				t.Errorf("newAdminAuthFromEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderAdminServiceOrders(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryOrderStore()
+	s := newOrderAdminService(newCheckoutServiceV2(&checkoutService{orderStore: store}))
+	save := func(userID string) string {
+		orderID := uuid.NewString()
+		if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: userID + "@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1"}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}})}); err != nil {
+			t.Fatal(err)
+		}
+		return orderID
+	}
+	first := save("user-1")
+	save("user-2")
+
+	all, err := s.ListAllOrders(ctx, &pbv2.ListAllOrdersRequest{})
+	if err != nil || len(all.GetOrders()) != 2 {
+		t.Fatalf("ListAllOrders() = %v, %v, want both orders", all, err)
+	}
+	mine, err := s.ListAllOrders(ctx, &pbv2.ListAllOrdersRequest{UserId: "user-1"})
+	if err != nil || len(mine.GetOrders()) != 1 || mine.GetOrders()[0].GetOrderId() != first {
+		t.Errorf("ListAllOrders() of user-1 = %v, %v, want order %s", mine, err, first)
+	}
+	page, err := s.ListAllOrders(ctx, &pbv2.ListAllOrdersRequest{PageSize: 1})
+	if err != nil || len(page.GetOrders()) != 1 || page.GetNextPageToken() == "" {
+		t.Errorf("ListAllOrders() of one order = %v, %v, want a next page", page, err)
+	}
+
+	for _, text := range []string{"called about the delivery", "delivery rescheduled"} {
+		if _, err := s.AddOrderNote(ctx, &pbv2.AddOrderNoteRequest{OrderId: first, Author: "support@example.com", Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := s.AddOrderNote(ctx, &pbv2.AddOrderNoteRequest{OrderId: first, Author: "support@example.com", Text: "refunded shipping"})
+	if err != nil || len(resp.GetNotes()) != 3 || resp.GetNotes()[0].GetText() != "called about the delivery" || resp.GetNotes()[2].GetCreatedAt() == nil {
+		t.Errorf("AddOrderNote() = %v, %v, want the three notes oldest first", resp, err)
+	}
+	if _, err := s.AddOrderNote(ctx, &pbv2.AddOrderNoteRequest{OrderId: uuid.NewString(), Author: "support@example.com", Text: "hello"}); status.Code(err) != codes.NotFound {
+		t.Errorf("AddOrderNote() to an unknown order = %v, want NotFound", err)
+	}
+	for _, req := range []*pbv2.AddOrderNoteRequest{
+		{OrderId: first, Text: "no author"},
+		{OrderId: first, Author: "support@example.com", Text: "  "},
+	} {
+		if _, err := s.AddOrderNote(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("AddOrderNote(%v) = %v, want InvalidArgument", req, err)
+		}
+	}
+
+	updated, err := s.UpdateOrderStatus(ctx, &pbv2.UpdateOrderStatusRequest{OrderId: first, Status: pbv2.OrderStatus_ORDER_STATUS_SHIPPED})
+	if err != nil || updated.GetStatus() != pbv2.OrderStatus_ORDER_STATUS_SHIPPED {
+		t.Errorf("UpdateOrderStatus() = %v, %v, want the order shipped", updated, err)
+	}
+}
+
+// TestOrderNotesSQLite adds notes to an order in a SQLite database.
+func TestOrderNotesSQLite(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	orderID := uuid.NewString()
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: "user-1", Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddOrderNote(ctx, OrderNote{OrderID: orderID, Author: "a@example.com", Text: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := store.AddOrderNote(ctx, OrderNote{OrderID: orderID, Author: "b@example.com", Text: "second"})
+	if err != nil || len(notes) != 2 || notes[0].Text != "first" || notes[1].Author != "b@example.com" || notes[1].CreatedAt.IsZero() {
+		t.Errorf("AddOrderNote() = %+v, %v, want both notes oldest first", notes, err)
+	}
+	if _, err := store.AddOrderNote(ctx, OrderNote{OrderID: uuid.NewString(), Author: "a@example.com", Text: "lost"}); err == nil {
+		t.Error("AddOrderNote() to an unknown order succeeded")
+	}
+}
+
+// recordingEmail is an email service that records the confirmations it is
+// asked to send.
+type recordingEmail struct {
+	pb.UnimplementedEmailServiceServer
+
+	mu   sync.Mutex
+	sent []*pb.SendOrderConfirmationRequest
+}
+
+func (e *recordingEmail) SendOrderConfirmation(_ context.Context, req *pb.SendOrderConfirmationRequest) (*pb.Empty, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sent = append(e.sent, req)
+	return &pb.Empty{}, nil
+}
+
+func TestResendConfirmation(t *testing.T) {
+	ctx := context.Background()
+	cs, _ := newFakeCheckoutService(t)
+	email := &recordingEmail{}
+	cs.emailSvcConn = contract.Serve(t, func(srv *grpc.Server) { pb.RegisterEmailServiceServer(srv, email) })
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	s := newOrderAdminService(newCheckoutServiceV2(cs))
+	placed, pending := uuid.NewString(), uuid.NewString()
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: placed, UserID: "user-1", Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", ShippingCostUnits: 2}, Items: pricedItems(placed, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SavePendingOrder(ctx, orderRecord{Order: Order{OrderID: pending, UserID: "user-1", Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.ResendConfirmation(ctx, &pbv2.ResendConfirmationRequest{OrderId: placed})
+	if err != nil || resp.GetEmail() != "someone@example.com" {
+		t.Fatalf("ResendConfirmation() = %v, %v, want it sent to the order's address", resp, err)
+	}
+	if _, err := s.ResendConfirmation(ctx, &pbv2.ResendConfirmationRequest{OrderId: placed, Email: "other@example.com", Locale: "fr-FR"}); err != nil {
+		t.Fatal(err)
+	}
+	email.mu.Lock()
+	sent := email.sent
+	email.mu.Unlock()
+	if len(sent) != 2 || sent[0].GetEmail() != "someone@example.com" || sent[1].GetEmail() != "other@example.com" || sent[1].GetOrder().GetOrderId() != placed || sent[1].GetTextBody() == "" {
+		t.Errorf("sent confirmations = %v, want the order's to both addresses", sent)
+	}
+
+	if _, err := s.ResendConfirmation(ctx, &pbv2.ResendConfirmationRequest{OrderId: pending}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ResendConfirmation() of a pending order = %v, want FailedPrecondition", err)
+	}
+	if _, err := store.DeleteUserData(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResendConfirmation(ctx, &pbv2.ResendConfirmationRequest{OrderId: placed}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ResendConfirmation() of an erased order = %v, want FailedPrecondition", err)
+	}
+	if _, err := s.ResendConfirmation(ctx, &pbv2.ResendConfirmationRequest{OrderId: uuid.NewString()}); status.Code(err) != codes.NotFound {
+		t.Errorf("ResendConfirmation() of an unknown order = %v, want NotFound", err)
+	}
+}
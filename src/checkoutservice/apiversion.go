@@ -0,0 +1,54 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// API versions of the checkout service, as reported in metrics.
+const (
+	apiV1 = "v1"
+	apiV2 = "v2"
+)
+
+var placeOrderRequests, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.place_order.requests",
+	metric.WithDescription("PlaceOrder requests handled, by API version and status code."),
+	metric.WithUnit("{request}"),
+)
+
+// recordPlaceOrder counts a PlaceOrder request that finished with err.
+func recordPlaceOrder(ctx context.Context, version string, err error) {
+	placeOrderRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("api.version", version),
+		attribute.String("rpc.grpc.status", status.Code(err).String()),
+	))
+}
+
+// markDeprecated tells the caller that the method it called is deprecated,
+// through a "deprecation" response header.
+func markDeprecated(ctx context.Context) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs("deprecation", "true")); err != nil {
+		log.WithContext(ctx).Debugf("failed to set deprecation header: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+var testLog = logging.New("testservice")
+
+func open(t *testing.T, path string) *Log {
+	t.Helper()
+	l, err := Open("testservice", path, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close(context.Background()) })
+	return l
+}
+
+func TestRecordChainsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := open(t, path)
+	for _, op := range []string{"flags.Set", "catalog.Reload"} {
+		if err := l.Record(context.Background(), Entry{Actor: "admin", Operation: op, Outcome: "OK"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close(context.Background())
+
+	// Reopening continues the chain from the file.
+	l = open(t, path)
+	if err := l.Record(context.Background(), Entry{Actor: "admin", Operation: "flags.Set", Outcome: "OK"}); err != nil {
+		t.Fatal(err)
+	}
+	entries := l.Entries(Query{})
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) || e.Service != "testservice" || e.Hash == "" {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+	}
+	if entries[2].PrevHash != entries[1].Hash {
+		t.Error("entry 3 is not chained to entry 2")
+	}
+	if err := l.Verify(); err != nil {
+		t.Error(err)
+	}
+	if got := l.Entries(Query{After: 1, Operation: "flags.Set"}); len(got) != 1 || got[0].Seq != 3 {
+		t.Errorf("query returned %+v, want entry 3", got)
+	}
+}
+
+func TestOpenDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := open(t, path)
+	for _, target := range []string{"OLJCESPC7Z", "66VCHSJNUP", "1YMWWN1N4O"} {
+		if err := l.Record(context.Background(), Entry{Actor: "admin", Operation: "inventory.Set", Target: target, Outcome: "OK"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close(context.Background())
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(b)), "\n")
+
+	for name, tampered := range map[string]string{
+		"edited":    strings.Replace(string(b), "66VCHSJNUP", "9SIQT8TOJO", 1),
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+	} {
+		if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open("testservice", path, testLog); !errors.Is(err, errBroken) {
+			t.Errorf("%s entry: Open returned %v, want a broken chain", name, err)
+		}
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	l := open(t, "")
+	for i := 0; i < 3; i++ {
+		l.Record(context.Background(), Entry{Actor: "admin", Operation: "flags.Set", Outcome: "OK"})
+	}
+	entries := l.Entries(Query{})
+	if err := Verify(entries); err != nil {
+		t.Fatal(err)
+	}
+	entries[1].Actor = "someone-else"
+	if err := Verify(entries); !errors.Is(err, errBroken) {
+		t.Errorf("Verify of an edited entry returned %v, want a broken chain", err)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	l := open(t, "")
+	h := l.HTTPHandler("logging.SetLevel", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	if _, err := http.Get(srv.URL + "/debug/loglevel"); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/debug/loglevel", strings.NewReader("verbose\n"))
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := l.Entries(Query{})
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want only the PUT", len(entries))
+	}
+	e := entries[0]
+	if e.Operation != "logging.SetLevel" || e.Outcome != "400" || e.Details["body"] != "verbose" || e.Actor == "" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	l := open(t, "")
+	intercept := l.UnaryServerInterceptor(Operations{
+		"/svc/Update": func(req any) string { return req.(string) },
+	})
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	}
+	for _, method := range []string{"/svc/Update", "/svc/Get"} {
+		intercept(context.Background(), "product-1", &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	entries := l.Entries(Query{})
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if e := entries[0]; e.Operation != "/svc/Update" || e.Target != "product-1" || e.Outcome != "PermissionDenied" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	l := open(t, "")
+	for i := 0; i < 3; i++ {
+		l.Record(context.Background(), Entry{Actor: "admin", Operation: "flags.Set", Outcome: "OK"})
+	}
+	srv := httptest.NewServer(l.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?after=1&limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Entries  []Entry `json:"entries"`
+		Verified bool    `json:"verified"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Entries) != 1 || body.Entries[0].Seq != 2 || !body.Verified {
+		t.Errorf("response = %+v, want verified entry 2", body)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package breaker fails the calls to a dependency fast while the dependency
+// is failing, so that a hung or crashed dependency costs callers an error at
+// once instead of their deadline.
+//
+// Each dependency has a Breaker. A breaker is closed as long as calls
+// succeed. After Failures calls in a row fail with Unavailable, Unknown,
+// Internal, ResourceExhausted or DeadlineExceeded it opens, and for OpenFor
+// every call fails at once with Unavailable and reason CIRCUIT_OPEN, without
+// reaching the dependency. It then lets one call through: the breaker closes
+// if the call succeeds, and opens again for OpenFor if it fails. Other errors
+// tell the dependency is up and reset the failures, and calls their caller
+// cancels do not count. A hung dependency only trips the breaker if its calls
+// time out before their caller gives up, which the call policies see to (see
+// package callpolicy).
+//
+// The breaker.state gauge reports the state of each breaker by dependency (0
+// closed, 1 half-open, 2 open), and the breaker.rejected counter the calls
+// failed while it was open.
+package breaker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// ReasonOpen is the error reason of calls failed by an open breaker.
+const ReasonOpen = "CIRCUIT_OPEN"
+
+const (
+	defaultFailures = 5
+	defaultOpenFor  = 30 * time.Second
+)
+
+// State is the state of a Breaker.
+type State int
+
+// States, in the order of the breaker.state gauge.
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+var stateNames = [...]string{Closed: "closed", HalfOpen: "half-open", Open: "open"}
+
+func (s State) String() string {
+	if s < Closed || s > Open {
+		return strconv.Itoa(int(s))
+	}
+	return stateNames[s]
+}
+
+// Config sets when breakers open.
+type Config struct {
+	// Failures is the number of calls in a row that must fail for a
+	// breaker to open.
+	Failures int
+	// OpenFor is how long an open breaker fails calls before letting one
+	// through.
+	OpenFor time.Duration
+}
+
+// Set holds the breakers of a service's dependencies. A nil Set has only nil
+// breakers, which let every call through.
+type Set struct {
+	cfg      Config
+	now      func() time.Time
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+
+	rejected metric.Int64Counter
+}
+
+// FromEnv returns a Set configured with CIRCUIT_BREAKER_FAILURES (default 5)
+// and CIRCUIT_BREAKER_OPEN_FOR (default 30s), or nil if
+// CIRCUIT_BREAKER_FAILURES is 0.
+func FromEnv() (*Set, error) {
+	cfg := Config{Failures: defaultFailures, OpenFor: defaultOpenFor}
+	if v := os.Getenv("CIRCUIT_BREAKER_FAILURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid CIRCUIT_BREAKER_FAILURES %q", v)
+		}
+		cfg.Failures = n
+	}
+	if v := os.Getenv("CIRCUIT_BREAKER_OPEN_FOR"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid CIRCUIT_BREAKER_OPEN_FOR %q", v)
+		}
+		cfg.OpenFor = d
+	}
+	if cfg.Failures == 0 {
+		return nil, nil
+	}
+	return New(cfg), nil
+}
+
+// New returns a Set for cfg.
+func New(cfg Config) *Set {
+	s := &Set{cfg: cfg, now: time.Now, breakers: make(map[string]*Breaker)}
+	meter := otel.Meter("breaker")
+	s.rejected, _ = meter.Int64Counter(
+		"breaker.rejected",
+		metric.WithDescription("Calls failed at once because the breaker of their dependency was open, by dependency."),
+		metric.WithUnit("{call}"))
+	_, _ = meter.Int64ObservableGauge(
+		"breaker.state",
+		metric.WithDescription("State of the breaker of each dependency: 0 closed, 1 half-open, 2 open."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for name, b := range s.breakers {
+				o.Observe(int64(b.State()), metric.WithAttributes(attribute.String("dependency", name)))
+			}
+			return nil
+		}))
+	return s
+}
+
+// String describes the configuration, for logging.
+func (s *Set) String() string {
+	if s == nil {
+		return "disabled"
+	}
+	return fmt.Sprintf("open after %d failures for %s", s.cfg.Failures, s.cfg.OpenFor)
+}
+
+// For returns the breaker of the named dependency.
+func (s *Set) For(name string) *Breaker {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[name]
+	if !ok {
+		b = &Breaker{name: name, set: s}
+		s.breakers[name] = b
+	}
+	return b
+}
+
+// Breaker is the breaker of one dependency. A nil Breaker lets every call
+// through.
+type Breaker struct {
+	name string
+	set  *Set
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// probing is set while the call let through a half-open breaker is in
+	// flight.
+	probing bool
+}
+
+// State returns the state of b.
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.set.now().Sub(b.openedAt) >= b.set.cfg.OpenFor {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a call may proceed. If it does, done must be called
+// with its error once it completes.
+func (b *Breaker) Allow(ctx context.Context) (done func(error), err error) {
+	if b == nil {
+		return func(error) {}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.set.now().Sub(b.openedAt) >= b.set.cfg.OpenFor {
+		b.state = HalfOpen
+	}
+	switch {
+	case b.state == Open, b.state == HalfOpen && b.probing:
+		b.set.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("dependency", b.name)))
+		return nil, rpcerrors.Errorf(codes.Unavailable, ReasonOpen, "%s is failing, circuit breaker open", b.name)
+	case b.state == HalfOpen:
+		b.probing = true
+	}
+	return b.record, nil
+}
+
+// record records the outcome of a call Allow let through.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.state == HalfOpen && b.probing
+	if probe {
+		b.probing = false
+	}
+	if status.Code(err) == codes.Canceled {
+		// the caller gave up, which tells nothing about the dependency
+		return
+	}
+	if !failure(err) {
+		b.state, b.failures = Closed, 0
+		return
+	}
+	b.failures++
+	if probe || b.failures >= b.set.cfg.Failures {
+		b.state, b.openedAt = Open, b.set.now()
+	}
+}
+
+// failure reports whether err tells that the dependency is failing.
+func failure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Unknown, codes.Internal, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// UnaryClientInterceptor fails calls through b while it is open.
+func (b *Breaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, err := b.Allow(ctx)
+		if err != nil {
+			return err
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		done(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor fails streams opened through b while it is open.
+// Only whether streams could be opened counts towards the breaker.
+func (b *Breaker) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		done, err := b.Allow(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		done(err)
+		return s, err
+	}
+}
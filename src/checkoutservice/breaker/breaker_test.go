@@ -0,0 +1,163 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, "open after 5 failures for 30s", false},
+		{"set", map[string]string{"CIRCUIT_BREAKER_FAILURES": "3", "CIRCUIT_BREAKER_OPEN_FOR": "1m"}, "open after 3 failures for 1m0s", false},
+		{"off", map[string]string{"CIRCUIT_BREAKER_FAILURES": "0"}, "disabled", false},
+		{"invalid failures", map[string]string{"CIRCUIT_BREAKER_FAILURES": "-1"}, "", true},
+		{"invalid open for", map[string]string{"CIRCUIT_BREAKER_OPEN_FOR": "0s"}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CIRCUIT_BREAKER_FAILURES", "CIRCUIT_BREAKER_OPEN_FOR"} {
+				t.Setenv(key, tt.env[key])
+			}
+			s, err := FromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && s.String() != tt.want {
+				t.Errorf("FromEnv() = %q, want %q", s, tt.want)
+			}
+		})
+	}
+}
+
+// call makes a call through b that fails with code, and returns the error
+// of Allow.
+func call(b *Breaker, code codes.Code) error {
+	done, err := b.Allow(context.Background())
+	if err != nil {
+		return err
+	}
+	done(status.Error(code, "call"))
+	return nil
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Now()
+	s := New(Config{Failures: 3, OpenFor: time.Minute})
+	s.now = func() time.Time { return now }
+	b := s.For("payment")
+	if s.For("payment") != b {
+		t.Fatal("For() returned another breaker for the same dependency")
+	}
+
+	for range 2 {
+		if err := call(b, codes.Unavailable); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a dependency that answers is up
+	if err := call(b, codes.InvalidArgument); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := call(b, codes.DeadlineExceeded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.State() != Open {
+		t.Fatalf("state after 3 failures = %s, want open", b.State())
+	}
+	err := call(b, codes.OK)
+	if c := rpcerrors.Classify(err); c.Code != codes.Unavailable || c.Reason != ReasonOpen {
+		t.Fatalf("call while open = %v, want Unavailable %s", err, ReasonOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("state after OpenFor = %s, want half-open", b.State())
+	}
+	done, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(b, codes.OK); err == nil {
+		t.Error("second call while half-open was let through")
+	}
+	done(status.Error(codes.Unavailable, "still down"))
+	if b.State() != Open {
+		t.Fatalf("state after a failed probe = %s, want open", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if err := call(b, codes.OK); err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != Closed {
+		t.Errorf("state after a successful probe = %s, want closed", b.State())
+	}
+}
+
+func TestCanceledCallsDoNotCount(t *testing.T) {
+	b := New(Config{Failures: 2, OpenFor: time.Minute}).For("cart")
+	for _, code := range []codes.Code{codes.Unavailable, codes.Canceled, codes.Unavailable} {
+		if err := call(b, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.State() != Open {
+		t.Errorf("state = %s, want open", b.State())
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	var s *Set
+	b := s.For("cart")
+	for range 10 {
+		if err := call(b, codes.Unavailable); err != nil {
+			t.Fatalf("nil breaker failed a call: %v", err)
+		}
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	b := New(Config{Failures: 1, OpenFor: time.Minute}).For("shipping")
+	intercept := b.UnaryClientInterceptor()
+	calls := 0
+	down := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.Unavailable, "down")
+	}
+	if err := intercept(context.Background(), "/hipstershop.ShippingService/ShipOrder", nil, nil, nil, down); status.Code(err) != codes.Unavailable {
+		t.Fatalf("call = %v, want Unavailable", err)
+	}
+	if err := intercept(context.Background(), "/hipstershop.ShippingService/ShipOrder", nil, nil, nil, down); rpcerrors.Classify(err).Reason != ReasonOpen {
+		t.Fatalf("call after the breaker opened = %v, want %s", err, ReasonOpen)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls reached the dependency, want 1", calls)
+	}
+}
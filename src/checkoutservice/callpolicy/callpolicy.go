@@ -0,0 +1,263 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package callpolicy bounds and retries the gRPC calls a service makes to its
+// dependencies, with a Policy per dependency, so that how the service
+// degrades when a dependency slows down or fails can be tuned, and shown, at
+// deploy time.
+//
+// A policy sets:
+//
+//   - timeout, how long each attempt of a unary call may take (10s by
+//     default, "0s" for as long as the caller allows);
+//   - retries, how many times a failed call is made again (none by
+//     default). Calls that are not idempotent may be retried after they
+//     succeeded on the server, so only raise it for dependencies whose calls
+//     can be repeated;
+//   - retry_on, the codes of the failures that are retried (["UNAVAILABLE"]
+//     by default);
+//   - backoff, how long to wait before the first retry (100ms by default),
+//     doubling with each retry.
+//
+// Policies are given as a JSON object keyed by dependency in CALL_POLICIES,
+// or in the file named by CALL_POLICIES_FILE, such as a mounted ConfigMap.
+// The "default" key sets the defaults of the other dependencies, and each
+// dependency takes the defaults for what its policy leaves out:
+//
+//	{"default": {"timeout": "5s"},
+//	 "cart": {"retries": 2, "retry_on": ["UNAVAILABLE", "DEADLINE_EXCEEDED"]},
+//	 "payment": {"timeout": "15s"}}
+//
+// Calls failed by an open circuit breaker are never retried, nor are calls
+// whose caller gave up. Streams are neither bounded nor retried. Retries are
+// counted by dependency and code in the callpolicy.retries metric.
+package callpolicy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/breaker"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// DefaultKey is the key of the default policy.
+const DefaultKey = "default"
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultBackoff = 100 * time.Millisecond
+)
+
+var retries, _ = otel.Meter("callpolicy").Int64Counter(
+	"callpolicy.retries",
+	metric.WithDescription("Calls to dependencies made again after they failed, by dependency and code."),
+	metric.WithUnit("{call}"))
+
+// Policy is how the calls to a dependency are bounded and retried.
+type Policy struct {
+	// Timeout bounds each attempt of a unary call, or is 0 to leave it to
+	// the caller's deadline.
+	Timeout time.Duration
+	// Retries is how many times a failed call is made again.
+	Retries int
+	// RetryOn are the codes of the failures that are retried.
+	RetryOn []codes.Code
+	// Backoff is how long to wait before the first retry.
+	Backoff time.Duration
+
+	name string
+}
+
+// Default is the policy of dependencies that have none.
+func Default() Policy {
+	return Policy{Timeout: defaultTimeout, RetryOn: []codes.Code{codes.Unavailable}, Backoff: defaultBackoff}
+}
+
+// Policies are the policies of a service's dependencies.
+type Policies struct {
+	deflt  Policy
+	byName map[string]Policy
+}
+
+// rawPolicy is a Policy as configured, with nil for what it leaves out.
+type rawPolicy struct {
+	Timeout *string      `json:"timeout"`
+	Retries *int         `json:"retries"`
+	RetryOn []codes.Code `json:"retry_on"`
+	Backoff *string      `json:"backoff"`
+}
+
+// FromEnv returns the policies set by CALL_POLICIES or CALL_POLICIES_FILE for
+// the named dependencies, or the default policy for all of them if neither is
+// set.
+func FromEnv(dependencies ...string) (*Policies, error) {
+	v := os.Getenv("CALL_POLICIES")
+	if file := os.Getenv("CALL_POLICIES_FILE"); file != "" {
+		if v != "" {
+			return nil, fmt.Errorf("CALL_POLICIES and CALL_POLICIES_FILE are both set")
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CALL_POLICIES_FILE: %w", err)
+		}
+		v = string(b)
+	}
+	if v == "" {
+		return &Policies{deflt: Default()}, nil
+	}
+	p, err := Parse([]byte(v), dependencies...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid call policies: %w", err)
+	}
+	return p, nil
+}
+
+// Parse parses the JSON policies of the named dependencies.
+func Parse(b []byte, dependencies ...string) (*Policies, error) {
+	var raw map[string]rawPolicy
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	p := &Policies{byName: make(map[string]Policy)}
+	var err error
+	if p.deflt, err = raw[DefaultKey].apply(Default()); err != nil {
+		return nil, fmt.Errorf("%s: %w", DefaultKey, err)
+	}
+	for name, r := range raw {
+		if name == DefaultKey {
+			continue
+		}
+		if !slices.Contains(dependencies, name) {
+			return nil, fmt.Errorf("unknown dependency %q, want one of %s", name, strings.Join(dependencies, ", "))
+		}
+		if p.byName[name], err = r.apply(p.deflt); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// apply returns base overridden by what r sets.
+func (r rawPolicy) apply(base Policy) (Policy, error) {
+	p := base
+	p.RetryOn = slices.Clone(base.RetryOn)
+	if r.Timeout != nil {
+		d, err := time.ParseDuration(*r.Timeout)
+		if err != nil || d < 0 {
+			return p, fmt.Errorf("invalid timeout %q", *r.Timeout)
+		}
+		p.Timeout = d
+	}
+	if r.Retries != nil {
+		if *r.Retries < 0 {
+			return p, fmt.Errorf("invalid retries %d", *r.Retries)
+		}
+		p.Retries = *r.Retries
+	}
+	if r.RetryOn != nil {
+		p.RetryOn = r.RetryOn
+	}
+	if r.Backoff != nil {
+		d, err := time.ParseDuration(*r.Backoff)
+		if err != nil || d < 0 {
+			return p, fmt.Errorf("invalid backoff %q", *r.Backoff)
+		}
+		p.Backoff = d
+	}
+	return p, nil
+}
+
+// For returns the policy of the named dependency.
+func (ps *Policies) For(name string) Policy {
+	p, ok := ps.byName[name]
+	if !ok {
+		p = ps.deflt
+	}
+	p.name = name
+	return p
+}
+
+// String describes the policies, for logging.
+func (ps *Policies) String() string {
+	names := make([]string, 0, len(ps.byName))
+	for name := range ps.byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	s := ps.deflt.String()
+	for _, name := range names {
+		s += fmt.Sprintf("; %s: %s", name, ps.byName[name])
+	}
+	return s
+}
+
+func (p Policy) String() string {
+	s := fmt.Sprintf("timeout %s", p.Timeout)
+	if p.Retries > 0 {
+		s += fmt.Sprintf(", %d retries on %v after %s", p.Retries, p.RetryOn, p.Backoff)
+	}
+	return s
+}
+
+// retryable reports whether a call that failed with err may be made again.
+func (p Policy) retryable(err error) bool {
+	if rpcerrors.Classify(err).Reason == breaker.ReasonOpen {
+		// the dependency was not called
+		return false
+	}
+	return slices.Contains(p.RetryOn, status.Code(err))
+}
+
+// UnaryClientInterceptor bounds each attempt of a call with the timeout of p,
+// and makes failed calls again as p allows.
+func (p Policy) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		backoff := p.Backoff
+		for attempt := 0; ; attempt++ {
+			err := p.attempt(ctx, method, req, reply, cc, invoker, opts...)
+			if err == nil || attempt >= p.Retries || !p.retryable(err) {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			retries.Add(ctx, 1, metric.WithAttributes(attribute.String("dependency", p.name), attribute.String("code", status.Code(err).String())))
+			backoff *= 2
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
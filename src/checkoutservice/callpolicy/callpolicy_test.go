@@ -0,0 +1,158 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package callpolicy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/breaker"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+var deps = []string{"cart", "email", "payment"}
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`{"default": {"timeout": "5s"},
+		"cart": {"retries": 2, "retry_on": ["UNAVAILABLE", "DEADLINE_EXCEEDED"]},
+		"payment": {"timeout": "0s", "backoff": "1s"}}`), deps...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"cart", "timeout 5s, 2 retries on [Unavailable DeadlineExceeded] after 100ms"},
+		{"payment", "timeout 0s"},
+		{"email", "timeout 5s"},
+	} {
+		if got := p.For(tt.name).String(); got != tt.want {
+			t.Errorf("For(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := p.For("payment").Backoff; got != time.Second {
+		t.Errorf("payment backoff = %v, want 1s", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, config := range []string{
+		`[]`,
+		`{"shipping": {}}`,
+		`{"cart": {"timeout": "soon"}}`,
+		`{"cart": {"retries": -1}}`,
+		`{"default": {"retry_on": ["SOMETIMES"]}}`,
+		`{"cart": {"backoff": "-1s"}}`,
+	} {
+		if _, err := Parse([]byte(config), deps...); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", config)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(file, []byte(`{"email": {"timeout": "2s"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, "timeout 10s", false},
+		{"env", map[string]string{"CALL_POLICIES": `{"default": {"retries": 1}}`}, "timeout 10s, 1 retries on [Unavailable] after 100ms", false},
+		{"file", map[string]string{"CALL_POLICIES_FILE": file}, "timeout 10s; email: timeout 2s", false},
+		{"both", map[string]string{"CALL_POLICIES": `{}`, "CALL_POLICIES_FILE": file}, "", true},
+		{"missing file", map[string]string{"CALL_POLICIES_FILE": file + ".missing"}, "", true},
+		{"invalid", map[string]string{"CALL_POLICIES": `{"cart": {"timeout": 5}}`}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CALL_POLICIES", "CALL_POLICIES_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+			p, err := FromEnv(deps...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && p.String() != tt.want {
+				t.Errorf("FromEnv() = %q, want %q", p, tt.want)
+			}
+		})
+	}
+}
+
+// failing returns an invoker that fails the first n calls with err, and
+// counts the calls in *calls.
+func failing(n int, err error, calls *int) grpc.UnaryInvoker {
+	return func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		*calls++
+		if *calls <= n {
+			return err
+		}
+		return nil
+	}
+}
+
+func TestUnaryClientInterceptorRetries(t *testing.T) {
+	p := Policy{Retries: 2, RetryOn: []codes.Code{codes.Unavailable}, Backoff: time.Millisecond}
+	for _, tt := range []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{"recovers", 2, status.Error(codes.Unavailable, "down"), 3, codes.OK},
+		{"gives up", 5, status.Error(codes.Unavailable, "down"), 3, codes.Unavailable},
+		{"not retryable", 5, status.Error(codes.InvalidArgument, "bad"), 1, codes.InvalidArgument},
+		{"breaker open", 5, rpcerrors.Errorf(codes.Unavailable, breaker.ReasonOpen, "circuit open"), 1, codes.Unavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := p.UnaryClientInterceptor()(context.Background(), "/hipstershop.CartService/GetCart", nil, nil, nil, failing(tt.failures, tt.err, &calls))
+			if status.Code(err) != tt.wantCode || calls != tt.wantCalls {
+				t.Errorf("call = %v after %d calls, want %s after %d", err, calls, tt.wantCode, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptorTimesOutAttempts(t *testing.T) {
+	p := Policy{Timeout: 10 * time.Millisecond, Retries: 1, RetryOn: []codes.Code{codes.DeadlineExceeded}}
+	calls := 0
+	hung := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		calls++
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := p.UnaryClientInterceptor()(ctx, "/hipstershop.ShippingService/ShipOrder", nil, nil, nil, hung); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("hung call = %v, want DeadlineExceeded", err)
+	}
+	if calls != 2 {
+		t.Errorf("%d attempts, want 2", calls)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+)
+
+// CancelOrder cancels an order that has not shipped. The order is marked
+// cancelled first, so that it cannot ship while it is undone; its charge is
+// then refunded and its shipment cancelled like those of an order that could
+// not be recorded, and logged in order_compensations with the cancellation as
+// their cause. Refunds and shipment cancellations are idempotent, and an
+// order can be cancelled again, so cancelling an order retries the steps that
+// failed.
+
+// Cancellation is the outcome of cancelling an order.
+type Cancellation struct {
+	Order *Order
+	// Refund and Shipment undid the order's charge and shipment, nil if
+	// there was nothing to undo.
+	Refund, Shipment *Compensation
+}
+
+// cancelOrder cancels an order because of reason, failing with
+// errInvalidStatusTransition if it has shipped.
+func (cs *checkoutService) cancelOrder(ctx context.Context, orderID, reason string) (Cancellation, error) {
+	o, err := cs.orderStore.UpdateOrderStatus(ctx, orderID, StatusCancelled)
+	if err != nil {
+		return Cancellation{}, err
+	}
+	cause := "order cancelled"
+	if reason != "" {
+		cause += ": " + reason
+	}
+	steps := placedSteps{
+		orderID:       o.OrderID,
+		userID:        o.UserID,
+		transactionID: o.PaymentTransactionID,
+		total:         o.totalPaid(),
+		trackingID:    o.ShippingTrackingID,
+	}
+	c := Cancellation{Order: o}
+	for _, undone := range cs.undo(ctx, steps, cause) {
+		switch undone.Step {
+		case compensateRefund:
+			c.Refund = &undone
+		case compensateShipment:
+			c.Shipment = &undone
+		}
+	}
+	return c, nil
+}
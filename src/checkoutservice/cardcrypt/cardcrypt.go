@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cardcrypt encrypts the card data that checkoutservice keeps with
+// its orders, by envelope encryption: every value is sealed with AES-256-GCM
+// under a data key of its own, and the data key is stored with it, wrapped by
+// a key encryption key that never leaves its KeyWrapper. The key encryption
+// key is a local AES key here, and can live in a cloud KMS by implementing
+// KeyWrapper.
+package cardcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// KeySize is the size of local keys and data keys, in bytes.
+const KeySize = 32
+
+// KeyWrapper wraps data keys with a key encryption key.
+type KeyWrapper interface {
+	// Wrap encrypts dek and returns the ID of the key it used, which
+	// Unwrap is given back along with the wrapped key.
+	Wrap(ctx context.Context, dek []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKey is a KeyWrapper holding its key encryption key in memory.
+type LocalKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewLocalKey returns a LocalKey named id for the KeySize bytes of key.
+func NewLocalKey(id string, key []byte) (*LocalKey, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKey{id: id, aead: aead}, nil
+}
+
+// ParseKey decodes a base64-encoded local key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key is %d bytes long, want %d", len(key), KeySize)
+	}
+	return key, nil
+}
+
+func (k *LocalKey) Wrap(_ context.Context, dek []byte) (string, []byte, error) {
+	wrapped, err := seal(k.aead, dek, []byte(k.id))
+	return k.id, wrapped, err
+}
+
+func (k *LocalKey) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != k.id {
+		return nil, fmt.Errorf("data key is wrapped by key %q, not %q", keyID, k.id)
+	}
+	return open(k.aead, wrapped, []byte(keyID))
+}
+
+// Cipher seals and opens values in envelopes.
+type Cipher struct {
+	keys KeyWrapper
+}
+
+// New returns a Cipher wrapping its data keys with keys.
+func New(keys KeyWrapper) *Cipher {
+	return &Cipher{keys: keys}
+}
+
+// FromEnv returns a Cipher for the local key in CARD_ENCRYPTION_KEY, or the
+// secret that CARD_ENCRYPTION_KEY_SECRET references, named by
+// CARD_ENCRYPTION_KEY_ID ("local" by default). It returns nil if no key is
+// set.
+func FromEnv(ctx context.Context, secretStore *secrets.Manager) (*Cipher, error) {
+	v, err := secretStore.Value(ctx, "CARD_ENCRYPTION_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if v == "" {
+		return nil, nil
+	}
+	key, err := ParseKey(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CARD_ENCRYPTION_KEY: %w", err)
+	}
+	id := "local"
+	if v, err := secretStore.Value(ctx, "CARD_ENCRYPTION_KEY_ID"); err != nil {
+		return nil, err
+	} else if v != "" {
+		id = v
+	}
+	local, err := NewLocalKey(id, key)
+	if err != nil {
+		return nil, err
+	}
+	return New(local), nil
+}
+
+// envelope is the encoding of a sealed value.
+type envelope struct {
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"wk"`
+	Data       []byte `json:"data"`
+}
+
+// Seal encrypts plaintext, binding it to associated, which must be given
+// back to Open, so that an envelope cannot be moved to another record.
+func (c *Cipher) Seal(ctx context.Context, plaintext, associated []byte) ([]byte, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	data, err := seal(aead, plaintext, associated)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := c.keys.Wrap(ctx, dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return json.Marshal(envelope{KeyID: keyID, WrappedKey: wrapped, Data: data})
+}
+
+// Open decrypts an envelope sealed by Seal with the same associated data.
+func (c *Cipher) Open(ctx context.Context, sealed, associated []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	dek, err := c.keys.Unwrap(ctx, env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, env.Data, associated)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key is %d bytes long, want %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, associated []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, associated), nil
+}
+
+var errTampered = errors.New("ciphertext was tampered with or sealed with another key")
+
+func open(aead cipher.AEAD, sealed, associated []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errTampered
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, associated)
+	if err != nil {
+		return nil, errTampered
+	}
+	return plaintext, nil
+}
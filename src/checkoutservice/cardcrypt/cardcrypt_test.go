@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cardcrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testCipher(t *testing.T, id string, fill byte) *Cipher {
+	t.Helper()
+	k, err := NewLocalKey(id, bytes.Repeat([]byte{fill}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(k)
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	c := testCipher(t, "local", 1)
+	sealed, err := c.Seal(ctx, []byte("****-****-****-0454"), []byte("order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("0454")) {
+		t.Errorf("envelope %s contains the plaintext", sealed)
+	}
+	got, err := c.Open(ctx, sealed, []byte("order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "****-****-****-0454" {
+		t.Errorf("Open() = %q", got)
+	}
+
+	again, err := c.Seal(ctx, []byte("****-****-****-0454"), []byte("order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Error("sealing the same value twice gave the same envelope")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	ctx := context.Background()
+	c := testCipher(t, "local", 1)
+	sealed, err := c.Seal(ctx, []byte("secret"), []byte("order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name       string
+		c          *Cipher
+		sealed     []byte
+		associated string
+	}{
+		{"other record", c, sealed, "order-2"},
+		{"other key", testCipher(t, "local", 2), sealed, "order-1"},
+		{"other key ID", testCipher(t, "other", 1), sealed, "order-1"},
+		{"not an envelope", c, []byte("secret"), "order-1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.c.Open(ctx, tt.sealed, []byte(tt.associated)); err == nil {
+				t.Errorf("Open() = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))); err != nil {
+		t.Error(err)
+	}
+	for _, s := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseKey(s); err == nil || !strings.Contains(err.Error(), "key") {
+			t.Errorf("ParseKey(%q) error = %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// The card an order is paid with is tokenized as soon as the order is placed:
+// from then on checkout keeps and passes around only its token, brand and
+// last four digits, and the payment provider exchanges the token for the card
+// details only to charge it. The details are deleted once the order is
+// placed, or expire after cardTokenTTL if that fails, and orders keep only
+// the token and what can be shown of the card.
+//
+// With a SQL order store and a card encryption key, the details are kept in
+// card_vault sealed by the store's cipher, so that every pod of a region can
+// charge the card; otherwise they are kept in memory. A tokenization service
+// can hold them instead by implementing cardvault.Vault.
+
+// cardTokenTTL is how long the details of a card are kept at most, well past
+// the deadline of any PlaceOrder request.
+const cardTokenTTL = 10 * time.Minute
+
+// newCardVault returns the vault of the cards orders are paid with, which is
+// kept in store if it is a SQL store with a card encryption key.
+func newCardVault(store *OrderStore) cardvault.Vault {
+	if store == nil || store.cards == nil {
+		return cardvault.NewMemory(cardTokenTTL)
+	}
+	return &sqlCardVault{store: store, ttl: cardTokenTTL, now: time.Now}
+}
+
+// sqlCardVault is a cardvault.Vault keeping card details in the card_vault
+// table of a SQL order store, sealed by its cipher with the token as
+// associated data.
+type sqlCardVault struct {
+	store *OrderStore
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+func (v *sqlCardVault) String() string { return "encrypted in the order database" }
+
+func (v *sqlCardVault) Tokenize(ctx context.Context, card *pb.CreditCardInfo) (_ cardvault.Card, err error) {
+	ctx, span := startStoreSpan(ctx, "Tokenize")
+	defer func() { endSpan(span, err) }()
+	token, err := cardvault.NewToken()
+	if err != nil {
+		return cardvault.Card{}, err
+	}
+	b, err := proto.Marshal(card)
+	if err != nil {
+		return cardvault.Card{}, fmt.Errorf("failed to encode card: %w", err)
+	}
+	envelope, err := v.store.cards.Seal(ctx, b, []byte(token))
+	if err != nil {
+		return cardvault.Card{}, fmt.Errorf("failed to encrypt card: %w", err)
+	}
+	now := v.now()
+	err = withDBTimeout(ctx, v.store.timeouts.save, "Tokenize", func(ctx context.Context) error {
+		return retryDB(ctx, "tokenize card", func() error {
+			// expired cards are deleted as new ones come in
+			if _, err := v.store.db.ExecContext(ctx, `DELETE FROM card_vault WHERE expires_at <= $1`, now); err != nil {
+				return err
+			}
+			_, err := v.store.db.ExecContext(ctx, `
+                INSERT INTO card_vault (token, envelope, created_at, expires_at) VALUES ($1, $2, $3, $4)
+            `+v.store.dialect.ignoreDuplicate("token"), token, envelope, now, now.Add(v.ttl))
+			return err
+		})
+	})
+	if err != nil {
+		return cardvault.Card{}, fmt.Errorf("failed to store card: %w", err)
+	}
+	c := cardvault.Describe(card)
+	c.Token = token
+	return c, nil
+}
+
+func (v *sqlCardVault) Detokenize(ctx context.Context, token string) (_ *pb.CreditCardInfo, err error) {
+	ctx, span := startStoreSpan(ctx, "Detokenize")
+	defer func() { endSpan(span, err) }()
+	var envelope []byte
+	err = withDBTimeout(ctx, v.store.timeouts.read, "Detokenize", func(ctx context.Context) error {
+		return retryDB(ctx, "detokenize card", func() error {
+			return v.store.db.QueryRowContext(ctx, `
+                SELECT envelope FROM card_vault WHERE token = $1 AND expires_at > $2
+            `, token, v.now()).Scan(&envelope)
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, cardvault.ErrUnknownToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read card: %w", err)
+	}
+	b, err := v.store.cards.Open(ctx, envelope, []byte(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt card: %w", err)
+	}
+	card := &pb.CreditCardInfo{}
+	if err := proto.Unmarshal(b, card); err != nil {
+		return nil, fmt.Errorf("failed to decode card: %w", err)
+	}
+	return card, nil
+}
+
+func (v *sqlCardVault) Delete(ctx context.Context, token string) (err error) {
+	ctx, span := startStoreSpan(ctx, "DeleteCard")
+	defer func() { endSpan(span, err) }()
+	return withDBTimeout(ctx, v.store.timeouts.save, "DeleteCard", func(ctx context.Context) error {
+		return retryDB(ctx, "delete card", func() error {
+			_, err := v.store.db.ExecContext(ctx, `DELETE FROM card_vault WHERE token = $1`, token)
+			return err
+		})
+	})
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cardvault exchanges the card details orders are paid with for
+// tokens, so that checkoutservice keeps and passes around only a token and
+// what can be shown of the card: its brand, its last four digits and its
+// bank identification number. The details are given back only to the
+// payment provider that charges the card.
+//
+// A Vault holds the details. Memory keeps them in memory, which is enough for
+// tokens that live as long as the request that placed the order; an external
+// tokenization service can hold them instead by implementing Vault.
+package cardvault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// tokenPrefix starts every token, so that tokens are not mistaken for card
+// numbers.
+const tokenPrefix = "tok_"
+
+// ErrUnknownToken is returned for tokens that were never issued, or whose
+// card details were deleted or have expired.
+var ErrUnknownToken = errors.New("unknown card token")
+
+// Card is a tokenized card.
+type Card struct {
+	Token string
+	// Brand is the card network, such as "visa", or "" if it is not known.
+	Brand string
+	Last4 string
+	// BIN is the bank identification number of the card, its first six
+	// digits, which name its issuer.
+	BIN string
+}
+
+// Vault holds the details of cards behind their tokens.
+type Vault interface {
+	// Tokenize keeps the details of card and returns its token.
+	Tokenize(ctx context.Context, card *pb.CreditCardInfo) (Card, error)
+	// Detokenize returns the details of the card of token. It fails with
+	// ErrUnknownToken if there are none.
+	Detokenize(ctx context.Context, token string) (*pb.CreditCardInfo, error)
+	// Delete deletes the details of the card of token, once they are no
+	// longer needed.
+	Delete(ctx context.Context, token string) error
+}
+
+// Describe returns what can be shown of card, without a token.
+func Describe(card *pb.CreditCardInfo) Card {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(card.GetCreditCardNumber())
+	c := Card{Brand: Brand(digits)}
+	if len(digits) >= 4 {
+		c.Last4 = digits[len(digits)-4:]
+	}
+	if len(digits) >= 6 {
+		c.BIN = digits[:6]
+	}
+	return c
+}
+
+// Brand returns the network of the card with the given number, or "" if it
+// is not known.
+func Brand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case len(number) >= 2 && number[:2] >= "51" && number[:2] <= "55",
+		len(number) >= 4 && number[:4] >= "2221" && number[:4] <= "2720":
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	case strings.HasPrefix(number, "6011"), strings.HasPrefix(number, "65"):
+		return "discover"
+	}
+	return ""
+}
+
+// NewToken returns a new random token.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate card token: %w", err)
+	}
+	return tokenPrefix + hex.EncodeToString(b), nil
+}
+
+// Memory is a Vault keeping card details in memory, for TTL at most.
+type Memory struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	cards map[string]memoryCard
+}
+
+type memoryCard struct {
+	card    *pb.CreditCardInfo
+	expires time.Time
+}
+
+// NewMemory returns a Memory vault whose tokens expire after ttl.
+func NewMemory(ttl time.Duration) *Memory {
+	return &Memory{ttl: ttl, now: time.Now, cards: make(map[string]memoryCard)}
+}
+
+func (m *Memory) String() string { return "memory" }
+
+func (m *Memory) Tokenize(_ context.Context, card *pb.CreditCardInfo) (Card, error) {
+	token, err := NewToken()
+	if err != nil {
+		return Card{}, err
+	}
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for t, c := range m.cards {
+		if !now.Before(c.expires) {
+			delete(m.cards, t)
+		}
+	}
+	m.cards[token] = memoryCard{card: proto.Clone(card).(*pb.CreditCardInfo), expires: now.Add(m.ttl)}
+	c := Describe(card)
+	c.Token = token
+	return c, nil
+}
+
+func (m *Memory) Detokenize(_ context.Context, token string) (*pb.CreditCardInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.cards[token]
+	if !ok || !m.now().Before(c.expires) {
+		return nil, ErrUnknownToken
+	}
+	return proto.Clone(c.card).(*pb.CreditCardInfo), nil
+}
+
+func (m *Memory) Delete(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cards, token)
+	return nil
+}
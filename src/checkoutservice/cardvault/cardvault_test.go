@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cardvault
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestDescribe(t *testing.T) {
+	for number, want := range map[string]Card{
+		"4432-8015-6152-0454": {Brand: "visa", Last4: "0454", BIN: "443280"},
+		"5555 5555 5555 4444": {Brand: "mastercard", Last4: "4444", BIN: "555555"},
+		"2223003122003222":    {Brand: "mastercard", Last4: "3222", BIN: "222300"},
+		"378282246310005":     {Brand: "amex", Last4: "0005", BIN: "378282"},
+		"4432":                {Brand: "visa", Last4: "4432"},
+		"":                    {},
+	} {
+		if got := Describe(&pb.CreditCardInfo{CreditCardNumber: number}); got != want {
+			t.Errorf("Describe(%q) = %+v, want %+v", number, got, want)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	v := NewMemory(time.Minute)
+	now := time.Unix(1700000000, 0)
+	v.now = func() time.Time { return now }
+	card := &pb.CreditCardInfo{CreditCardNumber: "4432-8015-6152-0454", CreditCardCvv: 672, CreditCardExpirationMonth: 1, CreditCardExpirationYear: 2030}
+
+	c, err := v.Tokenize(ctx, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.Token, tokenPrefix) || strings.Contains(c.Token, "0454") || c.Last4 != "0454" || c.Brand != "visa" {
+		t.Errorf("Tokenize() = %+v, want a token and what can be shown of the card", c)
+	}
+	got, err := v.Detokenize(ctx, c.Token)
+	if err != nil || !proto.Equal(got, card) {
+		t.Errorf("Detokenize() = %v, %v, want %v", got, err, card)
+	}
+	if _, err := v.Detokenize(ctx, "tok_unknown"); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("Detokenize() of an unknown token = %v, want %v", err, ErrUnknownToken)
+	}
+
+	if err := v.Delete(ctx, c.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Detokenize(ctx, c.Token); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("Detokenize() after Delete() = %v, want %v", err, ErrUnknownToken)
+	}
+
+	c, _ = v.Tokenize(ctx, card)
+	now = now.Add(time.Minute)
+	if _, err := v.Detokenize(ctx, c.Token); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("Detokenize() of an expired token = %v, want %v", err, ErrUnknownToken)
+	}
+	v.Tokenize(ctx, card)
+	if _, ok := v.cards[c.Token]; ok {
+		t.Error("expired card details were kept")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardcrypt"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestNewCardVault(t *testing.T) {
+	store := newSQLiteStore(t)
+	if _, ok := newCardVault(nil).(*cardvault.Memory); !ok {
+		t.Error("newCardVault() without a store is not kept in memory")
+	}
+	if _, ok := newCardVault(store).(*cardvault.Memory); !ok {
+		t.Error("newCardVault() of a store without a card encryption key is not kept in memory")
+	}
+	store.cards = testCardCipher(t)
+	if _, ok := newCardVault(store).(*sqlCardVault); !ok {
+		t.Error("newCardVault() of a store with a card encryption key is not kept in the store")
+	}
+}
+
+func TestSQLCardVault(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	store.cards = testCardCipher(t)
+	v := newCardVault(store).(*sqlCardVault)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	card := contract.ValidCard()
+
+	c, err := v.Tokenize(ctx, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.Token, "tok_") || c.Brand != "visa" || c.Last4 != "0454" || c.BIN != "443280" {
+		t.Errorf("Tokenize() = %+v, want a token and what can be shown of the card", c)
+	}
+	var envelope []byte
+	if err := store.db.QueryRowContext(ctx, `SELECT envelope FROM card_vault WHERE token = $1`, c.Token).Scan(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(envelope, []byte("8015")) {
+		t.Error("card details are stored in the clear")
+	}
+	got, err := v.Detokenize(ctx, c.Token)
+	if err != nil || !proto.Equal(got, card) {
+		t.Errorf("Detokenize() = %v, %v, want %v", got, err, card)
+	}
+
+	if err := v.Delete(ctx, c.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Detokenize(ctx, c.Token); !errors.Is(err, cardvault.ErrUnknownToken) {
+		t.Errorf("Detokenize() after Delete() = %v, want %v", err, cardvault.ErrUnknownToken)
+	}
+
+	expired, _ := v.Tokenize(ctx, card)
+	now = now.Add(cardTokenTTL)
+	if _, err := v.Detokenize(ctx, expired.Token); !errors.Is(err, cardvault.ErrUnknownToken) {
+		t.Errorf("Detokenize() of an expired token = %v, want %v", err, cardvault.ErrUnknownToken)
+	}
+	if _, err := v.Tokenize(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM card_vault WHERE token = $1`, expired.Token).Scan(&n); err != nil || n != 0 {
+		t.Errorf("%d expired cards kept (%v), want none", n, err)
+	}
+}
+
+func TestPlaceOrderKeepsOnlyCardToken(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newSQLiteStore(t)
+	cs.orderStore = store
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	order, _, err := cs.placeOrder(ctx, orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the payment service is given the card details
+	if charges := backends.payment.Charges(); len(charges) != 1 || !proto.Equal(charges[0].GetCreditCard(), contract.ValidCard()) {
+		t.Errorf("charges = %v, want one with the card details", charges)
+	}
+	o, err := store.GetOrder(ctx, order.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(o.CardToken, "tok_") || o.CardBrand != "visa" || o.maskedCardNumber() != "****-****-****-0454" {
+		t.Errorf("order was stored with card %q, %q, %q; want its token, brand and masked number", o.CardToken, o.CardBrand, o.maskedCardNumber())
+	}
+	// and are gone once the order is placed
+	if _, err := cs.vault.Detokenize(ctx, o.CardToken); !errors.Is(err, cardvault.ErrUnknownToken) {
+		t.Errorf("Detokenize() after the order was placed = %v, want %v", err, cardvault.ErrUnknownToken)
+	}
+}
+
+// testCardCipher returns a card cipher with a test key.
+func testCardCipher(t *testing.T) *cardcrypt.Cipher {
+	t.Helper()
+	key, err := cardcrypt.NewLocalKey("test", make([]byte, cardcrypt.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cardcrypt.New(key)
+}
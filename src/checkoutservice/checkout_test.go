@@ -0,0 +1,289 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/emailtemplate"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+type fakeBackends struct {
+	catalog  *fakes.ProductCatalog
+	cart     *fakes.Cart
+	shipping *fakes.Shipping
+	payment  *fakes.Payment
+}
+
+// newFakeCheckoutService returns a checkout service whose dependencies are
+// fakes. The email service is not faked, so confirmations fail to send.
+func newFakeCheckoutService(t *testing.T) (*checkoutService, fakeBackends) {
+	t.Helper()
+	backends := fakeBackends{catalog: fakes.NewProductCatalog(), cart: fakes.NewCart(), shipping: fakes.NewShipping(), payment: fakes.NewPayment()}
+	conn := contract.Serve(t, func(srv *grpc.Server) {
+		backends.catalog.Register(srv)
+		fakes.NewCurrency(nil).Register(srv)
+		backends.cart.Register(srv)
+		backends.shipping.Register(srv)
+		backends.payment.Register(srv)
+	})
+	renderer, err := emailtemplate.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := cardvault.NewMemory(cardTokenTTL)
+	return &checkoutService{
+		productCatalogSvcConn: conn,
+		cartSvcConn:           conn,
+		currencySvcConn:       conn,
+		shippingSvcConn:       conn,
+		emailSvcConn:          conn,
+		paymentSvcConn:        conn,
+		payments:              &paymentServiceProvider{conn: conn, cards: vault},
+		vault:                 vault,
+		emailRenderer:         renderer,
+	}, backends
+}
+
+// tokenize tokenizes card in the vault of cs.
+func tokenize(t *testing.T, cs *checkoutService, card *pb.CreditCardInfo) cardvault.Card {
+	t.Helper()
+	c, err := cs.vault.Tokenize(context.Background(), card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// TestConvertRPCPassesContext checks that conversions are made with the
+// caller's context, and so carry its deadline, trace and metadata.
+func TestConvertRPCPassesContext(t *testing.T) {
+	cs, _ := newFakeCheckoutService(t)
+	usd := &pb.Money{CurrencyCode: "USD", Units: 10}
+	if _, err := cs.convertRPC(context.Background(), usd, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cs.convertRPC(ctx, usd, "EUR"); rpcerrors.Classify(err).Code != codes.Canceled {
+		t.Errorf("convertRPC() with a canceled context = %v, want Canceled", err)
+	}
+}
+
+func TestPlaceOrderWithFakes(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	ctx := context.Background()
+	for _, it := range []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}, {ProductId: "66VCHSJNUP", Quantity: 1}} {
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: it}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order, total, err := cs.placeOrder(ctx, orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 x 19.99 + 18.99 + 8.99 shipping
+	if total.GetCurrencyCode() != "USD" || total.GetUnits() != 67 || total.GetNanos() != 960000000 {
+		t.Errorf("total = %v, want 67.96 USD", total)
+	}
+	if len(order.GetItems()) != 2 || order.GetShippingTrackingId() == "" {
+		t.Errorf("order = %v, want 2 items and a tracking ID", order)
+	}
+	if charges := backends.payment.Charges(); len(charges) != 1 || charges[0].GetAmount().GetUnits() != 67 {
+		t.Errorf("charges = %v, want one of 67.96 USD", charges)
+	}
+	if shipped := backends.shipping.Shipped(); len(shipped) != 1 {
+		t.Errorf("%d orders shipped, want 1", len(shipped))
+	}
+	if cart, _ := backends.cart.GetCart(ctx, &pb.GetCartRequest{UserId: "user-1"}); len(cart.GetItems()) != 0 {
+		t.Errorf("cart after checkout = %v, want empty", cart.GetItems())
+	}
+}
+
+// baggageRecorder is a ChargeProvider that records the order ID in the
+// baggage of the charges it makes.
+type baggageRecorder struct {
+	ChargeProvider
+	orderIDs []string
+}
+
+func (r *baggageRecorder) Charge(ctx context.Context, orderID, idempotencyKey string, amount *pb.Money, card cardvault.Card) (string, error) {
+	r.orderIDs = append(r.orderIDs, baggage.FromContext(ctx).Member(instrumentation.BaggageOrderID).Value())
+	return r.ChargeProvider.Charge(ctx, orderID, idempotencyKey, amount, card)
+}
+
+func TestPlaceOrderCarriesOrderIDInBaggage(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	rec := &baggageRecorder{ChargeProvider: cs.payments}
+	cs.payments = rec
+	ctx := context.Background()
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	order, _, err := cs.placeOrder(ctx, orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{order.GetOrderId()}; !reflect.DeepEqual(rec.orderIDs, want) {
+		t.Errorf("order IDs in the baggage of charges = %q, want %q", rec.orderIDs, want)
+	}
+}
+
+func TestPlaceOrderConvertsWithFakes(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	ctx := context.Background()
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "1YMWWN1N4O", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	_, total, err := cs.placeOrder(ctx, orderRequest{userID: "user-1", userCurrency: "EUR", card: contract.ValidCard()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 109.99 / 1.1305 + 8.99 / 1.1305, each rounded to the nano
+	if total.GetCurrencyCode() != "EUR" || total.GetUnits() != 105 || total.GetNanos() != 245466608 {
+		t.Errorf("total = %v, want 105.245466608 EUR", total)
+	}
+
+	card := contract.ValidCard()
+	card.CreditCardExpirationYear = 2000
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "1YMWWN1N4O", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cs.placeOrder(ctx, orderRequest{userID: "user-1", userCurrency: "EUR", card: card}); err == nil {
+		t.Error("placeOrder with an expired card succeeded")
+	}
+	if shipped := backends.shipping.Shipped(); len(shipped) != 1 {
+		t.Errorf("%d orders shipped, want only the paid one", len(shipped))
+	}
+}
+
+// unsavedOrders is an order store that fails to save orders.
+type unsavedOrders struct {
+	*memoryOrderStore
+}
+
+func (unsavedOrders) SaveOrder(context.Context, orderRecord) error {
+	return errors.New("database unavailable")
+}
+
+func TestPlaceOrderCompensatesUnsavedOrders(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	store := unsavedOrders{newMemoryOrderStore()}
+	cs.orderStore = store
+	ctx := context.Background()
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := cs.placeOrder(ctx, orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	})
+	if c := rpcerrors.Classify(err); c.Code != codes.Unavailable || c.Reason != reasonOrderNotRecorded {
+		t.Fatalf("placeOrder with an unavailable store: got %v, want Unavailable with reason %s", err, reasonOrderNotRecorded)
+	}
+	if refunds := backends.payment.Refunds(); len(refunds) != 1 || refunds[0] != "fake-transaction-1" {
+		t.Errorf("refunds = %v, want the charge refunded", refunds)
+	}
+	if cancelled := backends.shipping.Cancelled(); len(cancelled) != 1 {
+		t.Errorf("cancelled shipments = %v, want the shipment cancelled", cancelled)
+	}
+	if cart, _ := backends.cart.GetCart(ctx, &pb.GetCartRequest{UserId: "user-1"}); len(cart.GetItems()) != 1 {
+		t.Errorf("cart after a compensated order = %v, want it kept", cart.GetItems())
+	}
+	var steps []string
+	for _, c := range store.compensations {
+		if c.Err != nil {
+			t.Errorf("compensation %s of %s failed: %v", c.Step, c.Reference, c.Err)
+		}
+		steps = append(steps, c.Step)
+	}
+	if want := []string{compensateShipment, compensateRefund}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("compensations = %v, want %v", steps, want)
+	}
+	if o, err := store.GetOrder(ctx, store.compensations[0].OrderID); err != nil || o.Status != StatusFailed {
+		t.Errorf("pending record of the compensated order = %+v, %v; want it failed", o, err)
+	}
+}
+
+func TestPlaceOrderQueuesUnsavedOrders(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = unsavedOrders{newMemoryOrderStore()}
+	queue, err := newOrderQueue(t.TempDir(), func(context.Context, orderRecord) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.store = &OrderStore{}
+	cs.orderQueue = queue
+	ctx := context.Background()
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	order, _, err := cs.placeOrder(ctx, orderRequest{userID: "user-1", userCurrency: "USD", card: contract.ValidCard()})
+	if err != nil {
+		t.Fatalf("placeOrder with an unavailable store and a queue: %v", err)
+	}
+	if refunds := backends.payment.Refunds(); len(refunds) != 0 {
+		t.Errorf("refunds = %v, want none", refunds)
+	}
+	if cart, _ := backends.cart.GetCart(ctx, &pb.GetCartRequest{UserId: "user-1"}); len(cart.GetItems()) != 0 {
+		t.Errorf("cart after a queued order = %v, want empty", cart.GetItems())
+	}
+	names, err := queue.entries(queue.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Fatalf("queued orders = %v, want 1", names)
+	}
+	entry, err := readEntry(filepath.Join(queue.dir, names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Record.Order.OrderID != order.GetOrderId() || len(entry.Record.Items) != 1 {
+		t.Errorf("queued record = %+v, want order %s with 1 item", entry.Record, order.GetOrderId())
+	}
+}
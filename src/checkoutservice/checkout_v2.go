@@ -0,0 +1,689 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+const (
+	// idempotencyTTL is how long an order can be replayed by its
+	// idempotency key.
+	idempotencyTTL = 24 * time.Hour
+	// maxPlacedOrders is how many idempotency keys are remembered in
+	// memory; older ones are replayed from the order store, if any.
+	maxPlacedOrders = 10000
+	// placedOrdersSweepInterval is how often expired idempotency keys are
+	// forgotten.
+	placedOrdersSweepInterval = time.Minute
+	// maxIdempotencyKeyLen is the longest idempotency key the database
+	// stores.
+	maxIdempotencyKeyLen = 255
+)
+
+// Reasons of the errors returned by the v2 API.
+const (
+	reasonIdempotencyKeyReused    = "IDEMPOTENCY_KEY_REUSED"
+	reasonPromoCodeInvalid        = "PROMO_CODE_INVALID"
+	reasonOrdersNotStored         = "ORDERS_NOT_STORED"
+	reasonStatusTransition        = "ORDER_STATUS_TRANSITION_INVALID"
+	reasonLoyaltyNotOffered       = "LOYALTY_NOT_OFFERED"
+	reasonInsufficientPoints      = "INSUFFICIENT_POINTS"
+	reasonPointsRedemptionInvalid = "POINTS_REDEMPTION_INVALID"
+)
+
+// checkoutServiceV2 serves hipstershop.v2.CheckoutService on top of the
+// same order flow as the v1 API.
+type checkoutServiceV2 struct {
+	pbv2.UnimplementedCheckoutServiceServer
+
+	cs     *checkoutService
+	placed *placedOrders
+}
+
+func newCheckoutServiceV2(cs *checkoutService) *checkoutServiceV2 {
+	return &checkoutServiceV2{cs: cs, placed: newPlacedOrders()}
+}
+
+func (s *checkoutServiceV2) PlaceOrder(ctx context.Context, req *pbv2.PlaceOrderRequest) (*pbv2.PlaceOrderResponse, error) {
+	log.WithContext(ctx).WithFields(logging.Fields{logging.UserIDKey: req.GetUserId(), "user_currency": req.GetUserCurrency()}).Info("[v2.PlaceOrder]")
+
+	resp, err := s.placeOrder(ctx, req)
+	recordPlaceOrder(ctx, apiV2, err)
+	return resp, err
+}
+
+func (s *checkoutServiceV2) placeOrder(ctx context.Context, req *pbv2.PlaceOrderRequest) (*pbv2.PlaceOrderResponse, error) {
+	if req.GetIdempotencyKey() == "" && !req.GetDryRun() {
+		return nil, status.Error(codes.InvalidArgument, "idempotency_key is required")
+	}
+	if len(req.GetIdempotencyKey()) > maxIdempotencyKeyLen {
+		return nil, status.Errorf(codes.InvalidArgument, "idempotency_key is longer than %d bytes", maxIdempotencyKeyLen)
+	}
+	card := req.GetPaymentMethod().GetCreditCard()
+	if card == nil {
+		return nil, status.Error(codes.InvalidArgument, "payment_method is required")
+	}
+	if req.GetDryRun() {
+		// nothing is placed, so there is nothing to replay
+		return s.placeNewOrder(ctx, req, card, [sha256.Size]byte{})
+	}
+	return s.placed.do(ctx, req, func() (*pbv2.PlaceOrderResponse, error) {
+		fingerprint, err := requestFingerprint(req)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to fingerprint request: %v", err)
+		}
+		resp, err := s.storedOrder(ctx, req, fingerprint)
+		if resp == nil && err == nil {
+			resp, err = s.placeNewOrder(ctx, req, card, fingerprint)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the token is not stored with the response, so that it follows
+		// the current key
+		if resp.LookupToken, err = s.cs.lookups.token(ctx, resp.GetOrder().GetOrderId(), req.GetEmail()); err != nil {
+			log.WithContext(ctx).Warnf("failed to sign lookup token of order %s: %v", resp.GetOrder().GetOrderId(), err)
+		}
+		return resp, nil
+	})
+}
+
+// placeNewOrder places the order of req, which no earlier request placed.
+func (s *checkoutServiceV2) placeNewOrder(ctx context.Context, req *pbv2.PlaceOrderRequest, card *pb.CreditCardInfo, fingerprint [sha256.Size]byte) (*pbv2.PlaceOrderResponse, error) {
+	// an unknown code is rejected rather than silently charging the full
+	// price
+	var promo *promotion
+	if code := req.GetPromoCode(); code != "" {
+		p, ok := s.cs.promotions.lookup(code)
+		if !ok {
+			return nil, rpcerrors.Errorf(codes.InvalidArgument, reasonPromoCodeInvalid, "promo code %q is not valid", code)
+		}
+		promo = &p
+	}
+	var idem *IdempotencyRecord
+	if !req.GetDryRun() {
+		idem = &IdempotencyRecord{Key: req.GetIdempotencyKey(), Fingerprint: fingerprint[:]}
+	}
+	order, total, err := s.cs.placeOrder(ctx, orderRequest{
+		userID:       req.GetUserId(),
+		userCurrency: req.GetUserCurrency(),
+		address:      req.GetAddress(),
+		billing:      req.GetBillingAddress(),
+		giftMessage:  req.GetGiftMessage(),
+		customerNote: req.GetCustomerNote(),
+		email:        req.GetEmail(),
+		locale:       req.GetLocale(),
+		card:         card,
+		promo:        promo,
+		redeemPoints: req.GetRedeemPoints(),
+		idempotency:  idem,
+		dryRun:       req.GetDryRun(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pbv2.PlaceOrderResponse{Order: order, TotalPaid: total}, nil
+}
+
+func (s *checkoutServiceV2) GetOrder(ctx context.Context, req *pbv2.GetOrderRequest) (*pbv2.Order, error) {
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+	if s.cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	o, err := s.cs.orderStore.GetOrder(ctx, req.GetOrderId())
+	if errors.Is(err, errOrderNotFound) {
+		return nil, status.Errorf(codes.NotFound, "order %s not found", req.GetOrderId())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get order: %v", err)
+	}
+	return orderToProto(o), nil
+}
+
+func (s *checkoutServiceV2) LookupGuestOrder(ctx context.Context, req *pbv2.LookupGuestOrderRequest) (*pbv2.Order, error) {
+	if req.GetLookupToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "lookup_token is required")
+	}
+	if s.cs.lookups == nil {
+		return nil, status.Error(codes.FailedPrecondition, "lookup tokens are not issued: ORDER_LOOKUP_KEY is not set")
+	}
+	if s.cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	// invalid tokens and unknown orders fail alike, so that tokens cannot
+	// be probed for order IDs
+	notFound := status.Error(codes.NotFound, "no order for this lookup token")
+	orderID, mac, err := s.cs.lookups.orderID(req.GetLookupToken())
+	if err != nil {
+		return nil, notFound
+	}
+	o, err := s.cs.orderStore.GetOrder(ctx, orderID)
+	if errors.Is(err, errOrderNotFound) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get order: %v", err)
+	}
+	if err := s.cs.lookups.check(ctx, o, mac); errors.Is(err, errInvalidLookupToken) {
+		log.WithContext(ctx).Warnf("rejected lookup token of order %s", orderID)
+		return nil, notFound
+	} else if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to check lookup token: %v", err)
+	}
+	return orderToProto(o), nil
+}
+
+func (s *checkoutServiceV2) UpdateOrderStatus(ctx context.Context, req *pbv2.UpdateOrderStatusRequest) (*pbv2.Order, error) {
+	log.WithContext(ctx).WithFields(logging.Fields{logging.OrderIDKey: req.GetOrderId(), "status": req.GetStatus().String()}).Info("[v2.UpdateOrderStatus]")
+
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+	to, ok := orderStatusFromProto(req.GetStatus())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid status %v", req.GetStatus())
+	}
+	if s.cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	// like new orders, status changes are only made in the active region
+	if err := s.cs.replicator.acceptOrders(); err != nil {
+		return nil, err
+	}
+	if s.cs.readOnly {
+		return nil, errSchemaReadOnly()
+	}
+	o, err := s.cs.orderStore.UpdateOrderStatus(ctx, req.GetOrderId(), to)
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		return nil, status.Errorf(codes.NotFound, "order %s not found", req.GetOrderId())
+	case errors.Is(err, errInvalidStatusTransition):
+		return nil, rpcerrors.Errorf(codes.FailedPrecondition, reasonStatusTransition, "%v", err)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to update order status: %v", err)
+	}
+	return orderToProto(o), nil
+}
+
+func (s *checkoutServiceV2) CancelOrder(ctx context.Context, req *pbv2.CancelOrderRequest) (*pbv2.CancelOrderResponse, error) {
+	log.WithContext(ctx).WithField(logging.OrderIDKey, req.GetOrderId()).Info("[v2.CancelOrder]")
+
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+	if s.cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	if err := s.cs.replicator.acceptOrders(); err != nil {
+		return nil, err
+	}
+	if s.cs.readOnly {
+		return nil, errSchemaReadOnly()
+	}
+	c, err := s.cs.cancelOrder(ctx, req.GetOrderId(), req.GetReason())
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		return nil, status.Errorf(codes.NotFound, "order %s not found", req.GetOrderId())
+	case errors.Is(err, errInvalidStatusTransition):
+		return nil, rpcerrors.Errorf(codes.FailedPrecondition, reasonStatusTransition, "%v", err)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to cancel order: %v", err)
+	}
+	return &pbv2.CancelOrderResponse{
+		Order:    orderToProto(c.Order),
+		Refund:   cancellationStepToProto(c.Refund),
+		Shipment: cancellationStepToProto(c.Shipment),
+	}, nil
+}
+
+// cancellationStepToProto returns the outcome of c, a step of a cancelled
+// order that is skipped if nil.
+func cancellationStepToProto(c *Compensation) *pbv2.CancellationStep {
+	switch {
+	case c == nil:
+		return &pbv2.CancellationStep{Outcome: pbv2.CancellationStep_OUTCOME_SKIPPED}
+	case c.Err != nil:
+		return &pbv2.CancellationStep{Outcome: pbv2.CancellationStep_OUTCOME_FAILED, Reference: c.Reference, Error: c.Err.Error()}
+	default:
+		return &pbv2.CancellationStep{Outcome: pbv2.CancellationStep_OUTCOME_SUCCEEDED, Reference: c.Reference}
+	}
+}
+
+func (s *checkoutServiceV2) DeleteUserData(ctx context.Context, req *pbv2.DeleteUserDataRequest) (*pbv2.DeleteUserDataResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if s.cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	if s.cs.readOnly {
+		return nil, errSchemaReadOnly()
+	}
+	e, err := s.cs.orderStore.DeleteUserData(ctx, req.GetUserId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to erase user data: %v", err)
+	}
+	return &pbv2.DeleteUserDataResponse{
+		OrdersAnonymized:       e.OrdersAnonymized,
+		OrderItemsDeleted:      e.OrderItemsDeleted,
+		IdempotencyKeysDeleted: e.IdempotencyKeysDeleted,
+	}, nil
+}
+
+// Page sizes of ListOrders.
+const (
+	defaultOrdersPageSize = 50
+	maxOrdersPageSize     = 500
+)
+
+func (s *checkoutServiceV2) ListOrders(ctx context.Context, req *pbv2.ListOrdersRequest) (*pbv2.ListOrdersResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	q, err := ordersQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	if s.cs.orderStore == nil {
+		return nil, errOrdersNotStored()
+	}
+	pageSize := q.Limit
+	// one more order tells whether there is a next page
+	q.Limit++
+	orders, err := s.cs.orderStore.GetUserOrders(ctx, req.GetUserId(), q)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list orders: %v", err)
+	}
+	resp := &pbv2.ListOrdersResponse{}
+	resp.Orders, resp.NextPageToken = ordersPage(orders, pageSize)
+	return resp, nil
+}
+
+func (s *checkoutServiceV2) SearchOrders(ctx context.Context, req *pbv2.SearchOrdersRequest) (*pbv2.SearchOrdersResponse, error) {
+	search, err := orderSearch(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pbv2.SearchOrdersResponse{}
+	resp.Orders, resp.NextPageToken, err = s.searchOrders(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// searchOrders returns the page of orders selected by search, and the token
+// of the next page if there are more.
+func (s *checkoutServiceV2) searchOrders(ctx context.Context, search OrderSearch) ([]*pbv2.Order, string, error) {
+	if s.cs.orderStore == nil {
+		return nil, "", errOrdersNotStored()
+	}
+	pageSize := search.Limit
+	search.Limit++
+	orders, err := s.cs.orderStore.SearchOrders(ctx, search)
+	if err != nil {
+		return nil, "", status.Errorf(codes.Internal, "failed to search orders: %v", err)
+	}
+	page, token := ordersPage(orders, pageSize)
+	return page, token, nil
+}
+
+// ordersPage returns the first pageSize orders, and the token of the next
+// page if there are more.
+func ordersPage(orders []Order, pageSize int) ([]*pbv2.Order, string) {
+	var token string
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		last := orders[pageSize-1]
+		token = encodePageToken(OrderCursor{CreatedAt: last.CreatedAt, OrderID: last.OrderID})
+	}
+	page := make([]*pbv2.Order, 0, len(orders))
+	for i := range orders {
+		page = append(page, orderToProto(&orders[i]))
+	}
+	return page, token
+}
+
+// ordersPageRequest is a request for a page of orders, such as a
+// ListOrdersRequest or a SearchOrdersRequest.
+type ordersPageRequest interface {
+	GetPageSize() int32
+	GetPageToken() string
+	GetStatuses() []pbv2.OrderStatus
+	GetCreatedAfter() *timestamppb.Timestamp
+	GetCreatedBefore() *timestamppb.Timestamp
+}
+
+// ordersQuery validates the paging and filters of req.
+func ordersQuery(req ordersPageRequest) (OrderQuery, error) {
+	q := OrderQuery{Limit: int(req.GetPageSize())}
+	switch {
+	case q.Limit < 0:
+		return q, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case q.Limit == 0:
+		q.Limit = defaultOrdersPageSize
+	case q.Limit > maxOrdersPageSize:
+		q.Limit = maxOrdersPageSize
+	}
+	if t := req.GetPageToken(); t != "" {
+		c, err := decodePageToken(t)
+		if err != nil {
+			return q, status.Errorf(codes.InvalidArgument, "invalid page_token: %v", err)
+		}
+		q.After = &c
+	}
+	for _, p := range req.GetStatuses() {
+		st, ok := orderStatusFromProto(p)
+		if !ok {
+			return q, status.Errorf(codes.InvalidArgument, "invalid status %v", p)
+		}
+		q.Statuses = append(q.Statuses, st)
+	}
+	for _, f := range []struct {
+		name string
+		ts   *timestamppb.Timestamp
+		dst  *time.Time
+	}{
+		{"created_after", req.GetCreatedAfter(), &q.CreatedAfter},
+		{"created_before", req.GetCreatedBefore(), &q.CreatedBefore},
+	} {
+		if f.ts == nil {
+			continue
+		}
+		if err := f.ts.CheckValid(); err != nil {
+			return q, status.Errorf(codes.InvalidArgument, "invalid %s: %v", f.name, err)
+		}
+		*f.dst = f.ts.AsTime()
+	}
+	return q, nil
+}
+
+// orderSearch validates the filters of req.
+func orderSearch(req *pbv2.SearchOrdersRequest) (OrderSearch, error) {
+	q, err := ordersQuery(req)
+	if err != nil {
+		return OrderSearch{}, err
+	}
+	s := OrderSearch{
+		OrderQuery:   q,
+		Email:        strings.TrimSpace(req.GetEmail()),
+		CurrencyCode: req.GetCurrencyCode(),
+		MinTotal:     req.GetMinTotal(),
+		MaxTotal:     req.GetMaxTotal(),
+	}
+	// a search without any of them would scan every order
+	if s.Email == "" && q.CreatedAfter.IsZero() && q.CreatedBefore.IsZero() && s.CurrencyCode == "" {
+		return s, status.Error(codes.InvalidArgument, "one of email, created_after, created_before and currency_code is required")
+	}
+	for _, f := range []struct {
+		name  string
+		total *pb.Money
+	}{{"min_total", s.MinTotal}, {"max_total", s.MaxTotal}} {
+		switch {
+		case f.total == nil:
+		case s.CurrencyCode == "":
+			return s, status.Errorf(codes.InvalidArgument, "currency_code is required with %s", f.name)
+		case f.total.GetCurrencyCode() != "" && f.total.GetCurrencyCode() != s.CurrencyCode:
+			return s, status.Errorf(codes.InvalidArgument, "%s is in %s, not currency_code %s", f.name, f.total.GetCurrencyCode(), s.CurrencyCode)
+		case f.total.GetUnits() < 0 || f.total.GetNanos() < 0 || f.total.GetNanos() > 999999999:
+			return s, status.Errorf(codes.InvalidArgument, "invalid %s", f.name)
+		}
+	}
+	return s, nil
+}
+
+// encodePageToken returns an opaque token for the orders after c.
+func encodePageToken(c OrderCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "/" + c.OrderID))
+}
+
+func decodePageToken(token string) (OrderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return OrderCursor{}, err
+	}
+	created, id, ok := strings.Cut(string(b), "/")
+	if !ok || id == "" {
+		return OrderCursor{}, errors.New("malformed token")
+	}
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return OrderCursor{}, err
+	}
+	return OrderCursor{CreatedAt: t, OrderID: id}, nil
+}
+
+func errOrdersNotStored() error {
+	return rpcerrors.Errorf(codes.FailedPrecondition, reasonOrdersNotStored,
+		"orders are not stored: no database is connected")
+}
+
+// orderToProto converts a stored order.
+func orderToProto(o *Order) *pbv2.Order {
+	items := make([]*pb.CartItem, 0, len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, &pb.CartItem{ProductId: item.ProductID, Quantity: item.Quantity})
+	}
+	return &pbv2.Order{
+		OrderId:                o.OrderID,
+		UserId:                 o.UserID,
+		Email:                  o.Email,
+		ShippingAddress:        o.shippingAddress(),
+		MaskedCreditCardNumber: o.maskedCardNumber(),
+		CardBrand:              o.CardBrand,
+		TotalPaid:              o.totalPaid(),
+		ShippingTrackingId:     o.ShippingTrackingID,
+		CreatedAt:              timestamppb.New(o.CreatedAt),
+		Items:                  items,
+		Status:                 o.Status.proto(),
+		PaymentTransactionId:   o.PaymentTransactionID,
+		ShippingCost:           o.shippingCost(),
+		ShippingQuote:          o.shippingQuote(),
+		BillingAddress:         o.billingAddress(),
+		GiftMessage:            o.GiftMessage,
+		CustomerNote:           o.CustomerNote,
+		PromoCode:              o.PromoCode,
+		Discount:               o.discount(),
+		PointsEarned:           o.PointsEarned,
+		PointsRedeemed:         o.PointsRedeemed,
+	}
+}
+
+// storedOrder returns the response to replay if an order was stored for
+// req's idempotency key, which covers retries that reach another replica or
+// outlive this one, or nil if none was.
+func (s *checkoutServiceV2) storedOrder(ctx context.Context, req *pbv2.PlaceOrderRequest, fingerprint [sha256.Size]byte) (*pbv2.PlaceOrderResponse, error) {
+	if s.cs.orderStore == nil {
+		return nil, nil
+	}
+	// charging again is worse than failing the retry
+	rec, err := s.cs.orderStore.GetIdempotencyRecord(ctx, req.GetUserId(), req.GetIdempotencyKey())
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to look up idempotency key: %v", err)
+	}
+	if rec == nil {
+		return nil, nil
+	}
+	if !bytes.Equal(rec.Fingerprint, fingerprint[:]) {
+		return nil, rpcerrors.Errorf(codes.InvalidArgument, reasonIdempotencyKeyReused,
+			"idempotency key %q was used for a different request", req.GetIdempotencyKey())
+	}
+	resp := &pbv2.PlaceOrderResponse{}
+	if err := proto.Unmarshal(rec.Response, resp); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode stored order: %v", err)
+	}
+	log.WithContext(ctx).Infof("replaying stored order %s for idempotency key %q", resp.GetOrder().GetOrderId(), req.GetIdempotencyKey())
+	resp.Replayed = true
+	return resp, nil
+}
+
+// placedOrders remembers the orders placed for each idempotency key so
+// that retried requests are answered without charging the user again.
+type placedOrders struct {
+	mu      sync.Mutex
+	entries map[string]*placedOrder
+	// max is how many entries are kept before the oldest completed one is
+	// forgotten.
+	max int
+}
+
+type placedOrder struct {
+	// done is closed once resp or err is set.
+	done        chan struct{}
+	fingerprint [sha256.Size]byte
+	resp        *pbv2.PlaceOrderResponse
+	err         error
+	expires     time.Time
+}
+
+func newPlacedOrders() *placedOrders {
+	return &placedOrders{entries: make(map[string]*placedOrder), max: maxPlacedOrders}
+}
+
+// run forgets the expired idempotency keys every placedOrdersSweepInterval
+// until ctx is done.
+func (p *placedOrders) run(ctx context.Context) {
+	t := time.NewTicker(placedOrdersSweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		p.evict(time.Now())
+	}
+}
+
+// do calls place unless an order was already placed, or is being placed,
+// for req's idempotency key, in which case that order is returned instead.
+// Failed attempts are forgotten so that the request can be retried.
+func (p *placedOrders) do(ctx context.Context, req *pbv2.PlaceOrderRequest, place func() (*pbv2.PlaceOrderResponse, error)) (*pbv2.PlaceOrderResponse, error) {
+	key := req.GetUserId() + "\x00" + req.GetIdempotencyKey()
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to fingerprint request: %v", err)
+	}
+
+	p.mu.Lock()
+	if e, ok := p.entries[key]; ok {
+		p.mu.Unlock()
+		if e.fingerprint != fingerprint {
+			return nil, rpcerrors.Errorf(codes.InvalidArgument, reasonIdempotencyKeyReused,
+				"idempotency key %q was used for a different request", req.GetIdempotencyKey())
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if e.err != nil {
+			return nil, e.err
+		}
+		log.WithContext(ctx).Infof("replaying order %s for idempotency key %q", e.resp.GetOrder().GetOrderId(), req.GetIdempotencyKey())
+		resp := proto.Clone(e.resp).(*pbv2.PlaceOrderResponse)
+		resp.Replayed = true
+		return resp, nil
+	}
+	if len(p.entries) >= p.max {
+		p.evictLocked(time.Now())
+		p.evictOldestLocked()
+	}
+	e := &placedOrder{done: make(chan struct{}), fingerprint: fingerprint}
+	p.entries[key] = e
+	p.mu.Unlock()
+
+	e.resp, e.err = place()
+
+	p.mu.Lock()
+	if e.err != nil {
+		delete(p.entries, key)
+	} else {
+		e.expires = time.Now().Add(idempotencyTTL)
+	}
+	p.mu.Unlock()
+	close(e.done)
+	return e.resp, e.err
+}
+
+// evict forgets the orders whose idempotency keys have expired by now.
+func (p *placedOrders) evict(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictLocked(now)
+}
+
+// evictLocked forgets the orders whose idempotency keys have expired.
+func (p *placedOrders) evictLocked(now time.Time) {
+	for k, e := range p.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(p.entries, k)
+		}
+	}
+}
+
+// evictOldestLocked forgets completed orders, the soonest to expire first,
+// until there is room for one more entry. Orders still being placed are
+// kept so that their retries keep waiting for them.
+func (p *placedOrders) evictOldestLocked() {
+	for len(p.entries) >= p.max {
+		var oldest string
+		var expires time.Time
+		for k, e := range p.entries {
+			if !e.expires.IsZero() && (expires.IsZero() || e.expires.Before(expires)) {
+				oldest, expires = k, e.expires
+			}
+		}
+		if expires.IsZero() {
+			return
+		}
+		delete(p.entries, oldest)
+	}
+}
+
+// requestFingerprint hashes everything in req but its idempotency key, to
+// tell a retry apart from a different request reusing the key. The payment
+// method is left out too: fingerprints are stored, and card numbers and
+// CVVs are few enough to be recovered from a plain hash of them.
+func requestFingerprint(req *pbv2.PlaceOrderRequest) ([sha256.Size]byte, error) {
+	req = proto.Clone(req).(*pbv2.PlaceOrderRequest)
+	req.IdempotencyKey = ""
+	req.PaymentMethod = nil
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,697 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func placeOrderRequest(key string) *pbv2.PlaceOrderRequest {
+	return &pbv2.PlaceOrderRequest{
+		UserId:         "user-1",
+		UserCurrency:   "USD",
+		Email:          "someone@example.com",
+		IdempotencyKey: key,
+		PaymentMethod: &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{
+			CreditCard: &pb.CreditCardInfo{CreditCardNumber: "4432-8015-6152-0454"},
+		}},
+	}
+}
+
+func TestPlacedOrdersReplaysRetries(t *testing.T) {
+	p := newPlacedOrders()
+	calls := 0
+	place := func() (*pbv2.PlaceOrderResponse, error) {
+		calls++
+		return &pbv2.PlaceOrderResponse{Order: &pb.OrderResult{OrderId: "order-1"}}, nil
+	}
+
+	first, err := p.do(context.Background(), placeOrderRequest("key-1"), place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry, err := p.do(context.Background(), placeOrderRequest("key-1"), place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("order placed %d times, want 1", calls)
+	}
+	if first.GetReplayed() || !retry.GetReplayed() {
+		t.Errorf("replayed = %v, %v; want false, true", first.GetReplayed(), retry.GetReplayed())
+	}
+	if got := retry.GetOrder().GetOrderId(); got != "order-1" {
+		t.Errorf("replayed order ID = %q, want %q", got, "order-1")
+	}
+
+	if _, err := p.do(context.Background(), placeOrderRequest("key-2"), place); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("order placed %d times, want 2 after a new key", calls)
+	}
+}
+
+func TestPlacedOrdersRejectsReusedKey(t *testing.T) {
+	p := newPlacedOrders()
+	place := func() (*pbv2.PlaceOrderResponse, error) {
+		return &pbv2.PlaceOrderResponse{Order: &pb.OrderResult{OrderId: "order-1"}}, nil
+	}
+	if _, err := p.do(context.Background(), placeOrderRequest("key-1"), place); err != nil {
+		t.Fatal(err)
+	}
+
+	req := placeOrderRequest("key-1")
+	req.Email = "someone-else@example.com"
+	_, err := p.do(context.Background(), req, place)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("reusing a key for a different request: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestRequestFingerprintLeavesOutCard(t *testing.T) {
+	fingerprint := func(req *pbv2.PlaceOrderRequest) [sha256.Size]byte {
+		t.Helper()
+		f, err := requestFingerprint(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	want := fingerprint(placeOrderRequest("key-1"))
+	req := placeOrderRequest("key-1")
+	req.GetPaymentMethod().GetCreditCard().CreditCardNumber = "4111-1111-1111-1111"
+	req.GetPaymentMethod().GetCreditCard().CreditCardCvv = 123
+	if fingerprint(req) != want {
+		t.Error("fingerprint depends on the card")
+	}
+	req.Email = "someone-else@example.com"
+	if fingerprint(req) == want {
+		t.Error("fingerprint does not depend on the email")
+	}
+}
+
+func TestPlacedOrdersForgetsFailures(t *testing.T) {
+	p := newPlacedOrders()
+	fail := errors.New("payment declined")
+	calls := 0
+	place := func() (*pbv2.PlaceOrderResponse, error) {
+		calls++
+		if calls == 1 {
+			return nil, fail
+		}
+		return &pbv2.PlaceOrderResponse{Order: &pb.OrderResult{OrderId: "order-1"}}, nil
+	}
+
+	if _, err := p.do(context.Background(), placeOrderRequest("key-1"), place); !errors.Is(err, fail) {
+		t.Fatalf("first attempt: got %v, want %v", err, fail)
+	}
+	resp, err := p.do(context.Background(), placeOrderRequest("key-1"), place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetReplayed() || calls != 2 {
+		t.Errorf("retry after a failure: replayed = %v after %d calls, want a new order", resp.GetReplayed(), calls)
+	}
+}
+
+func TestPlacedOrdersCapsEntries(t *testing.T) {
+	p := newPlacedOrders()
+	p.max = 2
+	place := func() (*pbv2.PlaceOrderResponse, error) {
+		return &pbv2.PlaceOrderResponse{Order: &pb.OrderResult{OrderId: "order-1"}}, nil
+	}
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		if _, err := p.do(context.Background(), placeOrderRequest(key), place); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(p.entries) != 2 {
+		t.Errorf("%d entries kept, want 2", len(p.entries))
+	}
+	if _, ok := p.entries[placeOrderRequest("key-1").GetUserId()+"\x00key-1"]; ok {
+		t.Error("the oldest entry was kept")
+	}
+}
+
+func TestPlacedOrdersEvictsExpired(t *testing.T) {
+	p := newPlacedOrders()
+	place := func() (*pbv2.PlaceOrderResponse, error) {
+		return &pbv2.PlaceOrderResponse{Order: &pb.OrderResult{OrderId: "order-1"}}, nil
+	}
+	if _, err := p.do(context.Background(), placeOrderRequest("key-1"), place); err != nil {
+		t.Fatal(err)
+	}
+	p.evict(time.Now())
+	if len(p.entries) != 1 {
+		t.Fatalf("%d entries kept before expiry, want 1", len(p.entries))
+	}
+	p.evict(time.Now().Add(idempotencyTTL + time.Second))
+	if len(p.entries) != 0 {
+		t.Errorf("%d entries kept after expiry, want 0", len(p.entries))
+	}
+}
+
+func TestPlaceOrderV2RejectsPromoCodes(t *testing.T) {
+	s := newCheckoutServiceV2(&checkoutService{})
+	req := placeOrderRequest("key-1")
+	req.PromoCode = "SPRING"
+	if _, err := s.PlaceOrder(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder with a promo code: got %v, want InvalidArgument", err)
+	}
+
+	req = placeOrderRequest("")
+	if _, err := s.PlaceOrder(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder without an idempotency key: got %v, want InvalidArgument", err)
+	}
+
+	req = placeOrderRequest(strings.Repeat("k", maxIdempotencyKeyLen+1))
+	if _, err := s.PlaceOrder(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder with a long idempotency key: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestOrdersWithoutStore(t *testing.T) {
+	s := newCheckoutServiceV2(&checkoutService{})
+	ctx := context.Background()
+	if _, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetOrder without an order ID: got %v, want InvalidArgument", err)
+	}
+	if _, err := s.ListOrders(ctx, &pbv2.ListOrdersRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListOrders without a user ID: got %v, want InvalidArgument", err)
+	}
+	if _, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: "o-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("GetOrder without a store: got %v, want FailedPrecondition", err)
+	}
+	if _, err := s.ListOrders(ctx, &pbv2.ListOrdersRequest{UserId: "u-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ListOrders without a store: got %v, want FailedPrecondition", err)
+	}
+	if _, err := s.DeleteUserData(ctx, &pbv2.DeleteUserDataRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("DeleteUserData without a user ID: got %v, want InvalidArgument", err)
+	}
+	if _, err := s.DeleteUserData(ctx, &pbv2.DeleteUserDataRequest{UserId: "u-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeleteUserData without a store: got %v, want FailedPrecondition", err)
+	}
+
+	if _, err := s.UpdateOrderStatus(ctx, &pbv2.UpdateOrderStatusRequest{Status: pbv2.OrderStatus_ORDER_STATUS_SHIPPED}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateOrderStatus without an order ID: got %v, want InvalidArgument", err)
+	}
+	if _, err := s.UpdateOrderStatus(ctx, &pbv2.UpdateOrderStatusRequest{OrderId: "o-1"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateOrderStatus without a status: got %v, want InvalidArgument", err)
+	}
+	if _, err := s.UpdateOrderStatus(ctx, &pbv2.UpdateOrderStatusRequest{OrderId: "o-1", Status: pbv2.OrderStatus_ORDER_STATUS_SHIPPED}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UpdateOrderStatus without a store: got %v, want FailedPrecondition", err)
+	}
+	if _, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CancelOrder without an order ID: got %v, want InvalidArgument", err)
+	}
+	if _, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: "o-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("CancelOrder without a store: got %v, want FailedPrecondition", err)
+	}
+}
+
+// TestOrdersInMemory places, reads and replays orders kept by the in-memory
+// store, through the v2 API.
+func TestOrdersInMemory(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = newMemoryOrderStore()
+	req := placeOrderRequest("key-1")
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	req.Address = &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", ZipCode: 94043}
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	placed, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newCheckoutServiceV2(cs)
+	o, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: placed.GetOrder().GetOrderId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(o.GetTotalPaid(), placed.GetTotalPaid()) || o.GetStatus() != pbv2.OrderStatus_ORDER_STATUS_PAID || len(o.GetItems()) != 1 {
+		t.Errorf("GetOrder = %v, want the paid order of %v", o, placed.GetTotalPaid())
+	}
+	if !proto.Equal(o.GetBillingAddress(), o.GetShippingAddress()) {
+		t.Errorf("GetOrder billing address = %v, want the shipping address %v when none is given", o.GetBillingAddress(), o.GetShippingAddress())
+	}
+	list, err := s.ListOrders(ctx, &pbv2.ListOrdersRequest{UserId: req.UserId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetOrders()) != 1 || list.GetOrders()[0].GetOrderId() != o.GetOrderId() {
+		t.Errorf("ListOrders = %v, want the placed order", list.GetOrders())
+	}
+
+	retry, err := s.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retry.GetReplayed() || retry.GetOrder().GetOrderId() != o.GetOrderId() {
+		t.Errorf("retry = %v, want a replay of order %s", retry, o.GetOrderId())
+	}
+	if n := len(backends.payment.Charges()); n != 1 {
+		t.Errorf("card charged %d times, want once", n)
+	}
+
+	erased, err := s.DeleteUserData(ctx, &pbv2.DeleteUserDataRequest{UserId: req.UserId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erased.GetOrdersAnonymized() != 1 || erased.GetOrderItemsDeleted() != 1 || erased.GetIdempotencyKeysDeleted() != 1 {
+		t.Errorf("DeleteUserData = %v, want 1 order, item and idempotency key", erased)
+	}
+	if list, err := s.ListOrders(ctx, &pbv2.ListOrdersRequest{UserId: req.UserId}); err != nil || len(list.GetOrders()) != 0 {
+		t.Errorf("ListOrders after erasure = %v, %v, want no orders", list.GetOrders(), err)
+	}
+	if o, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: o.GetOrderId()}); err != nil || o.GetEmail() != "" || o.GetShippingAddress().GetStreetAddress() != "" {
+		t.Errorf("GetOrder after erasure = %v, %v, want the order without personal data", o, err)
+	}
+}
+
+// TestOrderMessages places an order with a gift message and a note, which
+// are stored with it and included in its confirmation.
+func TestPlaceOrderDryRun(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	cs.readOnly = true
+	req := placeOrderRequest("")
+	req.DryRun = true
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	req.Address = &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", ZipCode: 94043}
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 x 19.99 + 8.99 shipping
+	if total := resp.GetTotalPaid(); total.GetUnits() != 48 || total.GetNanos() != 970000000 {
+		t.Errorf("total = %v, want 48.97 USD", total)
+	}
+	if len(resp.GetOrder().GetItems()) != 1 || resp.GetOrder().GetShippingCost().GetUnits() != 8 {
+		t.Errorf("order = %v, want its item and shipping cost", resp.GetOrder())
+	}
+	if charges := backends.payment.Charges(); len(charges) != 0 {
+		t.Errorf("dry run charged %v", charges)
+	}
+	if shipped := backends.shipping.Shipped(); len(shipped) != 0 {
+		t.Errorf("dry run shipped %d orders", len(shipped))
+	}
+	if cart, _ := backends.cart.GetCart(ctx, &pb.GetCartRequest{UserId: req.UserId}); len(cart.GetItems()) != 1 {
+		t.Errorf("cart after a dry run = %v, want it kept", cart.GetItems())
+	}
+	if _, err := store.GetOrder(ctx, resp.GetOrder().GetOrderId()); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("GetOrder() of a dry run = %v, want %v", err, errOrderNotFound)
+	}
+}
+
+func TestOrderMessages(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	email := &recordingEmail{}
+	cs.emailSvcConn = contract.Serve(t, func(srv *grpc.Server) { pb.RegisterEmailServiceServer(srv, email) })
+	cs.orderStore = newMemoryOrderStore()
+	req := placeOrderRequest("key-1")
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	req.GiftMessage = "Happy birthday!"
+	req.CustomerNote = "Please leave it at the door."
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	s := newCheckoutServiceV2(cs)
+
+	tooLong := proto.Clone(req).(*pbv2.PlaceOrderRequest)
+	tooLong.IdempotencyKey = "key-2"
+	tooLong.GiftMessage = strings.Repeat("x", maxGiftMessageLen+1)
+	if _, err := s.PlaceOrder(ctx, tooLong); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder() with a gift message too long = %v, want InvalidArgument", err)
+	}
+
+	placed, err := s.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: placed.GetOrder().GetOrderId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.GetGiftMessage() != req.GiftMessage || o.GetCustomerNote() != req.CustomerNote {
+		t.Errorf("GetOrder = %v, want gift message %q and note %q", o, req.GiftMessage, req.CustomerNote)
+	}
+	if len(email.sent) != 1 {
+		t.Fatalf("%d confirmations sent, want 1", len(email.sent))
+	}
+	for _, want := range []string{req.GiftMessage, req.CustomerNote} {
+		if body := email.sent[0].GetTextBody(); !strings.Contains(body, want) {
+			t.Errorf("confirmation %q does not include %q", body, want)
+		}
+	}
+}
+
+func TestPromoCodes(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = newMemoryOrderStore()
+	promos, err := parsePromoCodes("SPRING=10%,WELCOME=5EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.promotions = promos
+	s := newCheckoutServiceV2(cs)
+	place := func(key, code string) (*pbv2.PlaceOrderResponse, error) {
+		req := placeOrderRequest(key)
+		req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+		req.PromoCode = code
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+			t.Fatal(err)
+		}
+		return s.PlaceOrder(ctx, req)
+	}
+
+	full, err := place("key-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discounted, err := place("key-2", "spring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: discounted.GetOrder().GetOrderId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.GetPromoCode() != "SPRING" || !money.IsPositive(*o.GetDiscount()) {
+		t.Fatalf("GetOrder = %v, want SPRING and its discount", o)
+	}
+	want := money.Must(money.Sum(*full.GetTotalPaid(), money.Negate(*o.GetDiscount())))
+	if !money.AreEquals(*discounted.GetTotalPaid(), want) || !money.AreEquals(*o.GetTotalPaid(), want) {
+		t.Errorf("total paid = %v, want %v: %v less the discount", discounted.GetTotalPaid(), &want, full.GetTotalPaid())
+	}
+
+	for _, code := range []string{"SUMMER", "WELCOME"} {
+		_, err := place("key-"+code, code)
+		if rpcerrors.Classify(err).Reason != reasonPromoCodeInvalid {
+			t.Errorf("PlaceOrder() with promo code %s in USD = %v, want %s", code, err, reasonPromoCodeInvalid)
+		}
+	}
+}
+
+// TestCancelOrderInMemory cancels orders kept by the in-memory store, which
+// refunds their charge and cancels their shipment.
+func TestCancelOrderInMemory(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	req := placeOrderRequest("key-1")
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	s := newCheckoutServiceV2(cs)
+	placed, err := s.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderID := placed.GetOrder().GetOrderId()
+
+	succeeded := func(ref string) *pbv2.CancellationStep {
+		return &pbv2.CancellationStep{Outcome: pbv2.CancellationStep_OUTCOME_SUCCEEDED, Reference: ref}
+	}
+	// cancelling again retries the refund and shipment, which are idempotent
+	for range 2 {
+		res, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: orderID, Reason: "changed my mind"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.GetOrder().GetStatus() != pbv2.OrderStatus_ORDER_STATUS_CANCELLED {
+			t.Errorf("CancelOrder status = %v, want CANCELLED", res.GetOrder().GetStatus())
+		}
+		if want := succeeded("fake-transaction-1"); !proto.Equal(res.GetRefund(), want) {
+			t.Errorf("CancelOrder refund = %v, want %v", res.GetRefund(), want)
+		}
+		if want := succeeded(placed.GetOrder().GetShippingTrackingId()); !proto.Equal(res.GetShipment(), want) {
+			t.Errorf("CancelOrder shipment = %v, want %v", res.GetShipment(), want)
+		}
+	}
+	if refunds := backends.payment.Refunds(); len(refunds) != 1 {
+		t.Errorf("refunds = %v, want the charge refunded", refunds)
+	}
+	if cancelled := backends.shipping.Cancelled(); len(cancelled) != 1 {
+		t.Errorf("cancelled shipments = %v, want the shipment cancelled", cancelled)
+	}
+	if len(store.compensations) != 4 || store.compensations[0].Cause != "order cancelled: changed my mind" {
+		t.Errorf("compensations = %+v, want the refund and shipment of both cancellations", store.compensations)
+	}
+
+	// orders placed before transaction IDs were stored are not refunded
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: "order-2", UserID: "user-2", OrderTotalUnits: 5, CurrencyCode: "USD"}}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: "order-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetRefund().GetOutcome() != pbv2.CancellationStep_OUTCOME_SKIPPED || res.GetShipment().GetOutcome() != pbv2.CancellationStep_OUTCOME_SKIPPED {
+		t.Errorf("CancelOrder without a charge or shipment = %v, want both skipped", res)
+	}
+
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: "order-3", UserID: "user-3", OrderTotalUnits: 5, CurrencyCode: "USD", PaymentTransactionID: "txn-3", ShippingTrackingID: "track-3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpdateOrderStatus(ctx, "order-3", StatusShipped); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: "order-3"}); rpcerrors.Classify(err).Reason != reasonStatusTransition {
+		t.Errorf("CancelOrder of a shipped order: got %v, want reason %s", err, reasonStatusTransition)
+	}
+	if _, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: "order-4"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CancelOrder of an unknown order: got %v, want NotFound", err)
+	}
+}
+
+func TestOrderToProto(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	o := &Order{
+		OrderID:                   "o-1",
+		UserID:                    "u-1",
+		Email:                     "someone@example.com",
+		StreetAddress:             "1600 Amphitheatre Parkway",
+		City:                      "Mountain View",
+		State:                     "CA",
+		Country:                   "United States",
+		ZipCode:                   "94043",
+		CardToken:                 "tok_1",
+		CardLast4:                 "0454",
+		CardBrand:                 "visa",
+		OrderTotalUnits:           67,
+		OrderTotalNanos:           960000000,
+		CurrencyCode:              "USD",
+		ShippingTrackingID:        "TR-123",
+		CreatedAt:                 created,
+		Status:                    StatusShipped,
+		PaymentTransactionID:      "txn-1",
+		ShippingCostUnits:         8,
+		ShippingCostNanos:         990000000,
+		ShippingQuoteUnits:        8,
+		ShippingQuoteNanos:        990000000,
+		ShippingQuoteCurrencyCode: "USD",
+		BillingStreetAddress:      "1 Market Street",
+		BillingCity:               "San Francisco",
+		BillingState:              "CA",
+		BillingCountry:            "United States",
+		BillingZipCode:            "94105",
+		GiftMessage:               "Happy birthday!",
+		CustomerNote:              "Please leave it at the door.",
+		Items: []OrderItem{
+			{ID: 1, OrderID: "o-1", ProductID: "OLJCESPC7Z", Quantity: 1},
+			{ID: 2, OrderID: "o-1", ProductID: "66VCHSJNUP", Quantity: 2},
+		},
+	}
+	want := &pbv2.Order{
+		OrderId: "o-1",
+		UserId:  "u-1",
+		Email:   "someone@example.com",
+		ShippingAddress: &pb.Address{
+			StreetAddress: "1600 Amphitheatre Parkway",
+			City:          "Mountain View",
+			State:         "CA",
+			Country:       "United States",
+			ZipCode:       94043,
+		},
+		MaskedCreditCardNumber: "****-****-****-0454",
+		CardBrand:              "visa",
+		TotalPaid:              &pb.Money{CurrencyCode: "USD", Units: 67, Nanos: 960000000},
+		ShippingTrackingId:     "TR-123",
+		CreatedAt:              timestamppb.New(created),
+		Items:                  []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}, {ProductId: "66VCHSJNUP", Quantity: 2}},
+		Status:                 pbv2.OrderStatus_ORDER_STATUS_SHIPPED,
+		PaymentTransactionId:   "txn-1",
+		ShippingCost:           &pb.Money{CurrencyCode: "USD", Units: 8, Nanos: 990000000},
+		ShippingQuote:          &pb.Money{CurrencyCode: "USD", Units: 8, Nanos: 990000000},
+		BillingAddress: &pb.Address{
+			StreetAddress: "1 Market Street",
+			City:          "San Francisco",
+			State:         "CA",
+			Country:       "United States",
+			ZipCode:       94105,
+		},
+		GiftMessage:  "Happy birthday!",
+		CustomerNote: "Please leave it at the door.",
+	}
+	if got := orderToProto(o); !proto.Equal(got, want) {
+		t.Errorf("orderToProto() = %v, want %v", got, want)
+	}
+
+	o.ShippingQuoteUnits, o.ShippingQuoteNanos, o.ShippingQuoteCurrencyCode = 0, 0, ""
+	if got := orderToProto(o); got.ShippingQuote != nil {
+		t.Errorf("orderToProto() of an order placed before quotes were stored has shipping quote %v, want none", got.ShippingQuote)
+	}
+	o.BillingStreetAddress, o.BillingCity, o.BillingState, o.BillingCountry, o.BillingZipCode = "", "", "", "", ""
+	if got := orderToProto(o); got.BillingAddress != nil {
+		t.Errorf("orderToProto() of an order placed before billing addresses were stored has billing address %v, want none", got.BillingAddress)
+	}
+}
+
+func TestListOrdersRejectsInvalidPages(t *testing.T) {
+	s := newCheckoutServiceV2(&checkoutService{})
+	for _, req := range []*pbv2.ListOrdersRequest{
+		{UserId: "u-1", PageSize: -1},
+		{UserId: "u-1", PageToken: "not a token"},
+		{UserId: "u-1", PageToken: encodePageToken(OrderCursor{CreatedAt: time.Now()})},
+		{UserId: "u-1", CreatedAfter: &timestamppb.Timestamp{Nanos: -1}},
+		{UserId: "u-1", Statuses: []pbv2.OrderStatus{pbv2.OrderStatus_ORDER_STATUS_UNSPECIFIED}},
+	} {
+		if _, err := s.ListOrders(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListOrders(%v): got %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestSearchOrdersRejectsInvalidRequests(t *testing.T) {
+	s := newCheckoutServiceV2(&checkoutService{})
+	for _, req := range []*pbv2.SearchOrdersRequest{
+		{},
+		{Statuses: []pbv2.OrderStatus{pbv2.OrderStatus_ORDER_STATUS_PAID}},
+		{Email: "someone@example.com", MinTotal: &pb.Money{Units: 10}},
+		{CurrencyCode: "USD", MaxTotal: &pb.Money{CurrencyCode: "EUR", Units: 10}},
+		{CurrencyCode: "USD", MinTotal: &pb.Money{Units: -1}},
+		{Email: "someone@example.com", PageSize: -1},
+	} {
+		if _, err := s.SearchOrders(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("SearchOrders(%v): got %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestSearchOrdersInMemory(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryOrderStore()
+	saveMemoryOrder(t, store, "order-1", "user-1", nil)
+	saveMemoryOrder(t, store, "order-2", "user-2", nil)
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: "order-3", UserID: "user-3", Email: "other@example.com", OrderTotalUnits: 5, CurrencyCode: "EUR"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := newCheckoutServiceV2(&checkoutService{orderStore: store})
+
+	for _, tt := range []struct {
+		name string
+		req  *pbv2.SearchOrdersRequest
+		want []string
+	}{
+		{"email", &pbv2.SearchOrdersRequest{Email: "Someone@Example.com "}, []string{"order-2", "order-1"}},
+		{"currency", &pbv2.SearchOrdersRequest{CurrencyCode: "EUR"}, []string{"order-3"}},
+		{"total", &pbv2.SearchOrdersRequest{CurrencyCode: "USD", MinTotal: &pb.Money{Units: 67, Nanos: 960000000}, MaxTotal: &pb.Money{Units: 68}}, []string{"order-2", "order-1"}},
+		{"total too high", &pbv2.SearchOrdersRequest{CurrencyCode: "USD", MinTotal: &pb.Money{Units: 68}}, nil},
+		{"date range", &pbv2.SearchOrdersRequest{CreatedBefore: timestamppb.New(time.Now().Add(-time.Hour))}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.SearchOrders(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, o := range resp.GetOrders() {
+				got = append(got, o.GetOrderId())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchOrders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	page, err := s.SearchOrders(ctx, &pbv2.SearchOrdersRequest{CurrencyCode: "USD", PageSize: 1})
+	if err != nil || len(page.GetOrders()) != 1 || page.GetNextPageToken() == "" {
+		t.Fatalf("first page = %v, %v, want one order and a next page", page, err)
+	}
+	next, err := s.SearchOrders(ctx, &pbv2.SearchOrdersRequest{CurrencyCode: "USD", PageSize: 1, PageToken: page.GetNextPageToken()})
+	if err != nil || len(next.GetOrders()) != 1 || next.GetOrders()[0].GetOrderId() == page.GetOrders()[0].GetOrderId() || next.GetNextPageToken() != "" {
+		t.Errorf("second page = %v, %v, want the other order and no next page", next, err)
+	}
+}
+
+func TestOrdersQuery(t *testing.T) {
+	after := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursor := OrderCursor{CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC), OrderID: "o-1"}
+	for _, tt := range []struct {
+		name string
+		req  *pbv2.ListOrdersRequest
+		want OrderQuery
+	}{
+		{"defaults", &pbv2.ListOrdersRequest{}, OrderQuery{Limit: defaultOrdersPageSize}},
+		{"page size capped", &pbv2.ListOrdersRequest{PageSize: 10000}, OrderQuery{Limit: maxOrdersPageSize}},
+		{"next page", &pbv2.ListOrdersRequest{PageSize: 10, PageToken: encodePageToken(cursor)}, OrderQuery{Limit: 10, After: &cursor}},
+		{"date range", &pbv2.ListOrdersRequest{CreatedAfter: timestamppb.New(after)}, OrderQuery{Limit: defaultOrdersPageSize, CreatedAfter: after}},
+		{"statuses", &pbv2.ListOrdersRequest{Statuses: []pbv2.OrderStatus{pbv2.OrderStatus_ORDER_STATUS_PAID, pbv2.OrderStatus_ORDER_STATUS_SHIPPED}},
+			OrderQuery{Limit: defaultOrdersPageSize, Statuses: []OrderStatus{StatusPaid, StatusShipped}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ordersQuery(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ordersQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
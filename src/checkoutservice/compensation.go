@@ -0,0 +1,159 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// PlaceOrder is a saga: it charges the card, ships the order, then records
+// it. When a step fails after the card was charged, the steps before it are
+// compensated, refunding the charge and cancelling the shipment, so that users
+// are not charged for orders that were not recorded. Each compensation is
+// logged in order_compensations, and at error level in case the log cannot
+// be written, such as when the database is why the order was not recorded.
+
+const (
+	compensateRefund   = "refund"
+	compensateShipment = "cancel_shipment"
+
+	// compensationAttempts is how many times each compensation is tried.
+	compensationAttempts = 3
+	compensationTimeout  = 10 * time.Second
+
+	reasonOrderNotRecorded = "ORDER_NOT_RECORDED"
+)
+
+// Compensation is a step of a placed order that was undone.
+type Compensation struct {
+	OrderID string
+	UserID  string
+	// Step is compensateRefund or compensateShipment.
+	Step string
+	// Reference is the transaction ID of a refunded charge, or the
+	// tracking ID of a cancelled shipment.
+	Reference string
+	// Amount is the amount refunded, nil for shipments.
+	Amount *pb.Money
+	// Cause is why the order was compensated.
+	Cause string
+	// Err is why the compensation failed, nil if it succeeded.
+	Err error
+}
+
+// placedSteps are the steps of a placed order that a later failure
+// compensates.
+type placedSteps struct {
+	orderID, userID string
+	// transactionID and total are those of the charge, if the card was
+	// charged.
+	transactionID string
+	total         *pb.Money
+	// trackingID is that of the shipment, if the order was shipped.
+	trackingID string
+}
+
+// compensate undoes the steps of an order that failed with cause, and
+// reports whether they all succeeded.
+func (cs *checkoutService) compensate(ctx context.Context, steps placedSteps, cause error) bool {
+	for _, c := range cs.undo(ctx, steps, cause.Error()) {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// undo undoes the steps of an order because of cause, even if ctx is
+// cancelled, the shipment first, and logs and returns each compensation.
+func (cs *checkoutService) undo(ctx context.Context, steps placedSteps, cause string) []Compensation {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), compensationTimeout)
+	defer cancel()
+	var done []Compensation
+	if steps.trackingID != "" {
+		c := Compensation{OrderID: steps.orderID, UserID: steps.userID, Step: compensateShipment, Reference: steps.trackingID, Cause: cause}
+		c.Err = retryCompensation(ctx, func() error {
+			_, err := pb.NewShippingServiceClient(cs.shippingSvcConn).CancelShipment(ctx, &pb.CancelShipmentRequest{TrackingId: steps.trackingID})
+			return err
+		})
+		cs.recordCompensation(ctx, c)
+		done = append(done, c)
+	}
+	if steps.transactionID != "" {
+		c := Compensation{OrderID: steps.orderID, UserID: steps.userID, Step: compensateRefund, Reference: steps.transactionID, Amount: steps.total, Cause: cause}
+		c.Err = retryCompensation(ctx, func() error {
+			return cs.payments.Refund(ctx, steps.transactionID, steps.total)
+		})
+		cs.recordCompensation(ctx, c)
+		done = append(done, c)
+	}
+	return done
+}
+
+// retryCompensation calls compensate until it succeeds, up to
+// compensationAttempts times, and returns its last error.
+func retryCompensation(ctx context.Context, compensate func() error) error {
+	var err error
+	for i := range compensationAttempts {
+		if err = compensate(); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(100<<i) * time.Millisecond):
+		}
+	}
+	return err
+}
+
+// recordCompensation logs c, to the order store if there is one.
+func (cs *checkoutService) recordCompensation(ctx context.Context, c Compensation) {
+	l := log.WithContext(ctx).WithFields(logging.Fields{
+		"order_id":  c.OrderID,
+		"user_id":   c.UserID,
+		"step":      c.Step,
+		"reference": c.Reference,
+	})
+	if c.Err != nil {
+		l.Errorf("failed to compensate order %s (%s %s) after %v: %v", c.OrderID, c.Step, c.Reference, c.Cause, c.Err)
+	} else {
+		l.Warnf("compensated order %s (%s %s) after %v", c.OrderID, c.Step, c.Reference, c.Cause)
+	}
+	if cs.orderStore == nil {
+		return
+	}
+	if err := cs.orderStore.RecordCompensation(ctx, c); err != nil {
+		l.Errorf("failed to record compensation of order %s: %v", c.OrderID, err)
+	}
+}
+
+// errOrderNotRecorded is returned when an order could not be recorded, and
+// was compensated unless compensated is false.
+func errOrderNotRecorded(orderID string, compensated bool) error {
+	if !compensated {
+		return rpcerrors.Errorf(codes.Internal, reasonOrderNotRecorded,
+			"order %s could not be recorded, nor fully refunded; it will be refunded manually", orderID)
+	}
+	return rpcerrors.Errorf(codes.Unavailable, reasonOrderNotRecorded,
+		"order %s could not be recorded; its charge was refunded and its shipment cancelled", orderID)
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/callpolicy"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardcrypt"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/mtls"
+)
+
+// validateConfig checks the service's environment before it starts.
+func validateConfig() error {
+	c := configcheck.New("checkoutservice")
+	c.Common()
+	c.Port("PORT", listenPort)
+	c.Port("METRICS_PORT", instrumentation.DefaultMetricsPort)
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		c.Port("DEBUG_PORT", instrumentation.DefaultDebugPort)
+	}
+	if os.Getenv("EMAIL_PREVIEW_PORT") != "" {
+		c.Port("EMAIL_PREVIEW_PORT", "")
+	}
+	for _, key := range []string{
+		"SHIPPING_SERVICE_ADDR",
+		"PRODUCT_CATALOG_SERVICE_ADDR",
+		"CART_SERVICE_ADDR",
+		"CURRENCY_SERVICE_ADDR",
+		"EMAIL_SERVICE_ADDR",
+	} {
+		c.Addr(key, true)
+	}
+	c.OneOf("PAYMENT_PROVIDER", providerPaymentService, providerStripe)
+	c.Addr("PAYMENT_SERVICE_ADDR", usesPaymentService())
+	if os.Getenv("PAYMENT_PROVIDER") == providerStripe {
+		c.SecretRef("STRIPE_SECRET_KEY_SECRET")
+		c.URL("STRIPE_API_URL")
+		if os.Getenv("STRIPE_SECRET_KEY") == "" && os.Getenv("STRIPE_SECRET_KEY_SECRET") == "" {
+			c.Problemf("STRIPE_SECRET_KEY", "required with PAYMENT_PROVIDER=stripe")
+		}
+	}
+	c.Addr("INVENTORY_SERVICE_ADDR", false)
+
+	// The database settings may each come from a secret instead.
+	c.OneOf("DB_DRIVER", string(dialectPostgres), string(dialectMySQL), string(dialectSQLite))
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" || driver == string(dialectPostgres) {
+		c.DSN("DB_DSN")
+		c.DSN("DB_READ_DSN")
+	} else {
+		if driver == string(dialectMySQL) && os.Getenv("DB_DSN_SECRET") == "" {
+			c.Required("DB_DSN")
+		}
+		if os.Getenv("DB_READ_DSN") != "" || os.Getenv("DB_READ_DSN_SECRET") != "" {
+			c.Problemf("DB_READ_DSN", "requires DB_DRIVER=postgres")
+		}
+	}
+	c.Int("DB_PORT", 1)
+	c.Int("DB_MAX_OPEN_CONNS", 1)
+	c.Int("DB_MAX_IDLE_CONNS", 1)
+	c.Duration("DB_CONN_MAX_LIFETIME", time.Second)
+	c.Duration("DB_CONN_MAX_IDLE_TIME", time.Second)
+	c.OneOf("DB_PREPARE_STATEMENTS", "0", "1")
+	c.Duration("DB_SAVE_TIMEOUT", 0)
+	c.Duration("DB_READ_TIMEOUT", 0)
+	c.Duration("DB_MIGRATE_TIMEOUT", time.Second)
+	c.Duration("DB_CONNECT_TIMEOUT", time.Second)
+	for _, key := range []string{"DB_DSN", "DB_READ_DSN", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME"} {
+		c.SecretRef(key + "_SECRET")
+	}
+	c.SecretRef("CARD_ENCRYPTION_KEY_SECRET")
+	if v := os.Getenv("CARD_ENCRYPTION_KEY"); v != "" {
+		if _, err := cardcrypt.ParseKey(v); err != nil {
+			c.Problemf("CARD_ENCRYPTION_KEY", "%v", err)
+		}
+	}
+	c.Duration("SECRETS_CACHE_TTL", 0)
+	c.OneOf("ORDER_STORE", orderStorePostgres, orderStoreMemory)
+	c.OneOf("SCHEMA_MISMATCH", "fail", "read-only")
+
+	c.OneOf("REPLICATION_MODE", rolePrimary, roleReplica)
+	if mode := os.Getenv("REPLICATION_MODE"); mode != "" {
+		if driver != "" && driver != string(dialectPostgres) {
+			c.Problemf("REPLICATION_MODE", "requires DB_DRIVER=postgres")
+		}
+		c.Required("REGION")
+		if mode == roleReplica && os.Getenv("REPLICATION_PRIMARY_DSN_SECRET") == "" {
+			c.Required("REPLICATION_PRIMARY_DSN")
+		}
+	}
+	c.DSN("REPLICATION_PRIMARY_DSN")
+	c.SecretRef("REPLICATION_PRIMARY_DSN_SECRET")
+	c.Duration("REPLICATION_INTERVAL", time.Millisecond)
+	switch v := os.Getenv("ORDER_EVENTS_SINK"); {
+	case v == "log" || v == "none":
+	case strings.HasPrefix(v, "kafka://") || strings.HasPrefix(v, "pubsub://") || strings.HasPrefix(v, "nats://"):
+		// the broker URLs are checked when the sink connects
+	default:
+		c.URL("ORDER_EVENTS_SINK")
+	}
+	c.Duration("ORDER_EVENTS_INTERVAL", time.Millisecond)
+	if _, err := parseWebhookURLs(os.Getenv("WEBHOOK_URLS")); err != nil {
+		c.Problemf("WEBHOOK_URLS", "%v", err)
+	}
+	c.SecretRef("WEBHOOK_SIGNING_KEY_SECRET")
+	if _, err := parsePromoCodes(os.Getenv("PROMO_CODES")); err != nil {
+		c.Problemf("PROMO_CODES", "%v", err)
+	}
+	if _, err := newLoyaltyProgramFromEnv(); err != nil {
+		c.Problemf("LOYALTY_CURRENCY", "%v", err)
+	}
+	if _, err := newPriceCheckFromEnv(); err != nil {
+		c.Problemf("PRICE_DRIFT_TOLERANCE", "%v", err)
+	}
+	c.OneOf("FRAUD_CHECK", fraudCheckRules, fraudCheckOff)
+	if v := os.Getenv("FRAUD_MAX_AMOUNT"); v != "" {
+		if _, err := parseAmount(v); err != nil {
+			c.Problemf("FRAUD_MAX_AMOUNT", "%v", err)
+		}
+	}
+	c.Int("FRAUD_MAX_ORDERS_PER_EMAIL", 1)
+	c.Duration("FRAUD_VELOCITY_WINDOW", time.Nanosecond)
+	c.SecretRef("ORDER_LOOKUP_KEY_SECRET")
+	c.Int("WEBHOOK_MAX_ATTEMPTS", 1)
+	c.Duration("WEBHOOK_INTERVAL", time.Millisecond)
+	c.Int("ORDER_QUEUE_MAX_ATTEMPTS", 1)
+	c.Int("EMAIL_RETRY_MAX_ATTEMPTS", 1)
+	c.Duration("EMAIL_RETRY_INTERVAL", time.Nanosecond)
+	c.Duration("ORDER_DUPLICATE_WINDOW", 0)
+	c.OneOf("ORDER_DUPLICATE_MODE", duplicateReject, duplicateReplay)
+	c.Int("ORDER_RETENTION_DAYS", 1)
+	c.OneOf("ORDER_RETENTION_MODE", retentionArchive, retentionDelete)
+	c.Duration("ORDER_RETENTION_INTERVAL", time.Second)
+	c.OneOf("CURRENCY_RATES", "stream", "rpc")
+	c.Duration("CURRENCY_RATES_MAX_AGE", time.Second)
+	c.Duration("CURRENCY_CACHE_TTL", 0)
+	c.Duration("CURRENCY_CACHE_NEGATIVE_TTL", 0)
+	c.Int("CIRCUIT_BREAKER_FAILURES", 0)
+	c.Duration("CIRCUIT_BREAKER_OPEN_FOR", time.Nanosecond)
+	c.Float("RATE_LIMIT_PER_USER", 0)
+	c.Int("RATE_LIMIT_BURST", 1)
+	if _, err := callpolicy.FromEnv(dependencies...); err != nil {
+		c.Problemf("CALL_POLICIES", "%v", err)
+	}
+	c.URL("VAULT_ADDR")
+	c.Duration("HEALTH_CHECK_INTERVAL", time.Second)
+	c.OneOf("ADMIN_AUTH", adminAuthToken, adminAuthMTLS)
+	switch os.Getenv("ADMIN_AUTH") {
+	case adminAuthToken:
+		if os.Getenv("ADMIN_TOKEN_SECRET") == "" {
+			c.Required("ADMIN_TOKEN")
+		}
+	case adminAuthMTLS:
+		if mode := strings.ToLower(os.Getenv("MTLS_MODE")); mode == "" || mode == string(mtls.Disabled) {
+			c.Problemf("ADMIN_AUTH", "mtls requires MTLS_MODE=permissive or strict")
+		}
+	case "":
+		if os.Getenv("ADMIN_PORT") != "" {
+			c.Problemf("ADMIN_PORT", "requires ADMIN_AUTH")
+		}
+	}
+	c.SecretRef("ADMIN_TOKEN_SECRET")
+	if os.Getenv("ADMIN_PORT") != "" {
+		c.Port("ADMIN_PORT", "")
+	}
+	return c.Err()
+}
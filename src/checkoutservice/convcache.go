@@ -0,0 +1,202 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+)
+
+// Conversions that cannot use the streamed rates, because they are stale or
+// turned off, go through conversionCache: it keeps the exchange rate of each
+// pair of currencies it converted between for CURRENCY_CACHE_TTL (default
+// 1m), so that the items and shipping cost of an order, and of the orders
+// after it, cost one Convert call per pair rather than one per price. The rate
+// of a pair is that of a conversion of cacheReferenceUnits, so that it keeps
+// the precision of the currency service. A pair the currency service refuses,
+// such as one with an unsupported currency, is refused again without calling
+// it for CURRENCY_CACHE_NEGATIVE_TTL (default 5s); other failures are not
+// cached. CURRENCY_CACHE_TTL=0 turns the cache off.
+
+const (
+	defaultCacheTTL         = time.Minute
+	defaultCacheNegativeTTL = 5 * time.Second
+	// cacheReferenceUnits is the amount converted to find the rate of a
+	// pair.
+	cacheReferenceUnits = 1000000
+
+	// cache lookup results, as reported in metrics
+	cacheHit      = "hit"
+	cacheMiss     = "miss"
+	cacheNegative = "negative"
+)
+
+// conversionCache caches the exchange rates of currency pairs. A nil
+// conversionCache calls convert for every conversion.
+type conversionCache struct {
+	ttl, negativeTTL time.Duration
+	now              func() time.Time
+
+	mu    sync.Mutex
+	pairs map[currencyPair]cachedRate
+
+	lookups metric.Int64Counter
+}
+
+type currencyPair struct{ from, to string }
+
+// cachedRate is the rate of a currency pair, or the error converting
+// between them failed with, until expires.
+type cachedRate struct {
+	rate    float64
+	err     error
+	expires time.Time
+}
+
+// newConversionCacheFromEnv returns the cache set up by CURRENCY_CACHE_TTL
+// and CURRENCY_CACHE_NEGATIVE_TTL, or nil if it is turned off.
+func newConversionCacheFromEnv() (*conversionCache, error) {
+	c := newConversionCache()
+	if v := os.Getenv("CURRENCY_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid CURRENCY_CACHE_TTL %q", v)
+		}
+		if d == 0 {
+			return nil, nil
+		}
+		c.ttl = d
+	}
+	if v := os.Getenv("CURRENCY_CACHE_NEGATIVE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid CURRENCY_CACHE_NEGATIVE_TTL %q", v)
+		}
+		c.negativeTTL = d
+	}
+	var err error
+	c.lookups, err = otel.Meter("checkoutservice").Int64Counter(
+		"checkout.currency.cache.lookups",
+		metric.WithDescription("Lookups of the currency conversion cache, by result: hit, miss, or negative for a cached failure."),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newConversionCache() *conversionCache {
+	return &conversionCache{
+		ttl:         defaultCacheTTL,
+		negativeTTL: defaultCacheNegativeTTL,
+		now:         time.Now,
+		pairs:       make(map[currencyPair]cachedRate),
+	}
+}
+
+// String describes the cache for startup logs.
+func (c *conversionCache) String() string {
+	if c == nil {
+		return "off"
+	}
+	return fmt.Sprintf("TTL %s, failures %s", c.ttl, c.negativeTTL)
+}
+
+// convert converts from into toCurrency with the cached rate of the pair,
+// calling convert to find it unless it is cached.
+func (c *conversionCache) convert(ctx context.Context, from *pb.Money, toCurrency string,
+	convert func(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error)) (*pb.Money, error) {
+
+	if c == nil {
+		return convert(ctx, from, toCurrency)
+	}
+	pair := currencyPair{from: from.GetCurrencyCode(), to: toCurrency}
+	c.mu.Lock()
+	cached, ok := c.pairs[pair]
+	if ok && !c.now().Before(cached.expires) {
+		delete(c.pairs, pair)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	result := cacheHit
+	switch {
+	case !ok:
+		result = cacheMiss
+		cached = c.fetch(ctx, pair, convert)
+	case cached.err != nil:
+		result = cacheNegative
+	}
+	if c.lookups != nil {
+		c.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	}
+	if cached.err != nil {
+		return nil, cached.err
+	}
+	converted, err := money.Convert(*from, 1, cached.rate, toCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %v to %s at the cached rate %g: %w", from, toCurrency, cached.rate, err)
+	}
+	return &converted, nil
+}
+
+// fetch finds the rate of pair with convert, and caches it, or the error it
+// failed with if the currency service refused the pair.
+func (c *conversionCache) fetch(ctx context.Context, pair currencyPair,
+	convert func(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error)) cachedRate {
+
+	reference := &pb.Money{CurrencyCode: pair.from, Units: cacheReferenceUnits}
+	var cached cachedRate
+	if result, err := convert(ctx, reference, pair.to); err != nil {
+		cached = cachedRate{err: err, expires: c.now().Add(c.negativeTTL)}
+		if !refusedConversion(err) {
+			return cached
+		}
+	} else {
+		rate := (float64(result.GetUnits()) + float64(result.GetNanos())/1e9) / cacheReferenceUnits
+		if rate <= 0 {
+			// a rate of zero would convert every price to nothing
+			return cachedRate{err: fmt.Errorf("currency service converted %v to %v", reference, result)}
+		}
+		cached = cachedRate{rate: rate, expires: c.now().Add(c.ttl)}
+	}
+	c.mu.Lock()
+	c.pairs[pair] = cached
+	c.mu.Unlock()
+	return cached
+}
+
+// refusedConversion reports whether a conversion failed because of the
+// currencies rather than of the currency service.
+func refusedConversion(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition, codes.OutOfRange:
+		return true
+	}
+	return false
+}
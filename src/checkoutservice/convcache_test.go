@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestConversionCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	c := newConversionCache()
+	c.now = func() time.Time { return now }
+	calls := 0
+	convert := func(_ context.Context, from *pb.Money, to string) (*pb.Money, error) {
+		calls++
+		if to == "XXX" {
+			return nil, status.Error(codes.InvalidArgument, "unsupported currency")
+		}
+		if to == "GBP" {
+			return nil, status.Error(codes.Unavailable, "connection refused")
+		}
+		// 1 USD is 0.9 EUR
+		return &pb.Money{CurrencyCode: to, Units: from.GetUnits() * 9 / 10}, nil
+	}
+
+	for _, units := range []int64{10, 20} {
+		got, err := c.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: units}, "EUR", convert)
+		if err != nil || got.GetCurrencyCode() != "EUR" || got.GetUnits() != units*9/10 || got.GetNanos() != 0 {
+			t.Errorf("convert(%d USD) = %v, %v; want %d EUR", units, got, err, units*9/10)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("%d conversions for one pair, want 1", calls)
+	}
+	if got, _ := c.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: 1, Nanos: 500000000}, "EUR", convert); got.GetUnits() != 1 || got.GetNanos() != 350000000 {
+		t.Errorf("convert(1.5 USD) = %v, want 1.35 EUR", got)
+	}
+
+	now = now.Add(c.ttl)
+	if _, err := c.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: 10}, "EUR", convert); err != nil || calls != 2 {
+		t.Errorf("convert once the rate expired = %v, with %d conversions; want the rate fetched again", err, calls)
+	}
+
+	// refused pairs are cached, but not failures of the currency service
+	for range 2 {
+		if _, err := c.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: 10}, "XXX", convert); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("convert to an unsupported currency = %v, want InvalidArgument", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("%d conversions after refused ones, want the refusal cached", calls)
+	}
+	now = now.Add(c.negativeTTL)
+	if _, err := c.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: 10}, "XXX", convert); err == nil || calls != 4 {
+		t.Errorf("convert once the refusal expired = %v, with %d conversions; want it tried again", err, calls)
+	}
+	for range 2 {
+		if _, err := c.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: 10}, "GBP", convert); status.Code(err) != codes.Unavailable {
+			t.Errorf("convert while the currency service is down = %v, want Unavailable", err)
+		}
+	}
+	if calls != 6 {
+		t.Errorf("%d conversions, want failures of the currency service not cached", calls)
+	}
+
+	var off *conversionCache
+	if got, err := off.convert(ctx, &pb.Money{CurrencyCode: "USD", Units: 10}, "EUR", convert); err != nil || got.GetUnits() != 9 || calls != 7 {
+		t.Errorf("convert without a cache = %v, %v; want a conversion each time", got, err)
+	}
+}
+
+func TestConversionCacheFromEnv(t *testing.T) {
+	c, err := newConversionCacheFromEnv()
+	if err != nil || c.ttl != defaultCacheTTL || c.negativeTTL != defaultCacheNegativeTTL {
+		t.Errorf("newConversionCacheFromEnv() = %v, %v; want the defaults", c, err)
+	}
+	t.Setenv("CURRENCY_CACHE_NEGATIVE_TTL", "1s")
+	t.Setenv("CURRENCY_CACHE_TTL", "30s")
+	if c, err := newConversionCacheFromEnv(); err != nil || c.ttl != 30*time.Second || c.negativeTTL != time.Second {
+		t.Errorf("newConversionCacheFromEnv() = %v, %v; want TTLs of 30s and 1s", c, err)
+	}
+	t.Setenv("CURRENCY_CACHE_TTL", "0")
+	if c, err := newConversionCacheFromEnv(); c != nil || err != nil {
+		t.Errorf("newConversionCacheFromEnv() with CURRENCY_CACHE_TTL=0 = %v, %v; want nil", c, err)
+	}
+	t.Setenv("CURRENCY_CACHE_TTL", "soon")
+	if _, err := newConversionCacheFromEnv(); err == nil {
+		t.Error("newConversionCacheFromEnv() with an invalid TTL succeeded")
+	}
+}
@@ -3,33 +3,146 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardcrypt"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+var (
+	// errOrderNotFound is returned when no order has the requested ID.
+	errOrderNotFound = errors.New("order not found")
+	// errOrderExists is returned when saving an order that was saved.
+	errOrderExists = errors.New("order already exists")
 )
 
+// orderExistsError is returned when saving an order whose ID was already
+// saved, and wraps errOrderExists. Inserts do nothing on conflict, so saving
+// an order again, as when it is redelivered after a partial failure, changes
+// nothing.
+type orderExistsError struct {
+	OrderID string
+	// Redelivered reports whether the saved order is the one being saved:
+	// of the same user, charged with the same payment transaction. The
+	// save can then be taken as done.
+	Redelivered bool
+}
+
+func (e *orderExistsError) Error() string {
+	return fmt.Sprintf("%v: %s", errOrderExists, e.OrderID)
+}
+
+func (e *orderExistsError) Is(target error) bool { return target == errOrderExists }
+
+// redelivers reports whether saving the order of userID charged with
+// transactionID would save o again.
+func (o *Order) redelivers(userID, transactionID string) bool {
+	return o.UserID == userID && o.PaymentTransactionID == transactionID
+}
+
+// savedOrderExists returns the orderExistsError of rec, whose ID was already
+// saved.
+func savedOrderExists(ctx context.Context, q queryer, rec orderRecord) error {
+	var userID, transactionID string
+	err := q.QueryRowContext(ctx, `
+        SELECT user_id, payment_transaction_id FROM orders WHERE order_id = $1
+    `, rec.Order.OrderID).Scan(&userID, &transactionID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read saved order: %w", err)
+	}
+	return &orderExistsError{OrderID: rec.Order.OrderID, Redelivered: err == nil && rec.Order.redelivers(userID, transactionID)}
+}
+
+// OrderStore is the OrderStorage of a SQL database, PostgreSQL unless
+// dialect says otherwise.
 type OrderStore struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
+	// replica, if set, serves order reads; see dbreplica.go.
+	replica *readReplica
+	// cards encrypts the card data retained with orders; none is retained
+	// when it is nil
+	cards *cardcrypt.Cipher
+	// webhooks are the URLs the events of orders are delivered to; see
+	// webhooks.go.
+	webhooks []string
+	// stmts keeps the statements that save and read orders prepared, or
+	// is nil if they run unprepared; see stmtcache.go.
+	stmts *stmtCache
+	// timeouts bound its operations; see dbtimeout.go.
+	timeouts dbTimeouts
 }
 
 type Order struct {
-	OrderID                   string
-	UserID                    string
-	Email                     string
-	StreetAddress             string
-	City                      string
-	State                     string
-	Country                   string
-	ZipCode                   string
-	CreditCardNumber          string
-	CreditCardCVV             string
-	CreditCardExpirationMonth int32
-	CreditCardExpirationYear  int32
-	OrderTotal                float64
-	CurrencyCode              string
-	ShippingTrackingID        string
-	CreatedAt                 time.Time
+	OrderID       string
+	UserID        string
+	Email         string
+	StreetAddress string
+	City          string
+	State         string
+	Country       string
+	ZipCode       string
+	// CardToken is the token of the card the order was paid with, and
+	// CardLast4 and CardBrand what can be shown of it; see cardvault.go. They
+	// are empty for orders placed before cards were tokenized.
+	CardToken string
+	CardLast4 string
+	CardBrand string
+	// OrderTotalUnits and OrderTotalNanos are those of the pb.Money total.
+	OrderTotalUnits    int64
+	OrderTotalNanos    int32
+	CurrencyCode       string
+	ShippingTrackingID string
+	CreatedAt          time.Time
+	Status             OrderStatus
+	// PaymentTransactionID is that of the charge, empty for orders placed
+	// before it was stored.
+	PaymentTransactionID string
+	// ShippingCostUnits and ShippingCostNanos are those of the shipping
+	// quote, and TaxUnits and TaxNanos those of the tax, in CurrencyCode and
+	// included in the total. No tax is charged yet, so it is zero.
+	ShippingCostUnits int64
+	ShippingCostNanos int32
+	TaxUnits          int64
+	TaxNanos          int32
+	// ShippingQuoteUnits, ShippingQuoteNanos and ShippingQuoteCurrencyCode
+	// are those of the shipping quote as the shipping service made it,
+	// before it was converted to CurrencyCode; they are zero for orders
+	// placed before the quote was stored.
+	ShippingQuoteUnits        int64
+	ShippingQuoteNanos        int32
+	ShippingQuoteCurrencyCode string
+	// BillingStreetAddress and the other Billing fields are those of the
+	// billing address of the payment, empty for orders placed before it was
+	// stored.
+	BillingStreetAddress string
+	BillingCity          string
+	BillingState         string
+	BillingCountry       string
+	BillingZipCode       string
+	// GiftMessage and CustomerNote are those the order was placed with,
+	// empty if it was placed without them.
+	GiftMessage  string
+	CustomerNote string
+	// PromoCode is the promo code the order was placed with, if any, and
+	// DiscountUnits and DiscountNanos those of the discount that it and
+	// redeemed points took off the items, in CurrencyCode; the total is net
+	// of it.
+	PromoCode     string
+	DiscountUnits int64
+	DiscountNanos int32
+	// PointsEarned and PointsRedeemed are the loyalty points the order
+	// earned and redeemed; see loyalty.go.
+	PointsEarned   int64
+	PointsRedeemed int64
+	// Items are replicated in orderRecord.Items instead.
+	Items []OrderItem `json:"-"`
 }
 
 type OrderItem struct {
@@ -37,18 +150,96 @@ type OrderItem struct {
 	OrderID   string
 	ProductID string
 	Quantity  int32
+	// UnitPriceUnits, UnitPriceNanos and CurrencyCode are those of the
+	// price of one product, zero for orders placed before prices were
+	// stored.
+	UnitPriceUnits int64
+	UnitPriceNanos int32
+	CurrencyCode   string
 }
 
 // creates a new order store
 func NewOrderStore(db *sql.DB) *OrderStore {
-	return &OrderStore{db: db}
+	return &OrderStore{db: db, dialect: dialectPostgres, stmts: newStmtCache(), timeouts: defaultDBTimeouts}
+}
+
+// IdempotencyRecord is the idempotency key that a request placed an order
+// with, and the response to replay when the key is used again.
+type IdempotencyRecord struct {
+	Key string
+	// Fingerprint tells the request apart from others using the same key.
+	Fingerprint []byte
+	Response    []byte
 }
 
-// persists an order to the database
-func (os *OrderStore) SaveOrder(ctx context.Context, orderID, userID, email string,
-	address *pb.Address, creditCard *pb.CreditCardInfo, total *pb.Money,
-	items []*pb.CartItem, trackingID string) error {
+// stamped returns rec as it is recorded now with status.
+func (rec orderRecord) stamped(status OrderStatus) orderRecord {
+	rec.Order.Status = status
+	rec.Order.CreatedAt = time.Now()
+	return rec
+}
+
+// setShippingAddress sets the fields of the address the order is shipped to.
+func (o *Order) setShippingAddress(a *pb.Address) {
+	o.StreetAddress = a.GetStreetAddress()
+	o.City = a.GetCity()
+	o.State = a.GetState()
+	o.Country = a.GetCountry()
+	o.ZipCode = fmt.Sprint(a.GetZipCode())
+}
 
+// setBillingAddress sets the Billing fields, leaving them empty if a is nil.
+func (o *Order) setBillingAddress(a *pb.Address) {
+	if a == nil {
+		return
+	}
+	o.BillingStreetAddress = a.GetStreetAddress()
+	o.BillingCity = a.GetCity()
+	o.BillingState = a.GetState()
+	o.BillingCountry = a.GetCountry()
+	o.BillingZipCode = fmt.Sprint(a.GetZipCode())
+}
+
+// newOrderItems returns the rows of the items of an order.
+func newOrderItems(orderID string, items []*pb.OrderItem) []OrderItem {
+	var out []OrderItem
+	for _, item := range items {
+		out = append(out, OrderItem{
+			OrderID:        orderID,
+			ProductID:      item.GetItem().GetProductId(),
+			Quantity:       item.GetItem().GetQuantity(),
+			UnitPriceUnits: item.GetCost().GetUnits(),
+			UnitPriceNanos: item.GetCost().GetNanos(),
+			CurrencyCode:   item.GetCost().GetCurrencyCode(),
+		})
+	}
+	return out
+}
+
+// persists a paid order to the database, along with the idempotency key it
+// was placed with unless rec.Idempotency is nil
+func (os *OrderStore) SaveOrder(ctx context.Context, rec orderRecord) (err error) {
+	ctx, span := startStoreSpan(ctx, "SaveOrder")
+	defer func() { endSpan(span, err) }()
+	rec = rec.stamped(StatusPaid)
+	retried := false
+	return withDBTimeout(ctx, os.timeouts.save, "SaveOrder", func(ctx context.Context) error {
+		return retryDB(ctx, "save order "+rec.Order.OrderID, func() error {
+			err := os.saveRecord(ctx, rec)
+			os.stmts.reset(err)
+			if exists := (*orderExistsError)(nil); retried && errors.As(err, &exists) && exists.Redelivered {
+				// the previous attempt committed, but failed to report it
+				return nil
+			}
+			retried = true
+			return err
+		})
+	})
+}
+
+// persists the record of a newly placed order, failing with an
+// orderExistsError if the order was already persisted
+func (os *OrderStore) saveRecord(ctx context.Context, rec orderRecord) (err error) {
 	tx, err := os.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -59,77 +250,326 @@ func (os *OrderStore) SaveOrder(ctx context.Context, orderID, userID, email stri
 		}
 	}()
 
-	maskedCard := maskCreditCard(creditCard.CreditCardNumber)
+	q := os.stmts.on(os.db, tx)
+	inserted, err := insertOrderRecord(ctx, os.dialect, q, rec)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		var confirmed bool
+		if confirmed, err = confirmPendingOrder(ctx, os.dialect, q, rec); err != nil {
+			return err
+		}
+		if !confirmed {
+			err = savedOrderExists(ctx, q, rec)
+			return err
+		}
+	}
+	if err = applyOrderPoints(ctx, os.dialect, q, rec.Order, true); err != nil {
+		return err
+	}
+	if os.dialect.hasOutbox() {
+		// the outbox feeds replicas in other regions; see replication.go
+		if err = appendOutbox(ctx, q, rec); err != nil {
+			return err
+		}
+		// and order_events feeds downstream systems; see events.go
+		if err = appendOrderEvent(ctx, q, orderPlaced, rec, os.webhooks); err != nil {
+			return err
+		}
+	}
 
+	_, span := startStatement(ctx, "COMMIT", "orders")
+	err = tx.Commit()
+	endStatement(span, 0, err)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.WithContext(ctx).Info("order persisted to database")
+	return nil
+}
+
+// orderRecord is an order with its items, as stored and replicated.
+type orderRecord struct {
+	Order Order
+	Items []OrderItem
+	// Idempotency is only set when the order is placed.
+	Idempotency *IdempotencyRecord `json:",omitempty"`
+}
+
+// inserts an order and its items, unless an order with the same ID exists,
+// and reports whether it did
+func insertOrderRecord(ctx context.Context, d dialect, tx dbtx, rec orderRecord) (bool, error) {
 	insertOrderSQL := `
         INSERT INTO orders (
             order_id, user_id, email, street_address, city, state, country, zip_code,
-            credit_card_number, credit_card_cvv, credit_card_expiration_month,
-            credit_card_expiration_year, order_total, currency_code, shipping_tracking_id, created_at
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-    `
-
-	orderTotal := float64(total.Units) + float64(total.Nanos)/1e9
-
-	_, err = tx.ExecContext(ctx, insertOrderSQL,
-		orderID,
-		userID,
-		email,
-		address.StreetAddress,
-		address.City,
-		address.State,
-		address.Country,
-		address.ZipCode,
-		maskedCard,
-		creditCard.CreditCardCvv,
-		creditCard.CreditCardExpirationMonth,
-		creditCard.CreditCardExpirationYear,
-		orderTotal,
-		total.CurrencyCode,
-		trackingID,
-		time.Now(),
+            card_token, card_last4, card_brand, order_total_units, order_total_nanos, currency_code, shipping_tracking_id, created_at, status,
+            payment_transaction_id, shipping_cost_units, shipping_cost_nanos, tax_units, tax_nanos,
+            shipping_quote_units, shipping_quote_nanos, shipping_quote_currency_code,
+            billing_street_address, billing_city, billing_state, billing_country, billing_zip_code,
+            gift_message, customer_note, promo_code, discount_units, discount_nanos, points_earned, points_redeemed
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23,
+            $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37)
+        ` + d.ignoreDuplicate("order_id")
+
+	o := rec.Order
+	// orders replicated from older versions have no status
+	status := o.Status
+	if status == "" {
+		status = StatusPaid
+	}
+	sctx, span := startStatement(ctx, "INSERT", "orders")
+	res, err := tx.ExecContext(sctx, insertOrderSQL,
+		o.OrderID,
+		o.UserID,
+		o.Email,
+		o.StreetAddress,
+		o.City,
+		o.State,
+		o.Country,
+		o.ZipCode,
+		o.CardToken,
+		o.CardLast4,
+		o.CardBrand,
+		o.OrderTotalUnits,
+		o.OrderTotalNanos,
+		o.CurrencyCode,
+		o.ShippingTrackingID,
+		o.CreatedAt,
+		status,
+		o.PaymentTransactionID,
+		o.ShippingCostUnits,
+		o.ShippingCostNanos,
+		o.TaxUnits,
+		o.TaxNanos,
+		o.ShippingQuoteUnits,
+		o.ShippingQuoteNanos,
+		o.ShippingQuoteCurrencyCode,
+		o.BillingStreetAddress,
+		o.BillingCity,
+		o.BillingState,
+		o.BillingCountry,
+		o.BillingZipCode,
+		o.GiftMessage,
+		o.CustomerNote,
+		o.PromoCode,
+		o.DiscountUnits,
+		o.DiscountNanos,
+		o.PointsEarned,
+		o.PointsRedeemed,
 	)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	endStatement(span, n, err)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert order: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
 
+	if err := insertOrderItems(ctx, d, tx, o.OrderID, rec.Items); err != nil {
+		return false, err
+	}
+	if err := insertIdempotencyRecord(ctx, d, tx, o, rec.Idempotency); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// inserts the idempotency key that order o was placed with, unless idem is
+// nil
+func insertIdempotencyRecord(ctx context.Context, d dialect, tx dbtx, o Order, idem *IdempotencyRecord) error {
+	if idem == nil {
+		return nil
+	}
+	// an expired key is reused rather than kept forever
+	upsert := `
+            ON CONFLICT (user_id, idempotency_key) DO UPDATE
+            SET fingerprint = EXCLUDED.fingerprint, order_id = EXCLUDED.order_id,
+                response = EXCLUDED.response, created_at = EXCLUDED.created_at
+            WHERE idempotency_keys.created_at < $7
+        `
+	if d == dialectMySQL {
+		// created_at is set last, so that the other columns compare
+		// its old value
+		upsert = `
+            ON DUPLICATE KEY UPDATE
+                fingerprint = IF(created_at < $7, VALUES(fingerprint), fingerprint),
+                order_id = IF(created_at < $7, VALUES(order_id), order_id),
+                response = IF(created_at < $7, VALUES(response), response),
+                created_at = IF(created_at < $7, VALUES(created_at), created_at)
+        `
+	}
+	sctx, span := startStatement(ctx, "INSERT", "idempotency_keys")
+	res, err := tx.ExecContext(sctx, `
+            INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, order_id, response, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `+upsert, o.UserID, idem.Key, idem.Fingerprint, o.OrderID, idem.Response, o.CreatedAt, o.CreatedAt.Add(-idempotencyTTL))
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	endStatement(span, n, err)
 	if err != nil {
-		return fmt.Errorf("failed to insert order: %w", err)
+		return fmt.Errorf("failed to insert idempotency key: %w", err)
+	}
+	// the order was charged, so it is kept even though retries of its
+	// request will replay the other one
+	if n == 0 {
+		log.WithContext(ctx).Warnf("order %s was placed with idempotency key %q, which another order placed concurrently already used", o.OrderID, idem.Key)
 	}
+	return nil
+}
 
-	insertItemSQL := `
-        INSERT INTO order_items (order_id, product_id, quantity)
-        VALUES ($1, $2, $3)
+// inserts the items of an order in one statement, in the order given, so
+// that large carts do not take a round trip per item
+func insertOrderItems(ctx context.Context, d dialect, tx dbtx, orderID string, items []OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var (
+		query string
+		args  []any
+	)
+	if d == dialectPostgres {
+		productIDs := make([]string, len(items))
+		quantities := make([]int32, len(items))
+		priceUnits := make([]int64, len(items))
+		priceNanos := make([]int32, len(items))
+		currencies := make([]string, len(items))
+		for i, item := range items {
+			productIDs[i] = item.ProductID
+			quantities[i] = item.Quantity
+			priceUnits[i] = item.UnitPriceUnits
+			priceNanos[i] = item.UnitPriceNanos
+			currencies[i] = item.CurrencyCode
+		}
+		query = `
+        INSERT INTO order_items (order_id, product_id, quantity, unit_price_units, unit_price_nanos, currency_code)
+        SELECT $1, item.product_id, item.quantity, item.unit_price_units, item.unit_price_nanos, item.currency_code
+        FROM unnest($2::text[], $3::integer[], $4::bigint[], $5::integer[], $6::text[])
+            WITH ORDINALITY AS item(product_id, quantity, unit_price_units, unit_price_nanos, currency_code, n)
+        ORDER BY item.n
     `
-
-	for _, item := range items {
-		_, err = tx.ExecContext(ctx, insertItemSQL,
-			orderID,
-			item.ProductId,
-			item.Quantity,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert order item: %w", err)
+		args = []any{orderID, pq.Array(productIDs), pq.Array(quantities), pq.Array(priceUnits), pq.Array(priceNanos), pq.Array(currencies)}
+	} else {
+		rows := make([]string, len(items))
+		args = []any{orderID}
+		for i, item := range items {
+			args = append(args, item.ProductID, item.Quantity, item.UnitPriceUnits, item.UnitPriceNanos, item.CurrencyCode)
+			n := len(args)
+			rows[i] = fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d)", n-4, n-3, n-2, n-1, n)
 		}
+		query = "INSERT INTO order_items (order_id, product_id, quantity, unit_price_units, unit_price_nanos, currency_code) VALUES " + strings.Join(rows, ", ")
+	}
+	ctx, span := startStatement(ctx, "INSERT", "order_items")
+	_, err := tx.ExecContext(ctx, query, args...)
+	endStatement(span, int64(len(items)), err)
+	if err != nil {
+		return fmt.Errorf("failed to insert order items: %w", err)
 	}
+	return nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+// retrieves the idempotency key that userID placed an order with, or nil
+// if the user has not used key in the last idempotencyTTL
+func (os *OrderStore) GetIdempotencyRecord(ctx context.Context, userID, key string) (*IdempotencyRecord, error) {
+	rec := &IdempotencyRecord{Key: key}
+	err := withDBTimeout(ctx, os.timeouts.read, "GetIdempotencyRecord", func(ctx context.Context) error {
+		return retryDB(ctx, "query idempotency key", func() error {
+			return os.db.QueryRowContext(ctx, `
+	            SELECT fingerprint, response FROM idempotency_keys
+	            WHERE user_id = $1 AND idempotency_key = $2 AND created_at >= $3
+	        `, userID, key, time.Now().Add(-idempotencyTTL)).Scan(&rec.Fingerprint, &rec.Response)
+		})
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query idempotency key: %w", err)
 	}
+	return rec, nil
+}
 
-	log.Infof("Order %s persisted to database successfully", orderID)
-	return nil
+// retrieves the items of an order
+func getOrderItems(ctx context.Context, q queryer, orderID string) (items []OrderItem, err error) {
+	ctx, span := startStatement(ctx, "SELECT", "order_items")
+	defer func() { endStatement(span, int64(len(items)), err) }()
+	rows, err := q.QueryContext(ctx, `
+        SELECT id, order_id, product_id, quantity, unit_price_units, unit_price_nanos, currency_code
+        FROM order_items WHERE order_id = $1 ORDER BY id
+    `, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.UnitPriceUnits, &item.UnitPriceNanos, &item.CurrencyCode); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
 }
 
 // retrieves an order from the database
-func (os *OrderStore) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+func (os *OrderStore) GetOrder(ctx context.Context, orderID string) (_ *Order, err error) {
+	ctx, span := startStoreSpan(ctx, "GetOrder")
+	defer func() { endSpan(span, err) }()
+	var order *Order
+	err = withDBTimeout(ctx, os.timeouts.read, "GetOrder", func(ctx context.Context) error {
+		return retryDB(ctx, "get order "+orderID, func() (err error) {
+			return os.read(ctx, func(db *sql.DB) (err error) {
+				order, err = getOrder(ctx, os.stmts.on(db, nil), orderID)
+				os.stmts.reset(err)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// queryer is a database or a transaction.
+type queryer interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// dbtx is a database or a transaction that statements are also executed in.
+type dbtx interface {
+	queryer
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+// orderColumns are the columns of orders, in the order they are scanned.
+const orderColumns = `order_id, user_id, email, street_address, city, state, country, zip_code,
+    card_token, card_last4, card_brand, order_total_units, order_total_nanos, currency_code, shipping_tracking_id, created_at, status,
+    payment_transaction_id, shipping_cost_units, shipping_cost_nanos, tax_units, tax_nanos,
+    shipping_quote_units, shipping_quote_nanos, shipping_quote_currency_code,
+    billing_street_address, billing_city, billing_state, billing_country, billing_zip_code,
+    gift_message, customer_note, promo_code, discount_units, discount_nanos, points_earned, points_redeemed`
+
+// retrieves an order and its items
+func getOrder(ctx context.Context, q queryer, orderID string) (*Order, error) {
 	order := &Order{}
 
 	query := `
-        SELECT order_id, user_id, email, street_address, city, state, country, zip_code,
-               credit_card_number, credit_card_cvv, credit_card_expiration_month,
-               credit_card_expiration_year, order_total, currency_code, shipping_tracking_id, created_at
+        SELECT ` + orderColumns + `
         FROM orders WHERE order_id = $1
     `
 
-	err := os.db.QueryRowContext(ctx, query, orderID).Scan(
+	sctx, span := startStatement(ctx, "SELECT", "orders")
+	err := q.QueryRowContext(sctx, query, orderID).Scan(
 		&order.OrderID,
 		&order.UserID,
 		&order.Email,
@@ -138,42 +578,164 @@ func (os *OrderStore) GetOrder(ctx context.Context, orderID string) (*Order, err
 		&order.State,
 		&order.Country,
 		&order.ZipCode,
-		&order.CreditCardNumber,
-		&order.CreditCardCVV,
-		&order.CreditCardExpirationMonth,
-		&order.CreditCardExpirationYear,
-		&order.OrderTotal,
+		&order.CardToken,
+		&order.CardLast4,
+		&order.CardBrand,
+		&order.OrderTotalUnits,
+		&order.OrderTotalNanos,
 		&order.CurrencyCode,
 		&order.ShippingTrackingID,
 		&order.CreatedAt,
+		&order.Status,
+		&order.PaymentTransactionID,
+		&order.ShippingCostUnits,
+		&order.ShippingCostNanos,
+		&order.TaxUnits,
+		&order.TaxNanos,
+		&order.ShippingQuoteUnits,
+		&order.ShippingQuoteNanos,
+		&order.ShippingQuoteCurrencyCode,
+		&order.BillingStreetAddress,
+		&order.BillingCity,
+		&order.BillingState,
+		&order.BillingCountry,
+		&order.BillingZipCode,
+		&order.GiftMessage,
+		&order.CustomerNote,
+		&order.PromoCode,
+		&order.DiscountUnits,
+		&order.DiscountNanos,
+		&order.PointsEarned,
+		&order.PointsRedeemed,
 	)
-
+	endStatement(span, 1, err)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("order not found: %s", orderID)
+			return nil, fmt.Errorf("%w: %s", errOrderNotFound, orderID)
 		}
 		return nil, fmt.Errorf("failed to query order: %w", err)
 	}
 
+	order.Items, err = getOrderItems(ctx, q, orderID)
+	if err != nil {
+		return nil, err
+	}
 	return order, nil
 }
 
-// retrieves all orders for a user
-func (os *OrderStore) GetUserOrders(ctx context.Context, userID string) ([]Order, error) {
+// OrderQuery selects a page of a user's orders, newest first.
+type OrderQuery struct {
+	// Limit is the maximum number of orders returned, or 0 for all of them.
+	Limit int
+	// After, if set, is the last order of the previous page.
+	After *OrderCursor
+	// CreatedAfter and CreatedBefore, if not zero, restrict the orders to
+	// those created at or after CreatedAfter and before CreatedBefore.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Statuses, if not empty, restricts the orders to those in one of them,
+	// and otherwise to those that were placed, leaving out attemptStatuses.
+	Statuses []OrderStatus
+}
+
+// OrderCursor is the position of an order in a user's orders.
+type OrderCursor struct {
+	CreatedAt time.Time
+	OrderID   string
+}
+
+// sql returns the query selecting the orders of userID, and its arguments.
+func (q OrderQuery) sql(d dialect, userID string) (string, []any) {
+	return q.selectSQL(d, []string{"user_id = $1"}, []any{userID})
+}
+
+// selectSQL returns the query selecting the orders that meet conds, whose
+// placeholders stand for args, and q.
+func (q OrderQuery) selectSQL(d dialect, conds []string, args []any) (string, []any) {
 	query := `
-        SELECT order_id, user_id, email, street_address, city, state, country, zip_code,
-               credit_card_number, credit_card_cvv, credit_card_expiration_month,
-               credit_card_expiration_year, order_total, currency_code, shipping_tracking_id, created_at
-        FROM orders WHERE user_id = $1 ORDER BY created_at DESC
-    `
+        SELECT ` + orderColumns + `
+        FROM orders`
+	if !q.CreatedAfter.IsZero() {
+		args = append(args, q.CreatedAfter)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !q.CreatedBefore.IsZero() {
+		args = append(args, q.CreatedBefore)
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if len(q.Statuses) > 0 {
+		statuses := make([]string, len(q.Statuses))
+		for i, st := range q.Statuses {
+			statuses[i] = string(st)
+		}
+		conds = append(conds, d.inList("status", statuses, &args))
+	} else {
+		conds = append(conds, "status NOT IN ('pending', 'failed')")
+	}
+	if q.After != nil {
+		args = append(args, q.After.CreatedAt, q.After.OrderID)
+		conds = append(conds, fmt.Sprintf("(created_at, order_id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	// order_id breaks ties so that pages neither skip nor repeat orders
+	query += " ORDER BY created_at DESC, order_id DESC"
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	return query, args
+}
+
+// retrieves the orders of a user selected by q
+func (os *OrderStore) GetUserOrders(ctx context.Context, userID string, q OrderQuery) (_ []Order, err error) {
+	ctx, span := startStoreSpan(ctx, "GetUserOrders")
+	defer func() { endSpan(span, err) }()
+	var orders []Order
+	query, args := q.sql(os.dialect, userID)
+	err = withDBTimeout(ctx, os.timeouts.read, "GetUserOrders", func(ctx context.Context) error {
+		return retryDB(ctx, "get orders of user "+userID, func() (err error) {
+			return os.read(ctx, func(db *sql.DB) (err error) {
+				orders, err = os.getOrders(ctx, db, query, args)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
 
-	rows, err := os.db.QueryContext(ctx, query, userID)
+// retrieves the orders that query selects, with their items
+func (os *OrderStore) getOrders(ctx context.Context, db *sql.DB, query string, args []any) ([]Order, error) {
+	orders, err := queryOrders(ctx, db, query, args)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderID
+	}
+	items, err := getItemsOfOrders(ctx, os.dialect, db, ids)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query user orders: %w", err)
+		return nil, err
+	}
+	attachItems(orders, items)
+	return orders, nil
+}
+
+// retrieves the orders that query selects, without their items
+func queryOrders(ctx context.Context, db *sql.DB, query string, args []any) (orders []Order, err error) {
+	ctx, span := startStatement(ctx, "SELECT", "orders")
+	defer func() { endStatement(span, int64(len(orders)), err) }()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
 	}
 	defer rows.Close()
 
-	var orders []Order
 	for rows.Next() {
 		order := Order{}
 		err := rows.Scan(
@@ -185,80 +747,178 @@ func (os *OrderStore) GetUserOrders(ctx context.Context, userID string) ([]Order
 			&order.State,
 			&order.Country,
 			&order.ZipCode,
-			&order.CreditCardNumber,
-			&order.CreditCardCVV,
-			&order.CreditCardExpirationMonth,
-			&order.CreditCardExpirationYear,
-			&order.OrderTotal,
+			&order.CardToken,
+			&order.CardLast4,
+			&order.CardBrand,
+			&order.OrderTotalUnits,
+			&order.OrderTotalNanos,
 			&order.CurrencyCode,
 			&order.ShippingTrackingID,
 			&order.CreatedAt,
+			&order.Status,
+			&order.PaymentTransactionID,
+			&order.ShippingCostUnits,
+			&order.ShippingCostNanos,
+			&order.TaxUnits,
+			&order.TaxNanos,
+			&order.ShippingQuoteUnits,
+			&order.ShippingQuoteNanos,
+			&order.ShippingQuoteCurrencyCode,
+			&order.BillingStreetAddress,
+			&order.BillingCity,
+			&order.BillingState,
+			&order.BillingCountry,
+			&order.BillingZipCode,
+			&order.GiftMessage,
+			&order.CustomerNote,
+			&order.PromoCode,
+			&order.DiscountUnits,
+			&order.DiscountNanos,
+			&order.PointsEarned,
+			&order.PointsRedeemed,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
 		}
 		orders = append(orders, order)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	return orders, nil
+}
 
-	return orders, rows.Err()
-}
-
-// masks all but last 4 digits
-func maskCreditCard(cardNumber string) string {
-	if len(cardNumber) < 4 {
-		return "****"
-	}
-	return "****-****-****-" + cardNumber[len(cardNumber)-4:]
-}
-
-// InitDB initializes database schema
-func InitDB(db *sql.DB) error {
-	createOrdersTable := `
-        CREATE TABLE IF NOT EXISTS orders (
-            order_id VARCHAR(50) PRIMARY KEY,
-            user_id VARCHAR(50) NOT NULL,
-            email VARCHAR(255),
-            street_address VARCHAR(500),
-            city VARCHAR(100),
-            state VARCHAR(100),
-            country VARCHAR(100),
-            zip_code VARCHAR(20),
-            credit_card_number VARCHAR(25),
-            credit_card_cvv VARCHAR(4),
-            credit_card_expiration_month INT,
-            credit_card_expiration_year INT,
-            order_total DECIMAL(10, 2),
-            currency_code VARCHAR(3),
-            shipping_tracking_id VARCHAR(100),
-            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-        );
-        CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);
-        CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at);
-    `
+// retrieves the items of several orders
+func getItemsOfOrders(ctx context.Context, d dialect, db *sql.DB, orderIDs []string) (items []OrderItem, err error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+	ctx, span := startStatement(ctx, "SELECT", "order_items")
+	defer func() { endStatement(span, int64(len(items)), err) }()
+	var args []any
+	rows, err := db.QueryContext(ctx, `
+        SELECT id, order_id, product_id, quantity, unit_price_units, unit_price_nanos, currency_code
+        FROM order_items WHERE `+d.inList("order_id", orderIDs, &args)+` ORDER BY id
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %w", err)
+	}
+	defer rows.Close()
 
-	createOrderItemsTable := `
-        CREATE TABLE IF NOT EXISTS order_items (
-            id SERIAL PRIMARY KEY,
-            order_id VARCHAR(50) NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
-            product_id VARCHAR(50) NOT NULL,
-            quantity INT NOT NULL
-        );
-        CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
-    `
+	for rows.Next() {
+		var item OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.UnitPriceUnits, &item.UnitPriceNanos, &item.CurrencyCode); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// attaches items to the orders they belong to, keeping their order
+func attachItems(orders []Order, items []OrderItem) {
+	byID := make(map[string]*Order, len(orders))
+	for i := range orders {
+		byID[orders[i].OrderID] = &orders[i]
+	}
+	for _, item := range items {
+		if o, ok := byID[item.OrderID]; ok {
+			o.Items = append(o.Items, item)
+		}
+	}
+}
 
-	_, err := db.ExecContext(ctx, createOrdersTable)
+// changes the status of an order, failing with errInvalidStatusTransition
+// unless its current status can change to the new one, and returns the
+// updated order. The change is replicated like new orders are.
+func (os *OrderStore) UpdateOrderStatus(ctx context.Context, orderID string, status OrderStatus) (*Order, error) {
+	var order *Order
+	err := withDBTimeout(ctx, os.timeouts.save, "UpdateOrderStatus", func(ctx context.Context) error {
+		return retryDB(ctx, "update status of order "+orderID, func() error {
+			return inTx(ctx, os.db, func(tx *sql.Tx) error {
+				var current OrderStatus
+				err := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE order_id = $1 `+os.dialect.forUpdate(), orderID).Scan(&current)
+				if err == sql.ErrNoRows {
+					return fmt.Errorf("%w: %s", errOrderNotFound, orderID)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to query order status: %w", err)
+				}
+				if err := current.checkTransition(status); err != nil {
+					return err
+				}
+				if current != status {
+					if _, err := tx.ExecContext(ctx, `UPDATE orders SET status = $2 WHERE order_id = $1`, orderID, status); err != nil {
+						return fmt.Errorf("failed to update order status: %w", err)
+					}
+				}
+				order, err = getOrder(ctx, tx, orderID)
+				if err != nil {
+					return err
+				}
+				if status == StatusCancelled && current != status {
+					if err := revertOrderPoints(ctx, os.dialect, tx, *order); err != nil {
+						return err
+					}
+				}
+				if current == status || !os.dialect.hasOutbox() {
+					return nil
+				}
+				rec := orderRecord{Order: *order, Items: order.Items}
+				if err := appendOutbox(ctx, tx, rec); err != nil {
+					return err
+				}
+				if typ, ok := statusEvents[status]; ok {
+					return appendOrderEvent(ctx, tx, typ, rec, os.webhooks)
+				}
+				return nil
+			})
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create orders table: %w", err)
+		return nil, err
 	}
+	log.WithContext(ctx).WithFields(logging.Fields{logging.OrderIDKey: orderID, "status": status}).Info("order status changed")
+	return order, nil
+}
 
-	_, err = db.ExecContext(ctx, createOrderItemsTable)
+// persists a compensation to the database
+func (os *OrderStore) RecordCompensation(ctx context.Context, c Compensation) error {
+	var (
+		units, nanos any
+		currency     any
+		errText      any
+	)
+	if c.Amount != nil {
+		units, nanos, currency = c.Amount.GetUnits(), c.Amount.GetNanos(), c.Amount.GetCurrencyCode()
+	}
+	if c.Err != nil {
+		errText = c.Err.Error()
+	}
+	// a retry records the compensation twice if the lost attempt was
+	// committed; rows with the same order, step and reference are the same
+	// compensation
+	err := withDBTimeout(ctx, os.timeouts.save, "RecordCompensation", func(ctx context.Context) error {
+		return retryDB(ctx, "record compensation", func() error {
+			_, err := os.db.ExecContext(ctx, `
+	            INSERT INTO order_compensations (
+	                order_id, user_id, step, reference, amount_units, amount_nanos, currency_code, cause, succeeded, error
+	            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	        `, c.OrderID, c.UserID, c.Step, c.Reference, units, nanos, currency, c.Cause, c.Err == nil, errText)
+			return err
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create order_items table: %w", err)
+		return fmt.Errorf("failed to insert compensation: %w", err)
 	}
-
-	log.Info("Database schema initialized successfully")
 	return nil
 }
+
+// maskedCardNumber returns the card number of o masked but for its last 4
+// digits, or "" if they are not known.
+func (o *Order) maskedCardNumber() string {
+	if o.CardLast4 == "" {
+		return ""
+	}
+	return "****-****-****-" + o.CardLast4
+}
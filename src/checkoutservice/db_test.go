@@ -0,0 +1,385 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardcrypt"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+)
+
+func TestAttachItems(t *testing.T) {
+	orders := []Order{{OrderID: "order-2"}, {OrderID: "order-1"}, {OrderID: "order-3"}}
+	attachItems(orders, []OrderItem{
+		{ID: 1, OrderID: "order-1", ProductID: "OLJCESPC7Z", Quantity: 1},
+		{ID: 2, OrderID: "order-1", ProductID: "66VCHSJNUP", Quantity: 2},
+		{ID: 3, OrderID: "order-2", ProductID: "1YMWWN1N4O", Quantity: 3},
+		{ID: 4, OrderID: "order-1", ProductID: "L9ECAV7KIM", Quantity: 1},
+		{ID: 5, OrderID: "order-4", ProductID: "2ZYFJ3GM2N", Quantity: 1},
+	})
+	want := map[string][]string{
+		"order-1": {"OLJCESPC7Z", "66VCHSJNUP", "L9ECAV7KIM"},
+		"order-2": {"1YMWWN1N4O"},
+		"order-3": nil,
+	}
+	for _, o := range orders {
+		var got []string
+		for _, item := range o.Items {
+			got = append(got, item.ProductID)
+		}
+		if !reflect.DeepEqual(got, want[o.OrderID]) {
+			t.Errorf("items of %s = %v, want %v", o.OrderID, got, want[o.OrderID])
+		}
+	}
+}
+
+func TestOrderQuerySQL(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	cursor := &OrderCursor{CreatedAt: from.AddDate(0, 0, 7), OrderID: "o-1"}
+	query, args := OrderQuery{Limit: 10, After: cursor, CreatedAfter: from, CreatedBefore: to, Statuses: []OrderStatus{StatusShipped}}.sql(dialectPostgres, "u-1")
+	for _, clause := range []string{
+		"user_id = $1",
+		"created_at >= $2",
+		"created_at < $3",
+		"status = ANY($4)",
+		"(created_at, order_id) < ($5, $6)",
+		"ORDER BY created_at DESC, order_id DESC LIMIT $7",
+	} {
+		if !strings.Contains(query, clause) {
+			t.Errorf("query %q does not contain %q", query, clause)
+		}
+	}
+	if want := []any{"u-1", from, to, pq.Array([]string{"shipped"}), cursor.CreatedAt, "o-1", 10}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	query, args = OrderQuery{}.sql(dialectPostgres, "u-1")
+	if strings.Contains(query, "LIMIT") || len(args) != 1 {
+		t.Errorf("unrestricted query %q with %v selects a subset of the orders", query, args)
+	}
+}
+
+func TestOrderSearchSQL(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	query, args := OrderSearch{
+		OrderQuery:   OrderQuery{Limit: 10, CreatedAfter: from},
+		Email:        "Someone@Example.com",
+		CurrencyCode: "USD",
+		MinTotal:     &pb.Money{Units: 10},
+		MaxTotal:     &pb.Money{Units: 99, Nanos: 990000000},
+	}.sql(dialectPostgres)
+	for _, clause := range []string{
+		"WHERE lower(email) = $1",
+		"currency_code = $2",
+		"(order_total_units, order_total_nanos) >= ($3, $4)",
+		"(order_total_units, order_total_nanos) <= ($5, $6)",
+		"created_at >= $7",
+		"ORDER BY created_at DESC, order_id DESC LIMIT $8",
+	} {
+		if !strings.Contains(query, clause) {
+			t.Errorf("query %q does not contain %q", query, clause)
+		}
+	}
+	if want := []any{"someone@example.com", "USD", int64(10), int32(0), int64(99), int32(990000000), from, 10}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+	// only the orders that were not placed are left out
+	if query, _ := (OrderSearch{}).sql(dialectPostgres); !strings.Contains(query, "WHERE status NOT IN ('pending', 'failed') ORDER BY") {
+		t.Errorf("unrestricted search %q has a WHERE clause other than the placed statuses", query)
+	}
+	if query, args := (OrderSearch{UserID: "u-1"}).sql(dialectPostgres); !strings.Contains(query, "WHERE user_id = $1") || args[0] != "u-1" {
+		t.Errorf("search of a user's orders = %q %v", query, args)
+	}
+}
+
+// TestOrderStoreItems saves orders to the PostgreSQL database at
+// TEST_DB_DSN, which should be a scratch database.
+func TestOrderStoreItems(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	store := NewOrderStore(db)
+	key, err := cardcrypt.NewLocalKey("test", make([]byte, cardcrypt.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.cards = cardcrypt.New(key)
+	userID := uuid.NewString()
+	card, err := newCardVault(store).Tokenize(ctx, &pb.CreditCardInfo{CreditCardNumber: "4432801561520454", CreditCardCvv: 672, CreditCardExpirationMonth: 1, CreditCardExpirationYear: 2030})
+	if err != nil {
+		t.Fatal(err)
+	}
+	placed := map[string][]*pb.CartItem{
+		uuid.NewString(): {{ProductId: "OLJCESPC7Z", Quantity: 1}, {ProductId: "66VCHSJNUP", Quantity: 2}, {ProductId: "L9ECAV7KIM", Quantity: 1}},
+		uuid.NewString(): {{ProductId: "1YMWWN1N4O", Quantity: 3}},
+	}
+	for orderID, items := range placed {
+		if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", State: "CA", Country: "United States", ZipCode: "94043", CardToken: card.Token, CardLast4: card.Last4, CardBrand: card.Brand, OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-" + orderID, ShippingTrackingID: "track-" + orderID, ShippingCostUnits: 2}, Items: pricedItems(orderID, items)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for orderID, items := range placed {
+		o, err := store.GetOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkItems(t, o, items)
+		if o.ShippingCostUnits != 2 {
+			t.Errorf("order %s shipped for %d USD, want 2", orderID, o.ShippingCostUnits)
+		}
+		if o.maskedCardNumber() != "****-****-****-0454" || o.CardBrand != "visa" || o.CardToken != card.Token {
+			t.Errorf("order %s was paid with %q, %q, %q; want the token, brand and masked number of its card", orderID, o.CardToken, o.CardBrand, o.maskedCardNumber())
+		}
+	}
+	var orders []Order
+	q := OrderQuery{Limit: 1}
+	for {
+		page, err := store.GetUserOrders(ctx, userID, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		orders = append(orders, page...)
+		last := page[len(page)-1]
+		q.After = &OrderCursor{CreatedAt: last.CreatedAt, OrderID: last.OrderID}
+	}
+	if len(orders) != len(placed) {
+		t.Fatalf("GetUserOrders() returned %d orders a page at a time, want %d", len(orders), len(placed))
+	}
+	if orders[0].CreatedAt.Before(orders[1].CreatedAt) {
+		t.Errorf("GetUserOrders() returned the oldest order first")
+	}
+	for i := range orders {
+		checkItems(t, &orders[i], placed[orders[i].OrderID])
+	}
+}
+
+// BenchmarkInsertOrderItems compares inserting the items of a large cart in
+// one statement with inserting them one at a time, in the PostgreSQL
+// database at TEST_DB_DSN. Each insert is rolled back.
+func BenchmarkInsertOrderItems(b *testing.B) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DB_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := migrateSchema(db); err != nil {
+		b.Fatal(err)
+	}
+
+	items := make([]OrderItem, 50)
+	for i := range items {
+		items[i] = OrderItem{ProductID: fmt.Sprintf("product-%d", i), Quantity: 1, UnitPriceUnits: 19, UnitPriceNanos: 990000000, CurrencyCode: "USD"}
+	}
+	batch := func(ctx context.Context, tx *sql.Tx, orderID string, items []OrderItem) error {
+		return insertOrderItems(ctx, dialectPostgres, tx, orderID, items)
+	}
+	loop := func(ctx context.Context, tx *sql.Tx, orderID string, items []OrderItem) error {
+		for _, item := range items {
+			if _, err := tx.ExecContext(ctx, `
+                INSERT INTO order_items (order_id, product_id, quantity, unit_price_units, unit_price_nanos, currency_code)
+                VALUES ($1, $2, $3, $4, $5, $6)
+            `, orderID, item.ProductID, item.Quantity, item.UnitPriceUnits, item.UnitPriceNanos, item.CurrencyCode); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, bm := range []struct {
+		name   string
+		insert func(context.Context, *sql.Tx, string, []OrderItem) error
+	}{
+		{"batch", batch},
+		{"loop", loop},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				tx, err := db.BeginTx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				rec := orderRecord{Order: Order{OrderID: uuid.NewString(), UserID: "bench-user", CurrencyCode: "USD", CreatedAt: time.Now(), Status: StatusPaid}}
+				if _, err := insertOrderRecord(ctx, dialectPostgres, tx, rec); err != nil {
+					b.Fatal(err)
+				}
+				if err := bm.insert(ctx, tx, rec.Order.OrderID, items); err != nil {
+					b.Fatal(err)
+				}
+				if err := tx.Rollback(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestOrderStoreStatus changes the status of an order in the PostgreSQL
+// database at TEST_DB_DSN.
+func TestOrderStoreStatus(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	store := NewOrderStore(db)
+	orderID, userID := uuid.NewString(), uuid.NewString()
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", CardLast4: "0454", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1"}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}})}); err != nil {
+		t.Fatal(err)
+	}
+	o, err := store.GetOrder(ctx, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != StatusPaid {
+		t.Fatalf("new order is %s, want %s", o.Status, StatusPaid)
+	}
+
+	for _, step := range []struct {
+		to      OrderStatus
+		wantErr error
+	}{
+		{StatusShipped, nil},
+		{StatusShipped, nil},
+		{StatusCancelled, errInvalidStatusTransition},
+		{StatusDelivered, nil},
+	} {
+		o, err := store.UpdateOrderStatus(ctx, orderID, step.to)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("UpdateOrderStatus(%s): got %v, want %v", step.to, err, step.wantErr)
+		}
+		if err == nil && (o.Status != step.to || len(o.Items) != 1) {
+			t.Errorf("UpdateOrderStatus(%s) = order %s with %d items, want it %s with its item", step.to, o.Status, len(o.Items), step.to)
+		}
+	}
+	if _, err := store.UpdateOrderStatus(ctx, uuid.NewString(), StatusShipped); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("UpdateOrderStatus of an unknown order: got %v, want errOrderNotFound", err)
+	}
+
+	delivered, err := store.GetUserOrders(ctx, userID, OrderQuery{Statuses: []OrderStatus{StatusDelivered}})
+	if err != nil || len(delivered) != 1 {
+		t.Errorf("GetUserOrders() of delivered orders = %d orders, %v, want 1", len(delivered), err)
+	}
+	paid, err := store.GetUserOrders(ctx, userID, OrderQuery{Statuses: []OrderStatus{StatusPaid}})
+	if err != nil || len(paid) != 0 {
+		t.Errorf("GetUserOrders() of paid orders = %d orders, %v, want none", len(paid), err)
+	}
+}
+
+// TestPlaceOrderV2ReplaysStoredOrders places orders through two v2 services
+// sharing the PostgreSQL database at TEST_DB_DSN, like two replicas would.
+func TestPlaceOrderV2ReplaysStoredOrders(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = NewOrderStore(db)
+	req := placeOrderRequest(uuid.NewString())
+	req.UserId = uuid.NewString()
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := newCheckoutServiceV2(cs)
+	retry, err := other.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retry.GetReplayed() || retry.GetOrder().GetOrderId() != first.GetOrder().GetOrderId() || !proto.Equal(retry.GetTotalPaid(), first.GetTotalPaid()) {
+		t.Errorf("retry on another replica = %v, want a replay of %v", retry, first)
+	}
+	if n := len(backends.payment.Charges()); n != 1 {
+		t.Errorf("card charged %d times, want once", n)
+	}
+
+	req.Email = "other@example.com"
+	if _, err := other.PlaceOrder(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder reusing a stored key for another request: got %v, want InvalidArgument", err)
+	}
+}
+
+func checkItems(t *testing.T, o *Order, want []*pb.CartItem) {
+	t.Helper()
+	if len(o.Items) != len(want) {
+		t.Fatalf("order %s has %d items, want %d", o.OrderID, len(o.Items), len(want))
+	}
+	for i, item := range o.Items {
+		if item.OrderID != o.OrderID || item.ProductID != want[i].GetProductId() || item.Quantity != want[i].GetQuantity() ||
+			item.UnitPriceUnits != 1 || item.CurrencyCode != "USD" {
+			t.Errorf("item %d of order %s = %+v, want %v", i, o.OrderID, item, want[i])
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// dbPool is the configuration of the order database's connection pool.
+type dbPool struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+}
+
+// defaultDBPool recycles connections hourly, which also picks up rotated
+// credentials.
+var defaultDBPool = dbPool{maxOpen: 25, maxIdle: 5, maxLifetime: time.Hour}
+
+// dbPoolFromEnv returns the pool configured by DB_MAX_OPEN_CONNS,
+// DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME, each
+// defaulting to defaultDBPool's.
+func dbPoolFromEnv() (dbPool, error) {
+	p := defaultDBPool
+	for _, s := range []struct {
+		key string
+		n   *int
+	}{
+		{"DB_MAX_OPEN_CONNS", &p.maxOpen},
+		{"DB_MAX_IDLE_CONNS", &p.maxIdle},
+	} {
+		if v := os.Getenv(s.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return p, fmt.Errorf("invalid %s %q", s.key, v)
+			}
+			*s.n = n
+		}
+	}
+	for _, s := range []struct {
+		key string
+		d   *time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", &p.maxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &p.maxIdleTime},
+	} {
+		if v := os.Getenv(s.key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return p, fmt.Errorf("invalid %s %q", s.key, v)
+			}
+			*s.d = d
+		}
+	}
+	if p.maxIdle > p.maxOpen {
+		return p, fmt.Errorf("DB_MAX_IDLE_CONNS %d is more than DB_MAX_OPEN_CONNS %d", p.maxIdle, p.maxOpen)
+	}
+	return p, nil
+}
+
+func (p dbPool) apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.maxOpen)
+	db.SetMaxIdleConns(p.maxIdle)
+	db.SetConnMaxLifetime(p.maxLifetime)
+	db.SetConnMaxIdleTime(p.maxIdleTime)
+}
+
+func (p dbPool) String() string {
+	return fmt.Sprintf("max %d open, %d idle, lifetime %s, idle time %s", p.maxOpen, p.maxIdle, p.maxLifetime, p.maxIdleTime)
+}
+
+// registerDBMetrics exports the statistics of db's connection pool each time
+// metrics are collected.
+func registerDBMetrics(db *sql.DB) error {
+	meter := otel.Meter("checkoutservice")
+	conns, err := meter.Int64ObservableGauge(
+		"checkout.db.connections",
+		metric.WithDescription("Connections to the order database, by state."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	maxConns, err := meter.Int64ObservableGauge(
+		"checkout.db.connections.max",
+		metric.WithDescription("Maximum number of open connections to the order database."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	waits, err := meter.Int64ObservableCounter(
+		"checkout.db.connection_waits",
+		metric.WithDescription("Times a query waited for a free connection to the order database."),
+		metric.WithUnit("{wait}"),
+	)
+	if err != nil {
+		return err
+	}
+	waitTime, err := meter.Float64ObservableCounter(
+		"checkout.db.connection_wait_time",
+		metric.WithDescription("Total time queries waited for a free connection to the order database."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+	closed, err := meter.Int64ObservableCounter(
+		"checkout.db.connections.closed",
+		metric.WithDescription("Connections to the order database closed by the pool, by reason."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		st := db.Stats()
+		o.ObserveInt64(conns, int64(st.InUse), metric.WithAttributes(attribute.String("state", "in_use")))
+		o.ObserveInt64(conns, int64(st.Idle), metric.WithAttributes(attribute.String("state", "idle")))
+		o.ObserveInt64(maxConns, int64(st.MaxOpenConnections))
+		o.ObserveInt64(waits, st.WaitCount)
+		o.ObserveFloat64(waitTime, st.WaitDuration.Seconds())
+		o.ObserveInt64(closed, st.MaxIdleClosed, metric.WithAttributes(attribute.String("reason", "max_idle")))
+		o.ObserveInt64(closed, st.MaxIdleTimeClosed, metric.WithAttributes(attribute.String("reason", "max_idle_time")))
+		o.ObserveInt64(closed, st.MaxLifetimeClosed, metric.WithAttributes(attribute.String("reason", "max_lifetime")))
+		return nil
+	}, conns, maxConns, waits, waitTime, closed)
+	return err
+}
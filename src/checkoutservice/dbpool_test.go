@@ -0,0 +1,53 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDBPoolFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    dbPool
+		wantErr bool
+	}{
+		{"defaults", nil, defaultDBPool, false},
+		{"all set", map[string]string{
+			"DB_MAX_OPEN_CONNS":     "50",
+			"DB_MAX_IDLE_CONNS":     "10",
+			"DB_CONN_MAX_LIFETIME":  "30m",
+			"DB_CONN_MAX_IDLE_TIME": "5m",
+		}, dbPool{maxOpen: 50, maxIdle: 10, maxLifetime: 30 * time.Minute, maxIdleTime: 5 * time.Minute}, false},
+		{"more idle than open", map[string]string{"DB_MAX_OPEN_CONNS": "4"}, dbPool{}, true},
+		{"zero open", map[string]string{"DB_MAX_OPEN_CONNS": "0"}, dbPool{}, true},
+		{"invalid lifetime", map[string]string{"DB_CONN_MAX_LIFETIME": "1 hour"}, dbPool{}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := dbPoolFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dbPoolFromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("dbPoolFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// Orders can be read from a read-only replica of the order database, such
+// as a PostgreSQL streaming replica, at DB_READ_DSN. GetOrder and
+// GetUserOrders are served from it, and everything else, including the
+// idempotency keys that must not be stale, from the primary.
+//
+// The primary stands in while the replica is unreachable: a read that fails
+// with a transient error is run again on the primary, and the replica is
+// left alone for replicaRetryInterval. An order that the replica does not
+// have yet, having just been placed, is looked up on the primary too.
+
+// replicaRetryInterval is how long reads go to the primary after the
+// replica failed.
+const replicaRetryInterval = 30 * time.Second
+
+var replicaFallbacks, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.db.replica_fallbacks",
+	metric.WithDescription("Order reads served by the primary instead of the read replica, by reason."),
+	metric.WithUnit("{read}"),
+)
+
+// readReplica is a read-only replica of the order database.
+type readReplica struct {
+	db  *sql.DB
+	now func() time.Time
+
+	mu          sync.Mutex
+	unavailable time.Time // until when reads go to the primary
+}
+
+// newReadReplicaFromEnv opens the read replica at DB_READ_DSN, or its
+// secret DB_READ_DSN_SECRET, or returns nil if neither is set. Its pool is
+// sized like the primary's.
+func newReadReplicaFromEnv(secretStore *secrets.Manager) (*readReplica, error) {
+	if os.Getenv("DB_READ_DSN") == "" && os.Getenv("DB_READ_DSN_SECRET") == "" {
+		return nil, nil
+	}
+	pool, err := dbPoolFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(&secretDSNConnector{secrets: secretStore, key: "DB_READ_DSN"})
+	pool.apply(db)
+	return &readReplica{db: db, now: time.Now}, nil
+}
+
+// close closes the connections to the replica.
+func (r *readReplica) close(context.Context) error {
+	return r.db.Close()
+}
+
+// available reports whether reads can go to the replica.
+func (r *readReplica) available() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.now().Before(r.unavailable)
+}
+
+// failed sends reads to the primary for replicaRetryInterval.
+func (r *readReplica) failed(ctx context.Context, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.now().Before(r.unavailable) {
+		return
+	}
+	r.unavailable = r.now().Add(replicaRetryInterval)
+	log.WithContext(ctx).Warnf("read replica failed, reading from the primary for %v: %v", replicaRetryInterval, err)
+}
+
+// read runs fn on the read replica if there is one and it is available, and
+// on the primary if there is not, if the replica fails with a transient
+// error, or if it does not have the order yet.
+func (os *OrderStore) read(ctx context.Context, fn func(db *sql.DB) error) error {
+	r := os.replica
+	if r == nil || !r.available() {
+		return fn(os.db)
+	}
+	err := fn(r.db)
+	var reason string
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errOrderNotFound):
+		reason = "not_found"
+	case isStaleStmtError(err):
+		// the replica is fine, and the retry prepares the statement again
+		return err
+	case isTransientDBError(err):
+		reason = "unavailable"
+		r.failed(ctx, err)
+	default:
+		return err
+	}
+	replicaFallbacks.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	trace.SpanFromContext(ctx).AddEvent("replica fallback", trace.WithAttributes(attribute.String("reason", reason)))
+	return fn(os.db)
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// unreachableDB is a database whose connections are all refused.
+type unreachableDB struct{}
+
+func (unreachableDB) Connect(context.Context) (driver.Conn, error) {
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+}
+
+func (unreachableDB) Driver() driver.Driver { return unreachableDB{} }
+
+func (db unreachableDB) Open(string) (driver.Conn, error) {
+	return db.Connect(context.Background())
+}
+
+func TestOrderStoreReadReplica(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	clock := &fakeClock{t: time.Now()}
+	replica := &readReplica{db: newSQLiteStore(t).db, now: clock.now}
+	store.replica = replica
+
+	orderID, userID := uuid.NewString(), uuid.NewString()
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1"}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}})}); err != nil {
+		t.Fatal(err)
+	}
+	// the empty replica has not caught up with the primary
+	if orders, err := store.GetUserOrders(ctx, userID, OrderQuery{}); err != nil || len(orders) != 0 {
+		t.Errorf("GetUserOrders() = %d orders, %v, want none from the replica", len(orders), err)
+	}
+	if o, err := store.GetOrder(ctx, orderID); err != nil || o.OrderID != orderID {
+		t.Errorf("GetOrder() of an order the replica lacks = %v, %v, want it from the primary", o, err)
+	}
+	if !replica.available() {
+		t.Error("replica unavailable after lacking an order")
+	}
+
+	unreachable := sql.OpenDB(unreachableDB{})
+	t.Cleanup(func() { unreachable.Close() })
+	replica.db = unreachable
+	if orders, err := store.GetUserOrders(ctx, userID, OrderQuery{}); err != nil || len(orders) != 1 {
+		t.Errorf("GetUserOrders() with the replica down = %d orders, %v, want 1 from the primary", len(orders), err)
+	}
+	if replica.available() {
+		t.Error("replica available right after it failed")
+	}
+	clock.t = clock.t.Add(replicaRetryInterval)
+	if !replica.available() {
+		t.Errorf("replica unavailable %v after it failed", replicaRetryInterval)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// dbAttempts is how many times an OrderStore operation is tried when
+	// it fails with a transient error.
+	dbAttempts = 4
+	// dbMinBackoff and dbMaxBackoff bound the delay between attempts, which
+	// doubles after each failure and is jittered.
+	dbMinBackoff = 50 * time.Millisecond
+	dbMaxBackoff = time.Second
+)
+
+// retryDB calls fn until it succeeds, fails with an error that retrying
+// cannot fix, or was called dbAttempts times. It does not wait past the
+// deadline of ctx, returning the last error instead.
+func retryDB(ctx context.Context, op string, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !isTransientDBError(err) || attempt == dbAttempts {
+			return err
+		}
+		delay := dbBackoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		log.WithContext(ctx).Warnf("failed to %s (attempt %d of %d), retrying in %v: %v", op, attempt, dbAttempts, delay.Round(time.Millisecond), err)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt), attribute.String("error", err.Error())))
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// commitError is the error of a commit that was sent but failed, which may
+// have been applied all the same. retryDB does not retry it.
+type commitError struct{ err error }
+
+func (e *commitError) Error() string {
+	return "failed to commit transaction: " + e.err.Error()
+}
+
+func (e *commitError) Unwrap() error { return e.err }
+
+// inTxOnce is inTx for changes that must not be applied twice: its commit
+// errors are commitErrors, so that retryDB does not send the changes again
+// if the commit that failed was applied.
+func inTxOnce(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return &commitError{err}
+	}
+	return nil
+}
+
+// dbBackoff returns the delay before the next attempt after the given number
+// of failed attempts: dbMinBackoff doubled for each attempt and capped at
+// dbMaxBackoff, of which a random half is skipped so that the pods that
+// failed together do not retry together.
+func dbBackoff(attempts int) time.Duration {
+	d := dbMinBackoff << (attempts - 1)
+	if d <= 0 || d > dbMaxBackoff {
+		d = dbMaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// isTransientDBError reports whether err may not recur if the operation is
+// tried again: a serialization failure or deadlock, a dropped or refused
+// connection, a server that is restarting, a prepared statement that the
+// server dropped, or with SQLite a database that another connection has
+// locked. commitErrors are not, whatever their cause.
+func isTransientDBError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if commitErr := (*commitError)(nil); errors.As(err, &commitErr) {
+		return false
+	}
+	if isStaleStmtError(err) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// connection_exception and its subclasses
+		return pqErr.Code.Class() == "08"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_LOCK_DEADLOCK, ER_LOCK_WAIT_TIMEOUT
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// the primary result code, without the extended code in its high bits
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestIsTransientDBError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{fmt.Errorf("failed to commit transaction: %w", &pq.Error{Code: "40P01"}), true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "57P01"}, true},
+		{&pq.Error{Code: "23505"}, false},
+		{&pq.Error{Code: "42P01"}, false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{&pq.Error{Code: "26000"}, true},
+		{&mysql.MySQLError{Number: 1615}, true},
+		{driver.ErrBadConn, true},
+		{io.ErrUnexpectedEOF, true},
+		{sql.ErrNoRows, false},
+		{errOrderNotFound, false},
+		{context.DeadlineExceeded, false},
+		{&commitError{driver.ErrBadConn}, false},
+		{fmt.Errorf("redeem: %w", &commitError{&pq.Error{Code: "08006"}}), false},
+	} {
+		if got := isTransientDBError(tt.err); got != tt.want {
+			t.Errorf("isTransientDBError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDB(t *testing.T) {
+	serialization := &pq.Error{Code: "40001"}
+	for _, tt := range []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds after transient errors", []error{serialization, serialization, nil}, 3, nil},
+		{"gives up", []error{serialization, serialization, serialization, serialization, nil}, dbAttempts, serialization},
+		{"permanent error", []error{errOrderNotFound, nil}, 1, errOrderNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryDB(context.Background(), "test", func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) || calls != tt.wantCalls {
+				t.Errorf("retryDB() = %v after %d calls, want %v after %d", err, calls, tt.wantErr, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryDBDeadline(t *testing.T) {
+	// the deadline is too close for the first backoff
+	ctx, cancel := context.WithTimeout(context.Background(), dbMinBackoff/10)
+	defer cancel()
+	calls := 0
+	err := retryDB(ctx, "test", func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	if !errors.Is(err, driver.ErrBadConn) || calls != 1 {
+		t.Errorf("retryDB() = %v after %d calls, want the first error", err, calls)
+	}
+}
+
+func TestDBBackoff(t *testing.T) {
+	for attempts, max := range map[int]time.Duration{
+		1:  dbMinBackoff,
+		2:  2 * dbMinBackoff,
+		3:  4 * dbMinBackoff,
+		10: dbMaxBackoff,
+		80: dbMaxBackoff,
+	} {
+		for i := 0; i < 100; i++ {
+			if d := dbBackoff(attempts); d < max/2 || d > max {
+				t.Fatalf("dbBackoff(%d) = %v, want between %v and %v", attempts, d, max/2, max)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// dbTimeouts bound the time database operations take, within the deadline
+// of the request they are made for, so that a slow query leaves the rest of
+// the deadline to what follows it, such as queuing an order that could not
+// be saved.
+type dbTimeouts struct {
+	// save bounds writes to the order store, read its reads, each including
+	// their retries; zero leaves only the request's deadline.
+	save time.Duration
+	read time.Duration
+	// migrate bounds the schema migration at startup, and connect the first
+	// connection to the database.
+	migrate time.Duration
+	connect time.Duration
+}
+
+var defaultDBTimeouts = dbTimeouts{save: 5 * time.Second, read: 2 * time.Second, migrate: time.Minute, connect: 10 * time.Second}
+
+// errDBTimeout is returned by operations that took longer than their timeout.
+var errDBTimeout = errors.New("database operation timed out")
+
+var dbTimeoutsExceeded, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.db.timeouts",
+	metric.WithDescription("Order store operations that took longer than their timeout, by operation."),
+	metric.WithUnit("{operation}"),
+)
+
+// dbTimeoutsFromEnv returns the timeouts configured by DB_SAVE_TIMEOUT,
+// DB_READ_TIMEOUT, DB_MIGRATE_TIMEOUT and DB_CONNECT_TIMEOUT, each
+// defaulting to defaultDBTimeouts'.
+func dbTimeoutsFromEnv() (dbTimeouts, error) {
+	t := defaultDBTimeouts
+	for _, s := range []struct {
+		key string
+		d   *time.Duration
+		min time.Duration
+	}{
+		{"DB_SAVE_TIMEOUT", &t.save, 0},
+		{"DB_READ_TIMEOUT", &t.read, 0},
+		{"DB_MIGRATE_TIMEOUT", &t.migrate, time.Second},
+		{"DB_CONNECT_TIMEOUT", &t.connect, time.Second},
+	} {
+		if v := os.Getenv(s.key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < s.min {
+				return t, fmt.Errorf("invalid %s %q", s.key, v)
+			}
+			*s.d = d
+		}
+	}
+	return t, nil
+}
+
+func (t dbTimeouts) String() string {
+	return fmt.Sprintf("save %s, read %s, migrate %s, connect %s", t.save, t.read, t.migrate, t.connect)
+}
+
+// withDBTimeout runs the operation op with ctx bounded by d, unless d is
+// zero, and fails with errDBTimeout if it took longer while ctx had time
+// left.
+func withDBTimeout(ctx context.Context, d time.Duration, op string, fn func(context.Context) error) error {
+	if d <= 0 {
+		return fn(ctx)
+	}
+	bounded, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	err := fn(bounded)
+	if err != nil && errors.Is(bounded.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		dbTimeoutsExceeded.Add(ctx, 1, metric.WithAttributes(attribute.String("operation", op)))
+		return fmt.Errorf("%w: %s took longer than %v: %w", errDBTimeout, op, d, err)
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDBTimeoutsFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    dbTimeouts
+		wantErr bool
+	}{
+		{"defaults", nil, defaultDBTimeouts, false},
+		{"all set", map[string]string{
+			"DB_SAVE_TIMEOUT":    "3s",
+			"DB_READ_TIMEOUT":    "500ms",
+			"DB_MIGRATE_TIMEOUT": "10m",
+			"DB_CONNECT_TIMEOUT": "5s",
+		}, dbTimeouts{save: 3 * time.Second, read: 500 * time.Millisecond, migrate: 10 * time.Minute, connect: 5 * time.Second}, false},
+		{"unbounded reads", map[string]string{"DB_READ_TIMEOUT": "0s"},
+			dbTimeouts{save: defaultDBTimeouts.save, migrate: defaultDBTimeouts.migrate, connect: defaultDBTimeouts.connect}, false},
+		{"zero migrate", map[string]string{"DB_MIGRATE_TIMEOUT": "0s"}, dbTimeouts{}, true},
+		{"negative save", map[string]string{"DB_SAVE_TIMEOUT": "-1s"}, dbTimeouts{}, true},
+		{"invalid read", map[string]string{"DB_READ_TIMEOUT": "2 seconds"}, dbTimeouts{}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_SAVE_TIMEOUT", "DB_READ_TIMEOUT", "DB_MIGRATE_TIMEOUT", "DB_CONNECT_TIMEOUT"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := dbTimeoutsFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dbTimeoutsFromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("dbTimeoutsFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDBTimeout(t *testing.T) {
+	wait := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := withDBTimeout(context.Background(), time.Millisecond, "Wait", wait); !errors.Is(err, errDBTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want errDBTimeout", err)
+	}
+
+	// the request's deadline is not the operation's timeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := withDBTimeout(ctx, time.Minute, "Wait", wait); errors.Is(err, errDBTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("request deadline: got %v, want context.DeadlineExceeded alone", err)
+	}
+
+	err := withDBTimeout(context.Background(), 0, "Check", func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("zero timeout set a deadline")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestOrderStoreTimeouts reads an order with a read timeout too short for
+// the query, and again without one.
+func TestOrderStoreTimeouts(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	store.timeouts.read = time.Nanosecond
+	orderID := uuid.NewString()
+	if err := saveBenchOrder(ctx, store, orderID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetOrder(ctx, orderID); !errors.Is(err, errDBTimeout) {
+		t.Errorf("GetOrder: got %v, want errDBTimeout", err)
+	}
+	store.timeouts.read = 0
+	if _, err := store.GetOrder(ctx, orderID); err != nil {
+		t.Errorf("GetOrder without a timeout: %v", err)
+	}
+}
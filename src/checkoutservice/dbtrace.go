@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OrderStore operations are traced with a span each, named after the
+// operation, whose children are the spans of the statements it ran, named
+// after the statement and table, e.g. "INSERT order_items". Statement spans
+// carry the number of rows they returned or wrote; spans of operations that
+// failed record the error, except for orders that do not exist.
+
+// rowsKey is the number of rows a statement returned or wrote.
+const rowsKey = attribute.Key("db.response.rows")
+
+var tracer = otel.Tracer("checkoutservice")
+
+// startStoreSpan starts the span of the OrderStore operation op.
+func startStoreSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "OrderStore."+op, trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL))
+}
+
+// startStatement starts the span of a statement running operation (such as
+// SELECT) on table.
+func startStatement(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation+" "+table, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation), semconv.DBCollectionName(table)))
+}
+
+// endStatement ends the span of a statement that returned or wrote rows,
+// or that failed with err.
+func endStatement(span trace.Span, rows int64, err error) {
+	switch {
+	case err == nil:
+		span.SetAttributes(rowsKey.Int64(rows))
+	case errors.Is(err, sql.ErrNoRows):
+		span.SetAttributes(rowsKey.Int64(0))
+	}
+	endSpan(span, err)
+}
+
+// endSpan ends span, recording err unless it is only that no row matched.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, errOrderNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
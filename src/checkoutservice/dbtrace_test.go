@@ -0,0 +1,75 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStoreSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, op := startStoreSpan(context.Background(), "GetOrder")
+	_, found := startStatement(ctx, "SELECT", "order_items")
+	endStatement(found, 3, nil)
+	_, missing := startStatement(ctx, "SELECT", "orders")
+	endStatement(missing, 1, fmt.Errorf("%w: order-1", errOrderNotFound))
+	endSpan(op, errors.New("connection refused"))
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("%d spans ended, want 3", len(spans))
+	}
+	for _, tt := range []struct {
+		name string
+		rows int64
+		code codes.Code
+	}{
+		{"SELECT order_items", 3, codes.Unset},
+		{"SELECT orders", -1, codes.Unset},
+		{"OrderStore.GetOrder", -1, codes.Error},
+	} {
+		var span sdktrace.ReadOnlySpan
+		for _, s := range spans {
+			if s.Name() == tt.name {
+				span = s
+			}
+		}
+		if span == nil {
+			t.Errorf("no span %q", tt.name)
+			continue
+		}
+		if span.Status().Code != tt.code {
+			t.Errorf("status of %q = %v, want %v", tt.name, span.Status().Code, tt.code)
+		}
+		attrs := attribute.NewSet(span.Attributes()...)
+		if rows, ok := attrs.Value(rowsKey); tt.rows >= 0 && (!ok || rows.AsInt64() != tt.rows) {
+			t.Errorf("rows of %q = %v, want %d", tt.name, rows.AsInt64(), tt.rows)
+		}
+		if tt.name != "OrderStore.GetOrder" && span.Parent().SpanID() != op.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of the operation's", tt.name)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// The order store runs against PostgreSQL, MySQL or SQLite, as selected by
+// DB_DRIVER. Its statements are written for PostgreSQL, with $N
+// placeholders, which SQLite understands too and which are rewritten to ?
+// for MySQL by rebindConnector; dialect covers the rest of the differences.
+//
+// Only PostgreSQL has versioned migrations, replication and order events.
+// The other databases get the current schema when they have none, from
+// schemas/, and later schema changes must be applied to them by hand.
+
+// dialect is the SQL dialect of the order database, named after its
+// DB_DRIVER.
+type dialect string
+
+const (
+	dialectPostgres dialect = "postgres"
+	dialectMySQL    dialect = "mysql"
+	dialectSQLite   dialect = "sqlite"
+)
+
+// defaultSQLiteDSN keeps the orders of a local demo in orders.db, waiting
+// for locks rather than failing, and taking the write lock when a
+// transaction begins so that transactions that read before writing do not
+// deadlock.
+const defaultSQLiteDSN = "file:orders.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+
+// dialectFromEnv returns the dialect of DB_DRIVER, PostgreSQL by default.
+func dialectFromEnv() (dialect, error) {
+	switch d := dialect(os.Getenv("DB_DRIVER")); d {
+	case "":
+		return dialectPostgres, nil
+	case dialectPostgres, dialectMySQL, dialectSQLite:
+		return d, nil
+	default:
+		return "", fmt.Errorf("invalid DB_DRIVER %q", d)
+	}
+}
+
+// hasOutbox reports whether the database has the order_outbox and
+// order_events tables, that is whether its orders can be replicated and
+// published.
+func (d dialect) hasOutbox() bool {
+	return d == dialectPostgres
+}
+
+// ignoreDuplicate returns the clause ending an INSERT that inserts nothing
+// if a row with the same key exists.
+func (d dialect) ignoreDuplicate(key string) string {
+	if d == dialectMySQL {
+		return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", key, key)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", key)
+}
+
+// inList appends values to args and returns the condition that column is
+// one of them.
+func (d dialect) inList(column string, values []string, args *[]any) string {
+	if d == dialectPostgres {
+		*args = append(*args, pq.Array(values))
+		return fmt.Sprintf("%s = ANY($%d)", column, len(*args))
+	}
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		*args = append(*args, v)
+		placeholders[i] = "$" + strconv.Itoa(len(*args))
+	}
+	return fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", "))
+}
+
+// forUpdate returns the clause locking the rows a SELECT reads until the
+// transaction ends. SQLite has none, since its transactions lock the whole
+// database.
+func (d dialect) forUpdate() string {
+	if d == dialectSQLite {
+		return ""
+	}
+	return "FOR UPDATE"
+}
+
+// openDialectDB opens the MySQL or SQLite database at DB_DSN, and returns
+// its description for the logs.
+func openDialectDB(ctx context.Context, d dialect, c *dbConnector) (*sql.DB, string, error) {
+	dsn, err := c.secrets.Value(ctx, "DB_DSN")
+	if err != nil {
+		return nil, "", err
+	}
+	switch d {
+	case dialectMySQL:
+		if dsn == "" {
+			return nil, "", errors.New("DB_DSN must be set for MySQL")
+		}
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid DB_DSN: %w", err)
+		}
+		// orders are read into time.Time
+		cfg.ParseTime = true
+		connector, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return sql.OpenDB(rebindConnector{connector}), fmt.Sprintf("MySQL database %s at %s", cfg.DBName, cfg.Addr), nil
+	case dialectSQLite:
+		if dsn == "" {
+			dsn = defaultSQLiteDSN
+		}
+		db, err := sql.Open("sqlite", dsn)
+		name, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+		return db, "SQLite database " + name, err
+	}
+	return nil, "", fmt.Errorf("no connector for %s", d)
+}
+
+//go:embed schemas/*.sql
+var schemaFiles embed.FS
+
+// createSchema creates the tables of the order store in a MySQL or SQLite
+// database that lacks them.
+func createSchema(ctx context.Context, db *sql.DB, d dialect) error {
+	b, err := schemaFiles.ReadFile("schemas/" + string(d) + ".sql")
+	if err != nil {
+		return err
+	}
+	// neither driver runs several statements at once by default
+	for _, stmt := range strings.Split(string(b), ";\n") {
+		if strings.TrimSpace(stripComments(stmt)) == "" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create the %s schema: %w", d, err)
+		}
+	}
+	return nil
+}
+
+// stripComments removes the -- comments of SQL.
+func stripComments(sql string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// rebind rewrites the $N placeholders of query to ?, returning for each ?
+// the ordinal of the argument it stands for, since a $N can appear more than
+// once or out of order.
+func rebind(query string) (string, []int) {
+	var (
+		b        strings.Builder
+		ordinals []int
+		quoted   bool
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if c != '$' || quoted {
+			b.WriteByte(c)
+			continue
+		}
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(c)
+			continue
+		}
+		n, _ := strconv.Atoi(query[i+1 : j])
+		ordinals = append(ordinals, n)
+		b.WriteByte('?')
+		i = j - 1
+	}
+	return b.String(), ordinals
+}
+
+// bindArgs returns the arguments of the ? placeholders that stand for the
+// given ordinals of args.
+func bindArgs(args []driver.NamedValue, ordinals []int) ([]driver.NamedValue, error) {
+	if len(ordinals) == 0 {
+		return args, nil
+	}
+	bound := make([]driver.NamedValue, len(ordinals))
+	for i, n := range ordinals {
+		if n < 1 || n > len(args) {
+			return nil, fmt.Errorf("placeholder $%d has no argument", n)
+		}
+		bound[i] = driver.NamedValue{Ordinal: i + 1, Value: args[n-1].Value}
+	}
+	return bound, nil
+}
+
+// rebindConnector opens MySQL connections that accept $N placeholders.
+type rebindConnector struct {
+	driver.Connector
+}
+
+// mysqlConn is the set of interfaces that MySQL connections implement.
+type mysqlConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.SessionResetter
+	driver.Validator
+	driver.NamedValueChecker
+}
+
+func (c rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mc, ok := conn.(mysqlConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected MySQL connection %T", conn)
+	}
+	return rebindConn{mc}, nil
+}
+
+type rebindConn struct {
+	mysqlConn
+}
+
+func (c rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query, ordinals := rebind(query)
+	stmt, err := c.mysqlConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &rebindStmt{stmt: stmt, ordinals: ordinals}, nil
+}
+
+func (c rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, ordinals := rebind(query)
+	args, err := bindArgs(args, ordinals)
+	if err != nil {
+		return nil, err
+	}
+	return c.mysqlConn.ExecContext(ctx, query, args)
+}
+
+func (c rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, ordinals := rebind(query)
+	args, err := bindArgs(args, ordinals)
+	if err != nil {
+		return nil, err
+	}
+	return c.mysqlConn.QueryContext(ctx, query, args)
+}
+
+// rebindStmt is a prepared statement whose ? placeholders stand for the
+// given ordinals of its arguments.
+type rebindStmt struct {
+	stmt     driver.Stmt
+	ordinals []int
+}
+
+func (s *rebindStmt) Close() error { return s.stmt.Close() }
+
+// NumInput is unknown, since placeholders can repeat.
+func (s *rebindStmt) NumInput() int { return -1 }
+
+func (s *rebindStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *rebindStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *rebindStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	args, err := bindArgs(args, s.ordinals)
+	if err != nil {
+		return nil, err
+	}
+	return s.stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func (s *rebindStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	args, err := bindArgs(args, s.ordinals)
+	if err != nil {
+		return nil, err
+	}
+	return s.stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
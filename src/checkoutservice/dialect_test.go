@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestRebind(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		want     string
+		ordinals []int
+	}{
+		{"SELECT 1", "SELECT 1", nil},
+		{"SELECT a FROM t WHERE b = $1 AND c < $2", "SELECT a FROM t WHERE b = ? AND c < ?", []int{1, 2}},
+		{"UPDATE t SET a = IF(b < $3, $1, a), b = IF(b < $3, $2, b)", "UPDATE t SET a = IF(b < ?, ?, a), b = IF(b < ?, ?, b)", []int{3, 1, 3, 2}},
+		{"SELECT '$1', $10 FROM t", "SELECT '$1', ? FROM t", []int{10}},
+		{"SELECT $ FROM t", "SELECT $ FROM t", nil},
+	} {
+		got, ordinals := rebind(tc.query)
+		if got != tc.want || !reflect.DeepEqual(ordinals, tc.ordinals) {
+			t.Errorf("rebind(%q) = %q, %v, want %q, %v", tc.query, got, ordinals, tc.want, tc.ordinals)
+		}
+	}
+
+	args := []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}}
+	bound, err := bindArgs(args, []int{2, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []any
+	for i, v := range bound {
+		if v.Ordinal != i+1 {
+			t.Errorf("argument %d has ordinal %d", i+1, v.Ordinal)
+		}
+		values = append(values, v.Value)
+	}
+	if want := []any{"b", "a", "b"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("bindArgs() = %v, want %v", values, want)
+	}
+	if _, err := bindArgs(args, []int{3}); err == nil {
+		t.Error("bindArgs() of a missing argument succeeded")
+	}
+}
+
+func TestDialectInList(t *testing.T) {
+	args := []any{"u-1"}
+	if got, want := dialectPostgres.inList("status", []string{"paid", "shipped"}, &args), "status = ANY($2)"; got != want || len(args) != 2 {
+		t.Errorf("postgres inList() = %q with %d args, want %q with 2", got, len(args), want)
+	}
+	args = []any{"u-1"}
+	if got, want := dialectMySQL.inList("status", []string{"paid", "shipped"}, &args), "status IN ($2, $3)"; got != want || len(args) != 3 {
+		t.Errorf("mysql inList() = %q with %d args, want %q with 3", got, len(args), want)
+	}
+	query, args := OrderQuery{Statuses: []OrderStatus{StatusPaid, StatusShipped}}.sql(dialectSQLite, "u-1")
+	if !strings.Contains(query, "status IN ($2, $3)") || len(args) != 3 {
+		t.Errorf("sqlite OrderQuery.sql() = %q with %d args, want status IN ($2, $3)", query, len(args))
+	}
+}
+
+// newSQLiteStore returns an order store in a new SQLite database.
+func newSQLiteStore(t testing.TB) *OrderStore {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "orders.db") + strings.TrimPrefix(defaultSQLiteDSN, "file:orders.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// creating the schema again leaves it as it is
+	for i := 0; i < 2; i++ {
+		if err := createSchema(context.Background(), db, dialectSQLite); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := NewOrderStore(db)
+	store.dialect = dialectSQLite
+	return store
+}
+
+// TestOrderStoreSQLite runs the order store against a SQLite database.
+func TestOrderStoreSQLite(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	userID := uuid.NewString()
+	save := func(orderID string, idem *IdempotencyRecord) error {
+		return store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", City: "Mountain View", BillingCity: "Sunnyvale", BillingZipCode: "94086", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", ShippingCostUnits: 7, ShippingQuoteUnits: 6, ShippingQuoteNanos: 990000000, ShippingQuoteCurrencyCode: "USD", GiftMessage: "Happy birthday!", CustomerNote: "Ring twice.", PromoCode: "SPRING", DiscountUnits: 2, DiscountNanos: 500000000}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}, {ProductId: "66VCHSJNUP", Quantity: 1}}), Idempotency: idem})
+	}
+	first, second := uuid.NewString(), uuid.NewString()
+	idem := &IdempotencyRecord{Key: "key-1", Fingerprint: []byte("fingerprint"), Response: []byte("response")}
+	if err := save(first, idem); err != nil {
+		t.Fatal(err)
+	}
+	if err := save(second, nil); err != nil {
+		t.Fatal(err)
+	}
+	var exists *orderExistsError
+	if err := save(first, nil); !errors.As(err, &exists) || !exists.Redelivered {
+		t.Errorf("saving an order twice: got %v, want a redelivered orderExistsError", err)
+	}
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: first, UserID: uuid.NewString(), CurrencyCode: "USD", PaymentTransactionID: "txn-2"}}); !errors.As(err, &exists) || exists.Redelivered {
+		t.Errorf("saving another order with a saved ID: got %v, want an orderExistsError that is not redelivered", err)
+	}
+
+	o, err := store.GetOrder(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.City != "Mountain View" || o.OrderTotalUnits != 10 || o.PaymentTransactionID != "txn-1" || len(o.Items) != 2 || o.Items[0].ProductID != "OLJCESPC7Z" || o.Items[0].Quantity != 2 {
+		t.Errorf("GetOrder() = %+v, want the saved order", o)
+	}
+	if o.ShippingCostUnits != 7 || o.shippingQuote().GetNanos() != 990000000 || o.ShippingQuoteCurrencyCode != "USD" || o.Items[1].UnitPriceUnits != 1 || o.Items[1].CurrencyCode != "USD" {
+		t.Errorf("GetOrder() = %+v, want the shipping cost and quote and item prices", o)
+	}
+	if o.BillingCity != "Sunnyvale" || o.BillingZipCode != "94086" {
+		t.Errorf("GetOrder() = %+v, want the billing address", o)
+	}
+	if o.GiftMessage != "Happy birthday!" || o.CustomerNote != "Ring twice." {
+		t.Errorf("GetOrder() = %+v, want the gift message and note", o)
+	}
+	if o.PromoCode != "SPRING" || o.discount().GetUnits() != 2 || o.discount().GetNanos() != 500000000 {
+		t.Errorf("GetOrder() = %+v, want the promo code and discount", o)
+	}
+	if _, err := store.GetOrder(ctx, uuid.NewString()); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("GetOrder() of an unknown order: got %v, want errOrderNotFound", err)
+	}
+
+	rec, err := store.GetIdempotencyRecord(ctx, userID, "key-1")
+	if err != nil || rec == nil || string(rec.Response) != "response" {
+		t.Errorf("GetIdempotencyRecord() = %+v, %v, want the saved record", rec, err)
+	}
+
+	page, err := store.GetUserOrders(ctx, userID, OrderQuery{Limit: 1})
+	if err != nil || len(page) != 1 {
+		t.Fatalf("GetUserOrders() = %d orders, %v, want 1", len(page), err)
+	}
+	last := page[0]
+	rest, err := store.GetUserOrders(ctx, userID, OrderQuery{After: &OrderCursor{CreatedAt: last.CreatedAt, OrderID: last.OrderID}})
+	if err != nil || len(rest) != 1 || rest[0].OrderID == last.OrderID || len(rest[0].Items) != 2 {
+		t.Errorf("GetUserOrders() after %s = %+v, %v, want the other order", last.OrderID, rest, err)
+	}
+
+	found, err := store.SearchOrders(ctx, OrderSearch{Email: "SOMEONE@example.com", CurrencyCode: "USD", MinTotal: &pb.Money{Units: 10}, MaxTotal: &pb.Money{Units: 10}})
+	if err != nil || len(found) != 2 {
+		t.Errorf("SearchOrders() = %d orders, %v, want 2", len(found), err)
+	}
+	if found, err := store.SearchOrders(ctx, OrderSearch{CurrencyCode: "USD", MinTotal: &pb.Money{Units: 10, Nanos: 1}}); err != nil || len(found) != 0 {
+		t.Errorf("SearchOrders() above the totals = %d orders, %v, want none", len(found), err)
+	}
+
+	if _, err := store.UpdateOrderStatus(ctx, first, StatusShipped); err != nil {
+		t.Fatal(err)
+	}
+	shipped, err := store.GetUserOrders(ctx, userID, OrderQuery{Statuses: []OrderStatus{StatusShipped, StatusDelivered}})
+	if err != nil || len(shipped) != 1 || shipped[0].OrderID != first {
+		t.Errorf("GetUserOrders() of shipped orders = %+v, %v, want %s", shipped, err, first)
+	}
+
+	if err := store.RecordCompensation(ctx, Compensation{OrderID: second, UserID: userID, Step: compensateRefund,
+		Reference: "txn-1", Amount: &pb.Money{CurrencyCode: "USD", Units: 10}, Cause: "test"}); err != nil {
+		t.Error(err)
+	}
+}
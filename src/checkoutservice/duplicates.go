@@ -0,0 +1,234 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// Users double-clicking "Place Order" submit the same order twice, with
+// different idempotency keys if any. Before charging, an order claims the
+// fingerprint of its user, cart and address in order_fingerprints; if an
+// order with the same fingerprint was placed less than ORDER_DUPLICATE_WINDOW
+// ago, the new one is rejected with DUPLICATE_ORDER or, with
+// ORDER_DUPLICATE_MODE=replay, answered with the prior order. The fingerprint
+// of an order that fails is released, so that it can be placed again at once.
+
+const (
+	duplicateReject = "reject"
+	duplicateReplay = "replay"
+
+	defaultDuplicateWindow = 10 * time.Second
+
+	reasonDuplicateOrder = "DUPLICATE_ORDER"
+)
+
+var duplicateOrders, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.orders.duplicates",
+	metric.WithDescription("Orders identical to one placed within the duplicate window, by mode."),
+	metric.WithUnit("{order}"),
+)
+
+// duplicateDetector detects the orders identical to one placed within
+// window.
+type duplicateDetector struct {
+	window time.Duration
+	// mode is duplicateReject or duplicateReplay.
+	mode string
+}
+
+// newDuplicateDetectorFromEnv returns the detector configured by
+// ORDER_DUPLICATE_WINDOW and ORDER_DUPLICATE_MODE, or nil if the window is 0.
+func newDuplicateDetectorFromEnv() (*duplicateDetector, error) {
+	d := &duplicateDetector{window: defaultDuplicateWindow, mode: duplicateReject}
+	if v := os.Getenv("ORDER_DUPLICATE_WINDOW"); v != "" {
+		w, err := time.ParseDuration(v)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid ORDER_DUPLICATE_WINDOW %q", v)
+		}
+		d.window = w
+	}
+	switch m := os.Getenv("ORDER_DUPLICATE_MODE"); m {
+	case "", duplicateReject:
+	case duplicateReplay:
+		d.mode = m
+	default:
+		return nil, fmt.Errorf("invalid ORDER_DUPLICATE_MODE %q: want %s or %s", m, duplicateReject, duplicateReplay)
+	}
+	if d.window == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+func (d *duplicateDetector) String() string {
+	return fmt.Sprintf("%s orders identical to one placed less than %v ago", d.mode, d.window)
+}
+
+// orderFingerprint returns the hex SHA-256 of a user's ID, the products and
+// quantities of their cart in any order, and the shipping address.
+func orderFingerprint(userID string, items []*pb.CartItem, address *pb.Address) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("%s\x00%d", item.GetProductId(), item.GetQuantity()))
+	}
+	slices.Sort(lines)
+	h := sha256.New()
+	for _, field := range []string{
+		userID, strings.Join(lines, "\x00"),
+		address.GetStreetAddress(), address.GetCity(), address.GetState(), address.GetCountry(),
+		fmt.Sprint(address.GetZipCode()),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claim claims fingerprint for orderID in store. If an identical order was
+// placed within the window, it fails with DUPLICATE_ORDER or, in replay
+// mode, returns that order and its total instead.
+func (d *duplicateDetector) claim(ctx context.Context, store OrderStorage, fingerprint, userID, orderID string) (*pb.OrderResult, *pb.Money, error) {
+	prior, err := store.ClaimOrderFingerprint(ctx, fingerprint, userID, orderID, d.window)
+	if err != nil {
+		// detection is a safeguard, so orders are not failed for it
+		log.WithContext(ctx).Warnf("failed to check order %s for duplicates: %v", orderID, err)
+		return nil, nil, nil
+	}
+	if prior == "" {
+		return nil, nil, nil
+	}
+	duplicateOrders.Add(ctx, 1, metric.WithAttributes(attribute.String("mode", d.mode)))
+	if d.mode == duplicateReplay {
+		o, err := store.GetOrder(ctx, prior)
+		switch {
+		case err == nil && !slices.Contains(attemptStatuses, o.Status):
+			log.WithContext(ctx).Infof("replaying order %s for an identical order of user %q", prior, userID)
+			return o.result(), o.totalPaid(), nil
+		case err != nil && !errors.Is(err, errOrderNotFound):
+			return nil, nil, rpcerrors.Errorf(codes.Unavailable, reasonDuplicateOrder,
+				"an identical order %s was placed less than %v ago, and could not be read: %v", prior, d.window, err)
+		}
+		// the prior order is still being placed, or failed
+		return nil, nil, rpcerrors.Errorf(codes.Aborted, reasonDuplicateOrder,
+			"an identical order %s is being placed", prior)
+	}
+	return nil, nil, rpcerrors.Errorf(codes.AlreadyExists, reasonDuplicateOrder,
+		"an identical order %s was placed less than %v ago", prior, d.window)
+}
+
+// release releases fingerprint once orderID failed, so that it can be
+// placed again within the window.
+func (d *duplicateDetector) release(ctx context.Context, store OrderStorage, fingerprint, orderID string) {
+	// the order may have failed because ctx was cancelled
+	ctx = context.WithoutCancel(ctx)
+	if err := store.ReleaseOrderFingerprint(ctx, fingerprint, orderID); err != nil {
+		log.WithContext(ctx).Warnf("failed to release the fingerprint of failed order %s: %v", orderID, err)
+	}
+}
+
+// result returns the result of placing o, as PlaceOrder returned it.
+func (o *Order) result() *pb.OrderResult {
+	items := make([]*pb.OrderItem, 0, len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, &pb.OrderItem{
+			Item: &pb.CartItem{ProductId: item.ProductID, Quantity: item.Quantity},
+			Cost: &pb.Money{CurrencyCode: item.CurrencyCode, Units: item.UnitPriceUnits, Nanos: item.UnitPriceNanos},
+		})
+	}
+	return &pb.OrderResult{
+		OrderId:            o.OrderID,
+		ShippingTrackingId: o.ShippingTrackingID,
+		ShippingCost:       o.shippingCost(),
+		ShippingAddress:    o.shippingAddress(),
+		Items:              items,
+	}
+}
+
+// claims fingerprint for orderID unless another order claimed it within
+// window, and returns the ID of that order then
+func (os *OrderStore) ClaimOrderFingerprint(ctx context.Context, fingerprint, userID, orderID string, window time.Duration) (string, error) {
+	now := time.Now().UTC()
+	var prior string
+	err := withDBTimeout(ctx, os.timeouts.save, "ClaimOrderFingerprint", func(ctx context.Context) error {
+		return retryDB(ctx, "claim order fingerprint", func() error {
+			// expired fingerprints are deleted as new ones are claimed, so
+			// that the table only holds those of the window
+			if _, err := os.db.ExecContext(ctx, `
+	            DELETE FROM order_fingerprints WHERE created_at < $1
+	        `, now.Add(-window)); err != nil {
+				return err
+			}
+			res, err := os.db.ExecContext(ctx, `
+	            INSERT INTO order_fingerprints (fingerprint, user_id, order_id, created_at) VALUES ($1, $2, $3, $4)
+	        `+os.dialect.ignoreDuplicate("fingerprint"), fingerprint, userID, orderID, now)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil || n > 0 {
+				return err
+			}
+			err = os.db.QueryRowContext(ctx, `
+	            SELECT order_id FROM order_fingerprints WHERE fingerprint = $1
+	        `, fingerprint).Scan(&prior)
+			if err == sql.ErrNoRows {
+				// released since
+				return nil
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to claim order fingerprint: %w", err)
+	}
+	if prior == orderID {
+		// a retry whose first attempt was committed
+		return "", nil
+	}
+	return prior, nil
+}
+
+// releases the fingerprint claimed for orderID
+func (os *OrderStore) ReleaseOrderFingerprint(ctx context.Context, fingerprint, orderID string) error {
+	err := withDBTimeout(ctx, os.timeouts.save, "ReleaseOrderFingerprint", func(ctx context.Context) error {
+		return retryDB(ctx, "release order fingerprint", func() error {
+			_, err := os.db.ExecContext(ctx, `
+	            DELETE FROM order_fingerprints WHERE fingerprint = $1 AND order_id = $2
+	        `, fingerprint, orderID)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release order fingerprint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestDuplicateDetectorFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"default", nil, "reject orders identical to one placed less than 10s ago", false},
+		{"replay", map[string]string{"ORDER_DUPLICATE_WINDOW": "1m", "ORDER_DUPLICATE_MODE": "replay"}, "replay orders identical to one placed less than 1m0s ago", false},
+		{"disabled", map[string]string{"ORDER_DUPLICATE_WINDOW": "0"}, "", false},
+		{"negative window", map[string]string{"ORDER_DUPLICATE_WINDOW": "-1s"}, "", true},
+		{"unknown mode", map[string]string{"ORDER_DUPLICATE_MODE": "merge"}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"ORDER_DUPLICATE_WINDOW", "ORDER_DUPLICATE_MODE"} {
+				t.Setenv(key, tt.env[key])
+			}
+			d, err := newDuplicateDetectorFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newDuplicateDetectorFromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			got := ""
+			if d != nil {
+				got = d.String()
+			}
+			if got != tt.want {
+				t.Errorf("newDuplicateDetectorFromEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderFingerprint(t *testing.T) {
+	address := &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", ZipCode: 94043}
+	items := []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}, {ProductId: "66VCHSJNUP", Quantity: 1}}
+	want := orderFingerprint("user-1", items, address)
+	if got := orderFingerprint("user-1", []*pb.CartItem{items[1], items[0]}, address); got != want {
+		t.Errorf("fingerprint depends on the order of the cart")
+	}
+	for name, fp := range map[string]string{
+		"user":     orderFingerprint("user-2", items, address),
+		"quantity": orderFingerprint("user-1", []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}, items[1]}, address),
+		"address":  orderFingerprint("user-1", items, &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", ZipCode: 94044}),
+	} {
+		if fp == want {
+			t.Errorf("fingerprint ignores the %s", name)
+		}
+	}
+}
+
+func TestDuplicateOrders(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = newMemoryOrderStore()
+	cs.duplicates = &duplicateDetector{window: time.Minute, mode: duplicateReject}
+	req := orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	}
+	// an order placed twice in a row saw the same cart both times
+	fillCart := func() {
+		t.Helper()
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.userID, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fillCart()
+	declined := req
+	declined.card = &pb.CreditCardInfo{CreditCardNumber: "1234"}
+	if _, _, err := cs.placeOrder(ctx, declined); err == nil {
+		t.Fatal("placeOrder succeeded with an invalid card")
+	}
+	// the declined order released its fingerprint
+	first, _, err := cs.placeOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fillCart()
+	_, _, err = cs.placeOrder(ctx, req)
+	if c := rpcerrors.Classify(err); c.Code != codes.AlreadyExists || c.Reason != reasonDuplicateOrder {
+		t.Fatalf("placeOrder of a duplicate: got %v, want AlreadyExists with reason %s", err, reasonDuplicateOrder)
+	}
+
+	cs.duplicates.mode = duplicateReplay
+	replayed, total, err := cs.placeOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.GetOrderId() != first.GetOrderId() || replayed.GetShippingTrackingId() != first.GetShippingTrackingId() || len(replayed.GetItems()) != 1 || total == nil {
+		t.Errorf("placeOrder of a duplicate replayed %v, want %v", replayed, first)
+	}
+	if n := len(backends.payment.Charges()); n != 1 {
+		t.Errorf("%d charges, want duplicates not to be charged", n)
+	}
+}
+
+// TestDuplicateOfPendingOrder replays an order identical to one that is still
+// pending, which is not placed yet.
+func TestDuplicateOfPendingOrder(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryOrderStore()
+	d := &duplicateDetector{window: time.Minute, mode: duplicateReplay}
+	if err := store.SavePendingOrder(ctx, orderRecord{Order: Order{OrderID: "order-1", UserID: "user-1", CurrencyCode: "USD"}}); err != nil {
+		t.Fatal(err)
+	}
+	if prior, _, err := d.claim(ctx, store, "fingerprint", "user-1", "order-1"); prior != nil || err != nil {
+		t.Fatalf("claim of a new fingerprint = %v, %v", prior, err)
+	}
+	_, _, err := d.claim(ctx, store, "fingerprint", "user-1", "order-2")
+	if c := rpcerrors.Classify(err); c.Code != codes.Aborted || c.Reason != reasonDuplicateOrder {
+		t.Errorf("claim of a pending order's fingerprint: got %v, want Aborted with reason %s", err, reasonDuplicateOrder)
+	}
+}
+
+// TestClaimOrderFingerprint claims fingerprints in a SQLite database.
+func TestClaimOrderFingerprint(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	claim := func(orderID string, window time.Duration) string {
+		t.Helper()
+		prior, err := store.ClaimOrderFingerprint(ctx, "fp-1", "user-1", orderID, window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prior
+	}
+	if prior := claim("order-1", time.Minute); prior != "" {
+		t.Fatalf("first claim returned prior order %q", prior)
+	}
+	if prior := claim("order-2", time.Minute); prior != "order-1" {
+		t.Errorf("second claim returned prior order %q, want order-1", prior)
+	}
+	// only the order that claimed the fingerprint releases it
+	if err := store.ReleaseOrderFingerprint(ctx, "fp-1", "order-2"); err != nil {
+		t.Fatal(err)
+	}
+	if prior := claim("order-2", time.Minute); prior != "order-1" {
+		t.Errorf("claim after another order's release returned prior order %q, want order-1", prior)
+	}
+	if err := store.ReleaseOrderFingerprint(ctx, "fp-1", "order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if prior := claim("order-2", time.Minute); prior != "" {
+		t.Errorf("claim after release returned prior order %q", prior)
+	}
+	time.Sleep(time.Millisecond)
+	if prior := claim("order-3", time.Microsecond); prior != "" {
+		t.Errorf("claim after the window returned prior order %q", prior)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/instrumentation"
+)
+
+// An order confirmation that cannot be sent when the order is placed, such
+// as while the email service is down, is queued in email_outbox as the
+// rendered SendOrderConfirmationRequest, and an emailRetrier sends it again
+// later, backing off between attempts. A confirmation sent is deleted from
+// the outbox; one still failing after EMAIL_RETRY_MAX_ATTEMPTS attempts,
+// counting the first, is kept with failed_at set so that it can be resent
+// through ResendConfirmation. Each retrier leases the confirmations it sends
+// for emailLease, so that the pods sharing a database do not send the same
+// one, and a confirmation is sent again if its pod stops between sending it
+// and deleting it. With ORDER_STORE=memory the outbox is lost on restart.
+
+const (
+	defaultEmailMaxAttempts = 10
+	defaultEmailInterval    = 10 * time.Second
+	// emailBatch is how many confirmations are leased at a time.
+	emailBatch = 10
+	// emailTimeout bounds each attempt, and emailLease how long a leased
+	// confirmation is left to its retrier.
+	emailTimeout = 10 * time.Second
+	emailLease   = time.Minute
+	// emailMinBackoff is how long a confirmation waits after its first
+	// failure, doubling with each failure up to emailMaxBackoff.
+	emailMinBackoff = 30 * time.Second
+	emailMaxBackoff = time.Hour
+)
+
+var emailRetries, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.email.retries",
+	metric.WithDescription("Attempts to send queued order confirmations, by outcome: sent, retrying or failed."),
+	metric.WithUnit("{attempt}"),
+)
+
+// QueuedEmail is an order confirmation queued to be sent again.
+type QueuedEmail struct {
+	ID      int64
+	OrderID string
+	UserID  string
+	// Request is the SendOrderConfirmationRequest, encoded.
+	Request       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	// FailedAt is set once no attempts are left.
+	FailedAt time.Time
+}
+
+// emailRetrier sends the confirmations queued in an order store.
+type emailRetrier struct {
+	store       OrderStorage
+	send        func(context.Context, *pb.SendOrderConfirmationRequest) error
+	maxAttempts int
+	interval    time.Duration
+	now         func() time.Time
+}
+
+// newEmailRetrierFromEnv returns the retrier of the confirmations queued in
+// store, configured by EMAIL_RETRY_MAX_ATTEMPTS and EMAIL_RETRY_INTERVAL, or
+// nil if confirmations are attempted only once.
+func newEmailRetrierFromEnv(store OrderStorage, send func(context.Context, *pb.SendOrderConfirmationRequest) error) (*emailRetrier, error) {
+	r := &emailRetrier{
+		store:       store,
+		send:        send,
+		maxAttempts: defaultEmailMaxAttempts,
+		interval:    defaultEmailInterval,
+		now:         time.Now,
+	}
+	if v := os.Getenv("EMAIL_RETRY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid EMAIL_RETRY_MAX_ATTEMPTS %q", v)
+		}
+		r.maxAttempts = n
+	}
+	if v := os.Getenv("EMAIL_RETRY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid EMAIL_RETRY_INTERVAL %q", v)
+		}
+		r.interval = d
+	}
+	if r.maxAttempts == 1 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func (r *emailRetrier) String() string {
+	return fmt.Sprintf("up to %d attempts, every %s", r.maxAttempts, r.interval)
+}
+
+// queue queues req, whose first attempt failed with err, to be sent again.
+func (r *emailRetrier) queue(ctx context.Context, orderID, userID string, req *pb.SendOrderConfirmationRequest, err error) error {
+	b, merr := proto.Marshal(req)
+	if merr != nil {
+		return fmt.Errorf("failed to encode the confirmation: %w", merr)
+	}
+	return r.store.QueueEmail(ctx, QueuedEmail{
+		OrderID:       orderID,
+		UserID:        userID,
+		Request:       b,
+		Attempts:      1,
+		NextAttemptAt: r.now().Add(emailBackoff(1)),
+		LastError:     err.Error(),
+	})
+}
+
+// run sends the queued confirmations that are due every interval until ctx
+// is done.
+func (r *emailRetrier) run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := r.drain(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("email outbox: %v", err)
+		}
+	}
+}
+
+// drain sends the queued confirmations that are due until none is left, and
+// returns how many it attempted.
+func (r *emailRetrier) drain(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		due, err := r.store.ClaimDueEmails(ctx, r.now(), emailLease, emailBatch)
+		if err != nil {
+			return total, err
+		}
+		for _, e := range due {
+			if err := r.retry(ctx, e); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if len(due) < emailBatch {
+			return total, nil
+		}
+	}
+}
+
+// retry attempts to send e again, and records the outcome.
+func (r *emailRetrier) retry(ctx context.Context, e QueuedEmail) error {
+	ctx = instrumentation.WithBaggage(ctx, map[string]string{instrumentation.BaggageOrderID: e.OrderID})
+	req := new(pb.SendOrderConfirmationRequest)
+	err := proto.Unmarshal(e.Request, req)
+	if err == nil {
+		sendCtx, cancel := context.WithTimeout(ctx, emailTimeout)
+		err = r.send(sendCtx, req)
+		cancel()
+	}
+	e.Attempts++
+	outcome := "sent"
+	switch {
+	case err == nil:
+		log.WithContext(ctx).Infof("order confirmation of order %s sent after %d attempts", e.OrderID, e.Attempts)
+	case e.Attempts >= r.maxAttempts:
+		e.LastError, e.FailedAt, outcome = err.Error(), r.now(), "failed"
+		log.WithContext(ctx).Warnf("email outbox: giving up on the confirmation of order %s after %d attempts: %v", e.OrderID, e.Attempts, err)
+	default:
+		e.LastError, e.NextAttemptAt, outcome = err.Error(), r.now().Add(emailBackoff(e.Attempts)), "retrying"
+	}
+	if err := r.store.RecordEmailAttempt(context.WithoutCancel(ctx), e, err == nil); err != nil {
+		return err
+	}
+	emailRetries.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	return nil
+}
+
+// emailBackoff returns how long a confirmation waits after its attempts-th
+// failure.
+func emailBackoff(attempts int) time.Duration {
+	b := emailMinBackoff
+	for i := 1; i < attempts && b < emailMaxBackoff; i++ {
+		b *= 2
+	}
+	return min(b, emailMaxBackoff)
+}
+
+// queues a confirmation to be sent again
+func (os *OrderStore) QueueEmail(ctx context.Context, e QueuedEmail) (err error) {
+	ctx, span := startStoreSpan(ctx, "QueueEmail")
+	defer func() { endSpan(span, err) }()
+	err = withDBTimeout(ctx, os.timeouts.save, "QueueEmail", func(ctx context.Context) error {
+		// a retry queues the confirmation twice if the lost attempt was
+		// committed
+		return retryDB(ctx, "queue confirmation of order "+e.OrderID, func() error {
+			_, err := os.db.ExecContext(ctx, `
+                INSERT INTO email_outbox (order_id, user_id, request, attempts, next_attempt_at, last_error, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+            `, e.OrderID, e.UserID, e.Request, e.Attempts, e.NextAttemptAt.UTC(), e.LastError, time.Now().UTC())
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue confirmation: %w", err)
+	}
+	return nil
+}
+
+// leases up to limit queued confirmations due at now for lease, and returns
+// them
+func (os *OrderStore) ClaimDueEmails(ctx context.Context, now time.Time, lease time.Duration, limit int) (_ []QueuedEmail, err error) {
+	ctx, span := startStoreSpan(ctx, "ClaimDueEmails")
+	defer func() { endSpan(span, err) }()
+	var claimed []QueuedEmail
+	err = withDBTimeout(ctx, os.timeouts.save, "ClaimDueEmails", func(ctx context.Context) error {
+		due, err := queryDueEmails(ctx, os.db, now.UTC(), limit)
+		if err != nil {
+			return err
+		}
+		claimed = claimed[:0]
+		for _, e := range due {
+			// another pod leased it since unless it is still due
+			res, err := os.db.ExecContext(ctx, `
+                UPDATE email_outbox SET next_attempt_at = $2 WHERE id = $1 AND next_attempt_at <= $3 AND failed_at IS NULL
+            `, e.ID, now.Add(lease).UTC(), now.UTC())
+			if err != nil {
+				return fmt.Errorf("failed to lease queued confirmation: %w", err)
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return fmt.Errorf("failed to lease queued confirmation: %w", err)
+			} else if n == 1 {
+				claimed = append(claimed, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func queryDueEmails(ctx context.Context, q queryer, now time.Time, limit int) ([]QueuedEmail, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT id, order_id, user_id, request, attempts, next_attempt_at, last_error FROM email_outbox
+        WHERE failed_at IS NULL AND next_attempt_at <= $1 ORDER BY next_attempt_at LIMIT $2
+    `, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query queued confirmations: %w", err)
+	}
+	defer rows.Close()
+	var due []QueuedEmail
+	for rows.Next() {
+		var e QueuedEmail
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.UserID, &e.Request, &e.Attempts, &e.NextAttemptAt, &e.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan queued confirmation: %w", err)
+		}
+		due = append(due, e)
+	}
+	return due, rows.Err()
+}
+
+// records an attempt to send a queued confirmation, deleting it if it was
+// sent
+func (os *OrderStore) RecordEmailAttempt(ctx context.Context, e QueuedEmail, sent bool) (err error) {
+	ctx, span := startStoreSpan(ctx, "RecordEmailAttempt")
+	defer func() { endSpan(span, err) }()
+	var failedAt any
+	if !e.FailedAt.IsZero() {
+		failedAt = e.FailedAt.UTC()
+	}
+	err = withDBTimeout(ctx, os.timeouts.save, "RecordEmailAttempt", func(ctx context.Context) error {
+		return retryDB(ctx, "record confirmation attempt of order "+e.OrderID, func() error {
+			var err error
+			if sent {
+				_, err = os.db.ExecContext(ctx, `DELETE FROM email_outbox WHERE id = $1`, e.ID)
+			} else {
+				_, err = os.db.ExecContext(ctx, `
+                    UPDATE email_outbox SET attempts = $2, next_attempt_at = $3, last_error = $4, failed_at = $5 WHERE id = $1
+                `, e.ID, e.Attempts, e.NextAttemptAt.UTC(), e.LastError, failedAt)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record confirmation attempt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+)
+
+func TestEmailRetrierFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, "up to 10 attempts, every 10s", false},
+		{"set", map[string]string{"EMAIL_RETRY_MAX_ATTEMPTS": "3", "EMAIL_RETRY_INTERVAL": "1m"}, "up to 3 attempts, every 1m0s", false},
+		{"off", map[string]string{"EMAIL_RETRY_MAX_ATTEMPTS": "1"}, "", false},
+		{"invalid attempts", map[string]string{"EMAIL_RETRY_MAX_ATTEMPTS": "0"}, "", true},
+		{"invalid interval", map[string]string{"EMAIL_RETRY_INTERVAL": "often"}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"EMAIL_RETRY_MAX_ATTEMPTS", "EMAIL_RETRY_INTERVAL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			r, err := newEmailRetrierFromEnv(newMemoryOrderStore(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newEmailRetrierFromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			got := ""
+			if r != nil {
+				got = r.String()
+			}
+			if got != tt.want {
+				t.Errorf("newEmailRetrierFromEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:   30 * time.Second,
+		2:   time.Minute,
+		5:   8 * time.Minute,
+		10:  time.Hour,
+		100: time.Hour,
+	} {
+		if got := emailBackoff(attempts); got != want {
+			t.Errorf("emailBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestConfirmationQueuedUntilSent(t *testing.T) {
+	for name, store := range map[string]func(*testing.T) OrderStorage{
+		"sqlite": func(t *testing.T) OrderStorage { return newSQLiteStore(t) },
+		"memory": func(*testing.T) OrderStorage { return newMemoryOrderStore() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cs, _ := newFakeCheckoutService(t)
+			cs.orderStore = store(t)
+			now := time.Now()
+			cs.emails = &emailRetrier{store: cs.orderStore, send: cs.sendConfirmation, maxAttempts: 3, interval: time.Second, now: func() time.Time { return now }}
+			req := placeOrderRequest("key-1")
+			req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+			// the email service is not faked, so the confirmation is queued
+			placed, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n, err := cs.emails.drain(ctx); err != nil || n != 0 {
+				t.Fatalf("drain() before the backoff = %d, %v, want 0", n, err)
+			}
+			now = now.Add(emailBackoff(1))
+			if n, err := cs.emails.drain(ctx); err != nil || n != 1 {
+				t.Fatalf("drain() with the email service down = %d, %v, want 1", n, err)
+			}
+
+			email := &recordingEmail{}
+			cs.emailSvcConn = contract.Serve(t, func(srv *grpc.Server) { pb.RegisterEmailServiceServer(srv, email) })
+			now = now.Add(emailBackoff(2))
+			if n, err := cs.emails.drain(ctx); err != nil || n != 1 {
+				t.Fatalf("drain() = %d, %v, want 1", n, err)
+			}
+			if len(email.sent) != 1 || email.sent[0].GetOrder().GetOrderId() != placed.GetOrder().GetOrderId() || email.sent[0].GetSubject() == "" {
+				t.Fatalf("sent %v, want the rendered confirmation of order %s", email.sent, placed.GetOrder().GetOrderId())
+			}
+			now = now.Add(emailMaxBackoff)
+			if n, err := cs.emails.drain(ctx); err != nil || n != 0 {
+				t.Errorf("drain() after sending = %d, %v, want 0", n, err)
+			}
+		})
+	}
+}
+
+func TestEmailRetrierGivesUp(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	now := time.Now()
+	sends := 0
+	r := &emailRetrier{store: store, maxAttempts: 3, interval: time.Second, now: func() time.Time { return now }, send: func(context.Context, *pb.SendOrderConfirmationRequest) error {
+		sends++
+		return errors.New("email service down")
+	}}
+	if err := r.queue(ctx, "order-1", "user-1", &pb.SendOrderConfirmationRequest{Email: "someone@example.com"}, errors.New("first attempt")); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		now = now.Add(emailMaxBackoff)
+		if _, err := r.drain(ctx); err != nil {
+			t.Fatalf("drain() #%d: %v", i, err)
+		}
+	}
+	if sends != 2 {
+		t.Errorf("%d sends retried, want 2", sends)
+	}
+	var attempts int
+	var lastError string
+	var failedAt *time.Time
+	if err := store.db.QueryRow(`SELECT attempts, last_error, failed_at FROM email_outbox WHERE order_id = 'order-1'`).Scan(&attempts, &lastError, &failedAt); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || lastError != "email service down" || failedAt == nil {
+		t.Errorf("queued confirmation has %d attempts, error %q, failed at %v, want 3 attempts, the last error and failed_at", attempts, lastError, failedAt)
+	}
+}
+
+func TestEmailsLeasedOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	now := time.Now()
+	if err := store.QueueEmail(ctx, QueuedEmail{OrderID: "order-1", UserID: "user-1", Request: []byte{}, Attempts: 1, NextAttemptAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := store.ClaimDueEmails(ctx, now, emailLease, emailBatch)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDueEmails() = %v, %v, want the queued confirmation", claimed, err)
+	}
+	if again, err := store.ClaimDueEmails(ctx, now, emailLease, emailBatch); err != nil || len(again) != 0 {
+		t.Errorf("ClaimDueEmails() while leased = %v, %v, want none", again, err)
+	}
+	if again, err := store.ClaimDueEmails(ctx, now.Add(emailLease), emailLease, emailBatch); err != nil || len(again) != 1 {
+		t.Errorf("ClaimDueEmails() once the lease expired = %v, %v, want the queued confirmation", again, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailtemplate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// OrderLoader returns the template data for a persisted order.
+type OrderLoader func(ctx context.Context, orderID string) (*Order, error)
+
+// PreviewHandler serves rendered confirmation emails for template authors:
+//
+//	GET /preview?order_id=<id>&locale=fr&format=html|text
+//
+// Without order_id a built-in sample order is rendered, so templates can be
+// previewed without a database.
+func PreviewHandler(r *Renderer, load OrderLoader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		order := SampleOrder()
+		if id := q.Get("order_id"); id != "" {
+			if load == nil {
+				http.Error(w, "order persistence is not configured", http.StatusServiceUnavailable)
+				return
+			}
+			o, err := load(req.Context(), id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			order = o
+		}
+
+		msg, err := r.Render(q.Get("locale"), order)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Language", msg.Locale)
+		w.Header().Set("X-Email-Subject", msg.Subject)
+		if q.Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprint(w, msg.TextBody)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, msg.HTMLBody)
+	})
+}
+
+// SampleOrder returns a fixed order used for previews and tests.
+func SampleOrder() *Order {
+	return &Order{
+		OrderID:            "00000000-0000-0000-0000-000000000000",
+		Email:              "someone@example.com",
+		CreatedAt:          time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		ShippingTrackingID: "AB-1234-5678901",
+		ShippingAddress: &pb.Address{
+			StreetAddress: "1600 Amphitheatre Parkway",
+			City:          "Mountain View",
+			State:         "CA",
+			Country:       "United States",
+			ZipCode:       94043,
+		},
+		ShippingCost: &pb.Money{CurrencyCode: "USD", Units: 8, Nanos: 990000000},
+		Total:        &pb.Money{CurrencyCode: "USD", Units: 76, Nanos: 970000000},
+		Items: []*pb.OrderItem{
+			{
+				Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2},
+				Cost: &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000},
+			},
+			{
+				Item: &pb.CartItem{ProductId: "66VCHSJNUP", Quantity: 1},
+				Cost: &pb.Money{CurrencyCode: "USD", Units: 18, Nanos: 990000000},
+			},
+		},
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package emailtemplate renders order confirmation emails from per-locale HTML and
+// plain text templates.
+package emailtemplate
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// DefaultLocale is used when the requested locale has no template variant.
+const DefaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Order is the data made available to confirmation templates.
+type Order struct {
+	OrderID            string
+	Email              string
+	CreatedAt          time.Time
+	ShippingTrackingID string
+	ShippingAddress    *pb.Address
+	ShippingCost       *pb.Money
+	Total              *pb.Money
+	Items              []*pb.OrderItem
+	// GiftMessage and CustomerNote are those the order was placed with, if
+	// any.
+	GiftMessage  string
+	CustomerNote string
+}
+
+// Message is a rendered confirmation email.
+type Message struct {
+	Locale   string
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+type variant struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// Renderer renders confirmation emails. It is safe for concurrent use.
+type Renderer struct {
+	variants map[string]variant
+}
+
+var funcs = map[string]interface{}{
+	"money": FormatMoney,
+	"date":  func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+}
+
+// NewRenderer parses every locale directory under templates/. Each locale
+// must provide confirmation.html and confirmation.txt; the text template
+// also defines the "subject" line.
+func NewRenderer() (*Renderer, error) {
+	dirs, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	r := &Renderer{variants: make(map[string]variant)}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		locale := d.Name()
+		dir := path.Join("templates", locale)
+		h, err := htmltemplate.New("confirmation.html").Funcs(funcs).ParseFS(templateFS, path.Join(dir, "confirmation.html"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s html template: %w", locale, err)
+		}
+		t, err := texttemplate.New("confirmation.txt").Funcs(funcs).ParseFS(templateFS, path.Join(dir, "confirmation.txt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s text template: %w", locale, err)
+		}
+		r.variants[locale] = variant{html: h, text: t}
+	}
+	if _, ok := r.variants[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("missing templates for default locale %q", DefaultLocale)
+	}
+	return r, nil
+}
+
+// Locales returns the locales that have a template variant.
+func (r *Renderer) Locales() []string {
+	out := make([]string, 0, len(r.variants))
+	for l := range r.variants {
+		out = append(out, l)
+	}
+	return out
+}
+
+// Resolve maps a BCP 47 language tag such as "fr-CA" onto a supported
+// locale, falling back to DefaultLocale.
+func (r *Renderer) Resolve(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := r.variants[tag]; ok {
+		return tag
+	}
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		if _, ok := r.variants[tag[:i]]; ok {
+			return tag[:i]
+		}
+	}
+	return DefaultLocale
+}
+
+// Render produces the subject, HTML and text bodies for order in the
+// locale closest to tag.
+func (r *Renderer) Render(tag string, order *Order) (*Message, error) {
+	locale := r.Resolve(tag)
+	v := r.variants[locale]
+
+	var subject, html, text bytes.Buffer
+	if err := v.text.ExecuteTemplate(&subject, "subject", order); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := v.html.Execute(&html, order); err != nil {
+		return nil, fmt.Errorf("failed to render html body: %w", err)
+	}
+	if err := v.text.Execute(&text, order); err != nil {
+		return nil, fmt.Errorf("failed to render text body: %w", err)
+	}
+	return &Message{
+		Locale:   locale,
+		Subject:  strings.TrimSpace(subject.String()),
+		HTMLBody: html.String(),
+		TextBody: text.String(),
+	}, nil
+}
+
+// FormatMoney renders m as "12.30 USD", rounded to the nearest cent, half
+// away from zero. Amounts under a unit are negative when their nanos are, so
+// the sign is taken from either field.
+func FormatMoney(m *pb.Money) string {
+	if m == nil {
+		return ""
+	}
+	nanos := int64(m.GetNanos())
+	if nanos < 0 {
+		nanos -= 5000000
+	} else {
+		nanos += 5000000
+	}
+	cents := m.GetUnits()*100 + nanos/10000000
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, cents/100, cents%100, m.GetCurrencyCode())
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emailtemplate
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestResolve(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"":      "en",
+		"en":    "en",
+		"fr":    "fr",
+		"fr-CA": "fr",
+		"es_MX": "es",
+		"de-DE": "en",
+		" FR ":  "fr",
+	}
+	for in, want := range tests {
+		if got := r.Resolve(in); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderAllLocales(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := SampleOrder()
+	for _, locale := range r.Locales() {
+		msg, err := r.Render(locale, order)
+		if err != nil {
+			t.Fatalf("Render(%q) failed: %v", locale, err)
+		}
+		if !strings.Contains(msg.Subject, order.OrderID) {
+			t.Errorf("%s subject %q does not mention order id", locale, msg.Subject)
+		}
+		for _, body := range []string{msg.HTMLBody, msg.TextBody} {
+			for _, want := range []string{order.OrderID, order.ShippingTrackingID, "OLJCESPC7Z", "19.99 USD", "76.97 USD"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("%s body missing %q", locale, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderMessages(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := SampleOrder()
+	msg, err := r.Render("en", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.TextBody, "Gift message") || strings.Contains(msg.HTMLBody, "Your note") {
+		t.Error("body mentions messages the order was placed without")
+	}
+
+	order.GiftMessage = "Happy birthday!"
+	order.CustomerNote = "Please leave it at the door."
+	msg, err = r.Render("en", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{msg.HTMLBody, msg.TextBody} {
+		for _, want := range []string{"Gift message", order.GiftMessage, order.CustomerNote} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body missing %q", want)
+			}
+		}
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := SampleOrder()
+	order.ShippingAddress.StreetAddress = "<script>alert(1)</script>"
+	msg, err := r.Render("en", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.HTMLBody, "<script>") {
+		t.Error("html body contains unescaped address")
+	}
+	if !strings.Contains(msg.TextBody, "<script>") {
+		t.Error("text body should contain the raw address")
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	for _, tt := range []struct {
+		units int64
+		nanos int32
+		want  string
+	}{
+		{12, 300000000, "12.30 USD"},
+		{0, 0, "0.00 USD"},
+		// nanos round to the nearest cent, half away from zero
+		{19, 994999999, "19.99 USD"},
+		{19, 995000000, "20.00 USD"},
+		{0, 9999999, "0.01 USD"},
+		{-19, -995000000, "-20.00 USD"},
+		// amounts under a unit take their sign from their nanos
+		{0, -500000000, "-0.50 USD"},
+		{0, -4000000, "0.00 USD"},
+		{-3, -250000000, "-3.25 USD"},
+	} {
+		m := &pb.Money{CurrencyCode: "USD", Units: tt.units, Nanos: tt.nanos}
+		if got := FormatMoney(m); got != tt.want {
+			t.Errorf("FormatMoney(%d, %d) = %q, want %q", tt.units, tt.nanos, got, tt.want)
+		}
+	}
+	if got := FormatMoney(nil); got != "" {
+		t.Errorf("FormatMoney(nil) = %q, want empty", got)
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := PreviewHandler(r, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/preview?locale=fr&format=text", nil))
+	if rec.Code != 200 {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Language"); got != "fr" {
+		t.Errorf("Content-Language = %q, want fr", got)
+	}
+	if !strings.Contains(rec.Body.String(), "Merci") {
+		t.Errorf("expected french text body, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/preview?order_id=abc", nil))
+	if rec.Code != 503 {
+		t.Errorf("got status %d without a loader, want 503", rec.Code)
+	}
+}
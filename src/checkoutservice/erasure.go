@@ -0,0 +1,204 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Users can have the personal data of their orders erased, as data
+// protection law such as the GDPR entitles them to. Their orders are kept,
+// since accounting and refunds need their amounts, but are unlinked from the
+// user and stripped of the email, shipping address and card data. Their
+// items, which tell what the user bought, their notes, the user's
+// idempotency keys, whose responses repeat the order, the fraud decisions on
+// their orders and their queued order confirmations are deleted, and the
+// copies of the orders in the replication outbox, in unpublished order
+// events and in the order archive are scrubbed alike. Each erasure is recorded in user_erasures.
+//
+// Erasing only covers the region's database: orders still in the
+// write-behind queue are saved afterwards, and events already published
+// are out of reach.
+
+// UserErasure counts the rows affected by erasing a user's data.
+type UserErasure struct {
+	OrdersAnonymized       int64
+	OrderItemsDeleted      int64
+	IdempotencyKeysDeleted int64
+}
+
+// anonymize strips o of the personal data of the user who placed it.
+func (o *Order) anonymize() {
+	o.UserID, o.Email = "", ""
+	o.StreetAddress, o.City, o.State, o.Country, o.ZipCode = "", "", "", "", ""
+	o.BillingStreetAddress, o.BillingCity, o.BillingState, o.BillingCountry, o.BillingZipCode = "", "", "", "", ""
+	o.GiftMessage, o.CustomerNote = "", ""
+	o.CardToken, o.CardLast4, o.CardBrand = "", "", ""
+}
+
+// erases the personal data of a user's orders, and records the erasure
+func (os *OrderStore) DeleteUserData(ctx context.Context, userID string) (_ UserErasure, err error) {
+	ctx, span := startStoreSpan(ctx, "DeleteUserData")
+	defer func() { endSpan(span, err) }()
+	var e UserErasure
+	err = retryDB(ctx, "erase user data", func() error {
+		e = UserErasure{}
+		return inTx(ctx, os.db, func(tx *sql.Tx) error {
+			return os.eraseUser(ctx, tx, userID, &e)
+		})
+	})
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("failed to erase user data: %w", err)
+	}
+	log.WithContext(ctx).Infof("Erased user data: %d orders anonymized, %d order items and %d idempotency keys deleted",
+		e.OrdersAnonymized, e.OrderItemsDeleted, e.IdempotencyKeysDeleted)
+	return e, nil
+}
+
+func (os *OrderStore) eraseUser(ctx context.Context, tx *sql.Tx, userID string, e *UserErasure) error {
+	orderIDs, err := lockUserOrders(ctx, tx, os.dialect, userID)
+	if err != nil {
+		return err
+	}
+	exec := func(count *int64, query string, args ...any) error {
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if count != nil {
+			*count, err = res.RowsAffected()
+		}
+		return err
+	}
+
+	if len(orderIDs) > 0 {
+		var args []any
+		if err := exec(&e.OrderItemsDeleted, `DELETE FROM order_items WHERE `+os.dialect.inList("order_id", orderIDs, &args), args...); err != nil {
+			return fmt.Errorf("failed to delete order items: %w", err)
+		}
+		args = nil
+		if err := exec(nil, `DELETE FROM order_notes WHERE `+os.dialect.inList("order_id", orderIDs, &args), args...); err != nil {
+			return fmt.Errorf("failed to delete order notes: %w", err)
+		}
+		if err := exec(&e.OrdersAnonymized, `
+            UPDATE orders SET user_id = '', email = '', street_address = '', city = '', state = '',
+                country = '', zip_code = '', billing_street_address = '', billing_city = '',
+                billing_state = '', billing_country = '', billing_zip_code = '', gift_message = '',
+                customer_note = '', card_token = '', card_last4 = '', card_brand = ''
+            WHERE user_id = $1
+        `, userID); err != nil {
+			return fmt.Errorf("failed to anonymize orders: %w", err)
+		}
+		if os.dialect.hasOutbox() {
+			if err := scrubPayloads(ctx, tx, orderIDs); err != nil {
+				return err
+			}
+		}
+	}
+	// archived orders are erased alike; see retention.go
+	var archivedItems, archivedOrders int64
+	if err := exec(&archivedItems, `
+        DELETE FROM order_items_archive WHERE order_id IN (SELECT order_id FROM orders_archive WHERE user_id = $1)
+    `, userID); err != nil {
+		return fmt.Errorf("failed to delete archived order items: %w", err)
+	}
+	if err := exec(nil, `
+        DELETE FROM order_notes WHERE order_id IN (SELECT order_id FROM orders_archive WHERE user_id = $1)
+    `, userID); err != nil {
+		return fmt.Errorf("failed to delete archived order notes: %w", err)
+	}
+	if err := exec(&archivedOrders, `
+        UPDATE orders_archive SET user_id = '', email = '', street_address = '', city = '', state = '',
+            country = '', zip_code = '', billing_street_address = '', billing_city = '',
+            billing_state = '', billing_country = '', billing_zip_code = '', gift_message = '',
+            customer_note = '', card_token = '', card_last4 = '', card_brand = ''
+        WHERE user_id = $1
+    `, userID); err != nil {
+		return fmt.Errorf("failed to anonymize archived orders: %w", err)
+	}
+	e.OrderItemsDeleted += archivedItems
+	e.OrdersAnonymized += archivedOrders
+	if err := exec(&e.IdempotencyKeysDeleted, `DELETE FROM idempotency_keys WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete idempotency keys: %w", err)
+	}
+	if err := exec(nil, `DELETE FROM order_fingerprints WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete order fingerprints: %w", err)
+	}
+	if err := exec(nil, `DELETE FROM fraud_decisions WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete fraud decisions: %w", err)
+	}
+	if err := exec(nil, `DELETE FROM email_outbox WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete queued confirmations: %w", err)
+	}
+	if err := exec(nil, `UPDATE order_compensations SET user_id = '' WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to anonymize compensations: %w", err)
+	}
+	if err := exec(nil, `
+        INSERT INTO user_erasures (user_id, orders_anonymized, order_items_deleted, idempotency_keys_deleted)
+        VALUES ($1, $2, $3, $4)
+    `, userID, e.OrdersAnonymized, e.OrderItemsDeleted, e.IdempotencyKeysDeleted); err != nil {
+		return fmt.Errorf("failed to record erasure: %w", err)
+	}
+	return nil
+}
+
+// lockUserOrders returns the IDs of a user's orders, locking them until tx
+// ends.
+func lockUserOrders(ctx context.Context, tx *sql.Tx, d dialect, userID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT order_id FROM orders WHERE user_id = $1 `+d.forUpdate(), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user orders: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan order ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// scrubPayloads erases the personal data of orders from the copies of them
+// in the outbox, replication conflicts and unpublished order events.
+func scrubPayloads(ctx context.Context, tx *sql.Tx, orderIDs []string) error {
+	// the payloads of the outbox and of conflicts are orderRecords
+	for _, table := range []string{"order_outbox", "replication_conflicts"} {
+		if _, err := tx.ExecContext(ctx, `
+            UPDATE `+table+` SET payload = (jsonb_set(payload, '{Order}', payload->'Order' || '{
+                "UserID": "", "Email": "", "StreetAddress": "", "City": "", "State": "",
+                "Country": "", "ZipCode": "", "BillingStreetAddress": "", "BillingCity": "",
+                "BillingState": "", "BillingCountry": "", "BillingZipCode": "", "GiftMessage": "",
+                "CustomerNote": "", "CardToken": "", "CardLast4": "", "CardBrand": ""
+            }') || '{"Items": []}') - 'Idempotency'
+            WHERE order_id = ANY($1)
+        `, pq.Array(orderIDs)); err != nil {
+			return fmt.Errorf("failed to scrub %s: %w", table, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE order_events SET payload = payload || '{"user_id": "", "items": []}'
+        WHERE order_id = ANY($1) AND published_at IS NULL
+    `, pq.Array(orderIDs)); err != nil {
+		return fmt.Errorf("failed to scrub order events: %w", err)
+	}
+	return nil
+}
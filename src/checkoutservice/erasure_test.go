@@ -0,0 +1,88 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// TestOrderStoreDeleteUserData erases a user's orders from a SQLite
+// database.
+func TestOrderStoreDeleteUserData(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	userID, otherID := uuid.NewString(), uuid.NewString()
+	save := func(userID string, idem *IdempotencyRecord) string {
+		orderID := uuid.NewString()
+		if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", BillingCity: "Sunnyvale", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", GiftMessage: "Happy birthday!"}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}, {ProductId: "66VCHSJNUP", Quantity: 1}}), Idempotency: idem}); err != nil {
+			t.Fatal(err)
+		}
+		return orderID
+	}
+	erased := save(userID, &IdempotencyRecord{Key: "key-1", Fingerprint: []byte("f"), Response: []byte("r")})
+	save(userID, nil)
+	kept := save(otherID, nil)
+	if err := store.RecordCompensation(ctx, Compensation{OrderID: "o-1", UserID: userID, Step: compensateRefund, Reference: "txn-1", Cause: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, orderID := range []string{erased, kept} {
+		if _, err := store.AddOrderNote(ctx, OrderNote{OrderID: orderID, Author: "support@example.com", Text: "asked to deliver to a neighbour"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := store.DeleteUserData(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (UserErasure{OrdersAnonymized: 2, OrderItemsDeleted: 4, IdempotencyKeysDeleted: 1}); e != want {
+		t.Errorf("DeleteUserData() = %+v, want %+v", e, want)
+	}
+	o, err := store.GetOrder(ctx, erased)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.UserID != "" || o.Email != "" || o.StreetAddress != "" || o.City != "" || o.BillingCity != "" || o.GiftMessage != "" || len(o.Items) != 0 || o.OrderTotalUnits != 10 {
+		t.Errorf("erased order = %+v, want its total without personal data or items", o)
+	}
+	if rec, err := store.GetIdempotencyRecord(ctx, userID, "key-1"); rec != nil || err != nil {
+		t.Errorf("GetIdempotencyRecord() after erasure = %+v, %v, want nil", rec, err)
+	}
+	if o, err := store.GetOrder(ctx, kept); err != nil || o.Email == "" || len(o.Items) != 2 {
+		t.Errorf("order of another user = %+v, %v, want it untouched", o, err)
+	}
+	var remaining, recorded, notes int
+	if err := store.db.QueryRowContext(ctx, `SELECT count(*) FROM order_notes WHERE order_id = $1`, erased).Scan(&notes); err != nil || notes != 0 {
+		t.Errorf("%d notes of the erased user's order remain, %v", notes, err)
+	}
+	if err := store.db.QueryRowContext(ctx, `SELECT count(*) FROM order_notes WHERE order_id = $1`, kept).Scan(&notes); err != nil || notes != 1 {
+		t.Errorf("%d notes of another user's order remain, %v, want 1", notes, err)
+	}
+	if err := store.db.QueryRowContext(ctx, `SELECT count(*) FROM order_compensations WHERE user_id = $1`, userID).Scan(&remaining); err != nil || remaining != 0 {
+		t.Errorf("%d compensations of the erased user remain, %v", remaining, err)
+	}
+	if err := store.db.QueryRowContext(ctx, `SELECT orders_anonymized FROM user_erasures WHERE user_id = $1`, userID).Scan(&recorded); err != nil || recorded != 2 {
+		t.Errorf("recorded erasure of %d orders, %v, want 2", recorded, err)
+	}
+
+	if e, err := store.DeleteUserData(ctx, userID); err != nil || e != (UserErasure{}) {
+		t.Errorf("erasing again = %+v, %v, want nothing erased", e, err)
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Downstream systems learn about orders from the order_events table, a
+// transactional outbox: SaveOrder appends an event in the transaction that
+// stores the order, and an eventPublisher drains the events that were not
+// published yet to a sink, marking each with published_at once the sink
+// accepted it. An event is published again if the publisher fails before
+// marking it, so delivery is at least once and sinks should deduplicate on
+// the event ID. Publishers lock the events they publish with SKIP LOCKED, so
+// that the pods sharing a database do not publish the same events.
+//
+// Unlike order_outbox, which replicates orders between regions, order_events
+// is written in every region and only for orders placed there.
+
+const (
+	// orderPlaced is the type of the event appended when an order is saved.
+	orderPlaced = "order.placed"
+	// orderShipped and orderCancelled are the types of the events appended
+	// when an order becomes shipped or cancelled.
+	orderShipped   = "order.shipped"
+	orderCancelled = "order.cancelled"
+
+	defaultEventsInterval = time.Second
+	// publishBatch is how many events are published per transaction.
+	publishBatch = 100
+	// eventsRetention is how long published events are kept.
+	eventsRetention = 7 * 24 * time.Hour
+)
+
+// OrderEvent is the payload published for an order event. It carries no
+// card data or shipping address.
+type OrderEvent struct {
+	ID         int64            `json:"id"`
+	Type       string           `json:"type"`
+	OrderID    string           `json:"order_id"`
+	UserID     string           `json:"user_id"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Status     OrderStatus      `json:"status"`
+	Total      orderEventAmount `json:"total"`
+	Items      []orderEventItem `json:"items"`
+	TrackingID string           `json:"shipping_tracking_id"`
+}
+
+type orderEventAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Units        int64  `json:"units"`
+	Nanos        int32  `json:"nanos"`
+}
+
+type orderEventItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int32  `json:"quantity"`
+}
+
+// statusEvents are the types of the events appended when an order changes
+// to each status, if any.
+var statusEvents = map[OrderStatus]string{
+	StatusShipped:   orderShipped,
+	StatusCancelled: orderCancelled,
+}
+
+// appends an event of type typ for the order of rec to order_events, and
+// queues its delivery to webhooks
+func appendOrderEvent(ctx context.Context, tx dbtx, typ string, rec orderRecord, webhooks []string) error {
+	o := rec.Order
+	ev := OrderEvent{
+		Type:       typ,
+		OrderID:    o.OrderID,
+		UserID:     o.UserID,
+		CreatedAt:  o.CreatedAt,
+		Status:     o.Status,
+		Total:      orderEventAmount{CurrencyCode: o.CurrencyCode, Units: o.OrderTotalUnits, Nanos: o.OrderTotalNanos},
+		Items:      []orderEventItem{},
+		TrackingID: o.ShippingTrackingID,
+	}
+	for _, it := range rec.Items {
+		ev.Items = append(ev.Items, orderEventItem{ProductID: it.ProductID, Quantity: it.Quantity})
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to encode order event: %w", err)
+	}
+	ctx, span := startStatement(ctx, "INSERT", "order_events")
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO order_events (order_id, type, payload) VALUES ($1, $2, $3) RETURNING id
+    `, o.OrderID, typ, payload).Scan(&id)
+	endStatement(span, 1, err)
+	if err != nil {
+		return fmt.Errorf("failed to append order event: %w", err)
+	}
+	return enqueueWebhooks(ctx, tx, id, webhooks)
+}
+
+// eventSink receives the published order events.
+type eventSink interface {
+	Publish(ctx context.Context, ev OrderEvent) error
+}
+
+// logSink logs events, for local runs.
+type logSink struct{}
+
+func (logSink) String() string { return "the log" }
+
+func (logSink) Publish(ctx context.Context, ev OrderEvent) error {
+	log.Infof("order event %d: %s %s", ev.ID, ev.Type, ev.OrderID)
+	return nil
+}
+
+// webhookSink POSTs each event as JSON to a URL, with its ID in the
+// Idempotency-Key header, and succeeds on any 2xx response.
+type webhookSink struct {
+	url    *url.URL
+	client *http.Client
+}
+
+func (s *webhookSink) String() string { return s.url.Redacted() }
+
+func (s *webhookSink) Publish(ctx context.Context, ev OrderEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(ev.ID, 10))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// eventSinkFromEnv returns the sink named by ORDER_EVENTS_SINK: log (the
+// default), none to leave events unpublished, an http(s) URL to POST them to,
+// or the kafka://, pubsub:// or nats:// URL of a topic (see eventsinks.go).
+func eventSinkFromEnv(ctx context.Context) (eventSink, error) {
+	v := os.Getenv("ORDER_EVENTS_SINK")
+	switch v {
+	case "", "log":
+		return logSink{}, nil
+	case "none":
+		return nil, nil
+	}
+	var (
+		sink eventSink
+		err  error
+	)
+	switch {
+	case strings.HasPrefix(v, "kafka://"):
+		sink, err = newKafkaSink(v)
+	case strings.HasPrefix(v, "pubsub://"):
+		sink, err = newPubSubSink(ctx, v)
+	case strings.HasPrefix(v, "nats://"):
+		sink, err = newNATSSink(v)
+	default:
+		sink, err = newWebhookSink(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func newWebhookSink(v string) (*webhookSink, error) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid ORDER_EVENTS_SINK %q: want log, none, or an http(s), kafka, pubsub or nats URL", v)
+	}
+	return &webhookSink{url: u, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// eventPublisher publishes the events of order_events to a sink.
+type eventPublisher struct {
+	db       *sql.DB
+	sink     eventSink
+	interval time.Duration
+	// mu serializes drains, so that the last one, on shutdown, does not run
+	// alongside one of run's
+	mu sync.Mutex
+}
+
+// newEventPublisherFromEnv returns the publisher of db's events to the sink
+// of ORDER_EVENTS_SINK, polling every ORDER_EVENTS_INTERVAL, or nil if the
+// sink is none.
+func newEventPublisherFromEnv(ctx context.Context, db *sql.DB) (*eventPublisher, error) {
+	sink, err := eventSinkFromEnv(ctx)
+	if err != nil || sink == nil {
+		return nil, err
+	}
+	p := &eventPublisher{db: db, sink: sink, interval: defaultEventsInterval}
+	if v := os.Getenv("ORDER_EVENTS_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid ORDER_EVENTS_INTERVAL %q", v)
+		}
+		p.interval = d
+	}
+	return p, nil
+}
+
+// close closes the connection to the sink, if it has one.
+// close publishes the events still pending, such as those of the orders
+// drained on shutdown, and closes the sink.
+func (p *eventPublisher) close(ctx context.Context) error {
+	_, err := p.drain(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to flush order events: %w", err)
+	}
+	if c, ok := p.sink.(io.Closer); ok {
+		return errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// run publishes pending events every interval until ctx is done.
+func (p *eventPublisher) run(ctx context.Context) {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := p.drain(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("order events: %v", err)
+		}
+		if _, err := p.db.ExecContext(ctx, `
+            DELETE FROM order_events WHERE published_at < $1
+        `, time.Now().Add(-eventsRetention)); err != nil && ctx.Err() == nil {
+			log.Warnf("order events: failed to delete published events: %v", err)
+		}
+	}
+}
+
+// drain publishes pending events until none is left or one fails, and
+// returns how many it published.
+func (p *eventPublisher) drain(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	for {
+		n, err := p.publishBatch(ctx)
+		total += n
+		if err != nil || n < publishBatch {
+			return total, err
+		}
+	}
+}
+
+// publishBatch publishes the next batch of pending events, in order, in one
+// transaction. It stops at the first event the sink rejects, recording the
+// error on it, so that the events of an order are not published out of
+// order.
+func (p *eventPublisher) publishBatch(ctx context.Context) (int, error) {
+	published := 0
+	var failed error
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+            SELECT id, payload FROM order_events WHERE published_at IS NULL
+            ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+        `, publishBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read pending events: %w", err)
+		}
+		var events []OrderEvent
+		for rows.Next() {
+			var (
+				id      int64
+				payload []byte
+			)
+			if err := rows.Scan(&id, &payload); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read pending events: %w", err)
+			}
+			var ev OrderEvent
+			if err := json.Unmarshal(payload, &ev); err != nil {
+				rows.Close()
+				return fmt.Errorf("invalid payload of event %d: %w", id, err)
+			}
+			ev.ID = id
+			events = append(events, ev)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read pending events: %w", err)
+		}
+
+		for _, ev := range events {
+			if perr := p.sink.Publish(ctx, ev); perr != nil {
+				failed = fmt.Errorf("failed to publish event %d of order %s: %w", ev.ID, ev.OrderID, perr)
+				_, err := tx.ExecContext(ctx, `
+                    UPDATE order_events SET attempts = attempts + 1, last_error = $2 WHERE id = $1
+                `, ev.ID, perr.Error())
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `
+                UPDATE order_events SET attempts = attempts + 1, last_error = NULL, published_at = now() WHERE id = $1
+            `, ev.ID); err != nil {
+				return fmt.Errorf("failed to mark event %d published: %w", ev.ID, err)
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, failed
+}
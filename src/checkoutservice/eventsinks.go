@@ -0,0 +1,185 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// The message brokers order events can be published to. Each keys its
+// messages with the order ID, so that the events of an order are consumed in
+// order, and tags them with the event type and ID for consumers to route and
+// deduplicate them.
+
+// kafkaSink publishes events to a Kafka topic, named by a URL such as
+// kafka://broker-1:9092,broker-2:9092/orders.
+type kafkaSink struct {
+	brokers []string
+	writer  *kafka.Writer
+}
+
+func newKafkaSink(rawURL string) (*kafkaSink, error) {
+	hosts, topic, ok := strings.Cut(strings.TrimPrefix(rawURL, "kafka://"), "/")
+	if hosts == "" || topic == "" || !ok || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("invalid Kafka sink %q: want kafka://<broker>[,<broker>...]/<topic>", rawURL)
+	}
+	brokers := strings.Split(hosts, ",")
+	return &kafkaSink{brokers: brokers, writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// events are published one at a time, each waiting for its ack
+		BatchSize: 1,
+	}}, nil
+}
+
+func (s *kafkaSink) String() string {
+	return fmt.Sprintf("Kafka topic %s on %s", s.writer.Topic, strings.Join(s.brokers, ","))
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, ev OrderEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(ev.OrderID),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(ev.Type)},
+			{Key: "event_id", Value: []byte(strconv.FormatInt(ev.ID, 10))},
+		},
+	})
+}
+
+func (s *kafkaSink) Close() error { return s.writer.Close() }
+
+var pubsubTopicPattern = regexp.MustCompile(`^pubsub://projects/([^/]+)/topics/([^/]+)$`)
+
+// pubsubSink publishes events to a Google Cloud Pub/Sub topic, named by a URL
+// such as pubsub://projects/my-project/topics/orders, with the application
+// default credentials. Subscriptions should enable message ordering for the
+// events of an order to be delivered in order.
+type pubsubSink struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+func newPubSubSink(ctx context.Context, rawURL string) (*pubsubSink, error) {
+	m := pubsubTopicPattern.FindStringSubmatch(rawURL)
+	if m == nil {
+		return nil, fmt.Errorf("invalid Pub/Sub sink %q: want pubsub://projects/<project>/topics/<topic>", rawURL)
+	}
+	client, err := pubsub.NewClient(ctx, m[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	topic := client.Topic(m[2])
+	topic.EnableMessageOrdering = true
+	return &pubsubSink{client: client, topic: topic}, nil
+}
+
+func (s *pubsubSink) String() string { return "Pub/Sub topic " + s.topic.String() }
+
+func (s *pubsubSink) Publish(ctx context.Context, ev OrderEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = s.topic.Publish(ctx, &pubsub.Message{
+		Data:        data,
+		OrderingKey: ev.OrderID,
+		Attributes:  map[string]string{"type": ev.Type, "event_id": strconv.FormatInt(ev.ID, 10)},
+	}).Get(ctx)
+	if err != nil {
+		// a failed publish pauses its ordering key until resumed
+		s.topic.ResumePublish(ev.OrderID)
+	}
+	return err
+}
+
+func (s *pubsubSink) Close() error {
+	s.topic.Stop()
+	return s.client.Close()
+}
+
+// natsSink publishes events to a NATS JetStream subject, named by a URL such
+// as nats://nats:4222/orders.placed, which a stream must capture. The event
+// ID is the message ID, so that the stream discards duplicates within its
+// deduplication window.
+type natsSink struct {
+	subject string
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+}
+
+func newNATSSink(rawURL string) (*natsSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS sink %q: want nats://<host>[:<port>]/<subject>", rawURL)
+	}
+	subject := strings.TrimPrefix(u.Path, "/")
+	if subject == "" || strings.Contains(subject, "/") {
+		return nil, fmt.Errorf("invalid NATS sink %q: want nats://<host>[:<port>]/<subject>", rawURL)
+	}
+	server := url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host}
+	conn, err := nats.Connect(server.String(), nats.Name("checkoutservice"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to use NATS JetStream: %w", err)
+	}
+	return &natsSink{subject: subject, conn: conn, js: js}, nil
+}
+
+func (s *natsSink) String() string {
+	return fmt.Sprintf("NATS subject %s on %s", s.subject, s.conn.ConnectedUrlRedacted())
+}
+
+func (s *natsSink) Publish(ctx context.Context, ev OrderEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.subject)
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(ev.ID, 10))
+	msg.Header.Set("Order-Id", ev.OrderID)
+	msg.Header.Set("Type", ev.Type)
+	_, err = s.js.PublishMsg(msg, nats.Context(ctx))
+	return err
+}
+
+func (s *natsSink) Close() error {
+	if err := s.conn.Drain(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/audit"
+)
+
+// exportPageSize is how many orders an export reads at a time, which bounds
+// its memory however many orders it streams.
+const exportPageSize = 500
+
+// exportFormats are the content types of the formats of order exports.
+var exportFormats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// exportColumns are the columns of CSV exports, in order.
+var exportColumns = []string{
+	"order_id", "user_id", "email", "status", "created_at", "currency_code",
+	"total", "shipping_cost", "tax", "item_count", "shipping_tracking_id",
+	"street_address", "city", "state", "country", "zip_code",
+	"payment_transaction_id", "shipping_quote", "shipping_quote_currency_code",
+	"billing_street_address", "billing_city", "billing_state", "billing_country", "billing_zip_code",
+	"promo_code", "discount", "points_earned", "points_redeemed",
+}
+
+// handleExport registers GET /debug/orders/export on mux, which streams the
+// orders selected by its email, currency_code, created_after, created_before
+// (RFC 3339) and status (repeatable) parameters, newest first, as CSV or, with
+// format=ndjson, as one JSON order per line. Every export is audited with its
+// filter and the number of orders it streamed.
+//
+//	curl 'localhost:6060/debug/orders/export?created_after=2026-01-01T00:00:00Z&format=ndjson'
+func (cs *checkoutService) handleExport(mux *http.ServeMux, auditLog *audit.Log) {
+	mux.HandleFunc("/debug/orders/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		contentType, ok := exportFormats[format]
+		if !ok {
+			http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+			return
+		}
+		search, err := exportSearch(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e := audit.Entry{
+			Actor:     r.RemoteAddr,
+			Operation: "orders.Export",
+			Details:   map[string]string{"format": format, "filter": r.URL.RawQuery},
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format))
+		n, err := exportOrders(r.Context(), cs.orderStore, search, newOrderEncoder(format, w))
+		e.Details["orders"] = strconv.Itoa(n)
+		e.Outcome = "ok"
+		if err != nil {
+			e.Outcome = err.Error()
+		}
+		if err := auditLog.Record(r.Context(), e); err != nil {
+			log.WithContext(r.Context()).Errorf("failed to audit orders.Export: %v", err)
+		}
+		switch {
+		case err == nil:
+		case n == 0:
+			// nothing was written yet, so the status can still be set
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "failed to export orders: "+err.Error(), http.StatusServiceUnavailable)
+		default:
+			// aborting the response keeps clients from mistaking the
+			// orders streamed so far for the whole export
+			log.WithContext(r.Context()).Warnf("export aborted after %d orders: %v", n, err)
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// exportSearch returns the search selecting the orders that query filters.
+// Unlike SearchOrders, it needs no filter, so as to export every order.
+func exportSearch(query url.Values) (OrderSearch, error) {
+	s := OrderSearch{
+		Email:        strings.TrimSpace(query.Get("email")),
+		CurrencyCode: query.Get("currency_code"),
+	}
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"created_after", &s.CreatedAfter},
+		{"created_before", &s.CreatedBefore},
+	} {
+		v := query.Get(f.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return s, fmt.Errorf("invalid %s: %v", f.name, err)
+		}
+		*f.dst = t
+	}
+	for _, v := range query["status"] {
+		st := OrderStatus(v)
+		if _, ok := orderTransitions[st]; !ok {
+			return s, fmt.Errorf("invalid status %q", v)
+		}
+		s.Statuses = append(s.Statuses, st)
+	}
+	return s, nil
+}
+
+// exportOrders writes the orders selected by search to enc a page at a
+// time, following the cursor of the last order of each page, and returns how
+// many it wrote.
+func exportOrders(ctx context.Context, store OrderStorage, search OrderSearch, enc orderEncoder) (int, error) {
+	search.Limit = exportPageSize
+	n := 0
+	for {
+		orders, err := store.SearchOrders(ctx, search)
+		if err != nil {
+			return n, err
+		}
+		for i := range orders {
+			if err := enc.encode(&orders[i]); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if err := enc.flush(); err != nil {
+			return n, err
+		}
+		if len(orders) < exportPageSize {
+			return n, nil
+		}
+		last := orders[len(orders)-1]
+		search.After = &OrderCursor{CreatedAt: last.CreatedAt, OrderID: last.OrderID}
+	}
+}
+
+// orderEncoder writes the orders of an export.
+type orderEncoder interface {
+	encode(o *Order) error
+	// flush sends the orders encoded so far to the client.
+	flush() error
+}
+
+func newOrderEncoder(format string, w http.ResponseWriter) orderEncoder {
+	if format == "ndjson" {
+		return &ndjsonEncoder{w: w}
+	}
+	return &csvEncoder{w: csv.NewWriter(w), rw: w}
+}
+
+// csvEncoder writes one row per order, after a header of exportColumns.
+type csvEncoder struct {
+	w      *csv.Writer
+	rw     http.ResponseWriter
+	header bool
+}
+
+func (e *csvEncoder) encode(o *Order) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	var quantity int64
+	for _, item := range o.Items {
+		quantity += int64(item.Quantity)
+	}
+	return e.w.Write([]string{
+		o.OrderID, o.UserID, o.Email, string(o.Status), o.CreatedAt.UTC().Format(time.RFC3339Nano), o.CurrencyCode,
+		formatAmount(o.OrderTotalUnits, o.OrderTotalNanos),
+		formatAmount(o.ShippingCostUnits, o.ShippingCostNanos),
+		formatAmount(o.TaxUnits, o.TaxNanos),
+		strconv.FormatInt(quantity, 10), o.ShippingTrackingID,
+		o.StreetAddress, o.City, o.State, o.Country, o.ZipCode,
+		o.PaymentTransactionID, formatAmount(o.ShippingQuoteUnits, o.ShippingQuoteNanos), o.ShippingQuoteCurrencyCode,
+		o.BillingStreetAddress, o.BillingCity, o.BillingState, o.BillingCountry, o.BillingZipCode,
+		o.PromoCode, formatAmount(o.DiscountUnits, o.DiscountNanos),
+		strconv.FormatInt(o.PointsEarned, 10), strconv.FormatInt(o.PointsRedeemed, 10),
+	})
+}
+
+// writeHeader writes the header once, so that even empty exports have it.
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(exportColumns)
+}
+
+func (e *csvEncoder) flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	return flushResponse(e.rw)
+}
+
+// ndjsonEncoder writes each order as the JSON of its API representation,
+// on a line of its own.
+type ndjsonEncoder struct {
+	w http.ResponseWriter
+}
+
+func (e *ndjsonEncoder) encode(o *Order) error {
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(orderToProto(o))
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return flushResponse(e.w)
+}
+
+// flushResponse sends what was written to w so far, unless w cannot flush.
+func flushResponse(w http.ResponseWriter) error {
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// formatAmount returns the decimal amount of units and nanos, as in 12.5.
+func formatAmount(units int64, nanos int32) string {
+	sign := ""
+	if units < 0 || nanos < 0 {
+		sign = "-"
+		units, nanos = -units, -nanos
+	}
+	s := fmt.Sprintf("%s%d.%09d", sign, units, nanos)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/audit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/emailtemplate"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+// TestExportOrders exports more than a page of orders from a SQLite
+// database, as CSV and as NDJSON.
+func TestExportOrders(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	save := func(email string) {
+		id := uuid.NewString()
+		if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: id, UserID: uuid.NewString(), Email: email, City: "Mountain View", ZipCode: "94043", OrderTotalUnits: 12, OrderTotalNanos: 500000000, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", ShippingCostUnits: 2, ShippingQuoteUnits: 2, ShippingQuoteCurrencyCode: "USD"}, Items: pricedItems(id, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 3}})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const want = exportPageSize + 1
+	for i := 0; i < want; i++ {
+		save("someone@example.com")
+	}
+	save("someone.else@example.com")
+	mux := http.NewServeMux()
+	(&checkoutService{orderStore: store}).handleExport(mux, nil)
+	export := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/orders/export?"+query, nil))
+		return rec
+	}
+
+	rec := export("email=SOMEONE@example.com")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != exportFormats["csv"] {
+		t.Fatalf("CSV export = %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != want+1 {
+		t.Fatalf("CSV export has %d rows, want a header and %d orders", len(rows), want)
+	}
+	ids := map[string]bool{}
+	for _, row := range rows[1:] {
+		ids[row[0]] = true
+	}
+	if len(ids) != want {
+		t.Errorf("CSV export has %d distinct orders, want %d", len(ids), want)
+	}
+	got := map[string]string{}
+	for i, col := range rows[0] {
+		got[col] = rows[1][i]
+	}
+	if got["email"] != "someone@example.com" || got["total"] != "12.5" || got["shipping_cost"] != "2" || got["tax"] != "0" || got["item_count"] != "3" || got["zip_code"] != "94043" ||
+		got["payment_transaction_id"] != "txn-1" || got["shipping_quote"] != "2" || got["shipping_quote_currency_code"] != "USD" {
+		t.Errorf("CSV order = %v", got)
+	}
+
+	rec = export("format=ndjson&status=paid")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("NDJSON export = %d: %s", rec.Code, rec.Body)
+	}
+	lines := 0
+	for sc := bufio.NewScanner(rec.Body); sc.Scan(); lines++ {
+		var o struct {
+			OrderID string `json:"order_id"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &o); err != nil || o.OrderID == "" {
+			t.Fatalf("NDJSON line %d = %s: %v", lines, sc.Text(), err)
+		}
+	}
+	if lines != want+1 {
+		t.Errorf("NDJSON export has %d orders, want %d", lines, want+1)
+	}
+
+	rec = export("status=shipped")
+	if rows, _ := csv.NewReader(rec.Body).ReadAll(); len(rows) != 1 {
+		t.Errorf("export of no orders has %d rows, want only the header", len(rows))
+	}
+	for _, query := range []string{"format=xml", "status=lost", "created_after=yesterday"} {
+		if rec := export(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("export?%s = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestDebugEmailPreview previews a stored order's confirmation on the debug
+// mux, which records it in the audit log.
+func TestDebugEmailPreview(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	id := uuid.NewString()
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: id, UserID: uuid.NewString(), Email: "someone@example.com", City: "Mountain View", ZipCode: "94043", OrderTotalUnits: 12, OrderTotalNanos: 500000000, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", ShippingCostUnits: 2, ShippingQuoteUnits: 2, ShippingQuoteCurrencyCode: "USD"}, Items: pricedItems(id, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 3}})}); err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := emailtemplate.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog, err := audit.Open("checkoutservice", "", logging.New("checkoutservice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	(&checkoutService{orderStore: store, emailRenderer: renderer}).handleEmailPreview(mux, auditLog)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/email/preview?format=text&order_id="+id, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "track-1") {
+		t.Fatalf("preview = %d %s, want the stored order's confirmation", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/email/preview?order_id=missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("preview of a missing order = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	entries := auditLog.Entries(audit.Query{Operation: "orders.PreviewEmail"})
+	if len(entries) != 2 || entries[0].Details["order_id"] != id || entries[0].Outcome != "ok" || entries[1].Outcome == "ok" || entries[0].Actor == "" {
+		t.Errorf("audit entries = %+v, want both previews recorded with their outcome and actor", entries)
+	}
+}
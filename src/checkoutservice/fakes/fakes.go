@@ -0,0 +1,618 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakes provides in-memory implementations of the services the Go
+// services call: the product catalog, cart, currency, shipping, payment and
+// inventory services. They behave like the real services as far as their callers can
+// tell, which the contract package checks for fakes and real services alike,
+// so that code calling them can be tested without running them. Serve fakes
+// with contract.Serve.
+//
+// This package is duplicated in checkoutservice and frontend since they do
+// not share packages.
+package fakes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// Products returns a few products with the IDs of the real catalog.
+func Products() []*pb.Product {
+	return []*pb.Product{
+		{
+			Id:          "OLJCESPC7Z",
+			Name:        "Sunglasses",
+			Description: "Add a modern touch to your outfits with these sleek aviator sunglasses.",
+			PriceUsd:    &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000},
+			Categories:  []string{"accessories"},
+		},
+		{
+			Id:          "66VCHSJNUP",
+			Name:        "Tank Top",
+			Description: "Perfectly cropped cotton tank, with a scooped neckline.",
+			PriceUsd:    &pb.Money{CurrencyCode: "USD", Units: 18, Nanos: 990000000},
+			Categories:  []string{"clothing", "tops"},
+		},
+		{
+			Id:          "1YMWWN1N4O",
+			Name:        "Watch",
+			Description: "This gold-tone stainless steel watch will work with most of your outfits.",
+			PriceUsd:    &pb.Money{CurrencyCode: "USD", Units: 109, Nanos: 990000000},
+			Categories:  []string{"accessories"},
+		},
+	}
+}
+
+// ProductCatalog is a fake hipstershop.ProductCatalogService.
+type ProductCatalog struct {
+	pb.UnimplementedProductCatalogServiceServer
+
+	Products []*pb.Product
+}
+
+// NewProductCatalog returns a catalog of products, or of Products() if none
+// are given.
+func NewProductCatalog(products ...*pb.Product) *ProductCatalog {
+	if len(products) == 0 {
+		products = Products()
+	}
+	return &ProductCatalog{Products: products}
+}
+
+// Register serves the fake on srv.
+func (f *ProductCatalog) Register(srv *grpc.Server) {
+	pb.RegisterProductCatalogServiceServer(srv, f)
+}
+
+// ListProducts pages and sorts the catalog like productcatalogservice.
+func (f *ProductCatalog) ListProducts(_ context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	if req.GetPageSize() < 0 || req.GetPage() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size and page must not be negative")
+	}
+	products := slices.Clone(f.Products)
+	byName := func(a, b *pb.Product) int {
+		return strings.Compare(strings.ToLower(a.GetName()), strings.ToLower(b.GetName()))
+	}
+	byPrice := func(a, b *pb.Product) int {
+		x, y := a.GetPriceUsd(), b.GetPriceUsd()
+		if c := cmp.Compare(x.GetUnits(), y.GetUnits()); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.GetNanos(), y.GetNanos())
+	}
+	switch req.GetSort() {
+	case pb.ProductSort_PRODUCT_SORT_UNSPECIFIED:
+	case pb.ProductSort_PRODUCT_SORT_NAME:
+		slices.SortStableFunc(products, byName)
+	case pb.ProductSort_PRODUCT_SORT_PRICE_ASC:
+		slices.SortStableFunc(products, byPrice)
+	case pb.ProductSort_PRODUCT_SORT_PRICE_DESC:
+		slices.SortStableFunc(products, func(a, b *pb.Product) int { return byPrice(b, a) })
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown sort %v", req.GetSort())
+	}
+	resp := &pb.ListProductsResponse{Products: products, TotalSize: int32(len(products))}
+	if size := int(req.GetPageSize()); size > 0 {
+		start := min((max(int(req.GetPage()), 1)-1)*size, len(products))
+		resp.Products = products[start:min(start+size, len(products))]
+	}
+	return resp, nil
+}
+
+func (f *ProductCatalog) GetProduct(_ context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	for _, p := range f.Products {
+		if p.GetId() == req.GetId() {
+			return p, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no product with ID %s", req.GetId())
+}
+
+func (f *ProductCatalog) SearchProducts(_ context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
+	query := strings.ToLower(req.GetQuery())
+	var out []*pb.Product
+	for _, p := range f.Products {
+		if strings.Contains(strings.ToLower(p.GetName()), query) ||
+			strings.Contains(strings.ToLower(p.GetDescription()), query) {
+			out = append(out, p)
+		}
+	}
+	return &pb.SearchProductsResponse{Results: out}, nil
+}
+
+// Cart is a fake hipstershop.CartService.
+type Cart struct {
+	pb.UnimplementedCartServiceServer
+
+	mu    sync.Mutex
+	carts map[string][]*pb.CartItem
+}
+
+// NewCart returns a service where every cart is empty.
+func NewCart() *Cart {
+	return &Cart{carts: make(map[string][]*pb.CartItem)}
+}
+
+// Register serves the fake on srv.
+func (f *Cart) Register(srv *grpc.Server) {
+	pb.RegisterCartServiceServer(srv, f)
+}
+
+func (f *Cart) AddItem(_ context.Context, req *pb.AddItemRequest) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := f.carts[req.GetUserId()]
+	for _, it := range items {
+		if it.GetProductId() == req.GetItem().GetProductId() {
+			it.Quantity += req.GetItem().GetQuantity()
+			return &pb.Empty{}, nil
+		}
+	}
+	f.carts[req.GetUserId()] = append(items, proto.Clone(req.GetItem()).(*pb.CartItem))
+	return &pb.Empty{}, nil
+}
+
+func (f *Cart) GetCart(_ context.Context, req *pb.GetCartRequest) (*pb.Cart, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cart := &pb.Cart{UserId: req.GetUserId()}
+	for _, it := range f.carts[req.GetUserId()] {
+		cart.Items = append(cart.Items, proto.Clone(it).(*pb.CartItem))
+	}
+	return cart, nil
+}
+
+func (f *Cart) EmptyCart(_ context.Context, req *pb.EmptyCartRequest) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.carts, req.GetUserId())
+	return &pb.Empty{}, nil
+}
+
+func (f *Cart) MergeCart(_ context.Context, req *pb.MergeCartRequest) (*pb.Cart, error) {
+	from, to := req.GetFromUserId(), req.GetToUserId()
+	if from == "" || to == "" || from == to {
+		return nil, status.Error(codes.InvalidArgument, "from_user_id and to_user_id must be set and differ")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := f.carts[to]
+	for _, it := range f.carts[from] {
+		i := slices.IndexFunc(items, func(have *pb.CartItem) bool { return have.GetProductId() == it.GetProductId() })
+		if i < 0 {
+			items = append(items, it)
+			continue
+		}
+		items[i].Quantity += it.GetQuantity()
+	}
+	delete(f.carts, from)
+	cart := &pb.Cart{UserId: to}
+	if len(items) > 0 {
+		f.carts[to] = items
+	}
+	for _, it := range items {
+		cart.Items = append(cart.Items, proto.Clone(it).(*pb.CartItem))
+	}
+	return cart, nil
+}
+
+// Rates returns exchange rates against the euro for a few currencies, taken
+// from the real currency service.
+func Rates() map[string]float64 {
+	return map[string]float64{
+		"EUR": 1,
+		"USD": 1.1305,
+		"JPY": 126.40,
+		"GBP": 0.85970,
+		"CAD": 1.5128,
+		"TRY": 6.1781,
+	}
+}
+
+// Currency is a fake hipstershop.CurrencyService.
+type Currency struct {
+	pb.UnimplementedCurrencyServiceServer
+
+	rates map[string]float64
+}
+
+// NewCurrency returns a service with the given rates against the euro, or
+// Rates() if rates is nil.
+func NewCurrency(rates map[string]float64) *Currency {
+	if rates == nil {
+		rates = Rates()
+	}
+	return &Currency{rates: rates}
+}
+
+// Register serves the fake on srv.
+func (f *Currency) Register(srv *grpc.Server) {
+	pb.RegisterCurrencyServiceServer(srv, f)
+}
+
+func (f *Currency) GetSupportedCurrencies(context.Context, *pb.Empty) (*pb.GetSupportedCurrenciesResponse, error) {
+	codes := make([]string, 0, len(f.rates))
+	for code := range f.rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return &pb.GetSupportedCurrenciesResponse{CurrencyCodes: codes}, nil
+}
+
+func (f *Currency) Convert(_ context.Context, req *pb.CurrencyConversionRequest) (*pb.Money, error) {
+	fromRate, ok := f.rates[req.GetFrom().GetCurrencyCode()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported currency %q", req.GetFrom().GetCurrencyCode())
+	}
+	toRate, ok := f.rates[req.GetToCode()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported currency %q", req.GetToCode())
+	}
+	out, err := money.Convert(*req.GetFrom(), fromRate, toRate, req.GetToCode())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &out, nil
+}
+
+// WatchRates sends every rate, then keeps the stream open until the caller
+// cancels it; the fake's rates never change.
+func (f *Currency) WatchRates(_ *pb.WatchRatesRequest, stream pb.CurrencyService_WatchRatesServer) error {
+	if err := stream.Send(&pb.CurrencyRates{Rates: f.rates, Full: true}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+// Shipping is a fake hipstershop.ShippingService that quotes Cost for every
+// order and records the orders it ships and cancels. Its shipments stay in
+// transit until they are cancelled.
+type Shipping struct {
+	pb.UnimplementedShippingServiceServer
+
+	Cost *pb.Money
+
+	mu        sync.Mutex
+	shipped   []*pb.ShipOrderRequest
+	cancelled []string
+}
+
+// NewShipping returns a service that quotes 8.99 USD, like the real one.
+func NewShipping() *Shipping {
+	return &Shipping{Cost: &pb.Money{CurrencyCode: "USD", Units: 8, Nanos: 990000000}}
+}
+
+// Register serves the fake on srv.
+func (f *Shipping) Register(srv *grpc.Server) {
+	pb.RegisterShippingServiceServer(srv, f)
+}
+
+func (f *Shipping) GetQuote(context.Context, *pb.GetQuoteRequest) (*pb.GetQuoteResponse, error) {
+	return &pb.GetQuoteResponse{CostUsd: f.Cost}, nil
+}
+
+func (f *Shipping) ShipOrder(_ context.Context, req *pb.ShipOrderRequest) (*pb.ShipOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shipped = append(f.shipped, proto.Clone(req).(*pb.ShipOrderRequest))
+	return &pb.ShipOrderResponse{TrackingId: fmt.Sprintf("FK-%04d-%08d", len(f.shipped), len(f.shipped))}, nil
+}
+
+func (f *Shipping) CancelShipment(_ context.Context, req *pb.CancelShipmentRequest) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.known(req.GetTrackingId()) {
+		return nil, status.Errorf(codes.NotFound, "no shipment with tracking ID %q", req.GetTrackingId())
+	}
+	if !slices.Contains(f.cancelled, req.GetTrackingId()) {
+		f.cancelled = append(f.cancelled, req.GetTrackingId())
+	}
+	return &pb.Empty{}, nil
+}
+
+func (f *Shipping) GetShipment(_ context.Context, req *pb.GetShipmentRequest) (*pb.Shipment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.known(req.GetTrackingId()) {
+		return nil, status.Errorf(codes.NotFound, "no shipment with tracking ID %q", req.GetTrackingId())
+	}
+	res := &pb.Shipment{TrackingId: req.GetTrackingId(), Status: pb.ShipmentStatus_SHIPMENT_STATUS_IN_TRANSIT}
+	if slices.Contains(f.cancelled, req.GetTrackingId()) {
+		res.Status = pb.ShipmentStatus_SHIPMENT_STATUS_CANCELLED
+	}
+	return res, nil
+}
+
+// known reports whether ShipOrder returned trackingID. The caller must hold
+// f.mu.
+func (f *Shipping) known(trackingID string) bool {
+	var n int
+	_, err := fmt.Sscanf(trackingID, "FK-%04d-", &n)
+	return err == nil && n >= 1 && n <= len(f.shipped)
+}
+
+// Shipped returns the orders shipped so far.
+func (f *Shipping) Shipped() []*pb.ShipOrderRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*pb.ShipOrderRequest(nil), f.shipped...)
+}
+
+// Cancelled returns the tracking IDs of the shipments cancelled so far.
+func (f *Shipping) Cancelled() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.cancelled)
+}
+
+// Payment is a fake hipstershop.PaymentService. Like the real one, it only
+// accepts valid VISA and MasterCard numbers that have not expired, and
+// charges once per idempotency key.
+type Payment struct {
+	pb.UnimplementedPaymentServiceServer
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	charges []*pb.ChargeRequest
+	// refunds are the refunded transaction IDs
+	refunds []string
+	// keys maps idempotency key to the index of its charge in charges
+	keys map[string]int
+	// lose is how many of the next charges fail as if their response was
+	// lost
+	lose int
+}
+
+// NewPayment returns a service that accepts every valid card.
+func NewPayment() *Payment {
+	return &Payment{now: time.Now, keys: make(map[string]int)}
+}
+
+// LoseResponses makes the next n charges go through but fail with
+// Unavailable, as if their responses were lost.
+func (f *Payment) LoseResponses(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lose = n
+}
+
+// Register serves the fake on srv.
+func (f *Payment) Register(srv *grpc.Server) {
+	pb.RegisterPaymentServiceServer(srv, f)
+}
+
+func (f *Payment) Charge(_ context.Context, req *pb.ChargeRequest) (*pb.ChargeResponse, error) {
+	card := req.GetCreditCard()
+	number := strings.ReplaceAll(card.GetCreditCardNumber(), "-", "")
+	if !luhn(number) {
+		return nil, status.Error(codes.InvalidArgument, "Credit card info is invalid")
+	}
+	if !isVisa(number) && !isMasterCard(number) {
+		return nil, status.Error(codes.InvalidArgument, "Only VISA or MasterCard is accepted.")
+	}
+	now := f.now()
+	if now.Year()*12+int(now.Month()) > int(card.GetCreditCardExpirationYear())*12+int(card.GetCreditCardExpirationMonth()) {
+		return nil, status.Errorf(codes.InvalidArgument, "Your credit card (ending %s) expired on %d/%d",
+			number[len(number)-4:], card.GetCreditCardExpirationMonth(), card.GetCreditCardExpirationYear())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, ok := f.keys[req.GetIdempotencyKey()]
+	if ok && !proto.Equal(f.charges[n-1].GetAmount(), req.GetAmount()) {
+		return nil, status.Errorf(codes.InvalidArgument, "Idempotency key %s was used to charge a different amount", req.GetIdempotencyKey())
+	}
+	if !ok {
+		f.charges = append(f.charges, proto.Clone(req).(*pb.ChargeRequest))
+		n = len(f.charges)
+		if req.GetIdempotencyKey() != "" {
+			f.keys[req.GetIdempotencyKey()] = n
+		}
+	}
+	if f.lose > 0 {
+		f.lose--
+		return nil, status.Error(codes.Unavailable, "connection reset")
+	}
+	return &pb.ChargeResponse{TransactionId: fmt.Sprintf("fake-transaction-%d", n)}, nil
+}
+
+func (f *Payment) Refund(_ context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int
+	if _, err := fmt.Sscanf(req.GetTransactionId(), "fake-transaction-%d", &n); err != nil || n < 1 || n > len(f.charges) {
+		return nil, status.Errorf(codes.NotFound, "no charge with transaction ID %s", req.GetTransactionId())
+	}
+	if charged := f.charges[n-1].GetAmount(); !proto.Equal(req.GetAmount(), charged) {
+		return nil, status.Errorf(codes.InvalidArgument, "transaction %s charged %v, which must be refunded in full", req.GetTransactionId(), charged)
+	}
+	if !slices.Contains(f.refunds, req.GetTransactionId()) {
+		f.refunds = append(f.refunds, req.GetTransactionId())
+	}
+	return &pb.RefundResponse{RefundId: "fake-refund-" + req.GetTransactionId()}, nil
+}
+
+// Refunds returns the transaction IDs of the charges refunded so far.
+func (f *Payment) Refunds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.refunds)
+}
+
+// Charges returns the charges accepted so far.
+func (f *Payment) Charges() []*pb.ChargeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*pb.ChargeRequest(nil), f.charges...)
+}
+
+// Inventory is a fake hipstershop.InventoryService. Reservations never
+// expire.
+type Inventory struct {
+	pb.UnimplementedInventoryServiceServer
+
+	mu sync.Mutex
+	// stock is the quantity in stock of each product, less the reserved
+	// quantities; products not in it are never out of stock.
+	stock        map[string]int32
+	reservations map[string]*pb.StockReservation
+	confirmed    []string
+	released     []string
+}
+
+// NewInventory returns a service with stock of each product, and without
+// limit on the others.
+func NewInventory(stock map[string]int32) *Inventory {
+	f := &Inventory{stock: make(map[string]int32), reservations: make(map[string]*pb.StockReservation)}
+	for id, q := range stock {
+		f.stock[id] = q
+	}
+	return f
+}
+
+// Register serves the fake on srv.
+func (f *Inventory) Register(srv *grpc.Server) {
+	pb.RegisterInventoryServiceServer(srv, f)
+}
+
+func (f *Inventory) ReserveStock(_ context.Context, req *pb.ReserveStockRequest) (*pb.StockReservation, error) {
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.reservations[req.GetOrderId()]; ok {
+		return proto.Clone(r).(*pb.StockReservation), nil
+	}
+	want := make(map[string]int32)
+	for _, it := range req.GetItems() {
+		if it.GetProductId() == "" || it.GetQuantity() <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid item %v", it)
+		}
+		want[it.GetProductId()] += it.GetQuantity()
+	}
+	for id, q := range want {
+		if available, ok := f.stock[id]; ok && q > available {
+			return nil, rpcerrors.Errorf(codes.FailedPrecondition, "OUT_OF_STOCK", "only %d of product %s in stock", available, id)
+		}
+	}
+	for id, q := range want {
+		if _, ok := f.stock[id]; ok {
+			f.stock[id] -= q
+		}
+	}
+	r := &pb.StockReservation{OrderId: req.GetOrderId(), Items: req.GetItems(), ExpiresAt: timestamppb.New(time.Now().Add(time.Hour))}
+	f.reservations[req.GetOrderId()] = proto.Clone(r).(*pb.StockReservation)
+	return r, nil
+}
+
+func (f *Inventory) ConfirmReservation(_ context.Context, req *pb.ReservationRequest) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.reservations[req.GetOrderId()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "no reservation for order %s", req.GetOrderId())
+	}
+	delete(f.reservations, req.GetOrderId())
+	f.confirmed = append(f.confirmed, req.GetOrderId())
+	return &pb.Empty{}, nil
+}
+
+func (f *Inventory) ReleaseReservation(_ context.Context, req *pb.ReservationRequest) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, ok := f.reservations[req.GetOrderId()]
+	if !ok {
+		return &pb.Empty{}, nil
+	}
+	for _, it := range r.GetItems() {
+		if _, ok := f.stock[it.GetProductId()]; ok {
+			f.stock[it.GetProductId()] += it.GetQuantity()
+		}
+	}
+	delete(f.reservations, req.GetOrderId())
+	f.released = append(f.released, req.GetOrderId())
+	return &pb.Empty{}, nil
+}
+
+// Stock returns the quantity of productID in stock and not reserved.
+func (f *Inventory) Stock(productID string) int32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stock[productID]
+}
+
+// Confirmed returns the order IDs of the reservations confirmed so far.
+func (f *Inventory) Confirmed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.confirmed)
+}
+
+// Released returns the order IDs of the reservations released so far.
+func (f *Inventory) Released() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.released)
+}
+
+// luhn reports whether number is made of digits and passes the Luhn check.
+func luhn(number string) bool {
+	if len(number) < 12 {
+		return false
+	}
+	sum := 0
+	for i := range number {
+		d := int(number[len(number)-1-i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func isVisa(number string) bool { return strings.HasPrefix(number, "4") }
+
+func isMasterCard(number string) bool {
+	if len(number) < 4 {
+		return false
+	}
+	var prefix int
+	fmt.Sscanf(number[:4], "%d", &prefix)
+	return (prefix >= 5100 && prefix < 5600) || (prefix >= 2221 && prefix < 2721)
+}
@@ -0,0 +1,291 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// Before its card is charged, each order is checked for fraud by the
+// FraudChecker named by FRAUD_CHECK: rules, the default, or off. The rules
+// deny orders over FRAUD_MAX_AMOUNT (default 5000USD, converted to the
+// currency of the order), and orders from an email address that placed
+// FRAUD_MAX_ORDERS_PER_EMAIL (default 10) orders in the last
+// FRAUD_VELOCITY_WINDOW (default 1h), counting those denied and declined; an
+// order billed to another country than it is shipped to is flagged for review
+// but placed. Denied orders fail with PermissionDenied and reason
+// ORDER_REJECTED before anything is reserved or charged.
+//
+// Every decision is recorded in fraud_decisions, with what the order was
+// checked with; the velocity rule counts them, so it only applies with an
+// order store. A checker that fails lets the order through rather than
+// turning customers away, and is logged.
+
+const (
+	fraudCheckRules = "rules"
+	fraudCheckOff   = "off"
+
+	reasonOrderRejected = "ORDER_REJECTED"
+
+	defaultFraudMaxOrders = 10
+	defaultFraudWindow    = time.Hour
+)
+
+// defaultFraudMaxAmount is the FRAUD_MAX_AMOUNT orders are denied above.
+var defaultFraudMaxAmount = pb.Money{CurrencyCode: "USD", Units: 5000}
+
+// FraudOutcome is what is done with an order checked for fraud.
+type FraudOutcome string
+
+const (
+	FraudAllow FraudOutcome = "allow"
+	// FraudReview places the order but flags it for staff to review.
+	FraudReview FraudOutcome = "review"
+	FraudDeny   FraudOutcome = "deny"
+)
+
+// The rules of rulesFraudChecker, named in the reasons of its decisions.
+const (
+	fraudRuleAmount          = "amount"
+	fraudRuleVelocity        = "velocity"
+	fraudRuleCountryMismatch = "country_mismatch"
+)
+
+// FraudCheck is what an order is checked for fraud with.
+type FraudCheck struct {
+	OrderID string
+	UserID  string
+	// Email is the email address of the order, in lower case.
+	Email string
+	// Amount is the total charged for the order.
+	Amount         *pb.Money
+	Address        *pb.Address
+	BillingAddress *pb.Address
+	// CardBIN is the bank identification number of the card, its first six
+	// digits, which name its issuer.
+	CardBIN string
+}
+
+// FraudDecision is what a FraudChecker decided about an order.
+type FraudDecision struct {
+	Outcome FraudOutcome
+	// Reasons name the rules that led to the outcome, none if the order is
+	// allowed.
+	Reasons []string
+}
+
+// FraudChecker decides whether orders are placed before their cards are
+// charged.
+type FraudChecker interface {
+	Check(ctx context.Context, c FraudCheck) (FraudDecision, error)
+}
+
+// fraudHistory counts the orders checked for fraud.
+type fraudHistory interface {
+	// CountFraudDecisions returns how many orders from email were checked
+	// for fraud since then.
+	CountFraudDecisions(ctx context.Context, email string, since time.Time) (int, error)
+}
+
+// rulesFraudChecker is the FraudChecker of FRAUD_CHECK=rules.
+type rulesFraudChecker struct {
+	// maxAmount is the largest total of an order allowed.
+	maxAmount pb.Money
+	// maxOrders is how many orders from an email address are allowed
+	// within window, unless history is nil.
+	maxOrders int
+	window    time.Duration
+	history   fraudHistory
+	// convert converts amounts to the currency of maxAmount.
+	convert func(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error)
+}
+
+// newFraudCheckerFromEnv returns the FraudChecker named by FRAUD_CHECK, or
+// nil if it is off, with history the orders it counts, if any.
+func newFraudCheckerFromEnv(history fraudHistory, convert func(context.Context, *pb.Money, string) (*pb.Money, error)) (FraudChecker, error) {
+	switch v := os.Getenv("FRAUD_CHECK"); v {
+	case "", fraudCheckRules:
+	case fraudCheckOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid FRAUD_CHECK %q: want %s or %s", v, fraudCheckRules, fraudCheckOff)
+	}
+	r := &rulesFraudChecker{
+		maxAmount: defaultFraudMaxAmount,
+		maxOrders: defaultFraudMaxOrders,
+		window:    defaultFraudWindow,
+		history:   history,
+		convert:   convert,
+	}
+	if v := os.Getenv("FRAUD_MAX_AMOUNT"); v != "" {
+		amount, err := parseAmount(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FRAUD_MAX_AMOUNT %q: %v", v, err)
+		}
+		r.maxAmount = *amount
+	}
+	if v := os.Getenv("FRAUD_MAX_ORDERS_PER_EMAIL"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid FRAUD_MAX_ORDERS_PER_EMAIL %q", v)
+		}
+		r.maxOrders = n
+	}
+	if v := os.Getenv("FRAUD_VELOCITY_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid FRAUD_VELOCITY_WINDOW %q", v)
+		}
+		r.window = d
+	}
+	return r, nil
+}
+
+// String describes the rules for the startup log.
+func (r *rulesFraudChecker) String() string {
+	s := fmt.Sprintf("rules, denying orders over %d.%02d %s", r.maxAmount.GetUnits(), r.maxAmount.GetNanos()/1e7, r.maxAmount.GetCurrencyCode())
+	if r.history != nil {
+		s += fmt.Sprintf(" or from emails with %d orders in %v", r.maxOrders, r.window)
+	}
+	return s
+}
+
+func (r *rulesFraudChecker) Check(ctx context.Context, c FraudCheck) (FraudDecision, error) {
+	var d FraudDecision
+	amount := c.Amount
+	if amount.GetCurrencyCode() != r.maxAmount.GetCurrencyCode() {
+		converted, err := r.convert(ctx, amount, r.maxAmount.GetCurrencyCode())
+		if err != nil {
+			return FraudDecision{}, fmt.Errorf("failed to convert the total to %s: %w", r.maxAmount.GetCurrencyCode(), err)
+		}
+		amount = converted
+	}
+	over, err := money.Sum(*amount, money.Negate(r.maxAmount))
+	if err != nil {
+		return FraudDecision{}, err
+	}
+	if money.IsPositive(over) {
+		d.Reasons = append(d.Reasons, fraudRuleAmount)
+	}
+	if r.history != nil && c.Email != "" {
+		n, err := r.history.CountFraudDecisions(ctx, c.Email, time.Now().Add(-r.window))
+		if err != nil {
+			return FraudDecision{}, err
+		}
+		if n >= r.maxOrders {
+			d.Reasons = append(d.Reasons, fraudRuleVelocity)
+		}
+	}
+	if len(d.Reasons) > 0 {
+		d.Outcome = FraudDeny
+	}
+	shipped, billed := c.Address.GetCountry(), c.BillingAddress.GetCountry()
+	if shipped != "" && billed != "" && !strings.EqualFold(shipped, billed) {
+		d.Reasons = append(d.Reasons, fraudRuleCountryMismatch)
+	}
+	if d.Outcome == "" && len(d.Reasons) > 0 {
+		d.Outcome = FraudReview
+	}
+	if d.Outcome == "" {
+		d.Outcome = FraudAllow
+	}
+	return d, nil
+}
+
+// checkFraud checks an order for fraud, records the decision if record is
+// set and fails unless the order is placed.
+func (cs *checkoutService) checkFraud(ctx context.Context, c FraudCheck, record bool) error {
+	if cs.fraud == nil {
+		return nil
+	}
+	d, err := cs.fraud.Check(ctx, c)
+	if err != nil {
+		log.WithContext(ctx).Warnf("failed to check order %s for fraud, placing it anyway: %v", c.OrderID, err)
+		return nil
+	}
+	if cs.orderStore != nil && record {
+		if err := cs.orderStore.RecordFraudDecision(ctx, c, d); err != nil {
+			log.WithContext(ctx).Warnf("failed to record the fraud decision on order %s: %v", c.OrderID, err)
+		}
+	}
+	switch d.Outcome {
+	case FraudDeny:
+		log.WithContext(ctx).Warnf("order %s was rejected by the fraud check: %s", c.OrderID, strings.Join(d.Reasons, ", "))
+		return rpcerrors.Errorf(codes.PermissionDenied, reasonOrderRejected, "the order was rejected by the fraud check")
+	case FraudReview:
+		log.WithContext(ctx).Infof("order %s is flagged for fraud review: %s", c.OrderID, strings.Join(d.Reasons, ", "))
+	}
+	return nil
+}
+
+// records the decision of the fraud check on an order
+func (os *OrderStore) RecordFraudDecision(ctx context.Context, c FraudCheck, d FraudDecision) (err error) {
+	ctx, span := startStoreSpan(ctx, "RecordFraudDecision")
+	defer func() { endSpan(span, err) }()
+	err = withDBTimeout(ctx, os.timeouts.save, "RecordFraudDecision", func(ctx context.Context) error {
+		// a retry records the decision twice if the lost attempt was
+		// committed
+		return retryDB(ctx, "record fraud decision on order "+c.OrderID, func() error {
+			_, err := os.db.ExecContext(ctx, `
+                INSERT INTO fraud_decisions (
+                    order_id, user_id, email, amount_units, amount_nanos, currency_code, country, billing_country,
+                    card_bin, outcome, reasons, created_at
+                ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+            `, c.OrderID, c.UserID, c.Email, c.Amount.GetUnits(), c.Amount.GetNanos(), c.Amount.GetCurrencyCode(),
+				c.Address.GetCountry(), c.BillingAddress.GetCountry(), c.CardBIN, d.Outcome, strings.Join(d.Reasons, ","), time.Now().UTC())
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert fraud decision: %w", err)
+	}
+	return nil
+}
+
+// counts the orders from email checked for fraud since then
+func (os *OrderStore) CountFraudDecisions(ctx context.Context, email string, since time.Time) (_ int, err error) {
+	ctx, span := startStoreSpan(ctx, "CountFraudDecisions")
+	defer func() { endSpan(span, err) }()
+	var n int
+	// decisions are counted on the primary, which the last ones were
+	// written to
+	err = withDBTimeout(ctx, os.timeouts.read, "CountFraudDecisions", func(ctx context.Context) error {
+		return retryDB(ctx, "count fraud decisions", func() error {
+			err := os.db.QueryRowContext(ctx, `
+                SELECT COUNT(*) FROM fraud_decisions WHERE email = $1 AND created_at >= $2
+            `, email, since.UTC()).Scan(&n)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to count fraud decisions: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestFraudCheckerFromEnv(t *testing.T) {
+	c, err := newFraudCheckerFromEnv(nil, nil)
+	if r, ok := c.(*rulesFraudChecker); err != nil || !ok || r.maxAmount.GetUnits() != 5000 || r.maxOrders != defaultFraudMaxOrders || r.window != time.Hour {
+		t.Errorf("newFraudCheckerFromEnv() = %+v, %v; want the default rules", c, err)
+	}
+	t.Setenv("FRAUD_MAX_AMOUNT", "250.50EUR")
+	t.Setenv("FRAUD_MAX_ORDERS_PER_EMAIL", "3")
+	t.Setenv("FRAUD_VELOCITY_WINDOW", "10m")
+	c, err = newFraudCheckerFromEnv(nil, nil)
+	if r, ok := c.(*rulesFraudChecker); err != nil || !ok || r.maxAmount.GetCurrencyCode() != "EUR" || r.maxAmount.GetNanos() != 500000000 || r.maxOrders != 3 || r.window != 10*time.Minute {
+		t.Errorf("newFraudCheckerFromEnv() = %+v, %v; want the configured rules", c, err)
+	}
+	t.Setenv("FRAUD_CHECK", "off")
+	if c, err := newFraudCheckerFromEnv(nil, nil); c != nil || err != nil {
+		t.Errorf("newFraudCheckerFromEnv() with FRAUD_CHECK=off = %v, %v; want nil", c, err)
+	}
+	for key, v := range map[string]string{"FRAUD_CHECK": "strict", "FRAUD_MAX_AMOUNT": "lots", "FRAUD_MAX_ORDERS_PER_EMAIL": "0", "FRAUD_VELOCITY_WINDOW": "-1h"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv("FRAUD_CHECK", "")
+			t.Setenv(key, v)
+			if _, err := newFraudCheckerFromEnv(nil, nil); err == nil {
+				t.Errorf("newFraudCheckerFromEnv() with %s=%q succeeded", key, v)
+			}
+		})
+	}
+}
+
+func TestRulesFraudChecker(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryOrderStore()
+	r := &rulesFraudChecker{
+		maxAmount: pb.Money{CurrencyCode: "USD", Units: 100},
+		maxOrders: 2,
+		window:    time.Hour,
+		history:   store,
+		convert: func(_ context.Context, from *pb.Money, to string) (*pb.Money, error) {
+			if from.GetCurrencyCode() != "EUR" {
+				return nil, errors.New("no rate")
+			}
+			return &pb.Money{CurrencyCode: to, Units: from.GetUnits() * 2}, nil
+		},
+	}
+	us := &pb.Address{Country: "US"}
+	for _, tt := range []struct {
+		name   string
+		check  FraudCheck
+		want   FraudOutcome
+		reason []string
+	}{
+		{"under the limit", FraudCheck{Email: "a@example.com", Amount: &pb.Money{CurrencyCode: "USD", Units: 100}, Address: us, BillingAddress: us}, FraudAllow, nil},
+		{"over the limit", FraudCheck{Email: "a@example.com", Amount: &pb.Money{CurrencyCode: "USD", Units: 100, Nanos: 1}, Address: us, BillingAddress: us}, FraudDeny, []string{fraudRuleAmount}},
+		{"over the limit once converted", FraudCheck{Email: "a@example.com", Amount: &pb.Money{CurrencyCode: "EUR", Units: 60}, Address: us, BillingAddress: us}, FraudDeny, []string{fraudRuleAmount}},
+		{"billed abroad", FraudCheck{Email: "a@example.com", Amount: &pb.Money{CurrencyCode: "USD", Units: 10}, Address: us, BillingAddress: &pb.Address{Country: "Canada"}}, FraudReview, []string{fraudRuleCountryMismatch}},
+		{"same country in another case", FraudCheck{Email: "a@example.com", Amount: &pb.Money{CurrencyCode: "USD", Units: 10}, Address: us, BillingAddress: &pb.Address{Country: "us"}}, FraudAllow, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := r.Check(ctx, tt.check)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Outcome != tt.want || !slices.Equal(d.Reasons, tt.reason) {
+				t.Errorf("Check() = %+v, want %s for %v", d, tt.want, tt.reason)
+			}
+		})
+	}
+	if _, err := r.Check(ctx, FraudCheck{Amount: &pb.Money{CurrencyCode: "JPY", Units: 10}}); err == nil {
+		t.Error("Check() of an amount that cannot be converted succeeded")
+	}
+
+	check := FraudCheck{Email: "b@example.com", Amount: &pb.Money{CurrencyCode: "USD", Units: 10}}
+	for range r.maxOrders {
+		if err := store.RecordFraudDecision(ctx, check, FraudDecision{Outcome: FraudAllow}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d, _ := r.Check(ctx, check); d.Outcome != FraudDeny || !slices.Equal(d.Reasons, []string{fraudRuleVelocity}) {
+		t.Errorf("Check() after %d orders from the email = %+v, want it denied for velocity", r.maxOrders, d)
+	}
+	r.window = 0
+	if d, _ := r.Check(ctx, check); d.Outcome != FraudAllow {
+		t.Errorf("Check() once the orders are out of the window = %+v, want it allowed", d)
+	}
+}
+
+func TestPlaceOrderRejectedByFraudCheck(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newSQLiteStore(t)
+	cs.orderStore = store
+	cs.fraud = &rulesFraudChecker{maxAmount: pb.Money{CurrencyCode: "USD", Units: 1}, maxOrders: defaultFraudMaxOrders, window: time.Hour, history: store}
+	req := placeOrderRequest("key-1")
+	req.Email = "Someone@Example.com"
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	_, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+	if c := rpcerrors.Classify(err); c.Code != codes.PermissionDenied || c.Reason != reasonOrderRejected {
+		t.Fatalf("PlaceOrder() over the fraud limit = %v, want PermissionDenied %s", err, reasonOrderRejected)
+	}
+	if charges := len(backends.payment.Charges()); charges != 0 {
+		t.Errorf("%d charges, want none for a rejected order", charges)
+	}
+	if n, err := store.CountFraudDecisions(ctx, "someone@example.com", time.Now().Add(-time.Minute)); err != nil || n != 1 {
+		t.Errorf("CountFraudDecisions() = %d, %v; want the decision recorded", n, err)
+	}
+	if _, err := store.DeleteUserData(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := store.CountFraudDecisions(ctx, "someone@example.com", time.Time{}); n != 0 {
+		t.Errorf("%d fraud decisions left after erasing the user, want none", n)
+	}
+}
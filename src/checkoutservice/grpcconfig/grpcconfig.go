@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcconfig tunes the compression and keepalive behavior of gRPC
+// servers and clients, and turns on their diagnostic services, from the
+// environment, so that they can be adjusted per environment without code
+// changes. Everything is off unless set:
+//
+//   - GRPC_COMPRESSION=gzip makes clients compress their requests. Servers
+//     always accept gzip and answer compressed requests in kind.
+//   - GRPC_KEEPALIVE_TIME and GRPC_KEEPALIVE_TIMEOUT make servers ping idle
+//     clients and drop those that do not answer in time.
+//   - GRPC_MAX_CONNECTION_IDLE, GRPC_MAX_CONNECTION_AGE and
+//     GRPC_MAX_CONNECTION_AGE_GRACE make servers close idle or old
+//     connections, so that clients reconnect and rebalance across replicas.
+//   - GRPC_KEEPALIVE_MIN_TIME is the shortest ping interval servers allow
+//     clients (5m by default in gRPC); clients pinging more often are
+//     disconnected.
+//   - GRPC_CLIENT_KEEPALIVE_TIME and GRPC_CLIENT_KEEPALIVE_TIMEOUT make
+//     clients ping servers to detect dead connections, and GRPC_IDLE_TIMEOUT
+//     is how long a client connection may be unused before it is closed.
+//   - ENABLE_GRPC_REFLECTION=1 registers the server reflection service, which
+//     lets tools such as grpcurl list and call the server's methods without
+//     its .proto files.
+//   - ENABLE_CHANNELZ=1 registers the channelz service, which reports the
+//     state of the server's and its clients' connections and calls.
+//
+// Both diagnostic services reveal the service's internals and are meant for
+// development clusters.
+//
+// Durations use Go syntax, such as "30s" or "5m".
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package grpcconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	channelzsvc "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+)
+
+// Config holds the gRPC tuning settings. A nil *Config applies none.
+type Config struct {
+	// Compressor is the name of the compressor clients use, if any.
+	Compressor string
+
+	Server      keepalive.ServerParameters
+	Enforcement keepalive.EnforcementPolicy
+	Client      keepalive.ClientParameters
+	IdleTimeout time.Duration
+
+	Reflection bool
+	Channelz   bool
+}
+
+// FromEnv reads the settings from the environment.
+func FromEnv() (*Config, error) {
+	c := new(Config)
+	switch v := strings.ToLower(os.Getenv("GRPC_COMPRESSION")); v {
+	case "", "none":
+	case gzip.Name:
+		c.Compressor = gzip.Name
+	default:
+		return nil, fmt.Errorf("unsupported GRPC_COMPRESSION %q, want gzip or none", v)
+	}
+	for _, d := range []struct {
+		v   *time.Duration
+		key string
+	}{
+		{&c.Server.Time, "GRPC_KEEPALIVE_TIME"},
+		{&c.Server.Timeout, "GRPC_KEEPALIVE_TIMEOUT"},
+		{&c.Server.MaxConnectionIdle, "GRPC_MAX_CONNECTION_IDLE"},
+		{&c.Server.MaxConnectionAge, "GRPC_MAX_CONNECTION_AGE"},
+		{&c.Server.MaxConnectionAgeGrace, "GRPC_MAX_CONNECTION_AGE_GRACE"},
+		{&c.Enforcement.MinTime, "GRPC_KEEPALIVE_MIN_TIME"},
+		{&c.Client.Time, "GRPC_CLIENT_KEEPALIVE_TIME"},
+		{&c.Client.Timeout, "GRPC_CLIENT_KEEPALIVE_TIMEOUT"},
+		{&c.IdleTimeout, "GRPC_IDLE_TIMEOUT"},
+	} {
+		v := os.Getenv(d.key)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid %s %q: want a positive duration", d.key, v)
+		}
+		*d.v = parsed
+	}
+	// Let clients that ping as often as this service's own clients do keep
+	// their connections, even without a stream open.
+	if c.Enforcement.MinTime == 0 && c.Client.Time > 0 {
+		c.Enforcement.MinTime = c.Client.Time
+	}
+	c.Enforcement.PermitWithoutStream = c.Client.Time > 0
+	c.Reflection = os.Getenv("ENABLE_GRPC_REFLECTION") == "1"
+	c.Channelz = os.Getenv("ENABLE_CHANNELZ") == "1"
+	return c, nil
+}
+
+// ServerOptions returns the options that apply c to a server.
+func (c *Config) ServerOptions() []grpc.ServerOption {
+	if c == nil {
+		return nil
+	}
+	var opts []grpc.ServerOption
+	if c.Server != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(c.Server))
+	}
+	if c.Enforcement != (keepalive.EnforcementPolicy{}) {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(c.Enforcement))
+	}
+	return opts
+}
+
+// RegisterDiagnostics registers the diagnostic services c enables on srv.
+func (c *Config) RegisterDiagnostics(srv *grpc.Server) {
+	if c == nil {
+		return
+	}
+	if c.Reflection {
+		reflection.Register(srv)
+	}
+	if c.Channelz {
+		channelzsvc.RegisterChannelzServiceToServer(srv)
+	}
+}
+
+// DialOptions returns the options that apply c to a client connection.
+func (c *Config) DialOptions() []grpc.DialOption {
+	if c == nil {
+		return nil
+	}
+	var opts []grpc.DialOption
+	if c.Compressor != "" {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(c.Compressor)))
+	}
+	if c.Client != (keepalive.ClientParameters{}) {
+		opts = append(opts, grpc.WithKeepaliveParams(c.Client))
+	}
+	if c.IdleTimeout > 0 {
+		opts = append(opts, grpc.WithIdleTimeout(c.IdleTimeout))
+	}
+	return opts
+}
+
+// String summarizes c for the startup log.
+func (c *Config) String() string {
+	if c == nil {
+		return "defaults"
+	}
+	var parts []string
+	if c.Compressor != "" {
+		parts = append(parts, "compression="+c.Compressor)
+	}
+	for _, d := range []struct {
+		name string
+		v    time.Duration
+	}{
+		{"keepalive_time", c.Server.Time},
+		{"keepalive_timeout", c.Server.Timeout},
+		{"max_connection_idle", c.Server.MaxConnectionIdle},
+		{"max_connection_age", c.Server.MaxConnectionAge},
+		{"max_connection_age_grace", c.Server.MaxConnectionAgeGrace},
+		{"keepalive_min_time", c.Enforcement.MinTime},
+		{"client_keepalive_time", c.Client.Time},
+		{"client_keepalive_timeout", c.Client.Timeout},
+		{"idle_timeout", c.IdleTimeout},
+	} {
+		if d.v > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%v", d.name, d.v))
+		}
+	}
+	if c.Reflection {
+		parts = append(parts, "reflection")
+	}
+	if c.Channelz {
+		parts = append(parts, "channelz")
+	}
+	if len(parts) == 0 {
+		return "defaults"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// When the service shuts down, the orders being placed are drained once it is
+// unready (see package lifecycle): new orders fail with Unavailable and
+// reason SHUTTING_DOWN, so that the frontend places them with another pod
+// under the same idempotency key, and shutdown waits, for as long as the
+// grace period allows, for the orders in flight to be recorded before the
+// order events are flushed and the database pool is closed. An order whose
+// card is charged is finished, or compensated, within orderFinishTimeout even
+// if its caller gives up or the server stops its RPC, since stopping midway
+// would leave a charge without an order.
+
+const (
+	reasonShuttingDown = "SHUTTING_DOWN"
+
+	orderFinishTimeout = 30 * time.Second
+)
+
+// inFlightOrders counts the orders being placed.
+type inFlightOrders struct {
+	mu       sync.Mutex
+	n        int
+	draining bool
+	// idle is closed once draining starts and no order is in flight.
+	idle chan struct{}
+}
+
+func newInFlightOrders() *inFlightOrders {
+	return &inFlightOrders{idle: make(chan struct{})}
+}
+
+// begin counts an order as placed until done is called, failing instead with
+// SHUTTING_DOWN if the orders are being drained. A nil inFlightOrders counts
+// nothing.
+func (o *inFlightOrders) begin() (done func(), err error) {
+	if o == nil {
+		return func() {}, nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.draining {
+		return nil, rpcerrors.Errorf(codes.Unavailable, reasonShuttingDown, "the service is shutting down")
+	}
+	o.n++
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.n--; o.n == 0 && o.draining {
+			close(o.idle)
+		}
+	}, nil
+}
+
+// drain fails new orders, and waits until the orders in flight are done or
+// ctx is.
+func (o *inFlightOrders) drain(ctx context.Context) error {
+	o.mu.Lock()
+	if !o.draining {
+		o.draining = true
+		if o.n == 0 {
+			close(o.idle)
+		}
+	}
+	o.mu.Unlock()
+	select {
+	case <-o.idle:
+		return nil
+	case <-ctx.Done():
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return fmt.Errorf("grace period ran out with %d orders still being placed", o.n)
+	}
+}
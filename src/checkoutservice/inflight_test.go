@@ -0,0 +1,133 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestInFlightOrdersDrain(t *testing.T) {
+	o := newInFlightOrders()
+	done, err := o.begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drained := make(chan error)
+	go func() { drained <- o.drain(context.Background()) }()
+
+	// wait for drain to start refusing orders
+	for {
+		d, err := o.begin()
+		if err != nil {
+			if c := rpcerrors.Classify(err); c.Code != codes.Unavailable || c.Reason != reasonShuttingDown {
+				t.Fatalf("begin() while draining = %v, want Unavailable %s", err, reasonShuttingDown)
+			}
+			break
+		}
+		d()
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("drain() = %v with an order in flight", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	done()
+	if err := <-drained; err != nil {
+		t.Errorf("drain() = %v, want nil once the order is done", err)
+	}
+}
+
+func TestInFlightOrdersDrainTimesOut(t *testing.T) {
+	o := newInFlightOrders()
+	if _, err := o.begin(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := o.drain(ctx); err == nil {
+		t.Error("drain() succeeded with an order still in flight")
+	}
+}
+
+func TestPlaceOrderRefusedWhileDraining(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	cs.inFlight = newInFlightOrders()
+	if err := cs.inFlight.drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := cs.placeOrder(context.Background(), orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	})
+	if rpcerrors.Classify(err).Reason != reasonShuttingDown {
+		t.Fatalf("placeOrder() while draining = %v, want %s", err, reasonShuttingDown)
+	}
+	if charges := backends.payment.Charges(); len(charges) != 0 {
+		t.Errorf("charges = %v, want none", charges)
+	}
+}
+
+func TestChargedOrderFinishedAfterCallerGivesUp(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	// the caller gives up once the card is charged
+	rec := &cancellingProvider{ChargeProvider: cs.payments, cancel: cancel}
+	cs.payments = rec
+	order, _, err := cs.placeOrder(ctx, orderRequest{
+		userID:       "user-1",
+		userCurrency: "USD",
+		address:      &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", Country: "United States"},
+		email:        "someone@example.com",
+		card:         contract.ValidCard(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetOrder(context.Background(), order.GetOrderId()); err != nil {
+		t.Errorf("order charged before its caller gave up was not recorded: %v", err)
+	}
+}
+
+// cancellingProvider is a ChargeProvider that cancels the context of the
+// order once its card is charged.
+type cancellingProvider struct {
+	ChargeProvider
+	cancel context.CancelFunc
+}
+
+func (p *cancellingProvider) Charge(ctx context.Context, orderID, idempotencyKey string, amount *pb.Money, card cardvault.Card) (string, error) {
+	txID, err := p.ChargeProvider.Charge(ctx, orderID, idempotencyKey, amount, card)
+	p.cancel()
+	return txID, err
+}
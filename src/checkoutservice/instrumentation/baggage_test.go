@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHashID(t *testing.T) {
+	t.Setenv("TELEMETRY_HASH_KEY", "key-1")
+	h := HashID("user-1")
+	if len(h) != 16 || h == "user-1" {
+		t.Errorf("HashID(%q) = %q, want 16 hex digits", "user-1", h)
+	}
+	if HashID("user-1") != h {
+		t.Error("HashID is not stable")
+	}
+	if HashID("user-2") == h {
+		t.Error("HashID gives different IDs the same hash")
+	}
+	if HashID("") != "" {
+		t.Error("HashID of an empty ID is not empty")
+	}
+	t.Setenv("TELEMETRY_HASH_KEY", "key-2")
+	if HashID("user-1") == h {
+		t.Error("HashID ignores TELEMETRY_HASH_KEY")
+	}
+}
+
+func TestBaggageSpanProcessor(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{}),
+		sdktrace.WithSpanProcessor(rec))
+	defer tp.Shutdown(context.Background())
+
+	ctx := WithBaggage(context.Background(), map[string]string{
+		BaggageUserHash:         "0123456789abcdef",
+		BaggageExperimentBucket: "3",
+		BaggageSessionHash:      "",
+		BaggageOrderID:          "order-1",
+		"not valid baggage":     "x",
+	})
+	_, span := tp.Tracer("test").Start(ctx, "op")
+	span.End()
+
+	got := map[attribute.Key]string{}
+	for _, kv := range rec.Ended()[0].Attributes() {
+		got[kv.Key] = kv.Value.AsString()
+	}
+	want := map[attribute.Key]string{
+		BaggageUserHash:         "0123456789abcdef",
+		BaggageExperimentBucket: "3",
+		BaggageOrderID:          "order-1",
+	}
+	if len(got) != len(want) {
+		t.Errorf("span attributes = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("span attribute %s = %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instrumentation sets up OpenTelemetry for a service: traces and
+// metrics exported over OTLP, and the same metrics plus Go runtime stats
+// served in the Prometheus format.
+//
+// gRPC and HTTP metrics come from the otelgrpc stats handlers and otelhttp,
+// which record server latency and status codes (rpc.server.duration,
+// http.server.request.duration) as well as outgoing dependency calls
+// (rpc.client.duration, http.client.request.duration).
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// DefaultMetricsPort is where services serve /metrics unless METRICS_PORT
+// says otherwise.
+const DefaultMetricsPort = "9464"
+
+// Provider owns the telemetry pipeline of a service.
+type Provider struct {
+	tp      *sdktrace.TracerProvider
+	mp      *sdkmetric.MeterProvider
+	metrics http.Handler
+}
+
+// Init installs global tracer and meter providers for service.
+//
+// Metrics are always available in the Prometheus format through
+// MetricsHandler. When otlp is set, traces and metrics are also exported over
+// OTLP/gRPC to COLLECTOR_SERVICE_ADDR, or to the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT when that is unset. Sampling follows
+// OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG and defaults to
+// parentbased_always_on, so upstream sampling decisions are honoured. Spans
+// are annotated with the cohort baggage of their request (see BaggageUserHash)
+// and have personal data masked by the redact package before export.
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the resource.
+//
+// Measurements taken inside a sampled span keep it as an exemplar. Prometheus
+// only exposes exemplars to scrapers that negotiate the OpenMetrics format.
+func Init(ctx context.Context, service string, otlp bool) (*Provider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(service)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry resource: %w", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	promExporter, err := otelprom.New(otelprom.WithRegisterer(reg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+	metricOpts := []sdkmetric.Option{
+		sdkmetric.WithReader(promExporter),
+		sdkmetric.WithResource(res),
+		sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter),
+	}
+
+	p := &Provider{
+		metrics: promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	}
+	if otlp {
+		var (
+			traceExporterOpts  []otlptracegrpc.Option
+			metricExporterOpts []otlpmetricgrpc.Option
+		)
+		if addr := os.Getenv("COLLECTOR_SERVICE_ADDR"); addr != "" {
+			traceExporterOpts = append(traceExporterOpts, otlptracegrpc.WithEndpoint(addr), otlptracegrpc.WithInsecure())
+			metricExporterOpts = append(metricExporterOpts, otlpmetricgrpc.WithEndpoint(addr), otlpmetricgrpc.WithInsecure())
+		}
+		traceExporter, err := otlptracegrpc.New(ctx, traceExporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		}
+		metricExporter, err := otlpmetricgrpc.New(ctx, metricExporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		}
+		p.tp = sdktrace.NewTracerProvider(
+			sdktrace.WithSpanProcessor(baggageSpanProcessor{}),
+			sdktrace.WithSpanProcessor(redactingProcessor{sdktrace.NewBatchSpanProcessor(traceExporter)}),
+			sdktrace.WithResource(res))
+		otel.SetTracerProvider(p.tp)
+		metricOpts = append(metricOpts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))
+	}
+	p.mp = sdkmetric.NewMeterProvider(metricOpts...)
+	otel.SetMeterProvider(p.mp)
+	return p, nil
+}
+
+// MetricsHandler serves the service's metrics in the Prometheus format.
+func (p *Provider) MetricsHandler() http.Handler {
+	return p.metrics
+}
+
+// ServeMetrics serves MetricsHandler at /metrics on addr in the background.
+// It only fails if addr cannot be listened on.
+func (p *Provider) ServeMetrics(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.metrics)
+	go http.Serve(lis, mux)
+	return nil
+}
+
+// Shutdown flushes pending telemetry and stops the exporters.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	var errs []error
+	if p.tp != nil {
+		errs = append(errs, p.tp.Shutdown(ctx))
+	}
+	errs = append(errs, p.mp.Shutdown(ctx))
+	return errors.Join(errs...)
+}
+
+// MetricsAddr returns the address to serve metrics on: METRICS_PORT, or
+// DefaultMetricsPort when unset.
+func MetricsAddr() string {
+	port := os.Getenv("METRICS_PORT")
+	if port == "" {
+		port = DefaultMetricsPort
+	}
+	return ":" + port
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// With INVENTORY_SERVICE_ADDR set, PlaceOrder reserves the items of an order
+// with hipstershop.InventoryService before charging the card, so that
+// concurrent orders cannot sell more than is in stock. An order for more
+// than is available fails with OUT_OF_STOCK, and one that cannot be reserved
+// at all fails too, rather than risk overselling. The reservation is
+// confirmed once the order is recorded, or queued to be, and released when
+// the order fails; one left behind by a checkout that crashed expires in the
+// inventory service.
+
+const reasonOutOfStock = "OUT_OF_STOCK"
+
+// reserveStock reserves the items of orderID, and returns the func releasing
+// the reservation, or nil without an inventory service.
+func (cs *checkoutService) reserveStock(ctx context.Context, orderID string, items []*pb.CartItem) (func(), error) {
+	if cs.inventorySvcConn == nil {
+		return nil, nil
+	}
+	cl := pb.NewInventoryServiceClient(cs.inventorySvcConn)
+	if _, err := cl.ReserveStock(ctx, &pb.ReserveStockRequest{OrderId: orderID, Items: items}); err != nil {
+		if c := rpcerrors.Classify(err); c.Reason == reasonOutOfStock {
+			return nil, rpcerrors.Errorf(codes.FailedPrecondition, reasonOutOfStock, "%s; remove it from the cart and place the order again", status.Convert(err).Message())
+		}
+		return nil, status.Errorf(codes.Unavailable, "failed to reserve stock: %v", err)
+	}
+	return func() {
+		if _, err := cl.ReleaseReservation(context.WithoutCancel(ctx), &pb.ReservationRequest{OrderId: orderID}); err != nil {
+			log.WithContext(ctx).Warnf("failed to release the stock reserved for order %s, leaving it to expire: %v", orderID, err)
+		}
+	}, nil
+}
+
+// confirmStock confirms the stock reservation of a recorded order.
+func (cs *checkoutService) confirmStock(ctx context.Context, orderID string) {
+	if cs.inventorySvcConn == nil {
+		return
+	}
+	cl := pb.NewInventoryServiceClient(cs.inventorySvcConn)
+	if _, err := cl.ConfirmReservation(ctx, &pb.ReservationRequest{OrderId: orderID}); err != nil {
+		log.WithContext(ctx).Warnf("failed to confirm the stock reserved for order %s: %v", orderID, err)
+	}
+}
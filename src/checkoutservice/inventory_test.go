@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestStockReservations(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	inventory := fakes.NewInventory(map[string]int32{"OLJCESPC7Z": 2})
+	cs.inventorySvcConn = contract.Serve(t, func(srv *grpc.Server) { inventory.Register(srv) })
+	s := newCheckoutServiceV2(cs)
+	place := func(key string, quantity int32, card *pb.CreditCardInfo) (*pbv2.PlaceOrderResponse, error) {
+		t.Helper()
+		if _, err := backends.cart.EmptyCart(ctx, &pb.EmptyCartRequest{UserId: "user-1"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: quantity}}); err != nil {
+			t.Fatal(err)
+		}
+		req := placeOrderRequest(key)
+		req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: card}}
+		return s.PlaceOrder(ctx, req)
+	}
+
+	placed, err := place("key-1", 1, contract.ValidCard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(inventory.Confirmed(), placed.GetOrder().GetOrderId()) {
+		t.Errorf("reservation of order %s not confirmed; confirmed %v", placed.GetOrder().GetOrderId(), inventory.Confirmed())
+	}
+	if got := inventory.Stock("OLJCESPC7Z"); got != 1 {
+		t.Errorf("stock after an order = %d, want 1", got)
+	}
+
+	// an order that fails gives its stock back
+	expired := contract.ValidCard()
+	expired.CreditCardExpirationYear--
+	expired.CreditCardExpirationYear--
+	if _, err := place("key-2", 1, expired); err == nil {
+		t.Fatal("PlaceOrder() with an expired card succeeded")
+	}
+	if len(inventory.Released()) != 1 {
+		t.Errorf("released %d reservations after a failed order, want 1", len(inventory.Released()))
+	}
+	if got := inventory.Stock("OLJCESPC7Z"); got != 1 {
+		t.Errorf("stock after a failed order = %d, want 1", got)
+	}
+
+	if _, err := place("key-3", 2, contract.ValidCard()); rpcerrors.Classify(err).Reason != reasonOutOfStock {
+		t.Fatalf("PlaceOrder() for more than is in stock = %v, want %s", err, reasonOutOfStock)
+	}
+	if charges := len(backends.payment.Charges()); charges != 1 {
+		t.Errorf("%d charges, want only the first order's", charges)
+	}
+}
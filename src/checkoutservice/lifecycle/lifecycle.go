@@ -0,0 +1,224 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lifecycle shuts a service down gracefully when it receives SIGTERM
+// or SIGINT.
+//
+// Shutdown runs in three phases, each logged as it goes:
+//
+//  1. The service marks itself unready, by running the hooks registered with
+//     OnUnready, and then waits SHUTDOWN_DRAIN_DELAY (5s by default) so that
+//     load balancers and Kubernetes endpoints stop sending it new requests.
+//  2. Servers registered with OnDrain finish their in-flight requests,
+//     concurrently.
+//  3. Hooks registered with OnClose flush and release what the servers used,
+//     such as telemetry exporters and database pools, in the reverse of the
+//     order they were registered in, like deferred calls.
+//
+// All three phases share SHUTDOWN_GRACE_PERIOD (25s by default, to fit in the
+// 30s Kubernetes gives a pod by default). Once it runs out, servers still
+// draining are stopped forcibly and the remaining hooks get a cancelled
+// context.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+const (
+	defaultGracePeriod = 25 * time.Second
+	defaultDrainDelay  = 5 * time.Second
+)
+
+// Func stops or releases something, giving up when ctx is done.
+type Func func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Func
+}
+
+// Manager runs the shutdown hooks of a service.
+type Manager struct {
+	log         *logging.Logger
+	gracePeriod time.Duration
+	drainDelay  time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	unready []func()
+	drain   []hook
+	close   []hook
+}
+
+// New returns a Manager configured from SHUTDOWN_GRACE_PERIOD and
+// SHUTDOWN_DRAIN_DELAY.
+func New(log *logging.Logger) *Manager {
+	m := &Manager{
+		log:         log,
+		gracePeriod: durationEnv(log, "SHUTDOWN_GRACE_PERIOD", defaultGracePeriod),
+		drainDelay:  durationEnv(log, "SHUTDOWN_DRAIN_DELAY", defaultDrainDelay),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	return m
+}
+
+func durationEnv(log *logging.Logger, key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Warnf("invalid %s %q, using %v", key, v, fallback)
+		return fallback
+	}
+	return d
+}
+
+// Context returns a context that is cancelled once shutdown starts, for
+// background work that should stop taking on new jobs.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnUnready registers fn to mark the service unready when shutdown starts.
+func (m *Manager) OnUnready(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unready = append(m.unready, fn)
+}
+
+// OnDrain registers a server to drain once the service is unready.
+func (m *Manager) OnDrain(name string, fn Func) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drain = append(m.drain, hook{name, fn})
+}
+
+// OnClose registers fn to run after the servers have drained.
+func (m *Manager) OnClose(name string, fn Func) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.close = append(m.close, hook{name, fn})
+}
+
+// Wait blocks until the process receives SIGTERM or SIGINT and then shuts
+// the service down.
+func (m *Manager) Wait() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-sigs
+	signal.Stop(sigs)
+	m.log.Infof("received %v, shutting down (grace period %v)", sig, m.gracePeriod)
+	m.Shutdown()
+}
+
+// Shutdown runs the shutdown phases and returns once they are done or the
+// grace period has run out.
+func (m *Manager) Shutdown() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), m.gracePeriod)
+	defer cancel()
+	m.cancel()
+
+	m.mu.Lock()
+	unready := append([]func(){}, m.unready...)
+	drain := append([]hook{}, m.drain...)
+	closers := append([]hook{}, m.close...)
+	m.mu.Unlock()
+
+	for _, fn := range unready {
+		fn()
+	}
+	if m.drainDelay > 0 {
+		m.log.Infof("marked unready, waiting %v for traffic to stop", m.drainDelay)
+		select {
+		case <-time.After(m.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, h := range drain {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.run(ctx, "drained", h)
+		}()
+	}
+	wg.Wait()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		m.run(ctx, "closed", closers[i])
+	}
+	m.log.Infof("shutdown complete after %v", time.Since(start).Round(time.Millisecond))
+}
+
+func (m *Manager) run(ctx context.Context, done string, h hook) {
+	start := time.Now()
+	if err := h.fn(ctx); err != nil {
+		m.log.Warnf("%s: %v", h.name, err)
+		return
+	}
+	m.log.Infof("%s %s in %v", done, h.name, time.Since(start).Round(time.Millisecond))
+}
+
+// GRPCServer returns a Func that stops srv gracefully, letting in-flight RPCs
+// finish, and forcibly if ctx is done first. It does not wait for the forced
+// stop, which lasts as long as the handlers that ignore their cancellation.
+func GRPCServer(srv *grpc.Server) Func {
+	return func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			go srv.Stop()
+			return errors.New("grace period ran out, closing remaining RPCs")
+		}
+	}
+}
+
+// HTTPServer returns a Func that shuts srv down gracefully, letting in-flight
+// requests finish, and forcibly if ctx is done first.
+func HTTPServer(srv *http.Server) Func {
+	return func(ctx context.Context) error {
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+			return errors.New("grace period ran out, closed remaining connections")
+		}
+		return nil
+	}
+}
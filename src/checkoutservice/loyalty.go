@@ -0,0 +1,328 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// Users earn loyalty points with their orders when LOYALTY_CURRENCY names the
+// currency of the loyalty program: pointsPerUnitEarned points per whole unit
+// of it that the items of an order cost after discounts. They redeem them as
+// a discount on the items of a later order, pointsPerUnitRedeemed points for
+// a unit of it, or through hipstershop.v2.LoyaltyService for rewards granted
+// outside of orders. Amounts are converted between the currency of the order
+// and that of the program.
+//
+// The balance of each user is kept in user_points and changed in the
+// transaction that saves an order, which fails with errInsufficientPoints if
+// the user no longer holds the points it redeems. The balance is checked
+// before the card is charged too, so that only an order racing another
+// redemption is compensated. Cancelling an order gives back the points it
+// redeemed and takes back those it earned, without taking the balance below
+// zero. Replicas apply the points of the orders they replicate, but not
+// redemptions outside of orders.
+
+const (
+	// pointsPerUnitEarned are the points earned per unit of the loyalty
+	// currency spent.
+	pointsPerUnitEarned = 1
+	// pointsPerUnitRedeemed are the points that take one unit of the
+	// loyalty currency off an order.
+	pointsPerUnitRedeemed = 100
+)
+
+// errInsufficientPoints is returned when a user redeems more points than
+// they hold.
+var errInsufficientPoints = errors.New("not enough loyalty points")
+
+var currencyCodeRE = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// loyaltyProgram is the loyalty program of LOYALTY_CURRENCY.
+type loyaltyProgram struct {
+	currency string
+}
+
+// newLoyaltyProgramFromEnv returns the loyalty program of LOYALTY_CURRENCY,
+// or nil if it is not set.
+func newLoyaltyProgramFromEnv() (*loyaltyProgram, error) {
+	v := os.Getenv("LOYALTY_CURRENCY")
+	if v == "" {
+		return nil, nil
+	}
+	if !currencyCodeRE.MatchString(v) {
+		return nil, fmt.Errorf("invalid LOYALTY_CURRENCY %q: want a currency code such as USD", v)
+	}
+	return &loyaltyProgram{currency: v}, nil
+}
+
+func (l *loyaltyProgram) String() string { return l.currency }
+
+// value returns what points take off an order, in the loyalty currency.
+func (l *loyaltyProgram) value(points int64) pb.Money {
+	return pb.Money{
+		CurrencyCode: l.currency,
+		Units:        points / pointsPerUnitRedeemed,
+		Nanos:        int32(points % pointsPerUnitRedeemed * (1e9 / pointsPerUnitRedeemed)),
+	}
+}
+
+// convertPoints converts m to currency, unless it is in it already.
+func (cs *checkoutService) convertPoints(ctx context.Context, m pb.Money, currency string) (pb.Money, error) {
+	if m.GetCurrencyCode() == currency {
+		return m, nil
+	}
+	converted, err := cs.convertCurrency(ctx, &m, currency)
+	if err != nil {
+		return pb.Money{}, err
+	}
+	return *converted, nil
+}
+
+// pointsDiscount returns what redeeming points of userID takes off items
+// priced subtotal, in its currency, failing unless the user holds the points
+// and they take off no more than the items cost.
+func (cs *checkoutService) pointsDiscount(ctx context.Context, userID string, points int64, subtotal pb.Money) (pb.Money, error) {
+	if cs.loyalty == nil {
+		return pb.Money{}, errLoyaltyNotOffered()
+	}
+	if points < 0 {
+		return pb.Money{}, status.Error(codes.InvalidArgument, "redeem_points is negative")
+	}
+	balance, err := cs.orderStore.GetPointsBalance(ctx, userID)
+	if err != nil {
+		return pb.Money{}, status.Errorf(codes.Unavailable, "failed to get loyalty points: %v", err)
+	}
+	if balance < points {
+		return pb.Money{}, errNotEnoughPoints(balance, points)
+	}
+	discount, err := cs.convertPoints(ctx, cs.loyalty.value(points), subtotal.GetCurrencyCode())
+	if err != nil {
+		return pb.Money{}, status.Errorf(codes.Internal, "failed to convert the value of loyalty points: %v", err)
+	}
+	if left, err := money.Sum(subtotal, money.Negate(discount)); err != nil || money.IsNegative(left) {
+		return pb.Money{}, rpcerrors.Errorf(codes.InvalidArgument, reasonPointsRedemptionInvalid,
+			"%d loyalty points take off more than the items cost", points)
+	}
+	return discount, nil
+}
+
+// pointsEarned returns the points earned by items that cost spent, after
+// discounts.
+func (cs *checkoutService) pointsEarned(ctx context.Context, spent pb.Money) (int64, error) {
+	if cs.loyalty == nil {
+		return 0, nil
+	}
+	converted, err := cs.convertPoints(ctx, spent, cs.loyalty.currency)
+	if err != nil {
+		return 0, err
+	}
+	return converted.GetUnits() * pointsPerUnitEarned, nil
+}
+
+func errLoyaltyNotOffered() error {
+	return rpcerrors.Errorf(codes.FailedPrecondition, reasonLoyaltyNotOffered,
+		"loyalty points are not offered: LOYALTY_CURRENCY is not set or no database is connected")
+}
+
+func errNotEnoughPoints(balance, points int64) error {
+	return rpcerrors.Errorf(codes.FailedPrecondition, reasonInsufficientPoints,
+		"%d loyalty points are redeemed, but the user holds %d", points, balance)
+}
+
+// returns the loyalty points userID holds
+func (os *OrderStore) GetPointsBalance(ctx context.Context, userID string) (_ int64, err error) {
+	ctx, span := startStoreSpan(ctx, "GetPointsBalance")
+	defer func() { endSpan(span, err) }()
+	var balance int64
+	// balances are read from the primary, since they are checked before
+	// they are spent
+	err = withDBTimeout(ctx, os.timeouts.read, "GetPointsBalance", func(ctx context.Context) error {
+		return retryDB(ctx, "get loyalty points of user "+userID, func() (err error) {
+			balance, err = getPointsBalance(ctx, os.db, userID)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// redeems points of userID outside of an order, failing with
+// errInsufficientPoints if the user holds fewer, and returns the balance left
+func (os *OrderStore) RedeemPoints(ctx context.Context, userID string, points int64) (_ int64, err error) {
+	ctx, span := startStoreSpan(ctx, "RedeemPoints")
+	defer func() { endSpan(span, err) }()
+	var balance int64
+	err = withDBTimeout(ctx, os.timeouts.save, "RedeemPoints", func(ctx context.Context) error {
+		// redeeming is not idempotent, so a failed commit is not retried
+		return retryDB(ctx, "redeem loyalty points of user "+userID, func() error {
+			return inTxOnce(ctx, os.db, func(tx *sql.Tx) error {
+				ok, err := adjustPoints(ctx, os.dialect, tx, userID, -points, points)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("%w: user %s redeems %d", errInsufficientPoints, userID, points)
+				}
+				balance, err = getPointsBalance(ctx, tx, userID)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// returns the loyalty points userID holds, none if they never held any
+func getPointsBalance(ctx context.Context, q queryer, userID string) (int64, error) {
+	var balance int64
+	err := q.QueryRowContext(ctx, `SELECT balance FROM user_points WHERE user_id = $1`, userID).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query loyalty points: %w", err)
+	}
+	return balance, nil
+}
+
+// adds delta to the loyalty points of userID, provided they hold at least
+// minBalance, and reports whether it did
+func adjustPoints(ctx context.Context, d dialect, tx dbtx, userID string, delta, minBalance int64) (bool, error) {
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO user_points (user_id, balance, updated_at) VALUES ($1, 0, $2)
+    `+d.ignoreDuplicate("user_id"), userID, now); err != nil {
+		return false, fmt.Errorf("failed to insert loyalty points: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `
+        UPDATE user_points SET balance = balance + $2, updated_at = $3 WHERE user_id = $1 AND balance >= $4
+    `, userID, delta, now, minBalance)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update loyalty points: %w", err)
+	}
+	return n > 0, nil
+}
+
+// credits the points an order earned to its user and debits those it
+// redeemed, failing with errInsufficientPoints if check is set and the user
+// holds fewer than it redeemed
+func applyOrderPoints(ctx context.Context, d dialect, tx dbtx, o Order, check bool) error {
+	if o.UserID == "" || o.PointsEarned == 0 && o.PointsRedeemed == 0 {
+		return nil
+	}
+	minBalance := int64(math.MinInt64)
+	if check {
+		minBalance = o.PointsRedeemed
+	}
+	ok, err := adjustPoints(ctx, d, tx, o.UserID, o.PointsEarned-o.PointsRedeemed, minBalance)
+	if err == nil && !ok {
+		err = fmt.Errorf("%w: order %s redeems %d", errInsufficientPoints, o.OrderID, o.PointsRedeemed)
+	}
+	return err
+}
+
+// gives back the points a cancelled order redeemed to its user and takes
+// back those it earned, without taking the balance below zero
+func revertOrderPoints(ctx context.Context, d dialect, tx dbtx, o Order) error {
+	if o.UserID == "" || o.PointsEarned == 0 && o.PointsRedeemed == 0 {
+		return nil
+	}
+	delta := o.PointsRedeemed - o.PointsEarned
+	ok, err := adjustPoints(ctx, d, tx, o.UserID, delta, -delta)
+	if err != nil || ok {
+		return err
+	}
+	// the user already spent points the order earned
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE user_points SET balance = 0, updated_at = $2 WHERE user_id = $1
+    `, o.UserID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to update loyalty points: %w", err)
+	}
+	return nil
+}
+
+// loyaltyService serves hipstershop.v2.LoyaltyService.
+type loyaltyService struct {
+	pbv2.UnimplementedLoyaltyServiceServer
+
+	cs *checkoutService
+}
+
+func newLoyaltyService(cs *checkoutService) *loyaltyService {
+	return &loyaltyService{cs: cs}
+}
+
+func (s *loyaltyService) GetPointsBalance(ctx context.Context, req *pbv2.GetPointsBalanceRequest) (*pbv2.PointsBalance, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if s.cs.loyalty == nil {
+		return nil, errLoyaltyNotOffered()
+	}
+	balance, err := s.cs.orderStore.GetPointsBalance(ctx, req.GetUserId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get loyalty points: %v", err)
+	}
+	return s.balance(req.GetUserId(), balance), nil
+}
+
+func (s *loyaltyService) RedeemPoints(ctx context.Context, req *pbv2.RedeemPointsRequest) (*pbv2.PointsBalance, error) {
+	log.WithContext(ctx).WithFields(logging.Fields{logging.UserIDKey: req.GetUserId(), "points": req.GetPoints()}).Info("[v2.RedeemPoints]")
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if req.GetPoints() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "points must be positive")
+	}
+	if s.cs.loyalty == nil {
+		return nil, errLoyaltyNotOffered()
+	}
+	balance, err := s.cs.orderStore.RedeemPoints(ctx, req.GetUserId(), req.GetPoints())
+	if errors.Is(err, errInsufficientPoints) {
+		held, _ := s.cs.orderStore.GetPointsBalance(ctx, req.GetUserId())
+		return nil, errNotEnoughPoints(held, req.GetPoints())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to redeem loyalty points: %v", err)
+	}
+	return s.balance(req.GetUserId(), balance), nil
+}
+
+func (s *loyaltyService) balance(userID string, points int64) *pbv2.PointsBalance {
+	value := s.cs.loyalty.value(points)
+	return &pbv2.PointsBalance{UserId: userID, Points: points, Value: &value}
+}
@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestLoyaltyPoints(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = newMemoryOrderStore()
+	cs.loyalty = &loyaltyProgram{currency: "USD"}
+	s, loyalty := newCheckoutServiceV2(cs), newLoyaltyService(cs)
+	place := func(key string, quantity int32, points int64) (*pbv2.PlaceOrderResponse, error) {
+		req := placeOrderRequest(key)
+		req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+		req.RedeemPoints = points
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: quantity}}); err != nil {
+			t.Fatal(err)
+		}
+		return s.PlaceOrder(ctx, req)
+	}
+	balance := func() int64 {
+		t.Helper()
+		b, err := loyalty.GetPointsBalance(ctx, &pbv2.GetPointsBalanceRequest{UserId: "user-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.GetPoints()
+	}
+
+	// ten items at 19.99 USD earn 199 points
+	first, err := place("key-1", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 199 {
+		t.Fatalf("balance after the first order = %d, want 199", got)
+	}
+	if _, err := place("key-2", 1, 1000); rpcerrors.Classify(err).Reason != reasonInsufficientPoints {
+		t.Errorf("PlaceOrder() redeeming more points than held = %v, want %s", err, reasonInsufficientPoints)
+	}
+	if _, err := backends.cart.EmptyCart(ctx, &pb.EmptyCartRequest{UserId: "user-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.pointsDiscount(ctx, "user-1", 199, pb.Money{CurrencyCode: "USD", Units: 1}); rpcerrors.Classify(err).Reason != reasonPointsRedemptionInvalid {
+		t.Errorf("pointsDiscount() above the price of the items = %v, want %s", err, reasonPointsRedemptionInvalid)
+	}
+
+	// 150 points take 1.50 USD off, and the 18.49 USD left earn 18 points
+	second, err := place("key-3", 1, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.GetTotalPaid(); got.GetUnits() != 27 || got.GetNanos() != 480000000 {
+		t.Errorf("total paid = %v, want 27.48 USD with the shipping", got)
+	}
+	o, err := s.GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: second.GetOrder().GetOrderId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.GetPointsRedeemed() != 150 || o.GetPointsEarned() != 18 || o.GetDiscount().GetUnits() != 1 || o.GetDiscount().GetNanos() != 500000000 {
+		t.Errorf("GetOrder() = %v, want 150 points redeemed for 1.50 USD and 18 earned", o)
+	}
+	if got := balance(); got != 67 {
+		t.Errorf("balance after the second order = %d, want 67", got)
+	}
+
+	if _, err := loyalty.RedeemPoints(ctx, &pbv2.RedeemPointsRequest{UserId: "user-1", Points: 68}); rpcerrors.Classify(err).Reason != reasonInsufficientPoints {
+		t.Errorf("RedeemPoints() of more points than held = %v, want %s", err, reasonInsufficientPoints)
+	}
+	left, err := loyalty.RedeemPoints(ctx, &pbv2.RedeemPointsRequest{UserId: "user-1", Points: 17})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left.GetPoints() != 50 || left.GetValue().GetNanos() != 500000000 {
+		t.Errorf("RedeemPoints() = %v, want 50 points worth 0.50 USD left", left)
+	}
+
+	// cancelling gives back the 150 points redeemed and takes back the 18
+	// earned, then the 199 earned first, of which 182 were spent
+	if _, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: second.GetOrder().GetOrderId()}); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 182 {
+		t.Errorf("balance after cancelling the second order = %d, want 182", got)
+	}
+	if _, err := loyalty.RedeemPoints(ctx, &pbv2.RedeemPointsRequest{UserId: "user-1", Points: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelOrder(ctx, &pbv2.CancelOrderRequest{OrderId: first.GetOrder().GetOrderId()}); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 0 {
+		t.Errorf("balance after cancelling the first order = %d, want 0", got)
+	}
+}
+
+func TestLoyaltyNotOffered(t *testing.T) {
+	ctx := context.Background()
+	cs := &checkoutService{orderStore: newMemoryOrderStore()}
+	loyalty := newLoyaltyService(cs)
+	if _, err := loyalty.GetPointsBalance(ctx, &pbv2.GetPointsBalanceRequest{UserId: "user-1"}); rpcerrors.Classify(err).Reason != reasonLoyaltyNotOffered {
+		t.Errorf("GetPointsBalance() = %v, want %s", err, reasonLoyaltyNotOffered)
+	}
+	if _, err := loyalty.RedeemPoints(ctx, &pbv2.RedeemPointsRequest{UserId: "user-1", Points: 1}); rpcerrors.Classify(err).Reason != reasonLoyaltyNotOffered {
+		t.Errorf("RedeemPoints() = %v, want %s", err, reasonLoyaltyNotOffered)
+	}
+	if _, err := cs.pointsDiscount(ctx, "user-1", 1, pb.Money{CurrencyCode: "USD", Units: 1}); rpcerrors.Classify(err).Reason != reasonLoyaltyNotOffered {
+		t.Errorf("pointsDiscount() = %v, want %s", err, reasonLoyaltyNotOffered)
+	}
+	if _, err := loyalty.RedeemPoints(ctx, &pbv2.RedeemPointsRequest{UserId: "user-1"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RedeemPoints() of no points = %v, want InvalidArgument", err)
+	}
+}
+
+// TestPointsInSQLite changes balances in the transactions that save and
+// cancel orders.
+func TestPointsInSQLite(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	save := func(orderID string, earned, redeemed int64) error {
+		return store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: "user-1", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-" + orderID, PointsEarned: earned, PointsRedeemed: redeemed}})
+	}
+	balance := func() int64 {
+		t.Helper()
+		b, err := store.GetPointsBalance(ctx, "user-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if got := balance(); got != 0 {
+		t.Errorf("balance of a new user = %d, want 0", got)
+	}
+
+	first, second := uuid.NewString(), uuid.NewString()
+	if err := save(first, 50, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := save(second, 5, 80); !errors.Is(err, errInsufficientPoints) {
+		t.Errorf("saving an order redeeming more points than held: got %v, want errInsufficientPoints", err)
+	}
+	if _, err := store.GetOrder(ctx, second); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("GetOrder() of the order that failed to redeem points: got %v, want errOrderNotFound", err)
+	}
+	if got := balance(); got != 50 {
+		t.Errorf("balance = %d, want 50", got)
+	}
+
+	if left, err := store.RedeemPoints(ctx, "user-1", 20); err != nil || left != 30 {
+		t.Errorf("RedeemPoints(20) = %d, %v, want 30 left", left, err)
+	}
+	if _, err := store.RedeemPoints(ctx, "user-1", 40); !errors.Is(err, errInsufficientPoints) {
+		t.Errorf("RedeemPoints(40) = %v, want errInsufficientPoints", err)
+	}
+	if _, err := store.UpdateOrderStatus(ctx, first, StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 0 {
+		t.Errorf("balance after cancelling an order whose points were spent = %d, want 0", got)
+	}
+}
@@ -17,29 +17,55 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"flag"
 	"fmt"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/profiler"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-
+	"google.golang.org/protobuf/proto"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/audit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/breaker"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/callpolicy"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardcrypt"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/dedup"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/emailtemplate"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/grpcconfig"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/healthcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/lifecycle"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
 	money "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
-	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/ratelimit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/warmup"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -47,20 +73,54 @@ const (
 	usdCurrency = "USD"
 )
 
-var log *logrus.Logger
-
-func init() {
-	log = logrus.New()
-	log.Level = logrus.DebugLevel
-	log.Formatter = &logrus.JSONFormatter{
-		FieldMap: logrus.FieldMap{
-			logrus.FieldKeyTime:  "timestamp",
-			logrus.FieldKeyLevel: "severity",
-			logrus.FieldKeyMsg:   "message",
+var (
+	log *logging.Logger
+
+	// peerCreds secure gRPC traffic with the other Go services.
+	peerCreds *mtls.Credentials
+	// grpcSettings tune gRPC traffic and enable diagnostic services.
+	grpcSettings *grpcconfig.Config
+	// admit sheds low-priority requests when the service is overloaded.
+	admit *admission.Controller
+	// breakers fail calls to dependencies fast while they are failing.
+	breakers *breaker.Set
+	// callPolicies bound and retry the calls to dependencies.
+	callPolicies *callpolicy.Policies
+
+	// auditedOperations are the privileged methods recorded in the audit log.
+	auditedOperations = audit.Operations{
+		pbv2.CheckoutService_UpdateOrderStatus_FullMethodName: func(req any) string {
+			return req.(*pbv2.UpdateOrderStatusRequest).GetOrderId()
+		},
+		pbv2.CheckoutService_DeleteUserData_FullMethodName: func(req any) string {
+			return req.(*pbv2.DeleteUserDataRequest).GetUserId()
+		},
+		pbv2.CheckoutService_SearchOrders_FullMethodName: func(req any) string {
+			return req.(*pbv2.SearchOrdersRequest).GetEmail()
+		},
+		pbv2.CheckoutService_CancelOrder_FullMethodName: func(req any) string {
+			return req.(*pbv2.CancelOrderRequest).GetOrderId()
+		},
+		pbv2.LoyaltyService_RedeemPoints_FullMethodName: func(req any) string {
+			return req.(*pbv2.RedeemPointsRequest).GetUserId()
+		},
+		pbv2.OrderAdminService_ListAllOrders_FullMethodName: func(req any) string {
+			return req.(*pbv2.ListAllOrdersRequest).GetUserId()
+		},
+		pbv2.OrderAdminService_UpdateOrderStatus_FullMethodName: func(req any) string {
+			return req.(*pbv2.UpdateOrderStatusRequest).GetOrderId()
+		},
+		pbv2.OrderAdminService_AddOrderNote_FullMethodName: func(req any) string {
+			return req.(*pbv2.AddOrderNoteRequest).GetOrderId()
+		},
+		pbv2.OrderAdminService_ResendConfirmation_FullMethodName: func(req any) string {
+			return req.(*pbv2.ResendConfirmationRequest).GetOrderId()
 		},
-		TimestampFormat: time.RFC3339Nano,
 	}
-	log.Out = os.Stdout
+)
+
+func init() {
+	log = logging.New("checkoutservice")
 }
 
 type checkoutService struct {
@@ -81,23 +141,98 @@ type checkoutService struct {
 	emailSvcAddr string
 	emailSvcConn *grpc.ClientConn
 
+	// paymentSvcConn is nil unless cards are charged with the payment
+	// service
 	paymentSvcAddr string
 	paymentSvcConn *grpc.ClientConn
+	// payments charges the cards orders are paid with
+	payments ChargeProvider
+	// vault holds the details of the cards of the orders being placed,
+	// which are tokenized; see cardvault.go
+	vault cardvault.Vault
+
+	// inventorySvcConn is nil unless stock is reserved for orders
+	inventorySvcAddr string
+	inventorySvcConn *grpc.ClientConn
 
 	//DB connection & store
-	db         *sql.DB
-	orderStore *OrderStore
+	db *sql.DB
+	// orderStore is nil unless orders are stored
+	orderStore OrderStorage
+	// readOnly is set when the database schema is incompatible with this
+	// binary's, which must not write to it
+	readOnly bool
+	// replicator is nil unless the orders store is replicated
+	replicator *replicator
+	// orderQueue is nil unless orders that cannot be saved are queued
+	orderQueue *orderQueue
+	// duplicates is nil unless identical orders placed in a row are
+	// detected, which needs orderStore
+	duplicates *duplicateDetector
+
+	// rates is nil unless prices are converted with streamed rates
+	rates *rateTable
+	// conversions is nil unless the rates of conversions that do not use
+	// the streamed rates are cached
+	conversions *conversionCache
+	// lookups is nil unless orders get lookup tokens
+	lookups *orderLookup
+	// promotions are the promo codes orders can be placed with
+	promotions promotions
+	// loyalty is nil unless orders earn loyalty points, which needs
+	// orderStore
+	loyalty *loyaltyProgram
+	// prices is nil unless the prices of orders are checked again before
+	// charging them
+	prices *priceCheck
+	// fraud is nil unless orders are checked for fraud before charging
+	// them
+	fraud FraudChecker
+
+	emailRenderer *emailtemplate.Renderer
+	// emails is nil unless order confirmations that cannot be sent are
+	// queued to be sent again, which needs orderStore
+	emails *emailRetrier
+	// inFlight is nil unless the orders being placed are drained on
+	// shutdown
+	inFlight *inFlightOrders
 }
 
 func main() {
+	checkSchemaOnly := flag.Bool("check-schema", false, "check whether the database schema is compatible with this binary, then exit")
+	migrateOnly := flag.String("migrate", "", "`direction` to migrate the database schema in, then exit: up to this binary's version, or down one version")
+	migrateTo := flag.Int("migrate-to", -1, "schema `version` to migrate to with -migrate, instead of the default")
+	flag.Parse()
+
+	if err := validateConfig(); err != nil {
+		if configcheck.FailFast() {
+			log.Fatal(err)
+		}
+		log.Warn(err)
+	}
+
+	if *checkSchemaOnly {
+		os.Exit(checkSchemaCommand())
+	}
+	if *migrateOnly != "" {
+		os.Exit(migrateCommand(*migrateOnly, *migrateTo))
+	}
+
 	ctx := context.Background()
 	if os.Getenv("ENABLE_TRACING") == "1" {
 		log.Info("Tracing enabled.")
-		initTracing()
-
 	} else {
 		log.Info("Tracing disabled.")
 	}
+	tel, err := instrumentation.Init(ctx, "checkoutservice", os.Getenv("ENABLE_TRACING") == "1")
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	if err := tel.ServeMetrics(instrumentation.MetricsAddr()); err != nil {
+		log.Fatal(err)
+	}
+	life := lifecycle.New(log)
+	life.OnClose("telemetry", tel.Shutdown)
 
 	if os.Getenv("ENABLE_PROFILER") == "1" {
 		log.Info("Profiling enabled.")
@@ -106,29 +241,250 @@ func main() {
 		log.Info("Profiling disabled.")
 	}
 
+	auditLog, err := audit.FromEnv("checkoutservice", log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	life.OnClose("audit log", auditLog.Close)
+
+	// debugMux is nil unless debug endpoints are enabled; order exports,
+	// email previews and replication add their admin endpoints to it
+	var debugMux *http.ServeMux
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		log.Info("Debug endpoints enabled.")
+		levelHandler := auditLog.HTTPHandler("logging.SetLevel", logging.LevelHandler())
+		debugMux = http.NewServeMux()
+		debugMux.Handle("/", instrumentation.DebugHandler(levelHandler, auditLog.Handler()))
+		if err := instrumentation.ServeDebug(instrumentation.DebugAddr(), debugMux); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Info("Debug endpoints disabled.")
+	}
+
+	creds, err := mtls.FromEnv(ctx, log)
+	if err != nil {
+		log.Fatalf("failed to set up mTLS: %v", err)
+	}
+	peerCreds = creds
+	log.Infof("mTLS mode: %s", peerCreds.Mode())
+
+	settings, err := grpcconfig.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcSettings = settings
+	log.Infof("gRPC settings: %s", grpcSettings)
+
+	admit, err = admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Load shedding: %s", admit)
+	go admit.Run(life.Context())
+
+	breakers, err = breaker.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Circuit breakers: %s", breakers)
+	callPolicies, err = callpolicy.FromEnv(dependencies...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Call policies: %s", callPolicies)
+	limits, err := ratelimit.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Rate limits: %s", limits)
+
 	port := listenPort
 	if os.Getenv("PORT") != "" {
 		port = os.Getenv("PORT")
 	}
 
-	// initialize db connection
-	db, err := initDatabaseConnection()
+	secretStore, err := secrets.FromEnv(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dbDialect, err := dialectFromEnv()
 	if err != nil {
+		log.Fatal(err)
+	}
+
+	svc := new(checkoutService)
+	svc.inFlight = newInFlightOrders()
+	var db *sql.DB
+	if os.Getenv("ORDER_STORE") == orderStoreMemory {
+		log.Info("Orders are kept in memory (ORDER_STORE=memory).")
+		svc.orderStore = newMemoryOrderStore()
+	} else if db, err = initDatabaseConnection(ctx, secretStore, dbDialect); err != nil {
 		log.Warnf("Database connection failed (continuing without persistence): %v", err)
 	} else {
-		defer db.Close()
+		life.OnClose("database", func(context.Context) error { return db.Close() })
 		log.Info("Database connection established")
+		if err := registerDBMetrics(db); err != nil {
+			log.Warnf("failed to register database pool metrics: %v", err)
+		}
 
 		// initialize db schema
-		if err := InitDB(db); err != nil {
+		if dbDialect != dialectPostgres {
+			if err := createSchema(ctx, db, dbDialect); err != nil {
+				log.Warnf("Failed to initialize database schema: %v", err)
+			}
+		} else if err := migrateSchema(db); errors.Is(err, errSchemaIncompatible) {
+			if !schemaReadOnly() {
+				log.Fatal(err)
+			}
+			log.Warnf("%v; running read-only", err)
+			svc.readOnly = true
+		} else if err != nil {
 			log.Warnf("Failed to initialize database schema: %v", err)
 		}
 	}
 
-	svc := new(checkoutService)
 	svc.db = db
+	var store *OrderStore
 	if db != nil {
-		svc.orderStore = NewOrderStore(db)
+		store = NewOrderStore(db)
+		store.dialect = dbDialect
+		if store.timeouts, err = dbTimeoutsFromEnv(); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Order store timeouts: %s", store.timeouts)
+		if os.Getenv("DB_PREPARE_STATEMENTS") == "0" {
+			log.Info("Order statements run unprepared (DB_PREPARE_STATEMENTS=0).")
+			store.stmts = nil
+		}
+		if dbDialect == dialectPostgres {
+			store.replica, err = newReadReplicaFromEnv(secretStore)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if store.replica != nil {
+			log.Info("Orders are read from the replica at DB_READ_DSN when it is available.")
+			life.OnClose("read replica", store.replica.close)
+		}
+		store.cards, err = cardcrypt.FromEnv(ctx, secretStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		svc.orderStore = store
+		if err := registerPendingOrderMetrics(store); err != nil {
+			log.Warnf("failed to register pending order metrics: %v", err)
+		}
+	}
+	svc.vault = newCardVault(store)
+	log.Infof("Card vault: %s", svc.vault)
+	if debugMux != nil && svc.orderStore != nil {
+		svc.handleExport(debugMux, auditLog)
+	}
+	svc.promotions, err = parsePromoCodes(os.Getenv("PROMO_CODES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(svc.promotions) > 0 {
+		log.Infof("Orders can be placed with %d promo codes.", len(svc.promotions))
+	}
+	svc.prices, err = newPriceCheckFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Price revalidation: %s", svc.prices)
+	var history fraudHistory
+	if svc.orderStore != nil {
+		history = svc.orderStore
+	}
+	svc.fraud, err = newFraudCheckerFromEnv(history, svc.convertCurrency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if svc.fraud != nil {
+		log.Infof("Fraud check: %s", svc.fraud)
+	} else {
+		log.Info("Fraud check: off")
+	}
+	if svc.orderStore != nil {
+		svc.lookups, err = newOrderLookupFromEnv(ctx, secretStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if svc.lookups != nil {
+			log.Info("Orders get lookup tokens signed with ORDER_LOOKUP_KEY.")
+		}
+		svc.duplicates, err = newDuplicateDetectorFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if svc.duplicates != nil {
+			log.Infof("Duplicate orders: %s.", svc.duplicates)
+		}
+		svc.loyalty, err = newLoyaltyProgramFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if svc.loyalty != nil {
+			log.Infof("Orders earn loyalty points in %s.", svc.loyalty)
+		}
+	}
+	if db != nil && !svc.readOnly && dbDialect.hasOutbox() {
+		svc.replicator, err = newReplicatorFromEnv(ctx, db, secretStore)
+		if err != nil {
+			log.Fatalf("failed to set up replication: %v", err)
+		}
+		if svc.replicator != nil {
+			life.OnClose("replication", svc.replicator.close)
+			go svc.replicator.run(life.Context())
+			if debugMux != nil {
+				svc.replicator.handleDebug(debugMux, auditLog)
+			}
+		}
+
+		publisher, err := newEventPublisherFromEnv(ctx, db)
+		if err != nil {
+			log.Fatalf("failed to set up order events: %v", err)
+		}
+		if publisher != nil {
+			log.Infof("Order events are published to %s.", publisher.sink)
+			life.OnClose("order events", publisher.close)
+			go publisher.run(life.Context())
+		} else {
+			log.Info("Order events are not published (ORDER_EVENTS_SINK=none).")
+		}
+
+		webhooks, err := newWebhookDispatcherFromEnv(ctx, db, secretStore)
+		if err != nil {
+			log.Fatalf("failed to set up webhooks: %v", err)
+		}
+		if webhooks != nil {
+			log.Infof("Order events are delivered to the webhooks %s.", webhooks)
+			store.webhooks = webhooks.urls
+			go webhooks.run(life.Context())
+		}
+	} else if db != nil && !svc.readOnly {
+		log.Infof("Orders are neither replicated nor published from %s databases.", dbDialect)
+	}
+	if db != nil && !svc.readOnly {
+		svc.orderQueue, err = newOrderQueueFromEnv(store)
+		if err != nil {
+			log.Fatalf("failed to set up the order queue: %v", err)
+		}
+		if svc.orderQueue != nil {
+			log.Infof("Orders that cannot be saved are queued in %s.", svc.orderQueue.dir)
+			go svc.orderQueue.run(life.Context())
+		}
+
+		janitor, err := newOrderJanitorFromEnv(store)
+		if err != nil {
+			log.Fatalf("failed to set up order retention: %v", err)
+		}
+		if janitor != nil {
+			log.Infof("Order retention: %s.", janitor)
+			go janitor.run(life.Context())
+		}
 	}
 
 	mustMapEnv(&svc.shippingSvcAddr, "SHIPPING_SERVICE_ADDR")
@@ -136,17 +492,77 @@ func main() {
 	mustMapEnv(&svc.cartSvcAddr, "CART_SERVICE_ADDR")
 	mustMapEnv(&svc.currencySvcAddr, "CURRENCY_SERVICE_ADDR")
 	mustMapEnv(&svc.emailSvcAddr, "EMAIL_SERVICE_ADDR")
-	mustMapEnv(&svc.paymentSvcAddr, "PAYMENT_SERVICE_ADDR")
+	if usesPaymentService() {
+		mustMapEnv(&svc.paymentSvcAddr, "PAYMENT_SERVICE_ADDR")
+	}
 
-	mustConnGRPC(ctx, &svc.shippingSvcConn, svc.shippingSvcAddr)
-	mustConnGRPC(ctx, &svc.productCatalogSvcConn, svc.productCatalogSvcAddr)
-	mustConnGRPC(ctx, &svc.cartSvcConn, svc.cartSvcAddr)
-	mustConnGRPC(ctx, &svc.currencySvcConn, svc.currencySvcAddr)
-	mustConnGRPC(ctx, &svc.emailSvcConn, svc.emailSvcAddr)
-	mustConnGRPC(ctx, &svc.paymentSvcConn, svc.paymentSvcAddr)
+	mustConnGRPC(ctx, &svc.shippingSvcConn, svc.shippingSvcAddr, "shipping")
+	mustConnGRPC(ctx, &svc.productCatalogSvcConn, svc.productCatalogSvcAddr, "product")
+	mustConnGRPC(ctx, &svc.cartSvcConn, svc.cartSvcAddr, "cart")
+	mustConnGRPC(ctx, &svc.currencySvcConn, svc.currencySvcAddr, "currency")
+	mustConnGRPC(ctx, &svc.emailSvcConn, svc.emailSvcAddr, "email")
+	if svc.paymentSvcAddr != "" {
+		mustConnGRPC(ctx, &svc.paymentSvcConn, svc.paymentSvcAddr, "payment")
+	}
+	svc.payments, err = paymentProviderFromEnv(secretStore, svc.paymentSvcConn, svc.vault)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Cards are charged with %s.", svc.payments)
+	if svc.inventorySvcAddr = os.Getenv("INVENTORY_SERVICE_ADDR"); svc.inventorySvcAddr != "" {
+		mustConnGRPC(ctx, &svc.inventorySvcConn, svc.inventorySvcAddr, "inventory")
+		log.Infof("Stock is reserved for orders with %s.", svc.inventorySvcAddr)
+	}
+
+	svc.rates, err = newRateTableFromEnv(pb.NewCurrencyServiceClient(svc.currencySvcConn))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Currency rates: %s", svc.rates)
+	if svc.rates != nil {
+		go svc.rates.run(life.Context())
+	}
+	svc.conversions, err = newConversionCacheFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Currency conversion cache: %s", svc.conversions)
+
+	svc.emailRenderer, err = emailtemplate.NewRenderer()
+	if err != nil {
+		log.Fatalf("failed to load email templates: %v", err)
+	}
+	if previewPort := os.Getenv("EMAIL_PREVIEW_PORT"); previewPort != "" {
+		svc.serveEmailPreview(previewPort, life)
+	}
+	if debugMux != nil && svc.orderStore != nil {
+		svc.handleEmailPreview(debugMux, auditLog)
+	}
+	if svc.orderStore != nil && !svc.readOnly {
+		svc.emails, err = newEmailRetrierFromEnv(svc.orderStore, svc.sendConfirmation)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if svc.emails != nil {
+			log.Infof("Order confirmations that cannot be sent are sent again, %s.", svc.emails)
+			go svc.emails.run(life.Context())
+		}
+	}
 
 	log.Infof("service config: %+v", svc)
 
+	warm, err := warmup.New(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	svc.addWarmupTasks(warm, db)
+	log.Infof("Warm-up: %s", warm)
+
+	adminAuth, err := newAdminAuthFromEnv(ctx, secretStore, peerCreds)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		log.Fatal(err)
@@ -158,99 +574,281 @@ func main() {
 	otel.SetTextMapPropagator(
 		propagation.NewCompositeTextMapPropagator(
 			propagation.TraceContext{}, propagation.Baggage{}))
-	srv = grpc.NewServer(
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
-	)
+	srv = grpc.NewServer(append([]grpc.ServerOption{
+		peerCreds.ServerOption(),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(peerCreds.UnaryServerInterceptor(), adminAuth.UnaryServerInterceptor(), requestid.UnaryServerInterceptor(), admit.UnaryServerInterceptor(admission.Checkout), limits.UnaryServerInterceptor(pb.CheckoutService_PlaceOrder_FullMethodName, pbv2.CheckoutService_PlaceOrder_FullMethodName), dedup.UnaryServerInterceptor(pbv2.CheckoutService_GetOrder_FullMethodName, pbv2.CheckoutService_ListOrders_FullMethodName, pbv2.CheckoutService_LookupGuestOrder_FullMethodName, pbv2.LoyaltyService_GetPointsBalance_FullMethodName), auditLog.UnaryServerInterceptor(auditedOperations), rpcerrors.UnaryServerInterceptor(log)),
+		grpc.ChainStreamInterceptor(peerCreds.StreamServerInterceptor(), requestid.StreamServerInterceptor(), admit.StreamServerInterceptor(admission.Checkout), rpcerrors.StreamServerInterceptor(log)),
+	}, grpcSettings.ServerOptions()...)...)
 
 	pb.RegisterCheckoutServiceServer(srv, svc)
-	healthpb.RegisterHealthServer(srv, svc)
+	v2 := newCheckoutServiceV2(svc)
+	go v2.placed.run(life.Context())
+	pbv2.RegisterCheckoutServiceServer(srv, v2)
+	pbv2.RegisterLoyaltyServiceServer(srv, newLoyaltyService(svc))
+	switch adminPort := os.Getenv("ADMIN_PORT"); {
+	case adminAuth == nil:
+		log.Info("OrderAdminService is not served and LoyaltyService.RedeemPoints is rejected (ADMIN_AUTH not set).")
+	case adminPort == "" || adminPort == port:
+		log.Infof("OrderAdminService is served on the gRPC port, authenticated by %s.", adminAuth)
+		pbv2.RegisterOrderAdminServiceServer(srv, newOrderAdminService(v2))
+	default:
+		log.Infof("OrderAdminService is served on port %s, authenticated by %s.", adminPort, adminAuth)
+		if err := serveAdmin(life, newOrderAdminService(v2), adminAuth, auditLog, adminPort); err != nil {
+			log.Fatal(err)
+		}
+	}
+	health := healthcheck.New(log, pb.CheckoutService_ServiceDesc.ServiceName, pbv2.CheckoutService_ServiceDesc.ServiceName)
+	if db != nil && svc.orderQueue != nil {
+		// orders are queued while the database is down
+		health.Add("db", db.PingContext)
+	} else if db != nil {
+		health.AddCritical("db", db.PingContext)
+	}
+	if store != nil && store.replica != nil {
+		// reads fall back to the primary while the replica is down
+		health.Add("db-replica", store.replica.db.PingContext)
+	}
+	if svc.replicator != nil {
+		health.Add("replication", svc.replicator.check)
+	}
+	health.Add("shipping", healthcheck.Conn(svc.shippingSvcConn))
+	health.Add("productcatalog", healthcheck.Conn(svc.productCatalogSvcConn))
+	health.Add("cart", healthcheck.Conn(svc.cartSvcConn))
+	health.Add("currency", healthcheck.Conn(svc.currencySvcConn))
+	if svc.rates != nil {
+		health.Add("rates", svc.rates.check)
+	}
+	health.Add("email", healthcheck.Conn(svc.emailSvcConn))
+	if svc.paymentSvcConn != nil {
+		health.Add("payment", healthcheck.Conn(svc.paymentSvcConn))
+	}
+	if svc.inventorySvcConn != nil {
+		health.Add("inventory", healthcheck.Conn(svc.inventorySvcConn))
+	}
+	health.Register(srv)
+	grpcSettings.RegisterDiagnostics(srv)
+	go health.Run(life.Context())
+	life.OnUnready(health.Shutdown)
+	life.OnDrain("grpc server", lifecycle.GRPCServer(srv))
+	life.OnDrain("orders in flight", svc.inFlight.drain)
+	release := health.Hold()
+	go func() {
+		warm.Run(life.Context())
+		release()
+	}()
+
 	log.Infof("starting to listen on tcp: %q", lis.Addr().String())
-	err = srv.Serve(lis)
-	log.Fatal(err)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	life.Wait()
 }
 
-func initDatabaseConnection() (*sql.DB, error) {
-	// get database config from env or use defaults
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+// migrateCommand runs the -migrate command, migrating the schema in
+// direction to version to, or by default to schemaVersion up and to the
+// previous version down.
+func migrateCommand(direction string, to int) int {
+	ctx := context.Background()
+	if direction != "up" && direction != "down" {
+		fmt.Fprintf(os.Stderr, "-migrate must be up or down, not %q\n", direction)
+		return 2
+	}
+	if to > schemaVersion {
+		fmt.Fprintf(os.Stderr, "-migrate-to %d is after this binary's schema version %d\n", to, schemaVersion)
+		return 2
+	}
+	if err := requirePostgres(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
 	}
+	secretStore, err := secrets.FromEnv(log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	db, err := initDatabaseConnection(ctx, secretStore, dialectPostgres)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the database: %v\n", err)
+		return 2
+	}
+	defer db.Close()
 
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = "5432"
+	if direction == "up" {
+		if to < 0 {
+			to = schemaVersion
+		}
+		err = migrateUp(ctx, db, to)
+	} else {
+		if to < 0 {
+			state, err := loadSchemaState(ctx, db)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			to = max(state.Version-1, 0)
+		}
+		err = migrateDown(ctx, db, to)
 	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
 
-	dbUser := os.Getenv("DB_USER")
-	if dbUser == "" {
-		dbUser = "postgres"
+// checkSchemaCommand runs the -check-schema command.
+func checkSchemaCommand() int {
+	ctx := context.Background()
+	if err := requirePostgres(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	secretStore, err := secrets.FromEnv(log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	db, err := initDatabaseConnection(ctx, secretStore, dialectPostgres)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the database: %v\n", err)
+		return 2
 	}
+	defer db.Close()
+	return runSchemaCheck(ctx, db)
+}
 
-	dbPassword := os.Getenv("DB_PASSWORD")
-	if dbPassword == "" {
-		dbPassword = "postgres"
+// requirePostgres fails unless DB_DRIVER selects PostgreSQL, the only
+// database with versioned migrations.
+func requirePostgres() error {
+	d, err := dialectFromEnv()
+	if err != nil {
+		return err
+	}
+	if d != dialectPostgres {
+		return fmt.Errorf("schema migrations are only supported on PostgreSQL, not DB_DRIVER=%s", d)
 	}
+	return nil
+}
 
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		dbName = "orders_db"
+func initDatabaseConnection(ctx context.Context, secretStore *secrets.Manager, d dialect) (*sql.DB, error) {
+	c := &dbConnector{secrets: secretStore}
+	pool, err := dbPoolFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	timeouts, err := dbTimeoutsFromEnv()
+	if err != nil {
+		return nil, err
 	}
 
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName,
+	var (
+		db   *sql.DB
+		name string
 	)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
+	if d == dialectPostgres {
+		dsn, err := c.dsn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		db, name = sql.OpenDB(c), "PostgreSQL at "+dsn.Redacted()
+	} else if db, name, err = openDialectDB(ctx, d, c); err != nil {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, timeouts.connect)
+	defer cancel()
+
 	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	// configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	pool.apply(db)
 
-	log.Infof("Connected to PostgreSQL at %s:%s", dbHost, dbPort)
+	log.Infof("Connected to %s (pool: %s)", name, pool)
 	return db, nil
 }
 
-func initStats() {
-	//TODO(arbrown) Implement OpenTelemetry stats
+// dbConnector resolves the database credentials each time it opens a
+// connection, so that rotated secrets are used without a restart.
+type dbConnector struct {
+	secrets *secrets.Manager
 }
 
-func initTracing() {
-	var (
-		collectorAddr string
-		collectorConn *grpc.ClientConn
-	)
+// dbDSN is a PostgreSQL connection string in URL form.
+type dbDSN struct {
+	*url.URL
+}
 
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
-	defer cancel()
+// dsn builds the connection string from DB_DSN if set, or else from DB_HOST,
+// DB_PORT, DB_USER, DB_PASSWORD and DB_NAME. Any of them can instead be read
+// from a secret with the matching *_SECRET variable.
+func (c *dbConnector) dsn(ctx context.Context) (dbDSN, error) {
+	get := func(key, fallback string) (string, error) {
+		v, err := c.secrets.Value(ctx, key)
+		if v == "" {
+			v = fallback
+		}
+		return v, err
+	}
+	if dsn, err := get("DB_DSN", ""); err != nil {
+		return dbDSN{}, err
+	} else if dsn != "" {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dbDSN{}, fmt.Errorf("invalid DB_DSN: %w", err)
+		}
+		return dbDSN{u}, nil
+	}
 
-	mustMapEnv(&collectorAddr, "COLLECTOR_SERVICE_ADDR")
-	mustConnGRPC(ctx, &collectorConn, collectorAddr)
+	// get database config from env or use defaults
+	var host, port, user, password, name string
+	for _, s := range []struct {
+		v             *string
+		key, fallback string
+	}{
+		{&host, "DB_HOST", "localhost"},
+		{&port, "DB_PORT", "5432"},
+		{&user, "DB_USER", "postgres"},
+		{&password, "DB_PASSWORD", "postgres"},
+		{&name, "DB_NAME", "orders_db"},
+	} {
+		v, err := get(s.key, s.fallback)
+		if err != nil {
+			return dbDSN{}, err
+		}
+		*s.v = v
+	}
+	return dbDSN{&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + name,
+		RawQuery: "sslmode=disable",
+	}}, nil
+}
 
-	exporter, err := otlptracegrpc.New(
-		ctx,
-		otlptracegrpc.WithGRPCConn(collectorConn))
+func (c *dbConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(dsn.String())
 	if err != nil {
-		log.Warnf("warn: Failed to create trace exporter: %v", err)
+		return nil, err
 	}
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()))
-	otel.SetTracerProvider(tp)
+	return connector.Connect(ctx)
+}
+
+func (c *dbConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
 
+func initStats() {
+	//TODO(arbrown) Implement OpenTelemetry stats
 }
 
 func initProfiling(service, version string) {
@@ -283,93 +881,376 @@ func mustMapEnv(target *string, envKey string) {
 	*target = v
 }
 
-func mustConnGRPC(ctx context.Context, conn **grpc.ClientConn, addr string) {
+// dependencies are the names of the services checkout calls, which their
+// circuit breakers and call policies go by.
+var dependencies = []string{"cart", "currency", "email", "inventory", "payment", "product", "shipping"}
+
+// mustConnGRPC connects to the named dependency at addr, through its call
+// policy and circuit breaker, so that every retry counts towards the breaker.
+func mustConnGRPC(ctx context.Context, conn **grpc.ClientConn, addr, dependency string) {
 	var err error
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
-	*conn, err = grpc.DialContext(ctx, addr,
-		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()))
+	b := breakers.For(dependency)
+	*conn, err = grpc.DialContext(ctx, addr, append([]grpc.DialOption{
+		peerCreds.DialOption(addr),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(requestid.UnaryClientInterceptor(), admission.UnaryClientInterceptor(), callPolicies.For(dependency).UnaryClientInterceptor(), b.UnaryClientInterceptor(), logging.UnaryClientInterceptor(log, dependency)),
+		grpc.WithChainStreamInterceptor(requestid.StreamClientInterceptor(), admission.StreamClientInterceptor(), b.StreamClientInterceptor()),
+	}, grpcSettings.DialOptions()...)...)
 	if err != nil {
 		panic(errors.Wrapf(err, "grpc: failed to connect %s", addr))
 	}
 }
 
-func (cs *checkoutService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
-	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+// PlaceOrder is the v1 checkout API, deprecated in favor of
+// hipstershop.v2.CheckoutService/PlaceOrder.
+func (cs *checkoutService) PlaceOrder(ctx context.Context, req *pb.PlaceOrderRequest) (*pb.PlaceOrderResponse, error) {
+	log.WithContext(ctx).WithFields(logging.Fields{logging.UserIDKey: req.UserId, "user_currency": req.UserCurrency}).Info("[PlaceOrder]")
+	markDeprecated(ctx)
+
+	order, _, err := cs.placeOrder(ctx, orderRequest{
+		userID:       req.UserId,
+		userCurrency: req.UserCurrency,
+		address:      req.Address,
+		billing:      req.BillingAddress,
+		email:        req.Email,
+		locale:       req.Locale,
+		card:         req.CreditCard,
+		giftMessage:  req.GiftMessage,
+		customerNote: req.CustomerNote,
+	})
+	recordPlaceOrder(ctx, apiV1, err)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PlaceOrderResponse{Order: order}, nil
 }
 
-func (cs *checkoutService) Watch(req *healthpb.HealthCheckRequest, ws healthpb.Health_WatchServer) error {
-	return status.Errorf(codes.Unimplemented, "health check via Watch not implemented")
+// orderRequest holds the fields of a PlaceOrder request that every API
+// version shares.
+type orderRequest struct {
+	userID       string
+	userCurrency string
+	address      *pb.Address
+	email        string
+	locale       string
+	// card is tokenized by placeOrder, which keeps only its token.
+	card *pb.CreditCardInfo
+	// billing is the billing address of the card, if it is not address.
+	billing      *pb.Address
+	giftMessage  string
+	customerNote string
+	// promo, if set, is the promotion of the promo code the order is placed
+	// with.
+	promo *promotion
+	// redeemPoints are the loyalty points redeemed as a discount.
+	redeemPoints int64
+	// idempotency, if set, is stored with the order so that v2 retries
+	// replay it; its Response is filled in by placeOrder.
+	idempotency *IdempotencyRecord
+	// dryRun computes the order and its total without placing it.
+	dryRun bool
 }
 
-func (cs *checkoutService) PlaceOrder(ctx context.Context, req *pb.PlaceOrderRequest) (*pb.PlaceOrderResponse, error) {
-	log.Infof("[PlaceOrder] user_id=%q user_currency=%q", req.UserId, req.UserCurrency)
+// maxGiftMessageLen and maxCustomerNoteLen bound the messages an order is
+// placed with, in bytes.
+const (
+	maxGiftMessageLen  = 500
+	maxCustomerNoteLen = 1000
+)
+
+// promoCode returns the promo code the order is placed with, if any.
+func (req orderRequest) promoCode() string {
+	if req.promo == nil {
+		return ""
+	}
+	return req.promo.Code
+}
+
+// billingAddress returns the billing address of the card.
+func (req orderRequest) billingAddress() *pb.Address {
+	if req.billing != nil {
+		return req.billing
+	}
+	return req.address
+}
+
+// placeOrder charges the user for their cart, ships it and returns the
+// order along with the total charged. A dry run stops before the first step
+// that changes anything outside checkout, and returns the order it would
+// have placed.
+func (cs *checkoutService) placeOrder(ctx context.Context, req orderRequest) (*pb.OrderResult, *pb.Money, error) {
+	start := time.Now()
+	// a dry run writes nothing, so replicas and read-only databases serve
+	// it
+	if !req.dryRun {
+		if err := cs.replicator.acceptOrders(); err != nil {
+			return nil, nil, err
+		}
+		if cs.readOnly {
+			return nil, nil, errSchemaReadOnly()
+		}
+	}
+	done, err := cs.inFlight.begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+	req = withCallerPreferences(ctx, req)
+	if len(req.giftMessage) > maxGiftMessageLen {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "gift_message is longer than %d bytes", maxGiftMessageLen)
+	}
+	if len(req.customerNote) > maxCustomerNoteLen {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "customer_note is longer than %d bytes", maxCustomerNoteLen)
+	}
 
 	orderID, err := uuid.NewUUID()
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to generate order uuid")
+		return nil, nil, status.Errorf(codes.Internal, "failed to generate order uuid")
 	}
+	// the order ID goes on every span, downstream call and log line of the
+	// checkout from here on
+	ctx = instrumentation.WithBaggage(ctx, map[string]string{instrumentation.BaggageOrderID: orderID.String()})
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String(instrumentation.BaggageOrderID, orderID.String()),
+		attribute.Bool("app.checkout.dry_run", req.dryRun))
+	l := log.WithContext(ctx).WithField(logging.UserIDKey, req.userID)
+
+	// from here on only the token of the card is kept and passed around
+	card, err := cs.vault.Tokenize(ctx, req.card)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "failed to tokenize card: %v", err)
+	}
+	req.card = nil
+	defer func() {
+		if err := cs.vault.Delete(context.WithoutCancel(ctx), card.Token); err != nil {
+			l.WithField("error", err).Warn("failed to delete card details")
+		}
+	}()
 
-	prep, err := cs.prepareOrderItemsAndShippingQuoteFromCart(ctx, req.UserId, req.UserCurrency, req.Address)
+	prep, err := cs.prepareOrderItemsAndShippingQuoteFromCart(ctx, req.userID, req.userCurrency, req.address)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+		return nil, nil, status.Errorf(codes.Internal, err.Error())
 	}
 
-	total := pb.Money{CurrencyCode: req.UserCurrency,
+	// release releases the fingerprint of the order, fail marks its
+	// pending record failed and unreserve releases its stock, unless the
+	// order is recorded
+	var release, fail, unreserve func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+		if fail != nil {
+			fail()
+		}
+		if unreserve != nil {
+			unreserve()
+		}
+	}()
+	if cs.duplicates != nil && cs.orderStore != nil && !req.dryRun {
+		fingerprint := orderFingerprint(req.userID, prep.cartItems, req.address)
+		if prior, total, err := cs.duplicates.claim(ctx, cs.orderStore, fingerprint, req.userID, orderID.String()); prior != nil || err != nil {
+			return prior, total, err
+		}
+		release = func() { cs.duplicates.release(ctx, cs.orderStore, fingerprint, orderID.String()) }
+	}
+
+	total := pb.Money{CurrencyCode: req.userCurrency,
 		Units: 0,
 		Nanos: 0}
-	total = money.Must(money.Sum(total, *prep.shippingCostLocalized))
 	for _, it := range prep.orderItems {
 		multPrice := money.MultiplySlow(*it.Cost, uint32(it.GetItem().GetQuantity()))
 		total = money.Must(money.Sum(total, multPrice))
 	}
-
-	txID, err := cs.chargeCard(ctx, &total, req.CreditCard)
+	var discount *pb.Money
+	if req.promo != nil {
+		d, err := req.promo.discount(total)
+		if err != nil {
+			return nil, nil, rpcerrors.Errorf(codes.InvalidArgument, reasonPromoCodeInvalid, "promo code %q does not apply to orders in %s", req.promo.Code, req.userCurrency)
+		}
+		discount = &d
+		total = money.Must(money.Sum(total, money.Negate(d)))
+	}
+	if req.redeemPoints != 0 {
+		d, err := cs.pointsDiscount(ctx, req.userID, req.redeemPoints, total)
+		if err != nil {
+			return nil, nil, err
+		}
+		total = money.Must(money.Sum(total, money.Negate(d)))
+		if discount != nil {
+			d = money.Must(money.Sum(*discount, d))
+		}
+		discount = &d
+	}
+	pointsEarned, err := cs.pointsEarned(ctx, total)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to charge card: %+v", err)
+		return nil, nil, status.Errorf(codes.Internal, "failed to convert the price of the items to the loyalty currency: %v", err)
+	}
+	total = money.Must(money.Sum(total, *prep.shippingCostLocalized))
+	if err := cs.revalidatePrices(ctx, prep); err != nil {
+		return nil, nil, err
+	}
+	if err := cs.checkFraud(ctx, FraudCheck{
+		OrderID:        orderID.String(),
+		UserID:         req.userID,
+		Email:          strings.ToLower(req.email),
+		Amount:         &total,
+		Address:        req.address,
+		BillingAddress: req.billingAddress(),
+		CardBIN:        card.BIN,
+	}, !req.dryRun); err != nil {
+		return nil, nil, err
+	}
+	if req.dryRun {
+		l.WithField(logging.DurationKey, time.Since(start)).Info("order dry run")
+		return &pb.OrderResult{
+			OrderId:         orderID.String(),
+			ShippingCost:    prep.shippingCostLocalized,
+			ShippingAddress: req.address,
+			Items:           prep.orderItems,
+		}, &total, nil
+	}
+	if unreserve, err = cs.reserveStock(ctx, orderID.String(), prep.cartItems); err != nil {
+		return nil, nil, err
 	}
-	log.Infof("payment went through (transaction_id: %s)", txID)
 
-	shippingTrackingID, err := cs.shipOrder(ctx, req.Address, prep.cartItems)
+	rec := orderRecord{Order: Order{
+		OrderID:                   orderID.String(),
+		UserID:                    req.userID,
+		Email:                     req.email,
+		CardToken:                 card.Token,
+		CardLast4:                 card.Last4,
+		CardBrand:                 card.Brand,
+		OrderTotalUnits:           total.GetUnits(),
+		OrderTotalNanos:           total.GetNanos(),
+		CurrencyCode:              total.GetCurrencyCode(),
+		ShippingCostUnits:         prep.shippingCostLocalized.GetUnits(),
+		ShippingCostNanos:         prep.shippingCostLocalized.GetNanos(),
+		ShippingQuoteUnits:        prep.shippingQuote.GetUnits(),
+		ShippingQuoteNanos:        prep.shippingQuote.GetNanos(),
+		ShippingQuoteCurrencyCode: prep.shippingQuote.GetCurrencyCode(),
+		GiftMessage:               req.giftMessage,
+		CustomerNote:              req.customerNote,
+		PromoCode:                 req.promoCode(),
+		DiscountUnits:             discount.GetUnits(),
+		DiscountNanos:             discount.GetNanos(),
+		PointsEarned:              pointsEarned,
+		PointsRedeemed:            req.redeemPoints,
+	}, Items: newOrderItems(orderID.String(), prep.orderItems)}
+	rec.Order.setShippingAddress(req.address)
+	rec.Order.setBillingAddress(req.billingAddress())
+
+	// the order is recorded as pending before it is charged, so that one
+	// interrupted before it is saved can be found and reconciled
+	if cs.orderStore != nil {
+		if err := cs.orderStore.SavePendingOrder(ctx, rec); err != nil {
+			l.WithField("error", err).Warn("failed to record pending order, placing it anyway")
+		} else {
+			fail = func() {
+				if err := cs.orderStore.FailOrder(context.WithoutCancel(ctx), orderID.String()); err != nil {
+					l.WithField("error", err).Warn("failed to mark order failed")
+				}
+			}
+		}
+	}
+
+	txID, err := cs.chargeCard(ctx, orderID.String(), &total, card)
 	if err != nil {
-		return nil, status.Errorf(codes.Unavailable, "shipping error: %+v", err)
+		return nil, nil, status.Errorf(codes.Internal, "failed to charge card: %+v", err)
 	}
+	// the card is charged, so the order is finished even if its caller
+	// gives up or the server stops
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), orderFinishTimeout)
+	defer cancel()
+	l.WithField("transaction_id", txID).Info("payment went through")
+	steps := placedSteps{orderID: orderID.String(), userID: req.userID, transactionID: txID, total: &total}
 
-	_ = cs.emptyUserCart(ctx, req.UserId)
+	shippingTrackingID, err := cs.shipOrder(ctx, req.address, prep.cartItems)
+	if err != nil {
+		cs.compensate(ctx, steps, err)
+		return nil, nil, status.Errorf(codes.Unavailable, "shipping error: %+v", err)
+	}
+	steps.trackingID = shippingTrackingID
 
 	orderResult := &pb.OrderResult{
 		OrderId:            orderID.String(),
 		ShippingTrackingId: shippingTrackingID,
 		ShippingCost:       prep.shippingCostLocalized,
-		ShippingAddress:    req.Address,
+		ShippingAddress:    req.address,
 		Items:              prep.orderItems,
 	}
 
-	// save order to db
+	// save order to db before sending the confirmation, which is rendered
+	// from the persisted record
 	if cs.orderStore != nil {
-		go func() {
-			if err := cs.orderStore.SaveOrder(ctx, orderID.String(), req.UserId, req.Email,
-				req.Address, req.CreditCard, &total, prep.cartItems, shippingTrackingID); err != nil {
-				log.Warnf("failed to persist order to database: %v", err)
+		idem := req.idempotency
+		if idem != nil {
+			if idem.Response, err = proto.Marshal(&pbv2.PlaceOrderResponse{Order: orderResult, TotalPaid: &total}); err != nil {
+				l.WithFields(logging.Fields{"idempotency_key": idem.Key, "error": err}).Warn("failed to encode the response to replay")
+				idem = nil
+			}
+		}
+		rec.Order.PaymentTransactionID = txID
+		rec.Order.ShippingTrackingID = shippingTrackingID
+		rec.Idempotency = idem
+		err := cs.orderStore.SaveOrder(ctx, rec)
+		if exists := (*orderExistsError)(nil); errors.As(err, &exists) && exists.Redelivered {
+			l.Info("order was already saved")
+			err = nil
+		}
+		if err != nil && cs.orderQueue != nil && !errors.Is(err, errInsufficientPoints) {
+			// the order is charged and shipped, so it is saved later rather
+			// than compensated
+			if qerr := cs.orderQueue.enqueue(rec); qerr != nil {
+				err = fmt.Errorf("%w; %v", err, qerr)
+			} else {
+				l.WithField("error", err).Warn("failed to persist order, queued it to be saved later")
+				err = nil
 			}
-		}()
+		}
+		if err != nil {
+			l.WithField("error", err).Error("failed to persist order")
+			compensated := cs.compensate(ctx, steps, fmt.Errorf("failed to persist order: %w", err))
+			if compensated && errors.Is(err, errInsufficientPoints) {
+				// the points were redeemed while the order was placed
+				balance, _ := cs.orderStore.GetPointsBalance(ctx, req.userID)
+				return nil, nil, errNotEnoughPoints(balance, req.redeemPoints)
+			}
+			return nil, nil, errOrderNotRecorded(orderID.String(), compensated)
+		}
 	}
-
-	if err := cs.sendOrderConfirmation(ctx, req.Email, orderResult); err != nil {
-		log.Warnf("failed to send order confirmation to %q: %+v", req.Email, err)
+	release, fail, unreserve = nil, nil, nil
+	cs.confirmStock(ctx, orderID.String())
+
+	// the cart is kept until the order is recorded, so that a compensated
+	// order can be placed again
+	_ = cs.emptyUserCart(ctx, req.userID)
+
+	confirmation := cs.confirmationRequest(ctx, req.email, req.locale, orderResult, &total, req.giftMessage, req.customerNote)
+	if err := cs.sendConfirmation(ctx, confirmation); err != nil {
+		l.WithField("error", err).Warn("failed to send order confirmation")
+		if cs.emails != nil {
+			if err := cs.emails.queue(ctx, orderID.String(), req.userID, confirmation, err); err != nil {
+				l.WithField("error", err).Error("failed to queue order confirmation")
+			}
+		}
 	} else {
-		log.Infof("order confirmation email sent to %q", req.Email)
+		l.Info("order confirmation email sent")
 	}
-	resp := &pb.PlaceOrderResponse{Order: orderResult}
-	return resp, nil
+	l.WithField(logging.DurationKey, time.Since(start)).Info("order placed")
+	return orderResult, &total, nil
 }
 
 type orderPrep struct {
 	orderItems            []*pb.OrderItem
 	cartItems             []*pb.CartItem
 	shippingCostLocalized *pb.Money
+	// shippingQuote is the quote of the shipping service, in USD.
+	shippingQuote *pb.Money
+	// pricesUsd are the catalog prices of cartItems, in USD.
+	pricesUsd []*pb.Money
 }
 
 func (cs *checkoutService) prepareOrderItemsAndShippingQuoteFromCart(ctx context.Context, userID, userCurrency string, address *pb.Address) (orderPrep, error) {
@@ -378,7 +1259,7 @@ func (cs *checkoutService) prepareOrderItemsAndShippingQuoteFromCart(ctx context
 	if err != nil {
 		return out, fmt.Errorf("cart failure: %+v", err)
 	}
-	orderItems, err := cs.prepOrderItems(ctx, cartItems, userCurrency)
+	orderItems, pricesUsd, err := cs.prepOrderItems(ctx, cartItems, userCurrency)
 	if err != nil {
 		return out, fmt.Errorf("failed to prepare order: %+v", err)
 	}
@@ -392,8 +1273,10 @@ func (cs *checkoutService) prepareOrderItemsAndShippingQuoteFromCart(ctx context
 	}
 
 	out.shippingCostLocalized = shippingPrice
+	out.shippingQuote = shippingUSD
 	out.cartItems = cartItems
 	out.orderItems = orderItems
+	out.pricesUsd = pricesUsd
 	return out, nil
 }
 
@@ -423,53 +1306,279 @@ func (cs *checkoutService) emptyUserCart(ctx context.Context, userID string) err
 	return nil
 }
 
-func (cs *checkoutService) prepOrderItems(ctx context.Context, items []*pb.CartItem, userCurrency string) ([]*pb.OrderItem, error) {
+func (cs *checkoutService) prepOrderItems(ctx context.Context, items []*pb.CartItem, userCurrency string) ([]*pb.OrderItem, []*pb.Money, error) {
 	out := make([]*pb.OrderItem, len(items))
+	pricesUsd := make([]*pb.Money, len(items))
 	cl := pb.NewProductCatalogServiceClient(cs.productCatalogSvcConn)
 
 	for i, item := range items {
 		product, err := cl.GetProduct(ctx, &pb.GetProductRequest{Id: item.GetProductId()})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get product #%q", item.GetProductId())
+			return nil, nil, fmt.Errorf("failed to get product #%q", item.GetProductId())
 		}
 		price, err := cs.convertCurrency(ctx, product.GetPriceUsd(), userCurrency)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert price of %q to %s", item.GetProductId(), userCurrency)
+			return nil, nil, fmt.Errorf("failed to convert price of %q to %s", item.GetProductId(), userCurrency)
 		}
 		out[i] = &pb.OrderItem{
 			Item: item,
 			Cost: price}
+		pricesUsd[i] = product.GetPriceUsd()
 	}
-	return out, nil
+	return out, pricesUsd, nil
 }
 
 func (cs *checkoutService) convertCurrency(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error) {
-	result, err := pb.NewCurrencyServiceClient(cs.currencySvcConn).Convert(context.TODO(), &pb.CurrencyConversionRequest{
-		From:   from,
-		ToCode: toCurrency})
+	if result, ok := cs.rates.convert(ctx, from, toCurrency); ok {
+		return result, nil
+	}
+	result, err := cs.conversions.convert(ctx, from, toCurrency, cs.convertRPC)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert currency: %+v", err)
 	}
 	return result, err
 }
 
-func (cs *checkoutService) chargeCard(ctx context.Context, amount *pb.Money, paymentInfo *pb.CreditCardInfo) (string, error) {
-	paymentResp, err := pb.NewPaymentServiceClient(cs.paymentSvcConn).Charge(ctx, &pb.ChargeRequest{
-		Amount:     amount,
-		CreditCard: paymentInfo})
-	if err != nil {
-		return "", fmt.Errorf("could not charge the card: %+v", err)
+// convertRPC converts from into toCurrency with the Convert RPC.
+func (cs *checkoutService) convertRPC(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error) {
+	return pb.NewCurrencyServiceClient(cs.currencySvcConn).Convert(ctx, &pb.CurrencyConversionRequest{
+		From:   from,
+		ToCode: toCurrency})
+}
+
+// addWarmupTasks registers the work done before the service reports ready.
+func (cs *checkoutService) addWarmupTasks(w *warmup.Warmup, db *sql.DB) {
+	w.Add("shipping", warmup.Conn(cs.shippingSvcConn))
+	w.Add("productcatalog", warmup.Conn(cs.productCatalogSvcConn))
+	w.Add("cart", warmup.Conn(cs.cartSvcConn))
+	w.Add("email", warmup.Conn(cs.emailSvcConn))
+	if cs.paymentSvcConn != nil {
+		w.Add("payment", warmup.Conn(cs.paymentSvcConn))
+	}
+	if cs.inventorySvcConn != nil {
+		w.Add("inventory", warmup.Conn(cs.inventorySvcConn))
+	}
+	if db != nil {
+		w.Add("db", db.PingContext)
+	}
+	w.Add("currency", func(ctx context.Context) error {
+		if cs.rates != nil {
+			return cs.rates.wait(ctx)
+		}
+		if err := warmup.Conn(cs.currencySvcConn)(ctx); err != nil {
+			return err
+		}
+		_, err := pb.NewCurrencyServiceClient(cs.currencySvcConn).Convert(ctx, &pb.CurrencyConversionRequest{
+			From:   &pb.Money{CurrencyCode: "USD", Units: 1},
+			ToCode: "EUR"})
+		return err
+	})
+	w.Add("email templates", cs.warmEmailTemplates)
+}
+
+// warmEmailTemplates renders the sample order in every locale, so that the
+// first confirmation sent in each is not slower than the rest.
+func (cs *checkoutService) warmEmailTemplates(context.Context) error {
+	order := emailtemplate.SampleOrder()
+	for _, locale := range cs.emailRenderer.Locales() {
+		if _, err := cs.emailRenderer.Render(locale, order); err != nil {
+			return fmt.Errorf("locale %s: %w", locale, err)
+		}
 	}
-	return paymentResp.GetTransactionId(), nil
+	return nil
+}
+
+func (cs *checkoutService) sendOrderConfirmation(ctx context.Context, email, locale string, order *pb.OrderResult, total *pb.Money, giftMessage, customerNote string) error {
+	return cs.sendConfirmation(ctx, cs.confirmationRequest(ctx, email, locale, order, total, giftMessage, customerNote))
 }
 
-func (cs *checkoutService) sendOrderConfirmation(ctx context.Context, email string, order *pb.OrderResult) error {
-	_, err := pb.NewEmailServiceClient(cs.emailSvcConn).SendOrderConfirmation(ctx, &pb.SendOrderConfirmationRequest{
+// confirmationRequest renders the order confirmation of an order.
+func (cs *checkoutService) confirmationRequest(ctx context.Context, email, locale string, order *pb.OrderResult, total *pb.Money, giftMessage, customerNote string) *pb.SendOrderConfirmationRequest {
+	req := &pb.SendOrderConfirmationRequest{
 		Email: email,
-		Order: order})
+		Order: order}
+	msg, err := cs.emailRenderer.Render(locale, cs.confirmationData(ctx, email, order, total, giftMessage, customerNote))
+	if err != nil {
+		// the email service can still render its own default template
+		log.WithContext(ctx).Warnf("failed to render order confirmation for %q: %v", order.GetOrderId(), err)
+	} else {
+		req.Subject = msg.Subject
+		req.HtmlBody = msg.HTMLBody
+		req.TextBody = msg.TextBody
+		req.Locale = msg.Locale
+	}
+	return req
+}
+
+func (cs *checkoutService) sendConfirmation(ctx context.Context, req *pb.SendOrderConfirmationRequest) error {
+	_, err := pb.NewEmailServiceClient(cs.emailSvcConn).SendOrderConfirmation(ctx, req)
 	return err
 }
 
+// confirmationData builds the email template data for an order. The
+// persisted order record is preferred so the email reflects what was stored;
+// line items and shipping cost come from the checkout result.
+func (cs *checkoutService) confirmationData(ctx context.Context, email string, order *pb.OrderResult, total *pb.Money, giftMessage, customerNote string) *emailtemplate.Order {
+	data := &emailtemplate.Order{
+		OrderID:            order.GetOrderId(),
+		Email:              email,
+		CreatedAt:          time.Now(),
+		ShippingTrackingID: order.GetShippingTrackingId(),
+		ShippingAddress:    order.GetShippingAddress(),
+		ShippingCost:       order.GetShippingCost(),
+		Total:              total,
+		Items:              order.GetItems(),
+		GiftMessage:        giftMessage,
+		CustomerNote:       customerNote,
+	}
+	if cs.orderStore == nil {
+		return data
+	}
+	stored, err := cs.orderStore.GetOrder(ctx, order.GetOrderId())
+	if err != nil {
+		log.WithContext(ctx).Warnf("rendering confirmation from checkout result: %v", err)
+		return data
+	}
+	stored.applyTo(data)
+	return data
+}
+
+// applyTo overwrites the template fields that are persisted on the order.
+func (o *Order) applyTo(data *emailtemplate.Order) {
+	data.OrderID = o.OrderID
+	data.Email = o.Email
+	data.CreatedAt = o.CreatedAt
+	data.ShippingTrackingID = o.ShippingTrackingID
+	data.ShippingAddress = o.shippingAddress()
+	data.Total = o.totalPaid()
+	data.GiftMessage = o.GiftMessage
+	data.CustomerNote = o.CustomerNote
+}
+
+func (o *Order) shippingAddress() *pb.Address {
+	zip, _ := strconv.ParseInt(o.ZipCode, 10, 32)
+	return &pb.Address{
+		StreetAddress: o.StreetAddress,
+		City:          o.City,
+		State:         o.State,
+		Country:       o.Country,
+		ZipCode:       int32(zip),
+	}
+}
+
+// billingAddress returns nil for orders placed before the billing address
+// was stored, and for erased orders.
+func (o *Order) billingAddress() *pb.Address {
+	if o.BillingStreetAddress == "" && o.BillingCity == "" && o.BillingCountry == "" {
+		return nil
+	}
+	zip, _ := strconv.ParseInt(o.BillingZipCode, 10, 32)
+	return &pb.Address{
+		StreetAddress: o.BillingStreetAddress,
+		City:          o.BillingCity,
+		State:         o.BillingState,
+		Country:       o.BillingCountry,
+		ZipCode:       int32(zip),
+	}
+}
+
+func (o *Order) totalPaid() *pb.Money {
+	return &pb.Money{
+		CurrencyCode: o.CurrencyCode,
+		Units:        o.OrderTotalUnits,
+		Nanos:        o.OrderTotalNanos,
+	}
+}
+
+func (o *Order) shippingCost() *pb.Money {
+	return &pb.Money{
+		CurrencyCode: o.CurrencyCode,
+		Units:        o.ShippingCostUnits,
+		Nanos:        o.ShippingCostNanos,
+	}
+}
+
+// discount returns nil for orders placed without a promo code or redeeming
+// points.
+func (o *Order) discount() *pb.Money {
+	if o.PromoCode == "" && o.PointsRedeemed == 0 {
+		return nil
+	}
+	return &pb.Money{
+		CurrencyCode: o.CurrencyCode,
+		Units:        o.DiscountUnits,
+		Nanos:        o.DiscountNanos,
+	}
+}
+
+// shippingQuote returns nil for orders placed before quotes were stored.
+func (o *Order) shippingQuote() *pb.Money {
+	if o.ShippingQuoteCurrencyCode == "" {
+		return nil
+	}
+	return &pb.Money{
+		CurrencyCode: o.ShippingQuoteCurrencyCode,
+		Units:        o.ShippingQuoteUnits,
+		Nanos:        o.ShippingQuoteNanos,
+	}
+}
+
+// serveEmailPreview exposes the confirmation email of the sample order over
+// HTTP so template changes can be reviewed without placing orders. Stored
+// orders hold customers' addresses, so they are only previewed on the debug
+// port, by handleEmailPreview.
+func (cs *checkoutService) serveEmailPreview(port string, life *lifecycle.Manager) {
+	mux := http.NewServeMux()
+	mux.Handle("/preview", emailtemplate.PreviewHandler(cs.emailRenderer, nil))
+	srv := &http.Server{Addr: ":" + port, Handler: mux}
+	life.OnDrain("email preview server", lifecycle.HTTPServer(srv))
+	log.Infof("serving email previews on :%s/preview", port)
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Warnf("email preview server stopped: %v", err)
+		}
+	}()
+}
+
+// handleEmailPreview adds the preview of stored orders' confirmation emails
+// to the debug mux, recording every order previewed in the audit log.
+func (cs *checkoutService) handleEmailPreview(mux *http.ServeMux, auditLog *audit.Log) {
+	mux.HandleFunc("/debug/email/preview", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		emailtemplate.PreviewHandler(cs.emailRenderer, cs.auditedOrderLoader(auditLog, r.RemoteAddr)).ServeHTTP(w, r)
+	})
+}
+
+// auditedOrderLoader loads the orders to preview, recording each as read by
+// actor.
+func (cs *checkoutService) auditedOrderLoader(auditLog *audit.Log, actor string) emailtemplate.OrderLoader {
+	return func(ctx context.Context, orderID string) (*emailtemplate.Order, error) {
+		o, err := cs.orderStore.GetOrder(ctx, orderID)
+		e := audit.Entry{
+			Actor:     actor,
+			Operation: "orders.PreviewEmail",
+			Details:   map[string]string{"order_id": orderID},
+			Outcome:   "ok",
+		}
+		if err != nil {
+			e.Outcome = err.Error()
+		}
+		if err := auditLog.Record(ctx, e); err != nil {
+			log.WithContext(ctx).Errorf("failed to audit orders.PreviewEmail: %v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := &emailtemplate.Order{}
+		o.applyTo(data)
+		return data, nil
+	}
+}
+
 func (cs *checkoutService) shipOrder(ctx context.Context, address *pb.Address, items []*pb.CartItem) (string, error) {
 	resp, err := pb.NewShippingServiceClient(cs.shippingSvcConn).ShipOrder(ctx, &pb.ShipOrderRequest{
 		Address: address,
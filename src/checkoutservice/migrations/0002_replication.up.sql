@@ -0,0 +1,38 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- See replication.go.
+CREATE TABLE IF NOT EXISTS order_outbox (
+    seq BIGSERIAL PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    region VARCHAR(50) NOT NULL,
+    epoch BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS replication_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    role VARCHAR(10) NOT NULL,
+    region VARCHAR(50) NOT NULL,
+    epoch BIGINT NOT NULL,
+    applied_seq BIGINT NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS replication_conflicts (
+    id BIGSERIAL PRIMARY KEY,
+    source_seq BIGINT NOT NULL,
+    order_id VARCHAR(50) NOT NULL,
+    reason TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
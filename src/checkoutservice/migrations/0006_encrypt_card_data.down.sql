@@ -0,0 +1,20 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The scrubbed card data cannot be restored.
+ALTER TABLE orders DROP COLUMN IF EXISTS card_envelope;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS credit_card_number VARCHAR(25);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS credit_card_cvv VARCHAR(4);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS credit_card_expiration_month INT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS credit_card_expiration_year INT;
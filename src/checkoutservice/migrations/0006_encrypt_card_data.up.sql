@@ -0,0 +1,30 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Card data is no longer kept in the clear: the CVV and expiry are not
+-- kept at all, and the masked number is kept, encrypted, in card_envelope
+-- (see cardcrypt). Existing rows and the order payloads kept for replication
+-- are scrubbed of their card data rather than encrypted, which SQL cannot do.
+ALTER TABLE orders DROP COLUMN IF EXISTS credit_card_cvv;
+ALTER TABLE orders DROP COLUMN IF EXISTS credit_card_number;
+ALTER TABLE orders DROP COLUMN IF EXISTS credit_card_expiration_month;
+ALTER TABLE orders DROP COLUMN IF EXISTS credit_card_expiration_year;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS card_envelope BYTEA;
+
+UPDATE order_outbox SET payload = payload
+    #- '{Order,CreditCardNumber}' #- '{Order,CreditCardCVV}'
+    #- '{Order,CreditCardExpirationMonth}' #- '{Order,CreditCardExpirationYear}';
+UPDATE replication_conflicts SET payload = payload
+    #- '{Order,CreditCardNumber}' #- '{Order,CreditCardCVV}'
+    #- '{Order,CreditCardExpirationMonth}' #- '{Order,CreditCardExpirationYear}';
@@ -0,0 +1,28 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Totals are rounded back to the cent.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS order_total DECIMAL(10, 2);
+UPDATE orders SET order_total = round(order_total_units + order_total_nanos / 1000000000.0, 2);
+ALTER TABLE orders DROP COLUMN IF EXISTS order_total_units;
+ALTER TABLE orders DROP COLUMN IF EXISTS order_total_nanos;
+
+UPDATE order_outbox SET payload = jsonb_set(payload #- '{Order,OrderTotalUnits}' #- '{Order,OrderTotalNanos}',
+    '{Order,OrderTotal}', to_jsonb(coalesce((payload #>> '{Order,OrderTotalUnits}')::numeric, 0)
+        + coalesce((payload #>> '{Order,OrderTotalNanos}')::numeric, 0) / 1000000000))
+    WHERE payload #> '{Order,OrderTotalUnits}' IS NOT NULL;
+UPDATE replication_conflicts SET payload = jsonb_set(payload #- '{Order,OrderTotalUnits}' #- '{Order,OrderTotalNanos}',
+    '{Order,OrderTotal}', to_jsonb(coalesce((payload #>> '{Order,OrderTotalUnits}')::numeric, 0)
+        + coalesce((payload #>> '{Order,OrderTotalNanos}')::numeric, 0) / 1000000000))
+    WHERE payload #> '{Order,OrderTotalUnits}' IS NOT NULL;
@@ -0,0 +1,33 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Order totals are kept as the units and nanos of a pb.Money rather than as a
+-- decimal, which only kept cents and was read through a float64. The order
+-- payloads kept for replication are rewritten the same way.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS order_total_units BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS order_total_nanos INT NOT NULL DEFAULT 0;
+UPDATE orders SET
+    order_total_units = trunc(order_total),
+    order_total_nanos = (order_total - trunc(order_total)) * 1000000000
+    WHERE order_total IS NOT NULL;
+ALTER TABLE orders DROP COLUMN IF EXISTS order_total;
+
+UPDATE order_outbox SET payload = jsonb_set(jsonb_set(payload #- '{Order,OrderTotal}',
+    '{Order,OrderTotalUnits}', to_jsonb(trunc((payload #>> '{Order,OrderTotal}')::numeric)::bigint)),
+    '{Order,OrderTotalNanos}', to_jsonb(round(((payload #>> '{Order,OrderTotal}')::numeric % 1) * 1000000000)::int))
+    WHERE payload #> '{Order,OrderTotal}' IS NOT NULL;
+UPDATE replication_conflicts SET payload = jsonb_set(jsonb_set(payload #- '{Order,OrderTotal}',
+    '{Order,OrderTotalUnits}', to_jsonb(trunc((payload #>> '{Order,OrderTotal}')::numeric)::bigint)),
+    '{Order,OrderTotalNanos}', to_jsonb(round(((payload #>> '{Order,OrderTotal}')::numeric % 1) * 1000000000)::int))
+    WHERE payload #> '{Order,OrderTotal}' IS NOT NULL;
@@ -0,0 +1,31 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- See compensation.go. Compensated orders were not stored, so order_id does
+-- not reference orders.
+CREATE TABLE IF NOT EXISTS order_compensations (
+    id BIGSERIAL PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    user_id VARCHAR(50) NOT NULL,
+    step VARCHAR(20) NOT NULL,
+    reference VARCHAR(100) NOT NULL,
+    amount_units BIGINT,
+    amount_nanos INT,
+    currency_code VARCHAR(3),
+    cause TEXT NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_order_compensations_order_id ON order_compensations (order_id);
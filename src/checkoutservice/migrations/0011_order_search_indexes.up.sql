@@ -0,0 +1,18 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- SearchOrders finds orders by email, newest first, and by currency and total;
+-- searches by date alone use idx_orders_created_at.
+CREATE INDEX IF NOT EXISTS idx_orders_email ON orders(lower(email), created_at DESC, order_id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_currency_total ON orders(currency_code, order_total_units, order_total_nanos);
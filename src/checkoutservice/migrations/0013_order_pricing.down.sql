@@ -0,0 +1,21 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_nanos;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_units;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_cost_nanos;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_cost_units;
+ALTER TABLE order_items DROP COLUMN IF EXISTS currency_code;
+ALTER TABLE order_items DROP COLUMN IF EXISTS unit_price_nanos;
+ALTER TABLE order_items DROP COLUMN IF EXISTS unit_price_units;
@@ -0,0 +1,52 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- See retention.go. The archive keeps the columns of orders and order_items
+-- as of this version: a migration that adds a column to one adds it to its
+-- archive too.
+CREATE TABLE IF NOT EXISTS orders_archive (
+    order_id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL,
+    email VARCHAR(255),
+    street_address VARCHAR(500),
+    city VARCHAR(100),
+    state VARCHAR(100),
+    country VARCHAR(100),
+    zip_code VARCHAR(20),
+    card_envelope BYTEA,
+    order_total_units BIGINT NOT NULL DEFAULT 0,
+    order_total_nanos INT NOT NULL DEFAULT 0,
+    currency_code VARCHAR(3),
+    shipping_tracking_id VARCHAR(100),
+    created_at TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'paid',
+    payment_transaction_id VARCHAR(100) NOT NULL DEFAULT '',
+    shipping_cost_units BIGINT NOT NULL DEFAULT 0,
+    shipping_cost_nanos INT NOT NULL DEFAULT 0,
+    tax_units BIGINT NOT NULL DEFAULT 0,
+    tax_nanos INT NOT NULL DEFAULT 0,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_orders_archive_user_id ON orders_archive(user_id);
+
+CREATE TABLE IF NOT EXISTS order_items_archive (
+    id INT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL REFERENCES orders_archive(order_id) ON DELETE CASCADE,
+    product_id VARCHAR(50) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price_units BIGINT NOT NULL DEFAULT 0,
+    unit_price_nanos INT NOT NULL DEFAULT 0,
+    currency_code VARCHAR(3) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_archive_order_id ON order_items_archive(order_id);
@@ -0,0 +1,30 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- One row per order event and webhook endpoint, appended with the event.
+-- See webhooks.go.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    event_id BIGINT NOT NULL REFERENCES order_events (id) ON DELETE CASCADE,
+    endpoint VARCHAR(2048) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMPTZ,
+    last_status INT,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ,
+    failed_at TIMESTAMPTZ,
+    PRIMARY KEY (event_id, endpoint)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (endpoint, next_attempt_at)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,24 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The fingerprints of the orders placed recently, which identical orders
+-- placed within the duplicate window are detected by. See duplicates.go.
+CREATE TABLE IF NOT EXISTS order_fingerprints (
+    fingerprint VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL,
+    order_id VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_order_fingerprints_created_at ON order_fingerprints (created_at);
+CREATE INDEX IF NOT EXISTS idx_order_fingerprints_user_id ON order_fingerprints (user_id);
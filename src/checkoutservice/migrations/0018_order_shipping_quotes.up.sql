@@ -0,0 +1,24 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Orders keep the shipping quote as the shipping service made it, before it
+-- was converted to the currency of the order, so the price that was quoted
+-- can be told apart from the price that was charged. Orders placed before
+-- are left with a zero quote and an empty quote currency.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_quote_units BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_quote_nanos INT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_quote_currency_code VARCHAR(3) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS shipping_quote_units BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS shipping_quote_nanos INT NOT NULL DEFAULT 0;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS shipping_quote_currency_code VARCHAR(3) NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Orders keep the billing address of their payment apart from the address
+-- they are shipped to. Orders placed before have an empty billing address.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_street_address VARCHAR(500) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_city VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_state VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_country VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_zip_code VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS billing_street_address VARCHAR(500) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS billing_city VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS billing_state VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS billing_country VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS billing_zip_code VARCHAR(20) NOT NULL DEFAULT '';
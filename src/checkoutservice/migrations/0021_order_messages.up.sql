@@ -0,0 +1,20 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Orders keep the gift message and the note the user placed them with.
+-- Orders placed before have neither.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS gift_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_note TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS gift_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS customer_note TEXT NOT NULL DEFAULT '';
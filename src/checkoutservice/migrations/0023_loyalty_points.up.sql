@@ -0,0 +1,26 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Loyalty points: the balance of each user, kept in user_points and updated
+-- in the transaction that saves an order, and the points each order earned
+-- and redeemed. See loyalty.go.
+CREATE TABLE IF NOT EXISTS user_points (
+    user_id VARCHAR(50) PRIMARY KEY,
+    balance BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS points_earned BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS points_redeemed BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS points_earned BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS points_redeemed BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,36 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The payment ledger: every attempt to charge the card of an order, recorded
+-- before the payment provider is called and finished with its result, so
+-- that a charge retried after a timeout reuses its idempotency key and one
+-- that went through is not charged again. See paymentattempts.go. Like
+-- compensations, attempts do not reference orders, and outlive them.
+CREATE TABLE IF NOT EXISTS payment_attempts (
+    id VARCHAR(36) PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    idempotency_key VARCHAR(100) NOT NULL,
+    attempt INT NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    amount_units BIGINT NOT NULL,
+    amount_nanos INT NOT NULL,
+    currency_code VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transaction_id VARCHAR(100) NOT NULL DEFAULT '',
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_payment_attempts_idempotency_key ON payment_attempts (idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_payment_attempts_order_id ON payment_attempts (order_id);
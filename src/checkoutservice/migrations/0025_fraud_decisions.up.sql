@@ -0,0 +1,34 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The decisions of the fraud check on orders, made before they are charged,
+-- with what each order was checked with. The velocity rule counts them by
+-- email address. See fraud.go.
+CREATE TABLE IF NOT EXISTS fraud_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    user_id VARCHAR(50) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    amount_units BIGINT NOT NULL,
+    amount_nanos INT NOT NULL,
+    currency_code VARCHAR(3) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    billing_country VARCHAR(100) NOT NULL,
+    card_bin VARCHAR(6) NOT NULL,
+    outcome VARCHAR(10) NOT NULL,
+    reasons TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_email ON fraud_decisions (email, created_at);
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_user_id ON fraud_decisions (user_id);
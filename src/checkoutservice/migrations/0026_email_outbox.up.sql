@@ -0,0 +1,31 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Order confirmations that could not be sent when their order was placed,
+-- queued as their encoded SendOrderConfirmationRequest to be sent again with
+-- backoff. A confirmation is deleted once sent, and kept with failed_at set
+-- once it ran out of attempts. See emailoutbox.go.
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    user_id VARCHAR(50) NOT NULL,
+    request BYTEA NOT NULL,
+    attempts INT NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_next_attempt_at ON email_outbox (next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_user_id ON email_outbox (user_id);
@@ -0,0 +1,24 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The masked card numbers cannot be restored.
+DROP TABLE IF EXISTS card_vault;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS card_envelope BYTEA;
+ALTER TABLE orders_archive DROP COLUMN IF EXISTS card_brand;
+ALTER TABLE orders_archive DROP COLUMN IF EXISTS card_last4;
+ALTER TABLE orders_archive DROP COLUMN IF EXISTS card_token;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS card_envelope BYTEA;
+ALTER TABLE orders DROP COLUMN IF EXISTS card_brand;
+ALTER TABLE orders DROP COLUMN IF EXISTS card_last4;
+ALTER TABLE orders DROP COLUMN IF EXISTS card_token;
@@ -0,0 +1,38 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Orders keep only the token of their card and what can be shown of it, its
+-- brand and last four digits; the card details behind the token are kept
+-- sealed in card_vault (see cardvault.go) until the order is charged. The
+-- masked numbers sealed in card_envelope cannot be opened by SQL, so orders
+-- placed before lose them, as do the order payloads kept for replication.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS card_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS card_last4 VARCHAR(4) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS card_brand VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE orders DROP COLUMN IF EXISTS card_envelope;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS card_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS card_last4 VARCHAR(4) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS card_brand VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE orders_archive DROP COLUMN IF EXISTS card_envelope;
+
+UPDATE order_outbox SET payload = payload #- '{Order,CardEnvelope}';
+UPDATE replication_conflicts SET payload = payload #- '{Order,CardEnvelope}';
+
+CREATE TABLE IF NOT EXISTS card_vault (
+    token VARCHAR(64) PRIMARY KEY,
+    envelope BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_card_vault_expires_at ON card_vault (expires_at);
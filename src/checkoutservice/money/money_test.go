@@ -16,6 +16,7 @@ package money
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
@@ -228,6 +229,8 @@ func TestSum(t *testing.T) {
 		{"mixed (larger positive, with borrow)", args{mm(11, 100000000), mm(-2, -9000000 /*.09*/)}, mm(9, 91000000 /*.091*/), nil},
 		{"mixed (larger negative, no borrow)", args{mm(-11, -100000000), mm(2, 100000000)}, mm(-9, 0), nil},
 		{"mixed (larger negative, with borrow)", args{mm(-11, -100000000), mm(2, 9000000 /*.09*/)}, mm(-9, -91000000 /*.091*/), nil},
+		{"mixed (units cancel out, positive)", args{mm(19, 990000000), mm(-19, -500000000)}, mm(0, 490000000), nil},
+		{"mixed (units cancel out, negative)", args{mm(1, 0), mm(-1, -990000000)}, mm(0, -990000000), nil},
 		{"0+negative", args{mm(0, 0), mm(-2, -100000000)}, mm(-2, -100000000), nil},
 		{"negative+0", args{mm(-2, -100000000), mm(0, 0)}, mm(-2, -100000000), nil},
 	}
@@ -243,3 +246,37 @@ func TestSum(t *testing.T) {
 		})
 	}
 }
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name             string
+		m                pb.Money
+		fromRate, toRate float64
+		want             pb.Money
+		wantErr          error
+	}{
+		{"same rate", mmc(12, 340000000, "EUR"), 1, 1, mmc(12, 340000000, "EUR"), nil},
+		{"from base", mmc(1, 0, "EUR"), 1, 1.1305, mmc(1, 130500000, "USD"), nil},
+		{"to base", mmc(1, 130500000, "USD"), 1.1305, 1, mmc(1, 0, "EUR"), nil},
+		{"cross rate", mmc(100, 0, "USD"), 1.1305, 126.40, mmc(11180, 893409996, "JPY"), nil},
+		{"rounds half away from zero", mmc(0, 1, "USD"), 2, 1, mmc(0, 1, "EUR"), nil},
+		{"negative", mmc(-1, -500000000, "EUR"), 1, 2, mmc(-3, 0, "USD"), nil},
+		{"zero", mmc(0, 0, "EUR"), 1, 1.1305, mmc(0, 0, "USD"), nil},
+		{"Error: invalid value", mm(1, -1), 1, 1, mm(0, 0), ErrInvalidValue},
+		{"Error: zero rate", mm(1, 0), 0, 1, mm(0, 0), ErrInvalidRate},
+		{"Error: negative rate", mm(1, 0), 1, -1, mm(0, 0), ErrInvalidRate},
+		{"Error: NaN rate", mm(1, 0), 1, math.NaN(), mm(0, 0), ErrInvalidRate},
+		{"Error: overflow", mm(math.MaxInt64, 0), 1, 2, mm(0, 0), ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.m, tt.fromRate, tt.toRate, tt.want.GetCurrencyCode())
+			if err != tt.wantErr {
+				t.Errorf("Convert([%v], %v, %v): expected err=\"%v\" got=\"%v\"", tt.m, tt.fromRate, tt.toRate, tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Convert([%v], %v, %v) = %v, want %v", tt.m, tt.fromRate, tt.toRate, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// Guests have no user ID to list their orders by, so every order placed with
+// an email address gets a lookup token, which the v2 LookupGuestOrder RPC
+// returns the order for. A token is the order ID and an HMAC-SHA256 of the
+// order ID and email address under ORDER_LOOKUP_KEY:
+//
+//	<order ID>.<base64url HMAC>
+//
+// Tokens are checked against the email address stored with the order, so
+// they stop working once the user's data is erased, and changing the key
+// invalidates every token issued with the previous one.
+
+var errInvalidLookupToken = errors.New("invalid lookup token")
+
+// orderLookup signs and checks lookup tokens. A nil *orderLookup issues no
+// tokens.
+type orderLookup struct {
+	key func(ctx context.Context) (string, error)
+}
+
+// newOrderLookupFromEnv returns the signer of lookup tokens under
+// ORDER_LOOKUP_KEY, or nil if it is not set.
+func newOrderLookupFromEnv(ctx context.Context, secretStore *secrets.Manager) (*orderLookup, error) {
+	l := &orderLookup{key: func(ctx context.Context) (string, error) {
+		return secretStore.Value(ctx, "ORDER_LOOKUP_KEY")
+	}}
+	key, err := l.key(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ORDER_LOOKUP_KEY: %w", err)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// token returns the lookup token of an order, or "" if l is nil or the
+// order has no email address.
+func (l *orderLookup) token(ctx context.Context, orderID, email string) (string, error) {
+	if l == nil || strings.TrimSpace(email) == "" {
+		return "", nil
+	}
+	mac, err := l.mac(ctx, orderID, email)
+	if err != nil {
+		return "", err
+	}
+	return orderID + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// orderID returns the ID of the order token claims to be for, and the
+// signature to check once the order is read.
+func (l *orderLookup) orderID(token string) (string, []byte, error) {
+	orderID, sig, ok := strings.Cut(token, ".")
+	if !ok || orderID == "" {
+		return "", nil, errInvalidLookupToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || len(mac) != sha256.Size {
+		return "", nil, errInvalidLookupToken
+	}
+	return orderID, mac, nil
+}
+
+// check returns errInvalidLookupToken unless mac signs o.
+func (l *orderLookup) check(ctx context.Context, o *Order, mac []byte) error {
+	if strings.TrimSpace(o.Email) == "" {
+		return errInvalidLookupToken
+	}
+	want, err := l.mac(ctx, o.OrderID, o.Email)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, want) {
+		return errInvalidLookupToken
+	}
+	return nil
+}
+
+func (l *orderLookup) mac(ctx context.Context, orderID, email string) ([]byte, error) {
+	key, err := l.key(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ORDER_LOOKUP_KEY: %w", err)
+	}
+	if key == "" {
+		return nil, errors.New("ORDER_LOOKUP_KEY is not set")
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(orderID))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+)
+
+func staticLookup(key string) *orderLookup {
+	return &orderLookup{key: func(context.Context) (string, error) { return key, nil }}
+}
+
+func TestOrderLookupToken(t *testing.T) {
+	ctx := context.Background()
+	l := staticLookup("s3cret")
+	o := &Order{OrderID: "order-1", Email: "Someone@example.com"}
+	token, err := l.token(ctx, o.OrderID, "someone@example.com ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderID, mac, err := l.orderID(token)
+	if err != nil || orderID != o.OrderID {
+		t.Fatalf("orderID(%q) = %q, %v, want %q", token, orderID, err, o.OrderID)
+	}
+	if err := l.check(ctx, o, mac); err != nil {
+		t.Errorf("check() = %v, want the token accepted", err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		l     *orderLookup
+		order *Order
+	}{
+		{"other email", l, &Order{OrderID: o.OrderID, Email: "other@example.com"}},
+		{"erased email", l, &Order{OrderID: o.OrderID}},
+		{"other key", staticLookup("rotated"), o},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.l.check(ctx, tt.order, mac); err != errInvalidLookupToken {
+				t.Errorf("check() = %v, want %v", err, errInvalidLookupToken)
+			}
+		})
+	}
+	for _, bad := range []string{"", "order-1", ".AAAA", "order-1.not base64", "order-1.AAAA"} {
+		if _, _, err := l.orderID(bad); err != errInvalidLookupToken {
+			t.Errorf("orderID(%q) = %v, want %v", bad, err, errInvalidLookupToken)
+		}
+	}
+
+	var none *orderLookup
+	if token, err := none.token(ctx, o.OrderID, o.Email); token != "" || err != nil {
+		t.Errorf("nil token() = %q, %v, want no token", token, err)
+	}
+	if token, err := l.token(ctx, o.OrderID, ""); token != "" || err != nil {
+		t.Errorf("token() without email = %q, %v, want no token", token, err)
+	}
+}
+
+func TestOrderLookupFromEnv(t *testing.T) {
+	// without ORDER_LOOKUP_KEY_SECRET the key is read from the environment,
+	// so no secret store is needed
+	t.Setenv("ORDER_LOOKUP_KEY", "")
+	if l, err := newOrderLookupFromEnv(context.Background(), nil); l != nil || err != nil {
+		t.Errorf("newOrderLookupFromEnv() unset = %v, %v, want nil", l, err)
+	}
+	t.Setenv("ORDER_LOOKUP_KEY", "s3cret")
+	if l, err := newOrderLookupFromEnv(context.Background(), nil); l == nil || err != nil {
+		t.Errorf("newOrderLookupFromEnv() = %v, %v, want a signer", l, err)
+	}
+}
+
+// TestLookupGuestOrder looks up an order placed through the v2 API by the
+// lookup token it was placed with.
+func TestLookupGuestOrder(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.orderStore = newMemoryOrderStore()
+	cs.lookups = staticLookup("s3cret")
+	req := placeOrderRequest("key-1")
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: req.UserId, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	s := newCheckoutServiceV2(cs)
+	placed, err := s.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed.GetLookupToken() == "" {
+		t.Fatal("PlaceOrder returned no lookup token")
+	}
+	retry, err := s.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.GetLookupToken() != placed.GetLookupToken() {
+		t.Errorf("replayed lookup token = %q, want %q", retry.GetLookupToken(), placed.GetLookupToken())
+	}
+
+	o, err := s.LookupGuestOrder(ctx, &pbv2.LookupGuestOrderRequest{LookupToken: placed.GetLookupToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.GetOrderId() != placed.GetOrder().GetOrderId() || len(o.GetItems()) != 1 {
+		t.Errorf("LookupGuestOrder = %v, want order %s", o, placed.GetOrder().GetOrderId())
+	}
+
+	tampered := placed.GetOrder().GetOrderId() + "x" + placed.GetLookupToken()[len(placed.GetOrder().GetOrderId()):]
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  codes.Code
+	}{
+		{"no token", "", codes.InvalidArgument},
+		{"tampered", tampered, codes.NotFound},
+		{"garbage", "not-a-token", codes.NotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.LookupGuestOrder(ctx, &pbv2.LookupGuestOrderRequest{LookupToken: tt.token})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("LookupGuestOrder() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	cs.lookups = nil
+	if _, err := s.LookupGuestOrder(ctx, &pbv2.LookupGuestOrderRequest{LookupToken: placed.GetLookupToken()}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("LookupGuestOrder() without ORDER_LOOKUP_KEY = %v, want FailedPrecondition", err)
+	}
+}
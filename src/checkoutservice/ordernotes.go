@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Staff add notes to orders through OrderAdminService, such as what a user
+// asked support about an order. Notes are kept in order_notes, which like
+// order_compensations does not reference orders: notes stay with the orders
+// that retention archives, and are deleted with those it deletes and with
+// the orders of erased users.
+
+// maxOrderNoteLen is the longest note text accepted, in bytes.
+const maxOrderNoteLen = 4096
+
+// OrderNote is a note staff added to an order.
+type OrderNote struct {
+	OrderID   string
+	Author    string
+	Text      string
+	CreatedAt time.Time
+}
+
+// adds a note to its order and returns every note of the order, oldest first
+func (os *OrderStore) AddOrderNote(ctx context.Context, n OrderNote) (_ []OrderNote, err error) {
+	ctx, span := startStoreSpan(ctx, "AddOrderNote")
+	defer func() { endSpan(span, err) }()
+	n.CreatedAt = time.Now().UTC()
+	var notes []OrderNote
+	err = withDBTimeout(ctx, os.timeouts.save, "AddOrderNote", func(ctx context.Context) error {
+		// a retry adds the note twice if the lost attempt was committed
+		return retryDB(ctx, "add note to order "+n.OrderID, func() error {
+			return inTx(ctx, os.db, func(tx *sql.Tx) error {
+				var exists int
+				err := tx.QueryRowContext(ctx, `SELECT 1 FROM orders WHERE order_id = $1`, n.OrderID).Scan(&exists)
+				if err == sql.ErrNoRows {
+					return fmt.Errorf("%w: %s", errOrderNotFound, n.OrderID)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to query order: %w", err)
+				}
+				if _, err := tx.ExecContext(ctx, `
+                    INSERT INTO order_notes (order_id, author, text, created_at) VALUES ($1, $2, $3, $4)
+                `, n.OrderID, n.Author, n.Text, n.CreatedAt); err != nil {
+					return fmt.Errorf("failed to insert order note: %w", err)
+				}
+				notes, err = getOrderNotes(ctx, tx, n.OrderID)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// getOrderNotes returns the notes of an order, oldest first.
+func getOrderNotes(ctx context.Context, q queryer, orderID string) ([]OrderNote, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT order_id, author, text, created_at FROM order_notes WHERE order_id = $1 ORDER BY id
+    `, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order notes: %w", err)
+	}
+	defer rows.Close()
+	var notes []OrderNote
+	for rows.Next() {
+		var n OrderNote
+		if err := rows.Scan(&n.OrderID, &n.Author, &n.Text, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
@@ -0,0 +1,317 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/instrumentation"
+)
+
+// When ORDER_QUEUE_DIR is set, an order that cannot be saved when it is
+// placed, typically because the database is briefly unavailable, is queued
+// in that directory instead of being compensated, and an orderQueue saves
+// it later, backing off between attempts. An order that still cannot be
+// saved after ORDER_QUEUE_MAX_ATTEMPTS attempts is moved to the dead/
+// subdirectory, from which an operator can move it back once the cause is
+// fixed. Each queued order is one JSON file, written to a temporary file
+// and renamed so that a crash never leaves a partial entry behind.
+//
+// Queued orders are not visible to GetOrder or GetUserOrders until they are
+// saved, and the directory should be on a persistent volume: orders queued
+// on a volume lost with the pod were charged but are never recorded.
+
+const (
+	defaultQueueMaxAttempts = 20
+	// queueInterval is how often the queue looks for orders to save.
+	queueInterval = time.Second
+	// queueMinBackoff and queueMaxBackoff bound the delay between attempts
+	// to save a queued order, which doubles after each failure.
+	queueMinBackoff = time.Second
+	queueMaxBackoff = 5 * time.Minute
+
+	queueExt = ".json"
+	// deadLetterDir is the subdirectory of the queue for orders that could
+	// not be saved.
+	deadLetterDir = "dead"
+)
+
+// queuedOrder is the entry of an order waiting to be saved.
+type queuedOrder struct {
+	Record      orderRecord
+	QueuedAt    time.Time
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string `json:",omitempty"`
+}
+
+// orderQueue saves the orders that could not be saved when they were placed.
+type orderQueue struct {
+	dir         string
+	store       *OrderStore
+	maxAttempts int
+	// save persists a record; it is store.saveRecord except in tests.
+	save func(context.Context, orderRecord) error
+	now  func() time.Time
+}
+
+// newOrderQueueFromEnv returns the queue of ORDER_QUEUE_DIR for store, or
+// nil if it is not set.
+func newOrderQueueFromEnv(store *OrderStore) (*orderQueue, error) {
+	dir := os.Getenv("ORDER_QUEUE_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+	q, err := newOrderQueue(dir, store.saveRecord)
+	if err != nil {
+		return nil, err
+	}
+	q.store = store
+	if v := os.Getenv("ORDER_QUEUE_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid ORDER_QUEUE_MAX_ATTEMPTS %q", v)
+		}
+		q.maxAttempts = n
+	}
+	if err := q.registerMetrics(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func newOrderQueue(dir string, save func(context.Context, orderRecord) error) (*orderQueue, error) {
+	if err := os.MkdirAll(filepath.Join(dir, deadLetterDir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the order queue: %w", err)
+	}
+	return &orderQueue{
+		dir:         dir,
+		maxAttempts: defaultQueueMaxAttempts,
+		save:        save,
+		now:         time.Now,
+	}, nil
+}
+
+func (q *orderQueue) registerMetrics() error {
+	meter := otel.Meter("checkoutservice")
+	if _, err := meter.Int64ObservableGauge(
+		"checkout.order_queue.depth",
+		metric.WithDescription("Orders waiting in the write-behind queue to be saved."),
+		metric.WithUnit("{order}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			if names, err := q.entries(q.dir); err == nil {
+				o.Observe(int64(len(names)))
+			}
+			return nil
+		}),
+	); err != nil {
+		return err
+	}
+	_, err := meter.Int64ObservableGauge(
+		"checkout.order_queue.dead_letters",
+		metric.WithDescription("Orders the write-behind queue gave up saving."),
+		metric.WithUnit("{order}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			if names, err := q.entries(filepath.Join(q.dir, deadLetterDir)); err == nil {
+				o.Observe(int64(len(names)))
+			}
+			return nil
+		}),
+	)
+	return err
+}
+
+// enqueue queues a newly placed order, as SaveOrder would save it.
+func (q *orderQueue) enqueue(rec orderRecord) error {
+	return q.push(rec.stamped(StatusPaid))
+}
+
+// push queues rec to be saved as soon as possible.
+func (q *orderQueue) push(rec orderRecord) error {
+	now := q.now()
+	entry := queuedOrder{Record: rec, QueuedAt: now, NextAttempt: now}
+	// the zero-padded time keeps the entries in the order they were queued
+	name := fmt.Sprintf("%020d-%s%s", now.UnixNano(), rec.Order.OrderID, queueExt)
+	if err := writeEntry(filepath.Join(q.dir, name), entry); err != nil {
+		return fmt.Errorf("failed to queue order %s: %w", rec.Order.OrderID, err)
+	}
+	return nil
+}
+
+// run saves the queued orders every queueInterval until ctx is done.
+func (q *orderQueue) run(ctx context.Context) {
+	t := time.NewTicker(queueInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := q.process(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("order queue: %v", err)
+		}
+	}
+}
+
+// process attempts to save each queued order whose next attempt is due. An
+// entry that cannot be read or updated is skipped, so that it does not hold
+// up the others.
+func (q *orderQueue) process(ctx context.Context) error {
+	names, err := q.entries(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := q.attempt(ctx, filepath.Join(q.dir, name)); err != nil {
+			log.Warnf("order queue: %v", err)
+		}
+	}
+	return nil
+}
+
+// attempt saves the order queued at path if it is due, removing the entry
+// once the order is saved, and otherwise records the failure and schedules
+// the next attempt, or moves the entry to the dead letters.
+func (q *orderQueue) attempt(ctx context.Context, path string) error {
+	entry, err := readEntry(path)
+	if err != nil {
+		return err
+	}
+	now := q.now()
+	if entry.NextAttempt.After(now) {
+		return nil
+	}
+	orderID := entry.Record.Order.OrderID
+	ctx = instrumentation.WithBaggage(ctx, map[string]string{instrumentation.BaggageOrderID: orderID})
+
+	serr := q.save(ctx, entry.Record)
+	if serr == nil || errors.Is(serr, errOrderExists) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to dequeue order %s: %w", orderID, err)
+		}
+		log.WithContext(ctx).Infof("order queue: saved order %s after %d failed attempts", orderID, entry.Attempts+1)
+		return nil
+	}
+	if ctx.Err() != nil {
+		// shutting down is not a failure of the order
+		return nil
+	}
+
+	entry.Attempts++
+	entry.LastError = serr.Error()
+	if entry.Attempts >= q.maxAttempts {
+		dead := filepath.Join(q.dir, deadLetterDir, filepath.Base(path))
+		if err := writeEntry(dead, entry); err != nil {
+			return fmt.Errorf("failed to dead-letter order %s: %w", orderID, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to dequeue order %s: %w", orderID, err)
+		}
+		log.WithContext(ctx).Errorf("order queue: gave up saving order %s after %d attempts: %v", orderID, entry.Attempts, serr)
+		return nil
+	}
+	entry.NextAttempt = now.Add(queueBackoff(entry.Attempts))
+	log.WithContext(ctx).Warnf("order queue: failed to save order %s (attempt %d of %d): %v", orderID, entry.Attempts, q.maxAttempts, serr)
+	return writeEntry(path, entry)
+}
+
+// queueBackoff returns the delay before the next attempt to save an order
+// after the given number of failed attempts.
+func queueBackoff(attempts int) time.Duration {
+	d := queueMinBackoff
+	for i := 1; i < attempts && d < queueMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, queueMaxBackoff)
+}
+
+// entries returns the names of the queued orders in dir, oldest first.
+func (q *orderQueue) entries(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the order queue: %w", err)
+	}
+	var names []string
+	for _, f := range files {
+		if f.Type().IsRegular() && strings.HasSuffix(f.Name(), queueExt) && !strings.HasPrefix(f.Name(), ".") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readEntry(path string) (queuedOrder, error) {
+	var entry queuedOrder
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read queued order: %w", err)
+	}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return entry, fmt.Errorf("invalid queued order %s: %w", filepath.Base(path), err)
+	}
+	return entry, nil
+}
+
+// writeEntry replaces the file at path with entry, atomically.
+func writeEntry(path string, entry queuedOrder) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, ".tmp-*"+queueExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	// the rename is only durable once the directory is synced
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
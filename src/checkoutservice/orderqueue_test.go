@@ -0,0 +1,163 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestQueue(t *testing.T, save func(context.Context, orderRecord) error) (*orderQueue, *fakeClock) {
+	t.Helper()
+	q, err := newOrderQueue(t.TempDir(), save)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q.now = clock.now
+	return q, clock
+}
+
+func queueDepth(t *testing.T, q *orderQueue, dir string) int {
+	t.Helper()
+	names, err := q.entries(filepath.Join(q.dir, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(names)
+}
+
+func TestOrderQueueRetries(t *testing.T) {
+	var (
+		saved []string
+		fail  = true
+	)
+	q, clock := newTestQueue(t, func(_ context.Context, rec orderRecord) error {
+		if fail {
+			return errors.New("database unavailable")
+		}
+		saved = append(saved, rec.Order.OrderID)
+		return nil
+	})
+	ctx := context.Background()
+	for _, id := range []string{"order-1", "order-2"} {
+		if err := q.push(orderRecord{Order: Order{OrderID: id}}); err != nil {
+			t.Fatal(err)
+		}
+		clock.t = clock.t.Add(time.Millisecond)
+	}
+
+	if err := q.process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := q.entries(q.dir)
+	entry, err := readEntry(filepath.Join(q.dir, names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Attempts != 1 || entry.LastError != "database unavailable" || !entry.NextAttempt.Equal(clock.t.Add(queueMinBackoff)) {
+		t.Errorf("entry after a failed attempt = %+v, want 1 attempt retried in %v", entry, queueMinBackoff)
+	}
+
+	// the orders are not retried before their backoff
+	fail = false
+	if err := q.process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 0 {
+		t.Errorf("saved %v before the backoff", saved)
+	}
+
+	clock.t = clock.t.Add(queueMinBackoff)
+	if err := q.process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(saved) != "[order-1 order-2]" {
+		t.Errorf("saved %v, want order-1 then order-2", saved)
+	}
+	if n := queueDepth(t, q, ""); n != 0 {
+		t.Errorf("%d orders still queued, want none", n)
+	}
+}
+
+func TestOrderQueueDeadLetters(t *testing.T) {
+	q, clock := newTestQueue(t, func(context.Context, orderRecord) error {
+		return errors.New("constraint violated")
+	})
+	q.maxAttempts = 3
+	ctx := context.Background()
+	if err := q.push(orderRecord{Order: Order{OrderID: "order-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < q.maxAttempts; i++ {
+		if err := q.process(ctx); err != nil {
+			t.Fatal(err)
+		}
+		clock.t = clock.t.Add(queueMaxBackoff)
+	}
+	if n := queueDepth(t, q, ""); n != 0 {
+		t.Errorf("%d orders still queued, want none", n)
+	}
+	if n := queueDepth(t, q, deadLetterDir); n != 1 {
+		t.Fatalf("%d dead letters, want 1", n)
+	}
+	names, _ := q.entries(filepath.Join(q.dir, deadLetterDir))
+	entry, err := readEntry(filepath.Join(q.dir, deadLetterDir, names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Record.Order.OrderID != "order-1" || entry.Attempts != 3 || entry.LastError != "constraint violated" {
+		t.Errorf("dead letter = %+v, want order-1 after 3 attempts", entry)
+	}
+}
+
+func TestOrderQueueSavedOrders(t *testing.T) {
+	q, _ := newTestQueue(t, func(context.Context, orderRecord) error {
+		return fmt.Errorf("%w: order-1", errOrderExists)
+	})
+	if err := q.push(orderRecord{Order: Order{OrderID: "order-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.process(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := queueDepth(t, q, ""); n != 0 {
+		t.Errorf("an order that was saved is still queued")
+	}
+}
+
+func TestQueueBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		9:  256 * time.Second,
+		10: queueMaxBackoff,
+		60: queueMaxBackoff,
+	} {
+		if got := queueBackoff(attempts); got != want {
+			t.Errorf("queueBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// OrderSearch selects the orders of any user, for support staff looking for
+// an order without its ID. Its OrderQuery pages and filters the orders that
+// meet all of its other fields, which are ignored when zero.
+type OrderSearch struct {
+	OrderQuery
+	// UserID selects the orders of one user.
+	UserID string
+	// Email selects the orders placed with it, ignoring case.
+	Email string
+	// CurrencyCode selects the orders paid in it.
+	CurrencyCode string
+	// MinTotal and MaxTotal select the orders whose total, in CurrencyCode,
+	// is at least MinTotal and at most MaxTotal.
+	MinTotal, MaxTotal *pb.Money
+}
+
+// sql returns the query selecting the orders of s, and its arguments.
+func (s OrderSearch) sql(d dialect) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	if s.UserID != "" {
+		// matches idx_orders_user_id_created_at
+		args = append(args, s.UserID)
+		conds = append(conds, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if s.Email != "" {
+		// matches idx_orders_email
+		args = append(args, strings.ToLower(s.Email))
+		conds = append(conds, fmt.Sprintf("lower(email) = $%d", len(args)))
+	}
+	if s.CurrencyCode != "" {
+		args = append(args, s.CurrencyCode)
+		conds = append(conds, fmt.Sprintf("currency_code = $%d", len(args)))
+	}
+	for _, bound := range []struct {
+		total *pb.Money
+		op    string
+	}{{s.MinTotal, ">="}, {s.MaxTotal, "<="}} {
+		if bound.total == nil {
+			continue
+		}
+		args = append(args, bound.total.GetUnits(), bound.total.GetNanos())
+		conds = append(conds, fmt.Sprintf("(order_total_units, order_total_nanos) %s ($%d, $%d)", bound.op, len(args)-1, len(args)))
+	}
+	return s.OrderQuery.selectSQL(d, conds, args)
+}
+
+// matches reports whether s selects o, ignoring its limit.
+func (s OrderSearch) matches(o Order) bool {
+	switch {
+	case !s.OrderQuery.matches(o):
+		return false
+	case s.UserID != "" && o.UserID != s.UserID:
+		return false
+	case s.Email != "" && !strings.EqualFold(o.Email, s.Email):
+		return false
+	case s.CurrencyCode != "" && o.CurrencyCode != s.CurrencyCode:
+		return false
+	case s.MinTotal != nil && compareTotal(o, s.MinTotal) < 0:
+		return false
+	case s.MaxTotal != nil && compareTotal(o, s.MaxTotal) > 0:
+		return false
+	}
+	return true
+}
+
+// compareTotal compares the total of o with m, in the same currency.
+func compareTotal(o Order, m *pb.Money) int {
+	if c := cmp.Compare(o.OrderTotalUnits, m.GetUnits()); c != 0 {
+		return c
+	}
+	return cmp.Compare(o.OrderTotalNanos, m.GetNanos())
+}
+
+// retrieves the orders of any user selected by s
+func (os *OrderStore) SearchOrders(ctx context.Context, s OrderSearch) (_ []Order, err error) {
+	ctx, span := startStoreSpan(ctx, "SearchOrders")
+	defer func() { endSpan(span, err) }()
+	var orders []Order
+	query, args := s.sql(os.dialect)
+	err = withDBTimeout(ctx, os.timeouts.read, "SearchOrders", func(ctx context.Context) error {
+		return retryDB(ctx, "search orders", func() (err error) {
+			return os.read(ctx, func(db *sql.DB) (err error) {
+				orders, err = os.getOrders(ctx, db, query, args)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+)
+
+// OrderStatus is the stage of an order's lifecycle, as stored in the status
+// column of orders.
+type OrderStatus string
+
+// Orders are pending from before they are charged until they are placed,
+// when they are paid, or failed if they could not be; see
+// OrderStorage.SavePendingOrder. Paid orders are then either shipped and
+// delivered, or cancelled before they are shipped.
+const (
+	StatusPending   OrderStatus = "pending"
+	StatusFailed    OrderStatus = "failed"
+	StatusPaid      OrderStatus = "paid"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+)
+
+// orderTransitions lists the statuses each status can change to. Only
+// placing an order changes it from pending.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   nil,
+	StatusFailed:    nil,
+	StatusPaid:      {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: nil,
+	StatusCancelled: nil,
+}
+
+// attemptStatuses are those of orders that were not placed, which queries
+// only select when asked for them by status.
+var attemptStatuses = []OrderStatus{StatusPending, StatusFailed}
+
+// errInvalidStatusTransition is returned when an order cannot change from
+// its status to the one requested.
+var errInvalidStatusTransition = errors.New("invalid order status transition")
+
+// checkTransition fails with errInvalidStatusTransition unless an order can
+// change from status s to to. Keeping the same status is allowed, so that
+// updates can be retried.
+func (s OrderStatus) checkTransition(to OrderStatus) error {
+	if s == to {
+		return nil
+	}
+	for _, next := range orderTransitions[s] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: an order that is %s cannot be %s", errInvalidStatusTransition, s, to)
+}
+
+var orderStatusProtos = map[OrderStatus]pbv2.OrderStatus{
+	StatusPending:   pbv2.OrderStatus_ORDER_STATUS_PENDING,
+	StatusFailed:    pbv2.OrderStatus_ORDER_STATUS_FAILED,
+	StatusPaid:      pbv2.OrderStatus_ORDER_STATUS_PAID,
+	StatusShipped:   pbv2.OrderStatus_ORDER_STATUS_SHIPPED,
+	StatusDelivered: pbv2.OrderStatus_ORDER_STATUS_DELIVERED,
+	StatusCancelled: pbv2.OrderStatus_ORDER_STATUS_CANCELLED,
+}
+
+func (s OrderStatus) proto() pbv2.OrderStatus {
+	return orderStatusProtos[s]
+}
+
+// orderStatusFromProto returns the status p stands for, and false for
+// ORDER_STATUS_UNSPECIFIED and unknown values.
+func orderStatusFromProto(p pbv2.OrderStatus) (OrderStatus, bool) {
+	for s, sp := range orderStatusProtos {
+		if sp == p {
+			return s, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+)
+
+func TestCheckTransition(t *testing.T) {
+	for _, tt := range []struct {
+		from, to OrderStatus
+		ok       bool
+	}{
+		{StatusPaid, StatusShipped, true},
+		{StatusPaid, StatusCancelled, true},
+		{StatusShipped, StatusDelivered, true},
+		{StatusShipped, StatusShipped, true},
+		{StatusPaid, StatusDelivered, false},
+		{StatusShipped, StatusCancelled, false},
+		{StatusDelivered, StatusPaid, false},
+		{StatusCancelled, StatusShipped, false},
+	} {
+		err := tt.from.checkTransition(tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("%s -> %s: %v", tt.from, tt.to, err)
+		}
+		if !tt.ok && !errors.Is(err, errInvalidStatusTransition) {
+			t.Errorf("%s -> %s: got %v, want errInvalidStatusTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestOrderStatusProto(t *testing.T) {
+	for s := range orderTransitions {
+		p := s.proto()
+		if p == pbv2.OrderStatus_ORDER_STATUS_UNSPECIFIED {
+			t.Errorf("%s has no proto value", s)
+		}
+		if got, ok := orderStatusFromProto(p); !ok || got != s {
+			t.Errorf("orderStatusFromProto(%v) = %q, %v, want %q", p, got, ok, s)
+		}
+	}
+	if _, ok := orderStatusFromProto(pbv2.OrderStatus_ORDER_STATUS_UNSPECIFIED); ok {
+		t.Error("ORDER_STATUS_UNSPECIFIED is a valid status")
+	}
+}
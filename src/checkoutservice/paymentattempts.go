@@ -0,0 +1,283 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// Every attempt to charge the card of an order is kept in the payment
+// ledger, payment_attempts: it is recorded as started before the payment
+// provider is called, and finished with the result. Each order is charged
+// with one idempotency key, derived from its ID, which the provider charges
+// once however often it is sent. An attempt whose outcome is unknown, such as
+// one that timed out, is retried with the same key, up to paymentAttempts
+// times, and an order the ledger records as charged is not charged again.
+// Attempts still started after interruptedOrderAge were interrupted with the
+// order, and tell whether its card has to be reconciled. Writing to the
+// ledger is best effort: a charge is not given up because it could not be
+// recorded.
+
+const (
+	// paymentAttempts is how often a charge whose outcome is unknown is
+	// tried.
+	paymentAttempts = 3
+	// paymentAttemptTimeout bounds each attempt, so that one is left for a
+	// retry within the deadline of PlaceOrder.
+	paymentAttemptTimeout = 10 * time.Second
+)
+
+// PaymentAttemptStatus is the result of a payment attempt.
+type PaymentAttemptStatus string
+
+const (
+	// AttemptStarted is an attempt whose provider call has not returned.
+	AttemptStarted PaymentAttemptStatus = "started"
+	// AttemptSucceeded charged the card.
+	AttemptSucceeded PaymentAttemptStatus = "succeeded"
+	// AttemptFailed was refused, so the card was not charged.
+	AttemptFailed PaymentAttemptStatus = "failed"
+	// AttemptUnknown failed in a way that may have charged the card.
+	AttemptUnknown PaymentAttemptStatus = "unknown"
+)
+
+// PaymentAttempt is an attempt to charge the card of an order.
+type PaymentAttempt struct {
+	ID             string
+	OrderID        string
+	IdempotencyKey string
+	// Attempt counts the attempts with IdempotencyKey, from 1.
+	Attempt  int
+	Provider string
+	Amount   *pb.Money
+	Status   PaymentAttemptStatus
+	// TransactionID is that of the charge if the attempt succeeded, and
+	// Error the error it failed with otherwise.
+	TransactionID string
+	Error         string
+	CreatedAt     time.Time
+	FinishedAt    time.Time
+}
+
+// chargeKey returns the idempotency key the card of orderID is charged with.
+func chargeKey(orderID string) string {
+	return "charge-" + orderID
+}
+
+// chargeCard charges amount to card for orderID, unless the ledger records
+// it charged, and returns the ID of the transaction.
+func (cs *checkoutService) chargeCard(ctx context.Context, orderID string, amount *pb.Money, card cardvault.Card) (string, error) {
+	key := chargeKey(orderID)
+	prior := cs.paymentAttempts(ctx, key)
+	for _, a := range prior {
+		if a.Status == AttemptSucceeded {
+			log.WithContext(ctx).Infof("order %s was already charged by attempt %d", orderID, a.Attempt)
+			return a.TransactionID, nil
+		}
+	}
+	var err error
+	for i := range paymentAttempts {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return "", fmt.Errorf("could not charge the card: %+v", err)
+			case <-time.After(time.Duration(100<<i) * time.Millisecond):
+			}
+		}
+		a := &PaymentAttempt{
+			ID:             uuid.NewString(),
+			OrderID:        orderID,
+			IdempotencyKey: key,
+			Attempt:        len(prior) + i + 1,
+			Provider:       providerName(cs.payments),
+			Amount:         amount,
+			Status:         AttemptStarted,
+			CreatedAt:      time.Now().UTC(),
+		}
+		cs.recordPaymentAttempt(ctx, a)
+		attemptCtx, cancel := context.WithTimeout(ctx, paymentAttemptTimeout)
+		var txID string
+		txID, err = cs.payments.Charge(attemptCtx, orderID, key, amount, card)
+		cancel()
+		cs.finishPaymentAttempt(ctx, a, txID, err)
+		if err == nil {
+			return txID, nil
+		}
+		if !errors.Is(err, errPaymentOutcomeUnknown) {
+			break
+		}
+		log.WithContext(ctx).Warnf("attempt %d to charge order %s may have charged the card, retrying with the same idempotency key: %v", a.Attempt, orderID, err)
+	}
+	return "", fmt.Errorf("could not charge the card: %+v", err)
+}
+
+// paymentAttempts returns the attempts of the ledger with key, none if they
+// cannot be read.
+func (cs *checkoutService) paymentAttempts(ctx context.Context, key string) []PaymentAttempt {
+	if cs.orderStore == nil {
+		return nil
+	}
+	attempts, err := cs.orderStore.GetPaymentAttempts(ctx, key)
+	if err != nil {
+		log.WithContext(ctx).Warnf("failed to read payment attempts with idempotency key %q: %v", key, err)
+	}
+	return attempts
+}
+
+// recordPaymentAttempt records a started attempt in the ledger.
+func (cs *checkoutService) recordPaymentAttempt(ctx context.Context, a *PaymentAttempt) {
+	if cs.orderStore == nil {
+		return
+	}
+	if err := cs.orderStore.StartPaymentAttempt(ctx, *a); err != nil {
+		log.WithContext(ctx).Warnf("failed to record attempt %d to charge order %s, charging anyway: %v", a.Attempt, a.OrderID, err)
+	}
+}
+
+// finishPaymentAttempt records the result of an attempt in the ledger, even
+// if ctx is cancelled.
+func (cs *checkoutService) finishPaymentAttempt(ctx context.Context, a *PaymentAttempt, txID string, err error) {
+	a.FinishedAt = time.Now().UTC()
+	switch {
+	case err == nil:
+		a.Status, a.TransactionID = AttemptSucceeded, txID
+	case errors.Is(err, errPaymentOutcomeUnknown):
+		a.Status, a.Error = AttemptUnknown, err.Error()
+	default:
+		a.Status, a.Error = AttemptFailed, err.Error()
+	}
+	if cs.orderStore == nil {
+		return
+	}
+	if err := cs.orderStore.FinishPaymentAttempt(context.WithoutCancel(ctx), *a); err != nil {
+		log.WithContext(ctx).Warnf("failed to record the result of attempt %d to charge order %s (%s): %v", a.Attempt, a.OrderID, a.Status, err)
+	}
+}
+
+// providerName returns the PAYMENT_PROVIDER of p.
+func providerName(p ChargeProvider) string {
+	switch p.(type) {
+	case *paymentServiceProvider:
+		return providerPaymentService
+	case *stripeProvider:
+		return providerStripe
+	default:
+		return fmt.Sprintf("%T", p)
+	}
+}
+
+// records an attempt as started
+func (os *OrderStore) StartPaymentAttempt(ctx context.Context, a PaymentAttempt) (err error) {
+	ctx, span := startStoreSpan(ctx, "StartPaymentAttempt")
+	defer func() { endSpan(span, err) }()
+	err = withDBTimeout(ctx, os.timeouts.save, "StartPaymentAttempt", func(ctx context.Context) error {
+		return retryDB(ctx, "record payment attempt of order "+a.OrderID, func() error {
+			_, err := os.db.ExecContext(ctx, `
+                INSERT INTO payment_attempts (
+                    id, order_id, idempotency_key, attempt, provider, amount_units, amount_nanos, currency_code, status, created_at
+                ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+            `+os.dialect.ignoreDuplicate("id"), a.ID, a.OrderID, a.IdempotencyKey, a.Attempt, a.Provider,
+				a.Amount.GetUnits(), a.Amount.GetNanos(), a.Amount.GetCurrencyCode(), AttemptStarted, a.CreatedAt)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert payment attempt: %w", err)
+	}
+	return nil
+}
+
+// records the result of a started attempt
+func (os *OrderStore) FinishPaymentAttempt(ctx context.Context, a PaymentAttempt) (err error) {
+	ctx, span := startStoreSpan(ctx, "FinishPaymentAttempt")
+	defer func() { endSpan(span, err) }()
+	var errText any
+	if a.Error != "" {
+		errText = a.Error
+	}
+	err = withDBTimeout(ctx, os.timeouts.save, "FinishPaymentAttempt", func(ctx context.Context) error {
+		return retryDB(ctx, "finish payment attempt of order "+a.OrderID, func() error {
+			_, err := os.db.ExecContext(ctx, `
+                UPDATE payment_attempts SET status = $2, transaction_id = $3, error = $4, finished_at = $5 WHERE id = $1
+            `, a.ID, a.Status, a.TransactionID, errText, a.FinishedAt)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payment attempt: %w", err)
+	}
+	return nil
+}
+
+// returns the attempts with an idempotency key, earliest first
+func (os *OrderStore) GetPaymentAttempts(ctx context.Context, key string) (_ []PaymentAttempt, err error) {
+	ctx, span := startStoreSpan(ctx, "GetPaymentAttempts")
+	defer func() { endSpan(span, err) }()
+	var attempts []PaymentAttempt
+	// attempts are read from the primary, since the one just recorded
+	// decides whether to charge
+	err = withDBTimeout(ctx, os.timeouts.read, "GetPaymentAttempts", func(ctx context.Context) error {
+		return retryDB(ctx, "get payment attempts", func() (err error) {
+			attempts, err = getPaymentAttempts(ctx, os.db, key)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func getPaymentAttempts(ctx context.Context, q queryer, key string) ([]PaymentAttempt, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT id, order_id, idempotency_key, attempt, provider, amount_units, amount_nanos, currency_code,
+            status, transaction_id, error, created_at, finished_at
+        FROM payment_attempts WHERE idempotency_key = $1 ORDER BY attempt
+    `, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment attempts: %w", err)
+	}
+	defer rows.Close()
+	var attempts []PaymentAttempt
+	for rows.Next() {
+		var (
+			a          PaymentAttempt
+			amount     pb.Money
+			errText    *string
+			finishedAt *time.Time
+		)
+		if err := rows.Scan(&a.ID, &a.OrderID, &a.IdempotencyKey, &a.Attempt, &a.Provider, &amount.Units, &amount.Nanos, &amount.CurrencyCode,
+			&a.Status, &a.TransactionID, &errText, &a.CreatedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan payment attempt: %w", err)
+		}
+		a.Amount = &amount
+		if errText != nil {
+			a.Error = *errText
+		}
+		if finishedAt != nil {
+			a.FinishedAt = *finishedAt
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
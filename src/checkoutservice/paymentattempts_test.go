@@ -0,0 +1,104 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+)
+
+func TestChargeRetriedAfterLostResponse(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newSQLiteStore(t)
+	cs.orderStore = store
+	backends.payment.LoseResponses(1)
+	req := placeOrderRequest("key-1")
+	req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+	placed, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderID := placed.GetOrder().GetOrderId()
+
+	charges := backends.payment.Charges()
+	if len(charges) != 1 || charges[0].GetIdempotencyKey() != chargeKey(orderID) {
+		t.Fatalf("charges = %v, want one with idempotency key %s", charges, chargeKey(orderID))
+	}
+	attempts, err := store.GetPaymentAttempts(ctx, chargeKey(orderID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0].Status != AttemptUnknown || attempts[1].Status != AttemptSucceeded {
+		t.Fatalf("attempts = %+v, want one unknown and one succeeded", attempts)
+	}
+	o, err := store.GetOrder(ctx, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := attempts[1]; a.Attempt != 2 || a.Provider != providerPaymentService || a.TransactionID != o.PaymentTransactionID || a.FinishedAt.IsZero() {
+		t.Errorf("second attempt = %+v, want attempt 2 of %s with transaction %s", a, providerPaymentService, o.PaymentTransactionID)
+	}
+	if a := attempts[0]; a.Error == "" || a.Amount.GetUnits() != o.OrderTotalUnits || a.Amount.GetCurrencyCode() != "USD" {
+		t.Errorf("first attempt = %+v, want the error and the total of the order", a)
+	}
+}
+
+func TestChargeCardReusesLedger(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	amount := &pb.Money{CurrencyCode: "USD", Units: 10}
+	if err := store.StartPaymentAttempt(ctx, PaymentAttempt{ID: "attempt-1", OrderID: "order-1", IdempotencyKey: chargeKey("order-1"), Attempt: 1, Amount: amount}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishPaymentAttempt(ctx, PaymentAttempt{ID: "attempt-1", OrderID: "order-1", IdempotencyKey: chargeKey("order-1"), Attempt: 1, Amount: amount,
+		Status: AttemptSucceeded, TransactionID: "transaction-1", FinishedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	txID, err := cs.chargeCard(ctx, "order-1", amount, tokenize(t, cs, contract.ValidCard()))
+	if err != nil || txID != "transaction-1" {
+		t.Errorf("chargeCard() = %q, %v; want the recorded transaction-1", txID, err)
+	}
+	if n := len(backends.payment.Charges()); n != 0 {
+		t.Errorf("%d charges, want none for an order already charged", n)
+	}
+}
+
+func TestDeclinedChargeNotRetried(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	expired := contract.ValidCard()
+	expired.CreditCardExpirationYear -= 2
+	if _, err := cs.chargeCard(ctx, "order-1", &pb.Money{CurrencyCode: "USD", Units: 10}, tokenize(t, cs, expired)); err == nil {
+		t.Fatal("chargeCard() with an expired card succeeded")
+	}
+	attempts, _ := store.GetPaymentAttempts(ctx, chargeKey("order-1"))
+	if len(attempts) != 1 || attempts[0].Status != AttemptFailed {
+		t.Errorf("attempts = %+v, want one failed", attempts)
+	}
+	if n := len(backends.payment.Charges()); n != 0 {
+		t.Errorf("%d charges, want none", n)
+	}
+}
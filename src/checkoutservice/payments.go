@@ -0,0 +1,117 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/breaker"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// Cards are charged through the ChargeProvider named by PAYMENT_PROVIDER:
+// paymentservice (the default), the hipstershop.PaymentService at
+// PAYMENT_SERVICE_ADDR, or stripe, the Stripe API in test mode (see
+// stripe.go).
+
+const (
+	providerPaymentService = "paymentservice"
+	providerStripe         = "stripe"
+)
+
+// errPaymentOutcomeUnknown is wrapped by the errors of charges that may or
+// may not have gone through, such as those that timed out, which are
+// retried with the same idempotency key; see paymentattempts.go.
+var errPaymentOutcomeUnknown = errors.New("payment outcome unknown")
+
+// ChargeProvider charges the cards orders are paid with, and refunds the
+// charges of orders that are compensated or cancelled.
+type ChargeProvider interface {
+	// Charge charges amount to card for orderID and returns the ID of the
+	// transaction, exchanging the token of card for its details. Charging again with the same idempotencyKey returns the
+	// same transaction instead of charging twice. Its error wraps
+	// errPaymentOutcomeUnknown if the card may have been charged.
+	Charge(ctx context.Context, orderID, idempotencyKey string, amount *pb.Money, card cardvault.Card) (string, error)
+	// Refund refunds amount, the whole amount of the transaction, which
+	// succeeds again for a transaction already refunded.
+	Refund(ctx context.Context, transactionID string, amount *pb.Money) error
+}
+
+// paymentProviderFromEnv returns the provider named by PAYMENT_PROVIDER,
+// with conn the connection to the payment service, which is nil unless
+// PAYMENT_PROVIDER is paymentservice, and cards the vault of the cards it
+// charges.
+func paymentProviderFromEnv(secretStore *secrets.Manager, conn *grpc.ClientConn, cards cardvault.Vault) (ChargeProvider, error) {
+	switch v := os.Getenv("PAYMENT_PROVIDER"); v {
+	case "", providerPaymentService:
+		return &paymentServiceProvider{conn: conn, cards: cards}, nil
+	case providerStripe:
+		return newStripeProvider(secretStore, os.Getenv("STRIPE_API_URL"), cards)
+	default:
+		return nil, fmt.Errorf("invalid PAYMENT_PROVIDER %q: want %s or %s", v, providerPaymentService, providerStripe)
+	}
+}
+
+// usesPaymentService reports whether PAYMENT_PROVIDER charges cards through
+// the payment service.
+func usesPaymentService() bool {
+	v := os.Getenv("PAYMENT_PROVIDER")
+	return v == "" || v == providerPaymentService
+}
+
+// paymentServiceProvider charges cards with hipstershop.PaymentService.
+type paymentServiceProvider struct {
+	conn  *grpc.ClientConn
+	cards cardvault.Vault
+}
+
+func (p *paymentServiceProvider) String() string { return "the payment service" }
+
+func (p *paymentServiceProvider) Charge(ctx context.Context, _, idempotencyKey string, amount *pb.Money, card cardvault.Card) (string, error) {
+	details, err := p.cards.Detokenize(ctx, card.Token)
+	if err != nil {
+		return "", err
+	}
+	resp, err := pb.NewPaymentServiceClient(p.conn).Charge(ctx, &pb.ChargeRequest{
+		Amount:         amount,
+		CreditCard:     details,
+		IdempotencyKey: idempotencyKey})
+	if rpcerrors.Classify(err).Reason == breaker.ReasonOpen {
+		// the charge was not sent
+		return "", err
+	}
+	if c := status.Code(err); c == codes.DeadlineExceeded || c == codes.Unavailable {
+		return "", fmt.Errorf("%w: %v", errPaymentOutcomeUnknown, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.GetTransactionId(), nil
+}
+
+func (p *paymentServiceProvider) Refund(ctx context.Context, transactionID string, amount *pb.Money) error {
+	_, err := pb.NewPaymentServiceClient(p.conn).Refund(ctx, &pb.RefundRequest{TransactionId: transactionID, Amount: amount})
+	return err
+}
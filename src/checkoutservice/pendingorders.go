@@ -0,0 +1,135 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// An order is recorded twice while it is placed: as pending before its card
+// is charged, and as paid once it is charged and shipped, when SaveOrder
+// records its payment transaction and tracking ID. An order that could not
+// be placed, because its card was declined or placing it was compensated, is
+// marked failed. One still pending after interruptedOrderAge was
+// interrupted, typically by a crash between the charge and SaveOrder, and its
+// charge has to be reconciled with the payment service: the
+// checkout.orders.interrupted gauge counts them, and the order export lists
+// them with status=pending.
+//
+// Pending and failed orders are left out of order listings unless asked for
+// by status, and are not replicated to other regions or published as events.
+
+// interruptedOrderAge is how long an order can be pending while it is placed,
+// well past the deadline of any PlaceOrder request.
+const interruptedOrderAge = 10 * time.Minute
+
+// persists an order before its card is charged, as pending, so that it is
+// recorded even if placing it is interrupted; SaveOrder confirms it
+func (os *OrderStore) SavePendingOrder(ctx context.Context, rec orderRecord) (err error) {
+	ctx, span := startStoreSpan(ctx, "SavePendingOrder")
+	defer func() { endSpan(span, err) }()
+	rec = rec.stamped(StatusPending)
+	return withDBTimeout(ctx, os.timeouts.save, "SavePendingOrder", func(ctx context.Context) error {
+		return retryDB(ctx, "save pending order "+rec.Order.OrderID, func() error {
+			// a retry whose first attempt was committed inserts nothing
+			err := inTx(ctx, os.db, func(tx *sql.Tx) error {
+				_, err := insertOrderRecord(ctx, os.dialect, os.stmts.on(os.db, tx), rec)
+				return err
+			})
+			os.stmts.reset(err)
+			return err
+		})
+	})
+}
+
+// marks a pending order failed, leaving it as it is if it is not pending
+func (os *OrderStore) FailOrder(ctx context.Context, orderID string) (err error) {
+	ctx, span := startStoreSpan(ctx, "FailOrder")
+	defer func() { endSpan(span, err) }()
+	return withDBTimeout(ctx, os.timeouts.save, "FailOrder", func(ctx context.Context) error {
+		return retryDB(ctx, "fail order "+orderID, func() error {
+			_, err := os.db.ExecContext(ctx, `
+                UPDATE orders SET status = $2 WHERE order_id = $1 AND status = $3
+            `, orderID, StatusFailed, StatusPending)
+			if err != nil {
+				return fmt.Errorf("failed to mark order failed: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// confirms the pending order of rec, recording its payment transaction,
+// tracking ID and idempotency key, and reports whether it was pending
+func confirmPendingOrder(ctx context.Context, d dialect, tx dbtx, rec orderRecord) (bool, error) {
+	o := rec.Order
+	sctx, span := startStatement(ctx, "UPDATE", "orders")
+	res, err := tx.ExecContext(sctx, `
+        UPDATE orders SET status = $2, payment_transaction_id = $3, shipping_tracking_id = $4, created_at = $5
+        WHERE order_id = $1 AND status = $6
+    `, o.OrderID, o.Status, o.PaymentTransactionID, o.ShippingTrackingID, o.CreatedAt, StatusPending)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	endStatement(span, n, err)
+	if err != nil {
+		return false, fmt.Errorf("failed to confirm order: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+	return true, insertIdempotencyRecord(ctx, d, tx, o, rec.Idempotency)
+}
+
+// counts the orders that have been pending for longer than
+// interruptedOrderAge
+func (os *OrderStore) countInterruptedOrders(ctx context.Context) (int64, error) {
+	var n int64
+	err := withDBTimeout(ctx, os.timeouts.read, "countInterruptedOrders", func(ctx context.Context) error {
+		return os.db.QueryRowContext(ctx, `
+            SELECT COUNT(*) FROM orders WHERE status = 'pending' AND created_at < $1
+        `, time.Now().UTC().Add(-interruptedOrderAge)).Scan(&n)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count interrupted orders: %w", err)
+	}
+	return n, nil
+}
+
+// registerPendingOrderMetrics exports the number of interrupted orders of
+// store each time metrics are collected.
+func registerPendingOrderMetrics(store *OrderStore) error {
+	_, err := otel.Meter("checkoutservice").Int64ObservableGauge(
+		"checkout.orders.interrupted",
+		metric.WithDescription("Orders pending for longer than placing an order takes, whose charge has to be reconciled."),
+		metric.WithUnit("{order}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			n, err := store.countInterruptedOrders(ctx)
+			if err != nil {
+				return err
+			}
+			o.Observe(n)
+			return nil
+		}),
+	)
+	return err
+}
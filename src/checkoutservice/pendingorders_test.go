@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// TestPendingOrderSQLite records orders as pending in SQLite, then confirms
+// one and fails the other.
+func TestPendingOrderSQLite(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	userID := uuid.NewString()
+	pending := func(orderID string) {
+		t.Helper()
+		if err := store.SavePendingOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", ShippingCostUnits: 7}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := func(orderID string) OrderStatus {
+		t.Helper()
+		o, err := store.GetOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o.Status
+	}
+	placed, failed := uuid.NewString(), uuid.NewString()
+	pending(placed)
+	pending(failed)
+	if s := status(placed); s != StatusPending {
+		t.Errorf("status = %s, want pending", s)
+	}
+	if orders, err := store.GetUserOrders(ctx, userID, OrderQuery{}); err != nil || len(orders) != 0 {
+		t.Errorf("GetUserOrders with only pending orders = %v, %v; want none", orders, err)
+	}
+	if orders, err := store.GetUserOrders(ctx, userID, OrderQuery{Statuses: []OrderStatus{StatusPending}}); err != nil || len(orders) != 2 {
+		t.Errorf("GetUserOrders of pending orders = %d orders, %v; want 2", len(orders), err)
+	}
+
+	idem := &IdempotencyRecord{Key: "key-1", Fingerprint: []byte("fingerprint"), Response: []byte("response")}
+	if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: placed, UserID: userID, Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", ShippingCostUnits: 7}, Items: pricedItems(placed, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}}), Idempotency: idem}); err != nil {
+		t.Fatal(err)
+	}
+	o, err := store.GetOrder(ctx, placed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != StatusPaid || o.PaymentTransactionID != "txn-1" || o.ShippingTrackingID != "track-1" || len(o.Items) != 1 {
+		t.Errorf("confirmed order = %+v, want it paid with its transaction, tracking ID and item", o)
+	}
+	if rec, err := store.GetIdempotencyRecord(ctx, userID, "key-1"); err != nil || rec == nil {
+		t.Errorf("idempotency key of the confirmed order = %v, %v; want it stored", rec, err)
+	}
+	if err := saveBenchOrder(ctx, store, placed); !errors.Is(err, errOrderExists) {
+		t.Errorf("saving a confirmed order again: got %v, want errOrderExists", err)
+	}
+
+	if err := store.FailOrder(ctx, failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FailOrder(ctx, placed); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(failed); s != StatusFailed {
+		t.Errorf("status = %s, want failed", s)
+	}
+	if s := status(placed); s != StatusPaid {
+		t.Errorf("status of a paid order marked failed = %s, want paid", s)
+	}
+	if orders, err := store.GetUserOrders(ctx, userID, OrderQuery{}); err != nil || len(orders) != 1 || orders[0].OrderID != placed {
+		t.Errorf("GetUserOrders = %v, %v; want only the placed order", orders, err)
+	}
+}
+
+func TestCountInterruptedOrders(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	for _, age := range []time.Duration{time.Minute, time.Hour} {
+		orderID := uuid.NewString()
+		if err := store.SavePendingOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: "user-1", CurrencyCode: "USD"}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.db.ExecContext(ctx, `UPDATE orders SET created_at = $1 WHERE order_id = $2`, time.Now().UTC().Add(-age), orderID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := store.countInterruptedOrders(ctx); err != nil || n != 1 {
+		t.Errorf("countInterruptedOrders() = %d, %v; want the order pending for an hour", n, err)
+	}
+}
+
+// TestPlaceOrderRecordsPendingOrders places an order and one whose card is
+// declined, which are recorded as pending and then paid and failed.
+func TestPlaceOrderRecordsPendingOrders(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	store := newMemoryOrderStore()
+	cs.orderStore = store
+	ctx := context.Background()
+	addItem := func() {
+		t.Helper()
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addItem()
+	order, _, err := cs.placeOrder(ctx, orderRequest{userID: "user-1", userCurrency: "USD", card: contract.ValidCard()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := store.GetOrder(ctx, order.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != StatusPaid || o.PaymentTransactionID == "" || o.ShippingTrackingID != order.GetShippingTrackingId() || o.ShippingQuoteCurrencyCode != "USD" {
+		t.Errorf("placed order = %+v, want it paid with its transaction and tracking ID and shipping quote", o)
+	}
+
+	addItem()
+	card := contract.ValidCard()
+	card.CreditCardExpirationYear = 2000
+	if _, _, err := cs.placeOrder(ctx, orderRequest{userID: "user-1", userCurrency: "USD", card: card}); err == nil {
+		t.Fatal("placeOrder with an expired card succeeded")
+	}
+	attempts, err := store.GetUserOrders(ctx, "user-1", OrderQuery{Statuses: attemptStatuses})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || attempts[0].Status != StatusFailed {
+		t.Errorf("orders not placed = %+v, want the declined one failed", attempts)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// The frontend sends the currency and locale the user chose in the metadata
+// of every call it makes on their behalf.
+const (
+	metadataCurrency = "x-user-currency"
+	metadataLocale   = "x-user-locale"
+)
+
+// withCallerPreferences fills in the currency and locale req leaves unset
+// from the ones the caller sent in the metadata of ctx, so that an order is
+// priced, saved and confirmed in the currency the user chose even when the
+// request does not say.
+func withCallerPreferences(ctx context.Context, req orderRequest) orderRequest {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(metadataCurrency); req.userCurrency == "" && len(v) > 0 {
+		req.userCurrency = v[0]
+	}
+	if v := md.Get(metadataLocale); req.locale == "" && len(v) > 0 {
+		req.locale = v[0]
+	}
+	return req
+}
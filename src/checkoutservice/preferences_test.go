@@ -0,0 +1,57 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestWithCallerPreferences(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(metadataCurrency, "EUR", metadataLocale, "fr-FR"))
+	if req := withCallerPreferences(ctx, orderRequest{}); req.userCurrency != "EUR" || req.locale != "fr-FR" {
+		t.Errorf("unset currency and locale = %q, %q, want the caller's EUR, fr-FR", req.userCurrency, req.locale)
+	}
+	if req := withCallerPreferences(ctx, orderRequest{userCurrency: "JPY", locale: "ja-JP"}); req.userCurrency != "JPY" || req.locale != "ja-JP" {
+		t.Errorf("set currency and locale = %q, %q, want the request's JPY, ja-JP", req.userCurrency, req.locale)
+	}
+	if req := withCallerPreferences(context.Background(), orderRequest{}); req.userCurrency != "" || req.locale != "" {
+		t.Errorf("currency and locale without metadata = %q, %q, want them unset", req.userCurrency, req.locale)
+	}
+}
+
+func TestPlaceOrderInCallerCurrency(t *testing.T) {
+	cs, backends := newFakeCheckoutService(t)
+	ctx := context.Background()
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "1YMWWN1N4O", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(metadataCurrency, "EUR"))
+	order, total, err := cs.placeOrder(ctx, orderRequest{userID: "user-1", card: contract.ValidCard()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.GetCurrencyCode() != "EUR" {
+		t.Errorf("total = %v, want it in the caller's EUR", total)
+	}
+	if got := order.GetShippingCost().GetCurrencyCode(); got != "EUR" {
+		t.Errorf("shipping cost currency = %q, want EUR", got)
+	}
+}
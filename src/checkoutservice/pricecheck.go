@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// PlaceOrder prices the items of an order with the catalog prices it fetched
+// while preparing it, and fetches them again just before charging the card.
+// An order whose prices changed in between by more than PRICE_DRIFT_TOLERANCE
+// percent fails with PRICES_CHANGED, rather than charging a price the user
+// was not shown, so that they refresh their cart and place it again. The
+// tolerance defaults to 0, failing on any change, and PRICE_DRIFT_TOLERANCE=off
+// skips the check, saving a catalog lookup per item.
+
+const reasonPricesChanged = "PRICES_CHANGED"
+
+// priceCheck compares the prices of the items of an order with the catalog.
+type priceCheck struct {
+	// tolerance is the largest change, in percent of the earlier price,
+	// that an order is placed with.
+	tolerance float64
+}
+
+// newPriceCheckFromEnv returns the price check of PRICE_DRIFT_TOLERANCE, or
+// nil if it is off.
+func newPriceCheckFromEnv() (*priceCheck, error) {
+	v := strings.TrimSpace(os.Getenv("PRICE_DRIFT_TOLERANCE"))
+	switch v {
+	case "":
+		return &priceCheck{}, nil
+	case "off":
+		return nil, nil
+	}
+	tolerance, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil || tolerance < 0 || tolerance > 100 {
+		return nil, fmt.Errorf("invalid PRICE_DRIFT_TOLERANCE %q: want a percentage from 0 to 100, or off", v)
+	}
+	return &priceCheck{tolerance: tolerance}, nil
+}
+
+// String describes the check for the startup log.
+func (p *priceCheck) String() string {
+	if p == nil {
+		return "off"
+	}
+	return fmt.Sprintf("%g%% tolerance", p.tolerance)
+}
+
+// drifted reports whether the price of an item moved from was to now by
+// more than the tolerance.
+func (p *priceCheck) drifted(was, now *pb.Money) bool {
+	if was.GetCurrencyCode() != now.GetCurrencyCode() {
+		return true
+	}
+	before := float64(was.GetUnits())*1e9 + float64(was.GetNanos())
+	after := float64(now.GetUnits())*1e9 + float64(now.GetNanos())
+	diff := after - before
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*100 > p.tolerance*before
+}
+
+// revalidatePrices fetches the catalog prices of the items of prep again and
+// fails with PRICES_CHANGED if any drifted from the prices it was prepared
+// with.
+func (cs *checkoutService) revalidatePrices(ctx context.Context, prep orderPrep) error {
+	if cs.prices == nil {
+		return nil
+	}
+	cl := pb.NewProductCatalogServiceClient(cs.productCatalogSvcConn)
+	var changed []string
+	for i, item := range prep.cartItems {
+		product, err := cl.GetProduct(ctx, &pb.GetProductRequest{Id: item.GetProductId()})
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to get product #%q to check its price: %v", item.GetProductId(), err)
+		}
+		if cs.prices.drifted(prep.pricesUsd[i], product.GetPriceUsd()) {
+			changed = append(changed, item.GetProductId())
+		}
+	}
+	if len(changed) > 0 {
+		return rpcerrors.Errorf(codes.FailedPrecondition, reasonPricesChanged, "the prices of %s changed during checkout; refresh the cart and place the order again", strings.Join(changed, ", "))
+	}
+	return nil
+}
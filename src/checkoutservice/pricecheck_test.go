@@ -0,0 +1,134 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestPriceCheckFromEnv(t *testing.T) {
+	for v, want := range map[string]string{"": "0% tolerance", "off": "off", "2.5": "2.5% tolerance", "10%": "10% tolerance"} {
+		t.Setenv("PRICE_DRIFT_TOLERANCE", v)
+		p, err := newPriceCheckFromEnv()
+		if err != nil || p.String() != want {
+			t.Errorf("PRICE_DRIFT_TOLERANCE=%q: newPriceCheckFromEnv() = %v, %v, want %s", v, p, err, want)
+		}
+	}
+	for _, v := range []string{"-1", "101", "lots"} {
+		t.Setenv("PRICE_DRIFT_TOLERANCE", v)
+		if _, err := newPriceCheckFromEnv(); err == nil {
+			t.Errorf("PRICE_DRIFT_TOLERANCE=%q: newPriceCheckFromEnv() succeeded", v)
+		}
+	}
+}
+
+func TestPriceCheckDrifted(t *testing.T) {
+	was := &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000}
+	for _, tc := range []struct {
+		tolerance float64
+		now       *pb.Money
+		want      bool
+	}{
+		{0, &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000}, false},
+		{0, &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 980000000}, true},
+		{5, &pb.Money{CurrencyCode: "USD", Units: 20, Nanos: 490000000}, false},
+		{5, &pb.Money{CurrencyCode: "USD", Units: 17, Nanos: 990000000}, true},
+		{5, &pb.Money{CurrencyCode: "EUR", Units: 19, Nanos: 990000000}, true},
+	} {
+		p := &priceCheck{tolerance: tc.tolerance}
+		if got := p.drifted(was, tc.now); got != tc.want {
+			t.Errorf("drifted(%v, %v) with %s = %v, want %v", was, tc.now, p, got, tc.want)
+		}
+	}
+}
+
+// repricingCatalog is a product catalog whose Sunglasses go up to 24.99 USD
+// after the first time they are looked up, as if repriced during checkout.
+type repricingCatalog struct {
+	*fakes.ProductCatalog
+
+	mu      sync.Mutex
+	lookups int
+}
+
+func (f *repricingCatalog) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	p, err := f.ProductCatalog.GetProduct(ctx, req)
+	if err != nil || p.GetId() != "OLJCESPC7Z" {
+		return p, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lookups++; f.lookups == 1 {
+		return p, nil
+	}
+	p = proto.Clone(p).(*pb.Product)
+	p.PriceUsd = &pb.Money{CurrencyCode: "USD", Units: 24, Nanos: 990000000}
+	return p, nil
+}
+
+func TestPricesChangedDuringCheckout(t *testing.T) {
+	ctx := context.Background()
+	cs, backends := newFakeCheckoutService(t)
+	cs.prices = &priceCheck{}
+	s := newCheckoutServiceV2(cs)
+	addItem := func() {
+		t.Helper()
+		if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	place := func(key string) (*pbv2.PlaceOrderResponse, error) {
+		req := placeOrderRequest(key)
+		req.PaymentMethod = &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: contract.ValidCard()}}
+		return s.PlaceOrder(ctx, req)
+	}
+
+	addItem()
+	if _, err := place("key-1"); err != nil {
+		t.Fatalf("PlaceOrder() with unchanged prices: %v", err)
+	}
+
+	catalog := &repricingCatalog{ProductCatalog: backends.catalog}
+	cs.productCatalogSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pb.RegisterProductCatalogServiceServer(srv, catalog)
+	})
+	addItem()
+	if _, err := place("key-2"); rpcerrors.Classify(err).Reason != reasonPricesChanged {
+		t.Fatalf("PlaceOrder() with a price changed during checkout = %v, want %s", err, reasonPricesChanged)
+	}
+	if charges := len(backends.payment.Charges()); charges != 1 {
+		t.Errorf("%d charges after an order with changed prices, want only the first order's", charges)
+	}
+
+	// the cart is kept, and placing it again charges the new price
+	resp, err := place("key-3")
+	if err != nil {
+		t.Fatalf("PlaceOrder() after the price change: %v", err)
+	}
+	if got := resp.GetOrder().GetItems()[0].GetCost(); got.GetUnits() != 24 || got.GetNanos() != 990000000 {
+		t.Errorf("item cost after the price change = %v, want 24.99 USD", got)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+)
+
+// Promo codes are configured by PROMO_CODES, a comma-separated list of
+// CODE=DISCOUNT rules. A discount is either a percentage off the items of an
+// order, such as SPRING=10%, or an amount off them in one currency, such as
+// WELCOME=5.50EUR, which only applies to orders in that currency. Codes are
+// matched ignoring case, and no discount exceeds the price of the items:
+// shipping is always charged in full.
+//
+// The v2 PlaceOrder applies the discount before charging the card, and the
+// order keeps the code and the amount taken off.
+
+const (
+	// maxPromoCodeLen is the longest promo code accepted, in bytes.
+	maxPromoCodeLen = 64
+
+	// percentOffCents rounds percentage discounts down to cents.
+	percentOffCents = 10000000
+)
+
+var promoAmountRE = regexp.MustCompile(`^(\d+(?:\.\d{1,9})?)([A-Z]{3})$`)
+
+// promotion is the discount of a promo code: Percent percent off, or Amount
+// off if it is set.
+type promotion struct {
+	Code    string
+	Percent int
+	Amount  *pb.Money
+}
+
+// promotions are the promo codes that are accepted, keyed by their upper
+// case code. A nil promotions accepts none.
+type promotions map[string]promotion
+
+// parsePromoCodes parses PROMO_CODES.
+func parsePromoCodes(v string) (promotions, error) {
+	var p promotions
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		code, discount, ok := strings.Cut(s, "=")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !ok || code == "" || len(code) > maxPromoCodeLen {
+			return nil, fmt.Errorf("invalid promo code rule %q: want CODE=DISCOUNT", s)
+		}
+		promo, err := parsePromoDiscount(code, strings.TrimSpace(discount))
+		if err != nil {
+			return nil, fmt.Errorf("invalid promo code rule %q: %w", s, err)
+		}
+		if _, dup := p[code]; dup {
+			return nil, fmt.Errorf("promo code %s is listed twice", code)
+		}
+		if p == nil {
+			p = make(promotions)
+		}
+		p[code] = promo
+	}
+	return p, nil
+}
+
+func parsePromoDiscount(code, discount string) (promotion, error) {
+	if pct, ok := strings.CutSuffix(discount, "%"); ok {
+		n, err := strconv.Atoi(pct)
+		if err != nil || n < 1 || n > 100 {
+			return promotion{}, errors.New("want a percentage from 1% to 100%")
+		}
+		return promotion{Code: code, Percent: n}, nil
+	}
+	if !promoAmountRE.MatchString(strings.ToUpper(discount)) {
+		return promotion{}, errors.New("want a percentage such as 10% or an amount such as 5.50EUR")
+	}
+	amount, err := parseAmount(discount)
+	if err != nil {
+		return promotion{}, err
+	}
+	return promotion{Code: code, Amount: amount}, nil
+}
+
+// parseAmount parses an amount above zero in one currency, such as 5.50EUR.
+func parseAmount(v string) (*pb.Money, error) {
+	m := promoAmountRE.FindStringSubmatch(strings.ToUpper(v))
+	if m == nil {
+		return nil, errors.New("want an amount such as 5.50EUR")
+	}
+	whole, frac, _ := strings.Cut(m[1], ".")
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("amount %s is too large", m[1])
+	}
+	nanos, _ := strconv.Atoi((frac + "000000000")[:9])
+	amount := &pb.Money{CurrencyCode: m[2], Units: units, Nanos: int32(nanos)}
+	if money.IsZero(*amount) {
+		return nil, errors.New("want an amount above zero")
+	}
+	return amount, nil
+}
+
+// lookup returns the promotion of code.
+func (p promotions) lookup(code string) (promotion, bool) {
+	promo, ok := p[strings.ToUpper(strings.TrimSpace(code))]
+	return promo, ok
+}
+
+// discount returns the amount the promotion takes off items priced subtotal,
+// failing with money.ErrMismatchingCurrency if its amount is in another
+// currency.
+func (p promotion) discount(subtotal pb.Money) (pb.Money, error) {
+	if p.Amount == nil {
+		// subtotal * percent / 100, in nanos, rounded down to cents
+		n := new(big.Int).Mul(big.NewInt(subtotal.GetUnits()), big.NewInt(1e9))
+		n.Add(n, big.NewInt(int64(subtotal.GetNanos())))
+		n.Mul(n, big.NewInt(int64(p.Percent)))
+		n.Quo(n, big.NewInt(100*percentOffCents))
+		n.Mul(n, big.NewInt(percentOffCents))
+		units, nanos := new(big.Int).QuoRem(n, big.NewInt(1e9), new(big.Int))
+		return pb.Money{CurrencyCode: subtotal.GetCurrencyCode(), Units: units.Int64(), Nanos: int32(nanos.Int64())}, nil
+	}
+	if p.Amount.GetCurrencyCode() != subtotal.GetCurrencyCode() {
+		return pb.Money{}, money.ErrMismatchingCurrency
+	}
+	left, err := money.Sum(subtotal, money.Negate(*p.Amount))
+	if err != nil {
+		return pb.Money{}, err
+	}
+	if money.IsNegative(left) {
+		return subtotal, nil
+	}
+	return *p.Amount, nil
+}
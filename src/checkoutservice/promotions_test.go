@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+)
+
+func TestParsePromoCodes(t *testing.T) {
+	p, err := parsePromoCodes(" spring=10% , WELCOME=5.50eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promo, ok := p.lookup("Spring"); !ok || promo.Percent != 10 {
+		t.Errorf("lookup(Spring) = %+v, %v, want 10%% off", promo, ok)
+	}
+	if promo, ok := p.lookup("welcome"); !ok || promo.Amount.GetCurrencyCode() != "EUR" || promo.Amount.GetUnits() != 5 || promo.Amount.GetNanos() != 500000000 {
+		t.Errorf("lookup(welcome) = %+v, %v, want 5.50 EUR off", promo, ok)
+	}
+	if _, ok := p.lookup("SUMMER"); ok {
+		t.Error("lookup(SUMMER) found an unlisted code")
+	}
+
+	if p, err := parsePromoCodes(""); p != nil || err != nil {
+		t.Errorf(`parsePromoCodes("") = %v, %v, want none`, p, err)
+	}
+	for _, bad := range []string{"SPRING", "=10%", "SPRING=0%", "SPRING=101%", "SPRING=0EUR", "SPRING=5.50", "SPRING=10%,spring=5%"} {
+		if _, err := parsePromoCodes(bad); err == nil {
+			t.Errorf("parsePromoCodes(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	subtotal := pb.Money{CurrencyCode: "EUR", Units: 19, Nanos: 990000000}
+	for _, tt := range []struct {
+		discount    string
+		units       int64
+		nanos       int32
+		currencyErr bool
+	}{
+		{"10%", 1, 990000000, false},
+		{"15%", 2, 990000000, false},
+		{"100%", 19, 990000000, false},
+		{"5.50EUR", 5, 500000000, false},
+		{"50EUR", 19, 990000000, false},
+		{"19.995EUR", 19, 990000000, false},
+		{"5USD", 0, 0, true},
+	} {
+		promo, err := parsePromoDiscount("CODE", tt.discount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := promo.discount(subtotal)
+		if tt.currencyErr {
+			if !errors.Is(err, money.ErrMismatchingCurrency) {
+				t.Errorf("%s off: discount() = %v, want %v", tt.discount, err, money.ErrMismatchingCurrency)
+			}
+			continue
+		}
+		if err != nil || got.GetCurrencyCode() != "EUR" || got.GetUnits() != tt.units || got.GetNanos() != tt.nanos {
+			t.Errorf("%s off: discount() = %v, %v, want %d.%09d EUR", tt.discount, &got, err, tt.units, tt.nanos)
+		}
+	}
+}
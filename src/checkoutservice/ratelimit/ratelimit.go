@@ -0,0 +1,205 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits how often each user may call a method, so that a
+// runaway client cannot overload the dependencies behind it.
+//
+// Every caller has a token bucket holding up to Burst calls, refilled at Rate
+// calls per second. Callers are told apart by the user ID of their requests,
+// or, for requests without one, by the first address of their
+// x-forwarded-for metadata or else their peer address. Calls over the limit
+// fail with ResourceExhausted and reason RATE_LIMITED, and are counted by
+// method in the ratelimit.rejected counter. Buckets that have refilled are
+// forgotten, so that idle callers cost nothing.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// ReasonRateLimited is the error reason of calls over their limit.
+const ReasonRateLimited = "RATE_LIMITED"
+
+const (
+	defaultRate  = 1
+	defaultBurst = 5
+
+	// sweepInterval is how often buckets that have refilled are forgotten.
+	sweepInterval = time.Minute
+)
+
+// Config sets the limit of every caller.
+type Config struct {
+	// Rate is the sustained number of calls per second a caller may make,
+	// and Burst how many it may make at once.
+	Rate  float64
+	Burst int
+}
+
+// Limiter holds the buckets of the callers of some methods. A nil Limiter
+// lets every call through.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+
+	rejected metric.Int64Counter
+}
+
+// FromEnv returns a Limiter configured with RATE_LIMIT_PER_USER, in calls per
+// second (default 1), and RATE_LIMIT_BURST (default 5), or nil if
+// RATE_LIMIT_PER_USER is 0.
+func FromEnv() (*Limiter, error) {
+	cfg := Config{Rate: defaultRate, Burst: defaultBurst}
+	if v := os.Getenv("RATE_LIMIT_PER_USER"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_PER_USER %q", v)
+		}
+		cfg.Rate = f
+	}
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_BURST %q", v)
+		}
+		cfg.Burst = n
+	}
+	if cfg.Rate == 0 {
+		return nil, nil
+	}
+	return New(cfg), nil
+}
+
+// New returns a Limiter for cfg.
+func New(cfg Config) *Limiter {
+	l := &Limiter{cfg: cfg, now: time.Now, buckets: make(map[string]*rate.Limiter)}
+	l.rejected, _ = otel.Meter("ratelimit").Int64Counter(
+		"ratelimit.rejected",
+		metric.WithDescription("Calls failed because their caller was over its rate limit, by method."),
+		metric.WithUnit("{call}"))
+	return l
+}
+
+// String describes the configuration, for logging.
+func (l *Limiter) String() string {
+	if l == nil {
+		return "disabled"
+	}
+	return fmt.Sprintf("%g calls per second per user, bursts of %d", l.cfg.Rate, l.cfg.Burst)
+}
+
+// Allow reports whether the caller identified by key may make a call now,
+// and if not how long it must wait.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweepLocked(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = rate.NewLimiter(rate.Limit(l.cfg.Rate), l.cfg.Burst)
+		l.buckets[key] = b
+	}
+	r := b.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		return false, d
+	}
+	return true, 0
+}
+
+// sweepLocked forgets the buckets that have refilled, which are the same as
+// new ones.
+func (l *Limiter) sweepLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if b.TokensAt(now) >= float64(l.cfg.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// userIDRequest is implemented by the requests of methods called on behalf of
+// a user.
+type userIDRequest interface {
+	GetUserId() string
+}
+
+// callerKey identifies the caller of a request: the user ID of req, if any,
+// or its address.
+func callerKey(ctx context.Context, req any) string {
+	if r, ok := req.(userIDRequest); ok && r.GetUserId() != "" {
+		return "user:" + r.GetUserId()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-forwarded-for"); len(v) > 0 {
+		if ip, _, _ := strings.Cut(v[0], ","); strings.TrimSpace(ip) != "" {
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "unknown"
+}
+
+// UnaryServerInterceptor limits the calls to methods, given by full method
+// name, per caller. Other methods are not limited.
+func (l *Limiter) UnaryServerInterceptor(methods ...string) grpc.UnaryServerInterceptor {
+	limited := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		limited[m] = true
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !limited[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if ok, wait := l.Allow(callerKey(ctx, req)); !ok {
+			l.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("rpc.method", info.FullMethod)))
+			return nil, rpcerrors.Errorf(codes.ResourceExhausted, ReasonRateLimited, "too many requests, retry in %v", wait.Round(time.Millisecond))
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+func TestFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, "1 calls per second per user, bursts of 5", false},
+		{"set", map[string]string{"RATE_LIMIT_PER_USER": "0.5", "RATE_LIMIT_BURST": "2"}, "0.5 calls per second per user, bursts of 2", false},
+		{"off", map[string]string{"RATE_LIMIT_PER_USER": "0"}, "disabled", false},
+		{"invalid rate", map[string]string{"RATE_LIMIT_PER_USER": "-1"}, "", true},
+		{"invalid burst", map[string]string{"RATE_LIMIT_BURST": "0"}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"RATE_LIMIT_PER_USER", "RATE_LIMIT_BURST"} {
+				t.Setenv(key, tt.env[key])
+			}
+			l, err := FromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && l.String() != tt.want {
+				t.Errorf("FromEnv() = %q, want %q", l, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllow(t *testing.T) {
+	l := New(Config{Rate: 1, Burst: 2})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("user:a"); !ok {
+			t.Fatalf("call %d of a burst of 2 was limited", i+1)
+		}
+	}
+	ok, wait := l.Allow("user:a")
+	if ok || wait != time.Second {
+		t.Fatalf("Allow() over the burst = %v, %v, want false, 1s", ok, wait)
+	}
+	if ok, _ := l.Allow("user:b"); !ok {
+		t.Error("another user was limited")
+	}
+	// a limited call takes no token
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("user:a"); !ok {
+		t.Error("call once a token refilled was limited")
+	}
+	if ok, _ := l.Allow("user:a"); ok {
+		t.Error("second call after one token refilled was allowed")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l := New(Config{Rate: 1, Burst: 2})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	l.Allow("user:a")
+	now = now.Add(sweepInterval)
+	l.Allow("user:b")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.buckets["user:a"]; ok {
+		t.Error("refilled bucket was not forgotten")
+	}
+	if _, ok := l.buckets["user:b"]; !ok {
+		t.Error("bucket in use was forgotten")
+	}
+}
+
+func TestCallerKey(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 41234}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		req  any
+		want string
+	}{
+		{"user", ctx, &pb.PlaceOrderRequest{UserId: "u-1"}, "user:u-1"},
+		{"forwarded", metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", "203.0.113.9, 10.0.0.1")), &pb.PlaceOrderRequest{}, "ip:203.0.113.9"},
+		{"peer", ctx, &pb.Empty{}, "ip:10.0.0.7"},
+		{"unknown", context.Background(), &pb.Empty{}, "unknown"},
+	} {
+		if got := callerKey(tt.ctx, tt.req); got != tt.want {
+			t.Errorf("%s: callerKey() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	l := New(Config{Rate: 1, Burst: 1})
+	intercept := l.UnaryServerInterceptor("/hipstershop.CheckoutService/PlaceOrder")
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+	call := func(method string) error {
+		_, err := intercept(context.Background(), &pb.PlaceOrderRequest{UserId: "u-1"}, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	if err := call("/hipstershop.CheckoutService/PlaceOrder"); err != nil {
+		t.Fatal(err)
+	}
+	err := call("/hipstershop.CheckoutService/PlaceOrder")
+	if c := rpcerrors.Classify(err); c.Code != codes.ResourceExhausted || c.Reason != ReasonRateLimited {
+		t.Errorf("call over the limit = %v, want ResourceExhausted %s", err, ReasonRateLimited)
+	}
+	if err := call("/hipstershop.v2.CheckoutService/GetOrder"); err != nil {
+		t.Errorf("method without a limit = %v, want it let through", err)
+	}
+
+	var off *Limiter
+	intercept = off.UnaryServerInterceptor("/hipstershop.CheckoutService/PlaceOrder")
+	for i := 0; i < 3; i++ {
+		if err := call("/hipstershop.CheckoutService/PlaceOrder"); err != nil {
+			t.Fatalf("nil Limiter limited call %d: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+)
+
+const (
+	defaultRatesMaxAge = 2 * time.Minute
+	ratesRetryMin      = time.Second
+	ratesRetryMax      = 30 * time.Second
+
+	// conversion sources, as reported in metrics
+	conversionLocal = "local"
+	conversionRPC   = "rpc"
+)
+
+// rateTable converts prices locally with the exchange rates the currency
+// service streams, instead of calling Convert for each of them. Conversions
+// fall back to the Convert RPC while the rates are stale, that is when
+// nothing, not even a heartbeat, has been received for maxAge, or lack one of
+// the currencies. A nil rateTable always falls back.
+type rateTable struct {
+	client pb.CurrencyServiceClient
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu       sync.RWMutex
+	rates    map[string]float64
+	received time.Time
+	// ready is closed once the first full set of rates is received.
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	conversions metric.Int64Counter
+}
+
+// newRateTableFromEnv returns the rate table set up by CURRENCY_RATES and
+// CURRENCY_RATES_MAX_AGE, or nil if every conversion should call Convert.
+func newRateTableFromEnv(client pb.CurrencyServiceClient) (*rateTable, error) {
+	switch mode := os.Getenv("CURRENCY_RATES"); mode {
+	case "", "stream":
+	case "rpc":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid CURRENCY_RATES %q", mode)
+	}
+	t := newRateTable(client)
+	if v := os.Getenv("CURRENCY_RATES_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid CURRENCY_RATES_MAX_AGE %q", v)
+		}
+		t.maxAge = d
+	}
+
+	meter := otel.Meter("checkoutservice")
+	t.conversions, _ = meter.Int64Counter(
+		"checkout.currency.conversions",
+		metric.WithDescription("Currency conversions, by whether they used the streamed rates or the Convert RPC."),
+		metric.WithUnit("{conversion}"),
+	)
+	if _, err := meter.Float64ObservableGauge(
+		"checkout.currency.rates.age",
+		metric.WithDescription("Time since the last message of the currency rates stream."),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			if age, ok := t.age(); ok {
+				o.Observe(age.Seconds())
+			}
+			return nil
+		}),
+	); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func newRateTable(client pb.CurrencyServiceClient) *rateTable {
+	return &rateTable{
+		client: client,
+		maxAge: defaultRatesMaxAge,
+		now:    time.Now,
+		ready:  make(chan struct{}),
+	}
+}
+
+// String describes the rate table for startup logs.
+func (t *rateTable) String() string {
+	if t == nil {
+		return "Convert RPC"
+	}
+	return fmt.Sprintf("streamed, max age %s", t.maxAge)
+}
+
+// run keeps a WatchRates stream open until ctx is done, reconnecting with
+// exponential backoff when it breaks.
+func (t *rateTable) run(ctx context.Context) {
+	backoff := ratesRetryMin
+	for {
+		received, err := t.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = ratesRetryMin
+		}
+		log.Warnf("currency rates stream failed, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, ratesRetryMax)
+	}
+}
+
+// watch applies the messages of one stream until it breaks, and reports
+// whether it received any.
+func (t *rateTable) watch(ctx context.Context) (received bool, err error) {
+	stream, err := t.client.WatchRates(ctx, &pb.WatchRatesRequest{})
+	if err != nil {
+		return false, err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		t.apply(msg)
+		received = true
+	}
+}
+
+// apply updates the rates with msg.
+func (t *rateTable) apply(msg *pb.CurrencyRates) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if msg.GetFull() {
+		t.rates = make(map[string]float64, len(msg.GetRates()))
+	} else if t.rates == nil {
+		// changes are meaningless without the rates they change
+		return
+	}
+	for code, rate := range msg.GetRates() {
+		t.rates[code] = rate
+	}
+	t.received = t.now()
+	if msg.GetFull() {
+		t.readyOnce.Do(func() { close(t.ready) })
+	}
+}
+
+// age returns the time since the last message, if any was received.
+func (t *rateTable) age() (time.Duration, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.received.IsZero() {
+		return 0, false
+	}
+	return t.now().Sub(t.received), true
+}
+
+// convert converts from into toCurrency with the streamed rates, and reports
+// false if the Convert RPC must be called instead.
+func (t *rateTable) convert(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, bool) {
+	if t == nil {
+		return nil, false
+	}
+	age, ok := t.age()
+	t.mu.RLock()
+	fromRate, fromOK := t.rates[from.GetCurrencyCode()]
+	toRate, toOK := t.rates[toCurrency]
+	t.mu.RUnlock()
+
+	source := conversionRPC
+	var result pb.Money
+	if ok && age <= t.maxAge && fromOK && toOK {
+		var err error
+		if result, err = money.Convert(*from, fromRate, toRate, toCurrency); err == nil {
+			source = conversionLocal
+		}
+	}
+	if t.conversions != nil {
+		t.conversions.Add(ctx, 1, metric.WithAttributes(attribute.String("source", source)))
+	}
+	if source != conversionLocal {
+		return nil, false
+	}
+	return &result, true
+}
+
+// wait returns once the first full set of rates is received or ctx is done.
+func (t *rateTable) wait(ctx context.Context) error {
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// check reports whether the rates are fresh enough to convert with.
+func (t *rateTable) check(context.Context) error {
+	age, ok := t.age()
+	switch {
+	case !ok:
+		return errors.New("no currency rates received yet")
+	case age > t.maxAge:
+		return fmt.Errorf("currency rates are %s old", age.Round(time.Second))
+	}
+	return nil
+}
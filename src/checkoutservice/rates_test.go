@@ -0,0 +1,128 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestRateTableConvert(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	rates := newRateTable(nil)
+	rates.now = func() time.Time { return now }
+	usd := &pb.Money{CurrencyCode: "USD", Units: 10}
+
+	if _, ok := rates.convert(context.Background(), usd, "EUR"); ok {
+		t.Error("converted before any rates were received")
+	}
+	if err := rates.check(context.Background()); err == nil {
+		t.Error("check succeeded before any rates were received")
+	}
+
+	rates.apply(&pb.CurrencyRates{Rates: map[string]float64{"EUR": 1, "USD": 2}, Full: true})
+	got, ok := rates.convert(context.Background(), usd, "EUR")
+	if !ok || got.GetCurrencyCode() != "EUR" || got.GetUnits() != 5 || got.GetNanos() != 0 {
+		t.Errorf("convert = %v, %v; want 5 EUR", got, ok)
+	}
+	if _, ok := rates.convert(context.Background(), usd, "JPY"); ok {
+		t.Error("converted into a currency without a rate")
+	}
+
+	// changes only replace the rates they list
+	rates.apply(&pb.CurrencyRates{Rates: map[string]float64{"USD": 4}})
+	if got, _ := rates.convert(context.Background(), usd, "EUR"); got.GetUnits() != 2 || got.GetNanos() != 500000000 {
+		t.Errorf("convert after change = %v, want 2.5 EUR", got)
+	}
+
+	now = now.Add(rates.maxAge + time.Second)
+	if _, ok := rates.convert(context.Background(), usd, "EUR"); ok {
+		t.Error("converted with stale rates")
+	}
+	if err := rates.check(context.Background()); err == nil {
+		t.Error("check succeeded with stale rates")
+	}
+	rates.apply(&pb.CurrencyRates{})
+	if _, ok := rates.convert(context.Background(), usd, "EUR"); !ok {
+		t.Error("heartbeat did not refresh the rates")
+	}
+
+	var disabled *rateTable
+	if _, ok := disabled.convert(context.Background(), usd, "EUR"); ok {
+		t.Error("nil rate table converted")
+	}
+}
+
+type fakeCurrencyService struct {
+	pb.UnimplementedCurrencyServiceServer
+	updates []*pb.CurrencyRates
+}
+
+func (s *fakeCurrencyService) WatchRates(_ *pb.WatchRatesRequest, stream pb.CurrencyService_WatchRatesServer) error {
+	for _, u := range s.updates {
+		if err := stream.Send(u); err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func TestRateTableWatch(t *testing.T) {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	pb.RegisterCurrencyServiceServer(srv, &fakeCurrencyService{updates: []*pb.CurrencyRates{
+		{Rates: map[string]float64{"EUR": 1, "USD": 1.25}, Full: true},
+		{Rates: map[string]float64{"USD": 2}},
+	}})
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rates := newRateTable(pb.NewCurrencyServiceClient(conn))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go rates.run(ctx)
+	if err := rates.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		got, ok := rates.convert(ctx, &pb.Money{CurrencyCode: "EUR", Units: 3}, "USD")
+		if ok && got.GetUnits() == 6 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("change was not applied, last conversion %v", got)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
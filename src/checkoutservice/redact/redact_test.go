@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"order o-1 placed", "order o-1 placed"},
+		{"confirmation sent to someone@example.com", "confirmation sent to " + Mask},
+		{"card 4432-8015-6152-0454 declined", "card " + Mask + " declined"},
+		{"card 4432 8015 6152 0454 declined", "card " + Mask + " declined"},
+		{"card 4432801561520454 declined", "card " + Mask + " declined"},
+		// Long numbers that are not card numbers are kept.
+		{"tracking 1234567890123", "tracking 1234567890123"},
+		{"ship to 1600 Amphitheatre Parkway, Mountain View", "ship to " + Mask + ", Mountain View"},
+		{`email:"someone@example.com" user_id:"u-1"`, `email:"` + Mask + `" user_id:"u-1"`},
+		{`address:{street_address:"1 x" city:"Paris" zip_code:75001}`, `address:{street_address:"` + Mask + `" city:"Paris" zip_code:` + Mask + `}`},
+		{`credit_card:{credit_card_number:"4111" credit_card_cvv:672 credit_card_expiration_month:1}`,
+			`credit_card:{credit_card_number:"` + Mask + `" credit_card_cvv:` + Mask + ` credit_card_expiration_month:` + Mask + `}`},
+		{`{"email": "a", "Password": "b", "currency": "EUR"}`, `{"email": "` + Mask + `", "Password": "` + Mask + `", "currency": "EUR"}`},
+		{"user=u-1 email=x zip-code=94043", "user=u-1 email=" + Mask + " zip-code=" + Mask},
+		{"dial paymentservice:50051 at 10.0.0.1:8080", "dial paymentservice:50051 at 10.0.0.1:8080"},
+	} {
+		if got := String(tc.in); got != tc.want {
+			t.Errorf("String(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSensitiveKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"email":              true,
+		"Email":              true,
+		"order.email":        true,
+		"street_address":     true,
+		"ZipCode":            true,
+		"credit_card_number": true,
+		"CreditCardCvv":      true,
+		"cvv":                true,
+		"user_id":            false,
+		"server.address":     false,
+		"rpc.service":        false,
+		"app.user.hash":      false,
+		"emailSvcAddr":       false,
+		"http.req.path":      false,
+	} {
+		if got := SensitiveKey(key); got != want {
+			t.Errorf("SensitiveKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	if got := Value("email", "not-an-email"); got != Mask {
+		t.Errorf("Value of a sensitive key = %q, want %q", got, Mask)
+	}
+	if got := Value("note", "call someone@example.com"); strings.Contains(got, "someone@example.com") {
+		t.Errorf("Value(%q) = %q leaks an email address", "note", got)
+	}
+	if got := Value("email", ""); got != "" {
+		t.Errorf("Value of an empty field = %q, want it empty", got)
+	}
+}
@@ -0,0 +1,581 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/audit"
+	money "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// Orders are replicated active-passive between regions by event replay: the
+// active region (the primary) appends every order it saves, and the order
+// again whenever its status changes, to the order_outbox table in the same
+// transaction, and the passive regions
+// (replicas) poll the primary's outbox and apply its events in order,
+// copying them into their own outbox so that they can serve them in turn
+// once promoted.
+//
+// Every region keeps its role, its epoch and the last outbox sequence number
+// it applied in replication_state. Promoting a replica makes it the primary
+// of a new epoch; the epoch of each event tells a replica which primary wrote
+// it, so that events from a stale primary are detected. Events that cannot be
+// applied cleanly are recorded in replication_conflicts and skipped.
+
+const (
+	rolePrimary = "primary"
+	roleReplica = "replica"
+
+	defaultReplicationInterval = time.Second
+	// replayBatch is how many events a replica applies per transaction.
+	replayBatch = 100
+
+	reasonRegionPassive = "REGION_PASSIVE"
+)
+
+var errNotReplica = errors.New("region is not a replica")
+
+// appends an order to the outbox, tagged with the region's epoch; nothing is
+// appended unless the region is a replication primary
+func appendOutbox(ctx context.Context, tx dbtx, rec orderRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox event: %w", err)
+	}
+	ctx, span := startStatement(ctx, "INSERT", "order_outbox")
+	res, err := tx.ExecContext(ctx, `
+        INSERT INTO order_outbox (order_id, region, epoch, payload)
+        SELECT $1, region, epoch, $2 FROM replication_state WHERE role = 'primary'
+    `, rec.Order.OrderID, payload)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	endStatement(span, n, err)
+	if err != nil {
+		return fmt.Errorf("failed to append to outbox: %w", err)
+	}
+	return nil
+}
+
+// replicationState is the replication_state of a region.
+type replicationState struct {
+	Role       string `json:"role"`
+	Region     string `json:"region"`
+	Epoch      int64  `json:"epoch"`
+	AppliedSeq int64  `json:"applied_seq"`
+}
+
+// outboxEvent is a row of order_outbox.
+type outboxEvent struct {
+	Seq     int64
+	OrderID string
+	Region  string
+	Epoch   int64
+	Payload []byte
+}
+
+// replicator replicates the orders store of a region.
+type replicator struct {
+	local    *sql.DB
+	store    *OrderStore
+	interval time.Duration
+
+	// primary is the database replicas replay, nil on a primary that was
+	// not configured with REPLICATION_PRIMARY_DSN.
+	primary *sql.DB
+
+	// mu serializes replays, promotions and demotions.
+	mu         sync.Mutex
+	state      replicationState
+	primarySeq int64
+	lastErr    error
+	conflicts  int64
+}
+
+// newReplicatorFromEnv sets up replication of db when REPLICATION_MODE is
+// set, to primary or replica, and returns nil otherwise. REGION names the
+// region; replicas replay the database at REPLICATION_PRIMARY_DSN, which can
+// also be read from a secret with REPLICATION_PRIMARY_DSN_SECRET. The
+// persisted role wins over REPLICATION_MODE once a region was promoted or
+// demoted.
+func newReplicatorFromEnv(ctx context.Context, db *sql.DB, secretStore *secrets.Manager) (*replicator, error) {
+	mode := strings.ToLower(os.Getenv("REPLICATION_MODE"))
+	if mode == "" {
+		return nil, nil
+	}
+	if mode != rolePrimary && mode != roleReplica {
+		return nil, fmt.Errorf("invalid REPLICATION_MODE %q", mode)
+	}
+	region := os.Getenv("REGION")
+	if region == "" {
+		return nil, errors.New("REGION must be set for replication")
+	}
+	r := &replicator{local: db, store: NewOrderStore(db), interval: defaultReplicationInterval}
+	if v := os.Getenv("REPLICATION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid REPLICATION_INTERVAL %q", v)
+		}
+		r.interval = d
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, `
+        INSERT INTO replication_state (role, region, epoch) VALUES ($1, $2, 1)
+        ON CONFLICT (id) DO NOTHING
+    `, mode, region); err != nil {
+		return nil, fmt.Errorf("failed to initialize replication state: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, `
+        SELECT role, region, epoch, applied_seq FROM replication_state
+    `).Scan(&r.state.Role, &r.state.Region, &r.state.Epoch, &r.state.AppliedSeq); err != nil {
+		return nil, fmt.Errorf("failed to load replication state: %w", err)
+	}
+	if r.state.Role != mode {
+		log.Warnf("replication: role %s, persisted after a failover, overrides REPLICATION_MODE=%s", r.state.Role, mode)
+	}
+	if r.state.Region != region {
+		return nil, fmt.Errorf("database belongs to region %q, not REGION %q", r.state.Region, region)
+	}
+
+	if os.Getenv("REPLICATION_PRIMARY_DSN") != "" || os.Getenv("REPLICATION_PRIMARY_DSN_SECRET") != "" {
+		r.primary = sql.OpenDB(&secretDSNConnector{secrets: secretStore, key: "REPLICATION_PRIMARY_DSN"})
+		r.primary.SetMaxOpenConns(2)
+		r.primary.SetConnMaxLifetime(time.Hour)
+	} else if r.state.Role == roleReplica {
+		return nil, errors.New("REPLICATION_PRIMARY_DSN must be set on replicas")
+	}
+
+	if _, err := otel.Meter("checkoutservice").Int64ObservableGauge(
+		"checkout.replication.lag",
+		metric.WithDescription("Outbox events of the primary not yet applied by this replica."),
+		metric.WithUnit("{event}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			if st := r.status(); st.Role == roleReplica {
+				o.Observe(st.Lag)
+			}
+			return nil
+		})); err != nil {
+		log.Warnf("replication: failed to register lag gauge: %v", err)
+	}
+
+	log.Infof("replication: region %s is %s at epoch %d", r.state.Region, r.state.Role, r.state.Epoch)
+	return r, nil
+}
+
+// secretDSNConnector opens connections to the PostgreSQL database at the
+// DSN of key, such as the primary's database, resolving it each time so that
+// rotated credentials are used.
+type secretDSNConnector struct {
+	secrets *secrets.Manager
+	key     string
+}
+
+func (c *secretDSNConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.secrets.Value(ctx, c.key)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *secretDSNConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// close closes the connections to the primary.
+func (r *replicator) close(context.Context) error {
+	if r.primary == nil {
+		return nil
+	}
+	return r.primary.Close()
+}
+
+// acceptOrders fails unless the region is the primary, since orders placed
+// in a passive region would be lost or conflict at failover.
+func (r *replicator) acceptOrders() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Role != rolePrimary {
+		return rpcerrors.Errorf(codes.Unavailable, reasonRegionPassive,
+			"region %s is passive; orders are placed in the active region", r.state.Region)
+	}
+	return nil
+}
+
+// run replays the primary's outbox every interval while the region is a
+// replica, until ctx is done.
+func (r *replicator) run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := r.catchUp(ctx); err != nil && !errors.Is(err, errNotReplica) && ctx.Err() == nil {
+			log.Warnf("replication: %v", err)
+		}
+	}
+}
+
+// catchUp applies the primary's outbox events until none is left, and
+// returns how many it applied.
+func (r *replicator) catchUp(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for {
+		n, err := r.replayLocked(ctx)
+		total += n
+		r.lastErr = err
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
+}
+
+// replayLocked applies the next batch of the primary's outbox events in one
+// transaction.
+func (r *replicator) replayLocked(ctx context.Context) (int, error) {
+	if r.state.Role != roleReplica {
+		return 0, errNotReplica
+	}
+	if err := r.primary.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM order_outbox`).Scan(&r.primarySeq); err != nil {
+		return 0, fmt.Errorf("failed to read the primary's outbox: %w", err)
+	}
+	rows, err := r.primary.QueryContext(ctx, `
+        SELECT seq, order_id, region, epoch, payload FROM order_outbox
+        WHERE seq > $1 ORDER BY seq LIMIT $2
+    `, r.state.AppliedSeq, replayBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the primary's outbox: %w", err)
+	}
+	var events []outboxEvent
+	for rows.Next() {
+		var ev outboxEvent
+		if err := rows.Scan(&ev.Seq, &ev.OrderID, &ev.Region, &ev.Epoch, &ev.Payload); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read the primary's outbox: %w", err)
+		}
+		events = append(events, ev)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read the primary's outbox: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	state := r.state
+	var conflicts int64
+	err = inTx(ctx, r.local, func(tx *sql.Tx) error {
+		for _, ev := range events {
+			reason, err := r.apply(ctx, tx, &state, ev)
+			if err != nil {
+				return fmt.Errorf("failed to apply outbox event %d: %w", ev.Seq, err)
+			}
+			if reason != "" {
+				conflicts++
+				log.Warnf("replication: conflict on order %s (event %d from %s, epoch %d): %s", ev.OrderID, ev.Seq, ev.Region, ev.Epoch, reason)
+				if _, err := tx.ExecContext(ctx, `
+                    INSERT INTO replication_conflicts (source_seq, order_id, reason, payload)
+                    VALUES ($1, $2, $3, $4)
+                `, ev.Seq, ev.OrderID, reason, ev.Payload); err != nil {
+					return fmt.Errorf("failed to record conflict: %w", err)
+				}
+			}
+			state.AppliedSeq = ev.Seq
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE replication_state SET epoch = $1, applied_seq = $2`, state.Epoch, state.AppliedSeq)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.state = state
+	r.conflicts += conflicts
+	return len(events), nil
+}
+
+// apply applies ev to the local database, advancing state's epoch to the
+// event's, and returns why it conflicts, if it does.
+func (r *replicator) apply(ctx context.Context, tx *sql.Tx, state *replicationState, ev outboxEvent) (string, error) {
+	if ev.Epoch < state.Epoch {
+		return fmt.Sprintf("written at epoch %d by a primary that was superseded at epoch %d", ev.Epoch, state.Epoch), nil
+	}
+	state.Epoch = ev.Epoch
+
+	var rec orderRecord
+	if err := json.Unmarshal(ev.Payload, &rec); err != nil {
+		return fmt.Sprintf("invalid payload: %v", err), nil
+	}
+	inserted, err := insertOrderRecord(ctx, dialectPostgres, tx, rec)
+	if err != nil {
+		return "", err
+	}
+	if inserted && rec.Order.Status != StatusCancelled {
+		// the primary checked the balance
+		if err := applyOrderPoints(ctx, dialectPostgres, tx, rec.Order, false); err != nil {
+			return "", err
+		}
+	}
+	if !inserted {
+		existing, err := r.loadRecord(ctx, tx, rec.Order.OrderID)
+		if err != nil {
+			return "", err
+		}
+		if diff := recordConflicts(existing, rec); len(diff) > 0 {
+			return fmt.Sprintf("order exists with different %v", diff), nil
+		}
+		// events of older versions have no status
+		to := rec.Order.Status
+		if to == "" || to == existing.Order.Status {
+			return "", nil
+		}
+		if err := existing.Order.Status.checkTransition(to); err != nil {
+			return err.Error(), nil
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE orders SET status = $2 WHERE order_id = $1`, rec.Order.OrderID, to); err != nil {
+			return "", fmt.Errorf("failed to update order status: %w", err)
+		}
+		if to == StatusCancelled {
+			if err := revertOrderPoints(ctx, dialectPostgres, tx, existing.Order); err != nil {
+				return "", err
+			}
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO order_outbox (order_id, region, epoch, payload) VALUES ($1, $2, $3, $4)
+    `, ev.OrderID, ev.Region, ev.Epoch, ev.Payload)
+	return "", err
+}
+
+// loadRecord reads an order and its items within tx.
+func (r *replicator) loadRecord(ctx context.Context, tx *sql.Tx, orderID string) (orderRecord, error) {
+	var o Order
+	err := tx.QueryRowContext(ctx, `
+        SELECT order_id, user_id, email, order_total_units, order_total_nanos, currency_code, shipping_tracking_id, status
+        FROM orders WHERE order_id = $1
+    `, orderID).Scan(&o.OrderID, &o.UserID, &o.Email, &o.OrderTotalUnits, &o.OrderTotalNanos, &o.CurrencyCode, &o.ShippingTrackingID, &o.Status)
+	if err != nil {
+		return orderRecord{}, fmt.Errorf("failed to query order: %w", err)
+	}
+	items, err := getOrderItems(ctx, tx, orderID)
+	if err != nil {
+		return orderRecord{}, err
+	}
+	return orderRecord{Order: o, Items: items}, nil
+}
+
+// recordConflicts returns the fields that differ between two versions of an
+// order: its user, email, total, tracking ID and items.
+func recordConflicts(a, b orderRecord) []string {
+	var diff []string
+	if a.Order.UserID != b.Order.UserID {
+		diff = append(diff, "user_id")
+	}
+	if a.Order.Email != b.Order.Email {
+		diff = append(diff, "email")
+	}
+	if !money.AreEquals(*a.Order.totalPaid(), *b.Order.totalPaid()) {
+		diff = append(diff, "order_total")
+	}
+	if a.Order.ShippingTrackingID != b.Order.ShippingTrackingID {
+		diff = append(diff, "shipping_tracking_id")
+	}
+	if !sameItems(a.Items, b.Items) {
+		diff = append(diff, "items")
+	}
+	return diff
+}
+
+func sameItems(a, b []OrderItem) bool {
+	count := make(map[string]int32)
+	for _, it := range a {
+		count[it.ProductID] += it.Quantity
+	}
+	for _, it := range b {
+		count[it.ProductID] -= it.Quantity
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// promote makes a replica the primary of a new epoch, after applying the
+// events left in the old primary's outbox. With force, a replica whose
+// primary cannot be reached is promoted anyway, losing the orders it did not
+// replicate yet.
+func (r *replicator) promote(ctx context.Context, force bool) error {
+	if _, err := r.catchUp(ctx); err != nil {
+		if errors.Is(err, errNotReplica) {
+			return err
+		}
+		if !force {
+			return fmt.Errorf("failed to catch up with the primary, promote with force to accept losing its last orders: %w", err)
+		}
+		log.Warnf("replication: promoting without catching up: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Role != roleReplica {
+		return errNotReplica
+	}
+	epoch := r.state.Epoch + 1
+	if _, err := r.local.ExecContext(ctx, `UPDATE replication_state SET role = 'primary', epoch = $1`, epoch); err != nil {
+		return fmt.Errorf("failed to promote: %w", err)
+	}
+	r.state.Role = rolePrimary
+	r.state.Epoch = epoch
+	log.Infof("replication: region %s promoted to primary at epoch %d", r.state.Region, epoch)
+	return nil
+}
+
+// demote turns a primary into a replica of the primary configured with
+// REPLICATION_PRIMARY_DSN, typically the replica promoted in its place. It
+// replays that primary's whole outbox, so that orders the demoted region
+// placed without replicating them show up as conflicts.
+func (r *replicator) demote(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Role != rolePrimary {
+		return errors.New("region is not a primary")
+	}
+	if r.primary == nil {
+		return errors.New("REPLICATION_PRIMARY_DSN must point at the new primary to demote")
+	}
+	if _, err := r.local.ExecContext(ctx, `UPDATE replication_state SET role = 'replica', applied_seq = 0`); err != nil {
+		return fmt.Errorf("failed to demote: %w", err)
+	}
+	r.state.Role = roleReplica
+	r.state.AppliedSeq = 0
+	log.Infof("replication: region %s demoted to replica", r.state.Region)
+	return nil
+}
+
+// replicationStatus is the state reported by the status endpoint.
+type replicationStatus struct {
+	replicationState
+	PrimarySeq int64  `json:"primary_seq,omitempty"`
+	Lag        int64  `json:"lag"`
+	Conflicts  int64  `json:"conflicts"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+func (r *replicator) status() replicationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := replicationStatus{replicationState: r.state, Conflicts: r.conflicts}
+	if r.state.Role == roleReplica {
+		st.PrimarySeq = r.primarySeq
+		st.Lag = max(0, r.primarySeq-r.state.AppliedSeq)
+	}
+	if r.lastErr != nil {
+		st.LastError = r.lastErr.Error()
+	}
+	return st
+}
+
+// check reports whether a replica is keeping up with its primary, for health
+// checks.
+func (r *replicator) check(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Role == roleReplica {
+		return r.lastErr
+	}
+	return nil
+}
+
+// handleDebug registers the replication admin endpoints on mux:
+// GET /debug/replication reports the status, and POST
+// /debug/replication/promote (with ?force=true to skip catching up) and
+// /debug/replication/demote run the failover procedure. Both are audited.
+func (r *replicator) handleDebug(mux *http.ServeMux, auditLog *audit.Log) {
+	mux.HandleFunc("/debug/replication", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.status())
+	})
+	post := func(op string, fn func(*http.Request) error) http.Handler {
+		return auditLog.HTTPHandler(op, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				w.Header().Set("Allow", "POST")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := fn(req); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(r.status())
+		}))
+	}
+	mux.Handle("/debug/replication/promote", post("replication.Promote", func(req *http.Request) error {
+		return r.promote(req.Context(), req.URL.Query().Get("force") == "true")
+	}))
+	mux.Handle("/debug/replication/demote", post("replication.Demote", func(req *http.Request) error {
+		return r.demote(req.Context())
+	}))
+}
+
+// inTx runs fn in a transaction of db, committing it if fn succeeds.
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
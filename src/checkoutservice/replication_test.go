@@ -0,0 +1,94 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func replicatedOrder() orderRecord {
+	return orderRecord{
+		Order: Order{
+			OrderID:            "order-1",
+			UserID:             "user-1",
+			Email:              "someone@example.com",
+			OrderTotalUnits:    19,
+			OrderTotalNanos:    990000000,
+			CurrencyCode:       "USD",
+			ShippingTrackingID: "track-1",
+			CreatedAt:          time.Now(),
+		},
+		Items: []OrderItem{{ProductID: "OLJCESPC7Z", Quantity: 1}, {ProductID: "66VCHSJNUP", Quantity: 2}},
+	}
+}
+
+func TestRecordConflicts(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		modify func(*orderRecord)
+		want   []string
+	}{
+		{"same order", func(*orderRecord) {}, nil},
+		{"stored later", func(r *orderRecord) { r.Order.CreatedAt = r.Order.CreatedAt.Add(time.Hour) }, nil},
+		{"other total", func(r *orderRecord) { r.Order.OrderTotalNanos = 990000001 }, []string{"order_total"}},
+		{"items in another order", func(r *orderRecord) { r.Items[0], r.Items[1] = r.Items[1], r.Items[0] }, nil},
+		{"other user", func(r *orderRecord) { r.Order.UserID = "user-2" }, []string{"user_id"}},
+		{"other currency", func(r *orderRecord) { r.Order.CurrencyCode = "EUR" }, []string{"order_total"}},
+		{"other items", func(r *orderRecord) { r.Items[1].Quantity = 3 }, []string{"items"}},
+		{"other order", func(r *orderRecord) {
+			r.Order.Email = "other@example.com"
+			r.Order.ShippingTrackingID = "track-2"
+		}, []string{"email", "shipping_tracking_id"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := replicatedOrder()
+			tt.modify(&b)
+			if got := recordConflicts(replicatedOrder(), b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recordConflicts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptOrders(t *testing.T) {
+	var disabled *replicator
+	if err := disabled.acceptOrders(); err != nil {
+		t.Errorf("acceptOrders() without replication = %v, want nil", err)
+	}
+	primary := &replicator{state: replicationState{Role: rolePrimary, Region: "us-east1"}}
+	if err := primary.acceptOrders(); err != nil {
+		t.Errorf("acceptOrders() on a primary = %v, want nil", err)
+	}
+	replica := &replicator{state: replicationState{Role: roleReplica, Region: "europe-west1"}}
+	if err := replica.acceptOrders(); status.Code(err) != codes.Unavailable {
+		t.Errorf("acceptOrders() on a replica = %v, want Unavailable", err)
+	}
+}
+
+func TestReplicationStatusLag(t *testing.T) {
+	r := &replicator{state: replicationState{Role: roleReplica, AppliedSeq: 40}, primarySeq: 42}
+	if st := r.status(); st.Lag != 2 {
+		t.Errorf("status().Lag = %d, want 2", st.Lag)
+	}
+	r.state.Role = rolePrimary
+	if st := r.status(); st.Lag != 0 || st.PrimarySeq != 0 {
+		t.Errorf("status() on a primary = %+v, want no lag", st)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestValid(t *testing.T) {
+	for id, want := range map[string]bool{
+		"":                                     false,
+		"3f2c8f5e-8c1a-4a57-9d4e-0b6f0e1f2a3b": true,
+		"lb.edge_01":                           true,
+		"has space":                            false,
+		"new\nline":                            false,
+		strings.Repeat("a", maxLen):            true,
+		strings.Repeat("a", maxLen+1):          false,
+	} {
+		if got := Valid(id); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var got string
+	handler := func(ctx context.Context, req any) (any, error) {
+		got = FromContext(ctx)
+		return nil, nil
+	}
+	intercept := UnaryServerInterceptor()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "req-1"))
+	if _, err := intercept(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if got != "req-1" {
+		t.Errorf("request ID = %q, want req-1", got)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "bad id"))
+	if _, err := intercept(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if got == "" || got == "bad id" {
+		t.Errorf("request ID = %q, want a newly generated ID", got)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	intercept := UnaryClientInterceptor()
+
+	ctx := NewContext(context.Background(), "req-1")
+	if err := intercept(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if v := md.Get(MetadataKey); len(v) != 1 || v[0] != "req-1" {
+		t.Errorf("outgoing %s = %v, want [req-1]", MetadataKey, v)
+	}
+
+	if err := intercept(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if v := md.Get(MetadataKey); len(v) != 0 {
+		t.Errorf("outgoing %s = %v without a request ID in the context", MetadataKey, v)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// When ORDER_RETENTION_DAYS is set, an orderJanitor removes the orders older
+// than that many days every ORDER_RETENTION_INTERVAL, so that demo clusters
+// do not accumulate orders forever. By default the orders and their items are
+// moved to orders_archive and order_items_archive, which keep the same
+// columns; with ORDER_RETENTION_MODE=delete they are deleted outright. With
+// ORDER_RETENTION_DRY_RUN=1 the janitor only logs and counts the orders it
+// would remove. Orders are removed a batch per transaction, so that sweeping
+// a large backlog does not hold long locks, and each region sweeps its own
+// database.
+
+const (
+	retentionArchive = "archive"
+	retentionDelete  = "delete"
+
+	defaultRetentionInterval = time.Hour
+	// retentionBatch is how many orders are removed per transaction.
+	retentionBatch = 500
+)
+
+// orderItemColumns are the columns of order_items, which order_items_archive
+// has too; orders_archive has the orderColumns of orders.
+const orderItemColumns = `id, order_id, product_id, quantity, unit_price_units, unit_price_nanos, currency_code`
+
+var ordersExpired, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.retention.orders",
+	metric.WithDescription("Orders past their retention archived or deleted, by mode, including those a dry run would have."),
+	metric.WithUnit("{order}"),
+)
+
+// orderJanitor removes the orders older than retention from a store.
+type orderJanitor struct {
+	db        *sql.DB
+	dialect   dialect
+	retention time.Duration
+	// mode is retentionArchive or retentionDelete.
+	mode     string
+	dryRun   bool
+	interval time.Duration
+	now      func() time.Time
+}
+
+// newOrderJanitorFromEnv returns the janitor of store configured by the
+// ORDER_RETENTION_* variables, or nil if ORDER_RETENTION_DAYS is not set.
+func newOrderJanitorFromEnv(store *OrderStore) (*orderJanitor, error) {
+	v := os.Getenv("ORDER_RETENTION_DAYS")
+	if v == "" {
+		return nil, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 1 {
+		return nil, fmt.Errorf("invalid ORDER_RETENTION_DAYS %q", v)
+	}
+	j := &orderJanitor{
+		db:        store.db,
+		dialect:   store.dialect,
+		retention: time.Duration(days) * 24 * time.Hour,
+		mode:      retentionArchive,
+		dryRun:    os.Getenv("ORDER_RETENTION_DRY_RUN") == "1",
+		interval:  defaultRetentionInterval,
+		now:       time.Now,
+	}
+	switch m := os.Getenv("ORDER_RETENTION_MODE"); m {
+	case "", retentionArchive:
+	case retentionDelete:
+		j.mode = m
+	default:
+		return nil, fmt.Errorf("invalid ORDER_RETENTION_MODE %q: want %s or %s", m, retentionArchive, retentionDelete)
+	}
+	if v := os.Getenv("ORDER_RETENTION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid ORDER_RETENTION_INTERVAL %q", v)
+		}
+		j.interval = d
+	}
+	return j, nil
+}
+
+func (j *orderJanitor) String() string {
+	s := fmt.Sprintf("%s orders older than %v every %v", j.mode, j.retention, j.interval)
+	if j.dryRun {
+		s += " (dry run)"
+	}
+	return s
+}
+
+// run sweeps the expired orders every interval until ctx is done.
+func (j *orderJanitor) run(ctx context.Context) {
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := j.sweep(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warnf("order retention: %v", err)
+		}
+		if n > 0 && j.dryRun {
+			log.Infof("order retention: would %s %d orders created before %s", j.mode, n, j.cutoff().Format(time.RFC3339))
+		} else if n > 0 {
+			log.Infof("order retention: %sd %d orders", j.mode, n)
+		}
+	}
+}
+
+// cutoff returns the time before which orders expire.
+func (j *orderJanitor) cutoff() time.Time {
+	return j.now().Add(-j.retention)
+}
+
+// sweep removes the expired orders a batch at a time, or only counts them
+// in a dry run, and returns how many it removed or counted.
+func (j *orderJanitor) sweep(ctx context.Context) (int64, error) {
+	cutoff := j.cutoff()
+	var total int64
+	defer func() {
+		ordersExpired.Add(ctx, total, metric.WithAttributes(
+			attribute.String("mode", j.mode), attribute.Bool("dry_run", j.dryRun)))
+	}()
+	if j.dryRun {
+		err := j.db.QueryRowContext(ctx, `SELECT count(*) FROM orders WHERE created_at < $1`, cutoff).Scan(&total)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count expired orders: %w", err)
+		}
+		return total, nil
+	}
+	for {
+		var n int
+		err := retryDB(ctx, "remove expired orders", func() (err error) {
+			n, err = j.removeBatch(ctx, cutoff)
+			return err
+		})
+		total += int64(n)
+		if err != nil || n < retentionBatch {
+			return total, err
+		}
+	}
+}
+
+// removeBatch archives or deletes up to retentionBatch orders created before
+// cutoff, in one transaction, and returns how many it removed.
+func (j *orderJanitor) removeBatch(ctx context.Context, cutoff time.Time) (int, error) {
+	var orderIDs []string
+	err := inTx(ctx, j.db, func(tx *sql.Tx) error {
+		var err error
+		orderIDs, err = lockExpiredOrders(ctx, tx, j.dialect, cutoff)
+		if err != nil || len(orderIDs) == 0 {
+			return err
+		}
+		// exec runs query on the rows of orderIDs
+		exec := func(what, query string) error {
+			var args []any
+			_, err := tx.ExecContext(ctx, query+` WHERE `+j.dialect.inList("order_id", orderIDs, &args), args...)
+			if err != nil {
+				return fmt.Errorf("failed to %s: %w", what, err)
+			}
+			return nil
+		}
+		if j.mode == retentionArchive {
+			if err := exec("archive orders", `
+                INSERT INTO orders_archive (`+orderColumns+`)
+                SELECT `+orderColumns+` FROM orders`); err != nil {
+				return err
+			}
+			if err := exec("archive order items", `
+                INSERT INTO order_items_archive (`+orderItemColumns+`)
+                SELECT `+orderItemColumns+` FROM order_items`); err != nil {
+				return err
+			}
+		}
+		// SQLite does not cascade deletes unless foreign keys are enforced
+		if err := exec("delete order items", `DELETE FROM order_items`); err != nil {
+			return err
+		}
+		if err := exec("delete idempotency keys", `DELETE FROM idempotency_keys`); err != nil {
+			return err
+		}
+		// archived orders keep their notes; see ordernotes.go
+		if j.mode == retentionDelete {
+			if err := exec("delete order notes", `DELETE FROM order_notes`); err != nil {
+				return err
+			}
+		}
+		return exec("delete orders", `DELETE FROM orders`)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(orderIDs), nil
+}
+
+// lockExpiredOrders returns the IDs of the oldest orders created before
+// cutoff, up to retentionBatch of them, locking them until tx ends.
+func lockExpiredOrders(ctx context.Context, tx *sql.Tx, d dialect, cutoff time.Time) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT order_id FROM orders WHERE created_at < $1 ORDER BY created_at LIMIT $2 `+d.forUpdate(),
+		cutoff, retentionBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired orders: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan order ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestOrderJanitorFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, "", false},
+		{"archive", map[string]string{"ORDER_RETENTION_DAYS": "30"}, "archive orders older than 720h0m0s every 1h0m0s", false},
+		{"delete dry run", map[string]string{"ORDER_RETENTION_DAYS": "1", "ORDER_RETENTION_MODE": "delete", "ORDER_RETENTION_DRY_RUN": "1", "ORDER_RETENTION_INTERVAL": "10m"},
+			"delete orders older than 24h0m0s every 10m0s (dry run)", false},
+		{"no days", map[string]string{"ORDER_RETENTION_DAYS": "0"}, "", true},
+		{"unknown mode", map[string]string{"ORDER_RETENTION_DAYS": "30", "ORDER_RETENTION_MODE": "truncate"}, "", true},
+		{"invalid interval", map[string]string{"ORDER_RETENTION_DAYS": "30", "ORDER_RETENTION_INTERVAL": "daily"}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"ORDER_RETENTION_DAYS", "ORDER_RETENTION_MODE", "ORDER_RETENTION_DRY_RUN", "ORDER_RETENTION_INTERVAL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			j, err := newOrderJanitorFromEnv(&OrderStore{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newOrderJanitorFromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			got := ""
+			if j != nil {
+				got = j.String()
+			}
+			if got != tt.want {
+				t.Errorf("newOrderJanitorFromEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOrderJanitor archives and deletes expired orders from a SQLite
+// database.
+func TestOrderJanitor(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	userID := uuid.NewString()
+	save := func(age time.Duration) string {
+		orderID := uuid.NewString()
+		if err := store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: userID, Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1"}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}})}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.db.ExecContext(ctx, `UPDATE orders SET created_at = $1 WHERE order_id = $2`, time.Now().Add(-age), orderID); err != nil {
+			t.Fatal(err)
+		}
+		return orderID
+	}
+	archived := save(40 * 24 * time.Hour)
+	deleted := save(35 * 24 * time.Hour)
+	kept := save(time.Hour)
+	for _, orderID := range []string{archived, deleted} {
+		if _, err := store.AddOrderNote(ctx, OrderNote{OrderID: orderID, Author: "support@example.com", Text: "refund requested"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(table string) int {
+		var n int
+		if err := store.db.QueryRowContext(ctx, `SELECT count(*) FROM `+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	j := &orderJanitor{db: store.db, dialect: store.dialect, retention: 30 * 24 * time.Hour, mode: retentionArchive, dryRun: true, now: time.Now}
+	if n, err := j.sweep(ctx); err != nil || n != 2 {
+		t.Errorf("dry run sweep() = %d, %v, want 2 orders counted", n, err)
+	}
+	if n := count("orders"); n != 3 {
+		t.Errorf("a dry run left %d orders, want all 3", n)
+	}
+
+	// a shorter retention first archives the oldest order only
+	j.dryRun, j.retention = false, 38*24*time.Hour
+	if n, err := j.sweep(ctx); err != nil || n != 1 {
+		t.Fatalf("sweep() = %d, %v, want 1 order archived", n, err)
+	}
+	var archivedID string
+	if err := store.db.QueryRowContext(ctx, `SELECT order_id FROM orders_archive`).Scan(&archivedID); err != nil || archivedID != archived {
+		t.Errorf("orders_archive holds %q, %v, want order %s", archivedID, err, archived)
+	}
+	if n := count("order_items_archive"); n != 1 {
+		t.Errorf("order_items_archive holds %d items, want 1", n)
+	}
+
+	j.mode, j.retention = retentionDelete, 30*24*time.Hour
+	if n, err := j.sweep(ctx); err != nil || n != 1 {
+		t.Fatalf("sweep() = %d, %v, want 1 order deleted", n, err)
+	}
+	if _, err := store.GetOrder(ctx, deleted); err == nil {
+		t.Errorf("expired order %s is still stored", deleted)
+	}
+	if n := count("orders_archive"); n != 1 {
+		t.Errorf("orders_archive holds %d orders after deleting, want 1", n)
+	}
+	if n := count("order_notes"); n != 1 {
+		t.Errorf("order_notes holds %d notes after deleting, want only that of the archived order", n)
+	}
+	if o, err := store.GetOrder(ctx, kept); err != nil || len(o.Items) != 1 {
+		t.Errorf("recent order = %+v, %v, want it kept with its items", o, err)
+	}
+	if n := count("order_items"); n != 1 {
+		t.Errorf("order_items holds %d items, want only those of the recent order", n)
+	}
+
+	// erasing the user reaches the archive too
+	e, err := store.DeleteUserData(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.OrdersAnonymized != 2 || e.OrderItemsDeleted != 2 {
+		t.Errorf("DeleteUserData() = %+v, want the recent and archived orders erased", e)
+	}
+	var email string
+	if err := store.db.QueryRowContext(ctx, `SELECT email FROM orders_archive`).Scan(&email); err != nil || email != "" {
+		t.Errorf("archived order has email %q, %v, want it erased", email, err)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpcerrors recovers panics in gRPC handlers and classifies the errors
+// the services return, so that callers can tell whether a failure is worth
+// retrying and whose fault it was.
+//
+// Every error leaving a server through its interceptors carries a
+// google.rpc.ErrorInfo detail whose Reason names the failure and whose
+// Metadata holds its class ("retryable" or "terminal") and fault ("user" or
+// "system"). Retryable errors also carry a google.rpc.RetryInfo detail with a
+// suggested delay. Handlers can choose the reason with Errorf; otherwise it is
+// derived from the status code:
+//
+//	Code                                       Class      Fault
+//	InvalidArgument, NotFound, AlreadyExists,  terminal   user
+//	FailedPrecondition, OutOfRange,
+//	PermissionDenied, Unauthenticated, Canceled
+//	ResourceExhausted                          retryable  user
+//	Unavailable, DeadlineExceeded, Aborted     retryable  system
+//	Internal, Unknown, DataLoss, Unimplemented terminal   system
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package rpcerrors
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+// Domain is the ErrorInfo domain of errors raised by this application.
+const Domain = "hipstershop"
+
+const (
+	// ReasonPanic is the reason of errors returned for a handler that
+	// panicked.
+	ReasonPanic = "PANIC"
+
+	retryDelay = time.Second
+)
+
+// Fault says who caused an error: the caller, or the system serving it.
+type Fault string
+
+const (
+	FaultUser   Fault = "user"
+	FaultSystem Fault = "system"
+)
+
+// Classification describes an error in terms of the taxonomy.
+type Classification struct {
+	Code      codes.Code
+	Reason    string
+	Retryable bool
+	Fault     Fault
+	// RetryDelay is how long to wait before retrying, if Retryable.
+	RetryDelay time.Duration
+}
+
+// Errorf returns a status error with the given reason and the class and
+// fault its code implies.
+func Errorf(c codes.Code, reason, format string, args ...any) error {
+	return withDetails(status.Newf(c, format, args...), reason).Err()
+}
+
+// Classify describes err, reading the details attached by a server using
+// this package and falling back to its status code otherwise. A nil err has
+// code OK.
+func Classify(err error) Classification {
+	st, _ := status.FromError(err)
+	c := classifyCode(st.Code())
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() != Domain {
+				continue
+			}
+			c.Reason = d.GetReason()
+			if v, ok := d.GetMetadata()["class"]; ok {
+				c.Retryable = v == "retryable"
+			}
+			if v, ok := d.GetMetadata()["fault"]; ok {
+				c.Fault = Fault(v)
+			}
+		case *errdetails.RetryInfo:
+			c.RetryDelay = d.GetRetryDelay().AsDuration()
+		}
+	}
+	return c
+}
+
+func classifyCode(code codes.Code) Classification {
+	c := Classification{Code: code, Reason: code.String(), Fault: FaultSystem}
+	switch code {
+	case codes.OK:
+		c.Reason = ""
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.FailedPrecondition, codes.OutOfRange, codes.PermissionDenied,
+		codes.Unauthenticated, codes.Canceled:
+		c.Fault = FaultUser
+	case codes.ResourceExhausted:
+		c.Fault = FaultUser
+		c.Retryable = true
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		c.Retryable = true
+	}
+	if c.Retryable {
+		c.RetryDelay = retryDelay
+	}
+	return c
+}
+
+// withDetails attaches the taxonomy details to st unless it has them already.
+func withDetails(st *status.Status, reason string) *status.Status {
+	if st.Code() == codes.OK {
+		return st
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == Domain {
+			return st
+		}
+	}
+	c := classifyCode(st.Code())
+	if reason == "" {
+		reason = c.Reason
+	}
+	class := "terminal"
+	if c.Retryable {
+		class = "retryable"
+	}
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   Domain,
+		Metadata: map[string]string{"class": class, "fault": string(c.Fault)},
+	}}
+	if c.Retryable {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(c.RetryDelay)})
+	}
+	withInfo, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withInfo
+}
+
+// annotate converts err to a status error carrying the taxonomy details.
+func annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+	return withDetails(status.Convert(err), "").Err()
+}
+
+// recovered turns a recovered panic into an Internal error, logging it with
+// its stack.
+func recovered(ctx context.Context, log *logging.Logger, method string, r any) error {
+	log.WithContext(ctx).WithField("method", method).Errorf("panic: %v\n%s", r, debug.Stack())
+	return Errorf(codes.Internal, ReasonPanic, "internal error")
+}
+
+// UnaryServerInterceptor recovers panics in handlers, logging them and
+// returning Internal instead of crashing the service, and attaches the
+// taxonomy details to every error.
+func UnaryServerInterceptor(log *logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, recovered(ctx, log, info.FullMethod, r)
+			}
+		}()
+		resp, err = handler(ctx, req)
+		return resp, annotate(err)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs.
+func StreamServerInterceptor(log *logging.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), log, info.FullMethod, r)
+			}
+		}()
+		return annotate(handler(srv, ss))
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/rpcerrors"
+)
+
+// The orders schema is versioned so that, during a blue/green rollout, old and
+// new binaries can tell whether they can share a database. Each binary
+// migrates a database that is behind it and records the version it migrated
+// to, along with the oldest binary version that can still use the database.
+// Schema changes are expected to be additive, so that binaries keep working on
+// a database migrated by a newer one; a change that is not bumps
+// schemaCompatibleFrom, and older binaries then refuse to start.
+//
+// Each version is a migration in migrations/, a pair of SQL scripts named
+// NNNN_name.up.sql and NNNN_name.down.sql that migrate the schema from
+// version NNNN-1 to NNNN and back.
+const (
+	// schemaVersion is the version of the schema this binary creates and
+	// writes, that of its last migration.
+	schemaVersion = 27
+	// schemaCompatibleFrom is the oldest schemaVersion of a binary that can
+	// use a database at schemaVersion, the last of breakingVersions.
+	schemaCompatibleFrom = 27
+
+	reasonSchemaReadOnly = "SCHEMA_READ_ONLY"
+)
+
+const createSchemaVersionTable = `
+    CREATE TABLE IF NOT EXISTS schema_version (
+        id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+        version INT NOT NULL,
+        compatible_from INT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+`
+
+// schemaState is the migration state of a database.
+type schemaState struct {
+	// Version is 0 for databases created before schemas were versioned.
+	Version        int
+	CompatibleFrom int
+}
+
+// schemaVerdict is what a binary does with a database.
+type schemaVerdict int
+
+const (
+	// schemaCurrent databases are at the binary's version.
+	schemaCurrent schemaVerdict = iota
+	// schemaBehind databases are migrated by the binary.
+	schemaBehind
+	// schemaAhead databases were migrated by a newer binary that is
+	// compatible with this one.
+	schemaAhead
+	// schemaIncompatible databases were migrated by a newer binary that
+	// this one cannot use them with.
+	schemaIncompatible
+)
+
+func (v schemaVerdict) String() string {
+	switch v {
+	case schemaCurrent:
+		return "current"
+	case schemaBehind:
+		return "behind, will be migrated"
+	case schemaAhead:
+		return "ahead, compatible"
+	default:
+		return "ahead, incompatible"
+	}
+}
+
+// checkSchema decides whether a binary at version, which is schemaVersion
+// outside of tests, can use a database in state.
+func checkSchema(state schemaState, version int) schemaVerdict {
+	switch {
+	case state.Version == version:
+		return schemaCurrent
+	case state.Version < version:
+		return schemaBehind
+	case version >= state.CompatibleFrom:
+		return schemaAhead
+	default:
+		return schemaIncompatible
+	}
+}
+
+// loadSchemaState reads the migration state of db.
+func loadSchemaState(ctx context.Context, db *sql.DB) (schemaState, error) {
+	var state schemaState
+	if _, err := db.ExecContext(ctx, createSchemaVersionTable); err != nil {
+		return state, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	err := db.QueryRowContext(ctx, `SELECT version, compatible_from FROM schema_version`).Scan(&state.Version, &state.CompatibleFrom)
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return state, nil
+}
+
+// errSchemaIncompatible is returned by migrateSchema for databases migrated
+// by a binary this one is not compatible with.
+var errSchemaIncompatible = errors.New("database schema is incompatible")
+
+// migrateSchema migrates db to schemaVersion if it is behind, within
+// DB_MIGRATE_TIMEOUT, and fails with errSchemaIncompatible if it was migrated
+// by an incompatible newer binary.
+func migrateSchema(db *sql.DB) error {
+	timeouts, err := dbTimeoutsFromEnv()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.migrate)
+	defer cancel()
+	state, err := loadSchemaState(ctx, db)
+	if err != nil {
+		return err
+	}
+	verdict := checkSchema(state, schemaVersion)
+	switch verdict {
+	case schemaCurrent:
+		return nil
+	case schemaAhead:
+		log.Infof("Database schema is at version %d, ahead of this binary's %d but compatible", state.Version, schemaVersion)
+		return nil
+	case schemaIncompatible:
+		return fmt.Errorf("%w: it is at version %d, which requires binaries at version %d or later, and this binary is at version %d",
+			errSchemaIncompatible, state.Version, state.CompatibleFrom, schemaVersion)
+	}
+	return migrateUp(ctx, db, schemaVersion)
+}
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration migrates the schema from version Version-1 to Version with Up,
+// and back with Down.
+type migration struct {
+	Version  int
+	Name     string
+	Up, Down string
+}
+
+func (m migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// migrations are those embedded in the binary, by version.
+var migrations = mustLoadMigrations(migrationFiles)
+
+func mustLoadMigrations(fsys fs.FS) []migration {
+	ms, err := loadMigrations(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return ms
+}
+
+var migrationName = regexp.MustCompile(`^(\d{4})_(\w+)\.(up|down)\.sql$`)
+
+// loadMigrations reads the migrations in the migrations directory of fsys,
+// which must be numbered from 1 without gaps and each have both scripts.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*migration)
+	for _, file := range files {
+		base := path.Base(file)
+		match := migrationName.FindStringSubmatch(base)
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+		version, _ := strconv.Atoi(match[1])
+		name, direction := match[2], match[3]
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %04d is named both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+	ms := make([]migration, len(byVersion))
+	for i := range ms {
+		m, ok := byVersion[i+1]
+		if !ok {
+			return nil, fmt.Errorf("migration %04d is missing", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down script", m)
+		}
+		ms[i] = *m
+	}
+	return ms, nil
+}
+
+// breakingVersions are the schema versions older binaries cannot use:
+//
+//	6: dropped the card columns that older versions write
+//	7: replaced order_total, which older versions write, with its units and nanos
+//	27: dropped card_envelope, which older versions write
+var breakingVersions = []int{6, 7, 27}
+
+// compatibleFrom returns the oldest version of a binary that can use a
+// database at version.
+func compatibleFrom(version int) int {
+	from := min(version, 1)
+	for _, v := range breakingVersions {
+		if v <= version {
+			from = v
+		}
+	}
+	return from
+}
+
+// schemaLockID is the key of the advisory lock that serializes migrations
+// between replicas.
+const schemaLockID = 0x6f72646572730001
+
+// migrateUp applies the migrations that take db from its version to target,
+// unless it is already there or further.
+func migrateUp(ctx context.Context, db *sql.DB, target int) error {
+	return migrateSteps(ctx, db, func(version int) (migration, string, bool) {
+		if version >= target {
+			return migration{}, "", false
+		}
+		m := migrations[version]
+		return m, m.Up, true
+	})
+}
+
+// migrateDown reverts the migrations that take db from its version back to
+// target, unless it is already there or before. Databases migrated by a newer
+// binary must be reverted with that binary, which has their down scripts.
+func migrateDown(ctx context.Context, db *sql.DB, target int) error {
+	state, err := loadSchemaState(ctx, db)
+	if err != nil {
+		return err
+	}
+	if state.Version > len(migrations) {
+		return fmt.Errorf("database schema is at version %d, which this binary at version %d cannot revert", state.Version, schemaVersion)
+	}
+	return migrateSteps(ctx, db, func(version int) (migration, string, bool) {
+		if version <= target {
+			return migration{}, "", false
+		}
+		m := migrations[version-1]
+		return m, m.Down, true
+	})
+}
+
+// migrateSteps runs the script that next returns for the version of db, in a
+// transaction that also records the version it leads to, until next returns
+// false. Each transaction holds the schema lock, so replicas starting
+// together migrate once.
+func migrateSteps(ctx context.Context, db *sql.DB, next func(version int) (migration, string, bool)) error {
+	if _, err := loadSchemaState(ctx, db); err != nil {
+		return err
+	}
+	for {
+		done := false
+		err := inTx(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, schemaLockID); err != nil {
+				return fmt.Errorf("failed to lock the schema: %w", err)
+			}
+			var state schemaState
+			err := tx.QueryRowContext(ctx, `SELECT version, compatible_from FROM schema_version`).Scan(&state.Version, &state.CompatibleFrom)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to read schema version: %w", err)
+			}
+			m, script, ok := next(state.Version)
+			if !ok {
+				done = true
+				return nil
+			}
+			version, direction := m.Version, "up"
+			if state.Version >= m.Version {
+				version, direction = m.Version-1, "down"
+			}
+			if _, err := tx.ExecContext(ctx, script); err != nil {
+				return fmt.Errorf("failed to migrate %s %s: %w", m, direction, err)
+			}
+			_, err = tx.ExecContext(ctx, `
+                INSERT INTO schema_version (version, compatible_from) VALUES ($1, $2)
+                ON CONFLICT (id) DO UPDATE
+                SET version = EXCLUDED.version, compatible_from = EXCLUDED.compatible_from, updated_at = now()
+            `, version, compatibleFrom(version))
+			if err != nil {
+				return fmt.Errorf("failed to record schema version: %w", err)
+			}
+			log.Infof("Database schema migrated %s from version %d to %d (%s)", direction, state.Version, version, m)
+			return nil
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// errSchemaReadOnly is returned instead of writing to a database with an
+// incompatible schema.
+func errSchemaReadOnly() error {
+	return rpcerrors.Errorf(codes.Unavailable, reasonSchemaReadOnly,
+		"the orders database was migrated by a newer, incompatible version of the service")
+}
+
+// schemaReadOnly reports whether the service should run without writing to a
+// database with an incompatible schema, which SCHEMA_MISMATCH=read-only asks
+// for, rather than refuse to start.
+func schemaReadOnly() bool {
+	return strings.EqualFold(os.Getenv("SCHEMA_MISMATCH"), "read-only")
+}
+
+// runSchemaCheck checks whether this binary can use the configured database
+// without migrating it, printing the verdict, and returns the exit status of
+// the -check-schema command: 0 if it can, 1 if the database is incompatible
+// and 2 if it cannot be checked.
+func runSchemaCheck(ctx context.Context, db *sql.DB) int {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var state schemaState
+	err := db.QueryRowContext(ctx, `SELECT version, compatible_from FROM schema_version`).Scan(&state.Version, &state.CompatibleFrom)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !isUndefinedTable(err) {
+		fmt.Fprintf(os.Stderr, "failed to read schema version: %v\n", err)
+		return 2
+	}
+	verdict := checkSchema(state, schemaVersion)
+	fmt.Printf("binary schema version %d (compatible from %d), database schema version %d (compatible from %d): %s\n",
+		schemaVersion, schemaCompatibleFrom, state.Version, state.CompatibleFrom, verdict)
+	if verdict == schemaIncompatible {
+		return 1
+	}
+	return 0
+}
+
+// isUndefinedTable reports whether err is due to a missing table.
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
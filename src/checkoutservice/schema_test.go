@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckSchema(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		state   schemaState
+		version int
+		want    schemaVerdict
+	}{
+		{"unversioned database", schemaState{}, 2, schemaBehind},
+		{"older database", schemaState{Version: 1, CompatibleFrom: 1}, 2, schemaBehind},
+		{"same version", schemaState{Version: 2, CompatibleFrom: 1}, 2, schemaCurrent},
+		{"additive migration", schemaState{Version: 3, CompatibleFrom: 1}, 2, schemaAhead},
+		{"breaking migration", schemaState{Version: 3, CompatibleFrom: 3}, 2, schemaIncompatible},
+		{"breaking migration after this version", schemaState{Version: 4, CompatibleFrom: 2}, 2, schemaAhead},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkSchema(tt.state, tt.version); got != tt.want {
+				t.Errorf("checkSchema(%+v, %d) = %v, want %v", tt.state, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaVersions(t *testing.T) {
+	if schemaCompatibleFrom > schemaVersion {
+		t.Errorf("schemaCompatibleFrom = %d, later than schemaVersion = %d", schemaCompatibleFrom, schemaVersion)
+	}
+	if got := compatibleFrom(schemaVersion); got != schemaCompatibleFrom {
+		t.Errorf("compatibleFrom(schemaVersion) = %d, want schemaCompatibleFrom = %d", got, schemaCompatibleFrom)
+	}
+	if got := compatibleFrom(5); got != 1 {
+		t.Errorf("compatibleFrom(5) = %d, want 1 since versions up to 5 are additive", got)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	if len(migrations) != schemaVersion {
+		t.Fatalf("%d migrations are embedded, want one per version up to schemaVersion = %d", len(migrations), schemaVersion)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migrations[%d] is %s", i, m)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+	ms, err := loadMigrations(fstest.MapFS{
+		"migrations/0002_second.down.sql": file("DROP TABLE b;"),
+		"migrations/0001_first.up.sql":    file("CREATE TABLE a ();"),
+		"migrations/0002_second.up.sql":   file("CREATE TABLE b ();"),
+		"migrations/0001_first.down.sql":  file("DROP TABLE a;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].String() != "0001_first" || ms[1].Up != "CREATE TABLE b ();" || ms[1].Down != "DROP TABLE b;" {
+		t.Errorf("loadMigrations() = %+v", ms)
+	}
+
+	for _, tt := range []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"gap", fstest.MapFS{
+			"migrations/0001_first.up.sql": file("1"), "migrations/0001_first.down.sql": file("1"),
+			"migrations/0003_third.up.sql": file("3"), "migrations/0003_third.down.sql": file("3"),
+		}, "0002 is missing"},
+		{"no down script", fstest.MapFS{"migrations/0001_first.up.sql": file("1")}, "both"},
+		{"renamed", fstest.MapFS{"migrations/0001_first.up.sql": file("1"), "migrations/0001_other.down.sql": file("1")}, "named both"},
+		{"misnamed", fstest.MapFS{"migrations/1_first.up.sql": file("1")}, "not named"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.fsys); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadMigrations() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMigrateDownAndUp reverts every migration of the PostgreSQL database at
+// TEST_DB_DSN, which loses its data, and applies them again.
+func TestMigrateDownAndUp(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	if err := migrateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		down    bool
+		version int
+	}{{true, 0}, {false, 2}, {false, schemaVersion}, {true, schemaVersion - 1}, {false, schemaVersion}} {
+		migrate := migrateUp
+		if step.down {
+			migrate = migrateDown
+		}
+		if err := migrate(ctx, db, step.version); err != nil {
+			t.Fatal(err)
+		}
+		state, err := loadSchemaState(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Version != step.version {
+			t.Errorf("schema at version %d after migrating to %d", state.Version, step.version)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The order store schema for MySQL, matching version 27 of migrations/. See
+-- dialect.go.
+CREATE TABLE IF NOT EXISTS orders (
+    order_id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL,
+    email VARCHAR(255),
+    street_address VARCHAR(500),
+    city VARCHAR(100),
+    state VARCHAR(100),
+    country VARCHAR(100),
+    zip_code VARCHAR(20),
+    card_token VARCHAR(64) NOT NULL DEFAULT '',
+    card_last4 VARCHAR(4) NOT NULL DEFAULT '',
+    card_brand VARCHAR(20) NOT NULL DEFAULT '',
+    order_total_units BIGINT NOT NULL DEFAULT 0,
+    order_total_nanos INT NOT NULL DEFAULT 0,
+    currency_code VARCHAR(3),
+    shipping_tracking_id VARCHAR(100),
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    status VARCHAR(20) NOT NULL DEFAULT 'paid',
+    payment_transaction_id VARCHAR(100) NOT NULL DEFAULT '',
+    shipping_cost_units BIGINT NOT NULL DEFAULT 0,
+    shipping_cost_nanos INT NOT NULL DEFAULT 0,
+    tax_units BIGINT NOT NULL DEFAULT 0,
+    tax_nanos INT NOT NULL DEFAULT 0,
+    shipping_quote_units BIGINT NOT NULL DEFAULT 0,
+    shipping_quote_nanos INT NOT NULL DEFAULT 0,
+    shipping_quote_currency_code VARCHAR(3) NOT NULL DEFAULT '',
+    billing_street_address VARCHAR(500) NOT NULL DEFAULT '',
+    billing_city VARCHAR(100) NOT NULL DEFAULT '',
+    billing_state VARCHAR(100) NOT NULL DEFAULT '',
+    billing_country VARCHAR(100) NOT NULL DEFAULT '',
+    billing_zip_code VARCHAR(20) NOT NULL DEFAULT '',
+    gift_message VARCHAR(500) NOT NULL DEFAULT '',
+    customer_note VARCHAR(1000) NOT NULL DEFAULT '',
+    promo_code VARCHAR(64) NOT NULL DEFAULT '',
+    discount_units BIGINT NOT NULL DEFAULT 0,
+    discount_nanos INT NOT NULL DEFAULT 0,
+    points_earned BIGINT NOT NULL DEFAULT 0,
+    points_redeemed BIGINT NOT NULL DEFAULT 0,
+    INDEX idx_orders_created_at (created_at),
+    INDEX idx_orders_user_id_created_at (user_id, created_at DESC, order_id DESC),
+    INDEX idx_orders_email ((lower(email)), created_at DESC, order_id DESC),
+    INDEX idx_orders_currency_total (currency_code, order_total_units, order_total_nanos),
+    INDEX idx_orders_pending (status, created_at)
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    product_id VARCHAR(50) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price_units BIGINT NOT NULL DEFAULT 0,
+    unit_price_nanos INT NOT NULL DEFAULT 0,
+    currency_code VARCHAR(3) NOT NULL DEFAULT '',
+    INDEX idx_order_items_order_id (order_id),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id VARCHAR(50) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint BLOB NOT NULL,
+    order_id VARCHAR(50) NOT NULL,
+    response BLOB NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS order_compensations (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    user_id VARCHAR(50) NOT NULL,
+    step VARCHAR(20) NOT NULL,
+    reference VARCHAR(100) NOT NULL,
+    amount_units BIGINT,
+    amount_nanos INT,
+    currency_code VARCHAR(3),
+    cause TEXT NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    error TEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_order_compensations_order_id (order_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_erasures (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL,
+    orders_anonymized INT NOT NULL,
+    order_items_deleted INT NOT NULL,
+    idempotency_keys_deleted INT NOT NULL,
+    erased_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_user_erasures_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS orders_archive (
+    order_id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL,
+    email VARCHAR(255),
+    street_address VARCHAR(500),
+    city VARCHAR(100),
+    state VARCHAR(100),
+    country VARCHAR(100),
+    zip_code VARCHAR(20),
+    card_token VARCHAR(64) NOT NULL DEFAULT '',
+    card_last4 VARCHAR(4) NOT NULL DEFAULT '',
+    card_brand VARCHAR(20) NOT NULL DEFAULT '',
+    order_total_units BIGINT NOT NULL DEFAULT 0,
+    order_total_nanos INT NOT NULL DEFAULT 0,
+    currency_code VARCHAR(3),
+    shipping_tracking_id VARCHAR(100),
+    created_at DATETIME(6),
+    status VARCHAR(20) NOT NULL DEFAULT 'paid',
+    payment_transaction_id VARCHAR(100) NOT NULL DEFAULT '',
+    shipping_cost_units BIGINT NOT NULL DEFAULT 0,
+    shipping_cost_nanos INT NOT NULL DEFAULT 0,
+    tax_units BIGINT NOT NULL DEFAULT 0,
+    tax_nanos INT NOT NULL DEFAULT 0,
+    shipping_quote_units BIGINT NOT NULL DEFAULT 0,
+    shipping_quote_nanos INT NOT NULL DEFAULT 0,
+    shipping_quote_currency_code VARCHAR(3) NOT NULL DEFAULT '',
+    billing_street_address VARCHAR(500) NOT NULL DEFAULT '',
+    billing_city VARCHAR(100) NOT NULL DEFAULT '',
+    billing_state VARCHAR(100) NOT NULL DEFAULT '',
+    billing_country VARCHAR(100) NOT NULL DEFAULT '',
+    billing_zip_code VARCHAR(20) NOT NULL DEFAULT '',
+    gift_message VARCHAR(500) NOT NULL DEFAULT '',
+    customer_note VARCHAR(1000) NOT NULL DEFAULT '',
+    promo_code VARCHAR(64) NOT NULL DEFAULT '',
+    discount_units BIGINT NOT NULL DEFAULT 0,
+    discount_nanos INT NOT NULL DEFAULT 0,
+    points_earned BIGINT NOT NULL DEFAULT 0,
+    points_redeemed BIGINT NOT NULL DEFAULT 0,
+    archived_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_orders_archive_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_items_archive (
+    id BIGINT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    product_id VARCHAR(50) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price_units BIGINT NOT NULL DEFAULT 0,
+    unit_price_nanos INT NOT NULL DEFAULT 0,
+    currency_code VARCHAR(3) NOT NULL DEFAULT '',
+    INDEX idx_order_items_archive_order_id (order_id),
+    FOREIGN KEY (order_id) REFERENCES orders_archive(order_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS order_fingerprints (
+    fingerprint VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL,
+    order_id VARCHAR(50) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_order_fingerprints_created_at (created_at),
+    INDEX idx_order_fingerprints_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_notes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    text TEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_order_notes_order_id (order_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_points (
+    user_id VARCHAR(50) PRIMARY KEY,
+    balance BIGINT NOT NULL DEFAULT 0,
+    updated_at DATETIME(6) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS payment_attempts (
+    id VARCHAR(36) PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    idempotency_key VARCHAR(100) NOT NULL,
+    attempt INT NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    amount_units BIGINT NOT NULL,
+    amount_nanos INT NOT NULL,
+    currency_code VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transaction_id VARCHAR(100) NOT NULL DEFAULT '',
+    error TEXT,
+    created_at DATETIME(6) NOT NULL,
+    finished_at DATETIME(6),
+    INDEX idx_payment_attempts_idempotency_key (idempotency_key),
+    INDEX idx_payment_attempts_order_id (order_id)
+);
+
+CREATE TABLE IF NOT EXISTS fraud_decisions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    user_id VARCHAR(50) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    amount_units BIGINT NOT NULL,
+    amount_nanos INT NOT NULL,
+    currency_code VARCHAR(3) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    billing_country VARCHAR(100) NOT NULL,
+    card_bin VARCHAR(6) NOT NULL,
+    outcome VARCHAR(10) NOT NULL,
+    reasons TEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_fraud_decisions_email (email, created_at),
+    INDEX idx_fraud_decisions_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(50) NOT NULL,
+    user_id VARCHAR(50) NOT NULL,
+    request BLOB NOT NULL,
+    attempts INT NOT NULL,
+    next_attempt_at DATETIME(6) NOT NULL,
+    last_error TEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    failed_at DATETIME(6),
+    INDEX idx_email_outbox_next_attempt_at (next_attempt_at),
+    INDEX idx_email_outbox_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS card_vault (
+    token VARCHAR(64) PRIMARY KEY,
+    envelope BLOB NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    INDEX idx_card_vault_expires_at (expires_at)
+);
@@ -0,0 +1,243 @@
+-- Copyright 2026 Google LLC
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--      http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- The order store schema for SQLite, matching version 27 of migrations/. See
+-- dialect.go. Times are declared DATETIME so that they are read back as
+-- times.
+CREATE TABLE IF NOT EXISTS orders (
+    order_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    email TEXT,
+    street_address TEXT,
+    city TEXT,
+    state TEXT,
+    country TEXT,
+    zip_code TEXT,
+    card_token TEXT NOT NULL DEFAULT '',
+    card_last4 TEXT NOT NULL DEFAULT '',
+    card_brand TEXT NOT NULL DEFAULT '',
+    order_total_units INTEGER NOT NULL DEFAULT 0,
+    order_total_nanos INTEGER NOT NULL DEFAULT 0,
+    currency_code TEXT,
+    shipping_tracking_id TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'paid',
+    payment_transaction_id TEXT NOT NULL DEFAULT '',
+    shipping_cost_units INTEGER NOT NULL DEFAULT 0,
+    shipping_cost_nanos INTEGER NOT NULL DEFAULT 0,
+    tax_units INTEGER NOT NULL DEFAULT 0,
+    tax_nanos INTEGER NOT NULL DEFAULT 0,
+    shipping_quote_units INTEGER NOT NULL DEFAULT 0,
+    shipping_quote_nanos INTEGER NOT NULL DEFAULT 0,
+    shipping_quote_currency_code TEXT NOT NULL DEFAULT '',
+    billing_street_address TEXT NOT NULL DEFAULT '',
+    billing_city TEXT NOT NULL DEFAULT '',
+    billing_state TEXT NOT NULL DEFAULT '',
+    billing_country TEXT NOT NULL DEFAULT '',
+    billing_zip_code TEXT NOT NULL DEFAULT '',
+    gift_message TEXT NOT NULL DEFAULT '',
+    customer_note TEXT NOT NULL DEFAULT '',
+    promo_code TEXT NOT NULL DEFAULT '',
+    discount_units INTEGER NOT NULL DEFAULT 0,
+    discount_nanos INTEGER NOT NULL DEFAULT 0,
+    points_earned INTEGER NOT NULL DEFAULT 0,
+    points_redeemed INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id_created_at ON orders(user_id, created_at DESC, order_id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_email ON orders(lower(email), created_at DESC, order_id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_currency_total ON orders(currency_code, order_total_units, order_total_nanos);
+CREATE INDEX IF NOT EXISTS idx_orders_pending ON orders(created_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    product_id TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    unit_price_units INTEGER NOT NULL DEFAULT 0,
+    unit_price_nanos INTEGER NOT NULL DEFAULT 0,
+    currency_code TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    fingerprint BLOB NOT NULL,
+    order_id TEXT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    response BLOB NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS order_compensations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    step TEXT NOT NULL,
+    reference TEXT NOT NULL,
+    amount_units INTEGER,
+    amount_nanos INTEGER,
+    currency_code TEXT,
+    cause TEXT NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_order_compensations_order_id ON order_compensations(order_id);
+
+CREATE TABLE IF NOT EXISTS user_erasures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    orders_anonymized INTEGER NOT NULL,
+    order_items_deleted INTEGER NOT NULL,
+    idempotency_keys_deleted INTEGER NOT NULL,
+    erased_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_user_erasures_user_id ON user_erasures(user_id);
+
+CREATE TABLE IF NOT EXISTS orders_archive (
+    order_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    email TEXT,
+    street_address TEXT,
+    city TEXT,
+    state TEXT,
+    country TEXT,
+    zip_code TEXT,
+    card_token TEXT NOT NULL DEFAULT '',
+    card_last4 TEXT NOT NULL DEFAULT '',
+    card_brand TEXT NOT NULL DEFAULT '',
+    order_total_units INTEGER NOT NULL DEFAULT 0,
+    order_total_nanos INTEGER NOT NULL DEFAULT 0,
+    currency_code TEXT,
+    shipping_tracking_id TEXT,
+    created_at DATETIME,
+    status TEXT NOT NULL DEFAULT 'paid',
+    payment_transaction_id TEXT NOT NULL DEFAULT '',
+    shipping_cost_units INTEGER NOT NULL DEFAULT 0,
+    shipping_cost_nanos INTEGER NOT NULL DEFAULT 0,
+    tax_units INTEGER NOT NULL DEFAULT 0,
+    tax_nanos INTEGER NOT NULL DEFAULT 0,
+    shipping_quote_units INTEGER NOT NULL DEFAULT 0,
+    shipping_quote_nanos INTEGER NOT NULL DEFAULT 0,
+    shipping_quote_currency_code TEXT NOT NULL DEFAULT '',
+    billing_street_address TEXT NOT NULL DEFAULT '',
+    billing_city TEXT NOT NULL DEFAULT '',
+    billing_state TEXT NOT NULL DEFAULT '',
+    billing_country TEXT NOT NULL DEFAULT '',
+    billing_zip_code TEXT NOT NULL DEFAULT '',
+    gift_message TEXT NOT NULL DEFAULT '',
+    customer_note TEXT NOT NULL DEFAULT '',
+    promo_code TEXT NOT NULL DEFAULT '',
+    discount_units INTEGER NOT NULL DEFAULT 0,
+    discount_nanos INTEGER NOT NULL DEFAULT 0,
+    points_earned INTEGER NOT NULL DEFAULT 0,
+    points_redeemed INTEGER NOT NULL DEFAULT 0,
+    archived_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_orders_archive_user_id ON orders_archive(user_id);
+
+CREATE TABLE IF NOT EXISTS order_items_archive (
+    id INTEGER PRIMARY KEY,
+    order_id TEXT NOT NULL REFERENCES orders_archive(order_id) ON DELETE CASCADE,
+    product_id TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    unit_price_units INTEGER NOT NULL DEFAULT 0,
+    unit_price_nanos INTEGER NOT NULL DEFAULT 0,
+    currency_code TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_archive_order_id ON order_items_archive(order_id);
+
+CREATE TABLE IF NOT EXISTS order_fingerprints (
+    fingerprint TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    order_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_order_fingerprints_created_at ON order_fingerprints(created_at);
+CREATE INDEX IF NOT EXISTS idx_order_fingerprints_user_id ON order_fingerprints(user_id);
+
+CREATE TABLE IF NOT EXISTS order_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL,
+    author TEXT NOT NULL,
+    text TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_order_notes_order_id ON order_notes(order_id);
+
+CREATE TABLE IF NOT EXISTS user_points (
+    user_id TEXT PRIMARY KEY,
+    balance INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS payment_attempts (
+    id TEXT PRIMARY KEY,
+    order_id TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    attempt INTEGER NOT NULL,
+    provider TEXT NOT NULL,
+    amount_units INTEGER NOT NULL,
+    amount_nanos INTEGER NOT NULL,
+    currency_code TEXT NOT NULL,
+    status TEXT NOT NULL,
+    transaction_id TEXT NOT NULL DEFAULT '',
+    error TEXT,
+    created_at DATETIME NOT NULL,
+    finished_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_payment_attempts_idempotency_key ON payment_attempts(idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_payment_attempts_order_id ON payment_attempts(order_id);
+
+CREATE TABLE IF NOT EXISTS fraud_decisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    email TEXT NOT NULL,
+    amount_units INTEGER NOT NULL,
+    amount_nanos INTEGER NOT NULL,
+    currency_code TEXT NOT NULL,
+    country TEXT NOT NULL,
+    billing_country TEXT NOT NULL,
+    card_bin TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    reasons TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_email ON fraud_decisions(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_user_id ON fraud_decisions(user_id);
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    request BLOB NOT NULL,
+    attempts INTEGER NOT NULL,
+    next_attempt_at DATETIME NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    failed_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_next_attempt_at ON email_outbox(next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_user_id ON email_outbox(user_id);
+
+CREATE TABLE IF NOT EXISTS card_vault (
+    token TEXT PRIMARY KEY,
+    envelope BLOB NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_card_vault_expires_at ON card_vault(expires_at);
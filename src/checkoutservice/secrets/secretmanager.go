@@ -0,0 +1,50 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// secretManagerBackend reads secret versions from Google Cloud Secret
+// Manager. A reference path is the full resource name of the version, e.g.
+// projects/my-project/secrets/db-password/versions/latest. The client is only
+// created on first use, so that services that do not run on Google Cloud do
+// not need credentials.
+type secretManagerBackend struct {
+	once   sync.Once
+	client *secretmanager.Client
+	err    error
+}
+
+func (b *secretManagerBackend) fetch(ctx context.Context, name string) (string, time.Duration, error) {
+	b.once.Do(func() {
+		b.client, b.err = secretmanager.NewClient(context.Background())
+	})
+	if b.err != nil {
+		return "", 0, fmt.Errorf("failed to create SecretManager client: %w", b.err)
+	}
+	result, err := b.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", 0, err
+	}
+	return string(result.GetPayload().GetData()), 0, nil
+}
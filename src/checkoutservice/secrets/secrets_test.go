@@ -0,0 +1,191 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+// fakeBackend serves values from a map and counts fetches.
+type fakeBackend struct {
+	values  map[string]string
+	ttl     time.Duration
+	err     error
+	fetches int
+}
+
+func (b *fakeBackend) fetch(_ context.Context, path string) (string, time.Duration, error) {
+	b.fetches++
+	if b.err != nil {
+		return "", 0, b.err
+	}
+	v, ok := b.values[path]
+	if !ok {
+		return "", 0, ErrNotFound
+	}
+	return v, b.ttl, nil
+}
+
+func TestManagerCaching(t *testing.T) {
+	fake := &fakeBackend{values: map[string]string{"db": "hunter2"}, ttl: time.Minute}
+	m := newManager(logging.New("test"), 5*time.Minute, map[string]backend{"fake": fake})
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if v, err := m.Get(ctx, "fake://db"); err != nil || v != "hunter2" {
+			t.Fatalf("Get = %q, %v, want hunter2", v, err)
+		}
+	}
+	if fake.fetches != 1 {
+		t.Errorf("fetched %d times within the lease, want 1", fake.fetches)
+	}
+
+	// The backend's one-minute lease is shorter than the cache TTL.
+	now = now.Add(2 * time.Minute)
+	fake.values["db"] = "rotated"
+	if v, _ := m.Get(ctx, "fake://db"); v != "rotated" {
+		t.Errorf("Get after the lease = %q, want rotated", v)
+	}
+
+	// A failed refresh falls back to the last value for another TTL.
+	now = now.Add(2 * time.Minute)
+	fake.err = errors.New("backend down")
+	if v, err := m.Get(ctx, "fake://db"); err != nil || v != "rotated" {
+		t.Errorf("Get during an outage = %q, %v, want the cached value", v, err)
+	}
+	now = now.Add(10 * time.Minute)
+	if _, err := m.Get(ctx, "fake://db"); err == nil {
+		t.Error("Get long after the lease succeeded during an outage")
+	}
+
+	if _, err := m.Get(ctx, "nope://db"); err == nil {
+		t.Error("Get with an unknown scheme succeeded")
+	}
+	if _, err := m.Get(ctx, "db"); err == nil {
+		t.Error("Get without a scheme succeeded")
+	}
+}
+
+func TestManagerValue(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "password")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromEnv(logging.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Setenv("DB_PASSWORD", "plain")
+	if v, _ := m.Value(ctx, "DB_PASSWORD"); v != "plain" {
+		t.Errorf("Value without a reference = %q, want plain", v)
+	}
+	t.Setenv("DB_PASSWORD_SECRET", "file://"+file)
+	if v, err := m.Value(ctx, "DB_PASSWORD"); err != nil || v != "from-file" {
+		t.Errorf("Value from file = %q, %v, want from-file", v, err)
+	}
+	t.Setenv("OTHER_PASSWORD", "from-env")
+	t.Setenv("API_KEY_SECRET", "env://OTHER_PASSWORD")
+	if v, err := m.Value(ctx, "API_KEY"); err != nil || v != "from-env" {
+		t.Errorf("Value from env = %q, %v, want from-env", v, err)
+	}
+	t.Setenv("API_KEY_SECRET", "env://MISSING_VARIABLE")
+	if _, err := m.Value(ctx, "API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Value of a missing variable: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVaultBackend(t *testing.T) {
+	jwt := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwt, []byte("sa-jwt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logins, dynamicReads := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["role"] != "checkoutservice" || body["jwt"] != "sa-jwt" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			logins++
+			json.NewEncoder(w).Encode(map[string]any{
+				"auth": map[string]any{"client_token": "s.token", "lease_duration": 3600},
+			})
+		case "/v1/secret/data/checkoutservice":
+			if r.Header.Get("X-Vault-Token") != "s.token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"db_password": "from-vault"},
+					"metadata": map[string]any{"version": 3},
+				},
+			})
+		case "/v1/database/creds/orders":
+			dynamicReads++
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_duration": 60,
+				"data":           map[string]any{"username": "v-orders", "password": "dynamic"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+		}
+	}))
+	defer srv.Close()
+
+	v := &vaultBackend{addr: srv.URL, role: "checkoutservice", authPath: "kubernetes", jwtFile: jwt, client: srv.Client()}
+	ctx := context.Background()
+	if got, _, err := v.fetch(ctx, "secret/data/checkoutservice#db_password"); err != nil || got != "from-vault" {
+		t.Errorf("KV v2 fetch = %q, %v, want from-vault", got, err)
+	}
+	got, ttl, err := v.fetch(ctx, "database/creds/orders#password")
+	if err != nil || got != "dynamic" || ttl != time.Minute {
+		t.Errorf("dynamic fetch = %q, %v, %v, want dynamic with a one-minute lease", got, ttl, err)
+	}
+	if got, _, err := v.fetch(ctx, "database/creds/orders#username"); err != nil || got != "v-orders" {
+		t.Errorf("dynamic fetch = %q, %v, want v-orders", got, err)
+	}
+	if dynamicReads != 1 {
+		t.Errorf("read dynamic credentials %d times, want 1 lease for both fields", dynamicReads)
+	}
+	if logins != 1 {
+		t.Errorf("logged in %d times, want 1", logins)
+	}
+	if _, _, err := v.fetch(ctx, "secret/data/missing#x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing secret: err = %v, want ErrNotFound", err)
+	}
+	if _, _, err := v.fetch(ctx, "secret/data/checkoutservice"); err == nil {
+		t.Error("fetch without a field succeeded")
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// maxCachedStmts bounds the statements a stmtCache keeps prepared, since
+// the items of a cart are inserted with a statement per cart size on MySQL
+// and SQLite. Statements past it run unprepared.
+const maxCachedStmts = 64
+
+// stmtCache keeps the statements that save and read orders prepared, so that
+// the database parses each of them once per connection rather than on every
+// call. database/sql prepares a cached statement again on each connection it
+// is used on, including the ones opened after a reconnect; statements the
+// server drops while its connection is kept, such as after a schema change,
+// are forgotten by reset and prepared again on the next attempt. A nil
+// stmtCache runs every statement unprepared.
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[stmtKey]*cachedStmt
+}
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+// cachedStmt is a prepared statement, which is closed once it is stale and
+// no call is using it.
+type cachedStmt struct {
+	stmt  *sql.Stmt
+	users int
+	stale bool
+}
+
+func newStmtCache() *stmtCache {
+	return &stmtCache{stmts: map[stmtKey]*cachedStmt{}}
+}
+
+// on returns db, or tx if it is not nil, running its statements prepared
+// in c.
+func (c *stmtCache) on(db *sql.DB, tx *sql.Tx) dbtx {
+	if c == nil {
+		if tx != nil {
+			return tx
+		}
+		return db
+	}
+	return &preparedDB{c: c, db: db, tx: tx}
+}
+
+// acquire returns the statement of query prepared on db, preparing it if it
+// is not yet, or nil if it cannot be; it must be released once its call has
+// started.
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) *cachedStmt {
+	key := stmtKey{db, query}
+	c.mu.Lock()
+	if s := c.stmts[key]; s != nil {
+		s.users++
+		c.mu.Unlock()
+		return s
+	}
+	full := len(c.stmts) >= maxCachedStmts
+	c.mu.Unlock()
+	if full {
+		return nil
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		// the call runs unprepared and reports the error itself
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stmts[key]
+	if s != nil {
+		// prepared concurrently
+		stmt.Close()
+	} else {
+		s = &cachedStmt{stmt: stmt}
+		c.stmts[key] = s
+	}
+	s.users++
+	return s
+}
+
+func (c *stmtCache) release(s *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.users--
+	if s.stale && s.users == 0 {
+		s.stmt.Close()
+	}
+}
+
+// reset forgets every statement if err tells that the server dropped one,
+// so that they are prepared again.
+func (c *stmtCache) reset(err error) {
+	if c == nil || !isStaleStmtError(err) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, s := range c.stmts {
+		delete(c.stmts, key)
+		s.stale = true
+		if s.users == 0 {
+			s.stmt.Close()
+		}
+	}
+}
+
+// isStaleStmtError reports whether err tells that a prepared statement no
+// longer exists or no longer matches the schema it was prepared against.
+func isStaleStmtError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// invalid_sql_statement_name, or a plan that a schema change
+		// invalidated
+		return pqErr.Code == "26000" ||
+			pqErr.Code == "0A000" && strings.Contains(pqErr.Message, "cached plan must not change result type")
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_NEED_REPREPARE, ER_UNKNOWN_STMT_HANDLER
+		return mysqlErr.Number == 1615 || mysqlErr.Number == 1243
+	}
+	return false
+}
+
+// preparedDB is a database or a transaction that runs statements prepared in
+// a stmtCache.
+type preparedDB struct {
+	c  *stmtCache
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// unprepared returns the database or the transaction.
+func (p *preparedDB) unprepared() dbtx {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.db
+}
+
+// stmt returns the statement of s to run in p.
+func (p *preparedDB) stmt(ctx context.Context, s *cachedStmt) *sql.Stmt {
+	if p.tx != nil {
+		return p.tx.StmtContext(ctx, s.stmt)
+	}
+	return s.stmt
+}
+
+func (p *preparedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	s := p.c.acquire(ctx, p.db, query)
+	if s == nil {
+		return p.unprepared().ExecContext(ctx, query, args...)
+	}
+	defer p.c.release(s)
+	return p.stmt(ctx, s).ExecContext(ctx, args...)
+}
+
+func (p *preparedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	s := p.c.acquire(ctx, p.db, query)
+	if s == nil {
+		return p.unprepared().QueryContext(ctx, query, args...)
+	}
+	defer p.c.release(s)
+	return p.stmt(ctx, s).QueryContext(ctx, args...)
+}
+
+func (p *preparedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	s := p.c.acquire(ctx, p.db, query)
+	if s == nil {
+		return p.unprepared().QueryRowContext(ctx, query, args...)
+	}
+	defer p.c.release(s)
+	return p.stmt(ctx, s).QueryRowContext(ctx, args...)
+}
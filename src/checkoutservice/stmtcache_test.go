@@ -0,0 +1,192 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func TestIsStaleStmtError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "26000"}, true},
+		{fmt.Errorf("failed to query order: %w", &pq.Error{Code: "0A000", Message: "cached plan must not change result type"}), true},
+		{&pq.Error{Code: "0A000", Message: "LOCK TABLE is not supported"}, false},
+		{&pq.Error{Code: "40001"}, false},
+		{&mysql.MySQLError{Number: 1615}, true},
+		{&mysql.MySQLError{Number: 1243}, true},
+		{&mysql.MySQLError{Number: 1213}, false},
+		{sql.ErrNoRows, false},
+	} {
+		if got := isStaleStmtError(tt.err); got != tt.want {
+			t.Errorf("isStaleStmtError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestStmtCache saves and reads orders in SQLite with prepared statements,
+// and prepares them again once the server drops one.
+func TestStmtCache(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	save := func() string {
+		t.Helper()
+		orderID := uuid.NewString()
+		if err := saveBenchOrder(ctx, store, orderID); err != nil {
+			t.Fatal(err)
+		}
+		order, err := store.GetOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(order.Items) != 2 {
+			t.Errorf("order %s has %d items, want 2", orderID, len(order.Items))
+		}
+		return orderID
+	}
+	save()
+	prepared := len(store.stmts.stmts)
+	if prepared == 0 {
+		t.Fatal("no statement was prepared")
+	}
+	save()
+	if n := len(store.stmts.stmts); n != prepared {
+		t.Errorf("%d statements are prepared after the second order, want %d", n, prepared)
+	}
+
+	var stmt *cachedStmt
+	for _, s := range store.stmts.stmts {
+		stmt = s
+		break
+	}
+	store.stmts.reset(errors.New("some error"))
+	if n := len(store.stmts.stmts); n != prepared {
+		t.Errorf("%d statements are prepared after an unrelated error, want %d", n, prepared)
+	}
+
+	// a statement in use is closed once it is released
+	stmt.users++
+	store.stmts.reset(&pq.Error{Code: "26000"})
+	if n := len(store.stmts.stmts); n != 0 {
+		t.Errorf("%d statements are prepared after a stale statement error, want 0", n)
+	}
+	if _, err := stmt.stmt.ExecContext(ctx); err != nil && err.Error() == "sql: statement is closed" {
+		t.Error("statement in use was closed")
+	}
+	store.stmts.release(stmt)
+	if _, err := stmt.stmt.ExecContext(ctx); err == nil || err.Error() != "sql: statement is closed" {
+		t.Errorf("released stale statement: got %v, want it closed", err)
+	}
+
+	save()
+	if n := len(store.stmts.stmts); n != prepared {
+		t.Errorf("%d statements are prepared again, want %d", n, prepared)
+	}
+}
+
+// TestStmtCacheDisabled saves and reads an order with every statement
+// unprepared.
+func TestStmtCacheDisabled(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	store.stmts = nil
+	orderID := uuid.NewString()
+	if err := saveBenchOrder(ctx, store, orderID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetOrder(ctx, orderID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saveBenchOrder(ctx context.Context, store *OrderStore, orderID string) error {
+	return store.SaveOrder(ctx, orderRecord{Order: Order{OrderID: orderID, UserID: "bench-user", Email: "someone@example.com", City: "Mountain View", OrderTotalUnits: 10, CurrencyCode: "USD", PaymentTransactionID: "txn-1", ShippingTrackingID: "track-1", ShippingCostUnits: 7}, Items: pricedItems(orderID, []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}, {ProductId: "66VCHSJNUP", Quantity: 1}})})
+}
+
+// BenchmarkOrderStore compares saving and reading orders with prepared and
+// unprepared statements, from as many goroutines as GOMAXPROCS, in SQLite
+// and in the PostgreSQL database at TEST_DB_DSN if it is set.
+func BenchmarkOrderStore(b *testing.B) {
+	type benchStore struct {
+		name string
+		open func(b *testing.B) *OrderStore
+	}
+	stores := []benchStore{{"sqlite", func(b *testing.B) *OrderStore { return newSQLiteStore(b) }}}
+	if dsn := os.Getenv("TEST_DB_DSN"); dsn != "" {
+		stores = append(stores, benchStore{"postgres", func(b *testing.B) *OrderStore {
+			db, err := sql.Open("postgres", dsn)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(func() { db.Close() })
+			if err := migrateSchema(db); err != nil {
+				b.Fatal(err)
+			}
+			return NewOrderStore(db)
+		}})
+	}
+	for _, bs := range stores {
+		for _, prepared := range []bool{true, false} {
+			mode := "unprepared"
+			if prepared {
+				mode = "prepared"
+			}
+			b.Run(bs.name+"/"+mode, func(b *testing.B) {
+				ctx := context.Background()
+				store := bs.open(b)
+				if !prepared {
+					store.stmts = nil
+				}
+				b.Run("SaveOrder", func(b *testing.B) {
+					b.RunParallel(func(pb *testing.PB) {
+						for pb.Next() {
+							if err := saveBenchOrder(ctx, store, uuid.NewString()); err != nil {
+								b.Error(err)
+								return
+							}
+						}
+					})
+				})
+				orderID := uuid.NewString()
+				if err := saveBenchOrder(ctx, store, orderID); err != nil {
+					b.Fatal(err)
+				}
+				b.Run("GetOrder", func(b *testing.B) {
+					b.RunParallel(func(pb *testing.PB) {
+						for pb.Next() {
+							if _, err := store.GetOrder(ctx, orderID); err != nil {
+								b.Error(err)
+								return
+							}
+						}
+					})
+				})
+			})
+		}
+	}
+}
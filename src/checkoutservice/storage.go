@@ -0,0 +1,500 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+// OrderStorage keeps the orders placed through the service. OrderStore keeps
+// them in PostgreSQL, and memoryOrderStore in memory for tests and for running
+// the demo without a database.
+type OrderStorage interface {
+	// SaveOrder stores a newly placed order as paid now, or confirms the
+	// pending order SavePendingOrder stored, along with the idempotency key
+	// it was placed with unless rec.Idempotency is nil. Saving an order that
+	// was already saved changes nothing and fails with an orderExistsError.
+	SaveOrder(ctx context.Context, rec orderRecord) error
+	// SavePendingOrder stores an order that is being placed, before its
+	// card is charged, as pending.
+	SavePendingOrder(ctx context.Context, rec orderRecord) error
+	// FailOrder marks a pending order that could not be placed failed.
+	FailOrder(ctx context.Context, orderID string) error
+	// GetOrder returns an order and its items, or an error wrapping
+	// errOrderNotFound.
+	GetOrder(ctx context.Context, orderID string) (*Order, error)
+	// GetUserOrders returns the orders of a user selected by q.
+	GetUserOrders(ctx context.Context, userID string, q OrderQuery) ([]Order, error)
+	// SearchOrders returns the orders of any user selected by s.
+	SearchOrders(ctx context.Context, s OrderSearch) ([]Order, error)
+	// UpdateOrderStatus changes the status of an order, failing with
+	// errInvalidStatusTransition unless its current status can change to
+	// the new one, and returns the updated order.
+	UpdateOrderStatus(ctx context.Context, orderID string, status OrderStatus) (*Order, error)
+	// GetIdempotencyRecord returns the idempotency key that userID placed
+	// an order with, or nil if the user has not used key in the last
+	// idempotencyTTL.
+	GetIdempotencyRecord(ctx context.Context, userID, key string) (*IdempotencyRecord, error)
+	// RecordCompensation logs a step of an order that was undone.
+	RecordCompensation(ctx context.Context, c Compensation) error
+	// AddOrderNote adds a note to its order, failing with errOrderNotFound
+	// if there is no such order, and returns every note of the order,
+	// oldest first.
+	AddOrderNote(ctx context.Context, n OrderNote) ([]OrderNote, error)
+	// ClaimOrderFingerprint claims the fingerprint of an order for
+	// orderID, unless another order claimed it less than window ago, and
+	// returns the ID of that order then; see duplicates.go.
+	ClaimOrderFingerprint(ctx context.Context, fingerprint, userID, orderID string, window time.Duration) (string, error)
+	// ReleaseOrderFingerprint releases the fingerprint orderID claimed.
+	ReleaseOrderFingerprint(ctx context.Context, fingerprint, orderID string) error
+	// DeleteUserData erases the personal data of a user's orders and
+	// returns how many rows it affected; see erasure.go.
+	DeleteUserData(ctx context.Context, userID string) (UserErasure, error)
+	// GetPointsBalance returns the loyalty points a user holds.
+	GetPointsBalance(ctx context.Context, userID string) (int64, error)
+	// RedeemPoints redeems loyalty points of a user outside of an order,
+	// failing with errInsufficientPoints if the user holds fewer, and
+	// returns the balance left; see loyalty.go.
+	RedeemPoints(ctx context.Context, userID string, points int64) (int64, error)
+	// StartPaymentAttempt records an attempt to charge the card of an
+	// order as started, and FinishPaymentAttempt records its result; see
+	// paymentattempts.go.
+	StartPaymentAttempt(ctx context.Context, a PaymentAttempt) error
+	FinishPaymentAttempt(ctx context.Context, a PaymentAttempt) error
+	// GetPaymentAttempts returns the payment attempts with an idempotency
+	// key, earliest first.
+	GetPaymentAttempts(ctx context.Context, key string) ([]PaymentAttempt, error)
+	// RecordFraudDecision records the decision of the fraud check on an
+	// order; see fraud.go.
+	RecordFraudDecision(ctx context.Context, c FraudCheck, d FraudDecision) error
+	fraudHistory
+	// QueueEmail queues an order confirmation to be sent again;
+	// ClaimDueEmails leases up to limit of those due at now for lease, and
+	// RecordEmailAttempt records an attempt to send one; see
+	// emailoutbox.go.
+	QueueEmail(ctx context.Context, e QueuedEmail) error
+	ClaimDueEmails(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]QueuedEmail, error)
+	RecordEmailAttempt(ctx context.Context, e QueuedEmail, sent bool) error
+}
+
+const (
+	orderStorePostgres = "postgres"
+	orderStoreMemory   = "memory"
+)
+
+// memoryOrderStore keeps orders in memory, so they are lost on restart and
+// not shared between replicas. It keeps no card data.
+type memoryOrderStore struct {
+	mu     sync.Mutex
+	orders map[string]orderRecord
+	// idempotency is keyed by user ID, then by idempotency key.
+	idempotency   map[string]map[string]memoryIdempotencyRecord
+	compensations []Compensation
+	fingerprints  map[string]memoryFingerprint
+	// notes are keyed by order ID.
+	notes map[string][]OrderNote
+	// points are the loyalty points held, keyed by user ID.
+	points map[string]int64
+	// payments are the payment attempts, keyed by idempotency key.
+	payments map[string][]PaymentAttempt
+	fraud    []memoryFraudDecision
+	// emails are the queued order confirmations, keyed by ID.
+	emails      map[int64]QueuedEmail
+	lastEmailID int64
+}
+
+type memoryFingerprint struct {
+	userID, orderID string
+	createdAt       time.Time
+}
+
+type memoryFraudDecision struct {
+	FraudCheck
+	FraudDecision
+	createdAt time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	IdempotencyRecord
+	createdAt time.Time
+}
+
+func newMemoryOrderStore() *memoryOrderStore {
+	return &memoryOrderStore{
+		orders:       make(map[string]orderRecord),
+		idempotency:  make(map[string]map[string]memoryIdempotencyRecord),
+		fingerprints: make(map[string]memoryFingerprint),
+		notes:        make(map[string][]OrderNote),
+		points:       make(map[string]int64),
+		payments:     make(map[string][]PaymentAttempt),
+		emails:       make(map[int64]QueuedEmail),
+	}
+}
+
+func (s *memoryOrderStore) SaveOrder(ctx context.Context, rec orderRecord) error {
+	rec = rec.stamped(StatusPaid)
+	rec.Items = slices.Clone(rec.Items)
+	o, idem := rec.Order, rec.Idempotency
+	orderID, userID, pointsEarned, pointsRedeemed := o.OrderID, o.UserID, o.PointsEarned, o.PointsRedeemed
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.orders[orderID]; ok && prev.Order.Status != StatusPending {
+		return &orderExistsError{OrderID: orderID, Redelivered: prev.Order.redelivers(userID, o.PaymentTransactionID)}
+	}
+	if s.points[userID] < pointsRedeemed {
+		return fmt.Errorf("%w: order %s redeems %d", errInsufficientPoints, orderID, pointsRedeemed)
+	}
+	if pointsEarned != 0 || pointsRedeemed != 0 {
+		s.points[userID] += pointsEarned - pointsRedeemed
+	}
+	for i := range rec.Items {
+		rec.Items[i].ID = i + 1
+	}
+	s.orders[orderID] = rec
+	if idem != nil {
+		keys := s.idempotency[userID]
+		if keys == nil {
+			keys = make(map[string]memoryIdempotencyRecord)
+			s.idempotency[userID] = keys
+		}
+		// an expired key is reused rather than kept forever
+		if prev, ok := keys[idem.Key]; ok && prev.createdAt.After(rec.Order.CreatedAt.Add(-idempotencyTTL)) {
+			log.WithContext(ctx).Warnf("order %s was placed with idempotency key %q, which another order placed concurrently already used", orderID, idem.Key)
+		} else {
+			keys[idem.Key] = memoryIdempotencyRecord{IdempotencyRecord: *idem, createdAt: rec.Order.CreatedAt}
+		}
+	}
+	log.WithContext(ctx).Info("order kept in memory")
+	return nil
+}
+
+func (s *memoryOrderStore) SavePendingOrder(ctx context.Context, rec orderRecord) error {
+	rec = rec.stamped(StatusPending)
+	rec.Items = slices.Clone(rec.Items)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[rec.Order.OrderID]; ok {
+		return nil
+	}
+	for i := range rec.Items {
+		rec.Items[i].ID = i + 1
+	}
+	s.orders[rec.Order.OrderID] = rec
+	return nil
+}
+
+func (s *memoryOrderStore) FailOrder(ctx context.Context, orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.orders[orderID]; ok && rec.Order.Status == StatusPending {
+		rec.Order.Status = StatusFailed
+		s.orders[orderID] = rec
+	}
+	return nil
+}
+
+func (s *memoryOrderStore) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errOrderNotFound, orderID)
+	}
+	return rec.order(), nil
+}
+
+func (s *memoryOrderStore) GetUserOrders(ctx context.Context, userID string, q OrderQuery) ([]Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var orders []Order
+	for _, rec := range s.orders {
+		if rec.Order.UserID == userID && q.matches(rec.Order) {
+			orders = append(orders, *rec.order())
+		}
+	}
+	slices.SortFunc(orders, func(a, b Order) int { return -compareOrders(a, b) })
+	if q.Limit > 0 && len(orders) > q.Limit {
+		orders = orders[:q.Limit]
+	}
+	return orders, nil
+}
+
+func (s *memoryOrderStore) SearchOrders(ctx context.Context, search OrderSearch) ([]Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var orders []Order
+	for _, rec := range s.orders {
+		if search.matches(rec.Order) {
+			orders = append(orders, *rec.order())
+		}
+	}
+	slices.SortFunc(orders, func(a, b Order) int { return -compareOrders(a, b) })
+	if search.Limit > 0 && len(orders) > search.Limit {
+		orders = orders[:search.Limit]
+	}
+	return orders, nil
+}
+
+func (s *memoryOrderStore) UpdateOrderStatus(ctx context.Context, orderID string, status OrderStatus) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errOrderNotFound, orderID)
+	}
+	if err := rec.Order.Status.checkTransition(status); err != nil {
+		return nil, err
+	}
+	if o := rec.Order; status == StatusCancelled && o.Status != status && (o.PointsEarned != 0 || o.PointsRedeemed != 0) {
+		s.points[o.UserID] = max(0, s.points[o.UserID]+o.PointsRedeemed-o.PointsEarned)
+	}
+	rec.Order.Status = status
+	s.orders[orderID] = rec
+	log.WithContext(ctx).WithFields(logging.Fields{logging.OrderIDKey: orderID, "status": status}).Info("order status changed")
+	return rec.order(), nil
+}
+
+func (s *memoryOrderStore) GetIdempotencyRecord(ctx context.Context, userID, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.idempotency[userID][key]
+	if !ok || rec.createdAt.Before(time.Now().Add(-idempotencyTTL)) {
+		return nil, nil
+	}
+	return &rec.IdempotencyRecord, nil
+}
+
+func (s *memoryOrderStore) RecordCompensation(ctx context.Context, c Compensation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compensations = append(s.compensations, c)
+	return nil
+}
+
+func (s *memoryOrderStore) AddOrderNote(ctx context.Context, n OrderNote) ([]OrderNote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[n.OrderID]; !ok {
+		return nil, fmt.Errorf("%w: %s", errOrderNotFound, n.OrderID)
+	}
+	n.CreatedAt = time.Now().UTC()
+	s.notes[n.OrderID] = append(s.notes[n.OrderID], n)
+	return slices.Clone(s.notes[n.OrderID]), nil
+}
+
+func (s *memoryOrderStore) ClaimOrderFingerprint(ctx context.Context, fingerprint, userID, orderID string, window time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for fp, f := range s.fingerprints {
+		if f.createdAt.Before(now.Add(-window)) {
+			delete(s.fingerprints, fp)
+		}
+	}
+	if f, ok := s.fingerprints[fingerprint]; ok {
+		return f.orderID, nil
+	}
+	s.fingerprints[fingerprint] = memoryFingerprint{userID: userID, orderID: orderID, createdAt: now}
+	return "", nil
+}
+
+func (s *memoryOrderStore) ReleaseOrderFingerprint(ctx context.Context, fingerprint, orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fingerprints[fingerprint].orderID == orderID {
+		delete(s.fingerprints, fingerprint)
+	}
+	return nil
+}
+
+func (s *memoryOrderStore) DeleteUserData(ctx context.Context, userID string) (UserErasure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var e UserErasure
+	for id, rec := range s.orders {
+		if rec.Order.UserID != userID {
+			continue
+		}
+		e.OrdersAnonymized++
+		e.OrderItemsDeleted += int64(len(rec.Items))
+		rec.Order.anonymize()
+		rec.Items = nil
+		s.orders[id] = rec
+		delete(s.notes, id)
+	}
+	e.IdempotencyKeysDeleted = int64(len(s.idempotency[userID]))
+	delete(s.idempotency, userID)
+	for fp, f := range s.fingerprints {
+		if f.userID == userID {
+			delete(s.fingerprints, fp)
+		}
+	}
+	s.fraud = slices.DeleteFunc(s.fraud, func(d memoryFraudDecision) bool { return d.UserID == userID })
+	for id, q := range s.emails {
+		if q.UserID == userID {
+			delete(s.emails, id)
+		}
+	}
+	for i := range s.compensations {
+		if s.compensations[i].UserID == userID {
+			s.compensations[i].UserID = ""
+		}
+	}
+	return e, nil
+}
+
+func (s *memoryOrderStore) GetPointsBalance(ctx context.Context, userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.points[userID], nil
+}
+
+func (s *memoryOrderStore) RedeemPoints(ctx context.Context, userID string, points int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.points[userID] < points {
+		return 0, fmt.Errorf("%w: user %s redeems %d", errInsufficientPoints, userID, points)
+	}
+	s.points[userID] -= points
+	return s.points[userID], nil
+}
+
+// order returns a copy of the order of rec, with its items.
+func (rec orderRecord) order() *Order {
+	o := rec.Order
+	o.Items = slices.Clone(rec.Items)
+	return &o
+}
+
+// matches reports whether q selects o, ignoring its limit.
+func (q OrderQuery) matches(o Order) bool {
+	if !q.CreatedAfter.IsZero() && o.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !o.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, o.Status) {
+		return false
+	}
+	if len(q.Statuses) == 0 && slices.Contains(attemptStatuses, o.Status) {
+		return false
+	}
+	if q.After != nil && compareOrders(o, Order{CreatedAt: q.After.CreatedAt, OrderID: q.After.OrderID}) >= 0 {
+		return false
+	}
+	return true
+}
+
+// compareOrders orders orders by creation time, then by ID, like OrderQuery
+// does.
+func compareOrders(a, b Order) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.OrderID, b.OrderID)
+}
+
+func (s *memoryOrderStore) StartPaymentAttempt(ctx context.Context, a PaymentAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payments[a.IdempotencyKey] = append(s.payments[a.IdempotencyKey], a)
+	return nil
+}
+
+func (s *memoryOrderStore) FinishPaymentAttempt(ctx context.Context, a PaymentAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.payments[a.IdempotencyKey]
+	for i := range attempts {
+		if attempts[i].ID == a.ID {
+			attempts[i] = a
+		}
+	}
+	return nil
+}
+
+func (s *memoryOrderStore) GetPaymentAttempts(ctx context.Context, key string) ([]PaymentAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.payments[key]), nil
+}
+
+func (s *memoryOrderStore) RecordFraudDecision(ctx context.Context, c FraudCheck, d FraudDecision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fraud = append(s.fraud, memoryFraudDecision{FraudCheck: c, FraudDecision: d, createdAt: time.Now()})
+	return nil
+}
+
+func (s *memoryOrderStore) CountFraudDecisions(ctx context.Context, email string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, d := range s.fraud {
+		if d.Email == email && !d.createdAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryOrderStore) QueueEmail(ctx context.Context, e QueuedEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastEmailID++
+	e.ID = s.lastEmailID
+	s.emails[e.ID] = e
+	return nil
+}
+
+func (s *memoryOrderStore) ClaimDueEmails(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]QueuedEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []QueuedEmail
+	for _, e := range s.emails {
+		if e.FailedAt.IsZero() && !e.NextAttemptAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	slices.SortFunc(due, func(a, b QueuedEmail) int { return a.NextAttemptAt.Compare(b.NextAttemptAt) })
+	due = due[:min(len(due), limit)]
+	for _, e := range due {
+		e.NextAttemptAt = now.Add(lease)
+		s.emails[e.ID] = e
+	}
+	return due, nil
+}
+
+func (s *memoryOrderStore) RecordEmailAttempt(ctx context.Context, e QueuedEmail, sent bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.emails[e.ID]; !ok {
+		return nil
+	}
+	if sent {
+		delete(s.emails, e.ID)
+	} else {
+		s.emails[e.ID] = e
+	}
+	return nil
+}
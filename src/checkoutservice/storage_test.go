@@ -0,0 +1,180 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+func saveMemoryOrder(t *testing.T, s *memoryOrderStore, orderID, userID string, idem *IdempotencyRecord) {
+	t.Helper()
+	err := s.SaveOrder(context.Background(), orderRecord{Order: Order{
+		OrderID:              orderID,
+		UserID:               userID,
+		Email:                "someone@example.com",
+		StreetAddress:        "1600 Amphitheatre Parkway",
+		ZipCode:              "94043",
+		CardToken:            "tok_" + orderID,
+		CardLast4:            "0454",
+		CardBrand:            "visa",
+		OrderTotalUnits:      67,
+		OrderTotalNanos:      960000000,
+		CurrencyCode:         "USD",
+		ShippingTrackingID:   "track-" + orderID,
+		PaymentTransactionID: "txn-" + orderID,
+		ShippingCostUnits:    8,
+		ShippingCostNanos:    990000000,
+	}, Items: []OrderItem{
+		{OrderID: orderID, ProductID: "OLJCESPC7Z", Quantity: 2, UnitPriceUnits: 19, UnitPriceNanos: 990000000, CurrencyCode: "USD"},
+		{OrderID: orderID, ProductID: "66VCHSJNUP", Quantity: 1, UnitPriceUnits: 18, UnitPriceNanos: 990000000, CurrencyCode: "USD"},
+	}, Idempotency: idem})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pricedItems returns the rows of items of an order priced at 1 USD each.
+func pricedItems(orderID string, items []*pb.CartItem) []OrderItem {
+	priced := make([]OrderItem, len(items))
+	for i, item := range items {
+		priced[i] = OrderItem{OrderID: orderID, ProductID: item.GetProductId(), Quantity: item.GetQuantity(), UnitPriceUnits: 1, CurrencyCode: "USD"}
+	}
+	return priced
+}
+
+func TestMemoryOrderStore(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryOrderStore()
+	saveMemoryOrder(t, s, "order-1", "user-1", nil)
+	var exists *orderExistsError
+	if err := s.SaveOrder(ctx, orderRecord{Order: Order{OrderID: "order-1", UserID: "user-1"}}); !errors.As(err, &exists) || exists.Redelivered {
+		t.Errorf("saving another order with a saved ID: got %v, want an orderExistsError that is not redelivered", err)
+	}
+	if err := s.SaveOrder(ctx, orderRecord{Order: Order{OrderID: "order-1", UserID: "user-1", PaymentTransactionID: "txn-order-1"}}); !errors.As(err, &exists) || !exists.Redelivered {
+		t.Errorf("saving an order twice: got %v, want a redelivered orderExistsError", err)
+	}
+
+	o, err := s.GetOrder(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.totalPaid().GetUnits() != 67 || o.totalPaid().GetNanos() != 960000000 || o.Status != StatusPaid || len(o.Items) != 2 {
+		t.Errorf("GetOrder = %+v, want a paid order of 67.96 USD with 2 items", o)
+	}
+	if o.ShippingCostUnits != 8 || o.Items[0].UnitPriceUnits != 19 || o.Items[0].UnitPriceNanos != 990000000 || o.Items[0].CurrencyCode != "USD" {
+		t.Errorf("GetOrder = %+v, want the shipping cost and item prices", o)
+	}
+	if o.CardToken != "tok_order-1" || o.maskedCardNumber() != "****-****-****-0454" {
+		t.Errorf("GetOrder = card %q, %q; want its token and masked number", o.CardToken, o.maskedCardNumber())
+	}
+	// orders are copied in and out of the store
+	o.Items[0].Quantity = 10
+	if o, _ := s.GetOrder(ctx, "order-1"); o.Items[0].Quantity != 2 {
+		t.Errorf("changing a returned order changed the stored one")
+	}
+	if _, err := s.GetOrder(ctx, "order-2"); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("GetOrder(unknown order) = %v, want errOrderNotFound", err)
+	}
+
+	if o, err := s.UpdateOrderStatus(ctx, "order-1", StatusShipped); err != nil || o.Status != StatusShipped {
+		t.Errorf("UpdateOrderStatus(shipped) = %v, %v; want a shipped order", o, err)
+	}
+	if _, err := s.UpdateOrderStatus(ctx, "order-1", StatusPaid); !errors.Is(err, errInvalidStatusTransition) {
+		t.Errorf("UpdateOrderStatus(paid) of a shipped order = %v, want errInvalidStatusTransition", err)
+	}
+	if _, err := s.UpdateOrderStatus(ctx, "order-2", StatusShipped); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("UpdateOrderStatus(unknown order) = %v, want errOrderNotFound", err)
+	}
+}
+
+func TestMemoryOrderStoreUserOrders(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryOrderStore()
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// order-b and order-c are placed at the same time
+	for id, at := range map[string]time.Duration{"order-a": 0, "order-b": 2 * time.Hour, "order-c": 2 * time.Hour, "order-d": 3 * time.Hour} {
+		saveMemoryOrder(t, s, id, "user-1", nil)
+		rec := s.orders[id]
+		rec.Order.CreatedAt = created.Add(at)
+		s.orders[id] = rec
+	}
+	if _, err := s.UpdateOrderStatus(ctx, "order-d", StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	saveMemoryOrder(t, s, "order-e", "user-2", nil)
+
+	ids := func(orders []Order) []string {
+		var ids []string
+		for _, o := range orders {
+			ids = append(ids, o.OrderID)
+		}
+		return ids
+	}
+	for _, tt := range []struct {
+		name string
+		q    OrderQuery
+		want []string
+	}{
+		{"all", OrderQuery{}, []string{"order-d", "order-c", "order-b", "order-a"}},
+		{"first page", OrderQuery{Limit: 2}, []string{"order-d", "order-c"}},
+		{"next page", OrderQuery{Limit: 2, After: &OrderCursor{CreatedAt: created.Add(2 * time.Hour), OrderID: "order-c"}}, []string{"order-b", "order-a"}},
+		{"created after", OrderQuery{CreatedAfter: created.Add(2 * time.Hour)}, []string{"order-d", "order-c", "order-b"}},
+		{"created before", OrderQuery{CreatedBefore: created.Add(2 * time.Hour)}, []string{"order-a"}},
+		{"statuses", OrderQuery{Statuses: []OrderStatus{StatusCancelled}}, []string{"order-d"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := s.GetUserOrders(ctx, "user-1", tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(orders); !slices.Equal(got, tt.want) {
+				t.Errorf("GetUserOrders = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryOrderStoreIdempotency(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryOrderStore()
+	saveMemoryOrder(t, s, "order-1", "user-1", &IdempotencyRecord{Key: "key-1", Fingerprint: []byte("f1"), Response: []byte("r1")})
+	// a concurrent order with the same key keeps the first response
+	saveMemoryOrder(t, s, "order-2", "user-1", &IdempotencyRecord{Key: "key-1", Fingerprint: []byte("f2"), Response: []byte("r2")})
+
+	rec, err := s.GetIdempotencyRecord(ctx, "user-1", "key-1")
+	if err != nil || rec == nil || string(rec.Response) != "r1" {
+		t.Errorf("GetIdempotencyRecord = %+v, %v; want the response of order-1", rec, err)
+	}
+	if rec, err := s.GetIdempotencyRecord(ctx, "user-2", "key-1"); rec != nil || err != nil {
+		t.Errorf("GetIdempotencyRecord(other user) = %+v, %v; want nil", rec, err)
+	}
+
+	expired := s.idempotency["user-1"]["key-1"]
+	expired.createdAt = time.Now().Add(-idempotencyTTL - time.Minute)
+	s.idempotency["user-1"]["key-1"] = expired
+	if rec, err := s.GetIdempotencyRecord(ctx, "user-1", "key-1"); rec != nil || err != nil {
+		t.Errorf("GetIdempotencyRecord(expired key) = %+v, %v; want nil", rec, err)
+	}
+	saveMemoryOrder(t, s, "order-3", "user-1", &IdempotencyRecord{Key: "key-1", Fingerprint: []byte("f3"), Response: []byte("r3")})
+	if rec, _ := s.GetIdempotencyRecord(ctx, "user-1", "key-1"); rec == nil || string(rec.Response) != "r3" {
+		t.Errorf("GetIdempotencyRecord after reusing an expired key = %+v, want the response of order-3", rec)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// With PAYMENT_PROVIDER=stripe, cards are charged with the Stripe API, as
+// PaymentIntents confirmed at once, using the test mode key STRIPE_SECRET_KEY
+// (or one read from STRIPE_SECRET_KEY_SECRET); live keys are refused. The
+// cards of the demo are not real, and Stripe only takes card numbers from its
+// own clients, so each is charged as the Stripe test card of its brand,
+// pm_card_visa or pm_card_mastercard, except for Stripe's test numbers that
+// decline, which decline here too. STRIPE_API_URL points the adapter at
+// another server implementing the API, such as stripe-mock.
+//
+// Both calls send an Idempotency-Key, that of the charge for charges and the
+// transaction ID for refunds, so that Stripe charges an order once however
+// often it is retried.
+
+const (
+	defaultStripeAPIURL = "https://api.stripe.com"
+	stripeTimeout       = 30 * time.Second
+)
+
+// stripeTestCards are the Stripe test payment methods of the Stripe test card
+// numbers that fail.
+var stripeTestCards = map[string]string{
+	"4000000000000002": "pm_card_visa_chargeDeclined",
+	"4000000000009995": "pm_card_visa_chargeDeclinedInsufficientFunds",
+	"4000000000000069": "pm_card_visa_chargeDeclinedExpiredCard",
+}
+
+// stripeZeroDecimal are the currencies Stripe takes amounts of in whole
+// units; amounts of the others are in cents.
+var stripeZeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// stripeProvider charges cards with the Stripe API.
+type stripeProvider struct {
+	apiURL string
+	key    func(context.Context) (string, error)
+	client *http.Client
+	now    func() time.Time
+	cards  cardvault.Vault
+}
+
+func newStripeProvider(secretStore *secrets.Manager, apiURL string, cards cardvault.Vault) (*stripeProvider, error) {
+	if apiURL == "" {
+		apiURL = defaultStripeAPIURL
+	}
+	if u, err := url.Parse(apiURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid STRIPE_API_URL %q", apiURL)
+	}
+	p := &stripeProvider{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		key: func(ctx context.Context) (string, error) {
+			key, err := secretStore.Value(ctx, "STRIPE_SECRET_KEY")
+			if err != nil {
+				return "", fmt.Errorf("failed to read STRIPE_SECRET_KEY: %w", err)
+			}
+			if !strings.HasPrefix(key, "sk_test_") && !strings.HasPrefix(key, "rk_test_") {
+				return "", errors.New("STRIPE_SECRET_KEY must be a test mode key")
+			}
+			return key, nil
+		},
+		client: &http.Client{Timeout: stripeTimeout},
+		now:    time.Now,
+		cards:  cards,
+	}
+	if _, err := p.key(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *stripeProvider) String() string { return "Stripe at " + p.apiURL }
+
+func (p *stripeProvider) Charge(ctx context.Context, orderID, idempotencyKey string, amount *pb.Money, card cardvault.Card) (string, error) {
+	details, err := p.cards.Detokenize(ctx, card.Token)
+	if err != nil {
+		return "", err
+	}
+	method, err := p.paymentMethod(details)
+	if err != nil {
+		return "", err
+	}
+	currency, value, err := stripeAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	var intent struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	err = p.post(ctx, "/v1/payment_intents", idempotencyKey, url.Values{
+		"amount":                             {value},
+		"currency":                           {currency},
+		"payment_method":                     {method},
+		"confirm":                            {"true"},
+		"automatic_payment_methods[enabled]": {"true"},
+		"automatic_payment_methods[allow_redirects]": {"never"},
+		"description":        {"Online Boutique order " + orderID},
+		"metadata[order_id]": {orderID},
+	}, &intent)
+	if err != nil {
+		return "", err
+	}
+	if intent.Status != "succeeded" {
+		return "", fmt.Errorf("stripe payment intent %s is %s, not succeeded", intent.ID, intent.Status)
+	}
+	return intent.ID, nil
+}
+
+func (p *stripeProvider) Refund(ctx context.Context, transactionID string, amount *pb.Money) error {
+	_, value, err := stripeAmount(amount)
+	if err != nil {
+		return err
+	}
+	err = p.post(ctx, "/v1/refunds", "refund-"+transactionID, url.Values{
+		"payment_intent": {transactionID},
+		"amount":         {value},
+	}, nil)
+	if serr := (*stripeError)(nil); errors.As(err, &serr) && serr.Code == "charge_already_refunded" {
+		return nil
+	}
+	return err
+}
+
+// paymentMethod returns the Stripe test payment method card is charged as.
+func (p *stripeProvider) paymentMethod(card *pb.CreditCardInfo) (string, error) {
+	number := strings.ReplaceAll(card.GetCreditCardNumber(), "-", "")
+	now := p.now()
+	if now.Year()*12+int(now.Month()) > int(card.GetCreditCardExpirationYear())*12+int(card.GetCreditCardExpirationMonth()) {
+		return "", fmt.Errorf("the card expired on %d/%d", card.GetCreditCardExpirationMonth(), card.GetCreditCardExpirationYear())
+	}
+	if method, ok := stripeTestCards[number]; ok {
+		return method, nil
+	}
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "pm_card_visa", nil
+	case strings.HasPrefix(number, "5") || strings.HasPrefix(number, "2"):
+		return "pm_card_mastercard", nil
+	default:
+		return "", errors.New("only VISA or MasterCard is accepted")
+	}
+}
+
+// stripeAmount returns the currency and amount of m as Stripe takes them, in
+// the smallest unit of the currency, rounded to the nearest.
+func stripeAmount(m *pb.Money) (currency, amount string, err error) {
+	if m.GetUnits() < 0 || m.GetNanos() < 0 {
+		return "", "", fmt.Errorf("cannot charge a negative amount %v", m)
+	}
+	code := strings.ToUpper(m.GetCurrencyCode())
+	perUnit, nanosPerMinor := int64(100), int64(10000000)
+	if stripeZeroDecimal[code] {
+		perUnit, nanosPerMinor = 1, 1000000000
+	}
+	minor := m.GetUnits()*perUnit + (int64(m.GetNanos())+nanosPerMinor/2)/nanosPerMinor
+	return strings.ToLower(code), strconv.FormatInt(minor, 10), nil
+}
+
+// stripeError is an error returned by the Stripe API.
+type stripeError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Decline string `json:"decline_code"`
+	Message string `json:"message"`
+}
+
+func (e *stripeError) Error() string {
+	msg := fmt.Sprintf("stripe: %s (%s", e.Message, e.Type)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Decline != "" {
+		msg += " " + e.Decline
+	}
+	return msg + ")"
+}
+
+// post POSTs form to path with idempotencyKey, and decodes the response into
+// out unless it is nil. Its error wraps errPaymentOutcomeUnknown if the
+// request may have taken effect without a response.
+func (p *stripeProvider) post(ctx context.Context, path, idempotencyKey string, form url.Values, out any) error {
+	key, err := p.key(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: stripe: %v", errPaymentOutcomeUnknown, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%w: stripe: failed to read the response: %v", errPaymentOutcomeUnknown, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Error stripeError `json:"error"`
+		}
+		err := error(&failure.Error)
+		if jerr := json.Unmarshal(body, &failure); jerr != nil || failure.Error.Message == "" {
+			err = fmt.Errorf("stripe returned %s", resp.Status)
+		}
+		// Stripe may have processed a request that failed on its side
+		if resp.StatusCode/100 == 5 {
+			return fmt.Errorf("%w: %w", errPaymentOutcomeUnknown, err)
+		}
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("stripe: failed to decode the response: %w", err)
+	}
+	return nil
+}
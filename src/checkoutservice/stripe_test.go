@@ -0,0 +1,209 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/cardvault"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/genproto"
+)
+
+// fakeStripe serves the parts of the Stripe API the adapter calls.
+type fakeStripe struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	forms    []map[string]string
+	refunded map[string]bool
+}
+
+func newFakeStripe(t *testing.T) (*fakeStripe, *httptest.Server) {
+	f := &fakeStripe{refunded: make(map[string]bool)}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeStripe) serve(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	form := make(map[string]string)
+	for k := range r.PostForm {
+		form[k] = r.PostForm.Get(k)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+	f.forms = append(f.forms, form)
+	fail := func(code string) {
+		w.WriteHeader(http.StatusPaymentRequired)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{
+			"type": "card_error", "code": code, "message": "Your card was declined.",
+		}})
+	}
+	switch r.URL.Path {
+	case "/v1/payment_intents":
+		if strings.Contains(form["payment_method"], "Declined") {
+			fail("card_declined")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": "pi_" + form["metadata[order_id]"], "status": "succeeded"})
+	case "/v1/refunds":
+		if f.refunded[form["payment_intent"]] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{
+				"type": "invalid_request_error", "code": "charge_already_refunded", "message": "already refunded",
+			}})
+			return
+		}
+		f.refunded[form["payment_intent"]] = true
+		json.NewEncoder(w).Encode(map[string]string{"id": "re_1", "status": "succeeded"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestStripeProvider(t *testing.T) {
+	ctx := context.Background()
+	f, srv := newFakeStripe(t)
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_123")
+	vault := cardvault.NewMemory(time.Minute)
+	p, err := newStripeProvider(nil, srv.URL, vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenize := func(card *pb.CreditCardInfo) cardvault.Card {
+		c, err := vault.Tokenize(ctx, card)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	amount := &pb.Money{CurrencyCode: "USD", Units: 28, Nanos: 980000000}
+	txID, err := p.Charge(ctx, "order-1", "charge-order-1", amount, tokenize(contract.ValidCard()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txID != "pi_order-1" {
+		t.Errorf("Charge() = %s, want the payment intent ID", txID)
+	}
+	req, form := f.requests[0], f.forms[0]
+	if got := req.Header.Get("Authorization"); got != "Bearer sk_test_123" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := req.Header.Get("Idempotency-Key"); got != "charge-order-1" {
+		t.Errorf("Idempotency-Key = %q, want charge-order-1", got)
+	}
+	if form["amount"] != "2898" || form["currency"] != "usd" || form["payment_method"] != "pm_card_visa" || form["confirm"] != "true" {
+		t.Errorf("charge form = %v, want 2898 usd confirmed with pm_card_visa", form)
+	}
+
+	declined := contract.ValidCard()
+	declined.CreditCardNumber = "4000-0000-0000-0002"
+	if _, err := p.Charge(ctx, "order-2", "charge-order-2", amount, tokenize(declined)); err == nil || !strings.Contains(err.Error(), "card_declined") {
+		t.Errorf("Charge() with a declining test card = %v, want card_declined", err)
+	}
+	amex := contract.ValidCard()
+	amex.CreditCardNumber = "3782-822463-10005"
+	if _, err := p.Charge(ctx, "order-3", "charge-order-3", amount, tokenize(amex)); err == nil {
+		t.Error("Charge() with an AMEX card succeeded")
+	}
+
+	for range 2 {
+		if err := p.Refund(ctx, txID, amount); err != nil {
+			t.Errorf("Refund(%s): %v", txID, err)
+		}
+	}
+	if got := f.requests[len(f.requests)-1].Header.Get("Idempotency-Key"); got != "refund-"+txID {
+		t.Errorf("refund Idempotency-Key = %q", got)
+	}
+}
+
+func TestStripeProviderRefusesLiveKeys(t *testing.T) {
+	for _, key := range []string{"", "sk_live_123"} {
+		t.Setenv("STRIPE_SECRET_KEY", key)
+		if _, err := newStripeProvider(nil, "", nil); err == nil {
+			t.Errorf("newStripeProvider() with key %q succeeded", key)
+		}
+	}
+}
+
+func TestStripeAmount(t *testing.T) {
+	for _, tc := range []struct {
+		m              *pb.Money
+		currency, want string
+	}{
+		{&pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000}, "usd", "1999"},
+		{&pb.Money{CurrencyCode: "EUR", Units: 0, Nanos: 5000000}, "eur", "1"},
+		{&pb.Money{CurrencyCode: "JPY", Units: 2199, Nanos: 600000000}, "jpy", "2200"},
+	} {
+		currency, amount, err := stripeAmount(tc.m)
+		if err != nil || currency != tc.currency || amount != tc.want {
+			t.Errorf("stripeAmount(%v) = %s, %s, %v; want %s %s", tc.m, currency, amount, err, tc.want, tc.currency)
+		}
+	}
+}
+
+func TestPlaceOrderWithStripe(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newFakeStripe(t)
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_123")
+	cs, backends := newFakeCheckoutService(t)
+	p, err := newStripeProvider(nil, srv.URL, cs.vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	cs.payments = p
+	if _, err := backends.cart.AddItem(ctx, &pb.AddItemRequest{UserId: "user-1", Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	req := placeOrderRequest("key-1")
+	req.PaymentMethod.GetCreditCard().CreditCardExpirationYear = 2030
+	resp, err := newCheckoutServiceV2(cs).PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(backends.payment.Charges()); got != 0 {
+		t.Errorf("the payment service charged %d cards, want none", got)
+	}
+	if resp.GetOrder().GetOrderId() == "" {
+		t.Error("PlaceOrder() returned no order")
+	}
+}
+
+func TestPaymentProviderFromEnv(t *testing.T) {
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_123")
+	for v, want := range map[string]string{"": "*main.paymentServiceProvider", "paymentservice": "*main.paymentServiceProvider", "stripe": "*main.stripeProvider"} {
+		t.Setenv("PAYMENT_PROVIDER", v)
+		p, err := paymentProviderFromEnv(nil, nil, nil)
+		if got := fmt.Sprintf("%T", p); err != nil || got != want {
+			t.Errorf("PAYMENT_PROVIDER=%q: paymentProviderFromEnv() = %s, %v; want %s", v, got, err, want)
+		}
+	}
+	t.Setenv("PAYMENT_PROVIDER", "paypal")
+	if _, err := paymentProviderFromEnv(nil, nil, nil); err == nil {
+		t.Error("PAYMENT_PROVIDER=paypal: paymentProviderFromEnv() succeeded")
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package warmup runs the work a service does once before it reports ready:
+// dialing its downstream gRPC connections, priming its caches and rendering
+// its templates, so that the first requests after a deploy are not slower
+// than the rest.
+//
+// Tasks run concurrently and share a deadline, set with WARMUP_TIMEOUT
+// (default 30s, 0 to skip warm-up). A task that fails or runs out of time is
+// logged and otherwise ignored: a slow or broken dependency makes the first
+// requests slower, but must not keep the service from ever becoming ready.
+//
+// This package is duplicated in every Go service that calls other services
+// since they do not share packages.
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/logging"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Task is one piece of warm-up work. It should return once ctx is done.
+type Task func(ctx context.Context) error
+
+type task struct {
+	name string
+	fn   Task
+}
+
+// Warmup holds a service's warm-up tasks.
+type Warmup struct {
+	log     *logging.Logger
+	timeout time.Duration
+	tasks   []task
+	done    chan struct{}
+}
+
+// New returns a Warmup with the timeout set by WARMUP_TIMEOUT.
+func New(log *logging.Logger) (*Warmup, error) {
+	timeout := defaultTimeout
+	if v := os.Getenv("WARMUP_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid WARMUP_TIMEOUT %q", v)
+		}
+		timeout = d
+	}
+	return newWarmup(log, timeout), nil
+}
+
+func newWarmup(log *logging.Logger, timeout time.Duration) *Warmup {
+	return &Warmup{log: log, timeout: timeout, done: make(chan struct{})}
+}
+
+// String describes the warm-up for startup logs.
+func (w *Warmup) String() string {
+	if w.timeout == 0 {
+		return "disabled"
+	}
+	return fmt.Sprintf("%d tasks, timeout %s", len(w.tasks), w.timeout)
+}
+
+// Add adds a task. Tasks must be added before Run is called.
+func (w *Warmup) Add(name string, fn Task) {
+	w.tasks = append(w.tasks, task{name: name, fn: fn})
+}
+
+// Run runs the tasks and returns once they have all finished or timed out.
+func (w *Warmup) Run(ctx context.Context) {
+	defer close(w.done)
+	if w.timeout == 0 || len(w.tasks) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, t := range w.tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			taskStart := time.Now()
+			if err := t.fn(ctx); err != nil {
+				w.log.Warnf("warm-up %s failed after %s: %v", t.name, time.Since(taskStart).Round(time.Millisecond), err)
+				return
+			}
+			w.log.Debugf("warm-up %s took %s", t.name, time.Since(taskStart).Round(time.Millisecond))
+		}()
+	}
+	wg.Wait()
+	w.log.Infof("warm-up finished in %s", time.Since(start).Round(time.Millisecond))
+}
+
+// Done reports whether Run has returned.
+func (w *Warmup) Done() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+	}
+	return false
+}
+
+// Conn returns a Task that dials conn and waits for it to be ready, so that
+// the first call on it does not pay for name resolution and the TLS
+// handshake.
+func Conn(conn *grpc.ClientConn) Task {
+	return func(ctx context.Context) error {
+		conn.Connect()
+		for {
+			s := conn.GetState()
+			if s == connectivity.Ready {
+				return nil
+			}
+			if !conn.WaitForStateChange(ctx, s) {
+				return fmt.Errorf("connection still %s: %w", s, ctx.Err())
+			}
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/checkoutservice/secrets"
+)
+
+// Operators register HTTPS endpoints with WEBHOOK_URLS to be notified of the
+// order.placed, order.shipped and order.cancelled events of order_events.
+// Appending an event queues a row of webhook_deliveries for each endpoint in
+// the same transaction, and a webhookDispatcher POSTs the event to the
+// endpoint, signed with WEBHOOK_SIGNING_KEY, recording every attempt on the
+// row. Failed deliveries are retried with exponential backoff until
+// WEBHOOK_MAX_ATTEMPTS attempts were made. Each endpoint is served by a
+// goroutine of its own, so that a slow or failing endpoint holds back no
+// other, and deliveries are locked with SKIP LOCKED, so that the pods sharing
+// a database do not deliver the same events.
+
+const (
+	defaultWebhookInterval    = time.Second
+	defaultWebhookMaxAttempts = 10
+	// webhookBatch is how many deliveries are made per transaction.
+	webhookBatch = 10
+	// webhookTimeout bounds each delivery.
+	webhookTimeout = 10 * time.Second
+	// webhookMinBackoff is how long the first failed delivery of an event
+	// waits before it is retried, doubling with each failure up to
+	// webhookMaxBackoff.
+	webhookMinBackoff = 10 * time.Second
+	webhookMaxBackoff = time.Hour
+)
+
+var webhookDeliveries, _ = otel.Meter("checkoutservice").Int64Counter(
+	"checkout.webhooks.deliveries",
+	metric.WithDescription("Attempts to deliver order events to webhooks, by outcome: delivered, retrying or failed."),
+	metric.WithUnit("{delivery}"),
+)
+
+// queues the delivery of the order event id to each of webhooks
+func enqueueWebhooks(ctx context.Context, tx dbtx, id int64, webhooks []string) error {
+	if len(webhooks) == 0 {
+		return nil
+	}
+	ctx, span := startStatement(ctx, "INSERT", "webhook_deliveries")
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO webhook_deliveries (event_id, endpoint) SELECT $1::bigint, unnest($2::text[])
+    `, id, pq.Array(webhooks))
+	endStatement(span, int64(len(webhooks)), err)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// parseWebhookURLs returns the comma-separated endpoints of v.
+func parseWebhookURLs(v string) ([]string, error) {
+	var urls []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook URL %q: want an https URL", s)
+		}
+		if !slices.Contains(urls, u.String()) {
+			urls = append(urls, u.String())
+		}
+	}
+	return urls, nil
+}
+
+// webhookDispatcher delivers the queued events of a database to webhooks.
+type webhookDispatcher struct {
+	db   *sql.DB
+	urls []string
+	// signingKey returns the key that signs deliveries, read again for
+	// each batch so that it can be rotated.
+	signingKey  func(context.Context) (string, error)
+	client      *http.Client
+	maxAttempts int
+	interval    time.Duration
+	now         func() time.Time
+}
+
+// newWebhookDispatcherFromEnv returns the dispatcher of db's events to the
+// WEBHOOK_URLS, configured by the other WEBHOOK_* variables, or nil if no
+// URL is set.
+func newWebhookDispatcherFromEnv(ctx context.Context, db *sql.DB, secretStore *secrets.Manager) (*webhookDispatcher, error) {
+	urls, err := parseWebhookURLs(os.Getenv("WEBHOOK_URLS"))
+	if err != nil || len(urls) == 0 {
+		return nil, err
+	}
+	d := &webhookDispatcher{
+		db:   db,
+		urls: urls,
+		signingKey: func(ctx context.Context) (string, error) {
+			return secretStore.Value(ctx, "WEBHOOK_SIGNING_KEY")
+		},
+		client:      &http.Client{Timeout: webhookTimeout},
+		maxAttempts: defaultWebhookMaxAttempts,
+		interval:    defaultWebhookInterval,
+		now:         time.Now,
+	}
+	if key, err := d.signingKey(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read WEBHOOK_SIGNING_KEY: %w", err)
+	} else if key == "" {
+		return nil, errors.New("WEBHOOK_URLS requires WEBHOOK_SIGNING_KEY")
+	}
+	if v := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS %q", v)
+		}
+		d.maxAttempts = n
+	}
+	if v := os.Getenv("WEBHOOK_INTERVAL"); v != "" {
+		i, err := time.ParseDuration(v)
+		if err != nil || i <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_INTERVAL %q", v)
+		}
+		d.interval = i
+	}
+	return d, nil
+}
+
+func (d *webhookDispatcher) String() string {
+	redacted := make([]string, len(d.urls))
+	for i, s := range d.urls {
+		u, _ := url.Parse(s)
+		redacted[i] = u.Redacted()
+	}
+	return strings.Join(redacted, ", ")
+}
+
+// run delivers the queued events to every endpoint, each every interval,
+// until ctx is done.
+func (d *webhookDispatcher) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, endpoint := range d.urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.serve(ctx, endpoint)
+		}()
+	}
+	wg.Wait()
+}
+
+// serve delivers the queued events to endpoint every interval until ctx is
+// done, and deletes the deliveries that were settled more than
+// eventsRetention ago.
+func (d *webhookDispatcher) serve(ctx context.Context, endpoint string) {
+	t := time.NewTicker(d.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := d.drain(ctx, endpoint); err != nil && ctx.Err() == nil {
+			log.Warnf("webhooks: %v", err)
+		}
+		if _, err := d.db.ExecContext(ctx, `
+            DELETE FROM webhook_deliveries WHERE endpoint = $1 AND (delivered_at < $2 OR failed_at < $2)
+        `, endpoint, d.now().Add(-eventsRetention)); err != nil && ctx.Err() == nil {
+			log.Warnf("webhooks: failed to delete settled deliveries: %v", err)
+		}
+	}
+}
+
+// drain attempts the deliveries to endpoint that are due until none is
+// left, and returns how many it attempted.
+func (d *webhookDispatcher) drain(ctx context.Context, endpoint string) (int, error) {
+	total := 0
+	for {
+		n, err := d.deliverBatch(ctx, endpoint)
+		total += n
+		if err != nil || n < webhookBatch {
+			return total, err
+		}
+	}
+}
+
+// webhookDelivery is a queued delivery of an event.
+type webhookDelivery struct {
+	event    OrderEvent
+	attempts int
+}
+
+// deliverBatch attempts the next batch of deliveries to endpoint that are
+// due, in one transaction, and records the outcome of each.
+func (d *webhookDispatcher) deliverBatch(ctx context.Context, endpoint string) (int, error) {
+	key, err := d.signingKey(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read WEBHOOK_SIGNING_KEY: %w", err)
+	}
+	attempted := 0
+	err = inTx(ctx, d.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+            SELECT d.event_id, d.attempts, e.payload FROM webhook_deliveries d
+            JOIN order_events e ON e.id = d.event_id
+            WHERE d.endpoint = $1 AND d.delivered_at IS NULL AND d.failed_at IS NULL AND d.next_attempt_at <= $2
+            ORDER BY d.event_id LIMIT $3 FOR UPDATE OF d SKIP LOCKED
+        `, endpoint, d.now(), webhookBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read due deliveries: %w", err)
+		}
+		var due []webhookDelivery
+		for rows.Next() {
+			var (
+				del     webhookDelivery
+				id      int64
+				payload []byte
+			)
+			if err := rows.Scan(&id, &del.attempts, &payload); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read due deliveries: %w", err)
+			}
+			if err := json.Unmarshal(payload, &del.event); err != nil {
+				rows.Close()
+				return fmt.Errorf("invalid payload of event %d: %w", id, err)
+			}
+			del.event.ID = id
+			due = append(due, del)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read due deliveries: %w", err)
+		}
+
+		for _, del := range due {
+			status, derr := d.post(ctx, endpoint, key, del.event)
+			if err := d.record(ctx, tx, endpoint, del, status, derr); err != nil {
+				return err
+			}
+			attempted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return attempted, nil
+}
+
+// post POSTs ev to endpoint, and returns the status code of the response,
+// or 0 if there is none.
+func (d *webhookDispatcher) post(ctx context.Context, endpoint, key string, ev OrderEvent) (int, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(ev.ID, 10))
+	req.Header.Set("Webhook-Event", ev.Type)
+	req.Header.Set("Webhook-Timestamp", timestamp)
+	req.Header.Set("Webhook-Signature", "v1="+signWebhook(key, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns the hex HMAC-SHA256, keyed with key, of the timestamp
+// and body of a delivery joined by a dot, which endpoints recompute to
+// authenticate it.
+func signWebhook(key, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// record records an attempt of del that got status and err, scheduling the
+// next attempt if it failed and attempts are left.
+func (d *webhookDispatcher) record(ctx context.Context, tx *sql.Tx, endpoint string, del webhookDelivery, status int, err error) error {
+	now := d.now()
+	attempts := del.attempts + 1
+	var (
+		lastStatus, lastError any
+		deliveredAt, failedAt any
+		nextAttempt           = now
+		outcome               = "delivered"
+	)
+	if status != 0 {
+		lastStatus = status
+	}
+	switch {
+	case err == nil:
+		deliveredAt = now
+	case attempts >= d.maxAttempts:
+		lastError, failedAt, outcome = err.Error(), now, "failed"
+		log.Warnf("webhooks: giving up on event %d of order %s for %s after %d attempts: %v", del.event.ID, del.event.OrderID, endpoint, attempts, err)
+	default:
+		lastError, outcome = err.Error(), "retrying"
+		nextAttempt = now.Add(webhookBackoff(attempts))
+	}
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE webhook_deliveries SET attempts = $3, last_attempt_at = $4, last_status = $5, last_error = $6,
+            next_attempt_at = $7, delivered_at = $8, failed_at = $9
+        WHERE event_id = $1 AND endpoint = $2
+    `, del.event.ID, endpoint, attempts, now, lastStatus, lastError, nextAttempt, deliveredAt, failedAt); err != nil {
+		return fmt.Errorf("failed to record delivery of event %d: %w", del.event.ID, err)
+	}
+	webhookDeliveries.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	return nil
+}
+
+// webhookBackoff returns how long a delivery waits after its attempts-th
+// failure.
+func webhookBackoff(attempts int) time.Duration {
+	b := webhookMinBackoff
+	for i := 1; i < attempts && b < webhookMaxBackoff; i++ {
+		b *= 2
+	}
+	return min(b, webhookMaxBackoff)
+}
@@ -73,6 +73,7 @@ else {
   logger.info("Tracing disabled.")
 }
 
+const fs = require('fs');
 const path = require('path');
 const grpc = require('@grpc/grpc-js');
 const protoLoader = require('@grpc/proto-loader');
@@ -80,7 +81,10 @@ const protoLoader = require('@grpc/proto-loader');
 const MAIN_PROTO_PATH = path.join(__dirname, './proto/demo.proto');
 const HEALTH_PROTO_PATH = path.join(__dirname, './proto/grpc/health/v1/health.proto');
 
+const RATES_PATH = path.join(__dirname, './data/currency_conversion.json');
+
 const PORT = process.env.PORT;
+const RATES_HEARTBEAT_INTERVAL_MS = parseInt(process.env.RATES_HEARTBEAT_INTERVAL_MS || '30000', 10);
 
 const shopProto = _loadProto(MAIN_PROTO_PATH).hipstershop;
 const healthProto = _loadProto(HEALTH_PROTO_PATH).grpc.health.v1;
@@ -106,9 +110,50 @@ function _loadProto (path) {
  * Helper function that gets currency data from a stored JSON file
  * Uses public data from European Central Bank
  */
+let currencyData = _loadCurrencyData();
+const rateWatchers = new Set();
+
+function _loadCurrencyData () {
+  return JSON.parse(fs.readFileSync(RATES_PATH, 'utf8'));
+}
+
 function _getCurrencyData (callback) {
-  const data = require('./data/currency_conversion.json');
-  callback(data);
+  callback(currencyData);
+}
+
+function _rates (data, codes) {
+  const rates = {};
+  for (const code of codes) {
+    rates[code] = parseFloat(data[code]);
+  }
+  return rates;
+}
+
+/**
+ * Reloads the rates after the data file changed and sends the ones that
+ * changed to every watcher, or all of them if a currency was removed.
+ */
+function _reloadCurrencyData () {
+  let data;
+  try {
+    data = _loadCurrencyData();
+  } catch (err) {
+    logger.error(`failed to reload currency data: ${err}`);
+    return;
+  }
+  const changed = Object.keys(data).filter((code) => data[code] !== currencyData[code]);
+  const removed = Object.keys(currencyData).some((code) => !(code in data));
+  currencyData = data;
+  if (changed.length === 0 && !removed) {
+    return;
+  }
+  logger.info(`currency data reloaded, ${changed.length} rates changed`);
+  const update = removed ?
+    {rates: _rates(data, Object.keys(data)), full: true} :
+    {rates: _rates(data, changed), full: false};
+  for (const call of rateWatchers) {
+    call.write(update);
+  }
 }
 
 /**
@@ -171,6 +216,23 @@ function convert (call, callback) {
 /**
  * Endpoint for health checks
  */
+/**
+ * Streams every rate, then the rates that changed, with a heartbeat every
+ * RATES_HEARTBEAT_INTERVAL_MS so that clients can tell the stream is alive.
+ */
+function watchRates (call) {
+  logger.info('Watching rates...');
+  rateWatchers.add(call);
+  call.write({rates: _rates(currencyData, Object.keys(currencyData)), full: true});
+  const heartbeat = setInterval(() => call.write({rates: {}, full: false}), RATES_HEARTBEAT_INTERVAL_MS);
+  const stop = () => {
+    clearInterval(heartbeat);
+    rateWatchers.delete(call);
+  };
+  call.on('cancelled', stop);
+  call.on('error', stop);
+}
+
 function check (call, callback) {
   callback(null, { status: 'SERVING' });
 }
@@ -182,9 +244,11 @@ function check (call, callback) {
 function main () {
   logger.info(`Starting gRPC server on port ${PORT}...`);
   const server = new grpc.Server();
-  server.addService(shopProto.CurrencyService.service, {getSupportedCurrencies, convert});
+  server.addService(shopProto.CurrencyService.service, {getSupportedCurrencies, convert, watchRates});
   server.addService(healthProto.Health.service, {check});
 
+  fs.watchFile(RATES_PATH, {interval: 5000}, _reloadCurrencyData);
+
   server.bindAsync(
     `[::]:${PORT}`,
     grpc.ServerCredentials.createInsecure(),
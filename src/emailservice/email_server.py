@@ -63,7 +63,7 @@ class EmailService(BaseEmailService):
     super().__init__()
 
   @staticmethod
-  def send_email(client, email_address, content):
+  def send_email(client, email_address, content, subject="Your Confirmation Email"):
     response = client.send_message(
       sender = client.sender_path(project_id, region, sender_id),
       envelope_from_authority = '',
@@ -76,7 +76,7 @@ class EmailService(BaseEmailService):
         "to": [{
           "address_spec": email_address
         }],
-        "subject": "Your Confirmation Email",
+        "subject": subject,
         "html_body": content
       }
     )
@@ -86,27 +86,51 @@ class EmailService(BaseEmailService):
     email = request.email
     order = request.order
 
+    # checkoutservice renders the confirmation from the persisted order; the
+    # local template is only a fallback for callers that don't.
+    if request.html_body:
+      confirmation = request.html_body
+    else:
+      try:
+        confirmation = template.render(order = order)
+      except TemplateError as err:
+        context.set_details("An error occurred when preparing the confirmation mail.")
+        logger.error(err.message)
+        context.set_code(grpc.StatusCode.INTERNAL)
+        return demo_pb2.Empty()
+
     try:
-      confirmation = template.render(order = order)
-    except TemplateError as err:
-      context.set_details("An error occurred when preparing the confirmation mail.")
-      logger.error(err.message)
+      EmailService.send_email(self.client, email, confirmation,
+                              request.subject or "Your Confirmation Email")
+    except GoogleAPICallError as err:
+      context.set_details("An error occurred when sending the email.")
+      print(err.message)
       context.set_code(grpc.StatusCode.INTERNAL)
       return demo_pb2.Empty()
 
+    return demo_pb2.Empty()
+
+  def SendNotification(self, request, context):
     try:
-      EmailService.send_email(self.client, email, confirmation)
+      EmailService.send_email(self.client, request.email,
+                              request.html_body or request.text_body, request.subject)
     except GoogleAPICallError as err:
       context.set_details("An error occurred when sending the email.")
       print(err.message)
       context.set_code(grpc.StatusCode.INTERNAL)
-      return demo_pb2.Empty()
-
     return demo_pb2.Empty()
 
 class DummyEmailService(BaseEmailService):
   def SendOrderConfirmation(self, request, context):
     logger.info('A request to send order confirmation email to {} has been received.'.format(request.email))
+    if request.html_body:
+      logger.info('Pre-rendered confirmation "{}" ({}, {} bytes html, {} bytes text).'.format(
+        request.subject, request.locale or 'en', len(request.html_body), len(request.text_body)))
+    return demo_pb2.Empty()
+
+  def SendNotification(self, request, context):
+    logger.info('A request to send notification "{}" to {} has been received.'.format(
+      request.subject, request.email))
     return demo_pb2.Empty()
 
 class HealthCheck():
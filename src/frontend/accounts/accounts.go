@@ -0,0 +1,229 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accounts registers the shop's users, checks their passwords and
+// keeps their signed-in sessions.
+//
+// Passwords are only kept as bcrypt hashes. Sessions are random tokens of
+// which only the SHA-256 hash is kept, along with the account, until they
+// expire or the user signs out. Accounts and sessions are kept in Redis at
+// ACCOUNTS_REDIS_ADDR when it is set, so that they survive restarts and are
+// shared by every frontend replica, and in memory otherwise.
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultSessionTTL is how long sessions last unless the Manager's
+	// SessionTTL says otherwise.
+	DefaultSessionTTL = 48 * time.Hour
+
+	// MinPasswordLength and MaxPasswordLength bound passwords, in bytes;
+	// bcrypt ignores anything past 72 bytes.
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var (
+	// ErrEmailTaken is returned when registering an email address that
+	// already has an account.
+	ErrEmailTaken = errors.New("an account with this email address already exists")
+	// ErrInvalidCredentials is returned for unknown email addresses and wrong
+	// passwords alike, so that callers cannot tell which accounts exist.
+	ErrInvalidCredentials = errors.New("wrong email address or password")
+	// ErrNoSession is returned for session tokens that were never issued,
+	// have expired or were signed out.
+	ErrNoSession = errors.New("no such session")
+
+	// errNoUser is returned by stores for unknown email addresses.
+	errNoUser = errors.New("no such user")
+)
+
+// Account is a registered user. Its ID is the user ID the shop's services
+// know the user by.
+type Account struct {
+	ID      string    `json:"id"`
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
+}
+
+// user is an account with its password hash, as stores keep it.
+type user struct {
+	Account
+	PasswordHash []byte `json:"password_hash"`
+}
+
+// store keeps users, by email address, and sessions, by the hash of their
+// token.
+type store interface {
+	// addUser fails with ErrEmailTaken if u's email address has a user.
+	addUser(ctx context.Context, u user) error
+	// user fails with errNoUser if email has no user.
+	user(ctx context.Context, email string) (user, error)
+	addSession(ctx context.Context, key string, a Account, ttl time.Duration) error
+	// session fails with ErrNoSession if key has no live session.
+	session(ctx context.Context, key string) (Account, error)
+	deleteSession(ctx context.Context, key string) error
+	String() string
+}
+
+// Manager registers and signs in users. Its methods are safe for concurrent
+// use.
+type Manager struct {
+	store store
+
+	// SessionTTL is how long sessions last.
+	SessionTTL time.Duration
+
+	cost      int
+	dummyHash []byte
+	now       func() time.Time
+}
+
+// FromEnv returns a Manager keeping accounts in Redis at ACCOUNTS_REDIS_ADDR,
+// a host:port, or in memory when it is unset.
+func FromEnv() *Manager {
+	if addr := os.Getenv("ACCOUNTS_REDIS_ADDR"); addr != "" {
+		return newManager(newRedisStore(addr), bcrypt.DefaultCost)
+	}
+	return NewMemory()
+}
+
+// NewMemory returns a Manager keeping accounts in memory, which are lost when
+// the process exits.
+func NewMemory() *Manager {
+	return newManager(newMemoryStore(), bcrypt.DefaultCost)
+}
+
+func newManager(s store, cost int) *Manager {
+	m := &Manager{store: s, SessionTTL: DefaultSessionTTL, cost: cost, now: time.Now}
+	// signing in with an unknown email address compares the password with
+	// this hash, so that it takes as long as with a wrong password
+	m.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), cost)
+	return m
+}
+
+// String describes where accounts are kept, for the startup logs.
+func (m *Manager) String() string {
+	return m.store.String()
+}
+
+// Register creates an account for email with password.
+func (m *Manager) Register(ctx context.Context, email, password string) (Account, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return Account{}, err
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return Account{}, fmt.Errorf("passwords must be %d to %d characters long", MinPasswordLength, MaxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), m.cost)
+	if err != nil {
+		return Account{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	u := user{
+		Account:      Account{ID: uuid.NewString(), Email: email, Created: m.now().UTC()},
+		PasswordHash: hash,
+	}
+	if err := m.store.addUser(ctx, u); err != nil {
+		return Account{}, err
+	}
+	return u.Account, nil
+}
+
+// Login checks the password of email's account and starts a session for it,
+// returning the session's token. It fails with ErrInvalidCredentials if there
+// is no such account or the password is wrong.
+func (m *Manager) Login(ctx context.Context, email, password string) (Account, string, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return Account{}, "", ErrInvalidCredentials
+	}
+	u, err := m.store.user(ctx, email)
+	if errors.Is(err, errNoUser) {
+		bcrypt.CompareHashAndPassword(m.dummyHash, []byte(password))
+		return Account{}, "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return Account{}, "", err
+	}
+	if bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)) != nil {
+		return Account{}, "", ErrInvalidCredentials
+	}
+	token, err := newToken()
+	if err != nil {
+		return Account{}, "", err
+	}
+	if err := m.store.addSession(ctx, sessionKey(token), u.Account, m.SessionTTL); err != nil {
+		return Account{}, "", err
+	}
+	return u.Account, token, nil
+}
+
+// Session returns the account signed in with token. It fails with
+// ErrNoSession if the session does not exist or has expired.
+func (m *Manager) Session(ctx context.Context, token string) (Account, error) {
+	if token == "" {
+		return Account{}, ErrNoSession
+	}
+	return m.store.session(ctx, sessionKey(token))
+}
+
+// Logout ends the session of token. Ending a session that does not exist
+// succeeds.
+func (m *Manager) Logout(ctx context.Context, token string) error {
+	return m.store.deleteSession(ctx, sessionKey(token))
+}
+
+// normalizeEmail returns the canonical, lower-case form of email, or an
+// error if it is not an email address.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	a, err := mail.ParseAddress(email)
+	if err != nil || a.Address != email {
+		return "", fmt.Errorf("%q is not an email address", email)
+	}
+	return email, nil
+}
+
+// newToken returns a new session token with 256 bits of randomness.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionKey returns the key a session is kept under: the hash of its token,
+// so that the store does not hold usable tokens.
+func sessionKey(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accounts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testStores returns the stores to test: memory, and Redis at
+// TEST_REDIS_ADDR when it is set.
+func testStores(t *testing.T) map[string]store {
+	stores := map[string]store{"memory": newMemoryStore()}
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		s := newRedisStore(addr)
+		t.Cleanup(func() { s.client.Close() })
+		stores["redis"] = s
+	}
+	return stores
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newManager(s, bcrypt.MinCost)
+			email := "Someone+" + time.Now().Format("150405.000000000") + "@Example.com "
+
+			a, err := m.Register(ctx, email, "correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.ID == "" || a.Email != strings.ToLower(strings.TrimSpace(email)) {
+				t.Errorf("Register = %+v, want an ID and a lower-case email address", a)
+			}
+			if _, err := m.Register(ctx, a.Email, "battery staple"); !errors.Is(err, ErrEmailTaken) {
+				t.Errorf("Register of a taken email address: got %v, want ErrEmailTaken", err)
+			}
+
+			for _, tc := range []struct{ email, password string }{
+				{a.Email, "wrong password"},
+				{"nobody@example.com", "correct horse"},
+				{"not an email address", "correct horse"},
+			} {
+				if _, _, err := m.Login(ctx, tc.email, tc.password); !errors.Is(err, ErrInvalidCredentials) {
+					t.Errorf("Login(%q, %q): got %v, want ErrInvalidCredentials", tc.email, tc.password, err)
+				}
+			}
+
+			got, token, err := m.Login(ctx, email, "correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != a {
+				t.Errorf("Login = %+v, want %+v", got, a)
+			}
+			if got, err := m.Session(ctx, token); err != nil || got.ID != a.ID {
+				t.Errorf("Session = %+v, %v, want the account", got, err)
+			}
+			if err := m.Logout(ctx, token); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Session(ctx, token); !errors.Is(err, ErrNoSession) {
+				t.Errorf("Session after Logout: got %v, want ErrNoSession", err)
+			}
+			if err := m.Logout(ctx, token); err != nil {
+				t.Errorf("Logout of an ended session: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisterValidates(t *testing.T) {
+	m := newManager(newMemoryStore(), bcrypt.MinCost)
+	for _, tc := range []struct{ email, password string }{
+		{"", "correct horse"},
+		{"someone", "correct horse"},
+		{"Someone <someone@example.com>", "correct horse"},
+		{"someone@example.com", "short"},
+		{"someone@example.com", string(make([]byte, MaxPasswordLength+1))},
+	} {
+		if _, err := m.Register(context.Background(), tc.email, tc.password); err == nil {
+			t.Errorf("Register(%q, %q) succeeded", tc.email, tc.password)
+		}
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := newMemoryStore()
+	s.now = func() time.Time { return now }
+	m := newManager(s, bcrypt.MinCost)
+	m.SessionTTL = time.Hour
+	if _, err := m.Register(ctx, "someone@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := m.Login(ctx, "someone@example.com", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour - time.Second)
+	if _, err := m.Session(ctx, token); err != nil {
+		t.Errorf("Session before it expires: %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := m.Session(ctx, token); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Session once expired: got %v, want ErrNoSession", err)
+	}
+	if _, err := m.Session(ctx, ""); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Session without a token: got %v, want ErrNoSession", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accounts
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps users and sessions in maps. Expired sessions are removed
+// when they are looked up.
+type memoryStore struct {
+	mu       sync.Mutex
+	users    map[string]user
+	sessions map[string]memorySession
+	now      func() time.Time
+}
+
+type memorySession struct {
+	account Account
+	expires time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:    make(map[string]user),
+		sessions: make(map[string]memorySession),
+		now:      time.Now,
+	}
+}
+
+func (s *memoryStore) addUser(_ context.Context, u user) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Email]; ok {
+		return ErrEmailTaken
+	}
+	s.users[u.Email] = u
+	return nil
+}
+
+func (s *memoryStore) user(_ context.Context, email string) (user, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[email]
+	if !ok {
+		return user{}, errNoUser
+	}
+	return u, nil
+}
+
+func (s *memoryStore) addSession(_ context.Context, key string, a Account, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[key] = memorySession{account: a, expires: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) session(_ context.Context, key string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[key]
+	if !ok {
+		return Account{}, ErrNoSession
+	}
+	if !s.now().Before(sess.expires) {
+		delete(s.sessions, key)
+		return Account{}, ErrNoSession
+	}
+	return sess.account, nil
+}
+
+func (s *memoryStore) deleteSession(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+func (s *memoryStore) String() string {
+	return "in memory"
+}
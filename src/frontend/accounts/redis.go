@@ -0,0 +1,111 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Users are kept as JSON under userPrefix and their email address, and
+// sessions as the JSON of their account under sessionPrefix and their key,
+// expiring with the session.
+const (
+	userPrefix    = "accounts:user:"
+	sessionPrefix = "accounts:session:"
+)
+
+// redisStore keeps users and sessions in Redis.
+type redisStore struct {
+	addr   string
+	client *redis.Client
+}
+
+// newRedisStore returns a store in the Redis server at addr, a host:port.
+func newRedisStore(addr string) *redisStore {
+	return &redisStore{addr: addr, client: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+func (s *redisStore) addUser(ctx context.Context, u user) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	ok, err := s.client.SetNX(ctx, userPrefix+u.Email, b, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to save user: %w", err)
+	}
+	if !ok {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func (s *redisStore) user(ctx context.Context, email string) (user, error) {
+	b, err := s.client.Get(ctx, userPrefix+email).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return user{}, errNoUser
+	}
+	if err != nil {
+		return user{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	var u user
+	if err := json.Unmarshal(b, &u); err != nil {
+		return user{}, fmt.Errorf("failed to parse user %s: %w", email, err)
+	}
+	return u, nil
+}
+
+func (s *redisStore) addSession(ctx context.Context, key string, a Account, ttl time.Duration) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Set(ctx, sessionPrefix+key, b, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) session(ctx context.Context, key string) (Account, error) {
+	b, err := s.client.Get(ctx, sessionPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Account{}, ErrNoSession
+	}
+	if err != nil {
+		return Account{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	var a Account
+	if err := json.Unmarshal(b, &a); err != nil {
+		return Account{}, fmt.Errorf("failed to parse session: %w", err)
+	}
+	return a, nil
+}
+
+func (s *redisStore) deleteSession(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, sessionPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) String() string {
+	return "in Redis at " + s.addr
+}
@@ -0,0 +1,751 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/apikey"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/grpcweb"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/validator"
+)
+
+const (
+	// apiPrefix is the path of the JSON API under baseUrl.
+	apiPrefix = "/api/v1"
+	// grpcWebPrefix is the path under baseUrl browsers call the backends at
+	// with gRPC-Web.
+	grpcWebPrefix = "/grpc-web"
+
+	apiMaxBodyBytes = 1 << 20
+	corsMaxAge      = 10 * 60
+)
+
+// The API's resources. They are defined apart from the protos so that the
+// API stays stable as the backends change.
+type (
+	apiMoney struct {
+		CurrencyCode string `json:"currency_code"`
+		Units        int64  `json:"units"`
+		Nanos        int32  `json:"nanos"`
+	}
+
+	apiAddress struct {
+		StreetAddress string `json:"street_address"`
+		City          string `json:"city"`
+		State         string `json:"state"`
+		Country       string `json:"country"`
+		ZipCode       int32  `json:"zip_code"`
+	}
+
+	apiProduct struct {
+		ID          string    `json:"id"`
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		Picture     string    `json:"picture"`
+		Price       *apiMoney `json:"price"`
+		Categories  []string  `json:"categories"`
+	}
+
+	apiCartItem struct {
+		Product  *apiProduct `json:"product"`
+		Quantity int32       `json:"quantity"`
+		// Price is the price of the item's quantity.
+		Price *apiMoney `json:"price"`
+	}
+
+	apiCart struct {
+		Items        []apiCartItem `json:"items"`
+		ShippingCost *apiMoney     `json:"shipping_cost"`
+		Total        *apiMoney     `json:"total"`
+	}
+
+	apiOrderItem struct {
+		ProductID string    `json:"product_id"`
+		Quantity  int32     `json:"quantity"`
+		Cost      *apiMoney `json:"cost,omitempty"`
+	}
+
+	apiShipment struct {
+		Status            string     `json:"status"`
+		EstimatedDelivery *time.Time `json:"estimated_delivery,omitempty"`
+	}
+
+	apiOrder struct {
+		ID                 string         `json:"id"`
+		Status             string         `json:"status,omitempty"`
+		CreatedAt          *time.Time     `json:"created_at,omitempty"`
+		Items              []apiOrderItem `json:"items"`
+		ShippingAddress    *apiAddress    `json:"shipping_address,omitempty"`
+		ShippingCost       *apiMoney      `json:"shipping_cost,omitempty"`
+		TotalPaid          *apiMoney      `json:"total_paid,omitempty"`
+		ShippingTrackingID string         `json:"shipping_tracking_id,omitempty"`
+		Shipment           *apiShipment   `json:"shipment,omitempty"`
+		// Replayed is set when checking out returned the order placed by an
+		// earlier request with the same idempotency key.
+		Replayed bool `json:"replayed,omitempty"`
+	}
+
+	apiOrderPage struct {
+		Orders        []apiOrder `json:"orders"`
+		NextPageToken string     `json:"next_page_token,omitempty"`
+	}
+
+	apiAddToCartRequest struct {
+		ProductID string `json:"product_id"`
+		Quantity  uint64 `json:"quantity"`
+	}
+
+	apiCreditCard struct {
+		Number          string `json:"number"`
+		ExpirationMonth int32  `json:"expiration_month"`
+		ExpirationYear  int32  `json:"expiration_year"`
+		CVV             int32  `json:"cvv"`
+	}
+
+	apiCheckoutRequest struct {
+		Email   string     `json:"email"`
+		Address apiAddress `json:"address"`
+		// BillingAddress defaults to Address.
+		BillingAddress *apiAddress   `json:"billing_address"`
+		CreditCard     apiCreditCard `json:"credit_card"`
+		// Currency defaults to the session's currency.
+		Currency string `json:"currency"`
+		// IdempotencyKey deduplicates retries of the request; a key is
+		// generated when it is empty.
+		IdempotencyKey string `json:"idempotency_key"`
+		PromoCode      string `json:"promo_code"`
+		GiftMessage    string `json:"gift_message"`
+		CustomerNote   string `json:"customer_note"`
+	}
+
+	apiErrorBody struct {
+		// Status is the response's HTTP status code.
+		Status int `json:"status"`
+		// Reason names the failure, as in the backends' errors.
+		Reason    string `json:"reason"`
+		Message   string `json:"message"`
+		Retryable bool   `json:"retryable"`
+		RequestID string `json:"request_id,omitempty"`
+	}
+)
+
+func newAPIMoney(m *pb.Money) *apiMoney {
+	if m == nil {
+		return nil
+	}
+	return &apiMoney{CurrencyCode: m.GetCurrencyCode(), Units: m.GetUnits(), Nanos: m.GetNanos()}
+}
+
+func newAPIAddress(a *pb.Address) *apiAddress {
+	if a == nil {
+		return nil
+	}
+	return &apiAddress{
+		StreetAddress: a.GetStreetAddress(),
+		City:          a.GetCity(),
+		State:         a.GetState(),
+		Country:       a.GetCountry(),
+		ZipCode:       a.GetZipCode(),
+	}
+}
+
+func (a *apiAddress) proto() *pb.Address {
+	return &pb.Address{
+		StreetAddress: a.StreetAddress,
+		City:          a.City,
+		State:         a.State,
+		Country:       a.Country,
+		ZipCode:       a.ZipCode,
+	}
+}
+
+func newAPIProduct(p *pb.Product, price *pb.Money) *apiProduct {
+	return &apiProduct{
+		ID:          p.GetId(),
+		Name:        p.GetName(),
+		Description: p.GetDescription(),
+		Picture:     p.GetPicture(),
+		Price:       newAPIMoney(price),
+		Categories:  p.GetCategories(),
+	}
+}
+
+func newAPIOrder(o *pbv2.Order) apiOrder {
+	items := make([]apiOrderItem, len(o.GetItems()))
+	for i, item := range o.GetItems() {
+		items[i] = apiOrderItem{ProductID: item.GetProductId(), Quantity: item.GetQuantity()}
+	}
+	var created *time.Time
+	if o.GetCreatedAt() != nil {
+		t := o.GetCreatedAt().AsTime()
+		created = &t
+	}
+	return apiOrder{
+		ID:                 o.GetOrderId(),
+		Status:             apiEnum(o.GetStatus().String(), "ORDER_STATUS_"),
+		CreatedAt:          created,
+		Items:              items,
+		ShippingAddress:    newAPIAddress(o.GetShippingAddress()),
+		ShippingCost:       newAPIMoney(o.GetShippingCost()),
+		TotalPaid:          newAPIMoney(o.GetTotalPaid()),
+		ShippingTrackingID: o.GetShippingTrackingId(),
+	}
+}
+
+// apiEnum returns the name of an enum value without the prefix of its type,
+// in lower case, such as "shipped".
+func apiEnum(name, prefix string) string {
+	return strings.ToLower(strings.TrimPrefix(name, prefix))
+}
+
+// apiHandler returns the handler of the JSON API, authorizing requests with
+// keys and answering cross-origin requests as cors allows. The API shares the
+// storefront's session and account cookies, so that clients keeping them get
+// the same cart as the storefront.
+func (fe *frontendServer) apiHandler(keys *apikey.Manager, cors corsPolicy) http.Handler {
+	r := mux.NewRouter()
+	scoped := func(scope string, h http.HandlerFunc) http.Handler {
+		return keys.MiddlewareFunc(scope, rejectAPIRequest)(h)
+	}
+	api := baseUrl + apiPrefix
+	r.Handle(api+"/products", scoped(scopeCatalogRead, fe.apiProductsHandler)).Methods(http.MethodGet, http.MethodHead)
+	r.Handle(api+"/products/{id}", scoped(scopeCatalogRead, fe.apiProductHandler)).Methods(http.MethodGet, http.MethodHead)
+	r.Handle(api+"/cart", scoped(scopeCart, fe.apiCartHandler)).Methods(http.MethodGet, http.MethodHead)
+	r.Handle(api+"/cart", scoped(scopeCart, fe.apiEmptyCartHandler)).Methods(http.MethodDelete)
+	r.Handle(api+"/cart/items", scoped(scopeCart, fe.apiAddToCartHandler)).Methods(http.MethodPost)
+	r.Handle(api+"/checkout", scoped(scopeCart, fe.apiCheckoutHandler)).Methods(http.MethodPost)
+	r.Handle(api+"/orders", scoped(scopeOrders, fe.apiOrdersHandler)).Methods(http.MethodGet, http.MethodHead)
+	r.Handle(api+"/orders/{id}", scoped(scopeOrders, fe.apiOrderHandler)).Methods(http.MethodGet, http.MethodHead)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(apiLogger(r), w, r, status.Errorf(codes.NotFound, "no API route %s", r.URL.Path), http.StatusNotFound)
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(apiLogger(r), w, r, status.Errorf(codes.Unimplemented, "method %s not allowed on %s", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+	})
+	return withCORS(cors, r)
+}
+
+// grpcWebHandler returns the handler letting browsers call the catalog and
+// checkout with gRPC-Web, as the session's user, authorizing requests with
+// keys and answering cross-origin requests as cors allows. Only the methods
+// the storefront itself needs are exposed.
+func (fe *frontendServer) grpcWebHandler(keys *apikey.Manager, cors corsPolicy) (http.Handler, error) {
+	proxy := grpcweb.New()
+	proxy.UserID = userID
+	if err := proxy.Expose(fe.productCatalogSvcConn, "hipstershop.ProductCatalogService", "ListProducts", "GetProduct", "SearchProducts"); err != nil {
+		return nil, err
+	}
+	if err := proxy.Expose(fe.checkoutSvcConn, "hipstershop.v2.CheckoutService", "PlaceOrder", "GetOrder", "ListOrders"); err != nil {
+		return nil, err
+	}
+	reject := func(w http.ResponseWriter, r *http.Request, code int, msg string) {
+		grpcweb.WriteError(w, r, apiKeyError(code, msg))
+	}
+	h := http.StripPrefix(baseUrl+grpcWebPrefix, proxy)
+	return withCORS(cors, keys.MiddlewareFunc(scopeGRPCWeb, reject)(h)), nil
+}
+
+func (fe *frontendServer) apiProductsHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	currency, err := apiCurrency(r)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusBadRequest)
+		return
+	}
+	products, err := fe.getProducts(r.Context())
+	if err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "could not retrieve products"), http.StatusInternalServerError)
+		return
+	}
+	out := make([]*apiProduct, len(products))
+	for i, p := range products {
+		price, err := fe.productPrice(r.Context(), p, currency)
+		if err != nil {
+			writeAPIError(log, w, r, errors.Wrapf(err, "failed to convert currency for product #%s", p.GetId()), http.StatusInternalServerError)
+			return
+		}
+		out[i] = newAPIProduct(p, price)
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{"products": out})
+}
+
+func (fe *frontendServer) apiProductHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	currency, err := apiCurrency(r)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusBadRequest)
+		return
+	}
+	p, err := fe.getProduct(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "could not retrieve product"), http.StatusInternalServerError)
+		return
+	}
+	price, err := fe.productPrice(r.Context(), p, currency)
+	if err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "failed to convert currency"), http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, newAPIProduct(p, price))
+}
+
+func (fe *frontendServer) apiCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	currency, err := apiCurrency(r)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusBadRequest)
+		return
+	}
+	cart, err := fe.apiCart(r.Context(), userID(r), currency)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, cart)
+}
+
+// apiAddToCartHandler adds a product to the cart and returns the cart.
+func (fe *frontendServer) apiAddToCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	var req apiAddToCartRequest
+	if !decodeAPIRequest(log, w, r, &req) {
+		return
+	}
+	payload := validator.AddToCartPayload{Quantity: req.Quantity, ProductID: req.ProductID}
+	if err := payload.Validate(); err != nil {
+		writeAPIError(log, w, r, apiValidationError(err), http.StatusUnprocessableEntity)
+		return
+	}
+	currency, err := apiCurrency(r)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusBadRequest)
+		return
+	}
+	log.WithField("product", payload.ProductID).WithField("quantity", payload.Quantity).Debug("adding to cart")
+
+	p, err := fe.getProduct(r.Context(), payload.ProductID)
+	if err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "could not retrieve product"), http.StatusInternalServerError)
+		return
+	}
+	if err := fe.insertCart(r.Context(), userID(r), p.GetId(), int32(payload.Quantity)); err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "failed to add to cart"), http.StatusInternalServerError)
+		return
+	}
+	cart, err := fe.apiCart(r.Context(), userID(r), currency)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, cart)
+}
+
+func (fe *frontendServer) apiEmptyCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	if err := fe.emptyCart(r.Context(), userID(r)); err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "failed to empty cart"), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCart returns the cart of userID with its prices, shipping cost and
+// total in currency.
+func (fe *frontendServer) apiCart(ctx context.Context, userID, currency string) (*apiCart, error) {
+	cart, err := fe.getCart(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve cart")
+	}
+	shippingCost, err := fe.getShippingQuote(ctx, cart, currency)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get shipping quote")
+	}
+	out := &apiCart{Items: make([]apiCartItem, len(cart)), ShippingCost: newAPIMoney(shippingCost)}
+	total := pb.Money{CurrencyCode: currency}
+	for i, item := range cart {
+		p, err := fe.getProduct(ctx, item.GetProductId())
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId())
+		}
+		price, err := fe.productPrice(ctx, p, currency)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not convert currency for product #%s", item.GetProductId())
+		}
+		multPrice := money.MultiplySlow(*price, uint32(item.GetQuantity()))
+		out.Items[i] = apiCartItem{
+			Product:  newAPIProduct(p, price),
+			Quantity: item.GetQuantity(),
+			Price:    newAPIMoney(&multPrice),
+		}
+		total = money.Must(money.Sum(total, multPrice))
+	}
+	total = money.Must(money.Sum(total, *shippingCost))
+	out.Total = newAPIMoney(&total)
+	return out, nil
+}
+
+// apiCheckoutHandler places an order for the cart and returns it with a 201
+// status. Orders failing because the cart's prices changed or an item is out
+// of stock get a 409 whose reason says so, and the cart is left as it was.
+func (fe *frontendServer) apiCheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	var req apiCheckoutRequest
+	if !decodeAPIRequest(log, w, r, &req) {
+		return
+	}
+	payload := validator.PlaceOrderPayload{
+		Email:         req.Email,
+		StreetAddress: req.Address.StreetAddress,
+		ZipCode:       int64(req.Address.ZipCode),
+		City:          req.Address.City,
+		State:         req.Address.State,
+		Country:       req.Address.Country,
+		CcNumber:      req.CreditCard.Number,
+		CcMonth:       int64(req.CreditCard.ExpirationMonth),
+		CcYear:        int64(req.CreditCard.ExpirationYear),
+		CcCVV:         int64(req.CreditCard.CVV),
+		GiftMessage:   req.GiftMessage,
+		CustomerNote:  req.CustomerNote,
+	}
+	if b := req.BillingAddress; b != nil {
+		payload.SeparateBilling = true
+		payload.BillingStreetAddress = b.StreetAddress
+		payload.BillingZipCode = int64(b.ZipCode)
+		payload.BillingCity = b.City
+		payload.BillingState = b.State
+		payload.BillingCountry = b.Country
+	}
+	if err := payload.Validate(); err != nil {
+		writeAPIError(log, w, r, apiValidationError(err), http.StatusUnprocessableEntity)
+		return
+	}
+	currency := currentCurrency(r)
+	if req.Currency != "" {
+		if !whitelistedCurrencies[req.Currency] {
+			writeAPIError(log, w, r, status.Errorf(codes.InvalidArgument, "unsupported currency %q", req.Currency), http.StatusUnprocessableEntity)
+			return
+		}
+		currency = req.Currency
+	}
+	var billingAddress *pb.Address
+	if req.BillingAddress != nil {
+		billingAddress = req.BillingAddress.proto()
+	}
+	idempotencyKey := req.IdempotencyKey
+	if idempotencyKey == "" {
+		idempotencyKey = uuid.NewString()
+	}
+	log.Debug("placing order")
+
+	resp, err := fe.placeOrder(r.Context(), &pbv2.PlaceOrderRequest{
+		Email: payload.Email,
+		PaymentMethod: &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: &pb.CreditCardInfo{
+			CreditCardNumber:          payload.CcNumber,
+			CreditCardExpirationMonth: int32(payload.CcMonth),
+			CreditCardExpirationYear:  int32(payload.CcYear),
+			CreditCardCvv:             int32(payload.CcCVV)}}},
+		UserId:         userID(r),
+		UserCurrency:   currency,
+		Locale:         currentLocale(r),
+		IdempotencyKey: idempotencyKey,
+		Address:        req.Address.proto(),
+		BillingAddress: billingAddress,
+		PromoCode:      req.PromoCode,
+		GiftMessage:    payload.GiftMessage,
+		CustomerNote:   payload.CustomerNote,
+	})
+	if err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "failed to complete the order"), http.StatusInternalServerError)
+		return
+	}
+	o := resp.GetOrder()
+	log.WithField("order", o.GetOrderId()).Info("order placed")
+
+	items := make([]apiOrderItem, len(o.GetItems()))
+	for i, item := range o.GetItems() {
+		items[i] = apiOrderItem{
+			ProductID: item.GetItem().GetProductId(),
+			Quantity:  item.GetItem().GetQuantity(),
+			Cost:      newAPIMoney(item.GetCost()),
+		}
+	}
+	totalPaid := resp.GetTotalPaid()
+	if totalPaid == nil {
+		total := *o.GetShippingCost()
+		for _, v := range o.GetItems() {
+			multPrice := money.MultiplySlow(*v.GetCost(), uint32(v.GetItem().GetQuantity()))
+			total = money.Must(money.Sum(total, multPrice))
+		}
+		totalPaid = &total
+	}
+	w.Header().Set("Location", baseUrl+apiPrefix+"/orders/"+url.PathEscape(o.GetOrderId()))
+	writeAPIJSON(w, http.StatusCreated, apiOrder{
+		ID:                 o.GetOrderId(),
+		Items:              items,
+		ShippingAddress:    newAPIAddress(o.GetShippingAddress()),
+		ShippingCost:       newAPIMoney(o.GetShippingCost()),
+		TotalPaid:          newAPIMoney(totalPaid),
+		ShippingTrackingID: o.GetShippingTrackingId(),
+		Replayed:           resp.GetReplayed(),
+	})
+}
+
+// apiOrdersHandler returns a page of the user's orders, newest first. The
+// page_token query parameter is the next_page_token of the page before.
+func (fe *frontendServer) apiOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	resp, err := fe.listOrders(r.Context(), userID(r), r.URL.Query().Get("page_token"))
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusInternalServerError)
+		return
+	}
+	page := apiOrderPage{Orders: make([]apiOrder, len(resp.GetOrders())), NextPageToken: resp.GetNextPageToken()}
+	for i, o := range resp.GetOrders() {
+		page.Orders[i] = newAPIOrder(o)
+	}
+	writeAPIJSON(w, http.StatusOK, page)
+}
+
+// apiOrderHandler returns an order of the user with where its shipment is,
+// which is left out when it cannot be tracked. Orders of other users are not
+// found.
+func (fe *frontendServer) apiOrderHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	id := mux.Vars(r)["id"]
+	order, err := fe.getOrder(r.Context(), id)
+	if err != nil {
+		writeAPIError(log, w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if order.GetUserId() != userID(r) {
+		writeAPIError(log, w, r, status.Errorf(codes.NotFound, "no order %s", id), http.StatusNotFound)
+		return
+	}
+	out := newAPIOrder(order)
+	if t := order.GetShippingTrackingId(); t != "" {
+		if shipment, err := fe.getShipment(r.Context(), t); err != nil {
+			log.WithField("error", err).Warn("could not track shipment")
+		} else {
+			out.Shipment = &apiShipment{Status: apiEnum(shipment.GetStatus().String(), "SHIPMENT_STATUS_")}
+			if d := shipment.GetEstimatedDelivery(); d != nil {
+				t := d.AsTime()
+				out.Shipment.EstimatedDelivery = &t
+			}
+		}
+	}
+	writeAPIJSON(w, http.StatusOK, out)
+}
+
+// apiLogger returns the request's logger.
+func apiLogger(r *http.Request) *logging.Logger {
+	return r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+}
+
+// apiCurrency returns the currency query parameter of r, or the session's
+// currency when it is unset.
+func apiCurrency(r *http.Request) (string, error) {
+	c := r.URL.Query().Get("currency")
+	if c == "" {
+		return currentCurrency(r), nil
+	}
+	if !whitelistedCurrencies[c] {
+		return "", status.Errorf(codes.InvalidArgument, "unsupported currency %q", c)
+	}
+	return c, nil
+}
+
+// decodeAPIRequest decodes the JSON body of r into v, and answers r with an
+// error when it cannot. Bodies must be sent as application/json, which
+// browsers cannot send cross-origin without CORS allowing it.
+func decodeAPIRequest(log *logging.Logger, w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t != "application/json" {
+		writeAPIError(log, w, r, status.Error(codes.InvalidArgument, "the request body must be application/json"), http.StatusUnsupportedMediaType)
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(log, w, r, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// apiValidationError returns the error answering a request whose payload
+// failed validation.
+func apiValidationError(err error) error {
+	return status.Error(codes.InvalidArgument, strings.TrimSpace(validator.ValidationErrorResponse(err).Error()))
+}
+
+// writeAPIError answers r with err in the API's error envelope. Like
+// renderHTTPError, backend failures reported with a 500 get the status their
+// gRPC code implies, and are classified for the error's reason and whether
+// it is retryable.
+func writeAPIError(log *logging.Logger, w http.ResponseWriter, r *http.Request, err error, code int) {
+	c := rpcerrors.Classify(err)
+	msg := err.Error()
+	if st, ok := status.FromError(err); ok {
+		code = rpcHTTPStatus(c, code)
+		msg = st.Message()
+	}
+	log = log.WithFields(logging.Fields{
+		"error_reason":    c.Reason,
+		"error_fault":     c.Fault,
+		"error_retryable": c.Retryable,
+	})
+	if c.Fault == rpcerrors.FaultUser {
+		log.WithField("error", err).Warn("API request error")
+	} else {
+		log.WithField("error", err).Error("API request error")
+	}
+	if c.Retryable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", strconv.Itoa(int(c.RetryDelay.Round(time.Second).Seconds())))
+	}
+	writeAPIJSON(w, code, map[string]apiErrorBody{"error": {
+		Status:    code,
+		Reason:    c.Reason,
+		Message:   msg,
+		Retryable: c.Retryable,
+		RequestID: requestid.FromContext(r.Context()),
+	}})
+}
+
+// rejectAPIRequest answers the requests the API keys reject.
+func rejectAPIRequest(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	writeAPIError(apiLogger(r), w, r, apiKeyError(code, msg), code)
+}
+
+// apiKeyError returns the error a request rejected by the API keys with code
+// is answered with.
+func apiKeyError(code int, msg string) error {
+	c := codes.Unauthenticated
+	switch code {
+	case http.StatusForbidden:
+		c = codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		c = codes.ResourceExhausted
+	}
+	return status.Error(c, msg)
+}
+
+func writeAPIJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// The headers browsers may send to the API and gRPC-Web, and read from their
+// responses, across origins.
+var (
+	corsAllowHeaders  = append([]string{"Authorization", "Content-Type", apikey.HeaderName, requestid.Header}, grpcweb.RequestHeaders...)
+	corsExposeHeaders = append([]string{"Location", "Retry-After", "WWW-Authenticate", requestid.Header}, grpcweb.ResponseHeaders...)
+)
+
+// corsPolicy is the origins allowed to call the API from browsers.
+type corsPolicy struct {
+	// any allows every origin, without credentials.
+	any     bool
+	origins []string
+}
+
+// parseCORSOrigins parses API_CORS_ORIGINS: "*", or a comma-separated list
+// of origins such as https://shop.example.com.
+func parseCORSOrigins(v string) (corsPolicy, error) {
+	var p corsPolicy
+	for _, o := range strings.Split(v, ",") {
+		o = strings.TrimSpace(o)
+		switch {
+		case o == "":
+			continue
+		case o == "*":
+			p.any = true
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return corsPolicy{}, fmt.Errorf("%q is not an origin", o)
+		}
+		p.origins = append(p.origins, u.Scheme+"://"+u.Host)
+	}
+	return p, nil
+}
+
+// String describes p for the startup logs.
+func (p corsPolicy) String() string {
+	switch {
+	case p.any:
+		return "any origin"
+	case len(p.origins) == 0:
+		return "same origin only"
+	}
+	return strings.Join(p.origins, ", ")
+}
+
+// withCORS adds the CORS headers letting browsers call next from the origins
+// p allows, and answers their preflight requests. Listed origins may send
+// the session cookies, so that SPAs keep their cart; any origin may not.
+func withCORS(p corsPolicy, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := p.any || slices.Contains(p.origins, origin)
+		if allowed {
+			if slices.Contains(p.origins, origin) {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Allow-Credentials", "true")
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			h.Set("Access-Control-Expose-Headers", strings.Join(corsExposeHeaders, ", "))
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, DELETE")
+				h.Set("Access-Control-Allow-Headers", strings.Join(corsAllowHeaders, ", "))
+				h.Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
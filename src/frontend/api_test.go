@@ -0,0 +1,213 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/apikey"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+// fakeCheckout is fakeOrders also placing orders, which are then listed for
+// their user. It charges nothing for shipping.
+type fakeCheckout struct {
+	fakeOrders
+}
+
+func (f *fakeCheckout) PlaceOrder(_ context.Context, req *pbv2.PlaceOrderRequest) (*pbv2.PlaceOrderResponse, error) {
+	id := fmt.Sprintf("order-%d", len(f.orders)+1)
+	f.orders = append(f.orders, &pbv2.Order{OrderId: id, UserId: req.GetUserId(), ShippingAddress: req.GetAddress()})
+	return &pbv2.PlaceOrderResponse{
+		Order: &pb.OrderResult{
+			OrderId:         id,
+			ShippingCost:    &pb.Money{CurrencyCode: req.GetUserCurrency()},
+			ShippingAddress: req.GetAddress(),
+		},
+		TotalPaid: &pb.Money{CurrencyCode: req.GetUserCurrency(), Units: 39, Nanos: 980000000},
+	}, nil
+}
+
+// serveAPI returns a function calling fe's API as the session sessionID.
+func serveAPI(t *testing.T, fe *frontendServer, cors corsPolicy) func(method, path, body, sessionID string) *httptest.ResponseRecorder {
+	t.Helper()
+	keys, err := apikey.FromEnv(logging.New("frontend"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := fe.apiHandler(keys, cors)
+	return func(method, path, body, sessionID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, sessionID)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r.WithContext(ctx))
+		return w
+	}
+}
+
+func decodeAPIResponse(t *testing.T, w *httptest.ResponseRecorder, wantCode int, v interface{}) {
+	t.Helper()
+	if w.Code != wantCode {
+		t.Fatalf("status = %d, want %d: %s", w.Code, wantCode, w.Body)
+	}
+	if v == nil {
+		return
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPICheckout(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	fe.checkoutSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pbv2.RegisterCheckoutServiceServer(srv, &fakeCheckout{})
+	})
+	call := serveAPI(t, fe, corsPolicy{})
+
+	var products struct{ Products []apiProduct }
+	decodeAPIResponse(t, call(http.MethodGet, "/api/v1/products?currency=EUR", "", "user-1"), http.StatusOK, &products)
+	if len(products.Products) == 0 || products.Products[0].Price.CurrencyCode != "EUR" {
+		t.Fatalf("products = %+v, want products priced in EUR", products.Products)
+	}
+
+	var cart apiCart
+	decodeAPIResponse(t, call(http.MethodPost, "/api/v1/cart/items", `{"product_id": "OLJCESPC7Z", "quantity": 2}`, "user-1"), http.StatusOK, &cart)
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 2 || cart.Items[0].Product.ID != "OLJCESPC7Z" {
+		t.Fatalf("cart items = %+v, want 2 OLJCESPC7Z", cart.Items)
+	}
+	if want := (apiMoney{CurrencyCode: "USD", Units: 48, Nanos: 970000000}); *cart.Total != want {
+		t.Errorf("cart total = %+v, want %+v", *cart.Total, want)
+	}
+
+	checkout := `{
+		"email": "someone@example.com",
+		"address": {"street_address": "1600 Amphitheatre Parkway", "city": "Mountain View", "state": "CA", "country": "United States", "zip_code": 94043},
+		"credit_card": {"number": "4432801561520454", "expiration_month": 1, "expiration_year": 2030, "cvv": 672}
+	}`
+	var placed apiOrder
+	w := call(http.MethodPost, "/api/v1/checkout", checkout, "user-1")
+	decodeAPIResponse(t, w, http.StatusCreated, &placed)
+	if placed.ID == "" || placed.TotalPaid == nil || w.Header().Get("Location") != "/api/v1/orders/"+placed.ID {
+		t.Errorf("placed order %+v at %q, want an ID, total and location", placed, w.Header().Get("Location"))
+	}
+
+	var page apiOrderPage
+	decodeAPIResponse(t, call(http.MethodGet, "/api/v1/orders", "", "user-1"), http.StatusOK, &page)
+	if len(page.Orders) != 1 || page.Orders[0].ID != placed.ID {
+		t.Errorf("orders = %+v, want the placed order", page.Orders)
+	}
+	var order apiOrder
+	decodeAPIResponse(t, call(http.MethodGet, "/api/v1/orders/"+placed.ID, "", "user-1"), http.StatusOK, &order)
+	if order.ShippingAddress == nil || order.ShippingAddress.City != "Mountain View" {
+		t.Errorf("order = %+v, want it shipped to Mountain View", order)
+	}
+	decodeAPIResponse(t, call(http.MethodGet, "/api/v1/orders/"+placed.ID, "", "user-2"), http.StatusNotFound, nil)
+
+	decodeAPIResponse(t, call(http.MethodDelete, "/api/v1/cart", "", "user-1"), http.StatusNoContent, nil)
+	decodeAPIResponse(t, call(http.MethodGet, "/api/v1/cart", "", "user-1"), http.StatusOK, &cart)
+	if len(cart.Items) != 0 {
+		t.Errorf("cart items after emptying = %+v, want none", cart.Items)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	call := serveAPI(t, fe, corsPolicy{})
+
+	for _, tt := range []struct {
+		name, method, path, body string
+		wantCode                 int
+		wantReason               string
+	}{
+		{"unknown route", http.MethodGet, "/api/v1/nothing", "", http.StatusNotFound, "NotFound"},
+		{"wrong method", http.MethodPut, "/api/v1/cart", "", http.StatusMethodNotAllowed, "Unimplemented"},
+		{"unknown product", http.MethodGet, "/api/v1/products/nope", "", http.StatusNotFound, "NotFound"},
+		{"unsupported currency", http.MethodGet, "/api/v1/products?currency=XXX", "", http.StatusBadRequest, "InvalidArgument"},
+		{"malformed body", http.MethodPost, "/api/v1/cart/items", `{"product_id":`, http.StatusBadRequest, "InvalidArgument"},
+		{"invalid quantity", http.MethodPost, "/api/v1/cart/items", `{"product_id": "OLJCESPC7Z", "quantity": 0}`, http.StatusUnprocessableEntity, "InvalidArgument"},
+		{"not JSON", http.MethodPost, "/api/v1/checkout", "", http.StatusUnsupportedMediaType, "InvalidArgument"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct{ Error apiErrorBody }
+			decodeAPIResponse(t, call(tt.method, tt.path, tt.body, "user-1"), tt.wantCode, &body)
+			if body.Error.Status != tt.wantCode || body.Error.Reason != tt.wantReason || body.Error.Message == "" {
+				t.Errorf("error = %+v, want status %d and reason %s", body.Error, tt.wantCode, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	cors, err := parseCORSOrigins("https://app.example.com, https://admin.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := withCORS(cors, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		origin, wantOrigin string
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://admin.example.com", "https://admin.example.com"},
+		{"https://evil.example.com", ""},
+	} {
+		r := httptest.NewRequest(http.MethodOptions, "/api/v1/checkout", nil)
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("preflight from %s = %d, want 204", tt.origin, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("preflight from %s allowed origin %q, want %q", tt.origin, got, tt.wantOrigin)
+		}
+		if tt.wantOrigin != "" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("preflight from %s does not allow credentials", tt.origin)
+		}
+	}
+
+	h = withCORS(corsPolicy{any: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("any origin got headers %v, want * without credentials", w.Header())
+	}
+
+	if _, err := parseCORSOrigins("app.example.com"); err == nil {
+		t.Error("parseCORSOrigins accepted an origin without a scheme")
+	}
+}
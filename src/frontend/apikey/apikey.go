@@ -0,0 +1,357 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apikey issues, revokes and checks the API keys of the frontend's
+// JSON endpoints, the only ones meant for clients other than the storefront's
+// own pages.
+//
+// Every key has scopes, naming the endpoints it may call, and its own rate
+// limit. Keys are tokens of the form "obk_<id>_<secret>"; only the SHA-256 hash
+// of the secret is kept, in API_KEYS_FILE when it is set and in memory
+// otherwise. Requests are counted per key and outcome in the
+// frontend.api_key.requests metric.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+const (
+	// tokenPrefix starts every key, so that leaked keys are easy to scan for.
+	tokenPrefix = "obk_"
+
+	// DefaultRate and DefaultBurst limit keys issued without a rate limit,
+	// in requests per second.
+	DefaultRate  = 10
+	DefaultBurst = 20
+
+	// ScopeAll grants every scope.
+	ScopeAll = "*"
+)
+
+var (
+	// ErrUnknownKey is returned for keys that were never issued or were
+	// revoked.
+	ErrUnknownKey = errors.New("unknown API key")
+	// ErrMissingScope is returned for keys without the scope an endpoint
+	// requires.
+	ErrMissingScope = errors.New("API key lacks the required scope")
+	// ErrRateLimited is returned for keys over their rate limit.
+	ErrRateLimited = errors.New("API key rate limit exceeded")
+)
+
+// Key describes an issued API key.
+type Key struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Rate is the sustained number of requests per second allowed, and
+	// Burst how many can be made at once.
+	Rate    float64    `json:"rate"`
+	Burst   int        `json:"burst"`
+	Created time.Time  `json:"created"`
+	Revoked *time.Time `json:"revoked,omitempty"`
+	// Hash is the hex-encoded SHA-256 hash of the key's secret.
+	Hash string `json:"hash"`
+}
+
+// Usage is a key along with how it was used since the process started.
+type Usage struct {
+	Key
+	Allowed     int64      `json:"allowed"`
+	RateLimited int64      `json:"rate_limited"`
+	Forbidden   int64      `json:"forbidden"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+}
+
+type entry struct {
+	key     Key
+	limiter *rate.Limiter
+
+	allowed, rateLimited, forbidden int64
+	lastUsed                        time.Time
+}
+
+// Manager holds the issued keys. Its methods are safe for concurrent use.
+type Manager struct {
+	log  *logging.Logger
+	path string
+
+	// Required makes Middleware reject requests without a key, except
+	// those Storefront reports as made by the storefront's own pages.
+	Required   bool
+	Storefront func(*http.Request) bool
+
+	requests metric.Int64Counter
+
+	mu   sync.Mutex
+	keys map[string]*entry
+}
+
+// FromEnv returns a Manager storing keys in API_KEYS_FILE, or in memory when
+// it is unset. API_KEYS_REQUIRED=1 makes keys required.
+func FromEnv(log *logging.Logger) (*Manager, error) {
+	m, err := Open(os.Getenv("API_KEYS_FILE"), log)
+	if err != nil {
+		return nil, err
+	}
+	m.Required = os.Getenv("API_KEYS_REQUIRED") == "1"
+	return m, nil
+}
+
+// Open returns a Manager storing keys in the file at path, loading any it
+// already holds, or in memory if path is empty.
+func Open(path string, log *logging.Logger) (*Manager, error) {
+	m := &Manager{log: log, path: path, keys: make(map[string]*entry)}
+	m.requests, _ = otel.Meter("frontend").Int64Counter(
+		"frontend.api_key.requests",
+		metric.WithDescription("Requests to the JSON endpoints, by API key and outcome."),
+		metric.WithUnit("{request}"))
+	if path == "" {
+		return m, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	var keys []Key
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys in %s: %w", path, err)
+	}
+	for _, k := range keys {
+		m.keys[k.ID] = newEntry(k)
+	}
+	log.Infof("loaded %d API keys from %s", len(keys), path)
+	return m, nil
+}
+
+func newEntry(k Key) *entry {
+	return &entry{key: k, limiter: rate.NewLimiter(rate.Limit(k.Rate), k.Burst)}
+}
+
+// Issue creates a key named name with scopes and a rate limit, which default
+// to DefaultRate and DefaultBurst when zero, and returns it with its token.
+// The token is not stored and cannot be recovered.
+func (m *Manager) Issue(name string, scopes []string, limit float64, burst int) (Key, string, error) {
+	if name == "" {
+		return Key{}, "", errors.New("API keys must have a name")
+	}
+	if len(scopes) == 0 {
+		return Key{}, "", errors.New("API keys must have at least one scope")
+	}
+	if limit < 0 || burst < 0 {
+		return Key{}, "", errors.New("rate limits must not be negative")
+	}
+	if limit == 0 {
+		limit = DefaultRate
+	}
+	if burst == 0 {
+		burst = DefaultBurst
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return Key{}, "", err
+	}
+	k := Key{
+		ID:      id,
+		Name:    name,
+		Scopes:  slices.Clone(scopes),
+		Rate:    limit,
+		Burst:   burst,
+		Created: time.Now().UTC(),
+		Hash:    hashSecret(secret),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[id] = newEntry(k)
+	if err := m.saveLocked(); err != nil {
+		delete(m.keys, id)
+		return Key{}, "", err
+	}
+	return k, tokenPrefix + id + "_" + secret, nil
+}
+
+// Revoke revokes the key with the given ID. Revoked keys are kept, so that
+// their usage can still be looked up.
+func (m *Manager) Revoke(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.keys[id]
+	if !ok || e.key.Revoked != nil {
+		return ErrUnknownKey
+	}
+	now := time.Now().UTC()
+	e.key.Revoked = &now
+	if err := m.saveLocked(); err != nil {
+		e.key.Revoked = nil
+		return err
+	}
+	return nil
+}
+
+// Usage returns every key, revoked ones included, with its usage, oldest
+// first.
+func (m *Manager) Usage() []Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := make([]Usage, 0, len(m.keys))
+	for _, e := range m.keys {
+		u := Usage{Key: e.key, Allowed: e.allowed, RateLimited: e.rateLimited, Forbidden: e.forbidden}
+		if !e.lastUsed.IsZero() {
+			t := e.lastUsed
+			u.LastUsed = &t
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Created.Before(usage[j].Created) })
+	return usage
+}
+
+// Check authorizes a request made with token for scope, and returns the key's
+// ID. It fails with ErrUnknownKey, ErrMissingScope or ErrRateLimited.
+func (m *Manager) Check(ctx context.Context, token, scope string) (string, error) {
+	id, secret, ok := parseToken(token)
+	if !ok {
+		m.count(ctx, "", "unauthenticated")
+		return "", ErrUnknownKey
+	}
+	m.mu.Lock()
+	e, ok := m.keys[id]
+	if !ok || e.key.Revoked != nil || subtle.ConstantTimeCompare([]byte(e.key.Hash), []byte(hashSecret(secret))) != 1 {
+		m.mu.Unlock()
+		m.count(ctx, "", "unauthenticated")
+		return "", ErrUnknownKey
+	}
+	e.lastUsed = time.Now().UTC()
+	var err error
+	switch {
+	case !hasScope(e.key.Scopes, scope):
+		e.forbidden++
+		err = ErrMissingScope
+	case !e.limiter.Allow():
+		e.rateLimited++
+		err = ErrRateLimited
+	default:
+		e.allowed++
+	}
+	m.mu.Unlock()
+
+	outcome := "allowed"
+	switch err {
+	case ErrMissingScope:
+		outcome = "forbidden"
+	case ErrRateLimited:
+		outcome = "rate_limited"
+	}
+	m.count(ctx, id, outcome)
+	return id, err
+}
+
+// count records a request, with id empty for requests with an invalid key.
+func (m *Manager) count(ctx context.Context, id, outcome string) {
+	if m.requests == nil {
+		return
+	}
+	m.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("api_key.id", id),
+		attribute.String("outcome", outcome)))
+}
+
+// saveLocked writes the keys to the file, if any, replacing it atomically.
+func (m *Manager) saveLocked() error {
+	if m.path == "" {
+		return nil
+	}
+	keys := make([]Key, 0, len(m.keys))
+	for _, e := range m.keys {
+		keys = append(keys, e.key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".apikeys-*")
+	if err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+	return nil
+}
+
+func parseToken(token string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	return id, secret, ok && id != "" && secret != ""
+}
+
+func hasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope) || slices.Contains(scopes, ScopeAll)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+var testLog = logging.New("testservice")
+
+func open(t *testing.T, path string) *Manager {
+	t.Helper()
+	m, err := Open(path, testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCheck(t *testing.T) {
+	m := open(t, "")
+	k, token, err := m.Issue("partner", []string{"catalog.read"}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if id, err := m.Check(ctx, token, "catalog.read"); err != nil || id != k.ID {
+			t.Fatalf("Check() = %q, %v, want %q, nil", id, err, k.ID)
+		}
+	}
+	if _, err := m.Check(ctx, token, "catalog.read"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Check() over the burst = %v, want ErrRateLimited", err)
+	}
+	if _, err := m.Check(ctx, token, "assistant"); !errors.Is(err, ErrMissingScope) {
+		t.Errorf("Check() for another scope = %v, want ErrMissingScope", err)
+	}
+	if _, err := m.Check(ctx, token+"0", "catalog.read"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Check() with a wrong secret = %v, want ErrUnknownKey", err)
+	}
+	if _, err := m.Check(ctx, "not-a-key", "catalog.read"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Check() with garbage = %v, want ErrUnknownKey", err)
+	}
+
+	if err := m.Revoke(k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Check(ctx, token, "catalog.read"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Check() with a revoked key = %v, want ErrUnknownKey", err)
+	}
+	if err := m.Revoke(k.ID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Revoke() twice = %v, want ErrUnknownKey", err)
+	}
+
+	u := m.Usage()
+	if len(u) != 1 || u[0].Allowed != 2 || u[0].RateLimited != 1 || u[0].Forbidden != 1 || u[0].Revoked == nil {
+		t.Errorf("Usage() = %+v, want 2 allowed, 1 rate limited and 1 forbidden request on a revoked key", u)
+	}
+}
+
+func TestKeysPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	m := open(t, path)
+	_, token, err := m.Issue("partner", []string{ScopeAll}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, _, err := m.Issue("former partner", []string{ScopeAll}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Revoke(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := open(t, path)
+	if _, err := reopened.Check(context.Background(), token, "assistant"); err != nil {
+		t.Errorf("Check() after reopening = %v, want nil", err)
+	}
+	u := reopened.Usage()
+	if len(u) != 2 || u[0].Rate != DefaultRate || u[0].Burst != DefaultBurst || u[1].Revoked == nil {
+		t.Errorf("Usage() after reopening = %+v, want both keys with default limits, the second revoked", u)
+	}
+	for _, k := range u {
+		if strings.Contains(token, k.Hash) {
+			t.Error("the token is stored in clear")
+		}
+	}
+}
+
+func TestIssueValidates(t *testing.T) {
+	m := open(t, "")
+	if _, _, err := m.Issue("", []string{ScopeAll}, 0, 0); err == nil {
+		t.Error("Issue() without a name succeeded")
+	}
+	if _, _, err := m.Issue("partner", nil, 0, 0); err == nil {
+		t.Error("Issue() without scopes succeeded")
+	}
+	if _, _, err := m.Issue("partner", []string{ScopeAll}, -1, 0); err == nil {
+		t.Error("Issue() with a negative rate succeeded")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := open(t, "")
+	_, token, err := m.Issue("partner", []string{"catalog.read"}, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Storefront = func(r *http.Request) bool { return r.Header.Get("Cookie") != "" }
+	h := m.Middleware("catalog.read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		name     string
+		required bool
+		header   http.Header
+		want     int
+	}{
+		{"anonymous", false, nil, http.StatusOK},
+		{"anonymous when required", true, nil, http.StatusUnauthorized},
+		{"storefront when required", true, http.Header{"Cookie": {"session=1"}}, http.StatusOK},
+		{"key", true, http.Header{"Authorization": {"Bearer " + token}}, http.StatusOK},
+		{"rate limited key", true, http.Header{HeaderName: {token}}, http.StatusTooManyRequests},
+		{"unknown key", false, http.Header{HeaderName: {"obk_0_0"}}, http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m.Required = tt.required
+			req := httptest.NewRequest(http.MethodGet, "/product-meta/1", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v[0])
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareFunc(t *testing.T) {
+	m := open(t, "")
+	m.Required = true
+	var rejected int
+	reject := func(w http.ResponseWriter, _ *http.Request, code int, msg string) {
+		rejected = code
+		w.WriteHeader(code)
+	}
+	h := m.MiddlewareFunc("cart", reject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cart", nil))
+	if rejected != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("rejected with %d and WWW-Authenticate %q, want 401 and Bearer", rejected, rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := open(t, "")
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"name": "partner", "scopes": ["assistant"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issued issueResponse
+	json.NewDecoder(resp.Body).Decode(&issued)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || !strings.HasPrefix(issued.Token, tokenPrefix) {
+		t.Fatalf("POST = %d %+v, want 201 with a token", resp.StatusCode, issued)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"?id="+issued.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var usage []Usage
+	json.NewDecoder(resp.Body).Decode(&usage)
+	resp.Body.Close()
+	if len(usage) != 1 || usage[0].Revoked == nil {
+		t.Errorf("GET = %+v, want the revoked key", usage)
+	}
+}
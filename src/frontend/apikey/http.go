@@ -0,0 +1,159 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HeaderName is the header carrying keys, which can also be sent as a bearer
+// token in the Authorization header.
+const HeaderName = "X-API-Key"
+
+// token returns the key sent with r, if any.
+func token(r *http.Request) string {
+	if t := r.Header.Get(HeaderName); t != "" {
+		return t
+	}
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return t
+	}
+	return ""
+}
+
+// RejectFunc answers a request Middleware rejected with the status code and
+// the reason it was rejected for.
+type RejectFunc func(w http.ResponseWriter, r *http.Request, code int, msg string)
+
+// textReject answers rejected requests with a plain text body.
+func textReject(w http.ResponseWriter, _ *http.Request, code int, msg string) {
+	http.Error(w, msg, code)
+}
+
+// Middleware authorizes the requests to next for scope: requests with a key
+// must be allowed by Check, and requests without one are only let through
+// unless keys are Required. Rejected requests get a 401, 403 or 429 status.
+func (m *Manager) Middleware(scope string) func(http.Handler) http.Handler {
+	return m.MiddlewareFunc(scope, textReject)
+}
+
+// MiddlewareFunc is Middleware answering rejected requests with reject, once
+// their WWW-Authenticate or Retry-After header is set.
+func (m *Manager) MiddlewareFunc(scope string, reject RejectFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := token(r)
+			if t == "" {
+				if m.Required && (m.Storefront == nil || !m.Storefront(r)) {
+					m.count(r.Context(), "", "unauthenticated")
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					reject(w, r, http.StatusUnauthorized, "an API key is required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, err := m.Check(r.Context(), t, scope)
+			switch {
+			case errors.Is(err, ErrUnknownKey):
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				reject(w, r, http.StatusUnauthorized, err.Error())
+			case errors.Is(err, ErrMissingScope):
+				reject(w, r, http.StatusForbidden, err.Error()+" "+strconv.Quote(scope))
+			case errors.Is(err, ErrRateLimited):
+				w.Header().Set("Retry-After", strconv.Itoa(m.retryAfter(id)))
+				reject(w, r, http.StatusTooManyRequests, err.Error())
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// retryAfter returns how many seconds a rate limited key should wait.
+func (m *Manager) retryAfter(id string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.keys[id]
+	if !ok || e.key.Rate <= 0 {
+		return 1
+	}
+	return max(1, int(math.Ceil(1/e.key.Rate)))
+}
+
+// issueRequest is the body of key creation requests.
+type issueRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Rate   float64  `json:"rate"`
+	Burst  int      `json:"burst"`
+}
+
+// issueResponse returns a created key with its token.
+type issueResponse struct {
+	Key
+	Token string `json:"token"`
+}
+
+// Handler serves the admin API: GET lists the keys with their usage, POST
+// issues a key from a JSON body with its name, scopes, rate and burst and
+// returns it with its token, and DELETE revokes the key whose ID is the id
+// parameter.
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, m.Usage())
+		case http.MethodPost:
+			var req issueRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			k, t, err := m.Issue(req.Name, req.Scopes, req.Rate, req.Burst)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			m.log.WithContext(r.Context()).Infof("issued API key %s (%s)", k.ID, k.Name)
+			writeJSON(w, http.StatusCreated, issueResponse{Key: k, Token: t})
+		case http.MethodDelete:
+			id := r.URL.Query().Get("id")
+			if err := m.Revoke(id); errors.Is(err, ErrUnknownKey) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			m.log.WithContext(r.Context()).Infof("revoked API key %s", id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
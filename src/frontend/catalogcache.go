@@ -0,0 +1,222 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/singleflight"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+// Nearly every page lists the catalog and converts the prices of the products
+// it shows. catalogCache keeps each listing it fetched from
+// productcatalogservice, and each price it converted, for CATALOG_CACHE_TTL
+// (default 1m), and the requests for a listing or price that is not cached
+// share a single call rather than making one each. Failed calls are not
+// cached. POST /debug/catalog/invalidate on the debug port empties the
+// cache, so that a reloaded catalog shows up at once. CATALOG_CACHE_TTL=0
+// turns it off.
+
+const (
+	defaultCatalogCacheTTL = time.Minute
+	// maxCatalogCacheEntries bounds the memory each kind of entry takes, as
+	// the pages listed and currencies converted to come from the shoppers.
+	maxCatalogCacheEntries = 1000
+	// catalogFetchTimeout bounds the calls requests share, which outlive
+	// any one of them.
+	catalogFetchTimeout = 3 * time.Second
+)
+
+// catalogCache caches catalog listings and converted prices. A nil
+// catalogCache makes a call for every listing and price.
+type catalogCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	listings map[listingKey]cacheEntry[listing]
+	prices   map[priceKey]cacheEntry[*pb.Money]
+	// generation counts the invalidations, so that calls in flight when the
+	// cache is emptied do not cache what they fetched from before.
+	generation uint64
+
+	calls   singleflight.Group
+	lookups metric.Int64Counter
+}
+
+// listingKey identifies a page of the catalog. The zero listingKey is the
+// whole catalog.
+type listingKey struct {
+	page, size int
+	sort       pb.ProductSort
+}
+
+// listing is a page of the catalog, and the number of products across all
+// pages.
+type listing struct {
+	products []*pb.Product
+	total    int
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// newCatalogCacheFromEnv returns the cache set up by CATALOG_CACHE_TTL, or
+// nil if it is turned off.
+func newCatalogCacheFromEnv() (*catalogCache, error) {
+	c := newCatalogCache()
+	if v := os.Getenv("CATALOG_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid CATALOG_CACHE_TTL %q", v)
+		}
+		if d == 0 {
+			return nil, nil
+		}
+		c.ttl = d
+	}
+	var err error
+	c.lookups, err = otel.Meter("frontend").Int64Counter(
+		"frontend.catalog.cache.lookups",
+		metric.WithDescription("Lookups of the catalog cache, by kind, listing or price, and result, hit or miss."),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newCatalogCache() *catalogCache {
+	return &catalogCache{
+		ttl:      defaultCatalogCacheTTL,
+		now:      time.Now,
+		listings: make(map[listingKey]cacheEntry[listing]),
+		prices:   make(map[priceKey]cacheEntry[*pb.Money]),
+	}
+}
+
+// String describes the cache for startup logs.
+func (c *catalogCache) String() string {
+	if c == nil {
+		return "off"
+	}
+	return fmt.Sprintf("TTL %s", c.ttl)
+}
+
+// listing returns the page of the catalog key identifies, calling fetch to
+// list it unless it is cached.
+func (c *catalogCache) listing(ctx context.Context, key listingKey, fetch func(ctx context.Context) (listing, error)) (listing, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+	return cached(ctx, c, "listing", c.listings, key, fetch)
+}
+
+// price returns from converted to currency, calling convert to convert it
+// unless it is cached.
+func (c *catalogCache) price(ctx context.Context, from *pb.Money, currency string,
+	convert func(ctx context.Context, from *pb.Money, currency string) (*pb.Money, error)) (*pb.Money, error) {
+
+	if c == nil {
+		return convert(ctx, from, currency)
+	}
+	key := priceKey{currencyCode: from.GetCurrencyCode(), units: from.GetUnits(), nanos: from.GetNanos(), to: currency}
+	return cached(ctx, c, "price", c.prices, key, func(ctx context.Context) (*pb.Money, error) {
+		return convert(ctx, from, currency)
+	})
+}
+
+// cached returns the value of key in entries unless it has expired, and calls
+// fetch to find and cache it otherwise, sharing the call with the other
+// lookups of key until it returns.
+func cached[K comparable, V any](ctx context.Context, c *catalogCache, kind string, entries map[K]cacheEntry[V], key K, fetch func(ctx context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+	e, ok := entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	result := "hit"
+	if !ok || !c.now().Before(e.expires) {
+		result = "miss"
+	}
+	if c.lookups != nil {
+		c.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("kind", kind), attribute.String("result", result)))
+	}
+	if result == "hit" {
+		return e.value, nil
+	}
+
+	v, err, _ := c.calls.Do(fmt.Sprintf("%s %d %v", kind, generation, key), func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), catalogFetchTimeout)
+		defer cancel()
+		v, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation == generation {
+			if len(entries) >= maxCatalogCacheEntries {
+				clear(entries)
+			}
+			entries[key] = cacheEntry[V]{value: v, expires: c.now().Add(c.ttl)}
+		}
+		return v, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return v.(V), nil
+}
+
+// invalidate empties the cache.
+func (c *catalogCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.listings)
+	clear(c.prices)
+	c.generation++
+}
+
+// Handler returns the debug endpoint emptying the cache on POST.
+func (c *catalogCache) Handler(log *logging.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		c.invalidate()
+		log.WithContext(r.Context()).Info("catalog cache invalidated")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+func TestCatalogCacheListings(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	catalog := &countingCatalog{ProductCatalog: fakes.NewProductCatalog()}
+	fe.productCatalogSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pb.RegisterProductCatalogServiceServer(srv, catalog)
+	})
+	now := time.Now()
+	fe.catalog = newCatalogCache()
+	fe.catalog.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for range 3 {
+		if products, err := fe.getProducts(ctx); err != nil || len(products) != 3 {
+			t.Fatalf("getProducts() = %d products, %v, want 3", len(products), err)
+		}
+	}
+	if products, total, err := fe.listProducts(ctx, 2, 2, pb.ProductSort_PRODUCT_SORT_NAME); err != nil || len(products) != 1 || total != 3 {
+		t.Fatalf("listProducts(page 2 of 2) = %d products of %d, %v, want 1 of 3", len(products), total, err)
+	}
+	if n := catalog.lists.Load(); n != 2 {
+		t.Errorf("%d ListProducts calls for two listings, want 2", n)
+	}
+
+	now = now.Add(fe.catalog.ttl)
+	fe.getProducts(ctx)
+	if n := catalog.lists.Load(); n != 3 {
+		t.Errorf("%d ListProducts calls after the TTL, want 3", n)
+	}
+
+	catalog.Products = catalog.Products[:1]
+	fe.catalog.invalidate()
+	if products, _ := fe.getProducts(ctx); len(products) != 1 {
+		t.Errorf("getProducts() after invalidating = %d products, want the reloaded catalog's 1", len(products))
+	}
+}
+
+func TestCatalogCachePrices(t *testing.T) {
+	var conversions atomic.Int32
+	convert := func(_ context.Context, from *pb.Money, currency string) (*pb.Money, error) {
+		conversions.Add(1)
+		if currency == "XXX" {
+			return nil, errors.New("unsupported currency")
+		}
+		return &pb.Money{CurrencyCode: currency, Units: from.GetUnits() * 2, Nanos: from.GetNanos()}, nil
+	}
+	c := newCatalogCache()
+	ctx := context.Background()
+	usd := &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000}
+
+	for range 3 {
+		if m, err := c.price(ctx, usd, "EUR", convert); err != nil || m.GetUnits() != 38 || m.GetCurrencyCode() != "EUR" {
+			t.Fatalf("price() = %v, %v, want 38.99 EUR", m, err)
+		}
+	}
+	c.price(ctx, usd, "JPY", convert)
+	c.price(ctx, &pb.Money{CurrencyCode: "USD", Units: 5}, "EUR", convert)
+	if n := conversions.Load(); n != 3 {
+		t.Errorf("%d conversions of two prices to two currencies, want 3", n)
+	}
+	for range 2 {
+		if _, err := c.price(ctx, usd, "XXX", convert); err == nil {
+			t.Error("price() in an unsupported currency succeeded")
+		}
+	}
+	if n := conversions.Load(); n != 5 {
+		t.Errorf("%d conversions, want failed ones retried", n)
+	}
+}
+
+func TestCatalogCacheSharesCalls(t *testing.T) {
+	c := newCatalogCache()
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	fetch := func(context.Context) (listing, error) {
+		fetches.Add(1)
+		<-release
+		return listing{products: fakes.Products(), total: 3}, nil
+	}
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l, err := c.listing(context.Background(), listingKey{}, fetch); err != nil || l.total != 3 {
+				t.Errorf("listing() = %+v, %v, want 3 products", l, err)
+			}
+		}()
+	}
+	// let the requests line up behind the first call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("%d calls for concurrent requests, want 1", n)
+	}
+}
+
+func TestCatalogCacheInvalidateInFlight(t *testing.T) {
+	c := newCatalogCache()
+	started, release := make(chan struct{}), make(chan struct{})
+	stale := func(context.Context) (listing, error) {
+		close(started)
+		<-release
+		return listing{total: 9}, nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.listing(context.Background(), listingKey{}, stale)
+	}()
+	<-started
+	c.invalidate()
+	close(release)
+	<-done
+
+	fresh := func(context.Context) (listing, error) { return listing{total: 3}, nil }
+	if l, _ := c.listing(context.Background(), listingKey{}, fresh); l.total != 3 {
+		t.Errorf("listing() after invalidating = %+v, want the one fetched after, not the stale one in flight", l)
+	}
+}
+
+func TestCatalogCacheHandler(t *testing.T) {
+	c := newCatalogCache()
+	fetches := 0
+	fetch := func(context.Context) (listing, error) {
+		fetches++
+		return listing{}, nil
+	}
+	c.listing(context.Background(), listingKey{}, fetch)
+	h := c.Handler(logging.New("frontend"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/catalog/invalidate", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/catalog/invalidate", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("POST = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	c.listing(context.Background(), listingKey{}, fetch)
+	if fetches != 2 {
+		t.Errorf("%d fetches, want the listing fetched again after invalidating", fetches)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/ratelimit"
+)
+
+// validateConfig checks the service's environment before it starts.
+func validateConfig() error {
+	c := configcheck.New("frontend")
+	c.Common()
+	c.Port("PORT", port)
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		c.Port("DEBUG_PORT", instrumentation.DefaultDebugPort)
+	}
+	for _, key := range []string{
+		"PRODUCT_CATALOG_SERVICE_ADDR",
+		"CURRENCY_SERVICE_ADDR",
+		"CART_SERVICE_ADDR",
+		"RECOMMENDATION_SERVICE_ADDR",
+		"CHECKOUT_SERVICE_ADDR",
+		"SHIPPING_SERVICE_ADDR",
+		"AD_SERVICE_ADDR",
+		"SHOPPING_ASSISTANT_SERVICE_ADDR",
+	} {
+		c.Addr(key, true)
+	}
+	c.Addr("NOTIFICATION_SERVICE_ADDR", false)
+	c.Addr("ACCOUNTS_REDIS_ADDR", false)
+	c.Addr("WISHLIST_REDIS_ADDR", false)
+	c.Addr("PREFERENCES_REDIS_ADDR", false)
+	c.URL("PACKAGING_SERVICE_URL")
+	c.Int("EXPERIMENT_BUCKETS", 1)
+	c.Duration("SUGGEST_CACHE_TTL", 0)
+	c.Duration("CATALOG_CACHE_TTL", 0)
+	c.Float("RATE_LIMIT_BROWSE", 0)
+	c.Int("RATE_LIMIT_BROWSE_BURST", 1)
+	c.Float("RATE_LIMIT_CHECKOUT", 0)
+	c.Int("RATE_LIMIT_CHECKOUT_BURST", 1)
+	if _, err := ratelimit.ParseProxies(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES")); err != nil {
+		c.Problemf("RATE_LIMIT_TRUSTED_PROXIES", "%v", err)
+	}
+	c.OneOf("API_KEYS_REQUIRED", "0", "1")
+	if _, err := parseCORSOrigins(os.Getenv("API_CORS_ORIGINS")); err != nil {
+		c.Problemf("API_CORS_ORIGINS", "%v", err)
+	}
+	if v := os.Getenv("BASE_URL"); v != "" && !strings.HasPrefix(v, "/") {
+		c.Problemf("BASE_URL", "%q must start with /", v)
+	}
+	return c.Err()
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configcheck
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	t.Setenv("SHIPPING_SERVICE_ADDR", "shippingservice:50051")
+	t.Setenv("CART_SERVICE_ADDR", "cartservice")
+	t.Setenv("PORT", "8080")
+	t.Setenv("METRICS_PORT", "8080")
+	t.Setenv("POLL_INTERVAL", "soon")
+	t.Setenv("MAX_ATTEMPTS", "0")
+	t.Setenv("CPU_THRESHOLD", "0")
+	t.Setenv("LOG_LEVEL", "INFO")
+	t.Setenv("DB_DSN", "host=db port=5432 sslmode=disable")
+	t.Setenv("BAD_DSN", "postgres://user:hunter2@/orders")
+	t.Setenv("DB_PASSWORD_SECRET", "vault://secret/data/orders")
+
+	c := New("testservice")
+	c.Addr("SHIPPING_SERVICE_ADDR", true)
+	c.Addr("CART_SERVICE_ADDR", true)
+	c.Addr("EMAIL_SERVICE_ADDR", true)
+	c.Addr("NOTIFICATION_SERVICE_ADDR", false)
+	c.Port("PORT", "5050")
+	c.Port("METRICS_PORT", "9464")
+	c.Duration("POLL_INTERVAL", time.Second)
+	c.Int("MAX_ATTEMPTS", 1)
+	c.Float("CPU_THRESHOLD", 0)
+	c.OneOf("LOG_LEVEL", "debug", "info")
+	c.DSN("DB_DSN")
+	c.DSN("BAD_DSN")
+	c.SecretRef("DB_PASSWORD_SECRET")
+
+	var cerr *Error
+	if err := c.Err(); !errors.As(err, &cerr) {
+		t.Fatalf("Err() = %v, want *Error", err)
+	}
+	want := []string{
+		"CART_SERVICE_ADDR:",
+		"EMAIL_SERVICE_ADDR: required",
+		"METRICS_PORT: port 8080 is already used by PORT",
+		"POLL_INTERVAL:",
+		"MAX_ATTEMPTS:",
+		"CPU_THRESHOLD:",
+		"BAD_DSN: URL has no host",
+		"DB_PASSWORD_SECRET:",
+	}
+	if len(cerr.Problems) != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", len(cerr.Problems), len(want), cerr)
+	}
+	for i, p := range cerr.Problems {
+		if i < len(want) && !strings.HasPrefix(p, want[i]) {
+			t.Errorf("problem %d = %q, want prefix %q", i, p, want[i])
+		}
+	}
+	if strings.Contains(cerr.Error(), "hunter2") {
+		t.Errorf("report leaks a password: %v", cerr)
+	}
+}
+
+func TestCheckerValid(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("MTLS_MODE", "")
+	c := New("testservice")
+	c.Port("PORT", "5050")
+	c.Port("METRICS_PORT", "9464")
+	c.Common()
+	if err := c.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	t.Setenv("CONFIG_VALIDATION", "")
+	if !FailFast() {
+		t.Error("FailFast() = false by default")
+	}
+	t.Setenv("CONFIG_VALIDATION", "warn")
+	if FailFast() {
+		t.Error("FailFast() = true with CONFIG_VALIDATION=warn")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contract_test
+
+import (
+	"os"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// run runs suite against the fake, and against the running service at the
+// address in addrEnv if it is set, such as CONTRACT_CART_ADDR=localhost:7070.
+func run(t *testing.T, addrEnv string, register func(*grpc.Server), suite func(*testing.T, *grpc.ClientConn)) {
+	t.Run("fake", func(t *testing.T) { suite(t, contract.Serve(t, register)) })
+	t.Run("live", func(t *testing.T) {
+		addr := os.Getenv(addrEnv)
+		if addr == "" {
+			t.Skipf("%s not set", addrEnv)
+		}
+		suite(t, contract.Dial(t, addr))
+	})
+}
+
+func TestProductCatalog(t *testing.T) {
+	run(t, "CONTRACT_PRODUCT_CATALOG_ADDR", fakes.NewProductCatalog().Register, func(t *testing.T, conn *grpc.ClientConn) {
+		contract.ProductCatalog(t, pb.NewProductCatalogServiceClient(conn))
+	})
+}
+
+func TestCart(t *testing.T) {
+	run(t, "CONTRACT_CART_ADDR", fakes.NewCart().Register, func(t *testing.T, conn *grpc.ClientConn) {
+		contract.Cart(t, pb.NewCartServiceClient(conn))
+	})
+}
+
+func TestCurrency(t *testing.T) {
+	run(t, "CONTRACT_CURRENCY_ADDR", fakes.NewCurrency(nil).Register, func(t *testing.T, conn *grpc.ClientConn) {
+		contract.Currency(t, pb.NewCurrencyServiceClient(conn))
+	})
+}
+
+func TestShipping(t *testing.T) {
+	run(t, "CONTRACT_SHIPPING_ADDR", fakes.NewShipping().Register, func(t *testing.T, conn *grpc.ClientConn) {
+		contract.Shipping(t, pb.NewShippingServiceClient(conn))
+	})
+}
+
+func TestPayment(t *testing.T) {
+	run(t, "CONTRACT_PAYMENT_ADDR", fakes.NewPayment().Register, func(t *testing.T, conn *grpc.ClientConn) {
+		contract.Payment(t, pb.NewPaymentServiceClient(conn))
+	})
+}
+
+func TestInventory(t *testing.T) {
+	run(t, "CONTRACT_INVENTORY_ADDR", fakes.NewInventory(nil).Register, func(t *testing.T, conn *grpc.ClientConn) {
+		contract.Inventory(t, pb.NewInventoryServiceClient(conn))
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csrf protects the storefront's forms against cross-site request
+// forgery.
+//
+// Every session gets a random token, which the frontend keeps in a cookie and
+// embeds in each form it renders. A form posted from another site carries the
+// shop's cookies but cannot know the token, so requests changing state are
+// only served when the token they post matches their cookie. Tokens are
+// replaced when the user signs in, so that one planted before cannot be used
+// in the signed-in session.
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// FieldName is the form field tokens are posted in.
+const FieldName = "csrf_token"
+
+// HeaderName is the header the scripts of the pages send tokens in.
+const HeaderName = "X-CSRF-Token"
+
+// ErrInvalidToken is returned for requests posted without their session's
+// token.
+var ErrInvalidToken = errors.New("missing or invalid CSRF token")
+
+// tokenLen is the number of random bytes in a token.
+const tokenLen = 32
+
+// NewToken returns a new random token.
+func NewToken() string {
+	b := make([]byte, tokenLen)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Valid reports whether token is one NewToken could have returned.
+func Valid(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == tokenLen
+}
+
+// formTypes are the content types forms post, which browsers send across
+// origins without asking the server first.
+var formTypes = map[string]bool{
+	"application/x-www-form-urlencoded": true,
+	"multipart/form-data":               true,
+	"text/plain":                        true,
+}
+
+// Protected reports whether r needs a token: requests that change state do,
+// unless their body has a type forms cannot post, such as the API's JSON,
+// which browsers only send across origins when CORS allows it.
+func Protected(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+	t, _, err := mime.ParseMediaType(ct)
+	return err != nil || formTypes[t]
+}
+
+// Check returns ErrInvalidToken unless r posts token in its FieldName form
+// field.
+func Check(r *http.Request, token string) error {
+	got := r.PostFormValue(FieldName)
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// CheckHeader returns ErrInvalidToken unless r sends token in its
+// HeaderName header.
+func CheckHeader(r *http.Request, token string) error {
+	got := r.Header.Get(HeaderName)
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// SameOrigin reports whether r was sent by a page of the site it is sent to,
+// going by the Sec-Fetch-Site header browsers send, or else by its Origin.
+func SameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	u, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying the session's token, for the
+// pages rendered with it to embed.
+func NewContext(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, token)
+}
+
+// FromContext returns the token in ctx, which is empty if there is none.
+func FromContext(ctx context.Context) string {
+	t, _ := ctx.Value(ctxKey{}).(string)
+	return t
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewToken(t *testing.T) {
+	a, b := NewToken(), NewToken()
+	if a == b {
+		t.Errorf("NewToken() returned %q twice", a)
+	}
+	if !Valid(a) {
+		t.Errorf("Valid(%q) = false, want true", a)
+	}
+	for _, token := range []string{"", "short", a + "!", a[:len(a)-2]} {
+		if Valid(token) {
+			t.Errorf("Valid(%q) = true, want false", token)
+		}
+	}
+}
+
+func TestProtected(t *testing.T) {
+	for _, tt := range []struct {
+		method, contentType string
+		want                bool
+	}{
+		{http.MethodGet, "", false},
+		{http.MethodHead, "", false},
+		{http.MethodPost, "application/x-www-form-urlencoded", true},
+		{http.MethodPost, "multipart/form-data; boundary=x", true},
+		{http.MethodPost, "text/plain;charset=UTF-8", true},
+		{http.MethodPost, "", true},
+		{http.MethodPost, "not a type;;", true},
+		{http.MethodDelete, "", true},
+		{http.MethodPost, "application/json", false},
+		{http.MethodPost, "application/grpc-web-text", false},
+	} {
+		r := httptest.NewRequest(tt.method, "/cart", nil)
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		if got := Protected(r); got != tt.want {
+			t.Errorf("Protected(%s %q) = %v, want %v", tt.method, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	token := NewToken()
+	post := func(form url.Values) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/cart/empty", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	if err := Check(post(url.Values{FieldName: {token}}), token); err != nil {
+		t.Errorf("Check() with the session's token = %v, want nil", err)
+	}
+	for name, form := range map[string]url.Values{
+		"missing":       {"product_id": {"OLJCESPC7Z"}},
+		"empty":         {FieldName: {""}},
+		"another token": {FieldName: {NewToken()}},
+	} {
+		if err := Check(post(form), token); err != ErrInvalidToken {
+			t.Errorf("Check() with %s token = %v, want ErrInvalidToken", name, err)
+		}
+	}
+	// a session without a token accepts none
+	if err := Check(post(url.Values{FieldName: {""}}), ""); err != ErrInvalidToken {
+		t.Errorf("Check() without a session token = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	token := NewToken()
+	for name, tt := range map[string]struct {
+		header string
+		want   error
+	}{
+		"session's token": {token, nil},
+		"missing":         {"", ErrInvalidToken},
+		"another token":   {NewToken(), ErrInvalidToken},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/suggest", nil)
+		if tt.header != "" {
+			r.Header.Set(HeaderName, tt.header)
+		}
+		if err := CheckHeader(r, token); err != tt.want {
+			t.Errorf("CheckHeader() with %s = %v, want %v", name, err, tt.want)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		header http.Header
+		want   bool
+	}{
+		{http.Header{"Sec-Fetch-Site": {"same-origin"}}, true},
+		{http.Header{"Sec-Fetch-Site": {"cross-site"}, "Origin": {"http://example.com"}}, false},
+		{http.Header{"Sec-Fetch-Site": {"same-site"}}, false},
+		{http.Header{"Origin": {"http://example.com"}}, true},
+		{http.Header{"Origin": {"http://evil.example"}}, false},
+		{nil, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/api/suggest", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v[0])
+		}
+		if got := SameOrigin(r); got != tt.want {
+			t.Errorf("SameOrigin(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
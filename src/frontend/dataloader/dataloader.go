@@ -0,0 +1,117 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dataloader batches and caches the lookups made while resolving a
+// request, so that resolving the N items of a list makes one downstream call
+// rather than N.
+//
+// Load queues a key and returns a thunk. Calling any thunk looks up every key
+// queued so far in one call of the loader's batch function, and each key is
+// looked up once per Loader, which should therefore live as long as a single
+// request. This matches resolvers that return thunks, which the GraphQL
+// executor only calls once it has resolved every field at the same depth.
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned for keys the batch function returned no value for.
+var ErrNotFound = errors.New("dataloader: not found")
+
+// BatchFunc looks up keys, returning the value of each key that exists.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+type result[V any] struct {
+	value V
+	err   error
+	done  bool
+}
+
+// Loader looks up keys of type K in batches. It is safe for concurrent use.
+type Loader[K comparable, V any] struct {
+	batch BatchFunc[K, V]
+
+	mu      sync.Mutex
+	pending []K
+	results map[K]*result[V]
+}
+
+// New returns a Loader looking keys up with batch.
+func New[K comparable, V any](batch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{batch: batch, results: make(map[K]*result[V])}
+}
+
+// Load queues key for the next batch, unless it was loaded already, and
+// returns a thunk returning its value.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = &result[V]{}
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		r := l.results[key]
+		if !r.done {
+			l.dispatchLocked(ctx)
+		}
+		return r.value, r.err
+	}
+}
+
+// LoadMany is Load for several keys, whose values the thunk returns in the
+// order of keys.
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) func() ([]V, error) {
+	thunks := make([]func() (V, error), len(keys))
+	for i, k := range keys {
+		thunks[i] = l.Load(ctx, k)
+	}
+	return func() ([]V, error) {
+		values := make([]V, len(thunks))
+		for i, thunk := range thunks {
+			v, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+}
+
+// dispatchLocked looks up the pending keys.
+func (l *Loader[K, V]) dispatchLocked(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+	values, err := l.batch(ctx, keys)
+	for _, k := range keys {
+		r := l.results[k]
+		r.done = true
+		if err != nil {
+			r.err = err
+			continue
+		}
+		v, ok := values[k]
+		if !ok {
+			r.err = ErrNotFound
+			continue
+		}
+		r.value = v
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLoaderBatches(t *testing.T) {
+	var batches [][]int
+	l := New(func(_ context.Context, keys []int) (map[int]string, error) {
+		batches = append(batches, slices.Clone(keys))
+		values := make(map[int]string)
+		for _, k := range keys {
+			if k > 0 {
+				values[k] = string(rune('a' + k - 1))
+			}
+		}
+		return values, nil
+	})
+	ctx := context.Background()
+
+	one, two, oneAgain := l.Load(ctx, 1), l.Load(ctx, 2), l.Load(ctx, 1)
+	missing := l.Load(ctx, 0)
+	if v, err := two(); v != "b" || err != nil {
+		t.Errorf("Load(2) = %q, %v, want b", v, err)
+	}
+	if v, err := one(); v != "a" || err != nil {
+		t.Errorf("Load(1) = %q, %v, want a", v, err)
+	}
+	if v, _ := oneAgain(); v != "a" {
+		t.Errorf("Load(1) again = %q, want a", v)
+	}
+	if _, err := missing(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load(0) error = %v, want ErrNotFound", err)
+	}
+	if want := [][]int{{1, 2, 0}}; !slices.EqualFunc(batches, want, slices.Equal) {
+		t.Fatalf("batches = %v, want %v", batches, want)
+	}
+
+	many, err := l.LoadMany(ctx, []int{3, 1})()
+	if err != nil || !slices.Equal(many, []string{"c", "a"}) {
+		t.Errorf("LoadMany(3, 1) = %v, %v, want [c a]", many, err)
+	}
+	if len(batches) != 2 || !slices.Equal(batches[1], []int{3}) {
+		t.Errorf("batches = %v, want a second batch of only the new key", batches)
+	}
+}
+
+func TestLoaderBatchError(t *testing.T) {
+	failure := errors.New("unavailable")
+	l := New(func(context.Context, []string) (map[string]int, error) { return nil, failure })
+	if _, err := l.LoadMany(context.Background(), []string{"a", "b"})(); err != failure {
+		t.Errorf("LoadMany error = %v, want %v", err, failure)
+	}
+}
@@ -3,14 +3,13 @@ package main
 import (
 	"net/http"
 	"os"
-	"time"
 
 	"cloud.google.com/go/compute/metadata"
-	"github.com/sirupsen/logrus"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
 )
 
 var deploymentDetailsMap map[string]string
-var log *logrus.Logger
+var log *logging.Logger
 
 func init() {
 	initializeLogger()
@@ -20,17 +19,7 @@ func init() {
 }
 
 func initializeLogger() {
-	log = logrus.New()
-	log.Level = logrus.DebugLevel
-	log.Formatter = &logrus.JSONFormatter{
-		FieldMap: logrus.FieldMap{
-			logrus.FieldKeyTime:  "timestamp",
-			logrus.FieldKeyLevel: "severity",
-			logrus.FieldKeyMsg:   "message",
-		},
-		TimestampFormat: time.RFC3339Nano,
-	}
-	log.Out = os.Stdout
+	log = logging.New("frontend")
 }
 
 func loadDeploymentDetails() {
@@ -39,24 +28,24 @@ func loadDeploymentDetails() {
 
 	podHostname, err := os.Hostname()
 	if err != nil {
-		log.Error("Failed to fetch the hostname for the Pod", err)
+		log.WithField("error", err).Error("Failed to fetch the hostname for the Pod")
 	}
 
 	podCluster, err := metaServerClient.InstanceAttributeValue("cluster-name")
 	if err != nil {
-		log.Error("Failed to fetch the name of the cluster in which the pod is running", err)
+		log.WithField("error", err).Error("Failed to fetch the name of the cluster in which the pod is running")
 	}
 
 	podZone, err := metaServerClient.Zone()
 	if err != nil {
-		log.Error("Failed to fetch the Zone of the node where the pod is scheduled", err)
+		log.WithField("error", err).Error("Failed to fetch the Zone of the node where the pod is scheduled")
 	}
 
 	deploymentDetailsMap["HOSTNAME"] = podHostname
 	deploymentDetailsMap["CLUSTERNAME"] = podCluster
 	deploymentDetailsMap["ZONE"] = podZone
 
-	log.WithFields(logrus.Fields{
+	log.WithFields(logging.Fields{
 		"cluster":  podCluster,
 		"zone":     podZone,
 		"hostname": podHostname,
@@ -22,4 +22,9 @@ outdir=./genproto
 
 protoc --proto_path=$protodir --go_out=./$outdir --go_opt=paths=source_relative --go-grpc_out=./$outdir --go-grpc_opt=paths=source_relative $protodir/demo.proto
 
+# hipstershop/v2 imports demo.proto, whose go_package does not match this
+# module, so both files are mapped onto the local genproto packages.
+pkg=github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto
+protoc --proto_path=$protodir --go_out=./$outdir --go_opt=paths=source_relative --go_opt=Mdemo.proto=$pkg --go_opt="Mhipstershop/v2/checkout.proto=$pkg/hipstershop/v2;hipstershopv2" --go-grpc_out=./$outdir --go-grpc_opt=paths=source_relative --go-grpc_opt=Mdemo.proto=$pkg --go-grpc_opt="Mhipstershop/v2/checkout.proto=$pkg/hipstershop/v2;hipstershopv2" $protodir/hipstershop/v2/checkout.proto
+
 # [END gke_frontend_genproto]
\ No newline at end of file
@@ -0,0 +1,501 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/dataloader"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/rpcerrors"
+)
+
+// maxConcurrentConversions bounds the currency conversions a batch of prices
+// makes at once.
+const maxConcurrentConversions = 8
+
+type ctxKeyGraphQL struct{}
+
+// graphqlRequest is what the resolvers of a GraphQL request share: who it is
+// made for, and the loaders batching and caching its downstream calls.
+type graphqlRequest struct {
+	userID   string
+	currency string
+
+	products *dataloader.Loader[string, *pb.Product]
+	prices   *dataloader.Loader[priceKey, *pb.Money]
+}
+
+// priceKey is a conversion of an amount of money to the currency to.
+type priceKey struct {
+	currencyCode string
+	units        int64
+	nanos        int32
+	to           string
+}
+
+// graphqlCart is the source of the Cart type.
+type graphqlCart struct {
+	items []*pb.CartItem
+}
+
+// graphqlError carries the classification of a failed downstream call to the
+// extensions of the GraphQL error it is reported as.
+type graphqlError struct {
+	err error
+}
+
+func (e graphqlError) Error() string {
+	if st, ok := status.FromError(e.err); ok {
+		return st.Message()
+	}
+	return e.err.Error()
+}
+
+func (e graphqlError) Extensions() map[string]interface{} {
+	c := rpcerrors.Classify(e.err)
+	return map[string]interface{}{"reason": c.Reason, "retryable": c.Retryable}
+}
+
+// graphqlErrorBody is a GraphQL error raised before the query is executed.
+type graphqlErrorBody struct {
+	Message    string                 `json:"message"`
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+// graphqlHandler serves GraphQL queries, sent as JSON with POST or as query
+// parameters with GET, over the product catalog, the cart, recommendations
+// and the order history. Like the JSON API, it acts for the session's user.
+func (fe *frontendServer) graphqlHandler() http.Handler {
+	schema := fe.mustGraphQLSchema()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := apiLogger(r)
+		var req struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+			Extensions    map[string]interface{} `json:"extensions"`
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodyBytes)).Decode(&req); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err))
+				return
+			}
+		default:
+			q := r.URL.Query()
+			req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+			if v := q.Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					writeGraphQLError(w, http.StatusBadRequest, status.Errorf(codes.InvalidArgument, "invalid variables: %v", err))
+					return
+				}
+			}
+		}
+		if req.Query == "" {
+			writeGraphQLError(w, http.StatusBadRequest, status.Error(codes.InvalidArgument, "no query"))
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        context.WithValue(r.Context(), ctxKeyGraphQL{}, fe.newGraphQLRequest(r)),
+		})
+		if len(result.Errors) > 0 {
+			log.WithField("errors", len(result.Errors)).WithField("error", result.Errors[0].Message).
+				Warn("GraphQL request errors")
+		}
+		writeAPIJSON(w, http.StatusOK, result)
+	})
+}
+
+// writeGraphQLError answers a request that could not be executed.
+func writeGraphQLError(w http.ResponseWriter, code int, err error) {
+	e := graphqlError{err}
+	writeAPIJSON(w, code, map[string][]graphqlErrorBody{"errors": {{Message: e.Error(), Extensions: e.Extensions()}}})
+}
+
+// rejectGraphQLRequest answers the requests the API keys reject.
+func rejectGraphQLRequest(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	writeGraphQLError(w, code, apiKeyError(code, msg))
+}
+
+// newGraphQLRequest returns the state of the GraphQL request r.
+func (fe *frontendServer) newGraphQLRequest(r *http.Request) *graphqlRequest {
+	return &graphqlRequest{
+		userID:   userID(r),
+		currency: currentCurrency(r),
+		products: dataloader.New(fe.loadProducts),
+		prices:   dataloader.New(fe.loadPrices),
+	}
+}
+
+func graphqlRequestFrom(ctx context.Context) *graphqlRequest {
+	return ctx.Value(ctxKeyGraphQL{}).(*graphqlRequest)
+}
+
+// loadProducts looks up products by ID. A single product is looked up by
+// itself, and more with one listing of the catalog, which is small.
+func (fe *frontendServer) loadProducts(ctx context.Context, ids []string) (map[string]*pb.Product, error) {
+	out := make(map[string]*pb.Product, len(ids))
+	if len(ids) == 1 {
+		p, err := fe.getProduct(ctx, ids[0])
+		if status.Code(err) == codes.NotFound {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[p.GetId()] = p
+		return out, nil
+	}
+	products, err := fe.getProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		out[p.GetId()] = p
+	}
+	return out, nil
+}
+
+// loadPrices converts each distinct price once, a few at a time.
+func (fe *frontendServer) loadPrices(ctx context.Context, keys []priceKey) (map[priceKey]*pb.Money, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		out      = make(map[priceKey]*pb.Money, len(keys))
+		firstErr error
+		sem      = make(chan struct{}, maxConcurrentConversions)
+	)
+	for _, k := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			m, err := fe.catalog.price(ctx, &pb.Money{CurrencyCode: k.currencyCode, Units: k.units, Nanos: k.nanos}, k.to, fe.convertCurrency)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to convert currency to %s", k.to)
+				}
+				return
+			}
+			out[k] = m
+		}()
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// loadPrice queues the conversion of m to the currency to.
+func (req *graphqlRequest) loadPrice(ctx context.Context, m *pb.Money, to string) func() (*pb.Money, error) {
+	return req.prices.Load(ctx, priceKey{m.GetCurrencyCode(), m.GetUnits(), m.GetNanos(), to})
+}
+
+// graphqlCurrency returns the currency argument of p, or the session's
+// currency when it is unset.
+func graphqlCurrency(p graphql.ResolveParams) (string, error) {
+	c, _ := p.Args["currency"].(string)
+	if c == "" {
+		return graphqlRequestFrom(p.Context).currency, nil
+	}
+	if !whitelistedCurrencies[c] {
+		return "", graphqlError{status.Errorf(codes.InvalidArgument, "unsupported currency %q", c)}
+	}
+	return c, nil
+}
+
+// graphqlThunk adapts the thunk of a loader to a resolver's result,
+// reporting its error as a graphqlError.
+func graphqlThunk[V any](thunk func() (V, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if err != nil {
+			return nil, graphqlError{err}
+		}
+		return v, nil
+	}
+}
+
+// graphqlProduct resolves the product with id, or null if there is none.
+func graphqlProduct(p graphql.ResolveParams, id string) func() (interface{}, error) {
+	thunk := graphqlRequestFrom(p.Context).products.Load(p.Context, id)
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if errors.Is(err, dataloader.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, graphqlError{err}
+		}
+		return v, nil
+	}
+}
+
+// mustGraphQLSchema returns the schema of the GraphQL endpoint, which is
+// documented in the development guide.
+func (fe *frontendServer) mustGraphQLSchema() graphql.Schema {
+	currencyArg := graphql.FieldConfigArgument{
+		"currency": &graphql.ArgumentConfig{Type: graphql.String, Description: "ISO 4217 code; the session's currency by default"},
+	}
+
+	moneyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Money",
+		Fields: graphql.Fields{
+			"currencyCode": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Money).GetCurrencyCode(), nil
+			}},
+			"units": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Money).GetUnits(), nil
+			}},
+			"nanos": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Money).GetNanos(), nil
+			}},
+			"formatted": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return renderMoney(*p.Source.(*pb.Money)), nil
+			}},
+		},
+	})
+
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Product).GetId(), nil
+			}},
+			"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Product).GetName(), nil
+			}},
+			"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Product).GetDescription(), nil
+			}},
+			"picture": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Product).GetPicture(), nil
+			}},
+			"categories": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.Product).GetCategories(), nil
+			}},
+			"price": &graphql.Field{Type: graphql.NewNonNull(moneyType), Args: currencyArg, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				currency, err := graphqlCurrency(p)
+				if err != nil {
+					return nil, err
+				}
+				return graphqlThunk(graphqlRequestFrom(p.Context).loadPrice(p.Context, p.Source.(*pb.Product).GetPriceUsd(), currency)), nil
+			}},
+		},
+	})
+
+	// cart and order items share their fields
+	itemFields := func() graphql.Fields {
+		return graphql.Fields{
+			"product": &graphql.Field{Type: productType, Description: "null if the product left the catalog", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return graphqlProduct(p, p.Source.(*pb.CartItem).GetProductId()), nil
+			}},
+			"quantity": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pb.CartItem).GetQuantity(), nil
+			}},
+		}
+	}
+	cartItemType := graphql.NewObject(graphql.ObjectConfig{Name: "CartItem", Fields: itemFields()})
+	orderItemType := graphql.NewObject(graphql.ObjectConfig{Name: "OrderItem", Fields: itemFields()})
+
+	cartType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Cart",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cartItemType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*graphqlCart).items, nil
+			}},
+			"shippingCost": &graphql.Field{Type: graphql.NewNonNull(moneyType), Args: currencyArg, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				currency, err := graphqlCurrency(p)
+				if err != nil {
+					return nil, err
+				}
+				cost, err := fe.getShippingQuote(p.Context, p.Source.(*graphqlCart).items, currency)
+				if err != nil {
+					return nil, graphqlError{err}
+				}
+				return cost, nil
+			}},
+			"subtotal": &graphql.Field{Type: graphql.NewNonNull(moneyType), Args: currencyArg, Description: "the price of the items, without shipping", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				currency, err := graphqlCurrency(p)
+				if err != nil {
+					return nil, err
+				}
+				return graphqlThunk(fe.cartSubtotal(p.Context, p.Source.(*graphqlCart).items, currency)), nil
+			}},
+		},
+	})
+
+	orderType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Order",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pbv2.Order).GetOrderId(), nil
+			}},
+			"status": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return apiEnum(p.Source.(*pbv2.Order).GetStatus().String(), "ORDER_STATUS_"), nil
+			}},
+			"createdAt": &graphql.Field{Type: graphql.String, Description: "RFC 3339 timestamp", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				t := p.Source.(*pbv2.Order).GetCreatedAt()
+				if t == nil {
+					return nil, nil
+				}
+				return t.AsTime().Format(time.RFC3339), nil
+			}},
+			"totalPaid": &graphql.Field{Type: moneyType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pbv2.Order).GetTotalPaid(), nil
+			}},
+			"trackingId": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pbv2.Order).GetShippingTrackingId(), nil
+			}},
+			"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderItemType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pbv2.Order).GetItems(), nil
+			}},
+		},
+	})
+
+	orderPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "OrderPage",
+		Fields: graphql.Fields{
+			"orders": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*pbv2.ListOrdersResponse).GetOrders(), nil
+			}},
+			"nextPageToken": &graphql.Field{Type: graphql.String, Description: "the pageToken of the next page, null on the last one", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if t := p.Source.(*pbv2.ListOrdersResponse).GetNextPageToken(); t != "" {
+					return t, nil
+				}
+				return nil, nil
+			}},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"products": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				products, err := fe.getProducts(p.Context)
+				if err != nil {
+					return nil, graphqlError{errors.Wrap(err, "could not retrieve products")}
+				}
+				return products, nil
+			}},
+			"product": &graphql.Field{Type: productType, Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			}, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return graphqlProduct(p, p.Args["id"].(string)), nil
+			}},
+			"recommendations": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))), Args: graphql.FieldConfigArgument{
+				"productIds": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.ID)), Description: "the products to recommend others for"},
+			}, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				var productIDs []string
+				if ids, ok := p.Args["productIds"].([]interface{}); ok {
+					for _, id := range ids {
+						productIDs = append(productIDs, id.(string))
+					}
+				}
+				resp, err := pb.NewRecommendationServiceClient(fe.recommendationSvcConn).ListRecommendations(p.Context,
+					&pb.ListRecommendationsRequest{UserId: graphqlRequestFrom(p.Context).userID, ProductIds: productIDs})
+				if err != nil {
+					return nil, graphqlError{errors.Wrap(err, "failed to get recommendations")}
+				}
+				return graphqlThunk(graphqlRequestFrom(p.Context).products.LoadMany(p.Context, resp.GetProductIds())), nil
+			}},
+			"cart": &graphql.Field{Type: graphql.NewNonNull(cartType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				items, err := fe.getCart(p.Context, graphqlRequestFrom(p.Context).userID)
+				if err != nil {
+					return nil, graphqlError{errors.Wrap(err, "could not retrieve cart")}
+				}
+				return &graphqlCart{items: items}, nil
+			}},
+			"orders": &graphql.Field{Type: graphql.NewNonNull(orderPageType), Args: graphql.FieldConfigArgument{
+				"pageToken": &graphql.ArgumentConfig{Type: graphql.String},
+			}, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				token, _ := p.Args["pageToken"].(string)
+				resp, err := fe.listOrders(p.Context, graphqlRequestFrom(p.Context).userID, token)
+				if err != nil {
+					return nil, graphqlError{err}
+				}
+				return resp, nil
+			}},
+			"order": &graphql.Field{Type: orderType, Description: "null unless the order is the session user's", Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			}, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				order, err := fe.getOrder(p.Context, p.Args["id"].(string))
+				if status.Code(err) == codes.NotFound {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, graphqlError{err}
+				}
+				if order.GetUserId() != graphqlRequestFrom(p.Context).userID {
+					return nil, nil
+				}
+				return order, nil
+			}},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+	if err != nil {
+		panic(errors.Wrap(err, "invalid GraphQL schema"))
+	}
+	return schema
+}
+
+// cartSubtotal returns a thunk adding up the prices of items in currency,
+// whose products and prices are loaded in one batch each.
+func (fe *frontendServer) cartSubtotal(ctx context.Context, items []*pb.CartItem, currency string) func() (*pb.Money, error) {
+	req := graphqlRequestFrom(ctx)
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.GetProductId()
+	}
+	products := req.products.LoadMany(ctx, ids)
+	return func() (*pb.Money, error) {
+		ps, err := products()
+		if err != nil {
+			return nil, err
+		}
+		prices := make([]func() (*pb.Money, error), len(ps))
+		for i, p := range ps {
+			prices[i] = req.loadPrice(ctx, p.GetPriceUsd(), currency)
+		}
+		total := pb.Money{CurrencyCode: currency}
+		for i, price := range prices {
+			m, err := price()
+			if err != nil {
+				return nil, err
+			}
+			total = money.Must(money.Sum(total, money.MultiplySlow(*m, uint32(items[i].GetQuantity()))))
+		}
+		return &total, nil
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+// countingCatalog is a fake catalog counting its calls.
+type countingCatalog struct {
+	*fakes.ProductCatalog
+	gets, lists atomic.Int32
+}
+
+func (c *countingCatalog) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	c.gets.Add(1)
+	return c.ProductCatalog.GetProduct(ctx, req)
+}
+
+func (c *countingCatalog) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	c.lists.Add(1)
+	return c.ProductCatalog.ListProducts(ctx, req)
+}
+
+// queryGraphQL posts query to fe's GraphQL endpoint as the session sessionID.
+func queryGraphQL(t *testing.T, fe *frontendServer, query, sessionID string) (data map[string]interface{}, errs []graphqlErrorBody) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+	ctx = context.WithValue(ctx, ctxKeySessionID{}, sessionID)
+	w := httptest.NewRecorder()
+	fe.graphqlHandler().ServeHTTP(w, r.WithContext(ctx))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /graphql = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data   map[string]interface{}
+		Errors []graphqlErrorBody
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data, resp.Errors
+}
+
+func TestGraphQLBatchesProducts(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	catalog := &countingCatalog{ProductCatalog: fakes.NewProductCatalog()}
+	fe.productCatalogSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pb.RegisterProductCatalogServiceServer(srv, catalog)
+	})
+	order := &pbv2.Order{OrderId: "order-1", UserId: "user-1", Items: []*pb.CartItem{{ProductId: "66VCHSJNUP", Quantity: 1}, {ProductId: "OLJCESPC7Z", Quantity: 3}}}
+	fe.checkoutSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pbv2.RegisterCheckoutServiceServer(srv, &fakeOrders{orders: []*pbv2.Order{order}})
+	})
+	ctx := context.Background()
+	for _, id := range []string{"OLJCESPC7Z", "66VCHSJNUP", "1YMWWN1N4O"} {
+		if err := fe.insertCart(ctx, "user-1", id, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, errs := queryGraphQL(t, fe, `{
+		cart {
+			items { quantity product { name price { formatted } } }
+			subtotal { units nanos }
+		}
+		orders { orders { id status items { quantity product { id } } } }
+	}`, "user-1")
+	if len(errs) > 0 {
+		t.Fatalf("errors = %+v", errs)
+	}
+	cart := data["cart"].(map[string]interface{})
+	if items := cart["items"].([]interface{}); len(items) != 3 {
+		t.Errorf("cart items = %v, want 3", items)
+	}
+	// 2 × (19.99 + 18.99 + 109.99)
+	if subtotal := cart["subtotal"].(map[string]interface{}); subtotal["units"] != 297.0 || subtotal["nanos"] != 940000000.0 {
+		t.Errorf("cart subtotal = %v, want 297.94", subtotal)
+	}
+	orders := data["orders"].(map[string]interface{})["orders"].([]interface{})
+	if len(orders) != 1 || orders[0].(map[string]interface{})["status"] != "unspecified" {
+		t.Errorf("orders = %v, want order-1", orders)
+	}
+	if gets, lists := catalog.gets.Load(), catalog.lists.Load(); gets != 0 || lists != 1 {
+		t.Errorf("catalog got %d products and was listed %d times, want a single listing", gets, lists)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+
+	data, errs := queryGraphQL(t, fe, `{ product(id: "OLJCESPC7Z") { name price(currency: "XXX") { units } } missing: product(id: "nope") { name } }`, "user-1")
+	if len(errs) != 1 || errs[0].Extensions["reason"] != "InvalidArgument" {
+		t.Fatalf("errors = %+v, want one InvalidArgument error", errs)
+	}
+	if data["product"] != nil || data["missing"] != nil {
+		t.Errorf("data = %v, want both products null", data)
+	}
+
+	if _, errs := queryGraphQL(t, fe, `{ nothing }`, "user-1"); len(errs) == 0 {
+		t.Error("querying an unknown field returned no error")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcconfig
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/stats"
+)
+
+func TestFromEnvDefaults(t *testing.T) {
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.ServerOptions()) + len(c.DialOptions()); n != 0 {
+		t.Errorf("got %d options with nothing set, want none", n)
+	}
+	if got := c.String(); got != "defaults" {
+		t.Errorf("String() = %q, want defaults", got)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("GRPC_COMPRESSION", "GZIP")
+	t.Setenv("GRPC_MAX_CONNECTION_AGE", "5m")
+	t.Setenv("GRPC_CLIENT_KEEPALIVE_TIME", "30s")
+	t.Setenv("GRPC_IDLE_TIMEOUT", "10m")
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Compressor != "gzip" || c.Server.MaxConnectionAge != 5*time.Minute || c.IdleTimeout != 10*time.Minute {
+		t.Errorf("FromEnv() = %+v", c)
+	}
+	if c.Enforcement.MinTime != 30*time.Second || !c.Enforcement.PermitWithoutStream {
+		t.Errorf("enforcement policy %+v does not allow the service's own client keepalive", c.Enforcement)
+	}
+	want := "compression=gzip max_connection_age=5m0s keepalive_min_time=30s client_keepalive_time=30s idle_timeout=10m0s"
+	if got := c.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterDiagnostics(t *testing.T) {
+	t.Setenv("ENABLE_GRPC_REFLECTION", "1")
+	t.Setenv("ENABLE_CHANNELZ", "1")
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	c.RegisterDiagnostics(srv)
+	for _, name := range []string{"grpc.reflection.v1.ServerReflection", "grpc.channelz.v1.Channelz"} {
+		if _, ok := srv.GetServiceInfo()[name]; !ok {
+			t.Errorf("%s not registered", name)
+		}
+	}
+
+	srv = grpc.NewServer()
+	(&Config{}).RegisterDiagnostics(srv)
+	if n := len(srv.GetServiceInfo()); n != 0 {
+		t.Errorf("registered %d services with diagnostics off, want none", n)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	for key, v := range map[string]string{
+		"GRPC_COMPRESSION":    "snappy",
+		"GRPC_KEEPALIVE_TIME": "often",
+		"GRPC_IDLE_TIMEOUT":   "-1s",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, v)
+			if _, err := FromEnv(); err == nil {
+				t.Errorf("FromEnv() with %s=%q succeeded, want error", key, v)
+			}
+		})
+	}
+}
+
+// encodingRecorder records the compression of each request a server gets.
+type encodingRecorder struct {
+	encoding chan string
+}
+
+func (r encodingRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r encodingRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok {
+		r.encoding <- h.Compression
+	}
+}
+
+func (r encodingRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r encodingRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func TestCompressedCalls(t *testing.T) {
+	t.Setenv("GRPC_COMPRESSION", "gzip")
+	t.Setenv("GRPC_KEEPALIVE_TIME", "1m")
+	t.Setenv("GRPC_CLIENT_KEEPALIVE_TIME", "1m")
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := encodingRecorder{make(chan string, 1)}
+	srv := grpc.NewServer(append(c.ServerOptions(), grpc.StatsHandler(rec))...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		append(c.DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-rec.encoding; got != "gzip" {
+		t.Errorf("request encoding = %q, want gzip", got)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcweb lets browsers call gRPC services directly. It serves
+// gRPC-Web, the variant of gRPC that can be spoken over HTTP/1.1 with fetch,
+// in both its binary and base64 text encodings, and forwards the unary calls
+// it receives to gRPC backends.
+//
+// Only the methods a Proxy exposes can be called, since backends also have
+// methods meant for the back office. Requests can be scoped to the user they
+// are made for, so that browsers cannot act for other users.
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	contentType     = "application/grpc-web"
+	contentTypeText = "application/grpc-web-text"
+
+	// maxMessageBytes bounds the size of request messages.
+	maxMessageBytes = 4 << 20
+
+	flagTrailers = 0x80
+)
+
+// Headers that browsers send and must be able to read across origins.
+var (
+	RequestHeaders  = []string{"X-Grpc-Web", "X-User-Agent", "Grpc-Timeout"}
+	ResponseHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+type method struct {
+	conn      grpc.ClientConnInterface
+	in, out   protoreflect.MessageType
+	userIDIn  protoreflect.FieldDescriptor
+	userIDOut protoreflect.FieldDescriptor
+}
+
+// Proxy forwards the gRPC-Web calls of the methods it exposes. Its path is
+// the method's, as in /hipstershop.ProductCatalogService/ListProducts, so it
+// is mounted with http.StripPrefix under a prefix.
+type Proxy struct {
+	// UserID, if set, returns the user a request is made for. The user_id
+	// field of its request message is then set to that user, and responses
+	// with another user_id are answered NOT_FOUND.
+	UserID func(*http.Request) string
+
+	methods map[string]*method
+}
+
+// New returns a Proxy exposing no methods.
+func New() *Proxy {
+	return &Proxy{methods: make(map[string]*method)}
+}
+
+// Expose forwards the calls of the named unary methods of service, a fully
+// qualified name such as hipstershop.ProductCatalogService, to conn. The
+// service's generated code must be linked into the binary.
+func (p *Proxy) Expose(conn grpc.ClientConnInterface, service string, methods ...string) error {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return fmt.Errorf("grpcweb: unknown service %s: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return fmt.Errorf("grpcweb: %s is not a service", service)
+	}
+	for _, name := range methods {
+		md := sd.Methods().ByName(protoreflect.Name(name))
+		if md == nil {
+			return fmt.Errorf("grpcweb: service %s has no method %s", service, name)
+		}
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			return fmt.Errorf("grpcweb: %s.%s streams, only unary methods are supported", service, name)
+		}
+		in, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+		if err != nil {
+			return fmt.Errorf("grpcweb: %s.%s: %w", service, name, err)
+		}
+		out, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+		if err != nil {
+			return fmt.Errorf("grpcweb: %s.%s: %w", service, name, err)
+		}
+		p.methods["/"+service+"/"+name] = &method{
+			conn:      conn,
+			in:        in,
+			out:       out,
+			userIDIn:  userIDField(md.Input()),
+			userIDOut: userIDField(md.Output()),
+		}
+	}
+	return nil
+}
+
+// userIDField returns the user_id field of messages of type md, if any.
+func userIDField(md protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	fd := md.Fields().ByName("user_id")
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return nil
+	}
+	return fd
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "gRPC-Web calls are POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+	text, ok := parseContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		http.Error(w, "unsupported content type "+strconv.Quote(r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+	m, ok := p.methods[r.URL.Path]
+	if !ok {
+		WriteError(w, r, status.Errorf(codes.Unimplemented, "method %s is not served to browsers", r.URL.Path))
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxMessageBytes)
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	payload, err := readMessage(body)
+	if err != nil {
+		WriteError(w, r, status.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+		return
+	}
+	in := m.in.New().Interface()
+	if err := proto.Unmarshal(payload, in); err != nil {
+		WriteError(w, r, status.Errorf(codes.InvalidArgument, "invalid request message: %v", err))
+		return
+	}
+	var userID string
+	if p.UserID != nil {
+		userID = p.UserID(r)
+		if m.userIDIn != nil {
+			in.ProtoReflect().Set(m.userIDIn, protoreflect.ValueOfString(userID))
+		}
+	}
+
+	ctx := r.Context()
+	if t := r.Header.Get("Grpc-Timeout"); t != "" {
+		d, err := parseTimeout(t)
+		if err != nil {
+			WriteError(w, r, status.Errorf(codes.InvalidArgument, "invalid grpc-timeout %q", t))
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	out := m.out.New().Interface()
+	if err := m.conn.Invoke(ctx, r.URL.Path, in, out); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	if p.UserID != nil && m.userIDOut != nil && out.ProtoReflect().Get(m.userIDOut).String() != userID {
+		WriteError(w, r, status.Error(codes.NotFound, "not found"))
+		return
+	}
+
+	msg, err := proto.Marshal(out)
+	if err != nil {
+		WriteError(w, r, status.Errorf(codes.Internal, "failed to marshal response: %v", err))
+		return
+	}
+	var buf bytes.Buffer
+	writeFrame(&buf, 0, msg)
+	writeFrame(&buf, flagTrailers, []byte("grpc-status: 0\r\ngrpc-message: \r\n"))
+	writeBody(w, text, buf.Bytes())
+}
+
+// WriteError answers a gRPC-Web request with err's status, in the headers of
+// an otherwise empty response as gRPC-Web allows, so that browsers can read
+// it without parsing a body.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	text, _ := parseContentType(r.Header.Get("Content-Type"))
+	h := w.Header()
+	h.Set("Grpc-Status", strconv.Itoa(int(st.Code())))
+	h.Set("Grpc-Message", encodeMessage(st.Message()))
+	if len(st.Proto().GetDetails()) > 0 {
+		if b, err := proto.Marshal(st.Proto()); err == nil {
+			h.Set("Grpc-Status-Details-Bin", base64.StdEncoding.EncodeToString(b))
+		}
+	}
+	writeBody(w, text, nil)
+}
+
+func writeBody(w http.ResponseWriter, text bool, body []byte) {
+	if text {
+		w.Header().Set("Content-Type", contentTypeText+"+proto")
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	} else {
+		w.Header().Set("Content-Type", contentType+"+proto")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// parseContentType reports whether v is a gRPC-Web content type with protobuf
+// messages, and whether it is the text encoding.
+func parseContentType(v string) (text, ok bool) {
+	t, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return false, false
+	}
+	switch t {
+	case contentType, contentType + "+proto":
+		return false, true
+	case contentTypeText, contentTypeText + "+proto":
+		return true, true
+	}
+	return false, false
+}
+
+// readMessage reads the single uncompressed message framed in r.
+func readMessage(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading frame header: %w", err)
+	}
+	if header[0] != 0 {
+		return nil, fmt.Errorf("unsupported frame flags %#x", header[0])
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxMessageBytes {
+		return nil, fmt.Errorf("message of %d bytes is too large", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	return msg, nil
+}
+
+func writeFrame(buf *bytes.Buffer, flags byte, payload []byte) {
+	buf.WriteByte(flags)
+	binary.Write(buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+}
+
+// parseTimeout parses a grpc-timeout header, such as 500m or 10S.
+func parseTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid timeout %q", v)
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", v)
+	}
+	unit, ok := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}[v[len(v)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid timeout unit in %q", v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// encodeMessage percent-encodes a status message for the grpc-message
+// header, as gRPC does.
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
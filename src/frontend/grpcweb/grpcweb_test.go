@@ -0,0 +1,174 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+)
+
+// fakeOrders returns orders of user-1 and lists those of the user asked for.
+type fakeOrders struct {
+	pbv2.UnimplementedCheckoutServiceServer
+}
+
+func (fakeOrders) GetOrder(_ context.Context, req *pbv2.GetOrderRequest) (*pbv2.Order, error) {
+	return &pbv2.Order{OrderId: req.GetOrderId(), UserId: "user-1"}, nil
+}
+
+func (fakeOrders) ListOrders(_ context.Context, req *pbv2.ListOrdersRequest) (*pbv2.ListOrdersResponse, error) {
+	return &pbv2.ListOrdersResponse{Orders: []*pbv2.Order{{OrderId: "order-1", UserId: req.GetUserId()}}}, nil
+}
+
+func newProxy(t *testing.T) *Proxy {
+	t.Helper()
+	conn := contract.Serve(t, func(srv *grpc.Server) {
+		fakes.NewProductCatalog().Register(srv)
+		pbv2.RegisterCheckoutServiceServer(srv, fakeOrders{})
+	})
+	p := New()
+	if err := p.Expose(conn, "hipstershop.ProductCatalogService", "ListProducts", "GetProduct"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Expose(conn, "hipstershop.v2.CheckoutService", "GetOrder", "ListOrders"); err != nil {
+		t.Fatal(err)
+	}
+	p.UserID = func(*http.Request) string { return "user-1" }
+	return p
+}
+
+// call calls method with req through p, and returns the status and the
+// response message.
+func call(t *testing.T, p *Proxy, method string, req proto.Message, text bool, out proto.Message) *status.Status {
+	t.Helper()
+	msg, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	writeFrame(&body, 0, msg)
+	ct := "application/grpc-web+proto"
+	if text {
+		ct = "application/grpc-web-text"
+		body = *bytes.NewBufferString(base64.StdEncoding.EncodeToString(body.Bytes()))
+	}
+	r := httptest.NewRequest(http.MethodPost, method, &body)
+	r.Header.Set("Content-Type", ct)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s = %d: %s", method, w.Code, w.Body)
+	}
+	if v := w.Header().Get("Grpc-Status"); v != "" {
+		code, _ := strconv.Atoi(v)
+		return status.New(codes.Code(code), w.Header().Get("Grpc-Message"))
+	}
+
+	resp := w.Body.Bytes()
+	if text {
+		if resp, err = base64.StdEncoding.DecodeString(string(resp)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(resp) < 5 || resp[0] != 0 {
+		t.Fatalf("response %q does not start with a message frame", resp)
+	}
+	n := binary.BigEndian.Uint32(resp[1:5])
+	if err := proto.Unmarshal(resp[5:5+n], out); err != nil {
+		t.Fatal(err)
+	}
+	if trailers := resp[5+n:]; len(trailers) < 5 || trailers[0] != flagTrailers || !bytes.Contains(trailers, []byte("grpc-status: 0")) {
+		t.Errorf("trailers = %q, want an OK status", trailers)
+	}
+	return status.New(codes.OK, "")
+}
+
+func TestProxy(t *testing.T) {
+	p := newProxy(t)
+
+	var products pb.ListProductsResponse
+	if st := call(t, p, "/hipstershop.ProductCatalogService/ListProducts", &pb.Empty{}, false, &products); st.Code() != codes.OK || len(products.GetProducts()) == 0 {
+		t.Errorf("ListProducts = %v, %d products, want products", st, len(products.GetProducts()))
+	}
+	var product pb.Product
+	if st := call(t, p, "/hipstershop.ProductCatalogService/GetProduct", &pb.GetProductRequest{Id: "OLJCESPC7Z"}, true, &product); st.Code() != codes.OK || product.GetId() != "OLJCESPC7Z" {
+		t.Errorf("GetProduct in text = %v, %v, want OLJCESPC7Z", st, product.GetId())
+	}
+	if st := call(t, p, "/hipstershop.ProductCatalogService/GetProduct", &pb.GetProductRequest{Id: "nope"}, false, &product); st.Code() != codes.NotFound {
+		t.Errorf("GetProduct of an unknown product = %v, want NotFound", st)
+	}
+	if st := call(t, p, "/hipstershop.v2.CheckoutService/SearchOrders", &pbv2.SearchOrdersRequest{}, false, &pbv2.SearchOrdersResponse{}); st.Code() != codes.Unimplemented {
+		t.Errorf("SearchOrders = %v, want Unimplemented since it is not exposed", st)
+	}
+}
+
+func TestProxyScopesUser(t *testing.T) {
+	p := newProxy(t)
+
+	var page pbv2.ListOrdersResponse
+	call(t, p, "/hipstershop.v2.CheckoutService/ListOrders", &pbv2.ListOrdersRequest{UserId: "user-2"}, false, &page)
+	if got := page.GetOrders()[0].GetUserId(); got != "user-1" {
+		t.Errorf("ListOrders for user-2 listed the orders of %s, want those of the session's user-1", got)
+	}
+	if st := call(t, p, "/hipstershop.v2.CheckoutService/GetOrder", &pbv2.GetOrderRequest{OrderId: "order-1"}, false, &pbv2.Order{}); st.Code() != codes.OK {
+		t.Errorf("GetOrder of an order of the user = %v, want OK", st)
+	}
+	p.UserID = func(*http.Request) string { return "user-2" }
+	if st := call(t, p, "/hipstershop.v2.CheckoutService/GetOrder", &pbv2.GetOrderRequest{OrderId: "order-1"}, false, &pbv2.Order{}); st.Code() != codes.NotFound {
+		t.Errorf("GetOrder of an order of another user = %v, want NotFound", st)
+	}
+}
+
+func TestExposeRejectsUnknownAndStreamingMethods(t *testing.T) {
+	p := New()
+	for _, tt := range []struct{ service, method string }{
+		{"hipstershop.NoSuchService", "Get"},
+		{"hipstershop.ProductCatalogService", "Delete"},
+		{"hipstershop.CurrencyService", "WatchRates"},
+	} {
+		if err := p.Expose(nil, tt.service, tt.method); err == nil {
+			t.Errorf("Expose(%s, %s) succeeded", tt.service, tt.method)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	for v, want := range map[string]int64{"10S": 10e9, "500m": 500e6, "1H": 3600e9} {
+		if d, err := parseTimeout(v); err != nil || int64(d) != want {
+			t.Errorf("parseTimeout(%q) = %v, %v, want %d", v, d, err, want)
+		}
+	}
+	for _, v := range []string{"", "S", "10x", "-1S"} {
+		if _, err := parseTimeout(v); err == nil {
+			t.Errorf("parseTimeout(%q) succeeded", v)
+		}
+	}
+}
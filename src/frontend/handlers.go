@@ -20,21 +20,36 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"math"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/accounts"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/csrf"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
 	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/preferences"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/rpcerrors"
 	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/validator"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/wishlist"
 )
 
 type platformDetails struct {
@@ -50,26 +65,63 @@ var (
 				Funcs(template.FuncMap{
 			"renderMoney":        renderMoney,
 			"renderCurrencyLogo": renderCurrencyLogo,
+			"renderStars":        renderStars,
 		}).ParseGlob("templates/*.html"))
 	plat platformDetails
 )
 
 var validEnvs = []string{"local", "gcp", "azure", "aws", "onprem", "alibaba"}
 
+// The reasons checkout fails orders with that are fixed by changing the
+// cart: the catalog prices of their items changed during checkout, there is
+// not enough of an item in stock, or the promo code does not apply.
+const (
+	reasonPricesChanged    = "PRICES_CHANGED"
+	reasonOutOfStock       = "OUT_OF_STOCK"
+	reasonPromoCodeInvalid = "PROMO_CODE_INVALID"
+)
+
+// maxPromoCodeLen is the longest promo code checkoutservice accepts, in bytes.
+const maxPromoCodeLen = 64
+
+// defaultPageSize is how many products the home page lists unless its size
+// query parameter says otherwise.
+const defaultPageSize = 12
+
+// productSorts are the orders the home page lists products in, by the value
+// of its sort query parameter. The empty one is catalog order.
+var productSorts = map[string]pb.ProductSort{
+	"":           pb.ProductSort_PRODUCT_SORT_UNSPECIFIED,
+	"name":       pb.ProductSort_PRODUCT_SORT_NAME,
+	"price_asc":  pb.ProductSort_PRODUCT_SORT_PRICE_ASC,
+	"price_desc": pb.ProductSort_PRODUCT_SORT_PRICE_DESC,
+}
+
 func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	log.WithField("currency", currentCurrency(r)).Info("home")
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
 		return
 	}
-	products, err := fe.getProducts(r.Context())
+	listing := validator.ProductListingPayload{Page: 1, Size: defaultPageSize, Sort: r.FormValue("sort")}
+	if v := r.FormValue("page"); v != "" {
+		listing.Page, _ = strconv.Atoi(v)
+	}
+	if v := r.FormValue("size"); v != "" {
+		listing.Size, _ = strconv.Atoi(v)
+	}
+	if err := listing.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	products, total, err := fe.listProducts(r.Context(), listing.Page, listing.Size, productSorts[listing.Sort])
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve products"), http.StatusInternalServerError)
 		return
 	}
-	cart, err := fe.getCart(r.Context(), sessionID(r))
+	cart, err := fe.getCart(r.Context(), userID(r))
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
 		return
@@ -81,7 +133,7 @@ func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	ps := make([]productView, len(products))
 	for i, p := range products {
-		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
+		price, err := fe.productPrice(r.Context(), p, currentCurrency(r))
 		if err != nil {
 			renderHTTPError(log, r, w, errors.Wrapf(err, "failed to do currency conversion for product %s", p.GetId()), http.StatusInternalServerError)
 			return
@@ -107,6 +159,19 @@ func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
 	plat = platformDetails{}
 	plat.setPlatformDetails(strings.ToLower(env))
 
+	pageCount := max((total+listing.Size-1)/listing.Size, 1)
+	var customSize int
+	if listing.Size != defaultPageSize {
+		customSize = listing.Size
+	}
+	var prevPage, nextPage string
+	if listing.Page > 1 {
+		prevPage = homePageURL(listing, min(listing.Page-1, pageCount))
+	}
+	if listing.Page < pageCount {
+		nextPage = homePageURL(listing, listing.Page+1)
+	}
+
 	if err := templates.ExecuteTemplate(w, "home", injectCommonTemplateData(r, map[string]interface{}{
 		"show_currency": true,
 		"currencies":    currencies,
@@ -114,11 +179,88 @@ func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
 		"cart_size":     cartSize(cart),
 		"banner_color":  os.Getenv("BANNER_COLOR"), // illustrates canary deployments
 		"ad":            fe.chooseAd(r.Context(), []string{}, log),
+		"sort":          listing.Sort,
+		"page_size":     customSize, // 0 for the default
+		"page":          listing.Page,
+		"page_count":    pageCount,
+		"prev_page":     prevPage,
+		"next_page":     nextPage,
+	})); err != nil {
+		log.Error(err)
+	}
+}
+
+func (fe *frontendServer) searchHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	payload := validator.SearchPayload{Query: strings.TrimSpace(r.FormValue("q"))}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("query", payload.Query).Debug("search")
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+	var products []*pb.Product
+	if payload.Query != "" {
+		products, err = fe.searchProducts(r.Context(), payload.Query)
+		if err != nil {
+			renderHTTPError(log, r, w, errors.Wrap(err, "could not search products"), http.StatusInternalServerError)
+			return
+		}
+	}
+	cart, err := fe.getCart(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
+
+	type productView struct {
+		Item  *pb.Product
+		Price *pb.Money
+	}
+	ps := make([]productView, len(products))
+	for i, p := range products {
+		price, err := fe.productPrice(r.Context(), p, currentCurrency(r))
+		if err != nil {
+			renderHTTPError(log, r, w, errors.Wrapf(err, "failed to do currency conversion for product %s", p.GetId()), http.StatusInternalServerError)
+			return
+		}
+		ps[i] = productView{p, price}
+	}
+
+	if err := templates.ExecuteTemplate(w, "search", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": true,
+		"currencies":    currencies,
+		"query":         payload.Query,
+		"products":      ps,
+		"cart_size":     cartSize(cart),
 	})); err != nil {
 		log.Error(err)
 	}
 }
 
+// homePageURL returns the URL of page of the home page listing, in the same
+// order and with as many products per page as listing.
+func homePageURL(listing validator.ProductListingPayload, page int) string {
+	q := url.Values{}
+	if listing.Sort != "" {
+		q.Set("sort", listing.Sort)
+	}
+	if listing.Size != defaultPageSize {
+		q.Set("size", strconv.Itoa(listing.Size))
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if len(q) == 0 {
+		return baseUrl + "/"
+	}
+	return baseUrl + "/?" + q.Encode()
+}
+
 func (plat *platformDetails) setPlatformDetails(env string) {
 	if env == "aws" {
 		plat.provider = "AWS"
@@ -142,7 +284,7 @@ func (plat *platformDetails) setPlatformDetails(env string) {
 }
 
 func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	id := mux.Vars(r)["id"]
 	if id == "" {
 		renderHTTPError(log, r, w, errors.New("product id not specified"), http.StatusBadRequest)
@@ -162,20 +304,20 @@ func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	cart, err := fe.getCart(r.Context(), sessionID(r))
+	cart, err := fe.getCart(r.Context(), userID(r))
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
 		return
 	}
 
-	price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
+	price, err := fe.productPrice(r.Context(), p, currentCurrency(r))
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to convert currency"), http.StatusInternalServerError)
 		return
 	}
 
 	// ignores the error retrieving recommendations since it is not critical
-	recommendations, err := fe.getRecommendations(r.Context(), sessionID(r), []string{id})
+	recommendations, err := fe.getRecommendations(r.Context(), userID(r), []string{id})
 	if err != nil {
 		log.WithField("error", err).Warn("failed to get product recommendations")
 	}
@@ -189,12 +331,45 @@ func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request)
 	// The packaging service is an optional microservice you can run as part of a Google Cloud demo.
 	var packagingInfo *PackagingInfo = nil
 	if isPackagingServiceConfigured() {
-		packagingInfo, err = httpGetPackagingInfo(id)
+		packagingInfo, err = httpGetPackagingInfo(r.Context(), id)
 		if err != nil {
 			fmt.Println("Failed to obtain product's packaging info:", err)
 		}
 	}
 
+	// ignores the error retrieving availability so the product stays purchasable
+	outOfStock, err := fe.isOutOfStock(r.Context(), id)
+	if err != nil {
+		log.WithField("error", err).Warn("failed to get product availability")
+	}
+
+	// ignores the error retrieving reviews since they are not critical
+	reviews, err := fe.listReviews(r.Context(), id)
+	if err != nil {
+		log.WithField("error", err).Warn("failed to get product reviews")
+	}
+	type reviewView struct {
+		AuthorName string
+		Rating     float64
+		Text       string
+		Date       string
+	}
+	reviewViews := make([]reviewView, len(reviews.GetReviews()))
+	for i, rv := range reviews.GetReviews() {
+		reviewViews[i] = reviewView{
+			AuthorName: rv.GetAuthorName(),
+			Rating:     float64(rv.GetRating()),
+			Text:       rv.GetText(),
+			Date:       rv.GetCreatedAt().AsTime().Format("January 2, 2006"),
+		}
+	}
+
+	// ignores the error retrieving the wishlist since it is not critical
+	onWishlist, err := fe.onWishlist(r.Context(), userID(r), id)
+	if err != nil {
+		log.WithField("error", err).Warn("failed to get wishlist")
+	}
+
 	if err := templates.ExecuteTemplate(w, "product", injectCommonTemplateData(r, map[string]interface{}{
 		"ad":              fe.chooseAd(r.Context(), p.Categories, log),
 		"show_currency":   true,
@@ -203,13 +378,76 @@ func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request)
 		"recommendations": recommendations,
 		"cart_size":       cartSize(cart),
 		"packagingInfo":   packagingInfo,
+		"out_of_stock":    outOfStock,
+		"notify_status":   r.URL.Query().Get("notify"),
+		"on_wishlist":     onWishlist,
+		"reviews":         reviewViews,
+		"rating":          reviews.GetRating(),
+		"review_status":   r.URL.Query().Get("review"),
 	})); err != nil {
-		log.Println(err)
+		log.Error(err)
+	}
+}
+
+// backInStockHandler subscribes an email address to be notified once an
+// out-of-stock product is available again.
+func (fe *frontendServer) backInStockHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	if fe.notificationSvcConn == nil {
+		renderHTTPError(log, r, w, errors.New("back-in-stock notifications are not enabled"), http.StatusNotFound)
+		return
+	}
+	payload := validator.BackInStockPayload{
+		ProductID: mux.Vars(r)["id"],
+		Email:     r.FormValue("email"),
 	}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("product", payload.ProductID).Debug("subscribing to back-in-stock notification")
+
+	if err := fe.subscribeBackInStock(r.Context(), payload.ProductID, payload.Email); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to subscribe to back-in-stock notification"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", baseUrl+"/product/"+payload.ProductID+"?notify=subscribed")
+	w.WriteHeader(http.StatusFound)
+}
+
+// submitReviewHandler submits the review of the form for moderation. It is
+// only shown on the product page once approved.
+func (fe *frontendServer) submitReviewHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	rating, _ := strconv.ParseUint(r.FormValue("rating"), 10, 32)
+	payload := validator.ReviewPayload{
+		ProductID:  mux.Vars(r)["id"],
+		AuthorName: strings.TrimSpace(r.FormValue("author_name")),
+		Rating:     rating,
+		Text:       strings.TrimSpace(r.FormValue("text")),
+	}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("product", payload.ProductID).WithField("rating", payload.Rating).Debug("submitting review")
+
+	if _, err := fe.submitReview(r.Context(), &pb.SubmitReviewRequest{
+		ProductId:  payload.ProductID,
+		UserId:     userID(r),
+		AuthorName: payload.AuthorName,
+		Rating:     int32(payload.Rating),
+		Text:       payload.Text,
+	}); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to submit review"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", baseUrl+"/product/"+payload.ProductID+"?review=submitted")
+	w.WriteHeader(http.StatusFound)
 }
 
 func (fe *frontendServer) addToCartHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	quantity, _ := strconv.ParseUint(r.FormValue("quantity"), 10, 32)
 	productID := r.FormValue("product_id")
 	payload := validator.AddToCartPayload{
@@ -228,42 +466,42 @@ func (fe *frontendServer) addToCartHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := fe.insertCart(r.Context(), sessionID(r), p.GetId(), int32(payload.Quantity)); err != nil {
+	if err := fe.insertCart(r.Context(), userID(r), p.GetId(), int32(payload.Quantity)); err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to add to cart"), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("location", baseUrl + "/cart")
+	w.Header().Set("location", baseUrl+"/cart")
 	w.WriteHeader(http.StatusFound)
 }
 
 func (fe *frontendServer) emptyCartHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	log.Debug("emptying cart")
 
-	if err := fe.emptyCart(r.Context(), sessionID(r)); err != nil {
+	if err := fe.emptyCart(r.Context(), userID(r)); err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to empty cart"), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("location", baseUrl + "/")
+	w.Header().Set("location", baseUrl+"/")
 	w.WriteHeader(http.StatusFound)
 }
 
 func (fe *frontendServer) viewCartHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	log.Debug("view user cart")
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
 		return
 	}
-	cart, err := fe.getCart(r.Context(), sessionID(r))
+	cart, err := fe.getCart(r.Context(), userID(r))
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
 		return
 	}
 
 	// ignores the error retrieving recommendations since it is not critical
-	recommendations, err := fe.getRecommendations(r.Context(), sessionID(r), cartIDs(cart))
+	recommendations, err := fe.getRecommendations(r.Context(), userID(r), cartIDs(cart))
 	if err != nil {
 		log.WithField("error", err).Warn("failed to get product recommendations")
 	}
@@ -287,7 +525,7 @@ func (fe *frontendServer) viewCartHandler(w http.ResponseWriter, r *http.Request
 			renderHTTPError(log, r, w, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId()), http.StatusInternalServerError)
 			return
 		}
-		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
+		price, err := fe.productPrice(r.Context(), p, currentCurrency(r))
 		if err != nil {
 			renderHTTPError(log, r, w, errors.Wrapf(err, "could not convert currency for product #%s", item.GetProductId()), http.StatusInternalServerError)
 			return
@@ -312,13 +550,143 @@ func (fe *frontendServer) viewCartHandler(w http.ResponseWriter, r *http.Request
 		"total_cost":       totalPrice,
 		"items":            items,
 		"expiration_years": []int{year, year + 1, year + 2, year + 3, year + 4},
+		"idempotency_key":  uuid.NewString(),
+		"prices_status":    r.URL.Query().Get("prices"),
+		"stock_status":     r.URL.Query().Get("stock"),
+		"promo_status":     r.URL.Query().Get("promo"),
+		"promo_code":       r.URL.Query().Get("promo_code"),
 	})); err != nil {
-		log.Println(err)
+		log.Error(err)
 	}
 }
 
+func (fe *frontendServer) wishlistHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	log.Debug("view user wishlist")
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
+	list, err := fe.wishlist.Items(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve wishlist"), http.StatusInternalServerError)
+		return
+	}
+
+	type wishlistItemView struct {
+		Item  *pb.Product
+		Price *pb.Money
+		Added string
+	}
+	items := make([]wishlistItemView, len(list))
+	for i, item := range list {
+		p, err := fe.getProduct(r.Context(), item.ProductID)
+		if err != nil {
+			renderHTTPError(log, r, w, errors.Wrapf(err, "could not retrieve product #%s", item.ProductID), http.StatusInternalServerError)
+			return
+		}
+		price, err := fe.productPrice(r.Context(), p, currentCurrency(r))
+		if err != nil {
+			renderHTTPError(log, r, w, errors.Wrapf(err, "could not convert currency for product #%s", item.ProductID), http.StatusInternalServerError)
+			return
+		}
+		items[i] = wishlistItemView{
+			Item:  p,
+			Price: price,
+			Added: item.Added.Format("January 2, 2006"),
+		}
+	}
+
+	if err := templates.ExecuteTemplate(w, "wishlist", injectCommonTemplateData(r, map[string]interface{}{
+		"currencies": currencies,
+		"items":      items,
+		"cart_size":  cartSize(cart),
+	})); err != nil {
+		log.Error(err)
+	}
+}
+
+// addToWishlistHandler saves the product of the form to the user's wishlist.
+func (fe *frontendServer) addToWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	payload := validator.WishlistPayload{ProductID: r.FormValue("product_id")}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("product", payload.ProductID).Debug("adding to wishlist")
+
+	p, err := fe.getProduct(r.Context(), payload.ProductID)
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve product"), http.StatusInternalServerError)
+		return
+	}
+	if err := fe.wishlist.Add(r.Context(), userID(r), p.GetId()); errors.Is(err, wishlist.ErrFull) {
+		renderHTTPError(log, r, w, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to add to wishlist"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("location", baseUrl+"/wishlist")
+	w.WriteHeader(http.StatusFound)
+}
+
+// removeFromWishlistHandler removes the product of the form from the user's
+// wishlist.
+func (fe *frontendServer) removeFromWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	payload := validator.WishlistPayload{ProductID: r.FormValue("product_id")}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("product", payload.ProductID).Debug("removing from wishlist")
+
+	if err := fe.wishlist.Remove(r.Context(), userID(r), payload.ProductID); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to remove from wishlist"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("location", baseUrl+"/wishlist")
+	w.WriteHeader(http.StatusFound)
+}
+
+// moveToCartHandler adds one of the product of the form to the user's cart
+// and takes it off their wishlist. If it cannot be taken off, it stays on the
+// wishlist as well as going in the cart.
+func (fe *frontendServer) moveToCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	payload := validator.WishlistPayload{ProductID: r.FormValue("product_id")}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("product", payload.ProductID).Debug("moving from wishlist to cart")
+
+	p, err := fe.getProduct(r.Context(), payload.ProductID)
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve product"), http.StatusInternalServerError)
+		return
+	}
+	if err := fe.insertCart(r.Context(), userID(r), p.GetId(), 1); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to add to cart"), http.StatusInternalServerError)
+		return
+	}
+	if err := fe.wishlist.Remove(r.Context(), userID(r), p.GetId()); err != nil {
+		log.WithField("error", err).Warn("could not remove the product from the wishlist")
+	}
+	w.Header().Set("location", baseUrl+"/cart")
+	w.WriteHeader(http.StatusFound)
+}
+
 func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	log.Debug("placing order")
 
 	var (
@@ -332,6 +700,11 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		ccMonth, _    = strconv.ParseInt(r.FormValue("credit_card_expiration_month"), 10, 32)
 		ccYear, _     = strconv.ParseInt(r.FormValue("credit_card_expiration_year"), 10, 32)
 		ccCVV, _      = strconv.ParseInt(r.FormValue("credit_card_cvv"), 10, 32)
+
+		separateBilling   = r.FormValue("separate_billing") == "on"
+		billingZipCode, _ = strconv.ParseInt(r.FormValue("billing_zip_code"), 10, 32)
+
+		promoCode = strings.ToUpper(strings.TrimSpace(r.FormValue("promo_code")))
 	)
 
 	payload := validator.PlaceOrderPayload{
@@ -345,42 +718,102 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		CcMonth:       ccMonth,
 		CcYear:        ccYear,
 		CcCVV:         ccCVV,
+		GiftMessage:   r.FormValue("gift_message"),
+		CustomerNote:  r.FormValue("customer_note"),
+	}
+	if separateBilling {
+		payload.SeparateBilling = true
+		payload.BillingStreetAddress = r.FormValue("billing_street_address")
+		payload.BillingZipCode = billingZipCode
+		payload.BillingCity = r.FormValue("billing_city")
+		payload.BillingState = r.FormValue("billing_state")
+		payload.BillingCountry = r.FormValue("billing_country")
 	}
 	if err := payload.Validate(); err != nil {
 		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
 		return
 	}
+	if len(promoCode) > maxPromoCodeLen {
+		invalidPromoCode(w, promoCode)
+		return
+	}
 
-	order, err := pb.NewCheckoutServiceClient(fe.checkoutSvcConn).
-		PlaceOrder(r.Context(), &pb.PlaceOrderRequest{
-			Email: payload.Email,
-			CreditCard: &pb.CreditCardInfo{
-				CreditCardNumber:          payload.CcNumber,
-				CreditCardExpirationMonth: int32(payload.CcMonth),
-				CreditCardExpirationYear:  int32(payload.CcYear),
-				CreditCardCvv:             int32(payload.CcCVV)},
-			UserId:       sessionID(r),
-			UserCurrency: currentCurrency(r),
-			Address: &pb.Address{
-				StreetAddress: payload.StreetAddress,
-				City:          payload.City,
-				State:         payload.State,
-				ZipCode:       int32(payload.ZipCode),
-				Country:       payload.Country},
-		})
+	var billingAddress *pb.Address
+	if payload.SeparateBilling {
+		billingAddress = &pb.Address{
+			StreetAddress: payload.BillingStreetAddress,
+			City:          payload.BillingCity,
+			State:         payload.BillingState,
+			ZipCode:       int32(payload.BillingZipCode),
+			Country:       payload.BillingCountry}
+	}
+
+	idempotencyKey := r.FormValue("idempotency_key")
+	if idempotencyKey == "" {
+		// Forms rendered before the key was added cannot be deduplicated.
+		idempotencyKey = uuid.NewString()
+	}
+	order, err := fe.placeOrder(r.Context(), &pbv2.PlaceOrderRequest{
+		Email: payload.Email,
+		PaymentMethod: &pbv2.PaymentMethod{Method: &pbv2.PaymentMethod_CreditCard{CreditCard: &pb.CreditCardInfo{
+			CreditCardNumber:          payload.CcNumber,
+			CreditCardExpirationMonth: int32(payload.CcMonth),
+			CreditCardExpirationYear:  int32(payload.CcYear),
+			CreditCardCvv:             int32(payload.CcCVV)}}},
+		UserId:         userID(r),
+		UserCurrency:   currentCurrency(r),
+		Locale:         currentLocale(r),
+		IdempotencyKey: idempotencyKey,
+		Address: &pb.Address{
+			StreetAddress: payload.StreetAddress,
+			City:          payload.City,
+			State:         payload.State,
+			ZipCode:       int32(payload.ZipCode),
+			Country:       payload.Country},
+		BillingAddress: billingAddress,
+		GiftMessage:    payload.GiftMessage,
+		CustomerNote:   payload.CustomerNote,
+		PromoCode:      promoCode,
+	})
 	if err != nil {
+		switch rpcerrors.Classify(err).Reason {
+		case reasonPricesChanged:
+			// show the cart again with the new prices
+			log.WithField("error", err).Info("prices changed during checkout")
+			w.Header().Set("Location", baseUrl+"/cart?prices=changed")
+			w.WriteHeader(http.StatusFound)
+			return
+		case reasonOutOfStock:
+			log.WithField("error", err).Info("items out of stock during checkout")
+			w.Header().Set("Location", baseUrl+"/cart?stock=out")
+			w.WriteHeader(http.StatusFound)
+			return
+		case reasonPromoCodeInvalid:
+			log.WithField("error", err).Info("promo code rejected during checkout")
+			invalidPromoCode(w, promoCode)
+			return
+		}
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to complete the order"), http.StatusInternalServerError)
 		return
 	}
 	log.WithField("order", order.GetOrder().GetOrderId()).Info("order placed")
 
 	order.GetOrder().GetItems()
-	recommendations, _ := fe.getRecommendations(r.Context(), sessionID(r), nil)
+	recommendations, _ := fe.getRecommendations(r.Context(), userID(r), nil)
 
-	totalPaid := *order.GetOrder().GetShippingCost()
+	// the discount is what the items and shipping cost less what was paid
+	total := *order.GetOrder().GetShippingCost()
 	for _, v := range order.GetOrder().GetItems() {
 		multPrice := money.MultiplySlow(*v.GetCost(), uint32(v.GetItem().GetQuantity()))
-		totalPaid = money.Must(money.Sum(totalPaid, multPrice))
+		total = money.Must(money.Sum(total, multPrice))
+	}
+	totalPaid := order.GetTotalPaid()
+	if totalPaid == nil {
+		totalPaid = &total
+	}
+	var discount *pb.Money
+	if d, err := money.Sum(total, money.Negate(*totalPaid)); err == nil && money.IsPositive(d) {
+		discount = &d
 	}
 
 	currencies, err := fe.getCurrencies(r.Context())
@@ -393,11 +826,197 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		"show_currency":   false,
 		"currencies":      currencies,
 		"order":           order.GetOrder(),
-		"total_paid":      &totalPaid,
+		"total_paid":      totalPaid,
+		"discount":        discount,
+		"promo_code":      promoCode,
 		"recommendations": recommendations,
 	})); err != nil {
-		log.Println(err)
+		log.Error(err)
+	}
+}
+
+// invalidPromoCode sends the user back to their cart to correct the promo
+// code they placed their order with, which is shown with the error unless
+// it is too long to.
+func invalidPromoCode(w http.ResponseWriter, code string) {
+	q := url.Values{"promo": {"invalid"}}
+	if len(code) <= maxPromoCodeLen {
+		q.Set("promo_code", code)
 	}
+	w.Header().Set("Location", baseUrl+"/cart?"+q.Encode())
+	w.WriteHeader(http.StatusFound)
+}
+
+// ordersHandler renders a page of the order history of the session's user.
+// The page query parameter is the token of the page, and the prev parameters
+// those of the pages before it, so that the page can link back to them.
+func (fe *frontendServer) ordersHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	pageToken := r.FormValue("page")
+	prev := r.Form["prev"]
+	log.WithField("page", pageToken).Debug("listing orders")
+
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+	resp, err := fe.listOrders(r.Context(), userID(r), pageToken)
+	if err != nil {
+		renderHTTPError(log, r, w, err, http.StatusInternalServerError)
+		return
+	}
+
+	type orderView struct {
+		ID         string
+		Date       string
+		Total      *pb.Money
+		Status     string
+		TrackingID string
+	}
+	orders := make([]orderView, len(resp.GetOrders()))
+	for i, o := range resp.GetOrders() {
+		orders[i] = orderView{
+			ID:         o.GetOrderId(),
+			Date:       o.GetCreatedAt().AsTime().Format("January 2, 2006"),
+			Total:      o.GetTotalPaid(),
+			Status:     orderStatusLabel(o.GetStatus()),
+			TrackingID: o.GetShippingTrackingId(),
+		}
+	}
+
+	// the newer page is the last of those before this one, the first page
+	// having no token, and the older page has this one before it
+	var newerURL, olderURL string
+	if pageToken != "" {
+		q := url.Values{}
+		if n := len(prev); n > 0 {
+			q["page"], q["prev"] = prev[n-1:], prev[:n-1]
+		}
+		newerURL = ordersURL(q)
+	}
+	if next := resp.GetNextPageToken(); next != "" {
+		older := prev
+		if pageToken != "" {
+			older = append(slices.Clone(prev), pageToken)
+		}
+		olderURL = ordersURL(url.Values{"page": {next}, "prev": older})
+	}
+
+	if err := templates.ExecuteTemplate(w, "orders", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": false,
+		"currencies":    currencies,
+		"orders":        orders,
+		"newer_url":     newerURL,
+		"older_url":     olderURL,
+	})); err != nil {
+		log.Error(err)
+	}
+}
+
+// orderHandler renders an order of the session's user with the products it
+// bought and where its shipment is. Orders of other users are not found.
+func (fe *frontendServer) orderHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	id := mux.Vars(r)["id"]
+	log.WithField("order_id", id).Debug("showing order")
+
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+	order, err := fe.getOrder(r.Context(), id)
+	if status.Code(errors.Cause(err)) == codes.NotFound {
+		renderHTTPError(log, r, w, errors.Errorf("no order %s", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		renderHTTPError(log, r, w, err, http.StatusInternalServerError)
+		return
+	}
+	if order.GetUserId() != userID(r) {
+		renderHTTPError(log, r, w, errors.Errorf("no order %s", id), http.StatusNotFound)
+		return
+	}
+
+	type itemView struct {
+		Item     *pb.Product
+		Quantity int32
+	}
+	items := make([]itemView, len(order.GetItems()))
+	for i, item := range order.GetItems() {
+		p, err := fe.getProduct(r.Context(), item.GetProductId())
+		if err != nil {
+			renderHTTPError(log, r, w, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId()), http.StatusInternalServerError)
+			return
+		}
+		items[i] = itemView{Item: p, Quantity: item.GetQuantity()}
+	}
+
+	// the order is still worth showing when the shipment cannot be tracked,
+	// so the page then says so instead of failing
+	var tracking, delivery string
+	if t := order.GetShippingTrackingId(); t != "" {
+		tracking = "Unavailable"
+		shipment, err := fe.getShipment(r.Context(), t)
+		if err != nil {
+			log.WithField("error", err).Warn("could not track shipment")
+		} else {
+			tracking = shipmentStatusLabel(shipment.GetStatus())
+			if d := shipment.GetEstimatedDelivery(); d != nil {
+				delivery = d.AsTime().Format("January 2, 2006 15:04 MST")
+			}
+		}
+	}
+
+	var discount *pb.Money
+	if d := order.GetDiscount(); d != nil && !money.IsZero(*d) {
+		discount = d
+	}
+
+	if err := templates.ExecuteTemplate(w, "order-detail", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": false,
+		"currencies":    currencies,
+		"order":         order,
+		"date":          order.GetCreatedAt().AsTime().Format("January 2, 2006"),
+		"status":        orderStatusLabel(order.GetStatus()),
+		"items":         items,
+		"tracking":      tracking,
+		"delivery":      delivery,
+		"discount":      discount,
+	})); err != nil {
+		log.Error(err)
+	}
+}
+
+// ordersURL returns the URL of the order history page with the query q.
+func ordersURL(q url.Values) string {
+	if len(q) == 0 {
+		return baseUrl + "/orders"
+	}
+	return baseUrl + "/orders?" + q.Encode()
+}
+
+// orderStatusLabel returns the status of an order as shown to its user, such
+// as "Shipped".
+func orderStatusLabel(s pbv2.OrderStatus) string {
+	return enumLabel(strings.TrimPrefix(s.String(), "ORDER_STATUS_"))
+}
+
+// shipmentStatusLabel returns the status of a shipment as shown to its user,
+// such as "Out for delivery".
+func shipmentStatusLabel(s pb.ShipmentStatus) string {
+	return enumLabel(strings.TrimPrefix(s.String(), "SHIPMENT_STATUS_"))
+}
+
+// enumLabel turns the name of an enum value without its prefix, such as
+// OUT_FOR_DELIVERY, into a label, such as "Out for delivery".
+func enumLabel(name string) string {
+	if name == "UNSPECIFIED" {
+		return ""
+	}
+	return name[:1] + strings.ToLower(strings.ReplaceAll(name[1:], "_", " "))
 }
 
 func (fe *frontendServer) assistantHandler(w http.ResponseWriter, r *http.Request) {
@@ -411,19 +1030,122 @@ func (fe *frontendServer) assistantHandler(w http.ResponseWriter, r *http.Reques
 		"show_currency": false,
 		"currencies":    currencies,
 	})); err != nil {
-		log.Println(err)
+		log.Error(err)
+	}
+}
+
+// loginPageHandler renders the sign-in form, or the sign-up form on /signup.
+func (fe *frontendServer) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	fe.renderLogin(w, r, http.StatusOK, "")
+}
+
+// loginHandler signs the user in with the email and password of the
+// sign-in form.
+func (fe *frontendServer) loginHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	log.Debug("signing in")
+	a, token, err := fe.accounts.Login(r.Context(), r.FormValue("email"), r.FormValue("password"))
+	if errors.Is(err, accounts.ErrInvalidCredentials) {
+		fe.renderLogin(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to sign in"), http.StatusInternalServerError)
+		return
+	}
+	fe.signIn(w, r, a, token)
+}
+
+// signupHandler registers an account with the email and password of the
+// sign-up form and signs its user in.
+func (fe *frontendServer) signupHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	log.Debug("signing up")
+	email, password := r.FormValue("email"), r.FormValue("password")
+	if _, err := fe.accounts.Register(r.Context(), email, password); errors.Is(err, accounts.ErrEmailTaken) {
+		fe.renderLogin(w, r, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		fe.renderLogin(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	a, token, err := fe.accounts.Login(r.Context(), email, password)
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to sign in"), http.StatusInternalServerError)
+		return
+	}
+	fe.signIn(w, r, a, token)
+}
+
+// signIn sets the account cookie of a new session and merges the cart and
+// wishlist of the anonymous session into the account's, so that signing in
+// keeps them. If they cannot be merged, the items stay in the anonymous
+// session's. The session gets a new CSRF token, so that a token planted
+// before signing in cannot be used after.
+func (fe *frontendServer) signIn(w http.ResponseWriter, r *http.Request, a accounts.Account, token string) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	if from := userID(r); from != a.ID {
+		if _, err := fe.mergeCart(r.Context(), from, a.ID); err != nil {
+			log.WithField("error", err).Warn("could not merge the cart into the account's")
+		}
+		if err := fe.wishlist.Merge(r.Context(), from, a.ID); err != nil {
+			log.WithField("error", err).Warn("could not merge the wishlist into the account's")
+		}
+		if err := fe.preferences.Merge(r.Context(), from, a.ID); err != nil {
+			log.WithField("error", err).Warn("could not merge the preferences into the account's")
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieAccount,
+		Value:    token,
+		Path:     baseUrl + "/",
+		MaxAge:   cookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	rotateCSRFToken(w)
+	w.Header().Set("Location", baseUrl+"/")
+	w.WriteHeader(http.StatusFound)
+}
+
+func (fe *frontendServer) renderLogin(w http.ResponseWriter, r *http.Request, code int, errMsg string) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(code)
+	if err := templates.ExecuteTemplate(w, "login", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": false,
+		"currencies":    currencies,
+		"signup":        strings.HasSuffix(r.URL.Path, "/signup"),
+		"email":         r.FormValue("email"),
+		"login_error":   errMsg,
+	})); err != nil {
+		log.Error(err)
 	}
 }
 
 func (fe *frontendServer) logoutHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	log.Debug("logging out")
+	if c, err := r.Cookie(cookieAccount); err == nil {
+		if err := fe.accounts.Logout(r.Context(), c.Value); err != nil {
+			log.WithField("error", err).Warn("could not end the account session")
+		}
+		// the account cookie is set for the whole shop, not the directory
+		// of the page that set it like the other cookies
+		http.SetCookie(w, &http.Cookie{Name: cookieAccount, Path: baseUrl + "/", MaxAge: -1})
+	}
+	// so is the CSRF cookie, which the next page replaces
+	http.SetCookie(w, &http.Cookie{Name: cookieCSRF, Path: baseUrl + "/", MaxAge: -1})
 	for _, c := range r.Cookies() {
 		c.Expires = time.Now().Add(-time.Hour * 24 * 365)
 		c.MaxAge = -1
 		http.SetCookie(w, c)
 	}
-	w.Header().Set("Location", baseUrl + "/")
+	w.Header().Set("Location", baseUrl+"/")
 	w.WriteHeader(http.StatusFound)
 }
 
@@ -448,8 +1170,37 @@ func (fe *frontendServer) getProductByID(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
+// suggestHandler returns the products to suggest as the shopper types in the
+// search box, for its q query parameter. It returns up to limit of them,
+// defaultSuggestLimit unless the limit query parameter says otherwise.
+func (fe *frontendServer) suggestHandler(w http.ResponseWriter, r *http.Request) {
+	log := apiLogger(r)
+	query := r.URL.Query().Get("q")
+	if len(query) > maxSearchQueryLen {
+		writeAPIError(log, w, r, status.Errorf(codes.InvalidArgument, "q is longer than %d bytes", maxSearchQueryLen), http.StatusBadRequest)
+		return
+	}
+	limit := defaultSuggestLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			writeAPIError(log, w, r, status.Errorf(codes.InvalidArgument, "limit must be from 1 to %d", maxSuggestLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	suggestions, err := fe.suggestions.suggest(r.Context(), query, fe.searchProducts)
+	if err != nil {
+		writeAPIError(log, w, r, errors.Wrap(err, "could not search products"), http.StatusInternalServerError)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{
+		"suggestions": append([]suggestion{}, suggestions[:min(limit, len(suggestions))]...),
+	})
+}
+
 func (fe *frontendServer) chatBotHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	type Response struct {
 		Message string `json:"message"`
 	}
@@ -462,14 +1213,14 @@ func (fe *frontendServer) chatBotHandler(w http.ResponseWriter, r *http.Request)
 	var response LLMResponse
 
 	url := "http://" + fe.shoppingAssistantSvcAddr
-	req, err := http.NewRequest(http.MethodPost, url, r.Body)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, r.Body)
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to create request"), http.StatusInternalServerError)
 		return
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	res, err := http.DefaultClient.Do(req)
+	res, err := otelhttp.DefaultClient.Do(req)
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to send request"), http.StatusInternalServerError)
 		return
@@ -497,7 +1248,7 @@ func (fe *frontendServer) chatBotHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (fe *frontendServer) setCurrencyHandler(w http.ResponseWriter, r *http.Request) {
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
 	cur := r.FormValue("currency_code")
 	payload := validator.SetCurrencyPayload{Currency: cur}
 	if err := payload.Validate(); err != nil {
@@ -508,6 +1259,7 @@ func (fe *frontendServer) setCurrencyHandler(w http.ResponseWriter, r *http.Requ
 		Debug("setting currency")
 
 	if payload.Currency != "" {
+		fe.savePreferences(r, preferences.Preferences{Currency: payload.Currency})
 		http.SetCookie(w, &http.Cookie{
 			Name:   cookieCurrency,
 			Value:  payload.Currency,
@@ -522,9 +1274,48 @@ func (fe *frontendServer) setCurrencyHandler(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusFound)
 }
 
+func (fe *frontendServer) setLocaleHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	payload := validator.SetLocalePayload{Locale: r.FormValue("locale")}
+	if err := payload.Validate(); err != nil {
+		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("locale.new", payload.Locale).WithField("locale.old", currentLocale(r)).
+		Debug("setting locale")
+
+	fe.savePreferences(r, preferences.Preferences{Locale: payload.Locale})
+	referer := r.Header.Get("referer")
+	if referer == "" {
+		referer = baseUrl + "/"
+	}
+	w.Header().Set("Location", referer)
+	w.WriteHeader(http.StatusFound)
+}
+
+// savePreferences records p as the user's choices. A signed-in user keeps
+// them for good, and an anonymous one for as long as their session cookie.
+// The choice still applies to the current page if it cannot be saved, so
+// failures are only logged.
+func (fe *frontendServer) savePreferences(r *http.Request, p preferences.Preferences) {
+	log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+	var ttl time.Duration
+	if currentAccount(r) == nil {
+		ttl = cookieMaxAge * time.Second
+	}
+	if err := fe.preferences.Save(r.Context(), userID(r), p, ttl); err != nil {
+		log.WithField("error", err).Warn("could not save preferences")
+	}
+}
+
 // chooseAd queries for advertisements available and randomly chooses one, if
-// available. It ignores the error retrieving the ad since it is not critical.
-func (fe *frontendServer) chooseAd(ctx context.Context, ctxKeys []string, log logrus.FieldLogger) *pb.Ad {
+// available. It ignores the error retrieving the ad since it is not critical,
+// and skips ads altogether while they are shed.
+func (fe *frontendServer) chooseAd(ctx context.Context, ctxKeys []string, log *logging.Logger) *pb.Ad {
+	if admit.Sheds(ctx, admission.Ads) {
+		log.Debug("skipping ads while shedding load")
+		return nil
+	}
 	ads, err := fe.getAd(ctx, ctxKeys)
 	if err != nil {
 		log.WithField("error", err).Warn("failed to retrieve ads")
@@ -533,7 +1324,20 @@ func (fe *frontendServer) chooseAd(ctx context.Context, ctxKeys []string, log lo
 	return ads[rand.Intn(len(ads))]
 }
 
-func renderHTTPError(log logrus.FieldLogger, r *http.Request, w http.ResponseWriter, err error, code int) {
+func renderHTTPError(log *logging.Logger, r *http.Request, w http.ResponseWriter, err error, code int) {
+	var retryable bool
+	if _, ok := status.FromError(err); ok {
+		c := rpcerrors.Classify(err)
+		log = log.WithFields(logging.Fields{
+			"error_reason":    c.Reason,
+			"error_fault":     c.Fault,
+			"error_retryable": c.Retryable,
+		})
+		code = rpcHTTPStatus(c, code)
+		if retryable = c.Retryable; retryable && w.Header().Get("Retry-After") == "" {
+			w.Header().Set("Retry-After", strconv.Itoa(int(c.RetryDelay.Round(time.Second).Seconds())))
+		}
+	}
 	log.WithField("error", err).Error("request error")
 	errMsg := fmt.Sprintf("%+v", err)
 
@@ -543,15 +1347,46 @@ func renderHTTPError(log logrus.FieldLogger, r *http.Request, w http.ResponseWri
 		"error":       errMsg,
 		"status_code": code,
 		"status":      http.StatusText(code),
+		"retryable":   retryable,
 	})); templateErr != nil {
-		log.Println(templateErr)
+		log.Error(templateErr)
+	}
+}
+
+// rpcHTTPStatus maps a failed backend call to the HTTP status to answer with.
+// Handlers report backend failures as 500, which only errors of the system's
+// own making deserve; anything else keeps the code the handler chose.
+func rpcHTTPStatus(c rpcerrors.Classification, code int) int {
+	if code != http.StatusInternalServerError {
+		return code
+	}
+	switch c.Code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
 	}
+	return code
 }
 
 func injectCommonTemplateData(r *http.Request, payload map[string]interface{}) map[string]interface{} {
 	data := map[string]interface{}{
 		"session_id":        sessionID(r),
-		"request_id":        r.Context().Value(ctxKeyRequestID{}),
+		"csrf_token":        csrf.FromContext(r.Context()),
+		"account":           currentAccount(r),
+		"request_id":        requestid.FromContext(r.Context()),
 		"user_currency":     currentCurrency(r),
 		"platform_css":      plat.css,
 		"platform_name":     plat.provider,
@@ -570,7 +1405,17 @@ func injectCommonTemplateData(r *http.Request, payload map[string]interface{}) m
 	return data
 }
 
+// currentCurrency returns the currency the user chose, or the default one.
 func currentCurrency(r *http.Request) string {
+	if c := preferences.FromContext(r.Context()).Currency; c != "" {
+		return c
+	}
+	return cookieCurrencyOrDefault(r)
+}
+
+// cookieCurrencyOrDefault returns the currency in the currency cookie, or the
+// default one.
+func cookieCurrencyOrDefault(r *http.Request) string {
 	c, _ := r.Cookie(cookieCurrency)
 	if c != nil {
 		return c.Value
@@ -578,6 +1423,25 @@ func currentCurrency(r *http.Request) string {
 	return defaultCurrency
 }
 
+// currentLocale returns the language tag the user chose, or the one their
+// browser prefers.
+func currentLocale(r *http.Request) string {
+	if l := preferences.FromContext(r.Context()).Locale; l != "" {
+		return l
+	}
+	return acceptedLocale(r)
+}
+
+// acceptedLocale returns the preferred language tag from the Accept-Language
+// header, ignoring quality values.
+func acceptedLocale(r *http.Request) string {
+	lang := r.Header.Get("Accept-Language")
+	if i := strings.IndexAny(lang, ",;"); i >= 0 {
+		lang = lang[:i]
+	}
+	return strings.TrimSpace(lang)
+}
+
 func sessionID(r *http.Request) string {
 	v := r.Context().Value(ctxKeySessionID{})
 	if v != nil {
@@ -586,6 +1450,21 @@ func sessionID(r *http.Request) string {
 	return ""
 }
 
+// currentAccount returns the account signed in for r, or nil.
+func currentAccount(r *http.Request) *accounts.Account {
+	a, _ := r.Context().Value(ctxKeyAccount{}).(*accounts.Account)
+	return a
+}
+
+// userID returns the ID the backends know r's user by: their account's ID
+// when they are signed in, and their session ID otherwise.
+func userID(r *http.Request) string {
+	if a := currentAccount(r); a != nil {
+		return a.ID
+	}
+	return sessionID(r)
+}
+
 func cartIDs(c []*pb.CartItem) []string {
 	out := make([]string, len(c))
 	for i, v := range c {
@@ -608,6 +1487,13 @@ func renderMoney(money pb.Money) string {
 	return fmt.Sprintf("%s%d.%02d", currencyLogo, money.GetUnits(), money.GetNanos()/10000000)
 }
 
+// renderStars renders a rating from 1 to 5 as five stars, as many of them
+// filled as the rating rounds to.
+func renderStars(rating float64) string {
+	n := min(max(int(math.Round(rating)), 0), 5)
+	return strings.Repeat("★", n) + strings.Repeat("☆", 5-n)
+}
+
 func renderCurrencyLogo(currencyCode string) string {
 	logos := map[string]string{
 		"USD": "$",
@@ -0,0 +1,870 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/accounts"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/apikey"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/csrf"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/preferences"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/ratelimit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/rpcerrors"
+)
+
+// fakeOrders is a checkout service getting and listing the orders it was
+// given, whose page tokens are the indexes the pages start at.
+type fakeOrders struct {
+	pbv2.UnimplementedCheckoutServiceServer
+	orders []*pbv2.Order
+}
+
+func (f *fakeOrders) ListOrders(_ context.Context, req *pbv2.ListOrdersRequest) (*pbv2.ListOrdersResponse, error) {
+	var mine []*pbv2.Order
+	for _, o := range f.orders {
+		if o.GetUserId() == req.GetUserId() {
+			mine = append(mine, o)
+		}
+	}
+	start, _ := strconv.Atoi(req.GetPageToken())
+	end := min(start+int(req.GetPageSize()), len(mine))
+	resp := &pbv2.ListOrdersResponse{Orders: mine[start:end]}
+	if end < len(mine) {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+func (f *fakeOrders) GetOrder(_ context.Context, req *pbv2.GetOrderRequest) (*pbv2.Order, error) {
+	for _, o := range f.orders {
+		if o.GetOrderId() == req.GetOrderId() {
+			return o, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no order %s", req.GetOrderId())
+}
+
+func TestOrdersHandler(t *testing.T) {
+	checkout := &fakeOrders{}
+	created := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	for i := range ordersPageSize + 2 {
+		checkout.orders = append(checkout.orders, &pbv2.Order{
+			OrderId:            "order-" + strconv.Itoa(i),
+			UserId:             "user-1",
+			TotalPaid:          &pb.Money{CurrencyCode: "USD", Units: int64(i + 1)},
+			ShippingTrackingId: "TRACK-" + strconv.Itoa(i),
+			Status:             pbv2.OrderStatus_ORDER_STATUS_SHIPPED,
+			CreatedAt:          timestamppb.New(created),
+		})
+	}
+	checkout.orders = append(checkout.orders, &pbv2.Order{OrderId: "order-of-someone-else", UserId: "user-2"})
+	fe := newFakeFrontend(t, nil)
+	fe.checkoutSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pbv2.RegisterCheckoutServiceServer(srv, checkout)
+	})
+
+	get := func(url string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "user-1")
+		w := httptest.NewRecorder()
+		fe.ordersHandler(w, r.WithContext(ctx))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, want 200: %s", url, w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	first := get("/orders")
+	if n := strings.Count(first, `title="Order `); n != ordersPageSize {
+		t.Errorf("first page lists %d orders, want %d", n, ordersPageSize)
+	}
+	for _, want := range []string{"TRACK-0", "March 14, 2026", "Shipped", "$1.00", `href="/orders?page=10"`} {
+		if !strings.Contains(first, want) {
+			t.Errorf("first page does not contain %q", want)
+		}
+	}
+	if strings.Contains(first, "Newer orders") || strings.Contains(first, "order-of-someone-else") {
+		t.Error("first page links to newer orders or lists those of another user")
+	}
+
+	second := get("/orders?page=10")
+	if n := strings.Count(second, `title="Order `); n != 2 || !strings.Contains(second, "TRACK-11") {
+		t.Errorf("second page lists %d orders, want the last 2", n)
+	}
+	if !strings.Contains(second, `href="/orders"`) || strings.Contains(second, "Older orders") {
+		t.Error("second page does not link back to the first only")
+	}
+}
+
+func TestOrderHandler(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	shipped, err := pb.NewShippingServiceClient(fe.shippingSvcConn).ShipOrder(context.Background(), &pb.ShipOrderRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := &pbv2.Order{
+		OrderId:                "order-1",
+		UserId:                 "user-1",
+		ShippingAddress:        &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", State: "CA", Country: "United States", ZipCode: 94043},
+		MaskedCreditCardNumber: "****-****-****-0454",
+		CardBrand:              "visa",
+		TotalPaid:              &pb.Money{CurrencyCode: "USD", Units: 119},
+		ShippingTrackingId:     shipped.GetTrackingId(),
+		CreatedAt:              timestamppb.New(time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)),
+		Items:                  []*pb.CartItem{{ProductId: "1YMWWN1N4O", Quantity: 2}},
+		Status:                 pbv2.OrderStatus_ORDER_STATUS_SHIPPED,
+	}
+	untracked := &pbv2.Order{OrderId: "order-2", UserId: "user-1", ShippingTrackingId: "no-such-shipment"}
+	someoneElses := &pbv2.Order{OrderId: "order-3", UserId: "user-2"}
+	fe.checkoutSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pbv2.RegisterCheckoutServiceServer(srv, &fakeOrders{orders: []*pbv2.Order{order, untracked, someoneElses}})
+	})
+
+	get := func(id string, wantCode int) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/order/"+id, nil)
+		r = mux.SetURLVars(r, map[string]string{"id": id})
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "user-1")
+		w := httptest.NewRecorder()
+		fe.orderHandler(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("GET /order/%s = %d, want %d: %s", id, w.Code, wantCode, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	page := get("order-1", http.StatusOK)
+	for _, want := range []string{"March 14, 2026", "Shipped", "Watch", "Quantity: 2", "Mountain View, CA 94043", "visa ****-****-****-0454", "$119.00", shipped.GetTrackingId(), "In transit"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("order page does not contain %q", want)
+		}
+	}
+	if page := get("order-2", http.StatusOK); !strings.Contains(page, "Unavailable") {
+		t.Error("order page of an untracked shipment does not say tracking is unavailable")
+	}
+	get("order-3", http.StatusNotFound)
+	unknown := get("no-such-order", http.StatusNotFound)
+	if strings.Contains(unknown, "failed to get order") {
+		t.Error("page of an unknown order shows the backend error")
+	}
+
+	fe.checkoutSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pbv2.RegisterCheckoutServiceServer(srv, &pbv2.UnimplementedCheckoutServiceServer{})
+	})
+	get("order-1", http.StatusInternalServerError)
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	fe.accounts = accounts.NewMemory()
+	ctx := context.Background()
+	if err := fe.insertCart(ctx, "session-1", "OLJCESPC7Z", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := fe.wishlist.Add(ctx, "session-1", "66VCHSJNUP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fe.preferences.Save(ctx, "session-1", preferences.Preferences{Currency: "EUR"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(h http.HandlerFunc, path string, form url.Values, wantCode int) *http.Response {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		h(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("POST %s = %d, want %d: %s", path, w.Code, wantCode, w.Body)
+		}
+		return w.Result()
+	}
+	form := url.Values{"email": {"someone@example.com"}, "password": {"correct horse"}}
+
+	resp := post(fe.signupHandler, "/signup", form, http.StatusFound)
+	var token string
+	for _, c := range resp.Cookies() {
+		if c.Name == cookieAccount {
+			token = c.Value
+		}
+	}
+	a, err := fe.accounts.Session(ctx, token)
+	if err != nil {
+		t.Fatalf("signing up did not sign in: %v", err)
+	}
+	if cart, _ := fe.getCart(ctx, a.ID); len(cart) != 1 || cart[0].GetQuantity() != 2 {
+		t.Errorf("account cart = %v, want the session's", cart)
+	}
+	if cart, _ := fe.getCart(ctx, "session-1"); len(cart) != 0 {
+		t.Errorf("session cart = %v, want it emptied", cart)
+	}
+	if items, _ := fe.wishlist.Items(ctx, a.ID); len(items) != 1 || items[0].ProductID != "66VCHSJNUP" {
+		t.Errorf("account wishlist = %v, want the session's", items)
+	}
+	if p, _ := fe.preferences.Get(ctx, a.ID); p.Currency != "EUR" {
+		t.Errorf("account preferences = %+v, want the session's currency EUR", p)
+	}
+
+	var got string
+	r := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	r.AddCookie(&http.Cookie{Name: cookieAccount, Value: token})
+	r = r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, "session-1"))
+	withAccount(fe.accounts, logging.New("frontend"), http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = userID(r)
+	}))(httptest.NewRecorder(), r)
+	if got != a.ID {
+		t.Errorf("user ID of a signed-in request = %q, want the account's %q", got, a.ID)
+	}
+
+	post(fe.signupHandler, "/signup", form, http.StatusConflict)
+	post(fe.loginHandler, "/login", url.Values{"email": form["email"], "password": {"wrong password"}}, http.StatusUnauthorized)
+
+	// signing in again merges the session's new cart into the account's
+	for _, item := range []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}, {ProductId: "66VCHSJNUP", Quantity: 1}} {
+		if err := fe.insertCart(ctx, "session-1", item.GetProductId(), item.GetQuantity()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	post(fe.loginHandler, "/login", form, http.StatusFound)
+	cart, _ := fe.getCart(ctx, a.ID)
+	quantities := make(map[string]int32)
+	for _, item := range cart {
+		quantities[item.GetProductId()] += item.GetQuantity()
+	}
+	if len(cart) != 2 || quantities["OLJCESPC7Z"] != 3 || quantities["66VCHSJNUP"] != 1 {
+		t.Errorf("account cart after signing in again = %v, want OLJCESPC7Z x3 and 66VCHSJNUP x1", cart)
+	}
+	if cart, _ := fe.getCart(ctx, "session-1"); len(cart) != 0 {
+		t.Errorf("session cart after signing in again = %v, want it emptied", cart)
+	}
+}
+
+func TestHomePagination(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	// no fake serves ads, so the page goes without
+	fe.adSvcConn = fe.productCatalogSvcConn
+	get := func(query string, wantCode int) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		fe.homeHandler(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("GET /?%s = %d, want %d: %s", query, w.Code, wantCode, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	page := get("", http.StatusOK)
+	for _, want := range []string{"Sunglasses", "Tank Top", "Watch"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("home page does not list %q", want)
+		}
+	}
+	if strings.Contains(page, "Page 1 of") {
+		t.Error("home page is paginated with fewer products than fit on a page")
+	}
+
+	page = get("sort=price_desc&size=2", http.StatusOK)
+	if i, j := strings.Index(page, "Watch"), strings.Index(page, "Sunglasses"); i < 0 || j < i || strings.Contains(page, "Tank Top") {
+		t.Error("first page by descending price does not list Watch then Sunglasses alone")
+	}
+	if !strings.Contains(page, "Page 1 of 2") || !strings.Contains(page, `href="/?page=2&amp;size=2&amp;sort=price_desc"`) {
+		t.Error("first page does not link to the second")
+	}
+	page = get("sort=price_desc&size=2&page=2", http.StatusOK)
+	if !strings.Contains(page, "Tank Top") || strings.Contains(page, "Watch") {
+		t.Error("second page by descending price does not list Tank Top alone")
+	}
+	if page := get("size=2&page=3", http.StatusOK); !strings.Contains(page, "There are no products on this page.") {
+		t.Error("page past the end does not say it is empty")
+	}
+
+	for _, query := range []string{"sort=popularity", "page=0", "page=two", "size=1000"} {
+		get(query, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	fe.suggestions = newSuggestionCache()
+	get := func(h http.HandlerFunc, path string, wantCode int) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		h(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("GET %s = %d, want %d: %s", path, w.Code, wantCode, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	page := get(fe.searchHandler, "/search?q=watch", http.StatusOK)
+	if !strings.Contains(page, `Results for "watch"`) || !strings.Contains(page, "$109.99") || strings.Contains(page, "Sunglasses") {
+		t.Error("search page for watch does not list the Watch alone")
+	}
+	if page := get(fe.searchHandler, "/search?q=nothing+like+it", http.StatusOK); !strings.Contains(page, "No products match") {
+		t.Error("search page without results does not say so")
+	}
+	if page := get(fe.searchHandler, "/search", http.StatusOK); !strings.Contains(page, "Enter a product name") {
+		t.Error("search page without a query does not ask for one")
+	}
+	get(fe.searchHandler, "/search?q="+strings.Repeat("a", maxSearchQueryLen+1), http.StatusUnprocessableEntity)
+
+	var body struct{ Suggestions []suggestion }
+	if err := json.Unmarshal([]byte(get(fe.suggestHandler, "/api/suggest?q=t", http.StatusOK)), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Suggestions == nil || len(body.Suggestions) != 0 {
+		t.Errorf("suggestions for t = %v, want an empty list", body.Suggestions)
+	}
+	if err := json.Unmarshal([]byte(get(fe.suggestHandler, "/api/suggest?q=ta&limit=1", http.StatusOK)), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Suggestions) != 1 || body.Suggestions[0].Name != "Tank Top" {
+		t.Errorf("suggestions for ta = %v, want Tank Top", body.Suggestions)
+	}
+	get(fe.suggestHandler, "/api/suggest?q=ta&limit=0", http.StatusBadRequest)
+	get(fe.suggestHandler, "/api/suggest?q=ta&limit="+strconv.Itoa(maxSuggestLimit+1), http.StatusBadRequest)
+}
+
+func TestPreferences(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	ctx := context.Background()
+
+	// do serves the request through withPreferences, as every page is.
+	do := func(h http.HandlerFunc, method, path string, form url.Values, header http.Header, wantCode int) {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		withPreferences(fe.preferences, logging.New("frontend"), h)(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, w.Code, wantCode, w.Body)
+		}
+	}
+	var got preferences.Preferences
+	page := func(_ http.ResponseWriter, r *http.Request) {
+		got = preferences.Preferences{Currency: currentCurrency(r), Locale: currentLocale(r)}
+		if p := preferences.FromContext(r.Context()); p != got {
+			t.Errorf("preferences sent to the backends = %+v, want %+v", p, got)
+		}
+	}
+	browser := http.Header{"Accept-Language": {"de-DE,de;q=0.9"}}
+
+	do(page, http.MethodGet, "/", nil, browser, http.StatusOK)
+	if want := (preferences.Preferences{Currency: defaultCurrency, Locale: "de-DE"}); got != want {
+		t.Errorf("preferences before choosing = %+v, want %+v", got, want)
+	}
+
+	do(fe.setCurrencyHandler, http.MethodPost, "/setCurrency", url.Values{"currency_code": {"JPY"}}, nil, http.StatusFound)
+	do(fe.setLocaleHandler, http.MethodPost, "/setLocale", url.Values{"locale": {"fr-FR"}}, nil, http.StatusFound)
+	do(fe.setLocaleHandler, http.MethodPost, "/setLocale", url.Values{"locale": {"not a locale"}}, nil, http.StatusUnprocessableEntity)
+
+	// the choices outlive the cookie and override the browser's language
+	do(page, http.MethodGet, "/", nil, browser, http.StatusOK)
+	if want := (preferences.Preferences{Currency: "JPY", Locale: "fr-FR"}); got != want {
+		t.Errorf("preferences after choosing = %+v, want %+v", got, want)
+	}
+	if p, _ := fe.preferences.Get(ctx, "session-1"); p.Currency != "JPY" {
+		t.Errorf("saved preferences = %+v, want currency JPY", p)
+	}
+}
+
+func TestWishlist(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	ctx := context.Background()
+
+	do := func(h http.HandlerFunc, method, path string, form url.Values, wantCode int) string {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		h(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, w.Code, wantCode, w.Body)
+		}
+		return w.Body.String()
+	}
+	for _, id := range []string{"OLJCESPC7Z", "66VCHSJNUP", "OLJCESPC7Z"} {
+		do(fe.addToWishlistHandler, http.MethodPost, "/wishlist", url.Values{"product_id": {id}}, http.StatusFound)
+	}
+	do(fe.addToWishlistHandler, http.MethodPost, "/wishlist", url.Values{"product_id": {"no-such-product"}}, http.StatusNotFound)
+	do(fe.addToWishlistHandler, http.MethodPost, "/wishlist", nil, http.StatusUnprocessableEntity)
+
+	page := do(fe.wishlistHandler, http.MethodGet, "/wishlist", nil, http.StatusOK)
+	for _, want := range []string{"Wishlist (2)", "Sunglasses", "$19.99"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("wishlist page does not contain %q", want)
+		}
+	}
+
+	do(fe.moveToCartHandler, http.MethodPost, "/wishlist/move", url.Values{"product_id": {"OLJCESPC7Z"}}, http.StatusFound)
+	if cart, _ := fe.getCart(ctx, "session-1"); len(cart) != 1 || cart[0].GetProductId() != "OLJCESPC7Z" || cart[0].GetQuantity() != 1 {
+		t.Errorf("cart after moving OLJCESPC7Z = %v, want one of it", cart)
+	}
+	do(fe.removeFromWishlistHandler, http.MethodPost, "/wishlist/remove", url.Values{"product_id": {"66VCHSJNUP"}}, http.StatusFound)
+	if items, _ := fe.wishlist.Items(ctx, "session-1"); len(items) != 0 {
+		t.Errorf("wishlist after moving and removing its products = %v, want it emptied", items)
+	}
+	if page := do(fe.wishlistHandler, http.MethodGet, "/wishlist", nil, http.StatusOK); !strings.Contains(page, "Your wishlist is empty!") {
+		t.Error("empty wishlist page does not say so")
+	}
+}
+
+// fakeReviews is a review service approving every review it is submitted.
+type fakeReviews struct {
+	pb.UnimplementedReviewServiceServer
+	reviews []*pb.Review
+}
+
+func (f *fakeReviews) SubmitReview(_ context.Context, req *pb.SubmitReviewRequest) (*pb.Review, error) {
+	r := &pb.Review{
+		Id:         strconv.Itoa(len(f.reviews)),
+		ProductId:  req.GetProductId(),
+		UserId:     req.GetUserId(),
+		AuthorName: req.GetAuthorName(),
+		Rating:     req.GetRating(),
+		Text:       req.GetText(),
+		Status:     pb.Review_APPROVED,
+		CreatedAt:  timestamppb.New(time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)),
+	}
+	f.reviews = append(f.reviews, r)
+	return r, nil
+}
+
+func (f *fakeReviews) ListReviews(_ context.Context, req *pb.ListReviewsRequest) (*pb.ListReviewsResponse, error) {
+	resp := &pb.ListReviewsResponse{Rating: &pb.RatingSummary{}}
+	var sum int32
+	for _, r := range f.reviews {
+		if r.GetProductId() == req.GetProductId() {
+			resp.Reviews = append(resp.Reviews, r)
+			sum += r.GetRating()
+		}
+	}
+	if n := len(resp.Reviews); n > 0 {
+		resp.Rating = &pb.RatingSummary{ReviewCount: int32(n), AverageRating: float64(sum) / float64(n)}
+	}
+	return resp, nil
+}
+
+func TestReviews(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	reviews := &fakeReviews{}
+	// the product page also asks for recommendations and ads, which fail
+	// without failing the page
+	conn := contract.Serve(t, func(srv *grpc.Server) {
+		fakes.NewProductCatalog().Register(srv)
+		pb.RegisterReviewServiceServer(srv, reviews)
+	})
+	fe.productCatalogSvcConn, fe.recommendationSvcConn, fe.adSvcConn = conn, conn, conn
+
+	do := func(method, path string, form url.Values, wantCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = mux.SetURLVars(r, map[string]string{"id": "OLJCESPC7Z"})
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		if method == http.MethodGet {
+			fe.productHandler(w, r.WithContext(ctx))
+		} else {
+			fe.submitReviewHandler(w, r.WithContext(ctx))
+		}
+		if w.Code != wantCode {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, w.Code, wantCode, w.Body)
+		}
+		return w
+	}
+
+	if page := do(http.MethodGet, "/product/OLJCESPC7Z", nil, http.StatusOK).Body.String(); !strings.Contains(page, "No reviews yet.") {
+		t.Error("product page without reviews does not say so")
+	}
+
+	for _, form := range []url.Values{
+		{"author_name": {"Sam"}, "rating": {"5"}, "text": {"Keeps the sun out."}},
+		{"author_name": {"Alex"}, "rating": {"2"}, "text": {"Scratched easily."}},
+	} {
+		w := do(http.MethodPost, "/product/OLJCESPC7Z/review", form, http.StatusFound)
+		if got, want := w.Header().Get("Location"), "/product/OLJCESPC7Z?review=submitted"; got != want {
+			t.Errorf("submitting a review redirects to %q, want %q", got, want)
+		}
+	}
+	for _, form := range []url.Values{
+		{"author_name": {"Sam"}, "rating": {"6"}, "text": {"Too good."}},
+		{"author_name": {"Sam"}, "rating": {"4"}},
+		{"rating": {"4"}, "text": {"Anonymous."}},
+	} {
+		do(http.MethodPost, "/product/OLJCESPC7Z/review", form, http.StatusUnprocessableEntity)
+	}
+	if len(reviews.reviews) != 2 || reviews.reviews[0].GetUserId() != "session-1" {
+		t.Errorf("submitted reviews = %v, want the 2 valid ones by session-1", reviews.reviews)
+	}
+
+	page := do(http.MethodGet, "/product/OLJCESPC7Z?review=submitted", nil, http.StatusOK).Body.String()
+	for _, want := range []string{"★★★★☆", "2 reviews", `title="3.5 out of 5"`, "Keeps the sun out.", "Alex", "March 14, 2026", "will appear here once it has been approved"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("product page does not contain %q", want)
+		}
+	}
+}
+
+// fakePromoCheckout is a checkout service taking 10% off the items of orders
+// with the promo code SPRING and rejecting any other code.
+type fakePromoCheckout struct {
+	pbv2.UnimplementedCheckoutServiceServer
+	promoCodes []string
+}
+
+func (f *fakePromoCheckout) PlaceOrder(_ context.Context, req *pbv2.PlaceOrderRequest) (*pbv2.PlaceOrderResponse, error) {
+	f.promoCodes = append(f.promoCodes, req.GetPromoCode())
+	paid := &pb.Money{CurrencyCode: "USD", Units: 25}
+	switch req.GetPromoCode() {
+	case "":
+	case "SPRING":
+		paid = &pb.Money{CurrencyCode: "USD", Units: 23}
+	default:
+		return nil, rpcerrors.Errorf(codes.InvalidArgument, reasonPromoCodeInvalid, "promo code %q is not valid", req.GetPromoCode())
+	}
+	return &pbv2.PlaceOrderResponse{
+		Order: &pb.OrderResult{
+			OrderId:      "order-1",
+			ShippingCost: &pb.Money{CurrencyCode: "USD", Units: 5},
+			Items: []*pb.OrderItem{{
+				Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2},
+				Cost: &pb.Money{CurrencyCode: "USD", Units: 10},
+			}},
+		},
+		TotalPaid: paid,
+	}, nil
+}
+
+func TestPlaceOrderPromoCode(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	checkout := &fakePromoCheckout{}
+	fe.checkoutSvcConn = contract.Serve(t, func(srv *grpc.Server) {
+		pbv2.RegisterCheckoutServiceServer(srv, checkout)
+	})
+	fe.recommendationSvcConn = fe.checkoutSvcConn
+
+	placeOrder := func(promoCode string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{
+			"email":                        {"someone@example.com"},
+			"street_address":               {"1600 Amphitheatre Parkway"},
+			"zip_code":                     {"94043"},
+			"city":                         {"Mountain View"},
+			"state":                        {"CA"},
+			"country":                      {"United States"},
+			"credit_card_number":           {"4432801561520454"},
+			"credit_card_expiration_month": {"1"},
+			"credit_card_expiration_year":  {strconv.Itoa(time.Now().Year() + 1)},
+			"credit_card_cvv":              {"672"},
+			"promo_code":                   {promoCode},
+		}
+		r := httptest.NewRequest(http.MethodPost, "/cart/checkout", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		fe.placeOrderHandler(w, r.WithContext(ctx))
+		return w
+	}
+
+	if w := placeOrder(" spring "); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Discount (SPRING)") || !strings.Contains(w.Body.String(), "-$2.00") {
+		t.Errorf("order with a valid promo code = %d, want the confirmation with a $2.00 discount: %s", w.Code, w.Body)
+	}
+	if w := placeOrder(""); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "Discount") {
+		t.Errorf("order without a promo code = %d, want the confirmation without a discount", w.Code)
+	}
+	if w, want := placeOrder("winter"), "/cart?promo=invalid&promo_code=WINTER"; w.Code != http.StatusFound || w.Header().Get("Location") != want {
+		t.Errorf("order with an invalid promo code = %d to %q, want a redirect to %q", w.Code, w.Header().Get("Location"), want)
+	}
+	if w, want := placeOrder(strings.Repeat("X", maxPromoCodeLen+1)), "/cart?promo=invalid"; w.Code != http.StatusFound || w.Header().Get("Location") != want {
+		t.Errorf("order with a too long promo code = %d to %q, want a redirect to %q", w.Code, w.Header().Get("Location"), want)
+	}
+	if want := []string{"SPRING", "", "WINTER"}; !slices.Equal(checkout.promoCodes, want) {
+		t.Errorf("checkout was sent promo codes %q, want %q", checkout.promoCodes, want)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/cart?promo=invalid&promo_code=WINTER", nil)
+	ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+	ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+	w := httptest.NewRecorder()
+	if err := fe.insertCart(ctx, "session-1", "OLJCESPC7Z", 1); err != nil {
+		t.Fatal(err)
+	}
+	fe.viewCartHandler(w, r.WithContext(ctx))
+	if page := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(page, "Promo code WINTER cannot be applied to your order.") || !strings.Contains(page, `value="WINTER"`) {
+		t.Errorf("cart after an invalid promo code = %d, want the code and its error inline: %s", w.Code, page)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	limits := ratelimit.New(ratelimit.Config{
+		Browse:         ratelimit.Budget{Rate: 1, Burst: 2},
+		Checkout:       ratelimit.Budget{Rate: 0.25, Burst: 1},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	h := withRateLimit(limits, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	// do sends a request from remoteAddr, with the session cookie and
+	// X-Forwarded-For header if set, and signed in to account if set.
+	do := func(method, path, remoteAddr, session, forwardedFor, account string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = remoteAddr
+		if session != "" {
+			r.AddCookie(&http.Cookie{Name: cookieSessionID, Value: session})
+		}
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		if account != "" {
+			ctx = context.WithValue(ctx, ctxKeyAccount{}, &accounts.Account{ID: account})
+		}
+		w := httptest.NewRecorder()
+		h(w, r.WithContext(ctx))
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do(http.MethodGet, "/", "192.0.2.1:1234", "", "", ""); w.Code != http.StatusOK {
+			t.Fatalf("page view %d = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	w := do(http.MethodGet, "/product/OLJCESPC7Z", "192.0.2.1:1234", "", "", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("page view over the limit = %d with Retry-After %q, want %d with 1", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if w := do(http.MethodPost, "/cart/checkout", "192.0.2.1:1234", "", "", ""); w.Code != http.StatusOK {
+		t.Errorf("checkout after browsing = %d, want its own budget", w.Code)
+	}
+	if w := do(http.MethodPost, "/cart/checkout", "192.0.2.1:1234", "", "", ""); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "4" {
+		t.Errorf("second checkout = %d with Retry-After %q, want %d with 4", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	w = do(http.MethodGet, apiPrefix+"/products", "192.0.2.1:1234", "", "", "")
+	var body struct {
+		Error apiErrorBody `json:"error"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if w.Code != http.StatusTooManyRequests || body.Error.Reason != ratelimit.ReasonRateLimited || !body.Error.Retryable {
+		t.Errorf("API request over the limit = %d %+v, want %d %s", w.Code, body.Error, http.StatusTooManyRequests, ratelimit.ReasonRateLimited)
+	}
+	for i := 0; i < 5; i++ {
+		if w := do(http.MethodGet, "/static/styles/styles.css", "192.0.2.1:1234", "", "", ""); w.Code != http.StatusOK {
+			t.Fatalf("static file = %d, want it not limited", w.Code)
+		}
+	}
+
+	// new session cookies and forwarded addresses from an untrusted
+	// address do not get new budgets
+	if w := do(http.MethodGet, "/", "192.0.2.1:1234", "session-2", "", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("page view with a new session = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := do(http.MethodPost, "/cart/checkout", "192.0.2.1:1234", "session-3", "", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("checkout with a new session = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := do(http.MethodGet, "/", "192.0.2.1:1234", "", "203.0.113.9", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("page view with a forged X-Forwarded-For = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := do(http.MethodGet, "/", "192.0.2.2:1234", "", "", ""); w.Code != http.StatusOK {
+		t.Errorf("request from another address = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// trusted proxies are looked through, from the right, so that what
+	// the client prepends is not used
+	for i, forwardedFor := range []string{"198.51.100.1, 203.0.113.9", "198.51.100.2, 203.0.113.9, 10.0.0.2"} {
+		if w := do(http.MethodGet, "/", "10.0.0.1:1234", "", forwardedFor, ""); w.Code != http.StatusOK {
+			t.Fatalf("proxied page view %d = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	if w := do(http.MethodGet, "/", "10.0.0.1:1234", "", "198.51.100.3, 203.0.113.9", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third proxied request from one address = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := do(http.MethodGet, "/", "10.0.0.1:1234", "", "203.0.113.10", ""); w.Code != http.StatusOK {
+		t.Errorf("proxied request from another address = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// signed-in users have their own budget wherever they come from
+	for i := 0; i < 2; i++ {
+		if w := do(http.MethodGet, "/", "192.0.2.1:1234", "", "", "account-1"); w.Code != http.StatusOK {
+			t.Fatalf("signed-in page view %d = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	if w := do(http.MethodGet, "/", "192.0.2.9:1234", "", "", "account-1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third signed-in request from another address = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestStorefrontNeedsNoAPIKey(t *testing.T) {
+	t.Setenv("API_KEYS_REQUIRED", "1")
+	keys, err := apikey.FromEnv(logging.New("frontend"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Storefront = fromStorefront
+	h := keys.Middleware(scopeCatalogRead)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	token := csrf.NewToken()
+	for _, tt := range []struct {
+		name    string
+		cookies map[string]string
+		header  http.Header
+		want    int
+	}{
+		{"session cookie", map[string]string{cookieSessionID: "made-up"}, nil, http.StatusUnauthorized},
+		{"made-up token", map[string]string{cookieCSRF: "made-up"}, http.Header{csrf.HeaderName: {"made-up"}, "Sec-Fetch-Site": {"same-origin"}}, http.StatusUnauthorized},
+		{"token without its cookie", map[string]string{cookieSessionID: "session-1"}, http.Header{csrf.HeaderName: {token}, "Sec-Fetch-Site": {"same-origin"}}, http.StatusUnauthorized},
+		{"another session's token", map[string]string{cookieCSRF: token}, http.Header{csrf.HeaderName: {csrf.NewToken()}, "Sec-Fetch-Site": {"same-origin"}}, http.StatusUnauthorized},
+		{"cross-site", map[string]string{cookieCSRF: token}, http.Header{csrf.HeaderName: {token}, "Sec-Fetch-Site": {"cross-site"}}, http.StatusUnauthorized},
+		{"storefront page", map[string]string{cookieCSRF: token}, http.Header{csrf.HeaderName: {token}, "Sec-Fetch-Site": {"same-origin"}}, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/suggest?q=sun", nil)
+			for name, value := range tt.cookies {
+				r.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			for k, v := range tt.header {
+				r.Header.Set(k, v[0])
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	fe := newFakeFrontend(t, nil)
+	fe.accounts = accounts.NewMemory()
+	ctx := context.Background()
+	if err := fe.insertCart(ctx, "session-1", "OLJCESPC7Z", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// do serves the request through withCSRF, as every page is, with the
+	// browser's CSRF cookie, and returns the CSRF cookie it was answered with.
+	var cookie string
+	do := func(h http.HandlerFunc, method, path, contentType, body string, wantCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: cookieCSRF, Value: cookie})
+		}
+		ctx := context.WithValue(r.Context(), ctxKeyLog{}, logging.New("frontend"))
+		ctx = context.WithValue(ctx, ctxKeySessionID{}, "session-1")
+		w := httptest.NewRecorder()
+		withCSRF(h)(w, r.WithContext(ctx))
+		if w.Code != wantCode {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, w.Code, wantCode, w.Body)
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == cookieCSRF && c.MaxAge > 0 {
+				cookie = c.Value
+			}
+		}
+		return w
+	}
+	const formType = "application/x-www-form-urlencoded"
+	cartItems := func() int {
+		cart, _ := fe.getCart(ctx, "session-1")
+		return len(cart)
+	}
+
+	// a session posting before it has a token is rejected, and gets one
+	do(fe.emptyCartHandler, http.MethodPost, "/cart/empty", formType, "", http.StatusForbidden)
+	if !csrf.Valid(cookie) {
+		t.Fatalf("CSRF cookie after a rejected request = %q, want a token", cookie)
+	}
+	token := cookie
+	w := do(fe.loginPageHandler, http.MethodGet, "/login", "", "", http.StatusOK)
+	if want := `name="csrf_token" value="` + token + `"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("sign-in page does not embed the session's token in its form")
+	}
+	if cookie != token {
+		t.Errorf("CSRF cookie changed to %q on a page view, want it kept", cookie)
+	}
+
+	for name, body := range map[string]string{
+		"no token":      "",
+		"empty token":   url.Values{csrf.FieldName: {""}}.Encode(),
+		"another token": url.Values{csrf.FieldName: {csrf.NewToken()}}.Encode(),
+	} {
+		do(fe.emptyCartHandler, http.MethodPost, "/cart/empty", formType, body, http.StatusForbidden)
+		if n := cartItems(); n != 1 {
+			t.Fatalf("cart after a forged request with %s = %d items, want it kept", name, n)
+		}
+	}
+	do(fe.emptyCartHandler, http.MethodPost, "/cart/empty", "text/plain", csrf.FieldName+"="+token, http.StatusForbidden)
+	do(fe.emptyCartHandler, http.MethodPost, "/cart/empty", formType, url.Values{csrf.FieldName: {token}}.Encode(), http.StatusFound)
+	if n := cartItems(); n != 0 {
+		t.Errorf("cart after emptying it with the session's token = %d items, want 0", n)
+	}
+
+	// JSON requests cannot be posted by forms, and need no token
+	do(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusCreated) }, http.MethodPost, apiPrefix+"/cart/items", "application/json", "{}", http.StatusCreated)
+
+	// signing in replaces the token
+	form := url.Values{"email": {"someone@example.com"}, "password": {"correct horse"}, csrf.FieldName: {token}}
+	do(fe.signupHandler, http.MethodPost, "/signup", formType, form.Encode(), http.StatusFound)
+	if cookie == token || !csrf.Valid(cookie) {
+		t.Fatalf("CSRF cookie after signing in = %q, want a new token", cookie)
+	}
+	do(fe.emptyCartHandler, http.MethodPost, "/cart/empty", formType, url.Values{csrf.FieldName: {token}}.Encode(), http.StatusForbidden)
+	do(fe.emptyCartHandler, http.MethodPost, "/cart/empty", formType, url.Values{csrf.FieldName: {cookie}}.Encode(), http.StatusFound)
+}
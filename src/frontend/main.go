@@ -18,20 +18,36 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/profiler"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/accounts"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/apikey"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/audit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/grpcconfig"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/lifecycle"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/preferences"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/ratelimit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/warmup"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/wishlist"
 )
 
 const (
@@ -42,6 +58,18 @@ const (
 	cookiePrefix    = "shop_"
 	cookieSessionID = cookiePrefix + "session-id"
 	cookieCurrency  = cookiePrefix + "currency"
+	cookieAccount   = cookiePrefix + "account-session"
+	cookieCSRF      = cookiePrefix + "csrf-token"
+
+	defaultExperimentBuckets = 10
+
+	// API key scopes of the JSON endpoints
+	scopeCatalogRead = "catalog.read"
+	scopeAssistant   = "assistant"
+	scopeCart        = "cart"
+	scopeOrders      = "orders"
+	scopeGraphQL     = "graphql"
+	scopeGRPCWeb     = "grpc-web"
 )
 
 var (
@@ -55,10 +83,22 @@ var (
 	}
 
 	baseUrl         = ""
+
+	// experimentBuckets is the number of cohorts sessions are split into.
+	experimentBuckets = defaultExperimentBuckets
+
+	// peerCreds secure gRPC traffic with the other Go services.
+	peerCreds *mtls.Credentials
+	// grpcSettings tune gRPC traffic to the backends.
+	grpcSettings *grpcconfig.Config
+	// admit sheds low-priority requests when the frontend is overloaded.
+	admit *admission.Controller
 )
 
 type ctxKeySessionID struct{}
 
+type ctxKeyAccount struct{}
+
 type frontendServer struct {
 	productCatalogSvcAddr string
 	productCatalogSvcConn *grpc.ClientConn
@@ -81,25 +121,29 @@ type frontendServer struct {
 	adSvcAddr string
 	adSvcConn *grpc.ClientConn
 
-	collectorAddr string
-	collectorConn *grpc.ClientConn
-
 	shoppingAssistantSvcAddr string
+
+	// optional; back-in-stock notifications are disabled when unset
+	notificationSvcAddr string
+	notificationSvcConn *grpc.ClientConn
+
+	accounts    *accounts.Manager
+	wishlist    *wishlist.List
+	preferences *preferences.Store
+	suggestions *suggestionCache
+	catalog     *catalogCache
 }
 
 func main() {
 	ctx := context.Background()
-	log := logrus.New()
-	log.Level = logrus.DebugLevel
-	log.Formatter = &logrus.JSONFormatter{
-		FieldMap: logrus.FieldMap{
-			logrus.FieldKeyTime:  "timestamp",
-			logrus.FieldKeyLevel: "severity",
-			logrus.FieldKeyMsg:   "message",
-		},
-		TimestampFormat: time.RFC3339Nano,
-	}
-	log.Out = os.Stdout
+	log := logging.New("frontend")
+
+	if err := validateConfig(); err != nil {
+		if configcheck.FailFast() {
+			log.Fatal(err)
+		}
+		log.Warn(err)
+	}
 
 	svc := new(frontendServer)
 
@@ -108,13 +152,25 @@ func main() {
 			propagation.TraceContext{}, propagation.Baggage{}))
 
 	baseUrl = os.Getenv("BASE_URL")
+	if v := os.Getenv("EXPERIMENT_BUCKETS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid EXPERIMENT_BUCKETS %q", v)
+		}
+		experimentBuckets = n
+	}
 
 	if os.Getenv("ENABLE_TRACING") == "1" {
 		log.Info("Tracing enabled.")
-		initTracing(log, ctx, svc)
 	} else {
 		log.Info("Tracing disabled.")
 	}
+	tel, err := instrumentation.Init(ctx, "frontend", os.Getenv("ENABLE_TRACING") == "1")
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	life := lifecycle.New(log)
+	life.OnClose("telemetry", tel.Shutdown)
 
 	if os.Getenv("ENABLE_PROFILER") == "1" {
 		log.Info("Profiling enabled.")
@@ -123,6 +179,87 @@ func main() {
 		log.Info("Profiling disabled.")
 	}
 
+	auditLog, err := audit.FromEnv("frontend", log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	life.OnClose("audit log", auditLog.Close)
+
+	apiKeys, err := apikey.FromEnv(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiKeys.Storefront = fromStorefront
+
+	cors, err := parseCORSOrigins(os.Getenv("API_CORS_ORIGINS"))
+	if err != nil {
+		log.Fatalf("invalid API_CORS_ORIGINS: %v", err)
+	}
+	log.Infof("API CORS: %s", cors)
+
+	svc.accounts = accounts.FromEnv()
+	svc.accounts.SessionTTL = cookieMaxAge * time.Second
+	log.Infof("Accounts: %s", svc.accounts)
+
+	svc.wishlist = wishlist.FromEnv()
+	log.Infof("Wishlist: %s", svc.wishlist)
+
+	svc.preferences = preferences.FromEnv()
+	log.Infof("Preferences: %s", svc.preferences)
+
+	svc.suggestions, err = newSuggestionCacheFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Suggestion cache: %s", svc.suggestions)
+
+	svc.catalog, err = newCatalogCacheFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Catalog cache: %s", svc.catalog)
+
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		log.Info("Debug endpoints enabled.")
+		levelHandler := auditLog.HTTPHandler("logging.SetLevel", logging.LevelHandler())
+		debugMux := http.NewServeMux()
+		debugMux.Handle("/", instrumentation.DebugHandler(levelHandler, auditLog.Handler()))
+		debugMux.Handle("/debug/apikeys", auditLog.HTTPHandler("apikey.Manage", apiKeys.Handler()))
+		debugMux.Handle("/debug/catalog/invalidate", auditLog.HTTPHandler("catalog.Invalidate", svc.catalog.Handler(log)))
+		if err := instrumentation.ServeDebug(instrumentation.DebugAddr(), debugMux); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Info("Debug endpoints disabled.")
+	}
+
+	creds, err := mtls.FromEnv(ctx, log)
+	if err != nil {
+		log.Fatalf("failed to set up mTLS: %v", err)
+	}
+	peerCreds = creds
+	log.Infof("mTLS mode: %s", peerCreds.Mode())
+
+	settings, err := grpcconfig.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcSettings = settings
+	log.Infof("gRPC settings: %s", grpcSettings)
+
+	admit, err = admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Load shedding: %s", admit)
+	go admit.Run(life.Context())
+
+	limits, err := ratelimit.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Rate limits: %s", limits)
+
 	srvPort := port
 	if os.Getenv("PORT") != "" {
 		srvPort = os.Getenv("PORT")
@@ -144,53 +281,113 @@ func main() {
 	mustConnGRPC(ctx, &svc.shippingSvcConn, svc.shippingSvcAddr)
 	mustConnGRPC(ctx, &svc.checkoutSvcConn, svc.checkoutSvcAddr)
 	mustConnGRPC(ctx, &svc.adSvcConn, svc.adSvcAddr)
+	if svc.notificationSvcAddr = os.Getenv("NOTIFICATION_SERVICE_ADDR"); svc.notificationSvcAddr != "" {
+		mustConnGRPC(ctx, &svc.notificationSvcConn, svc.notificationSvcAddr)
+	}
+
+	warm, err := warmup.New(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	warm.Add("currency", warmup.Conn(svc.currencySvcConn))
+	warm.Add("productcatalog", warmup.Conn(svc.productCatalogSvcConn))
+	warm.Add("cart", warmup.Conn(svc.cartSvcConn))
+	warm.Add("recommendation", warmup.Conn(svc.recommendationSvcConn))
+	warm.Add("shipping", warmup.Conn(svc.shippingSvcConn))
+	warm.Add("checkout", warmup.Conn(svc.checkoutSvcConn))
+	warm.Add("ad", warmup.Conn(svc.adSvcConn))
+	if svc.notificationSvcConn != nil {
+		warm.Add("notification", warmup.Conn(svc.notificationSvcConn))
+	}
+
+	// Fail readiness checks once shutdown starts, so that the load balancer
+	// stops sending requests before the server stops.
+	var shuttingDown atomic.Bool
+	life.OnUnready(func() { shuttingDown.Store(true) })
 
 	r := mux.NewRouter()
 	r.HandleFunc(baseUrl + "/", svc.homeHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl + "/product/{id}", svc.productHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl + "/product/{id}/notify", svc.backInStockHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/product/{id}/review", svc.submitReviewHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl + "/cart", svc.viewCartHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl + "/cart", svc.addToCartHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl + "/cart/empty", svc.emptyCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/wishlist", svc.wishlistHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl + "/wishlist", svc.addToWishlistHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/wishlist/remove", svc.removeFromWishlistHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/wishlist/move", svc.moveToCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/search", svc.searchHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl + "/setCurrency", svc.setCurrencyHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/setLocale", svc.setLocaleHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl + "/logout", svc.logoutHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl + "/login", svc.loginPageHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl + "/login", svc.loginHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/signup", svc.loginPageHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl + "/signup", svc.signupHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl + "/cart/checkout", svc.placeOrderHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/orders", svc.ordersHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl + "/order/{id}", svc.orderHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl + "/assistant", svc.assistantHandler).Methods(http.MethodGet)
 	r.PathPrefix(baseUrl + "/static/").Handler(http.StripPrefix(baseUrl + "/static/", http.FileServer(http.Dir("./static/"))))
 	r.HandleFunc(baseUrl + "/robots.txt", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "User-agent: *\nDisallow: /") })
-	r.HandleFunc(baseUrl + "/_healthz", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "ok") })
-	r.HandleFunc(baseUrl + "/product-meta/{ids}", svc.getProductByID).Methods(http.MethodGet)
-	r.HandleFunc(baseUrl + "/bot", svc.chatBotHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl + "/_healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if shuttingDown.Load() {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		if !warm.Done() {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+	r.Handle(baseUrl + "/metrics", tel.MetricsHandler())
+	r.Handle(baseUrl + "/product-meta/{ids}", apiKeys.Middleware(scopeCatalogRead)(http.HandlerFunc(svc.getProductByID))).Methods(http.MethodGet)
+	r.Handle(baseUrl + "/api/suggest", apiKeys.Middleware(scopeCatalogRead)(http.HandlerFunc(svc.suggestHandler))).Methods(http.MethodGet)
+	r.Handle(baseUrl + "/bot", apiKeys.Middleware(scopeAssistant)(http.HandlerFunc(svc.chatBotHandler))).Methods(http.MethodPost)
+	r.PathPrefix(baseUrl + apiPrefix + "/").Handler(svc.apiHandler(apiKeys, cors))
+	grpcWeb, err := svc.grpcWebHandler(apiKeys, cors)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.PathPrefix(baseUrl + grpcWebPrefix + "/").Handler(grpcWeb)
+	r.Handle(baseUrl + "/graphql", withCORS(cors, apiKeys.MiddlewareFunc(scopeGraphQL, rejectGraphQLRequest)(svc.graphqlHandler()))).Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
 
 	var handler http.Handler = r
-	handler = &logHandler{log: log, next: handler}     // add logging
-	handler = ensureSessionID(handler)                 // add session ID
-	handler = otelhttp.NewHandler(handler, "frontend") // add OTel tracing
-
-	log.Infof("starting server on " + addr + ":" + srvPort)
-	log.Fatal(http.ListenAndServe(addr+":"+srvPort, handler))
+	handler = withAdmission(handler)                         // add load shedding
+	handler = withRateLimit(limits, handler)                 // add rate limits
+	handler = withCSRF(handler)                              // add CSRF protection
+	handler = &logHandler{log: log, next: handler}           // add logging
+	handler = withCohortBaggage(handler)                     // add cohort baggage
+	handler = withPreferences(svc.preferences, log, handler) // add currency and locale
+	handler = withAccount(svc.accounts, log, handler)        // add signed-in account
+	handler = ensureSessionID(handler)                       // add session ID
+	handler = otelhttp.NewHandler(handler, "frontend")       // add OTel tracing
+
+	// Rendering the pages primes the catalog and currency conversions
+	// downstream and executes the templates once.
+	warm.Add("home page", warmPage(handler, baseUrl+"/"))
+	warm.Add("cart page", warmPage(handler, baseUrl+"/cart"))
+	log.Infof("Warm-up: %s", warm)
+	go warm.Run(life.Context())
+
+	srv := &http.Server{Addr: addr + ":" + srvPort, Handler: handler}
+	life.OnDrain("http server", lifecycle.HTTPServer(srv))
+
+	log.Info("starting server on " + srv.Addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	life.Wait()
 }
-func initStats(log logrus.FieldLogger) {
+func initStats(log *logging.Logger) {
 	// TODO(arbrown) Implement OpenTelemtry stats
 }
 
-func initTracing(log logrus.FieldLogger, ctx context.Context, svc *frontendServer) (*sdktrace.TracerProvider, error) {
-	mustMapEnv(&svc.collectorAddr, "COLLECTOR_SERVICE_ADDR")
-	mustConnGRPC(ctx, &svc.collectorConn, svc.collectorAddr)
-	exporter, err := otlptracegrpc.New(
-		ctx,
-		otlptracegrpc.WithGRPCConn(svc.collectorConn))
-	if err != nil {
-		log.Warnf("warn: Failed to create trace exporter: %v", err)
-	}
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()))
-	otel.SetTracerProvider(tp)
-
-	return tp, err
-}
-
-func initProfiling(log logrus.FieldLogger, service, version string) {
+func initProfiling(log *logging.Logger, service, version string) {
 	// TODO(ahmetb) this method is duplicated in other microservices using Go
 	// since they are not sharing packages.
 	for i := 1; i <= 3; i++ {
@@ -213,6 +410,18 @@ func initProfiling(log logrus.FieldLogger, service, version string) {
 	log.Warn("warning: could not initialize Stackdriver profiler after retrying, giving up")
 }
 
+// warmPage returns a warm-up task that renders the page at path through h.
+func warmPage(h http.Handler, path string) warmup.Task {
+	return func(ctx context.Context) error {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		if rec.Code != http.StatusOK {
+			return fmt.Errorf("GET %s returned %d", path, rec.Code)
+		}
+		return nil
+	}
+}
+
 func mustMapEnv(target *string, envKey string) {
 	v := os.Getenv(envKey)
 	if v == "" {
@@ -225,10 +434,12 @@ func mustConnGRPC(ctx context.Context, conn **grpc.ClientConn, addr string) {
 	var err error
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
-	*conn, err = grpc.DialContext(ctx, addr,
-		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()))
+	*conn, err = grpc.DialContext(ctx, addr, append([]grpc.DialOption{
+		peerCreds.DialOption(addr),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(requestid.UnaryClientInterceptor(), admission.UnaryClientInterceptor(), preferences.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(requestid.StreamClientInterceptor(), admission.StreamClientInterceptor(), preferences.StreamClientInterceptor()),
+	}, grpcSettings.DialOptions()...)...)
 	if err != nil {
 		panic(errors.Wrapf(err, "grpc: failed to connect %s", addr))
 	}
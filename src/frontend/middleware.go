@@ -16,19 +16,35 @@ package main
 
 import (
 	"context"
+	"hash/fnv"
+	"math"
+	"net"
 	"net/http"
+	"net/netip"
 	"time"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/accounts"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/csrf"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/preferences"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/ratelimit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/rpcerrors"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
 )
 
 type ctxKeyLog struct{}
-type ctxKeyRequestID struct{}
 
 type logHandler struct {
-	log  *logrus.Logger
+	log  *logging.Logger
 	next http.Handler
 }
 
@@ -56,22 +72,25 @@ func (r *responseRecorder) WriteHeader(statusCode int) {
 
 func (lh *logHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	requestID, _ := uuid.NewRandom()
-	ctx = context.WithValue(ctx, ctxKeyRequestID{}, requestID.String())
+	requestID := r.Header.Get(requestid.Header)
+	if !requestid.Valid(requestID) {
+		requestID = requestid.New()
+	}
+	ctx = requestid.NewContext(ctx, requestID)
+	w.Header().Set(requestid.Header, requestID)
 
 	start := time.Now()
 	rr := &responseRecorder{w: w}
-	log := lh.log.WithFields(logrus.Fields{
+	log := lh.log.WithContext(ctx).WithFields(logging.Fields{
 		"http.req.path":   r.URL.Path,
 		"http.req.method": r.Method,
-		"http.req.id":     requestID.String(),
 	})
 	if v, ok := r.Context().Value(ctxKeySessionID{}).(string); ok {
 		log = log.WithField("session", v)
 	}
 	log.Debug("request started")
 	defer func() {
-		log.WithFields(logrus.Fields{
+		log.WithFields(logging.Fields{
 			"http.resp.took_ms": int64(time.Since(start) / time.Millisecond),
 			"http.resp.status":  rr.status,
 			"http.resp.bytes":   rr.b}).Debugf("request complete")
@@ -109,3 +128,235 @@ func ensureSessionID(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+// requestPriority classifies a request for load shedding: placing an order
+// comes first, then the cart, then browsing, through the pages or the API.
+// Health checks and metrics are never shed.
+func requestPriority(r *http.Request) (admission.Priority, bool) {
+	path := strings.TrimPrefix(r.URL.Path, baseUrl)
+	path = strings.TrimPrefix(path, apiPrefix)
+	switch {
+	case path == "/_healthz" || path == "/metrics":
+		return 0, false
+	case path == "/cart/checkout" || path == "/checkout" || path == grpcWebPrefix+"/hipstershop.v2.CheckoutService/PlaceOrder":
+		return admission.Checkout, true
+	case path == "/cart" || strings.HasPrefix(path, "/cart/"):
+		return admission.Cart, true
+	default:
+		return admission.Browse, true
+	}
+}
+
+// withAdmission sheds low-priority requests when the frontend is overloaded,
+// and passes the priority of admitted ones on to the backends.
+func withAdmission(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := requestPriority(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		done, ok := admit.Admit(r.Context(), p)
+		if !ok {
+			log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+			if strings.HasPrefix(r.URL.Path, baseUrl+apiPrefix+"/") {
+				writeAPIError(log, w, r, admission.Error(p), http.StatusServiceUnavailable)
+				return
+			}
+			renderHTTPError(log, r, w, admission.Error(p), http.StatusServiceUnavailable)
+			return
+		}
+		defer done()
+		next.ServeHTTP(w, r.WithContext(admission.NewContext(r.Context(), p)))
+	}
+}
+
+// rateLimitClass tells which budget r counts against: placing an order has
+// its own, so that browsing cannot use it up. Static files, health checks and
+// metrics are not limited.
+func rateLimitClass(r *http.Request) (ratelimit.Class, bool) {
+	if strings.HasPrefix(r.URL.Path, baseUrl+"/static/") {
+		return 0, false
+	}
+	p, ok := requestPriority(r)
+	switch {
+	case !ok:
+		return 0, false
+	case p == admission.Checkout:
+		return ratelimit.Checkout, true
+	default:
+		return ratelimit.Browse, true
+	}
+}
+
+// rateLimitKey identifies the client of r: its signed-in account, or else its
+// address. Session cookies are made up by the client, so they are not used.
+// The address is the one r comes from, unless that is a trusted proxy, in
+// which case it is the right-most address of X-Forwarded-For that is not one.
+func rateLimitKey(limits *ratelimit.Limiter, r *http.Request) string {
+	if a := currentAccount(r); a != nil {
+		return "account:" + a.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return "ip:" + host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0 && limits.TrustsProxy(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+	}
+	return "ip:" + addr.Unmap().String()
+}
+
+// withRateLimit answers requests over their client's rate limit with a 429
+// whose Retry-After header says when the next one will be let through.
+func withRateLimit(limits *ratelimit.Limiter, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := rateLimitClass(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, wait := limits.Allow(r.Context(), c, rateLimitKey(limits, r)); !ok {
+			log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+			err := rpcerrors.Errorf(codes.ResourceExhausted, ratelimit.ReasonRateLimited, "too many %s requests, retry in %v", c, wait.Round(time.Millisecond))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			if strings.HasPrefix(r.URL.Path, baseUrl+apiPrefix+"/") {
+				writeAPIError(log, w, r, err, http.StatusTooManyRequests)
+				return
+			}
+			renderHTTPError(log, r, w, err, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// withCSRF gives each session a CSRF token, which the pages embed in their
+// forms, and rejects the requests changing state that do not post it with a
+// 403. Sessions without a valid token get a new one, so that the page
+// answering a rejected request can be posted from.
+func withCSRF(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if c, err := r.Cookie(cookieCSRF); err == nil && csrf.Valid(c.Value) {
+			token = c.Value
+		}
+		var err error
+		if csrf.Protected(r) {
+			err = csrf.Check(r, token)
+		}
+		if token == "" {
+			token = rotateCSRFToken(w)
+		}
+		r = r.WithContext(csrf.NewContext(r.Context(), token))
+		if err != nil {
+			log := r.Context().Value(ctxKeyLog{}).(*logging.Logger)
+			if strings.HasPrefix(r.URL.Path, baseUrl+apiPrefix+"/") {
+				writeAPIError(log, w, r, err, http.StatusForbidden)
+				return
+			}
+			renderHTTPError(log, r, w, err, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// rotateCSRFToken gives the session a new CSRF token, and returns it.
+func rotateCSRFToken(w http.ResponseWriter) string {
+	token := csrf.NewToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieCSRF,
+		Value:    token,
+		Path:     baseUrl + "/",
+		MaxAge:   cookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// fromStorefront reports whether r comes from the storefront's own pages,
+// and so needs no API key: their scripts send the session's CSRF token,
+// which other sites cannot read, and browsers tell their requests apart from
+// other sites'. Session cookies are made up by the client, so they are not
+// enough.
+func fromStorefront(r *http.Request) bool {
+	c, err := r.Cookie(cookieCSRF)
+	return err == nil && csrf.Valid(c.Value) && csrf.SameOrigin(r) && csrf.CheckHeader(r, c.Value) == nil
+}
+
+// withAccount adds the account signed in with the request's account cookie,
+// if any, to the request context. Expired sessions lose their cookie, and
+// requests whose session cannot be looked up are served as anonymous ones.
+func withAccount(accts *accounts.Manager, log *logging.Logger, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(cookieAccount)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a, err := accts.Session(r.Context(), c.Value)
+		switch {
+		case errors.Is(err, accounts.ErrNoSession):
+			http.SetCookie(w, &http.Cookie{Name: cookieAccount, Path: baseUrl + "/", MaxAge: -1})
+		case err != nil:
+			log.WithContext(r.Context()).WithField("error", err).Warn("could not look up account session")
+		default:
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyAccount{}, &a))
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// withPreferences adds the currency and locale the user chose to the request
+// context, which sends them to the backends with every call. A user who has
+// not chosen keeps the currency cookie or the default currency, and the
+// language their browser asks for.
+func withPreferences(prefs *preferences.Store, log *logging.Logger, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := prefs.Get(r.Context(), userID(r))
+		if err != nil {
+			log.WithContext(r.Context()).WithField("error", err).Warn("could not look up preferences")
+		}
+		if p.Currency == "" {
+			p.Currency = cookieCurrencyOrDefault(r)
+		}
+		if p.Locale == "" {
+			p.Locale = acceptedLocale(r)
+		}
+		next.ServeHTTP(w, r.WithContext(preferences.NewContext(r.Context(), p)))
+	}
+}
+
+// withCohortBaggage adds the session's cohort to the request baggage, which
+// is propagated to the backends, and to the frontend's request span. Users
+// who are not signed in are known by their session ID.
+func withCohortBaggage(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := sessionID(r)
+		ctx := instrumentation.WithBaggage(r.Context(), map[string]string{
+			instrumentation.BaggageUserHash:         instrumentation.HashID(userID(r)),
+			instrumentation.BaggageSessionHash:      instrumentation.HashID(id),
+			instrumentation.BaggageExperimentBucket: experimentBucket(id),
+		})
+		trace.SpanFromContext(ctx).SetAttributes(instrumentation.BaggageAttributes(ctx)...)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// experimentBucket assigns a session to one of experimentBuckets buckets.
+func experimentBucket(sessionID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return strconv.Itoa(int(h.Sum32() % uint32(experimentBuckets)))
+}
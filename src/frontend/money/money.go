@@ -16,6 +16,8 @@ package money
 
 import (
 	"errors"
+	"math"
+	"math/big"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
 )
@@ -29,6 +31,7 @@ const (
 var (
 	ErrInvalidValue        = errors.New("one of the specified money values is invalid")
 	ErrMismatchingCurrency = errors.New("mismatching currency codes")
+	ErrInvalidRate         = errors.New("exchange rates must be positive and finite")
 )
 
 // IsValid checks if specified value has a valid units/nanos signs and ranges.
@@ -99,7 +102,7 @@ func Sum(l, r pb.Money) (pb.Money, error) {
 	units := l.GetUnits() + r.GetUnits()
 	nanos := l.GetNanos() + r.GetNanos()
 
-	if (units == 0 && nanos == 0) || (units > 0 && nanos >= 0) || (units < 0 && nanos <= 0) {
+	if (units >= 0 && nanos >= 0) || (units <= 0 && nanos <= 0) {
 		// same sign <units, nanos>
 		units += int64(nanos / nanosMod)
 		nanos = nanos % nanosMod
@@ -130,3 +133,36 @@ func MultiplySlow(m pb.Money, n uint32) pb.Money {
 	}
 	return out
 }
+
+// Convert converts m into the currency toCode. fromRate and toRate are the
+// exchange rates of m's currency and of toCode against the same base
+// currency, such as how many units of each one euro buys. The result is
+// rounded to the nearest nano, half away from zero.
+func Convert(m pb.Money, fromRate, toRate float64, toCode string) (pb.Money, error) {
+	if !IsValid(m) {
+		return pb.Money{}, ErrInvalidValue
+	}
+	if !validRate(fromRate) || !validRate(toRate) {
+		return pb.Money{}, ErrInvalidRate
+	}
+	nanos := new(big.Int).Mul(big.NewInt(m.GetUnits()), big.NewInt(nanosMod))
+	nanos.Add(nanos, big.NewInt(int64(m.GetNanos())))
+	r := new(big.Rat).SetInt(nanos)
+	r.Mul(r, new(big.Rat).SetFloat64(toRate))
+	r.Quo(r, new(big.Rat).SetFloat64(fromRate))
+
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	units, n := q.QuoRem(q, big.NewInt(nanosMod), new(big.Int))
+	if !units.IsInt64() {
+		return pb.Money{}, ErrInvalidValue
+	}
+	return pb.Money{
+		Units:        units.Int64(),
+		Nanos:        int32(n.Int64()),
+		CurrencyCode: toCode}, nil
+}
+
+func validRate(r float64) bool { return r > 0 && !math.IsInf(r, 1) }
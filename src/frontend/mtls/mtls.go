@@ -0,0 +1,373 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mtls secures the gRPC traffic between the Go services with mutual
+// TLS, using certificates read from files (e.g. a cert-manager Secret) or
+// issued by the SPIFFE Workload API.
+//
+// MTLS_MODE sets the server policy:
+//
+//   - disabled (default): plaintext only.
+//   - permissive: mTLS and plaintext connections are both accepted on the
+//     same port, and the identity of each peer is logged. Use it while
+//     rolling mTLS out.
+//   - strict: calls over plaintext are rejected with Unauthenticated, except
+//     for the health service so that kubelet gRPC probes keep working.
+//
+// Outgoing calls use mTLS only to the hosts listed in MTLS_PEERS, since most
+// services in the application are not written in Go and do not speak it.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package mtls
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/logging"
+)
+
+// Mode is the policy servers apply to incoming connections.
+type Mode string
+
+const (
+	Disabled   Mode = "disabled"
+	Permissive Mode = "permissive"
+	Strict     Mode = "strict"
+)
+
+// Default locations of the certificate files, matching the layout of a
+// kubernetes.io/tls Secret issued by cert-manager.
+const (
+	DefaultCertFile = "/var/run/tls/tls.crt"
+	DefaultKeyFile  = "/var/run/tls/tls.key"
+	DefaultCAFile   = "/var/run/tls/ca.crt"
+)
+
+// healthService is exempt from strict mode.
+const healthService = "/grpc.health.v1.Health/"
+
+// Credentials hold the transport security of a service. A nil *Credentials
+// is valid and means mTLS is disabled.
+type Credentials struct {
+	mode   Mode
+	peers  map[string]bool
+	server credentials.TransportCredentials
+	client credentials.TransportCredentials
+	close  func() error
+}
+
+// FromEnv configures mTLS from the environment: MTLS_MODE, MTLS_PEERS and
+// MTLS_SOURCE, which is either "file" (the default, reading MTLS_CERT_FILE,
+// MTLS_KEY_FILE and MTLS_CA_FILE) or "spiffe" (using the Workload API at
+// SPIFFE_ENDPOINT_SOCKET and accepting peers of the same trust domain).
+func FromEnv(ctx context.Context, log *logging.Logger) (*Credentials, error) {
+	mode := Mode(strings.ToLower(os.Getenv("MTLS_MODE")))
+	switch mode {
+	case "", Disabled:
+		return &Credentials{mode: Disabled}, nil
+	case Permissive, Strict:
+	default:
+		return nil, fmt.Errorf("unknown MTLS_MODE %q", mode)
+	}
+
+	var (
+		serverCfg, clientCfg *tls.Config
+		closer               func() error
+	)
+	switch source := os.Getenv("MTLS_SOURCE"); source {
+	case "", "file":
+		files := &certFiles{
+			cert: envOr("MTLS_CERT_FILE", DefaultCertFile),
+			key:  envOr("MTLS_KEY_FILE", DefaultKeyFile),
+		}
+		if err := files.load(); err != nil {
+			return nil, err
+		}
+		pool, err := loadCA(envOr("MTLS_CA_FILE", DefaultCAFile))
+		if err != nil {
+			return nil, err
+		}
+		serverCfg = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: files.serverCertificate,
+			ClientCAs:      pool,
+			ClientAuth:     tls.VerifyClientCertIfGiven,
+		}
+		clientCfg = &tls.Config{
+			MinVersion:           tls.VersionTLS12,
+			GetClientCertificate: files.clientCertificate,
+			RootCAs:              pool,
+		}
+	case "spiffe":
+		src, err := workloadapi.NewX509Source(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the SPIFFE Workload API: %w", err)
+		}
+		svid, err := src.GetX509SVID()
+		if err != nil {
+			src.Close()
+			return nil, fmt.Errorf("failed to get an X.509 SVID: %w", err)
+		}
+		log.Infof("using SPIFFE identity %s", svid.ID)
+		authorizer := tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain())
+		serverCfg = tlsconfig.MTLSServerConfig(src, src, authorizer)
+		clientCfg = tlsconfig.MTLSClientConfig(src, src, authorizer)
+		closer = src.Close
+	default:
+		return nil, fmt.Errorf("unknown MTLS_SOURCE %q", source)
+	}
+
+	peers := make(map[string]bool)
+	for _, p := range strings.Split(os.Getenv("MTLS_PEERS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			peers[p] = true
+		}
+	}
+	return &Credentials{
+		mode:   mode,
+		peers:  peers,
+		server: &sniffingCreds{TransportCredentials: credentials.NewTLS(serverCfg), log: log},
+		client: credentials.NewTLS(clientCfg),
+		close:  closer,
+	}, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Mode returns the server policy.
+func (c *Credentials) Mode() Mode {
+	if c == nil {
+		return Disabled
+	}
+	return c.mode
+}
+
+// ServerOption returns the transport credentials for a gRPC server.
+func (c *Credentials) ServerOption() grpc.ServerOption {
+	if c.Mode() == Disabled {
+		return grpc.Creds(insecure.NewCredentials())
+	}
+	return grpc.Creds(c.server)
+}
+
+// DialOption returns the transport credentials for calls to addr: mTLS if
+// its host is one of MTLS_PEERS, plaintext otherwise.
+func (c *Credentials) DialOption(addr string) grpc.DialOption {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if c.Mode() == Disabled || !c.peers[host] {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	return grpc.WithTransportCredentials(c.client)
+}
+
+// UnaryServerInterceptor rejects calls made over plaintext in strict mode.
+func (c *Credentials) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := c.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func (c *Credentials) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := c.authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (c *Credentials) authorize(ctx context.Context, method string) error {
+	if c.Mode() != Strict || strings.HasPrefix(method, healthService) {
+		return nil
+	}
+	if p, ok := peer.FromContext(ctx); ok && Identity(p.AuthInfo) != "" {
+		return nil
+	}
+	return status.Errorf(codes.Unauthenticated, "%s requires mutual TLS", method)
+}
+
+// Close releases the certificate source.
+func (c *Credentials) Close() error {
+	if c == nil || c.close == nil {
+		return nil
+	}
+	return c.close()
+}
+
+// Identity returns the verified identity of a peer: the SPIFFE ID or, failing
+// that, the subject common name of its certificate. It is empty for peers
+// that did not present a certificate.
+func Identity(info credentials.AuthInfo) string {
+	tlsInfo, ok := info.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return ""
+	}
+	if tlsInfo.SPIFFEID != nil {
+		return tlsInfo.SPIFFEID.String()
+	}
+	cert := tlsInfo.State.PeerCertificates[0]
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	return cert.Subject.CommonName
+}
+
+// sniffingCreds accepts both TLS and plaintext connections, telling them
+// apart by the first byte a client sends, and logs who connected.
+type sniffingCreds struct {
+	credentials.TransportCredentials
+	log *logging.Logger
+}
+
+// recordTypeHandshake starts every TLS ClientHello.
+const recordTypeHandshake = 0x16
+
+func (c *sniffingCreds) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	r := bufio.NewReader(rawConn)
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := &peekedConn{Conn: rawConn, r: r}
+	if b[0] != recordTypeHandshake {
+		c.log.Infof("accepted plaintext connection from %s", rawConn.RemoteAddr())
+		return conn, plaintextInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+	}
+	tlsConn, info, err := c.TransportCredentials.ServerHandshake(conn)
+	if err != nil {
+		c.log.Warnf("TLS handshake with %s failed: %v", rawConn.RemoteAddr(), err)
+		return nil, nil, err
+	}
+	if id := Identity(info); id != "" {
+		c.log.Infof("accepted mTLS connection from %s (%s)", id, rawConn.RemoteAddr())
+	} else {
+		c.log.Infof("accepted TLS connection without a client certificate from %s", rawConn.RemoteAddr())
+	}
+	return tlsConn, info, nil
+}
+
+func (c *sniffingCreds) Clone() credentials.TransportCredentials {
+	return &sniffingCreds{TransportCredentials: c.TransportCredentials.Clone(), log: c.log}
+}
+
+type plaintextInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (plaintextInfo) AuthType() string { return "insecure" }
+
+// peekedConn reads through the buffer that holds the sniffed byte.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// certFiles serves a key pair from files, reloading it when the certificate
+// file changes so that rotated certificates are picked up without a restart.
+type certFiles struct {
+	cert, key string
+
+	mu      sync.Mutex
+	pair    *tls.Certificate
+	modTime time.Time
+}
+
+func (f *certFiles) load() error {
+	fi, err := os.Stat(f.cert)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pair != nil && fi.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	pair, err := tls.LoadX509KeyPair(f.cert, f.key)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair %s: %w", f.cert, err)
+	}
+	f.pair, f.modTime = &pair, fi.ModTime()
+	return nil
+}
+
+func (f *certFiles) current() (*tls.Certificate, error) {
+	if err := f.load(); err != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		// Keep serving the last good pair while a rotation is half written.
+		if f.pair != nil {
+			return f.pair, nil
+		}
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pair, nil
+}
+
+func (f *certFiles) serverCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return f.current()
+}
+
+func (f *certFiles) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return f.current()
+}
+
+func loadCA(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no CA certificates found in %s", file)
+	}
+	return pool, nil
+}
@@ -15,11 +15,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 /*
@@ -47,11 +50,11 @@ func isPackagingServiceConfigured() bool {
 	return packagingServiceUrl != ""
 }
 
-func httpGetPackagingInfo(productId string) (*PackagingInfo, error) {
+func httpGetPackagingInfo(ctx context.Context, productId string) (*PackagingInfo, error) {
 	// Make the GET request
 	url := packagingServiceUrl + "/" + productId
 	fmt.Println("Requesting packaging info from URL: ", url)
-	resp, err := http.Get(url)
+	resp, err := otelhttp.Get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
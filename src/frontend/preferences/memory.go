@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preferences
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps preferences in a map by user ID.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	prefs Preferences
+	// expires is when the entry expires, or zero if it never does.
+	expires time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// live returns userID's entry unless it has expired, dropping it if it has.
+// s.mu must be held.
+func (s *memoryStore) live(userID string) memoryEntry {
+	e := s.entries[userID]
+	if !e.expires.IsZero() && !s.now().Before(e.expires) {
+		delete(s.entries, userID)
+		return memoryEntry{}
+	}
+	return e
+}
+
+func (s *memoryStore) get(_ context.Context, userID string) (Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live(userID).prefs, nil
+}
+
+func (s *memoryStore) save(_ context.Context, userID string, p Preferences, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.live(userID)
+	e.prefs = e.prefs.merge(p)
+	e.expires = time.Time{}
+	if ttl > 0 {
+		e.expires = s.now().Add(ttl)
+	}
+	s.entries[userID] = e
+	return nil
+}
+
+func (s *memoryStore) remove(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, userID)
+	return nil
+}
+
+func (s *memoryStore) String() string {
+	return "in memory"
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preferences
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// MetadataCurrency is the gRPC metadata key the user's currency travels
+	// in.
+	MetadataCurrency = "x-user-currency"
+	// MetadataLocale is the gRPC metadata key the user's locale travels in.
+	MetadataLocale = "x-user-locale"
+)
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying p, which the client
+// interceptors send along with every call made with it.
+func NewContext(ctx context.Context, p Preferences) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the preferences in ctx, which are empty if there are
+// none.
+func FromContext(ctx context.Context) Preferences {
+	p, _ := ctx.Value(ctxKey{}).(Preferences)
+	return p
+}
+
+// outgoing returns ctx with the preferences it carries added to its outgoing
+// metadata.
+func outgoing(ctx context.Context) context.Context {
+	p := FromContext(ctx)
+	var kv []string
+	if p.Currency != "" {
+		kv = append(kv, MetadataCurrency, p.Currency)
+	}
+	if p.Locale != "" {
+		kv = append(kv, MetadataLocale, p.Locale)
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// UnaryClientInterceptor sends the preferences in the context of each call
+// to the server.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of
+// UnaryClientInterceptor.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoing(ctx), desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preferences keeps the currency and locale each user chose, and
+// carries them to the backends in the gRPC metadata of every call made on the
+// user's behalf.
+//
+// Preferences are kept by user ID, which is the account ID of signed-in users
+// and the session ID of anonymous ones, so that Merge can hand an anonymous
+// session's choices to the account it signs in to. They are kept in Redis at
+// PREFERENCES_REDIS_ADDR when it is set, so that they survive restarts and are
+// shared by every frontend replica, and in memory otherwise.
+package preferences
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Preferences are a user's choices. An empty field means the user has not
+// chosen.
+type Preferences struct {
+	// Currency is an ISO 4217 currency code.
+	Currency string
+	// Locale is a BCP 47 language tag.
+	Locale string
+}
+
+// merge returns p with the fields set in q replaced.
+func (p Preferences) merge(q Preferences) Preferences {
+	if q.Currency != "" {
+		p.Currency = q.Currency
+	}
+	if q.Locale != "" {
+		p.Locale = q.Locale
+	}
+	return p
+}
+
+// store keeps preferences by user ID.
+type store interface {
+	// get returns userID's preferences, which are empty if there are none.
+	get(ctx context.Context, userID string) (Preferences, error)
+	// save sets the fields of p that are set for userID, keeping the
+	// others, and has userID's preferences expire after ttl, or never when
+	// ttl is 0.
+	save(ctx context.Context, userID string, p Preferences, ttl time.Duration) error
+	remove(ctx context.Context, userID string) error
+	String() string
+}
+
+// Store keeps the users' preferences. Its methods are safe for concurrent
+// use.
+type Store struct {
+	store store
+}
+
+// FromEnv returns a Store keeping preferences in Redis at
+// PREFERENCES_REDIS_ADDR, a host:port, or in memory when it is unset.
+func FromEnv() *Store {
+	if addr := os.Getenv("PREFERENCES_REDIS_ADDR"); addr != "" {
+		return &Store{store: newRedisStore(addr)}
+	}
+	return NewMemory()
+}
+
+// NewMemory returns a Store keeping preferences in memory, which are lost
+// when the process exits.
+func NewMemory() *Store {
+	return &Store{store: newMemoryStore()}
+}
+
+// String describes where preferences are kept, for the startup logs.
+func (s *Store) String() string {
+	return s.store.String()
+}
+
+// Get returns userID's preferences.
+func (s *Store) Get(ctx context.Context, userID string) (Preferences, error) {
+	return s.store.get(ctx, userID)
+}
+
+// Save records the fields of p that are set as userID's choices, keeping
+// the ones it leaves empty. userID's preferences expire ttl after they were
+// last saved, which suits anonymous sessions, or never when ttl is 0.
+func (s *Store) Save(ctx context.Context, userID string, p Preferences, ttl time.Duration) error {
+	if userID == "" {
+		return fmt.Errorf("user ID is required")
+	}
+	if p == (Preferences{}) {
+		return nil
+	}
+	return s.store.save(ctx, userID, p, ttl)
+}
+
+// Merge moves the choices fromUserID made onto toUserID, whose preferences
+// no longer expire, replacing the ones toUserID made before.
+func (s *Store) Merge(ctx context.Context, fromUserID, toUserID string) error {
+	if fromUserID == toUserID {
+		return nil
+	}
+	p, err := s.store.get(ctx, fromUserID)
+	if err != nil {
+		return err
+	}
+	if p == (Preferences{}) {
+		return nil
+	}
+	if err := s.store.save(ctx, toUserID, p, 0); err != nil {
+		return err
+	}
+	return s.store.remove(ctx, fromUserID)
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preferences
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// testStores returns the stores to test: memory, and Redis at
+// TEST_REDIS_ADDR when it is set.
+func testStores(t *testing.T) map[string]store {
+	stores := map[string]store{"memory": newMemoryStore()}
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		s := newRedisStore(addr)
+		t.Cleanup(func() { s.client.Close() })
+		stores["redis"] = s
+	}
+	return stores
+}
+
+func TestSaveAndGet(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			prefs := &Store{store: s}
+			user := uuid.NewString()
+
+			if p, err := prefs.Get(ctx, user); err != nil || p != (Preferences{}) {
+				t.Fatalf("Get() = %+v, %v, want no preferences", p, err)
+			}
+			if err := prefs.Save(ctx, user, Preferences{Currency: "EUR"}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if err := prefs.Save(ctx, user, Preferences{Locale: "fr-FR"}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			want := Preferences{Currency: "EUR", Locale: "fr-FR"}
+			if p, err := prefs.Get(ctx, user); err != nil || p != want {
+				t.Errorf("Get() = %+v, %v, want %+v", p, err, want)
+			}
+			if err := prefs.Save(ctx, "", want, 0); err == nil {
+				t.Error("Save() with no user ID succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := newMemoryStore()
+	s.now = func() time.Time { return now }
+	prefs := &Store{store: s}
+
+	if err := prefs.Save(ctx, "session", Preferences{Currency: "JPY"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	if p, _ := prefs.Get(ctx, "session"); p != (Preferences{}) {
+		t.Errorf("Get() after the TTL = %+v, want no preferences", p)
+	}
+
+	if err := prefs.Save(ctx, "account", Preferences{Currency: "JPY"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(365 * 24 * time.Hour)
+	if p, _ := prefs.Get(ctx, "account"); p.Currency != "JPY" {
+		t.Errorf("Get() with no TTL = %+v, want currency JPY", p)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			prefs := &Store{store: s}
+			session, account := uuid.NewString(), uuid.NewString()
+
+			if err := prefs.Save(ctx, account, Preferences{Currency: "USD", Locale: "de-DE"}, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := prefs.Save(ctx, session, Preferences{Currency: "CAD"}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if err := prefs.Merge(ctx, session, account); err != nil {
+				t.Fatal(err)
+			}
+			want := Preferences{Currency: "CAD", Locale: "de-DE"}
+			if p, err := prefs.Get(ctx, account); err != nil || p != want {
+				t.Errorf("account preferences = %+v, %v, want %+v", p, err, want)
+			}
+			if p, err := prefs.Get(ctx, session); err != nil || p != (Preferences{}) {
+				t.Errorf("session preferences = %+v, %v, want none", p, err)
+			}
+
+			// an account signing in from a session with no choices keeps
+			// its own
+			if err := prefs.Merge(ctx, uuid.NewString(), account); err != nil {
+				t.Fatal(err)
+			}
+			if p, _ := prefs.Get(ctx, account); p != want {
+				t.Errorf("account preferences = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	intercept := UnaryClientInterceptor()
+
+	ctx := NewContext(context.Background(), Preferences{Currency: "EUR", Locale: "fr-FR"})
+	if err := intercept(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if v := md.Get(MetadataCurrency); len(v) != 1 || v[0] != "EUR" {
+		t.Errorf("outgoing %s = %v, want [EUR]", MetadataCurrency, v)
+	}
+	if v := md.Get(MetadataLocale); len(v) != 1 || v[0] != "fr-FR" {
+		t.Errorf("outgoing %s = %v, want [fr-FR]", MetadataLocale, v)
+	}
+
+	if err := intercept(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if len(md) != 0 {
+		t.Errorf("outgoing metadata = %v, want none without preferences", md)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preferences
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Preferences are kept as hashes under prefsPrefix and the user ID, with a
+// field for each choice the user made.
+const prefsPrefix = "preferences:"
+
+const (
+	fieldCurrency = "currency"
+	fieldLocale   = "locale"
+)
+
+// redisStore keeps preferences in Redis.
+type redisStore struct {
+	addr   string
+	client *redis.Client
+}
+
+// newRedisStore returns a store in the Redis server at addr, a host:port.
+func newRedisStore(addr string) *redisStore {
+	return &redisStore{addr: addr, client: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+func (s *redisStore) get(ctx context.Context, userID string) (Preferences, error) {
+	fields, err := s.client.HGetAll(ctx, prefsPrefix+userID).Result()
+	if err != nil {
+		return Preferences{}, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return Preferences{Currency: fields[fieldCurrency], Locale: fields[fieldLocale]}, nil
+}
+
+func (s *redisStore) save(ctx context.Context, userID string, p Preferences, ttl time.Duration) error {
+	key := prefsPrefix + userID
+	var values []any
+	if p.Currency != "" {
+		values = append(values, fieldCurrency, p.Currency)
+	}
+	if p.Locale != "" {
+		values = append(values, fieldLocale, p.Locale)
+	}
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, values...)
+		if ttl > 0 {
+			pipe.Expire(ctx, key, ttl)
+		} else {
+			pipe.Persist(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) remove(ctx context.Context, userID string) error {
+	if err := s.client.Del(ctx, prefsPrefix+userID).Err(); err != nil {
+		return fmt.Errorf("failed to remove preferences: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) String() string {
+	return "in Redis at " + s.addr
+}
@@ -0,0 +1,264 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits how often each client may make requests to the
+// frontend, so that a load spike or a runaway client slows down itself rather
+// than the shop.
+//
+// Requests are either browsing or checkout requests, and every client has a
+// token bucket for each, holding up to a Budget's Burst requests and
+// refilled at its Rate requests per second, so that browsing does not use up
+// the budget for placing orders. The frontend tells clients apart by their
+// signed-in account or address, looking through the X-Forwarded-For headers
+// of trusted proxies only. Requests over the limit are counted by class in the
+// ratelimit.rejected counter. Buckets that have refilled are forgotten, so
+// that idle clients cost nothing.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+// ReasonRateLimited is the error reason of requests over their limit.
+const ReasonRateLimited = "RATE_LIMITED"
+
+// Class is the budget a request is counted against.
+type Class int
+
+// Classes, each with their own budget.
+const (
+	Browse Class = iota
+	Checkout
+)
+
+const (
+	defaultBrowseBurst   = 20
+	defaultCheckoutBurst = 3
+
+	// sweepInterval is how often buckets that have refilled are forgotten.
+	sweepInterval = time.Minute
+)
+
+var classNames = [...]string{Browse: "browse", Checkout: "checkout"}
+
+func (c Class) String() string {
+	if c < Browse || c > Checkout {
+		return strconv.Itoa(int(c))
+	}
+	return classNames[c]
+}
+
+// Budget is the limit of every client for a class of requests.
+type Budget struct {
+	// Rate is the sustained number of requests per second a client may
+	// make, or 0 for no limit, and Burst how many it may make at once.
+	Rate  float64
+	Burst int
+}
+
+func (b Budget) String() string {
+	if b.Rate == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g requests per second, bursts of %d", b.Rate, b.Burst)
+}
+
+// Config sets the budgets of each class.
+type Config struct {
+	Browse, Checkout Budget
+	// TrustedProxies are the addresses whose X-Forwarded-For headers are
+	// believed.
+	TrustedProxies []netip.Prefix
+}
+
+// Limiter holds the buckets of the clients. A nil Limiter lets every request
+// through.
+type Limiter struct {
+	budgets [len(classNames)]Budget
+	proxies []netip.Prefix
+	now     func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*rate.Limiter
+	lastSweep time.Time
+
+	rejected metric.Int64Counter
+}
+
+type bucketKey struct {
+	class  Class
+	client string
+}
+
+// FromEnv returns a Limiter configured with RATE_LIMIT_BROWSE and
+// RATE_LIMIT_CHECKOUT, in requests per second per client, and
+// RATE_LIMIT_BROWSE_BURST (default 20) and RATE_LIMIT_CHECKOUT_BURST
+// (default 3), or nil if neither rate is set. A class whose rate is unset or
+// 0 is not limited. RATE_LIMIT_TRUSTED_PROXIES lists the proxies, as
+// addresses or CIDR ranges separated by commas, whose X-Forwarded-For headers
+// are believed.
+func FromEnv() (*Limiter, error) {
+	var cfg Config
+	proxies, err := ParseProxies(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_TRUSTED_PROXIES: %v", err)
+	}
+	cfg.TrustedProxies = proxies
+	for _, b := range []struct {
+		budget *Budget
+		name   string
+		burst  int
+	}{
+		{&cfg.Browse, "BROWSE", defaultBrowseBurst},
+		{&cfg.Checkout, "CHECKOUT", defaultCheckoutBurst},
+	} {
+		b.budget.Burst = b.burst
+		key := "RATE_LIMIT_" + b.name
+		if v := os.Getenv(key); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return nil, fmt.Errorf("invalid %s %q", key, v)
+			}
+			b.budget.Rate = f
+		}
+		if v := os.Getenv(key + "_BURST"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s_BURST %q", key, v)
+			}
+			b.budget.Burst = n
+		}
+	}
+	if cfg.Browse.Rate == 0 && cfg.Checkout.Rate == 0 {
+		return nil, nil
+	}
+	return New(cfg), nil
+}
+
+// ParseProxies parses a comma-separated list of addresses and CIDR ranges.
+func ParseProxies(s string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, netip.PrefixFrom(a, a.BitLen()))
+	}
+	return out, nil
+}
+
+// New returns a Limiter for cfg.
+func New(cfg Config) *Limiter {
+	l := &Limiter{proxies: cfg.TrustedProxies, now: time.Now, buckets: make(map[bucketKey]*rate.Limiter)}
+	l.budgets[Browse], l.budgets[Checkout] = cfg.Browse, cfg.Checkout
+	l.rejected, _ = otel.Meter("ratelimit").Int64Counter(
+		"ratelimit.rejected",
+		metric.WithDescription("Requests refused because their client was over its rate limit, by class."),
+		metric.WithUnit("{request}"))
+	return l
+}
+
+// String describes the configuration, for logging.
+func (l *Limiter) String() string {
+	if l == nil {
+		return "disabled"
+	}
+	var parts []string
+	for c, b := range l.budgets {
+		parts = append(parts, fmt.Sprintf("%s %v", Class(c), b))
+	}
+	s := strings.Join(parts, ", ") + " per client"
+	if len(l.proxies) > 0 {
+		s += fmt.Sprintf(", behind proxies %v", l.proxies)
+	}
+	return s
+}
+
+// TrustsProxy reports whether the X-Forwarded-For header of requests from
+// addr is believed.
+func (l *Limiter) TrustsProxy(addr netip.Addr) bool {
+	if l == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range l.proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow reports whether the client identified by key may make a request of
+// class c now, and if not how long it must wait.
+func (l *Limiter) Allow(ctx context.Context, c Class, key string) (bool, time.Duration) {
+	if l == nil || l.budgets[c].Rate == 0 {
+		return true, 0
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweepLocked(now)
+	}
+	k := bucketKey{c, key}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = rate.NewLimiter(rate.Limit(l.budgets[c].Rate), l.budgets[c].Burst)
+		l.buckets[k] = b
+	}
+	r := b.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		l.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("class", c.String())))
+		return false, d
+	}
+	return true, 0
+}
+
+// sweepLocked forgets the buckets that have refilled, which are the same as
+// new ones.
+func (l *Limiter) sweepLocked(now time.Time) {
+	for k, b := range l.buckets {
+		if b.TokensAt(now) >= float64(l.budgets[k.class].Burst) {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
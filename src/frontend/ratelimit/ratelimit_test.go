@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestFromEnv(t *testing.T) {
+	keys := []string{"RATE_LIMIT_BROWSE", "RATE_LIMIT_BROWSE_BURST", "RATE_LIMIT_CHECKOUT", "RATE_LIMIT_CHECKOUT_BURST", "RATE_LIMIT_TRUSTED_PROXIES"}
+	for _, tt := range []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"unset", nil, "disabled", false},
+		{"browse", map[string]string{"RATE_LIMIT_BROWSE": "10"}, "browse 10 requests per second, bursts of 20, checkout unlimited per client", false},
+		{"both", map[string]string{"RATE_LIMIT_BROWSE": "5", "RATE_LIMIT_BROWSE_BURST": "8", "RATE_LIMIT_CHECKOUT": "0.5"}, "browse 5 requests per second, bursts of 8, checkout 0.5 requests per second, bursts of 3 per client", false},
+		{"off", map[string]string{"RATE_LIMIT_BROWSE": "0", "RATE_LIMIT_CHECKOUT": "0"}, "disabled", false},
+		{"invalid rate", map[string]string{"RATE_LIMIT_CHECKOUT": "-1"}, "", true},
+		{"invalid burst", map[string]string{"RATE_LIMIT_BROWSE_BURST": "0"}, "", true},
+		{"proxies", map[string]string{"RATE_LIMIT_BROWSE": "10", "RATE_LIMIT_TRUSTED_PROXIES": "10.1.2.3/8, 192.0.2.1"}, "browse 10 requests per second, bursts of 20, checkout unlimited per client, behind proxies [10.0.0.0/8 192.0.2.1/32]", false},
+		{"invalid proxies", map[string]string{"RATE_LIMIT_BROWSE": "10", "RATE_LIMIT_TRUSTED_PROXIES": "10.0.0.0/33"}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range keys {
+				t.Setenv(key, tt.env[key])
+			}
+			l, err := FromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && l.String() != tt.want {
+				t.Errorf("FromEnv() = %q, want %q", l, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustsProxy(t *testing.T) {
+	proxies, err := ParseProxies("10.0.0.0/8,2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Config{Browse: Budget{Rate: 1, Burst: 1}, TrustedProxies: proxies})
+	for addr, want := range map[string]bool{
+		"10.4.0.1":        true,
+		"::ffff:10.4.0.1": true,
+		"2001:db8::1":     true,
+		"2001:db8::2":     false,
+		"203.0.113.9":     false,
+	} {
+		if got := l.TrustsProxy(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("TrustsProxy(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	var nilLimiter *Limiter
+	if nilLimiter.TrustsProxy(netip.MustParseAddr("10.4.0.1")) {
+		t.Error("nil Limiter trusts a proxy")
+	}
+}
+
+func TestAllow(t *testing.T) {
+	l := New(Config{Browse: Budget{Rate: 1, Burst: 2}, Checkout: Budget{Rate: 0.5, Burst: 1}})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(ctx, Browse, "session:a"); !ok {
+			t.Fatalf("request %d of a burst of 2 was limited", i+1)
+		}
+	}
+	ok, wait := l.Allow(ctx, Browse, "session:a")
+	if ok || wait != time.Second {
+		t.Fatalf("Allow() over the burst = %v, %v, want false, 1s", ok, wait)
+	}
+	if ok, _ := l.Allow(ctx, Browse, "session:b"); !ok {
+		t.Error("another client was limited")
+	}
+	if ok, _ := l.Allow(ctx, Checkout, "session:a"); !ok {
+		t.Error("checkout was limited by the browsing budget")
+	}
+	if ok, wait := l.Allow(ctx, Checkout, "session:a"); ok || wait != 2*time.Second {
+		t.Errorf("second checkout = %v, %v, want false, 2s", ok, wait)
+	}
+	// a limited request takes no token
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow(ctx, Browse, "session:a"); !ok {
+		t.Error("request once a token refilled was limited")
+	}
+	if ok, _ := l.Allow(ctx, Browse, "session:a"); ok {
+		t.Error("second request after one token refilled was allowed")
+	}
+}
+
+func TestAllowUnlimited(t *testing.T) {
+	l := New(Config{Checkout: Budget{Rate: 1, Burst: 1}})
+	var off *Limiter
+	for i := 0; i < 50; i++ {
+		if ok, _ := l.Allow(context.Background(), Browse, "session:a"); !ok {
+			t.Fatalf("request %d of a class without a limit was limited", i+1)
+		}
+		if ok, _ := off.Allow(context.Background(), Checkout, "session:a"); !ok {
+			t.Fatalf("nil Limiter limited request %d", i+1)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l := New(Config{Browse: Budget{Rate: 1, Burst: 2}})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	l.Allow(context.Background(), Browse, "session:a")
+	now = now.Add(sweepInterval)
+	l.Allow(context.Background(), Browse, "session:b")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.buckets[bucketKey{Browse, "session:a"}]; ok {
+		t.Error("refilled bucket was not forgotten")
+	}
+	if _, ok := l.buckets[bucketKey{Browse, "session:b"}]; !ok {
+		t.Error("bucket in use was forgotten")
+	}
+}
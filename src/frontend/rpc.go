@@ -19,12 +19,19 @@ import (
 	"time"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	pbv2 "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto/hipstershop/v2"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/rpcerrors"
 
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	avoidNoopCurrencyConversionRPC = false
+
+	// ordersPageSize is how many orders the order history shows at a time.
+	ordersPageSize = 10
 )
 
 func (fe *frontendServer) getCurrencies(ctx context.Context) ([]string, error) {
@@ -43,9 +50,25 @@ func (fe *frontendServer) getCurrencies(ctx context.Context) ([]string, error) {
 }
 
 func (fe *frontendServer) getProducts(ctx context.Context) ([]*pb.Product, error) {
+	products, _, err := fe.listProducts(ctx, 0, 0, pb.ProductSort_PRODUCT_SORT_UNSPECIFIED)
+	return products, err
+}
+
+// listProducts returns page of the pages of size products of the catalog
+// sorted by sort, and the number of products across all pages.
+func (fe *frontendServer) listProducts(ctx context.Context, page, size int, sort pb.ProductSort) ([]*pb.Product, int, error) {
+	l, err := fe.catalog.listing(ctx, listingKey{page: page, size: size, sort: sort}, func(ctx context.Context) (listing, error) {
+		resp, err := pb.NewProductCatalogServiceClient(fe.productCatalogSvcConn).
+			ListProducts(ctx, &pb.ListProductsRequest{PageSize: int32(size), Page: int32(page), Sort: sort})
+		return listing{products: resp.GetProducts(), total: int(resp.GetTotalSize())}, err
+	})
+	return l.products, l.total, err
+}
+
+func (fe *frontendServer) searchProducts(ctx context.Context, query string) ([]*pb.Product, error) {
 	resp, err := pb.NewProductCatalogServiceClient(fe.productCatalogSvcConn).
-		ListProducts(ctx, &pb.Empty{})
-	return resp.GetProducts(), err
+		SearchProducts(ctx, &pb.SearchProductsRequest{Query: query})
+	return resp.GetResults(), err
 }
 
 func (fe *frontendServer) getProduct(ctx context.Context, id string) (*pb.Product, error) {
@@ -64,6 +87,16 @@ func (fe *frontendServer) emptyCart(ctx context.Context, userID string) error {
 	return err
 }
 
+// mergeCart moves the items in the cart of fromUserID into that of toUserID,
+// adding up the quantities of the products in both.
+func (fe *frontendServer) mergeCart(ctx context.Context, fromUserID, toUserID string) ([]*pb.CartItem, error) {
+	resp, err := pb.NewCartServiceClient(fe.cartSvcConn).MergeCart(ctx, &pb.MergeCartRequest{
+		FromUserId: fromUserID,
+		ToUserId:   toUserID,
+	})
+	return resp.GetItems(), errors.Wrap(err, "failed to merge carts")
+}
+
 func (fe *frontendServer) insertCart(ctx context.Context, userID, productID string, quantity int32) error {
 	_, err := pb.NewCartServiceClient(fe.cartSvcConn).AddItem(ctx, &pb.AddItemRequest{
 		UserId: userID,
@@ -74,6 +107,28 @@ func (fe *frontendServer) insertCart(ctx context.Context, userID, productID stri
 	return err
 }
 
+// listReviews returns the approved reviews of productID, newest first, and
+// the summary of their ratings.
+func (fe *frontendServer) listReviews(ctx context.Context, productID string) (*pb.ListReviewsResponse, error) {
+	return pb.NewReviewServiceClient(fe.productCatalogSvcConn).
+		ListReviews(ctx, &pb.ListReviewsRequest{ProductId: productID})
+}
+
+func (fe *frontendServer) submitReview(ctx context.Context, req *pb.SubmitReviewRequest) (*pb.Review, error) {
+	return pb.NewReviewServiceClient(fe.productCatalogSvcConn).SubmitReview(ctx, req)
+}
+
+// onWishlist reports whether productID is on userID's wishlist.
+func (fe *frontendServer) onWishlist(ctx context.Context, userID, productID string) (bool, error) {
+	items, err := fe.wishlist.Items(ctx, userID)
+	for _, item := range items {
+		if item.ProductID == productID {
+			return true, nil
+		}
+	}
+	return false, err
+}
+
 func (fe *frontendServer) convertCurrency(ctx context.Context, money *pb.Money, currency string) (*pb.Money, error) {
 	if avoidNoopCurrencyConversionRPC && money.GetCurrencyCode() == currency {
 		return money, nil
@@ -84,6 +139,11 @@ func (fe *frontendServer) convertCurrency(ctx context.Context, money *pb.Money,
 			ToCode: currency})
 }
 
+// productPrice returns the price of p in currency.
+func (fe *frontendServer) productPrice(ctx context.Context, p *pb.Product, currency string) (*pb.Money, error) {
+	return fe.catalog.price(ctx, p.GetPriceUsd(), currency, fe.convertCurrency)
+}
+
 func (fe *frontendServer) getShippingQuote(ctx context.Context, items []*pb.CartItem, currency string) (*pb.Money, error) {
 	quote, err := pb.NewShippingServiceClient(fe.shippingSvcConn).GetQuote(ctx,
 		&pb.GetQuoteRequest{
@@ -125,3 +185,77 @@ func (fe *frontendServer) getAd(ctx context.Context, ctxKeys []string) ([]*pb.Ad
 	})
 	return resp.GetAds(), errors.Wrap(err, "failed to get ads")
 }
+
+// isOutOfStock reports whether a product is known to be out of stock. It
+// reports false when back-in-stock notifications are not configured.
+func (fe *frontendServer) isOutOfStock(ctx context.Context, productID string) (bool, error) {
+	if fe.notificationSvcConn == nil {
+		return false, nil
+	}
+	resp, err := pb.NewNotificationServiceClient(fe.notificationSvcConn).
+		GetAvailability(ctx, &pb.GetAvailabilityRequest{ProductId: productID})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetKnown() && resp.GetQuantity() <= 0, nil
+}
+
+func (fe *frontendServer) subscribeBackInStock(ctx context.Context, productID, email string) error {
+	_, err := pb.NewNotificationServiceClient(fe.notificationSvcConn).
+		SubscribeBackInStock(ctx, &pb.SubscribeBackInStockRequest{ProductId: productID, Email: email})
+	return err
+}
+
+// placeOrder places an order through the v2 checkout API, falling back to
+// the deprecated v1 API while checkoutservice is older than the frontend.
+// The v1 API cannot deduplicate retries nor apply promo codes, so orders
+// with one fail with PROMO_CODE_INVALID rather than being charged in full.
+func (fe *frontendServer) placeOrder(ctx context.Context, req *pbv2.PlaceOrderRequest) (*pbv2.PlaceOrderResponse, error) {
+	resp, err := pbv2.NewCheckoutServiceClient(fe.checkoutSvcConn).PlaceOrder(ctx, req)
+	if status.Code(err) != codes.Unimplemented {
+		return resp, err
+	}
+	if req.GetPromoCode() != "" {
+		return nil, rpcerrors.Errorf(codes.InvalidArgument, reasonPromoCodeInvalid, "promo codes cannot be applied by this checkoutservice")
+	}
+	v1, err := pb.NewCheckoutServiceClient(fe.checkoutSvcConn).
+		PlaceOrder(ctx, &pb.PlaceOrderRequest{
+			UserId:       req.GetUserId(),
+			UserCurrency: req.GetUserCurrency(),
+			Address:      req.GetAddress(),
+			Email:        req.GetEmail(),
+			Locale:       req.GetLocale(),
+			CreditCard:   req.GetPaymentMethod().GetCreditCard(),
+			// ignored by checkoutservice versions that do not store it
+			BillingAddress: req.GetBillingAddress(),
+			GiftMessage:    req.GetGiftMessage(),
+			CustomerNote:   req.GetCustomerNote(),
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &pbv2.PlaceOrderResponse{Order: v1.GetOrder()}, nil
+}
+
+// listOrders returns a page of the orders of userID, newest first, starting
+// at pageToken.
+func (fe *frontendServer) listOrders(ctx context.Context, userID, pageToken string) (*pbv2.ListOrdersResponse, error) {
+	resp, err := pbv2.NewCheckoutServiceClient(fe.checkoutSvcConn).ListOrders(ctx, &pbv2.ListOrdersRequest{
+		UserId:    userID,
+		PageSize:  ordersPageSize,
+		PageToken: pageToken,
+	})
+	return resp, errors.Wrap(err, "failed to list orders")
+}
+
+// getOrder returns the order with ID orderID.
+func (fe *frontendServer) getOrder(ctx context.Context, orderID string) (*pbv2.Order, error) {
+	resp, err := pbv2.NewCheckoutServiceClient(fe.checkoutSvcConn).GetOrder(ctx, &pbv2.GetOrderRequest{OrderId: orderID})
+	return resp, errors.Wrapf(err, "failed to get order %s", orderID)
+}
+
+// getShipment returns where the shipment with trackingID is.
+func (fe *frontendServer) getShipment(ctx context.Context, trackingID string) (*pb.Shipment, error) {
+	resp, err := pb.NewShippingServiceClient(fe.shippingSvcConn).GetShipment(ctx, &pb.GetShipmentRequest{TrackingId: trackingID})
+	return resp, errors.Wrapf(err, "failed to get shipment %s", trackingID)
+}
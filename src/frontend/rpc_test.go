@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/contract"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/preferences"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/wishlist"
+)
+
+// newFakeFrontend returns a frontend whose catalog, cart, currency and
+// shipping backends are fakes, keeping wishlists in memory.
+func newFakeFrontend(t *testing.T, rates map[string]float64) *frontendServer {
+	t.Helper()
+	conn := contract.Serve(t, func(srv *grpc.Server) {
+		fakes.NewProductCatalog().Register(srv)
+		fakes.NewCart().Register(srv)
+		fakes.NewCurrency(rates).Register(srv)
+		fakes.NewShipping().Register(srv)
+	})
+	return &frontendServer{
+		productCatalogSvcConn: conn,
+		cartSvcConn:           conn,
+		currencySvcConn:       conn,
+		shippingSvcConn:       conn,
+		wishlist:              wishlist.NewMemory(),
+		preferences:           preferences.NewMemory(),
+	}
+}
+
+func TestGetCurrenciesOnlyWhitelisted(t *testing.T) {
+	rates := fakes.Rates()
+	rates["BGN"] = 1.9558
+	fe := newFakeFrontend(t, rates)
+
+	got, err := fe.getCurrencies(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(whitelistedCurrencies) {
+		t.Errorf("getCurrencies = %v, want the %d whitelisted currencies", got, len(whitelistedCurrencies))
+	}
+	for _, code := range got {
+		if !whitelistedCurrencies[code] {
+			t.Errorf("getCurrencies returned %s, which is not whitelisted", code)
+		}
+	}
+}
+
+func TestGetShippingQuoteConverts(t *testing.T) {
+	fe := newFakeFrontend(t, map[string]float64{"EUR": 1, "USD": 2})
+
+	got, err := fe.getShippingQuote(context.Background(), []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetCurrencyCode() != "EUR" || got.GetUnits() != 4 || got.GetNanos() != 495000000 {
+		t.Errorf("shipping quote = %v, want 4.495 EUR", got)
+	}
+
+	if _, err := fe.getShippingQuote(context.Background(), nil, "XTS"); err == nil {
+		t.Error("quote in an unsupported currency succeeded")
+	}
+}
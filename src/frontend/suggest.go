@@ -0,0 +1,204 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// The search box suggests products as the shopper types, from GET
+// /api/suggest. Every keystroke is a request, so the suggestions for each
+// query are cached for SUGGEST_CACHE_TTL (default 30s), and the requests for
+// a query that is not cached yet share a single SearchProducts call rather
+// than making one each. SUGGEST_CACHE_TTL=0 turns the cache off.
+
+const (
+	defaultSuggestLimit = 5
+	maxSuggestLimit     = 10
+	// queries shorter than minSuggestQueryLen match too much to be worth
+	// suggesting for
+	minSuggestQueryLen = 2
+	maxSearchQueryLen  = 100
+
+	defaultSuggestCacheTTL = 30 * time.Second
+	// maxSuggestCacheEntries bounds the memory the cache takes, as the
+	// queries are whatever shoppers type.
+	maxSuggestCacheEntries = 1000
+	// suggestTimeout bounds the SearchProducts calls the requests for a query
+	// share, which outlive any one of them.
+	suggestTimeout = 2 * time.Second
+)
+
+// suggestion is a product suggested for a query.
+type suggestion struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// suggestionCache caches the suggestions for each query. A nil
+// suggestionCache searches for every query.
+type suggestionCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedSuggestions
+
+	searches singleflight.Group
+}
+
+// cachedSuggestions are the suggestions for a query, until expires.
+type cachedSuggestions struct {
+	suggestions []suggestion
+	expires     time.Time
+}
+
+// newSuggestionCacheFromEnv returns the cache set up by SUGGEST_CACHE_TTL,
+// or nil if it is turned off.
+func newSuggestionCacheFromEnv() (*suggestionCache, error) {
+	c := newSuggestionCache()
+	if v := os.Getenv("SUGGEST_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SUGGEST_CACHE_TTL %q", v)
+		}
+		if d == 0 {
+			return nil, nil
+		}
+		c.ttl = d
+	}
+	return c, nil
+}
+
+func newSuggestionCache() *suggestionCache {
+	return &suggestionCache{
+		ttl:     defaultSuggestCacheTTL,
+		now:     time.Now,
+		entries: make(map[string]cachedSuggestions),
+	}
+}
+
+// String describes the cache for startup logs.
+func (c *suggestionCache) String() string {
+	if c == nil {
+		return "off"
+	}
+	return fmt.Sprintf("TTL %s", c.ttl)
+}
+
+// suggest returns up to maxSuggestLimit products to suggest for query, from
+// the cache unless it has expired, and calling search otherwise.
+func (c *suggestionCache) suggest(ctx context.Context, query string,
+	search func(ctx context.Context, query string) ([]*pb.Product, error)) ([]suggestion, error) {
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	if len([]rune(query)) < minSuggestQueryLen {
+		return nil, nil
+	}
+	if c == nil {
+		products, err := search(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return rankSuggestions(query, products), nil
+	}
+
+	c.mu.Lock()
+	cached, ok := c.entries[query]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.suggestions, nil
+	}
+	v, err, _ := c.searches.Do(query, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), suggestTimeout)
+		defer cancel()
+		products, err := search(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		suggestions := rankSuggestions(query, products)
+		c.store(query, suggestions)
+		return suggestions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]suggestion), nil
+}
+
+// store caches the suggestions for query, first dropping the expired entries
+// if the cache is full, and every entry if none has expired.
+func (c *suggestionCache) store(query string, suggestions []suggestion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxSuggestCacheEntries {
+		for q, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, q)
+			}
+		}
+		if len(c.entries) >= maxSuggestCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[query] = cachedSuggestions{suggestions: suggestions, expires: now.Add(c.ttl)}
+}
+
+// rankSuggestions returns up to maxSuggestLimit of products whose names
+// contain query, which is in lower case: those whose names start with it
+// first, then those with a word starting with it, then the others, each by
+// name.
+func rankSuggestions(query string, products []*pb.Product) []suggestion {
+	rank := func(p *pb.Product) int {
+		name := strings.ToLower(p.GetName())
+		switch {
+		case strings.HasPrefix(name, query):
+			return 0
+		case strings.Contains(name, " "+query):
+			return 1
+		case strings.Contains(name, query):
+			return 2
+		}
+		return -1
+	}
+	var matches []*pb.Product
+	for _, p := range products {
+		if rank(p) >= 0 {
+			matches = append(matches, p)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b *pb.Product) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return strings.Compare(strings.ToLower(a.GetName()), strings.ToLower(b.GetName()))
+	})
+	suggestions := make([]suggestion, 0, min(len(matches), maxSuggestLimit))
+	for _, p := range matches[:min(len(matches), maxSuggestLimit)] {
+		suggestions = append(suggestions, suggestion{ID: p.GetId(), Name: p.GetName()})
+	}
+	return suggestions
+}
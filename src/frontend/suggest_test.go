@@ -0,0 +1,160 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/fakes"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// suggestedNames returns the names of suggestions, in order.
+func suggestedNames(suggestions []suggestion) []string {
+	names := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func TestRankSuggestions(t *testing.T) {
+	products := []*pb.Product{
+		{Id: "1", Name: "Salt & Pepper Shakers"},
+		{Id: "2", Name: "Candle Holder"},
+		{Id: "3", Name: "Hairdryer"},
+		{Id: "4", Name: "Vintage Camera Lens"},
+		{Id: "5", Name: "Mug"},
+		{Id: "6", Name: "Camera"},
+	}
+	if got, want := suggestedNames(rankSuggestions("ca", products)), []string{"Camera", "Candle Holder", "Vintage Camera Lens"}; !slices.Equal(got, want) {
+		t.Errorf("suggestions for ca = %q, want %q", got, want)
+	}
+	if got, want := suggestedNames(rankSuggestions("ha", products)), []string{"Hairdryer", "Salt & Pepper Shakers"}; !slices.Equal(got, want) {
+		t.Errorf("suggestions for ha = %q, want %q", got, want)
+	}
+
+	var many []*pb.Product
+	for range maxSuggestLimit + 5 {
+		many = append(many, &pb.Product{Name: "Mug"})
+	}
+	if got := rankSuggestions("mug", many); len(got) != maxSuggestLimit {
+		t.Errorf("%d suggestions, want at most %d", len(got), maxSuggestLimit)
+	}
+}
+
+func TestSuggestionCache(t *testing.T) {
+	catalog := fakes.NewProductCatalog()
+	var searches atomic.Int32
+	search := func(ctx context.Context, query string) ([]*pb.Product, error) {
+		searches.Add(1)
+		resp, err := catalog.SearchProducts(ctx, &pb.SearchProductsRequest{Query: query})
+		return resp.GetResults(), err
+	}
+	now := time.Now()
+	c := newSuggestionCache()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, q := range []string{"sun", " SUN ", "Sun"} {
+		got, err := c.suggest(ctx, q, search)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := suggestedNames(got); !slices.Equal(names, []string{"Sunglasses"}) {
+			t.Errorf("suggestions for %q = %q, want Sunglasses", q, names)
+		}
+	}
+	if n := searches.Load(); n != 1 {
+		t.Errorf("%d searches for the same query, want 1", n)
+	}
+	if got, _ := c.suggest(ctx, "s", search); len(got) != 0 || searches.Load() != 1 {
+		t.Errorf("suggestions for a one-letter query = %v, want none without searching", got)
+	}
+
+	now = now.Add(c.ttl)
+	if _, err := c.suggest(ctx, "sun", search); err != nil {
+		t.Fatal(err)
+	}
+	if n := searches.Load(); n != 2 {
+		t.Errorf("%d searches after the TTL, want 2", n)
+	}
+
+	// failures are not cached
+	fail := func(context.Context, string) ([]*pb.Product, error) {
+		searches.Add(1)
+		return nil, errors.New("catalog unavailable")
+	}
+	for range 2 {
+		if _, err := c.suggest(ctx, "watch", fail); err == nil {
+			t.Error("suggest succeeded with the catalog unavailable")
+		}
+	}
+	if n := searches.Load(); n != 4 {
+		t.Errorf("%d searches, want failed ones retried", n)
+	}
+}
+
+func TestSuggestionCacheSharesSearches(t *testing.T) {
+	release := make(chan struct{})
+	var searches atomic.Int32
+	search := func(context.Context, string) ([]*pb.Product, error) {
+		searches.Add(1)
+		<-release
+		return []*pb.Product{{Id: "1", Name: "Watch"}}, nil
+	}
+	c := newSuggestionCache()
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := c.suggest(context.Background(), "wat", search); err != nil || len(got) != 1 {
+				t.Errorf("suggest = %v, %v, want Watch", got, err)
+			}
+		}()
+	}
+	// let the requests line up behind the first search
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := searches.Load(); n != 1 {
+		t.Errorf("%d searches for concurrent requests, want 1", n)
+	}
+}
+
+func TestNilSuggestionCache(t *testing.T) {
+	var c *suggestionCache
+	var searches int
+	search := func(context.Context, string) ([]*pb.Product, error) {
+		searches++
+		return []*pb.Product{{Id: "1", Name: "Watch"}}, nil
+	}
+	for range 2 {
+		if got, err := c.suggest(context.Background(), "wat", search); err != nil || len(got) != 1 {
+			t.Errorf("suggest = %v, %v, want Watch", got, err)
+		}
+	}
+	if searches != 2 {
+		t.Errorf("%d searches with the cache off, want 2", searches)
+	}
+}
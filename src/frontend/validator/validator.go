@@ -50,12 +50,52 @@ type PlaceOrderPayload struct {
 	CcMonth       int64  `validate:"required,gte=1,lte=12"`
 	CcYear        int64  `validate:"required"`
 	CcCVV         int64  `validate:"required"`
+	// SeparateBilling is set when the billing address is not the shipping
+	// address, and the billing fields are then required.
+	SeparateBilling      bool
+	BillingStreetAddress string `validate:"required_if=SeparateBilling true,max=512"`
+	BillingZipCode       int64  `validate:"required_if=SeparateBilling true"`
+	BillingCity          string `validate:"required_if=SeparateBilling true,max=128"`
+	BillingState         string `validate:"required_if=SeparateBilling true,max=128"`
+	BillingCountry       string `validate:"required_if=SeparateBilling true,max=128"`
+	GiftMessage          string `validate:"max=500"`
+	CustomerNote         string `validate:"max=1000"`
+}
+
+type BackInStockPayload struct {
+	ProductID string `validate:"required"`
+	Email     string `validate:"required,email"`
+}
+
+type ReviewPayload struct {
+	ProductID  string `validate:"required"`
+	AuthorName string `validate:"required,max=100"`
+	Rating     uint64 `validate:"required,gte=1,lte=5"`
+	Text       string `validate:"required,max=2000"`
+}
+
+type WishlistPayload struct {
+	ProductID string `validate:"required"`
 }
 
 type SetCurrencyPayload struct {
 	Currency string `validate:"required,iso4217"`
 }
 
+type ProductListingPayload struct {
+	Page int    `validate:"min=1"`
+	Size int    `validate:"min=1,max=48"`
+	Sort string `validate:"omitempty,oneof=name price_asc price_desc"`
+}
+
+type SearchPayload struct {
+	Query string `validate:"max=100"`
+}
+
+type SetLocalePayload struct {
+	Locale string `validate:"required,max=35,bcp47_language_tag"`
+}
+
 // Implementations of the 'Payload' interface.
 func (ad *AddToCartPayload) Validate() error {
 	return validate.Struct(ad)
@@ -65,10 +105,34 @@ func (po *PlaceOrderPayload) Validate() error {
 	return validate.Struct(po)
 }
 
+func (bs *BackInStockPayload) Validate() error {
+	return validate.Struct(bs)
+}
+
+func (rv *ReviewPayload) Validate() error {
+	return validate.Struct(rv)
+}
+
+func (wl *WishlistPayload) Validate() error {
+	return validate.Struct(wl)
+}
+
 func (sc *SetCurrencyPayload) Validate() error {
 	return validate.Struct(sc)
 }
 
+func (pl *ProductListingPayload) Validate() error {
+	return validate.Struct(pl)
+}
+
+func (sp *SearchPayload) Validate() error {
+	return validate.Struct(sp)
+}
+
+func (sl *SetLocalePayload) Validate() error {
+	return validate.Struct(sl)
+}
+
 // Reusable error response function.
 func ValidationErrorResponse(err error) error {
 	validationErrs, ok := err.(validator.ValidationErrors)
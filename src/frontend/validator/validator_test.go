@@ -143,6 +143,28 @@ func TestAddToCartFailsValidation(t *testing.T) {
 	}
 }
 
+func TestBackInStockValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		productID string
+		email     string
+		valid     bool
+	}{
+		{"valid", "OLJCESPC7Z", "someone@example.com", true},
+		{"invalid email", "OLJCESPC7Z", "someone", false},
+		{"missing email", "OLJCESPC7Z", "", false},
+		{"missing product id", "", "someone@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := BackInStockPayload{ProductID: tt.productID, Email: tt.email}
+			if err := payload.Validate(); (err == nil) != tt.valid {
+				t.Errorf("want valid=%v on %v, got %v", tt.valid, payload, err)
+			}
+		})
+	}
+}
+
 func TestSetCurrencyPassesValidation(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -183,3 +205,71 @@ func TestSetCurrencyFailsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestPlaceOrderBillingAddress(t *testing.T) {
+	valid := func() PlaceOrderPayload {
+		return PlaceOrderPayload{
+			Email:         "test@example.com",
+			StreetAddress: "12345 example street",
+			ZipCode:       10004,
+			City:          "New York",
+			State:         "New York",
+			Country:       "United States",
+			CcNumber:      "5272940000751666",
+			CcMonth:       4,
+			CcYear:        2024,
+			CcCVV:         584,
+		}
+	}
+	if p := valid(); p.Validate() != nil {
+		t.Errorf("want validation without a billing address on %v", p)
+	}
+	separate := valid()
+	separate.SeparateBilling = true
+	separate.BillingStreetAddress = "1 Market Street"
+	separate.BillingZipCode = 94105
+	separate.BillingCity = "San Francisco"
+	separate.BillingState = "CA"
+	separate.BillingCountry = "United States"
+	if err := separate.Validate(); err != nil {
+		t.Errorf("want validation on %v, got %v", separate, err)
+	}
+	missing := separate
+	missing.BillingCity = ""
+	if err := missing.Validate(); err == nil {
+		t.Errorf("want validation failure on %v with no billing city", missing)
+	}
+	long := separate
+	long.BillingStreetAddress = strings.Repeat("1 Market Street", 50)
+	if err := long.Validate(); err == nil {
+		t.Errorf("want validation failure on %v with a billing address too long", long)
+	}
+}
+
+func TestPlaceOrderMessages(t *testing.T) {
+	p := PlaceOrderPayload{
+		Email:         "test@example.com",
+		StreetAddress: "12345 example street",
+		ZipCode:       10004,
+		City:          "New York",
+		State:         "New York",
+		Country:       "United States",
+		CcNumber:      "5272940000751666",
+		CcMonth:       4,
+		CcYear:        2024,
+		CcCVV:         584,
+		GiftMessage:   "Happy birthday!",
+		CustomerNote:  "Please leave it at the door.",
+	}
+	if err := p.Validate(); err != nil {
+		t.Errorf("want validation on %v, got %v", p, err)
+	}
+	p.GiftMessage = strings.Repeat("x", 501)
+	if err := p.Validate(); err == nil {
+		t.Errorf("want validation failure on %v with a gift message too long", p)
+	}
+	p.GiftMessage, p.CustomerNote = "", strings.Repeat("x", 1001)
+	if err := p.Validate(); err == nil {
+		t.Errorf("want validation failure on %v with a note too long", p)
+	}
+}
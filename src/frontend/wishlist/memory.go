@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wishlist
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps wishlists in a map of the time each product was added,
+// by user ID and product ID.
+type memoryStore struct {
+	mu    sync.Mutex
+	lists map[string]map[string]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{lists: make(map[string]map[string]time.Time)}
+}
+
+func (s *memoryStore) add(_ context.Context, userID, productID string, added time.Time, max int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.lists[userID]
+	if _, ok := list[productID]; ok {
+		return nil
+	}
+	if len(list) >= max {
+		return ErrFull
+	}
+	if list == nil {
+		list = make(map[string]time.Time)
+		s.lists[userID] = list
+	}
+	list[productID] = added
+	return nil
+}
+
+func (s *memoryStore) remove(_ context.Context, userID, productID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lists[userID], productID)
+	if len(s.lists[userID]) == 0 {
+		delete(s.lists, userID)
+	}
+	return nil
+}
+
+func (s *memoryStore) items(_ context.Context, userID string) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedItems(s.lists[userID]), nil
+}
+
+func (s *memoryStore) merge(_ context.Context, fromUserID, toUserID string, max int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from := s.lists[fromUserID]
+	if len(from) == 0 {
+		return nil
+	}
+	to := s.lists[toUserID]
+	if to == nil {
+		to = make(map[string]time.Time)
+		s.lists[toUserID] = to
+	}
+	for id, added := range from {
+		if t, ok := to[id]; !ok || added.Before(t) {
+			to[id] = added
+		}
+	}
+	delete(s.lists, fromUserID)
+	items := sortedItems(to)
+	for _, item := range items[min(max, len(items)):] {
+		delete(to, item.ProductID)
+	}
+	return nil
+}
+
+func (s *memoryStore) String() string {
+	return "in memory"
+}
+
+// sortedItems returns the items of list, most recently added first and, like
+// Redis does, in reverse order of product ID when added at the same time.
+func sortedItems(list map[string]time.Time) []Item {
+	items := make([]Item, 0, len(list))
+	for id, added := range list {
+		items = append(items, Item{ProductID: id, Added: added})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].Added.Equal(items[j].Added) {
+			return items[i].Added.After(items[j].Added)
+		}
+		return items[i].ProductID > items[j].ProductID
+	})
+	return items
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wishlist
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Wishlists are kept as sorted sets under listPrefix and the user ID, of
+// product IDs scored by the Unix time in milliseconds they were added.
+const listPrefix = "wishlist:"
+
+// addScript adds ARGV[2] to the set KEYS[1] with the score ARGV[1] unless it
+// is already there, returning 0 if the set has ARGV[3] members already.
+var addScript = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[2]) then
+	return 1
+end
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// redisStore keeps wishlists in Redis.
+type redisStore struct {
+	addr   string
+	client *redis.Client
+}
+
+// newRedisStore returns a store in the Redis server at addr, a host:port.
+func newRedisStore(addr string) *redisStore {
+	return &redisStore{addr: addr, client: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+func (s *redisStore) add(ctx context.Context, userID, productID string, added time.Time, max int) error {
+	ok, err := addScript.Run(ctx, s.client, []string{listPrefix + userID}, added.UnixMilli(), productID, max).Int()
+	if err != nil {
+		return fmt.Errorf("failed to add to wishlist: %w", err)
+	}
+	if ok == 0 {
+		return ErrFull
+	}
+	return nil
+}
+
+func (s *redisStore) remove(ctx context.Context, userID, productID string) error {
+	if err := s.client.ZRem(ctx, listPrefix+userID, productID).Err(); err != nil {
+		return fmt.Errorf("failed to remove from wishlist: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) items(ctx context.Context, userID string) ([]Item, error) {
+	zs, err := s.client.ZRevRangeWithScores(ctx, listPrefix+userID, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wishlist: %w", err)
+	}
+	items := make([]Item, len(zs))
+	for i, z := range zs {
+		id, _ := z.Member.(string)
+		items[i] = Item{ProductID: id, Added: time.UnixMilli(int64(z.Score)).UTC()}
+	}
+	return items, nil
+}
+
+func (s *redisStore) merge(ctx context.Context, fromUserID, toUserID string, max int) error {
+	from, to := listPrefix+fromUserID, listPrefix+toUserID
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZUnionStore(ctx, to, &redis.ZStore{Keys: []string{to, from}, Aggregate: "MIN"})
+		p.Del(ctx, from)
+		p.ZRemRangeByRank(ctx, to, 0, int64(-max-1))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to merge wishlists: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) String() string {
+	return "in Redis at " + s.addr
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wishlist keeps the products each user wants to buy later.
+//
+// Wishlists are kept by user ID, which is the account ID of signed-in users
+// and the session ID of anonymous ones, so that Merge can hand an anonymous
+// session's wishlist to the account it signs in to. They are kept in Redis at
+// WISHLIST_REDIS_ADDR when it is set, so that they survive restarts and are
+// shared by every frontend replica, and in memory otherwise.
+package wishlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultMaxItems is how many products a wishlist holds unless the List's
+// MaxItems says otherwise.
+const DefaultMaxItems = 100
+
+// ErrFull is returned when adding a product to a wishlist that has as many as
+// it may hold.
+var ErrFull = errors.New("the wishlist is full")
+
+// Item is a product on a wishlist.
+type Item struct {
+	ProductID string
+	Added     time.Time
+}
+
+// store keeps wishlists by user ID.
+type store interface {
+	// add adds productID to userID's wishlist unless it is already there,
+	// failing with ErrFull if the wishlist has max items.
+	add(ctx context.Context, userID, productID string, added time.Time, max int) error
+	remove(ctx context.Context, userID, productID string) error
+	// items returns userID's wishlist, most recently added first.
+	items(ctx context.Context, userID string) ([]Item, error)
+	// merge moves fromUserID's wishlist into toUserID's, keeping the
+	// earliest time each product was added and dropping the oldest items
+	// past max.
+	merge(ctx context.Context, fromUserID, toUserID string, max int) error
+	String() string
+}
+
+// List keeps the users' wishlists. Its methods are safe for concurrent use.
+type List struct {
+	store store
+
+	// MaxItems is how many products a wishlist holds.
+	MaxItems int
+
+	now func() time.Time
+}
+
+// FromEnv returns a List keeping wishlists in Redis at WISHLIST_REDIS_ADDR, a
+// host:port, or in memory when it is unset.
+func FromEnv() *List {
+	if addr := os.Getenv("WISHLIST_REDIS_ADDR"); addr != "" {
+		return newList(newRedisStore(addr))
+	}
+	return NewMemory()
+}
+
+// NewMemory returns a List keeping wishlists in memory, which are lost when
+// the process exits.
+func NewMemory() *List {
+	return newList(newMemoryStore())
+}
+
+func newList(s store) *List {
+	return &List{store: s, MaxItems: DefaultMaxItems, now: time.Now}
+}
+
+// String describes where wishlists are kept, for the startup logs.
+func (l *List) String() string {
+	return l.store.String()
+}
+
+// Add adds productID to userID's wishlist. Adding a product that is already
+// on it succeeds and keeps the time it was first added.
+func (l *List) Add(ctx context.Context, userID, productID string) error {
+	if userID == "" || productID == "" {
+		return fmt.Errorf("user ID and product ID are required")
+	}
+	return l.store.add(ctx, userID, productID, l.now().UTC(), l.MaxItems)
+}
+
+// Remove removes productID from userID's wishlist. Removing a product that
+// is not on it succeeds.
+func (l *List) Remove(ctx context.Context, userID, productID string) error {
+	return l.store.remove(ctx, userID, productID)
+}
+
+// Items returns userID's wishlist, most recently added first.
+func (l *List) Items(ctx context.Context, userID string) ([]Item, error) {
+	return l.store.items(ctx, userID)
+}
+
+// Merge moves the products on fromUserID's wishlist onto toUserID's, leaving
+// fromUserID's empty. If that makes toUserID's wishlist hold more than
+// MaxItems, the oldest are dropped.
+func (l *List) Merge(ctx context.Context, fromUserID, toUserID string) error {
+	if fromUserID == toUserID {
+		return nil
+	}
+	return l.store.merge(ctx, fromUserID, toUserID, l.MaxItems)
+}
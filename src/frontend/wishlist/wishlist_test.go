@@ -0,0 +1,147 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wishlist
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testStores returns the stores to test: memory, and Redis at
+// TEST_REDIS_ADDR when it is set.
+func testStores(t *testing.T) map[string]store {
+	stores := map[string]store{"memory": newMemoryStore()}
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		s := newRedisStore(addr)
+		t.Cleanup(func() { s.client.Close() })
+		stores["redis"] = s
+	}
+	return stores
+}
+
+// productIDs returns the product IDs of items, in order.
+func productIDs(items []Item) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+	}
+	return ids
+}
+
+func TestAddAndRemove(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			l := newList(s)
+			l.MaxItems = 3
+			l.now = func() time.Time { return now }
+			user := uuid.NewString()
+
+			for _, id := range []string{"OLJCESPC7Z", "66VCHSJNUP", "OLJCESPC7Z"} {
+				now = now.Add(time.Second)
+				if err := l.Add(ctx, user, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			items, err := l.Items(ctx, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := productIDs(items), []string{"66VCHSJNUP", "OLJCESPC7Z"}; !slices.Equal(got, want) {
+				t.Errorf("Items = %v, want %v", got, want)
+			}
+			if !items[1].Added.Equal(now.Add(-2 * time.Second)) {
+				t.Errorf("OLJCESPC7Z added at %v, want the first time it was added, %v", items[1].Added, now.Add(-2*time.Second))
+			}
+
+			if err := l.Add(ctx, user, "1YMWWN1N4O"); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Add(ctx, user, "L9ECAV7KIM"); !errors.Is(err, ErrFull) {
+				t.Errorf("Add to a full wishlist: got %v, want ErrFull", err)
+			}
+			if err := l.Add(ctx, user, "66VCHSJNUP"); err != nil {
+				t.Errorf("Add of a product already on a full wishlist: %v", err)
+			}
+
+			for _, id := range []string{"66VCHSJNUP", "66VCHSJNUP", "1YMWWN1N4O", "OLJCESPC7Z"} {
+				if err := l.Remove(ctx, user, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if items, err := l.Items(ctx, user); err != nil || len(items) != 0 {
+				t.Errorf("Items after removing them all = %v, %v, want none", items, err)
+			}
+			if err := l.Add(ctx, "", "OLJCESPC7Z"); err == nil {
+				t.Error("Add without a user ID succeeded")
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			l := newList(s)
+			l.MaxItems = 3
+			l.now = func() time.Time { return now }
+			session, account := uuid.NewString(), uuid.NewString()
+
+			add := func(user, id string) {
+				t.Helper()
+				now = now.Add(time.Second)
+				if err := l.Add(ctx, user, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			add(account, "OLJCESPC7Z")
+			add(session, "66VCHSJNUP")
+			add(account, "1YMWWN1N4O")
+			add(session, "L9ECAV7KIM")
+			add(session, "OLJCESPC7Z")
+
+			if err := l.Merge(ctx, session, account); err != nil {
+				t.Fatal(err)
+			}
+			// OLJCESPC7Z keeps the time the account added it, which makes
+			// it the oldest and drops it past MaxItems
+			items, err := l.Items(ctx, account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := productIDs(items), []string{"L9ECAV7KIM", "1YMWWN1N4O", "66VCHSJNUP"}; !slices.Equal(got, want) {
+				t.Errorf("account wishlist = %v, want %v", got, want)
+			}
+			if items, err := l.Items(ctx, session); err != nil || len(items) != 0 {
+				t.Errorf("session wishlist = %v, %v, want it emptied", items, err)
+			}
+			if err := l.Merge(ctx, session, account); err != nil {
+				t.Errorf("Merge of an empty wishlist: %v", err)
+			}
+			if items, _ := l.Items(ctx, account); len(items) != 3 {
+				t.Errorf("account wishlist after merging an empty one = %v, want it unchanged", items)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@
 # limitations under the License.
 
 import random
+import re
 from locust import FastHttpUser, TaskSet, between
 from faker import Faker
 import datetime
@@ -31,13 +32,20 @@ products = [
     'LS4PSXUNUM',
     'OLJCESPC7Z']
 
+def csrf_token(response):
+    # the frontend rejects forms posted without the session's CSRF token,
+    # which its pages embed
+    match = re.search(r'name="csrf_token" value="([^"]+)"', response.text)
+    return match.group(1) if match else ''
+
 def index(l):
-    l.client.get("/")
+    l.csrf_token = csrf_token(l.client.get("/"))
 
 def setCurrency(l):
     currencies = ['EUR', 'USD', 'JPY', 'CAD', 'GBP', 'TRY']
     l.client.post("/setCurrency",
-        {'currency_code': random.choice(currencies)})
+        {'currency_code': random.choice(currencies),
+        'csrf_token': l.csrf_token})
 
 def browseProduct(l):
     l.client.get("/product/" + random.choice(products))
@@ -50,10 +58,11 @@ def addToCart(l):
     l.client.get("/product/" + product)
     l.client.post("/cart", {
         'product_id': product,
-        'quantity': random.randint(1,10)})
+        'quantity': random.randint(1,10),
+        'csrf_token': l.csrf_token})
     
 def empty_cart(l):
-    l.client.post('/cart/empty')
+    l.client.post('/cart/empty', {'csrf_token': l.csrf_token})
 
 def checkout(l):
     addToCart(l)
@@ -69,6 +78,7 @@ def checkout(l):
         'credit_card_expiration_month': random.randint(1, 12),
         'credit_card_expiration_year': random.randint(current_year, current_year + 70),
         'credit_card_cvv': f"{random.randint(100, 999)}",
+        'csrf_token': l.csrf_token,
     })
     
 def logout(l):
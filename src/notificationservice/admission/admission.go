@@ -0,0 +1,368 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admission sheds low-priority requests when a service is overloaded,
+// so that checkouts keep going through while browsing slows down.
+//
+// Requests have a Priority, from highest to lowest Checkout, Cart, Browse and
+// Ads. The frontend classifies the requests it serves, and the priority
+// travels with every downstream gRPC call in the x-request-priority metadata;
+// calls without one get the default priority of the service that serves
+// them. A Controller samples the process' CPU usage and counts the requests
+// in flight: every interval the CPU usage or the peak number of requests in
+// flight exceeds its threshold, one more priority is shed, lowest first, and
+// one fewer is once both are back under 80% of their threshold. Checkout
+// requests are never shed. Shed requests are counted per priority in the
+// admission.shed metric.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package admission
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/rpcerrors"
+)
+
+// Priority orders requests for shedding.
+type Priority int
+
+// Priorities, lowest first.
+const (
+	Ads Priority = iota
+	Browse
+	Cart
+	Checkout
+)
+
+const (
+	// MetadataKey is the gRPC metadata key priorities travel in.
+	MetadataKey = "x-request-priority"
+
+	// ReasonShed is the error reason of shed calls.
+	ReasonShed = "LOAD_SHED"
+
+	defaultInterval = time.Second
+	// recovery is the fraction of its thresholds a service must be back
+	// under to shed one priority fewer.
+	recovery = 0.8
+)
+
+var priorityNames = [...]string{Ads: "ads", Browse: "browse", Cart: "cart", Checkout: "checkout"}
+
+func (p Priority) String() string {
+	if p < Ads || p > Checkout {
+		return strconv.Itoa(int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority returns the priority with the given name.
+func ParsePriority(s string) (Priority, bool) {
+	for p, name := range priorityNames {
+		if s == name {
+			return Priority(p), true
+		}
+	}
+	return 0, false
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying p, which is forwarded to the
+// servers of outgoing calls.
+func NewContext(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the priority in ctx, if any.
+func FromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Priority)
+	return p, ok
+}
+
+// Config sets when a Controller sheds requests.
+type Config struct {
+	// CPUThreshold is the CPU usage, as a fraction of GOMAXPROCS, above
+	// which requests are shed, or 0 to ignore CPU usage.
+	CPUThreshold float64
+	// MaxInFlight is the number of requests in flight above which requests
+	// are shed, or 0 to ignore it.
+	MaxInFlight int
+	// Interval is how often the load is sampled.
+	Interval time.Duration
+}
+
+// Controller admits or sheds requests. A nil Controller admits everything.
+type Controller struct {
+	cfg    Config
+	sample func() time.Duration // process CPU time, for tests
+
+	inFlight atomic.Int64
+	peak     atomic.Int64
+	// shed is the number of priorities shed, lowest first.
+	shed atomic.Int32
+
+	// the last sample, only used by Run
+	lastCPU time.Duration
+	lastAt  time.Time
+
+	shedCount metric.Int64Counter
+}
+
+// FromEnv returns a Controller configured with ADMISSION_CPU_THRESHOLD,
+// ADMISSION_MAX_IN_FLIGHT and ADMISSION_INTERVAL (default 1s), or nil if
+// neither threshold is set.
+func FromEnv() (*Controller, error) {
+	var cfg Config
+	if v := os.Getenv("ADMISSION_CPU_THRESHOLD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			return nil, fmt.Errorf("invalid ADMISSION_CPU_THRESHOLD %q", v)
+		}
+		cfg.CPUThreshold = f
+	}
+	if v := os.Getenv("ADMISSION_MAX_IN_FLIGHT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid ADMISSION_MAX_IN_FLIGHT %q", v)
+		}
+		cfg.MaxInFlight = n
+	}
+	if v := os.Getenv("ADMISSION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid ADMISSION_INTERVAL %q", v)
+		}
+		cfg.Interval = d
+	}
+	if cfg.CPUThreshold == 0 && cfg.MaxInFlight == 0 {
+		return nil, nil
+	}
+	return New(cfg), nil
+}
+
+// New returns a Controller for cfg. Run must be called for it to shed
+// anything.
+func New(cfg Config) *Controller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	c := &Controller{cfg: cfg, sample: processCPU}
+	c.shedCount, _ = otel.Meter("admission").Int64Counter(
+		"admission.shed",
+		metric.WithDescription("Requests shed because the service was overloaded, by priority."),
+		metric.WithUnit("{request}"))
+	return c
+}
+
+// String describes the thresholds, for logging.
+func (c *Controller) String() string {
+	if c == nil {
+		return "disabled"
+	}
+	return fmt.Sprintf("cpu threshold %g, max in flight %d, interval %s", c.cfg.CPUThreshold, c.cfg.MaxInFlight, c.cfg.Interval)
+}
+
+// Run samples the load every interval until ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.lastCPU, c.lastAt = c.sample(), time.Now()
+	t := time.NewTicker(c.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			c.update(c.cpuUsage(now), c.peak.Swap(c.inFlight.Load()))
+		}
+	}
+}
+
+// cpuUsage returns the CPU usage since the last sample, as a fraction of
+// GOMAXPROCS.
+func (c *Controller) cpuUsage(now time.Time) float64 {
+	cpu := c.sample()
+	wall := now.Sub(c.lastAt)
+	used := cpu - c.lastCPU
+	c.lastCPU, c.lastAt = cpu, now
+	if wall <= 0 {
+		return 0
+	}
+	return float64(used) / float64(wall) / float64(runtime.GOMAXPROCS(0))
+}
+
+// update sheds one more priority if cpu or peak exceeds its threshold, and
+// one fewer if both are well under.
+func (c *Controller) update(cpu float64, peak int64) {
+	over := (c.cfg.CPUThreshold > 0 && cpu > c.cfg.CPUThreshold) ||
+		(c.cfg.MaxInFlight > 0 && peak > int64(c.cfg.MaxInFlight))
+	under := (c.cfg.CPUThreshold == 0 || cpu < c.cfg.CPUThreshold*recovery) &&
+		(c.cfg.MaxInFlight == 0 || float64(peak) < float64(c.cfg.MaxInFlight)*recovery)
+	shed := c.shed.Load()
+	switch {
+	case over && shed < int32(Checkout):
+		c.shed.Store(shed + 1)
+	case under && shed > 0:
+		c.shed.Store(shed - 1)
+	}
+}
+
+// Sheds reports whether requests with priority p are being shed, counting
+// them as shed if they are. It is used for optional work done within an
+// admitted request, such as fetching ads.
+func (c *Controller) Sheds(ctx context.Context, p Priority) bool {
+	if c == nil || p >= Checkout || p >= Priority(c.shed.Load()) {
+		return false
+	}
+	c.shedCount.Add(ctx, 1, metric.WithAttributes(attribute.String("priority", p.String())))
+	return true
+}
+
+// Admit reports whether a request with priority p may proceed. If it does,
+// done must be called once it completes.
+func (c *Controller) Admit(ctx context.Context, p Priority) (done func(), ok bool) {
+	if c == nil {
+		return func() {}, true
+	}
+	if c.Sheds(ctx, p) {
+		return nil, false
+	}
+	n := c.inFlight.Add(1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	return func() { c.inFlight.Add(-1) }, true
+}
+
+// exempt reports whether calls to method are never shed: health checks and
+// diagnostics, which must keep working under load.
+func exempt(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.") ||
+		strings.HasPrefix(method, "/grpc.reflection.") ||
+		strings.HasPrefix(method, "/grpc.channelz.")
+}
+
+// fromIncoming returns the priority sent by the caller in the gRPC metadata of
+// ctx, or fallback.
+func fromIncoming(ctx context.Context, fallback Priority) Priority {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(MetadataKey); len(v) > 0 {
+		if p, ok := ParsePriority(v[0]); ok {
+			return p
+		}
+	}
+	return fallback
+}
+
+// Error returns the error shed requests with priority p fail with.
+func Error(p Priority) error {
+	return rpcerrors.Errorf(codes.Unavailable, ReasonShed, "overloaded, shedding %s requests", p)
+}
+
+// UnaryServerInterceptor sheds calls through c, with the caller's priority or
+// fallback, and puts the priority in the context of admitted calls.
+func (c *Controller) UnaryServerInterceptor(fallback Priority) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if exempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		p := fromIncoming(ctx, fallback)
+		done, ok := c.Admit(ctx, p)
+		if !ok {
+			return nil, Error(p)
+		}
+		defer done()
+		return handler(NewContext(ctx, p), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func (c *Controller) StreamServerInterceptor(fallback Priority) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if exempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		p := fromIncoming(ss.Context(), fallback)
+		done, ok := c.Admit(ss.Context(), p)
+		if !ok {
+			return Error(p)
+		}
+		defer done()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), p)})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// outgoing adds the priority in ctx, if any, to the outgoing metadata.
+func outgoing(ctx context.Context) context.Context {
+	if p, ok := FromContext(ctx); ok {
+		return metadata.AppendToOutgoingContext(ctx, MetadataKey, p.String())
+	}
+	return ctx
+}
+
+// UnaryClientInterceptor forwards the priority in the context of each call to
+// the server.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of
+// UnaryClientInterceptor.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoing(ctx), desc, cc, method, opts...)
+	}
+}
+
+// processCPU returns the CPU time used by the process so far.
+func processCPU() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
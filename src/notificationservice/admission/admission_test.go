@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func admitted(c *Controller) []Priority {
+	var ps []Priority
+	for p := Ads; p <= Checkout; p++ {
+		if done, ok := c.Admit(context.Background(), p); ok {
+			done()
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+func TestShedsLowestPrioritiesFirst(t *testing.T) {
+	c := New(Config{CPUThreshold: 0.5, MaxInFlight: 10})
+	steps := []struct {
+		cpu  float64
+		peak int64
+		want int // number of priorities admitted
+	}{
+		{0.2, 2, 4},
+		{0.6, 2, 3},  // over CPU: ads are shed
+		{0.2, 11, 2}, // over in flight: browse too
+		{0.6, 2, 1},
+		{0.9, 50, 1}, // checkout is never shed
+		{0.45, 2, 1}, // not enough under the thresholds to recover
+		{0.3, 2, 2},
+		{0.3, 2, 3},
+		{0.3, 2, 4},
+		{0.3, 2, 4},
+	}
+	for i, s := range steps {
+		c.update(s.cpu, s.peak)
+		got := admitted(c)
+		if len(got) != s.want || got[0] != Checkout-Priority(s.want-1) {
+			t.Errorf("step %d (cpu %g, peak %d): admitted %v, want the %d highest priorities", i, s.cpu, s.peak, got, s.want)
+		}
+	}
+}
+
+func TestNilControllerAdmitsEverything(t *testing.T) {
+	var c *Controller
+	if got := admitted(c); len(got) != 4 {
+		t.Errorf("nil Controller admitted %v, want every priority", got)
+	}
+	if c.Sheds(context.Background(), Ads) {
+		t.Error("nil Controller sheds ads")
+	}
+}
+
+func TestAdmitTracksPeakInFlight(t *testing.T) {
+	c := New(Config{MaxInFlight: 10})
+	var dones []func()
+	for i := 0; i < 3; i++ {
+		done, _ := c.Admit(context.Background(), Browse)
+		dones = append(dones, done)
+	}
+	for _, done := range dones {
+		done()
+	}
+	if got := c.peak.Load(); got != 3 {
+		t.Errorf("peak = %d, want 3", got)
+	}
+	if got := c.inFlight.Load(); got != 0 {
+		t.Errorf("in flight = %d after every request completed, want 0", got)
+	}
+}
+
+func TestCPUUsage(t *testing.T) {
+	c := New(Config{CPUThreshold: 0.5})
+	cpu := time.Duration(0)
+	c.sample = func() time.Duration { return cpu }
+	start := time.Now()
+	c.lastCPU, c.lastAt = 0, start
+	cpu = time.Second
+	want := 1 / float64(runtime.GOMAXPROCS(0))
+	if got := c.cpuUsage(start.Add(time.Second)); got != want {
+		t.Errorf("cpuUsage() = %g, want %g", got, want)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	c := New(Config{MaxInFlight: 10})
+	c.shed.Store(int32(Cart)) // shedding ads and browse
+	interceptor := c.UnaryServerInterceptor(Checkout)
+	var got Priority
+	handler := func(ctx context.Context, req any) (any, error) {
+		got, _ = FromContext(ctx)
+		return nil, nil
+	}
+	call := func(method string, p string) error {
+		ctx := context.Background()
+		if p != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, p))
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	if err := call("/hipstershop.ProductCatalogService/GetProduct", "browse"); status.Code(err) != codes.Unavailable {
+		t.Errorf("browse call = %v, want Unavailable", err)
+	}
+	if err := call("/hipstershop.ProductCatalogService/GetProduct", "cart"); err != nil || got != Cart {
+		t.Errorf("cart call = %v with priority %v, want nil with cart", err, got)
+	}
+	if err := call("/hipstershop.CheckoutService/PlaceOrder", ""); err != nil || got != Checkout {
+		t.Errorf("call without priority = %v with priority %v, want nil with the fallback", err, got)
+	}
+	if err := call("/grpc.health.v1.Health/Check", "ads"); err != nil {
+		t.Errorf("health check = %v, want nil", err)
+	}
+}
+
+func TestUnaryClientInterceptorForwardsPriority(t *testing.T) {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	ctx := NewContext(context.Background(), Cart)
+	if err := UnaryClientInterceptor()(ctx, "/test.Service/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if got := md.Get(MetadataKey); len(got) != 1 || got[0] != "cart" {
+		t.Errorf("outgoing %s = %v, want [cart]", MetadataKey, got)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Operations maps the full names of the gRPC methods to audit to a function
+// naming the target of a request, or nil when a method has none.
+type Operations map[string]func(req any) string
+
+// UnaryServerInterceptor records every call to the methods in ops, with its
+// status code as the outcome.
+func (l *Log) UnaryServerInterceptor(ops Operations) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		target, ok := ops[info.FullMethod]
+		if !ok || l == nil {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		e := Entry{Operation: info.FullMethod, Outcome: status.Code(err).String()}
+		if target != nil {
+			e.Target = target(req)
+		}
+		if rerr := l.Record(ctx, e); rerr != nil {
+			l.log.WithContext(ctx).Errorf("failed to audit %s: %v", info.FullMethod, rerr)
+		}
+		return resp, err
+	}
+}
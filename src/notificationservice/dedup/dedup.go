@@ -0,0 +1,159 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup coalesces identical gRPC calls that are in flight at the same
+// time onto a single execution, so that a burst of client retries does not
+// multiply the work done by a service or its dependencies.
+//
+// Calls are identical when they are to the same method with the same request
+// and the same idempotency key, taken from the idempotency-key metadata or the
+// idempotency_key field of the request. Read-only methods, whose response
+// does not depend on the caller, can be coalesced by request alone. Calls are
+// only coalesced while one of them is running: nothing is cached once it
+// returns.
+//
+// This package is duplicated in every Go gRPC service since they do not share
+// packages.
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataKey is the gRPC metadata key callers send idempotency keys in.
+const MetadataKey = "idempotency-key"
+
+// call is an execution that identical calls wait for.
+type call struct {
+	done chan struct{}
+	resp any
+	err  error
+}
+
+// group tracks the calls in flight.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+
+	coalesced metric.Int64Counter
+}
+
+// UnaryServerInterceptor coalesces identical unary calls. Calls with an
+// idempotency key are coalesced with those with the same key and request;
+// calls to the readOnly methods, given as full method names, are coalesced by
+// request even without a key.
+func UnaryServerInterceptor(readOnly ...string) grpc.UnaryServerInterceptor {
+	g := &group{calls: make(map[string]*call)}
+	g.coalesced, _ = otel.Meter("dedup").Int64Counter(
+		"rpc.server.coalesced",
+		metric.WithDescription("Calls served by the execution of an identical call in flight."),
+		metric.WithUnit("{call}"))
+	pure := make(map[string]bool, len(readOnly))
+	for _, m := range readOnly {
+		pure[m] = true
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := idempotencyKey(ctx, req)
+		if key == "" && !pure[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		fingerprint, ok := fingerprint(req)
+		if !ok {
+			return handler(ctx, req)
+		}
+		return g.do(ctx, info.FullMethod+"\x00"+key+"\x00"+fingerprint, info.FullMethod, func(ctx context.Context) (any, error) {
+			return handler(ctx, req)
+		})
+	}
+}
+
+// do runs fn for key, unless a call for key is in flight, in which case it
+// waits for it and returns its result. If that call was canceled, or ran out
+// of time, while ctx was not, fn is run again for this call alone.
+func (g *group) do(ctx context.Context, key, method string, fn func(context.Context) (any, error)) (any, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		if g.coalesced != nil {
+			g.coalesced.Add(ctx, 1, metric.WithAttributes(attribute.String("rpc.method", method)))
+		}
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if code := status.Code(c.err); (code == codes.Canceled || code == codes.DeadlineExceeded) && ctx.Err() == nil {
+			return fn(ctx)
+		}
+		return clone(c.resp), c.err
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.resp, c.err = fn(ctx)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+	return c.resp, c.err
+}
+
+// idempotencyKey returns the idempotency key of a call, or "" if it has none.
+func idempotencyKey(ctx context.Context, req any) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(MetadataKey); len(v) > 0 && v[0] != "" {
+		return v[0]
+	}
+	if r, ok := req.(interface{ GetIdempotencyKey() string }); ok {
+		return r.GetIdempotencyKey()
+	}
+	return ""
+}
+
+// fingerprint returns a hash of a request message.
+func fingerprint(req any) (string, bool) {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return "", false
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// clone copies a response shared between calls, so that no handler or
+// interceptor changes another call's.
+func clone(resp any) any {
+	if m, ok := resp.(proto.Message); ok && m != nil {
+		return proto.Clone(m)
+	}
+	return resp
+}
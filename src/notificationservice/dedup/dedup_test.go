@@ -0,0 +1,167 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	readMethod  = "/test.Service/Read"
+	writeMethod = "/test.Service/Write"
+)
+
+// slowHandler counts its executions and returns once release is closed.
+type slowHandler struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func newSlowHandler() *slowHandler {
+	return &slowHandler{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (h *slowHandler) handle(ctx context.Context, req any) (any, error) {
+	h.calls.Add(1)
+	h.started <- struct{}{}
+	select {
+	case <-h.release:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return wrapperspb.String("response to " + req.(*wrapperspb.StringValue).GetValue()), nil
+}
+
+// callConcurrently makes n calls with ctxs[i] and reqs[i], releasing the
+// handler once the first one started, and returns their responses.
+func callConcurrently(t *testing.T, interceptor grpc.UnaryServerInterceptor, h *slowHandler, method string, ctxs []context.Context, reqs []proto.Message) []any {
+	t.Helper()
+	resps := make([]any, len(reqs))
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := interceptor(ctxs[i], reqs[i], &grpc.UnaryServerInfo{FullMethod: method}, h.handle)
+			if err != nil {
+				t.Error(err)
+			}
+			resps[i] = resp
+		}()
+	}
+	<-h.started
+	time.Sleep(50 * time.Millisecond) // let the other calls join
+	close(h.release)
+	wg.Wait()
+	return resps
+}
+
+func repeat[T any](v T, n int) []T {
+	s := make([]T, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func TestCoalescesReadOnlyCalls(t *testing.T) {
+	h := newSlowHandler()
+	resps := callConcurrently(t, UnaryServerInterceptor(readMethod), h, readMethod,
+		repeat(context.Background(), 5), repeat[proto.Message](wrapperspb.String("a"), 5))
+	if n := h.calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+	for _, resp := range resps {
+		if got := resp.(*wrapperspb.StringValue).GetValue(); got != "response to a" {
+			t.Errorf("response = %q, want %q", got, "response to a")
+		}
+	}
+	if resps[0] == resps[1] {
+		t.Error("calls share the same response message")
+	}
+}
+
+func TestKeepsDifferentCallsApart(t *testing.T) {
+	h := newSlowHandler()
+	callConcurrently(t, UnaryServerInterceptor(readMethod), h, readMethod,
+		repeat(context.Background(), 2), []proto.Message{wrapperspb.String("a"), wrapperspb.String("b")})
+	if n := h.calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times for different requests, want 2", n)
+	}
+}
+
+func TestCoalescesCallsWithTheSameIdempotencyKey(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "key-1"))
+	other := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "key-2"))
+	h := newSlowHandler()
+	callConcurrently(t, UnaryServerInterceptor(), h, writeMethod,
+		[]context.Context{ctx, ctx, ctx, other}, repeat[proto.Message](wrapperspb.String("a"), 4))
+	if n := h.calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times for two idempotency keys, want 2", n)
+	}
+}
+
+func TestDoesNotCoalesceCallsWithoutKey(t *testing.T) {
+	h := newSlowHandler()
+	callConcurrently(t, UnaryServerInterceptor(readMethod), h, writeMethod,
+		repeat(context.Background(), 3), repeat[proto.Message](wrapperspb.String("a"), 3))
+	if n := h.calls.Load(); n != 3 {
+		t.Errorf("handler ran %d times, want 3", n)
+	}
+}
+
+func TestRunsAgainWhenTheFirstCallIsCanceled(t *testing.T) {
+	interceptor := UnaryServerInterceptor(readMethod)
+	h := newSlowHandler()
+	info := &grpc.UnaryServerInfo{FullMethod: readMethod}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := interceptor(first, wrapperspb.String("a"), info, h.handle)
+		firstErr <- err
+	}()
+	<-h.started
+	second := make(chan error)
+	go func() {
+		_, err := interceptor(context.Background(), wrapperspb.String("a"), info, h.handle)
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-firstErr; status.Code(err) != codes.Canceled {
+		t.Errorf("canceled call = %v, want Canceled", err)
+	}
+	<-h.started
+	close(h.release)
+	if err := <-second; err != nil {
+		t.Errorf("call waiting for a canceled one = %v, want nil", err)
+	}
+	if n := h.calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck serves grpc.health.v1.Health with a status for each of
+// a service's dependencies, such as its database and the services it calls.
+//
+// Dependencies are checked in the background every HEALTH_CHECK_INTERVAL
+// (10s by default). Each one is reported under its own name prefixed with
+// "dependency/", so that "dependency/db" can be checked or watched on its
+// own. The overall status, reported for the empty service name and for the
+// gRPC services the server registers, is SERVING unless a critical
+// dependency is failing. Dependencies added with AddCritical, without which
+// the service fails every request, such as its database, are critical
+// unless HEALTH_CRITICAL_DEPENDENCIES is set, in which case it lists the
+// critical dependencies instead. Other dependencies are not critical by
+// default: marking a service unready because a service it calls is down only
+// spreads the outage.
+//
+// LivenessService is SERVING until the server shuts down whatever the
+// dependencies, for liveness probes: restarting a service whose database is
+// down does not bring the database back.
+//
+// This package is duplicated in every Go service that serves gRPC since they
+// do not share packages.
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/logging"
+)
+
+const (
+	// DependencyPrefix prefixes the health service name of each dependency.
+	DependencyPrefix = "dependency/"
+	// LivenessService is the health service name reporting only whether
+	// the server is up.
+	LivenessService = "liveness"
+
+	defaultInterval = 10 * time.Second
+	checkTimeout    = 3 * time.Second
+)
+
+// CheckFunc reports whether a dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+// Checker tracks the health of a service and its dependencies.
+type Checker struct {
+	server   *health.Server
+	log      *logging.Logger
+	services []string
+	interval time.Duration
+	// critical lists the critical dependencies if HEALTH_CRITICAL_DEPENDENCIES
+	// is set, and is nil otherwise.
+	critical map[string]bool
+
+	mu       sync.Mutex
+	deps     []*dependency
+	shutdown bool
+	// held counts the holds keeping the overall status NOT_SERVING.
+	held int
+}
+
+type dependency struct {
+	name  string
+	check CheckFunc
+	// critical is whether the dependency was added with AddCritical.
+	critical bool
+	checked  bool
+	err      error
+}
+
+// New returns a Checker that reports the overall status under the empty
+// service name and under each of services, which should be the
+// fully-qualified names of the gRPC services the server registers.
+func New(log *logging.Logger, services ...string) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		log:      log,
+		services: append([]string{""}, services...),
+		interval: defaultInterval,
+	}
+	if v := os.Getenv("HEALTH_CHECK_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.interval = d
+		} else {
+			log.Warnf("invalid HEALTH_CHECK_INTERVAL %q, using %v", v, defaultInterval)
+		}
+	}
+	if v, ok := os.LookupEnv("HEALTH_CRITICAL_DEPENDENCIES"); ok {
+		c.critical = make(map[string]bool)
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				c.critical[name] = true
+			}
+		}
+	}
+	c.update()
+	return c
+}
+
+// Add registers a dependency checked by check. It is reported as UNKNOWN
+// until its first check.
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.add(&dependency{name: name, check: check})
+}
+
+// AddCritical registers a dependency like Add, but one that is critical
+// unless HEALTH_CRITICAL_DEPENDENCIES says otherwise.
+func (c *Checker) AddCritical(name string, check CheckFunc) {
+	c.add(&dependency{name: name, check: check, critical: true})
+}
+
+func (c *Checker) add(d *dependency) {
+	c.mu.Lock()
+	c.deps = append(c.deps, d)
+	c.mu.Unlock()
+	c.update()
+}
+
+// isCritical reports whether d is critical. c.mu must be held.
+func (c *Checker) isCritical(d *dependency) bool {
+	if c.critical != nil {
+		return c.critical[d.name]
+	}
+	return d.critical
+}
+
+// Register serves the health service on srv.
+func (c *Checker) Register(srv *grpc.Server) {
+	healthpb.RegisterHealthServer(srv, c.server)
+}
+
+// Run checks the dependencies now and then every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context) {
+	c.mu.Lock()
+	for name := range c.critical {
+		if c.find(name) == nil {
+			c.log.Warnf("HEALTH_CRITICAL_DEPENDENCIES names unknown dependency %q", name)
+		}
+	}
+	c.mu.Unlock()
+
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		c.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// CheckNow checks every dependency once, concurrently, and updates the
+// reported statuses.
+func (c *Checker) CheckNow(ctx context.Context) {
+	c.mu.Lock()
+	deps := append([]*dependency(nil), c.deps...)
+	c.mu.Unlock()
+
+	errs := make([]error, len(deps))
+	var wg sync.WaitGroup
+	for i, d := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			errs[i] = d.check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	for i, d := range deps {
+		switch err := errs[i]; {
+		case err != nil && (!d.checked || d.err == nil):
+			c.log.Warnf("dependency %s is unhealthy: %v", d.name, err)
+		case err == nil && d.checked && d.err != nil:
+			c.log.Infof("dependency %s has recovered", d.name)
+		}
+		d.checked, d.err = true, errs[i]
+	}
+	c.mu.Unlock()
+	c.update()
+}
+
+// Shutdown reports every service as NOT_SERVING from now on, so that load
+// balancers stop sending requests before the server stops.
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	c.shutdown = true
+	c.mu.Unlock()
+	c.server.Shutdown()
+}
+
+// Hold reports the overall status as NOT_SERVING until the returned function
+// is called, such as while the service warms up. Dependencies are still
+// checked and reported in the meantime.
+func (c *Checker) Hold() (release func()) {
+	c.mu.Lock()
+	c.held++
+	c.mu.Unlock()
+	c.update()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			c.held--
+			c.mu.Unlock()
+			c.update()
+		})
+	}
+}
+
+// find returns the dependency called name. c.mu must be held.
+func (c *Checker) find(name string) *dependency {
+	for _, d := range c.deps {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// update publishes the current statuses to the health server.
+func (c *Checker) update() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shutdown {
+		return
+	}
+	overall := healthpb.HealthCheckResponse_SERVING
+	if c.held > 0 {
+		overall = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, d := range c.deps {
+		st := healthpb.HealthCheckResponse_SERVING
+		switch {
+		case !d.checked:
+			st = healthpb.HealthCheckResponse_UNKNOWN
+		case d.err != nil:
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		c.server.SetServingStatus(DependencyPrefix+d.name, st)
+		if c.isCritical(d) && st != healthpb.HealthCheckResponse_SERVING {
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	for _, s := range c.services {
+		c.server.SetServingStatus(s, overall)
+	}
+	c.server.SetServingStatus(LivenessService, healthpb.HealthCheckResponse_SERVING)
+}
+
+// Conn returns a CheckFunc that asks the server at the other end of conn for
+// its overall health. A server without the health service counts as healthy
+// since it answered.
+func Conn(conn *grpc.ClientConn) CheckFunc {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%s reports %s", conn.Target(), resp.GetStatus())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/rpcerrors"
+)
+
+// reasonOutOfStock is the reason ReserveStock fails with when a product has
+// less stock available than an order asks for.
+const reasonOutOfStock = "OUT_OF_STOCK"
+
+// inventoryService reserves stock for the orders checkout places. Reserved
+// stock is not available to other orders, nor reported by GetAvailability,
+// until the reservation is released or expires.
+type inventoryService struct {
+	pb.UnimplementedInventoryServiceServer
+
+	notifications *notificationService
+	ttl           time.Duration
+}
+
+func (s *inventoryService) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.StockReservation, error) {
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+	for _, it := range req.GetItems() {
+		if it.GetProductId() == "" || it.GetQuantity() <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid item %v: want a product_id and a positive quantity", it)
+		}
+	}
+	r, err := s.notifications.store.reserve(req.GetOrderId(), req.GetItems(), time.Now(), s.ttl)
+	if out := (*outOfStockError)(nil); errors.As(err, &out) {
+		return nil, rpcerrors.Errorf(codes.FailedPrecondition, reasonOutOfStock, "%v", err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to reserve stock: %v", err)
+	}
+	log.WithContext(ctx).Infof("[ReserveStock] reserved %d items for order %s", len(r.GetItems()), r.GetOrderId())
+	return r, nil
+}
+
+func (s *inventoryService) ConfirmReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.Empty, error) {
+	if !s.notifications.store.confirm(req.GetOrderId()) {
+		return nil, status.Errorf(codes.NotFound, "no reservation for order %s; it may have expired", req.GetOrderId())
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *inventoryService) ReleaseReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.Empty, error) {
+	if r := s.notifications.store.release(req.GetOrderId()); r != nil {
+		log.WithContext(ctx).Infof("[ReleaseReservation] released the stock reserved for order %s", r.GetOrderId())
+		s.notifyReleased(ctx, r)
+	}
+	return &pb.Empty{}, nil
+}
+
+// notifyReleased notifies the subscribers of the products of a released
+// reservation that are now back in stock.
+func (s *inventoryService) notifyReleased(ctx context.Context, r *pb.StockReservation) {
+	for _, it := range r.GetItems() {
+		if q, ok := s.notifications.store.level(it.GetProductId()); ok && q > 0 {
+			s.notifications.notifyRestock(ctx, it.GetProductId())
+		}
+	}
+}
+
+// sweep periodically releases reservations that were never confirmed nor
+// released, such as those of a checkout that crashed.
+func (s *inventoryService) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired := s.notifications.store.expireReservations(now)
+			for _, r := range expired {
+				s.notifyReleased(ctx, r)
+			}
+			if len(expired) > 0 {
+				log.Infof("released %d expired stock reservations", len(expired))
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/rpcerrors"
+)
+
+func reserve(s *inventoryService, orderID string, quantity int32) error {
+	_, err := s.ReserveStock(context.Background(), &pb.ReserveStockRequest{
+		OrderId: orderID,
+		Items:   []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: quantity}},
+	})
+	return err
+}
+
+func available(t *testing.T, s *notificationService) int32 {
+	t.Helper()
+	a, err := s.GetAvailability(context.Background(), &pb.GetAvailabilityRequest{ProductId: "OLJCESPC7Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.GetQuantity()
+}
+
+func TestReservationsDoNotOversell(t *testing.T) {
+	s, _ := newTestService()
+	inventory := &inventoryService{notifications: s, ttl: time.Hour}
+	publish(t, s, "OLJCESPC7Z", 10)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := reserve(inventory, fmt.Sprintf("order-%d", i), 1)
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			} else if reason := rpcerrors.Classify(err).Reason; reason != reasonOutOfStock {
+				t.Errorf("ReserveStock() = %v, want %s", err, reasonOutOfStock)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if reserved != 10 {
+		t.Errorf("%d concurrent orders reserved one of 10 in stock, want 10", reserved)
+	}
+	if got := available(t, s); got != 0 {
+		t.Errorf("GetAvailability() with all stock reserved = %d, want 0", got)
+	}
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	s, email := newTestService()
+	inventory := &inventoryService{notifications: s, ttl: time.Hour}
+	ctx := context.Background()
+	publish(t, s, "OLJCESPC7Z", 3)
+
+	if err := reserve(inventory, "order-1", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := reserve(inventory, "order-1", 2); err != nil {
+		t.Fatalf("ReserveStock() again for the same order: %v", err)
+	}
+	if got := available(t, s); got != 1 {
+		t.Fatalf("available after reserving 2 of 3 = %d, want 1", got)
+	}
+	if _, err := inventory.ConfirmReservation(ctx, &pb.ReservationRequest{OrderId: "order-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := available(t, s); got != 1 {
+		t.Fatalf("available after confirming = %d, want 1", got)
+	}
+	if _, err := inventory.ConfirmReservation(ctx, &pb.ReservationRequest{OrderId: "order-1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("ConfirmReservation() twice = %v, want NotFound", err)
+	}
+
+	// releasing the last one puts the product back in stock
+	if err := reserve(inventory, "order-2", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SubscribeBackInStock(ctx, &pb.SubscribeBackInStockRequest{ProductId: "OLJCESPC7Z", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := inventory.ReleaseReservation(ctx, &pb.ReservationRequest{OrderId: "order-2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := available(t, s); got != 1 {
+		t.Errorf("available after releasing = %d, want 1", got)
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d notifications after a release put the product back in stock, want 1", len(email.sent))
+	}
+}
+
+func TestReservationsExpire(t *testing.T) {
+	s, _ := newTestService()
+	publish(t, s, "OLJCESPC7Z", 1)
+	r, err := s.store.reserve("order-1", []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 1}}, time.Now(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.store.expireReservations(r.GetExpiresAt().AsTime().Add(-time.Second))); n != 0 {
+		t.Fatalf("expired %d reservations before their expiry", n)
+	}
+	if n := len(s.store.expireReservations(r.GetExpiresAt().AsTime())); n != 1 {
+		t.Fatalf("expired %d reservations, want 1", n)
+	}
+	if got := available(t, s); got != 1 {
+		t.Errorf("available after the reservation expired = %d, want 1", got)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/logging"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	t.Setenv("SHUTDOWN_DRAIN_DELAY", "0")
+	m := New(logging.New("test"))
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(s string) Func {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, s)
+			return nil
+		}
+	}
+	m.OnUnready(func() { record("unready")(context.Background()) })
+	m.OnClose("telemetry", record("telemetry"))
+	m.OnDrain("server", record("server"))
+	m.OnClose("db", record("db"))
+	m.Shutdown()
+
+	want := []string{"unready", "server", "db", "telemetry"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("shutdown order = %v, want %v", order, want)
+	}
+	if m.Context().Err() == nil {
+		t.Error("Context not cancelled after Shutdown")
+	}
+}
+
+// slowHealth answers health checks after a delay, or when they are canceled,
+// to stand in for an RPC in flight during shutdown.
+type slowHealth struct {
+	healthpb.UnimplementedHealthServer
+	delay time.Duration
+}
+
+func (s slowHealth) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	select {
+	case <-time.After(s.delay):
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func startGRPC(t *testing.T, delay time.Duration) (*grpc.Server, healthpb.HealthClient) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, slowHealth{delay: delay})
+	go srv.Serve(lis)
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, healthpb.NewHealthClient(conn)
+}
+
+func TestGRPCServerDrainsInFlightRPCs(t *testing.T) {
+	t.Setenv("SHUTDOWN_DRAIN_DELAY", "0")
+	srv, client := startGRPC(t, 200*time.Millisecond)
+	m := New(logging.New("test"))
+	m.OnDrain("grpc server", GRPCServer(srv))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	m.Shutdown()
+	if err := <-done; err != nil {
+		t.Errorf("in-flight RPC failed during shutdown: %v", err)
+	}
+}
+
+func TestGracePeriodStopsServers(t *testing.T) {
+	t.Setenv("SHUTDOWN_DRAIN_DELAY", "0")
+	t.Setenv("SHUTDOWN_GRACE_PERIOD", "100ms")
+	srv, client := startGRPC(t, 5*time.Second)
+	m := New(logging.New("test"))
+	m.OnDrain("grpc server", GRPCServer(srv))
+
+	go client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	m.Shutdown()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Shutdown took %v, want it cut short by the 100ms grace period", d)
+	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})}
+	go srv.Serve(lis)
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + lis.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := HTTPServer(srv)(context.Background()); err != nil {
+		t.Errorf("HTTPServer: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("in-flight request failed during shutdown: %v", err)
+	}
+}
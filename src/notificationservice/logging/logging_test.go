@@ -0,0 +1,279 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/requestid"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry %q is not JSON: %v", buf.String(), err)
+	}
+	buf.Reset()
+	return entry
+}
+
+func TestLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+
+	log.WithField("order", "o-1").Warnf("payment %s", "declined")
+	entry := decode(t, &buf)
+	for k, want := range map[string]any{
+		"severity": "WARNING",
+		"message":  "payment declined",
+		"service":  "testservice",
+		"order":    "o-1",
+	} {
+		if got := entry[k]; got != want {
+			t.Errorf("%s = %v, want %v", k, got, want)
+		}
+	}
+	if _, ok := entry["timestamp"]; !ok {
+		t.Error("entry has no timestamp")
+	}
+	if _, ok := entry[TraceIDKey]; ok {
+		t.Errorf("entry without a span has %s", TraceIDKey)
+	}
+}
+
+func TestLoggerWithContext(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = requestid.NewContext(ctx, "req-1")
+	log.WithContext(ctx).WithFields(Fields{"user": "u-1"}).Info("checkout")
+	entry := decode(t, &buf)
+	if got, want := entry[TraceIDKey], sc.TraceID().String(); got != want {
+		t.Errorf("%s = %v, want %v", TraceIDKey, got, want)
+	}
+	if got, want := entry[SpanIDKey], sc.SpanID().String(); got != want {
+		t.Errorf("%s = %v, want %v", SpanIDKey, got, want)
+	}
+	if got := entry[RequestIDKey]; got != "req-1" {
+		t.Errorf("%s = %v, want req-1", RequestIDKey, got)
+	}
+	if got := entry["user"]; got != "u-1" {
+		t.Errorf("user = %v, want u-1", got)
+	}
+	if _, ok := entry[OrderIDKey]; ok {
+		t.Errorf("entry without an order has %s", OrderIDKey)
+	}
+
+	m, _ := baggage.NewMember(orderIDBaggage, "o-1")
+	b, _ := baggage.New(m)
+	log.WithContext(baggage.ContextWithBaggage(ctx, b)).Info("order placed")
+	if got := decode(t, &buf)[OrderIDKey]; got != "o-1" {
+		t.Errorf("%s = %v, want o-1", OrderIDKey, got)
+	}
+}
+
+func TestLoggerRedactsPII(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+
+	type card struct {
+		CreditCardNumber string
+		CreditCardCvv    int32
+	}
+	pii := []string{"someone@example.com", "4432801561520454", "672", "1600 Amphitheatre Parkway", "94043"}
+	log.WithFields(Fields{
+		"email":    "someone@example.com",
+		"zip_code": 94043,
+		"card":     card{CreditCardNumber: "4432801561520454", CreditCardCvv: 672},
+		"err":      errors.New("failed to send confirmation to someone@example.com"),
+		"order":    "o-1",
+	}).Infof("shipping to %s for %s", "1600 Amphitheatre Parkway", "someone@example.com")
+	log.l.Info("grouped", slog.Group("user", slog.String("email", "someone@example.com")))
+
+	out := buf.String()
+	for _, s := range pii {
+		if strings.Contains(out, s) {
+			t.Errorf("log output contains %q:\n%s", s, out)
+		}
+	}
+	if !strings.Contains(out, `"order":"o-1"`) {
+		t.Errorf("log output lost a field without personal data:\n%s", out)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	defer level.Set(level.Level())
+	level.Set(slog.LevelInfo)
+
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+	log.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("debug entry logged at info level: %s", buf.String())
+	}
+
+	srv := httptest.NewServer(LevelHandler())
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "debug" {
+		t.Fatalf("PUT debug: status %d, body %q", resp.StatusCode, body)
+	}
+
+	log.Debug("shown")
+	if got := decode(t, &buf)["message"]; got != "shown" {
+		t.Errorf("message = %v, want shown", got)
+	}
+
+	req, err = http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("verbose"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT verbose: status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := newSampler(2, 3)
+	now := time.Unix(1700000000, 0)
+	var kept []int
+	for i := 0; i < 9; i++ {
+		if s.keep("downstream call", now) {
+			kept = append(kept, i)
+		}
+	}
+	if want := []int{0, 1, 4, 7}; !slices.Equal(kept, want) {
+		t.Errorf("kept entries %v, want %v", kept, want)
+	}
+	if !s.keep("other message", now) {
+		t.Error("first entry with another message dropped")
+	}
+	if !s.keep("downstream call", now.Add(time.Second)) {
+		t.Error("first entry of the next second dropped")
+	}
+	if off := newSampler(0, 1); !off.keep("m", now) || !off.keep("m", now) {
+		t.Error("sampler with thereafter 1 dropped an entry")
+	}
+}
+
+func TestSampledDebugEntries(t *testing.T) {
+	defer func(s *sampler) { sampling = s }(sampling)
+	sampling = newSampler(1, 1000)
+
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+	for i := 0; i < 3; i++ {
+		log.Debug("busy")
+		log.Info("important")
+	}
+	if got := strings.Count(buf.String(), `"message":"busy"`); got != 1 {
+		t.Errorf("logged %d sampled debug entries, want 1", got)
+	}
+	if got := strings.Count(buf.String(), `"message":"important"`); got != 3 {
+		t.Errorf("logged %d info entries, want all 3", got)
+	}
+}
+
+func TestDurationField(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+	log.WithField(DurationKey, 1500*time.Microsecond).Info("call")
+	if got := decode(t, &buf)[DurationKey]; got != 1.5 {
+		t.Errorf("%s = %v, want 1.5", DurationKey, got)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "testservice")
+	intercept := UnaryClientInterceptor(log, "payment")
+	err := intercept(context.Background(), "/hipstershop.PaymentService/Charge", nil, nil, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "down")
+		})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want the invoker's error", err)
+	}
+	entry := decode(t, &buf)
+	for k, want := range map[string]any{
+		"message":     "downstream call",
+		"severity":    "DEBUG",
+		DownstreamKey: "payment",
+		"method":      "/hipstershop.PaymentService/Charge",
+		"code":        "Unavailable",
+	} {
+		if got := entry[k]; got != want {
+			t.Errorf("%s = %v, want %v", k, got, want)
+		}
+	}
+	if _, ok := entry[DurationKey].(float64); !ok {
+		t.Errorf("%s = %v, want a number of milliseconds", DurationKey, entry[DurationKey])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error\n": slog.LevelError,
+	} {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("ParseLevel(\"trace\") succeeded")
+	}
+}
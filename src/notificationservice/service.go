@@ -0,0 +1,114 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/genproto"
+)
+
+type notificationService struct {
+	pb.UnimplementedNotificationServiceServer
+
+	store *stockStore
+	email pb.EmailServiceClient
+	ttl   time.Duration
+}
+
+func (s *notificationService) SubscribeBackInStock(ctx context.Context, req *pb.SubscribeBackInStockRequest) (*pb.BackInStockSubscription, error) {
+	if req.GetProductId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "product_id is required")
+	}
+	addr, err := mail.ParseAddress(req.GetEmail())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid email: %v", err)
+	}
+	if q, ok := s.store.level(req.GetProductId()); ok && q > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "product %s is in stock", req.GetProductId())
+	}
+	sub := s.store.subscribe(req.GetProductId(), strings.ToLower(addr.Address), time.Now(), s.ttl)
+	log.WithContext(ctx).Infof("[SubscribeBackInStock] subscription %s waiting on product %s", sub.GetId(), sub.GetProductId())
+	return sub, nil
+}
+
+func (s *notificationService) GetAvailability(ctx context.Context, req *pb.GetAvailabilityRequest) (*pb.Availability, error) {
+	q, ok := s.store.level(req.GetProductId())
+	return &pb.Availability{ProductId: req.GetProductId(), Known: ok, Quantity: q}, nil
+}
+
+// PublishInventoryEvent records a new stock level. When a product is in
+// stock again every waiting subscriber is emailed, and their subscriptions
+// are fulfilled and dropped.
+func (s *notificationService) PublishInventoryEvent(ctx context.Context, ev *pb.InventoryEvent) (*pb.Empty, error) {
+	if ev.GetProductId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "product_id is required")
+	}
+	if ev.GetQuantity() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "quantity must not be negative")
+	}
+	s.store.setLevel(ev.GetProductId(), ev.GetQuantity())
+	if ev.GetQuantity() > 0 {
+		s.notifyRestock(ctx, ev.GetProductId())
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *notificationService) notifyRestock(ctx context.Context, productID string) {
+	subs := s.store.take(productID)
+	if len(subs) == 0 {
+		return
+	}
+	var sent int
+	for _, sub := range subs {
+		_, err := s.email.SendNotification(ctx, &pb.SendNotificationRequest{
+			Email:   sub.GetEmail(),
+			Subject: "Back in stock",
+			TextBody: fmt.Sprintf("Good news: product %s that you asked us to watch is back in stock.\n\n"+
+				"Quantities may be limited, so order soon. We won't email you about this product again.\n", productID),
+		})
+		if err != nil {
+			// Keep the subscription so that the next restock tries again.
+			log.Warnf("failed to notify %s that product %s is back in stock: %v", sub.GetEmail(), productID, err)
+			s.store.restore(sub)
+			continue
+		}
+		sent++
+	}
+	log.Infof("product %s restocked, notified %d of %d subscribers", productID, sent, len(subs))
+}
+
+// sweep periodically drops subscriptions that were never fulfilled.
+func (s *notificationService) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := s.store.expire(now); n > 0 {
+				log.Infof("expired %d back-in-stock subscriptions", n)
+			}
+		}
+	}
+}
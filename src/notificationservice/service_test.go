@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/genproto"
+)
+
+type fakeEmail struct {
+	pb.EmailServiceClient
+	err  error
+	sent []*pb.SendNotificationRequest
+}
+
+func (e *fakeEmail) SendNotification(ctx context.Context, in *pb.SendNotificationRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.sent = append(e.sent, in)
+	return &pb.Empty{}, nil
+}
+
+func newTestService() (*notificationService, *fakeEmail) {
+	email := &fakeEmail{}
+	return &notificationService{store: newStockStore(), email: email, ttl: time.Hour}, email
+}
+
+func publish(t *testing.T, s *notificationService, productID string, quantity int32) {
+	t.Helper()
+	if _, err := s.PublishInventoryEvent(context.Background(), &pb.InventoryEvent{ProductId: productID, Quantity: quantity}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestockNotifiesSubscribersOnce(t *testing.T) {
+	s, email := newTestService()
+	ctx := context.Background()
+	publish(t, s, "OLJCESPC7Z", 0)
+	for _, addr := range []string{"a@example.com", "b@example.com", "A@example.com"} {
+		if _, err := s.SubscribeBackInStock(ctx, &pb.SubscribeBackInStockRequest{ProductId: "OLJCESPC7Z", Email: addr}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publish(t, s, "OLJCESPC7Z", 5)
+	if len(email.sent) != 2 {
+		t.Fatalf("sent %d notifications, want 2 (one per distinct subscriber)", len(email.sent))
+	}
+
+	publish(t, s, "OLJCESPC7Z", 0)
+	publish(t, s, "OLJCESPC7Z", 3)
+	if len(email.sent) != 2 {
+		t.Errorf("sent %d notifications, want fulfilled subscriptions to be dropped", len(email.sent))
+	}
+}
+
+func TestRestockKeepsSubscriptionWhenEmailFails(t *testing.T) {
+	s, email := newTestService()
+	ctx := context.Background()
+	if _, err := s.SubscribeBackInStock(ctx, &pb.SubscribeBackInStockRequest{ProductId: "9SIQT8TOJO", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	email.err = errors.New("unavailable")
+	publish(t, s, "9SIQT8TOJO", 1)
+	email.err = nil
+	publish(t, s, "9SIQT8TOJO", 0)
+	publish(t, s, "9SIQT8TOJO", 2)
+	if len(email.sent) != 1 {
+		t.Errorf("sent %d notifications, want the failed one to be retried on the next restock", len(email.sent))
+	}
+}
+
+func TestSubscribeRejectsInStockProduct(t *testing.T) {
+	s, _ := newTestService()
+	publish(t, s, "OLJCESPC7Z", 2)
+	_, err := s.SubscribeBackInStock(context.Background(), &pb.SubscribeBackInStockRequest{ProductId: "OLJCESPC7Z", Email: "a@example.com"})
+	if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s, email := newTestService()
+	sub, err := s.SubscribeBackInStock(context.Background(), &pb.SubscribeBackInStockRequest{ProductId: "OLJCESPC7Z", Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.store.expire(sub.GetExpiresAt().AsTime().Add(-time.Second)); n != 0 {
+		t.Fatalf("expired %d subscriptions before their expiry", n)
+	}
+	if n := s.store.expire(sub.GetExpiresAt().AsTime()); n != 1 {
+		t.Fatalf("expired %d subscriptions, want 1", n)
+	}
+	publish(t, s, "OLJCESPC7Z", 1)
+	if len(email.sent) != 0 {
+		t.Errorf("expired subscription was notified")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/genproto"
+)
+
+// stockStore keeps the last reported stock level of each product, the
+// reservations holding some of it and the back-in-stock subscriptions
+// waiting on it, in memory.
+type stockStore struct {
+	mu     sync.Mutex
+	levels map[string]int32
+	// subs maps product ID to subscriber email to subscription.
+	subs map[string]map[string]*pb.BackInStockSubscription
+	// reservations maps order ID to the reservation of the order, and
+	// reserved product ID to the quantity they hold.
+	reservations map[string]*pb.StockReservation
+	reserved     map[string]int32
+}
+
+func newStockStore() *stockStore {
+	return &stockStore{
+		levels:       make(map[string]int32),
+		subs:         make(map[string]map[string]*pb.BackInStockSubscription),
+		reservations: make(map[string]*pb.StockReservation),
+		reserved:     make(map[string]int32),
+	}
+}
+
+// level returns the stock of productID that is not reserved, and whether a
+// level was ever reported for it.
+func (s *stockStore) level(productID string) (int32, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.levels[productID]
+	return max(q-s.reserved[productID], 0), ok
+}
+
+func (s *stockStore) setLevel(productID string, quantity int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.levels[productID] = quantity
+}
+
+// subscribe registers email for productID, or returns the subscription it
+// already has.
+func (s *stockStore) subscribe(productID, email string, now time.Time, ttl time.Duration) *pb.BackInStockSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.subs[productID][email]; ok {
+		return proto.Clone(existing).(*pb.BackInStockSubscription)
+	}
+	sub := &pb.BackInStockSubscription{
+		Id:        uuid.NewString(),
+		ProductId: productID,
+		Email:     email,
+		CreatedAt: timestamppb.New(now),
+		ExpiresAt: timestamppb.New(now.Add(ttl)),
+	}
+	if s.subs[productID] == nil {
+		s.subs[productID] = make(map[string]*pb.BackInStockSubscription)
+	}
+	s.subs[productID][email] = sub
+	return proto.Clone(sub).(*pb.BackInStockSubscription)
+}
+
+// take removes and returns every subscription waiting on productID, so that
+// concurrent restock events never notify the same subscriber twice.
+func (s *stockStore) take(productID string) []*pb.BackInStockSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*pb.BackInStockSubscription
+	for _, sub := range s.subs[productID] {
+		out = append(out, sub)
+	}
+	delete(s.subs, productID)
+	return out
+}
+
+// restore puts back a subscription that take returned but that could not be
+// fulfilled, unless the subscriber has since subscribed again.
+func (s *stockStore) restore(sub *pb.BackInStockSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[sub.GetProductId()] == nil {
+		s.subs[sub.GetProductId()] = make(map[string]*pb.BackInStockSubscription)
+	}
+	if _, ok := s.subs[sub.GetProductId()][sub.GetEmail()]; !ok {
+		s.subs[sub.GetProductId()][sub.GetEmail()] = sub
+	}
+}
+
+// expire drops subscriptions past their expiry and returns how many it
+// dropped.
+func (s *stockStore) expire(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for productID, byEmail := range s.subs {
+		for email, sub := range byEmail {
+			if !sub.GetExpiresAt().AsTime().After(now) {
+				delete(byEmail, email)
+				n++
+			}
+		}
+		if len(byEmail) == 0 {
+			delete(s.subs, productID)
+		}
+	}
+	return n
+}
+
+// outOfStockError is returned when an order reserves more of a product than
+// is available.
+type outOfStockError struct {
+	ProductID string
+	Available int32
+}
+
+func (e *outOfStockError) Error() string {
+	return fmt.Sprintf("only %d of product %s in stock", e.Available, e.ProductID)
+}
+
+// reserve holds the quantities of items for orderID until now+ttl, or
+// returns the reservation it already has. Products without a reported level
+// are reserved without limit.
+func (s *stockStore) reserve(orderID string, items []*pb.CartItem, now time.Time, ttl time.Duration) (*pb.StockReservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.reservations[orderID]; ok {
+		return proto.Clone(existing).(*pb.StockReservation), nil
+	}
+	want := make(map[string]int32)
+	for _, it := range items {
+		want[it.GetProductId()] += it.GetQuantity()
+	}
+	for productID, q := range want {
+		level, ok := s.levels[productID]
+		if available := max(level-s.reserved[productID], 0); ok && q > available {
+			return nil, &outOfStockError{ProductID: productID, Available: available}
+		}
+	}
+	r := &pb.StockReservation{OrderId: orderID, ExpiresAt: timestamppb.New(now.Add(ttl))}
+	for _, it := range items {
+		r.Items = append(r.Items, proto.Clone(it).(*pb.CartItem))
+	}
+	for productID, q := range want {
+		s.reserved[productID] += q
+	}
+	s.reservations[orderID] = r
+	return proto.Clone(r).(*pb.StockReservation), nil
+}
+
+// confirm takes the quantities of the reservation of orderID out of stock,
+// and reports whether there was one.
+func (s *stockStore) confirm(orderID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.drop(orderID)
+	if !ok {
+		return false
+	}
+	for _, it := range r.GetItems() {
+		if level, known := s.levels[it.GetProductId()]; known {
+			s.levels[it.GetProductId()] = max(level-it.GetQuantity(), 0)
+		}
+	}
+	return true
+}
+
+// release gives the quantities of the reservation of orderID back to stock
+// and returns it, or nil if there was none.
+func (s *stockStore) release(orderID string) *pb.StockReservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, _ := s.drop(orderID)
+	return r
+}
+
+// drop removes the reservation of orderID and the quantities it holds.
+func (s *stockStore) drop(orderID string) (*pb.StockReservation, bool) {
+	r, ok := s.reservations[orderID]
+	if !ok {
+		return nil, false
+	}
+	delete(s.reservations, orderID)
+	for _, it := range r.GetItems() {
+		if s.reserved[it.GetProductId()] -= it.GetQuantity(); s.reserved[it.GetProductId()] <= 0 {
+			delete(s.reserved, it.GetProductId())
+		}
+	}
+	return r, true
+}
+
+// expireReservations releases reservations past their expiry and returns
+// them.
+func (s *stockStore) expireReservations(now time.Time) []*pb.StockReservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*pb.StockReservation
+	for orderID, r := range s.reservations {
+		if !r.GetExpiresAt().AsTime().After(now) {
+			s.drop(orderID)
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/notificationservice/logging"
+)
+
+func TestRun(t *testing.T) {
+	w := newWarmup(logging.New("test"), 50*time.Millisecond)
+	var ran atomic.Int32
+	w.Add("ok", func(context.Context) error { ran.Add(1); return nil })
+	w.Add("broken", func(context.Context) error { ran.Add(1); return errors.New("boom") })
+	w.Add("slow", func(ctx context.Context) error {
+		ran.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if w.Done() {
+		t.Fatal("Done before Run")
+	}
+	w.Run(context.Background())
+	if !w.Done() {
+		t.Error("not Done after Run")
+	}
+	if got := ran.Load(); got != 3 {
+		t.Errorf("%d tasks ran, want 3", got)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	t.Setenv("WARMUP_TIMEOUT", "0")
+	w, err := New(logging.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Add("never", func(context.Context) error {
+		t.Error("task ran with warm-up disabled")
+		return nil
+	})
+	w.Run(context.Background())
+	if !w.Done() {
+		t.Error("not Done after Run")
+	}
+
+	t.Setenv("WARMUP_TIMEOUT", "soon")
+	if _, err := New(logging.New("test")); err == nil {
+		t.Error("New with invalid WARMUP_TIMEOUT succeeded")
+	}
+}
+
+func TestConn(t *testing.T) {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Conn(conn)(ctx); err != nil {
+		t.Errorf("Conn: %v", err)
+	}
+
+	lis.Close()
+	srv.Stop()
+	down, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer down.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := Conn(down)(ctx); err == nil {
+		t.Error("Conn to a closed listener succeeded")
+	}
+}
@@ -15,6 +15,7 @@
 const cardValidator = require('simple-card-validator');
 const { v4: uuidv4 } = require('uuid');
 const pino = require('pino');
+const { recordCharge } = require('./refund');
 
 const logger = pino({
   name: 'paymentservice-charge',
@@ -52,6 +53,20 @@ class ExpiredCreditCard extends CreditCardError {
   }
 }
 
+class IdempotencyKeyReused extends CreditCardError {
+  constructor (key) {
+    super(`Idempotency key ${key} was used to charge a different amount`);
+  }
+}
+
+// Charges made with an idempotency key are kept in memory, keyed by it, so
+// that retrying one returns its transaction until the service restarts.
+const chargesByKey = new Map();
+
+function sameAmount (a, b) {
+  return a.currency_code === b.currency_code && String(a.units) === String(b.units) && a.nanos === b.nanos;
+}
+
 /**
  * Verifies the credit card number and (pretend) charges the card.
  *
@@ -59,7 +74,13 @@ class ExpiredCreditCard extends CreditCardError {
  * @return transaction_id - a random uuid.
  */
 module.exports = function charge (request) {
-  const { amount, credit_card: creditCard } = request;
+  const { amount, credit_card: creditCard, idempotency_key: idempotencyKey } = request;
+  const previous = idempotencyKey && chargesByKey.get(idempotencyKey);
+  if (previous) {
+    if (!sameAmount(previous.amount, amount)) { throw new IdempotencyKeyReused(idempotencyKey); }
+    logger.info(`Transaction ${previous.transactionId} replayed for idempotency key ${idempotencyKey}`);
+    return { transaction_id: previous.transactionId };
+  }
   const cardNumber = creditCard.credit_card_number;
   const cardInfo = cardValidator(cardNumber);
   const {
@@ -82,5 +103,10 @@ module.exports = function charge (request) {
   logger.info(`Transaction processed: ${cardType} ending ${cardNumber.substr(-4)} \
     Amount: ${amount.currency_code}${amount.units}.${amount.nanos}`);
 
-  return { transaction_id: uuidv4() };
+  const transactionId = uuidv4();
+  recordCharge(transactionId, amount);
+  if (idempotencyKey) {
+    chargesByKey.set(idempotencyKey, { transactionId, amount });
+  }
+  return { transaction_id: transactionId };
 };
@@ -0,0 +1,77 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+const grpc = require('@grpc/grpc-js');
+const { v4: uuidv4 } = require('uuid');
+const pino = require('pino');
+
+const logger = pino({
+  name: 'paymentservice-refund',
+  messageKey: 'message',
+  formatters: {
+    level (logLevelString, logLevelNum) {
+      return { severity: logLevelString }
+    }
+  }
+});
+
+// Charges are kept in memory, keyed by transaction ID, so that they can be
+// refunded until the service restarts.
+const charges = new Map();
+
+class RefundError extends Error {
+  constructor (code, message) {
+    super(message);
+    this.code = code;
+  }
+}
+
+/**
+ * Records a charge so that it can be refunded.
+ *
+ * @param {string} transactionId
+ * @param {*} amount - the Money charged.
+ */
+function recordCharge (transactionId, amount) {
+  charges.set(transactionId, { amount, refundId: null });
+}
+
+/**
+ * Refunds a charge in full. Refunding a refunded charge returns the same
+ * refund.
+ *
+ * @param {*} request
+ * @return refund_id - a random uuid.
+ */
+function refund (request) {
+  const { transaction_id: transactionId, amount } = request;
+  const charge = charges.get(transactionId);
+  if (!charge) {
+    throw new RefundError(grpc.status.NOT_FOUND, `No charge with transaction ID ${transactionId}`);
+  }
+  const charged = charge.amount;
+  if (!amount || amount.currency_code !== charged.currency_code ||
+      String(amount.units) !== String(charged.units) || amount.nanos !== charged.nanos) {
+    throw new RefundError(grpc.status.INVALID_ARGUMENT,
+      `Transaction ${transactionId} charged ${charged.currency_code}${charged.units}.${charged.nanos}, which must be refunded in full`);
+  }
+
+  if (!charge.refundId) {
+    charge.refundId = uuidv4();
+    logger.info(`Transaction ${transactionId} refunded: ${charged.currency_code}${charged.units}.${charged.nanos}`);
+  }
+  return { refund_id: charge.refundId };
+}
+
+module.exports = { recordCharge, refund };
@@ -0,0 +1,301 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit keeps a tamper-evident, append-only log of privileged
+// operations, such as runtime configuration changes and inventory updates.
+//
+// Every entry records who did what to which target and with what outcome, and
+// is chained to the previous entry by a SHA-256 hash, so that editing,
+// removing or reordering entries breaks the chain and is detected by Verify.
+// Entries are appended to AUDIT_LOG_FILE, one JSON object per line, when it is
+// set and kept in memory otherwise; the most recent ones can be queried
+// through Handler, and each is also logged.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/peer"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/redact"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/requestid"
+)
+
+// maxRetained is how many of the latest entries are kept in memory for
+// queries.
+const maxRetained = 10000
+
+// Entry is a record of one privileged operation.
+type Entry struct {
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Service   string            `json:"service"`
+	Actor     string            `json:"actor"`
+	RequestID string            `json:"request_id,omitempty"`
+	Operation string            `json:"operation"`
+	Target    string            `json:"target,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Outcome   string            `json:"outcome"`
+	// PrevHash is the Hash of the previous entry, empty for the first one.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hex SHA-256 of the entry, with Hash itself empty, in JSON.
+	Hash string `json:"hash"`
+}
+
+// hash returns the hash that e should have.
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an audit log. A nil *Log records nothing.
+type Log struct {
+	service string
+	log     *logging.Logger
+
+	mu      sync.Mutex
+	file    *os.File
+	entries []Entry
+	last    Entry
+	now     func() time.Time
+}
+
+// FromEnv opens the audit log of service at AUDIT_LOG_FILE, or an in-memory
+// one when it is unset.
+func FromEnv(service string, log *logging.Logger) (*Log, error) {
+	return Open(service, os.Getenv("AUDIT_LOG_FILE"), log)
+}
+
+// Open opens the audit log of service stored at path, creating it if needed,
+// or an in-memory one when path is empty. It fails if the entries already in
+// the file do not form an unbroken chain, so that nothing is appended to a
+// log that was tampered with.
+func Open(service, path string, log *logging.Logger) (*Log, error) {
+	l := &Log{service: service, log: log, now: time.Now}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	prev := Entry{}
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("audit log %s: entry after %d is not valid: %w", path, prev.Seq, err)
+		}
+		if err := verifyLink(prev, e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("audit log %s: %w", path, err)
+		}
+		l.retain(e)
+		prev = e
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Close closes the file backing the log.
+func (l *Log) Close(context.Context) error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Record appends e to the log, filling in its sequence number, time, service
+// and hashes. The actor and request ID default to the gRPC peer and request
+// ID of ctx. Personal data in the details is masked.
+func (l *Log) Record(ctx context.Context, e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Actor == "" {
+		e.Actor = actor(ctx)
+	}
+	if e.RequestID == "" {
+		e.RequestID = requestid.FromContext(ctx)
+	}
+	if e.Details != nil {
+		details := make(map[string]string, len(e.Details))
+		for k, v := range e.Details {
+			details[k] = redact.Value(k, v)
+		}
+		e.Details = details
+	}
+	e.Service = l.service
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.last.Seq + 1
+	e.Time = l.now().UTC()
+	e.PrevHash = l.last.Hash
+	h, err := e.hash()
+	if err != nil {
+		return fmt.Errorf("failed to hash audit entry: %w", err)
+	}
+	e.Hash = h
+	if l.file != nil {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		if _, err := l.file.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+	}
+	l.retain(e)
+	l.log.WithContext(ctx).WithFields(logging.Fields{
+		"audit.seq":     e.Seq,
+		"audit.actor":   e.Actor,
+		"audit.target":  e.Target,
+		"audit.outcome": e.Outcome,
+	}).Infof("audit: %s", e.Operation)
+	return nil
+}
+
+// retain keeps e in memory as the latest entry; l.mu must be held once l is
+// in use.
+func (l *Log) retain(e Entry) {
+	l.last = e
+	l.entries = append(l.entries, e)
+	if len(l.entries) > maxRetained {
+		l.entries = append([]Entry(nil), l.entries[len(l.entries)-maxRetained:]...)
+	}
+}
+
+// Query selects entries of the log. Zero fields match every entry.
+type Query struct {
+	// After selects the entries whose sequence number is greater.
+	After     uint64
+	Operation string
+	Actor     string
+	// Limit caps the number of entries returned, the oldest first.
+	Limit int
+}
+
+// Entries returns the retained entries that match q, in order.
+func (l *Log) Entries(q Query) []Entry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Entry
+	for _, e := range l.entries {
+		if e.Seq <= q.After ||
+			(q.Operation != "" && e.Operation != q.Operation) ||
+			(q.Actor != "" && e.Actor != q.Actor) {
+			continue
+		}
+		out = append(out, e)
+		if q.Limit > 0 && len(out) == q.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// Verify checks that the retained entries form an unbroken chain.
+func (l *Log) Verify() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	entries := l.entries
+	l.mu.Unlock()
+	return Verify(entries)
+}
+
+// Verify checks that entries, as read back from an audit log, form an
+// unbroken chain. The first entry is trusted to link to the entry before it.
+func Verify(entries []Entry) error {
+	for i, e := range entries {
+		prev := Entry{Seq: e.Seq - 1, Hash: e.PrevHash}
+		if i > 0 {
+			prev = entries[i-1]
+		}
+		if err := verifyLink(prev, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errBroken reports a broken chain.
+var errBroken = errors.New("audit chain is broken")
+
+// verifyLink checks that e follows prev, the zero Entry standing for the
+// start of the log.
+func verifyLink(prev, e Entry) error {
+	if e.Seq != prev.Seq+1 || e.PrevHash != prev.Hash {
+		return fmt.Errorf("%w: entry %d does not follow entry %d", errBroken, e.Seq, prev.Seq)
+	}
+	h, err := e.hash()
+	if err != nil {
+		return err
+	}
+	if h != e.Hash {
+		return fmt.Errorf("%w: entry %d was modified", errBroken, e.Seq)
+	}
+	return nil
+}
+
+// actor identifies the caller of the request in ctx: its mTLS identity or,
+// failing that, its address.
+func actor(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	if id := mtls.Identity(p.AuthInfo); id != "" {
+		return id
+	}
+	if p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
@@ -23,8 +23,6 @@ import (
 	"strings"
 
 	"cloud.google.com/go/alloydbconn"
-	secretmanager "cloud.google.com/go/secretmanager/apiv1"
-	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -35,10 +33,19 @@ func loadCatalog(catalog *pb.ListProductsResponse) error {
 	defer catalogMutex.Unlock()
 
 	if os.Getenv("ALLOYDB_CLUSTER_NAME") != "" {
-		return loadCatalogFromAlloyDB(catalog)
+		catalogErr = loadCatalogFromAlloyDB(catalog)
+	} else {
+		catalogErr = loadCatalogFromLocalFile(catalog)
 	}
+	return catalogErr
+}
 
-	return loadCatalogFromLocalFile(catalog)
+// checkCatalog reports whether the catalog last loaded successfully, which
+// with AlloyDB and catalog reloading tells whether the database is usable.
+func checkCatalog(context.Context) error {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	return catalogErr
 }
 
 func loadCatalogFromLocalFile(catalog *pb.ListProductsResponse) error {
@@ -59,29 +66,6 @@ func loadCatalogFromLocalFile(catalog *pb.ListProductsResponse) error {
 	return nil
 }
 
-func getSecretPayload(project, secret, version string) (string, error) {
-	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
-	if err != nil {
-		log.Warnf("failed to create SecretManager client: %v", err)
-		return "", err
-	}
-	defer client.Close()
-
-	req := &secretmanagerpb.AccessSecretVersionRequest{
-		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/%s", project, secret, version),
-	}
-
-	// Call the API.
-	result, err := client.AccessSecretVersion(ctx, req)
-	if err != nil {
-		log.Warnf("failed to access SecretVersion: %v", err)
-		return "", err
-	}
-
-	return string(result.Payload.Data), nil
-}
-
 func loadCatalogFromAlloyDB(catalog *pb.ListProductsResponse) error {
 	log.Info("loading catalog from AlloyDB...")
 
@@ -93,8 +77,15 @@ func loadCatalogFromAlloyDB(catalog *pb.ListProductsResponse) error {
 	pgTableName := os.Getenv("ALLOYDB_TABLE_NAME")
 	pgSecretName := os.Getenv("ALLOYDB_SECRET_NAME")
 
-	pgPassword, err := getSecretPayload(projectID, pgSecretName, "latest")
+	// ALLOYDB_PASSWORD_SECRET can point at any secrets backend; by default
+	// the password is read from Secret Manager.
+	ref := os.Getenv("ALLOYDB_PASSWORD_SECRET")
+	if ref == "" {
+		ref = fmt.Sprintf("gcpsm://projects/%s/secrets/%s/versions/latest", projectID, pgSecretName)
+	}
+	pgPassword, err := secretStore.Get(context.Background(), ref)
 	if err != nil {
+		log.Warnf("failed to read AlloyDB password: %v", err)
 		return err
 	}
 
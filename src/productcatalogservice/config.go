@@ -0,0 +1,56 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/instrumentation"
+)
+
+// validateConfig checks the service's environment before it starts.
+func validateConfig() error {
+	c := configcheck.New("productcatalogservice")
+	c.Common()
+	c.Port("PORT", port)
+	c.Port("METRICS_PORT", instrumentation.DefaultMetricsPort)
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		c.Port("DEBUG_PORT", instrumentation.DefaultDebugPort)
+	}
+	c.Duration("EXTRA_LATENCY", 0)
+	c.URL("MODERATION_CLASSIFIER_URL")
+
+	if os.Getenv("ALLOYDB_CLUSTER_NAME") != "" {
+		for _, key := range []string{
+			"PROJECT_ID",
+			"REGION",
+			"ALLOYDB_INSTANCE_NAME",
+			"ALLOYDB_DATABASE_NAME",
+			"ALLOYDB_TABLE_NAME",
+		} {
+			c.Required(key)
+		}
+		if os.Getenv("ALLOYDB_PASSWORD_SECRET") == "" {
+			c.Required("ALLOYDB_SECRET_NAME")
+		}
+	}
+	c.SecretRef("ALLOYDB_PASSWORD_SECRET")
+	c.Duration("SECRETS_CACHE_TTL", 0)
+	c.URL("VAULT_ADDR")
+	c.Duration("HEALTH_CHECK_INTERVAL", time.Second)
+	return c.Err()
+}
@@ -0,0 +1,316 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configcheck validates a service's configuration at startup, so that
+// a missing variable or a malformed address is reported all at once before
+// the service starts serving, rather than surfacing mid-request.
+//
+// Each check reads one environment variable and records a problem if it is
+// unusable; Err returns them all together. Listening ports are also checked
+// against each other for collisions.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package configcheck
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checker accumulates configuration problems.
+type Checker struct {
+	service  string
+	problems []string
+	ports    map[int]string
+}
+
+// New returns a Checker for service.
+func New(service string) *Checker {
+	return &Checker{service: service, ports: make(map[int]string)}
+}
+
+// Problemf records a problem with key.
+func (c *Checker) Problemf(key, format string, args ...any) {
+	c.problems = append(c.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+// Required checks that key is set.
+func (c *Checker) Required(key string) {
+	if os.Getenv(key) == "" {
+		c.Problemf(key, "required but not set")
+	}
+}
+
+// Addr checks that key holds a host:port address. Unset is a problem only if
+// required.
+func (c *Checker) Addr(key string, required bool) {
+	v := os.Getenv(key)
+	if v == "" {
+		if required {
+			c.Problemf(key, "required but not set")
+		}
+		return
+	}
+	host, port, err := net.SplitHostPort(v)
+	if err != nil {
+		c.Problemf(key, "%q is not a host:port address", v)
+		return
+	}
+	if host == "" {
+		c.Problemf(key, "%q has no host", v)
+	}
+	if _, err := parsePort(port); err != nil {
+		c.Problemf(key, "%q: %v", v, err)
+	}
+}
+
+// Port checks that key, or fallback when unset, is a port number that no
+// other listener of the service uses.
+func (c *Checker) Port(key, fallback string) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		v = fallback
+	}
+	n, err := parsePort(v)
+	if err != nil {
+		c.Problemf(key, "%q: %v", v, err)
+		return
+	}
+	if other, taken := c.ports[n]; taken {
+		c.Problemf(key, "port %d is already used by %s", n, other)
+		return
+	}
+	c.ports[n] = key
+}
+
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("not a port number between 1 and 65535")
+	}
+	return n, nil
+}
+
+// URL checks that key, if set, is an absolute http or https URL.
+func (c *Checker) URL(key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.Problemf(key, "%q is not an http(s) URL", v)
+	}
+}
+
+// Duration checks that key, if set, is a duration of at least min.
+func (c *Checker) Duration(key string, min time.Duration) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		c.Problemf(key, "%q is not a duration such as 30s or 5m", v)
+	} else if d < min {
+		c.Problemf(key, "%s is shorter than %s", d, min)
+	}
+}
+
+// Int checks that key, if set, is an integer of at least min.
+func (c *Checker) Int(key string, min int) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		c.Problemf(key, "%q is not an integer", v)
+	} else if n < min {
+		c.Problemf(key, "%d is less than %d", n, min)
+	}
+}
+
+// Float checks that key, if set, is a number greater than min.
+func (c *Checker) Float(key string, min float64) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		c.Problemf(key, "%q is not a number", v)
+	} else if f <= min {
+		c.Problemf(key, "%g is not greater than %g", f, min)
+	}
+}
+
+// OneOf checks that key, if set, is one of values, ignoring case.
+func (c *Checker) OneOf(key string, values ...string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	for _, allowed := range values {
+		if strings.EqualFold(v, allowed) {
+			return
+		}
+	}
+	c.Problemf(key, "%q is not one of %s", v, strings.Join(values, ", "))
+}
+
+// File checks that key, or fallback when unset, names a readable file.
+func (c *Checker) File(key, fallback string) {
+	v := os.Getenv(key)
+	if v == "" {
+		v = fallback
+	}
+	if v == "" {
+		c.Problemf(key, "required but not set")
+		return
+	}
+	f, err := os.Open(v)
+	if err != nil {
+		c.Problemf(key, "%v", err)
+		return
+	}
+	f.Close()
+}
+
+// SecretRef checks that key, if set, is a secret reference with a known
+// scheme.
+func (c *Checker) SecretRef(key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	scheme, path, ok := strings.Cut(v, "://")
+	switch {
+	case !ok || path == "":
+		c.Problemf(key, "%q is not a secret reference such as file:///path", v)
+	case scheme != "env" && scheme != "file" && scheme != "vault" && scheme != "gcpsm":
+		c.Problemf(key, "unknown secret backend %q", scheme)
+	case scheme == "vault" && !strings.Contains(path, "#"):
+		c.Problemf(key, "%q has no #field", v)
+	}
+}
+
+// DSN checks that key, if set, is a PostgreSQL connection string, either a
+// postgres:// URL or space-separated key=value pairs.
+func (c *Checker) DSN(key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	if strings.HasPrefix(v, "postgres://") || strings.HasPrefix(v, "postgresql://") {
+		u, err := url.Parse(v)
+		if err != nil {
+			// The error quotes the DSN, which may hold a password.
+			c.Problemf(key, "not a valid URL")
+		} else if u.Host == "" {
+			c.Problemf(key, "URL has no host")
+		}
+		return
+	}
+	for _, field := range strings.Fields(v) {
+		if k, _, ok := strings.Cut(field, "="); !ok || k == "" {
+			c.Problemf(key, "not a postgres:// URL or key=value pairs")
+			return
+		}
+	}
+}
+
+// Common checks the settings every Go service shares: logging, tracing,
+// shutdown, gRPC settings, load shedding, warm-up and mutual TLS.
+func (c *Checker) Common() {
+	c.OneOf("LOG_LEVEL", "debug", "info", "warn", "warning", "error")
+	c.Int("LOG_SAMPLE_FIRST", 0)
+	c.Int("LOG_SAMPLE_THEREAFTER", 1)
+	c.OneOf("ENABLE_TRACING", "0", "1")
+	c.Duration("SHUTDOWN_GRACE_PERIOD", time.Second)
+	c.Duration("SHUTDOWN_DRAIN_DELAY", 0)
+	c.OneOf("GRPC_COMPRESSION", "none", "gzip")
+	c.OneOf("ENABLE_GRPC_REFLECTION", "0", "1")
+	c.OneOf("ENABLE_CHANNELZ", "0", "1")
+	c.Float("ADMISSION_CPU_THRESHOLD", 0)
+	c.Int("ADMISSION_MAX_IN_FLIGHT", 1)
+	c.Duration("ADMISSION_INTERVAL", time.Millisecond)
+	c.Duration("WARMUP_TIMEOUT", 0)
+	for _, key := range []string{
+		"GRPC_KEEPALIVE_TIME",
+		"GRPC_KEEPALIVE_TIMEOUT",
+		"GRPC_MAX_CONNECTION_IDLE",
+		"GRPC_MAX_CONNECTION_AGE",
+		"GRPC_MAX_CONNECTION_AGE_GRACE",
+		"GRPC_KEEPALIVE_MIN_TIME",
+		"GRPC_CLIENT_KEEPALIVE_TIME",
+		"GRPC_CLIENT_KEEPALIVE_TIMEOUT",
+		"GRPC_IDLE_TIMEOUT",
+	} {
+		c.Duration(key, time.Millisecond)
+	}
+	c.Addr("COLLECTOR_SERVICE_ADDR", false)
+	c.OneOf("MTLS_MODE", "disabled", "permissive", "strict")
+	c.OneOf("MTLS_SOURCE", "file", "spiffe")
+	if mode := strings.ToLower(os.Getenv("MTLS_MODE")); mode != "" && mode != "disabled" {
+		switch strings.ToLower(os.Getenv("MTLS_SOURCE")) {
+		case "", "file":
+			c.File("MTLS_CERT_FILE", "/var/run/tls/tls.crt")
+			c.File("MTLS_KEY_FILE", "/var/run/tls/tls.key")
+			c.File("MTLS_CA_FILE", "/var/run/tls/ca.crt")
+		case "spiffe":
+			c.Required("SPIFFE_ENDPOINT_SOCKET")
+		}
+	}
+}
+
+// Err returns every problem found, or nil if there were none.
+func (c *Checker) Err() error {
+	if len(c.problems) == 0 {
+		return nil
+	}
+	return &Error{Service: c.service, Problems: c.problems}
+}
+
+// Error lists the problems of an invalid configuration.
+type Error struct {
+	Service  string
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	noun := "problems"
+	if len(e.Problems) == 1 {
+		noun = "problem"
+	}
+	fmt.Fprintf(&b, "invalid configuration for %s (%d %s):", e.Service, len(e.Problems), noun)
+	for _, p := range e.Problems {
+		b.WriteString("\n  - " + p)
+	}
+	return b.String()
+}
+
+// FailFast reports whether an invalid configuration should stop the service.
+// It does unless CONFIG_VALIDATION is set to "warn", which lets a service with
+// a questionable configuration start while a fix is rolled out.
+func FailFast() bool {
+	return !strings.EqualFold(os.Getenv("CONFIG_VALIDATION"), "warn")
+}
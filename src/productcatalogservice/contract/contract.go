@@ -0,0 +1,461 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contract holds the behavior the Go services rely on from the
+// services they call, as test suites that run against any implementation: the
+// fakes of the fakes package, the Go services themselves in their own tests,
+// and any running service, such as the ones in other languages, whose address
+// is set in the environment (see the tests of this package). A fake or a
+// service change that breaks a caller's assumption fails the suite.
+//
+// This package is duplicated in checkoutservice, frontend,
+// productcatalogservice and shippingservice since they do not share packages.
+package contract
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
+)
+
+const callTimeout = 10 * time.Second
+
+// Serve serves the services that register registers in process until the
+// test ends, and returns a connection to them.
+func Serve(t testing.TB, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return dial(t, "passthrough:///bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+}
+
+// Dial returns a connection, without TLS, to the server at addr until the
+// test ends.
+func Dial(t testing.TB, addr string) *grpc.ClientConn {
+	t.Helper()
+	return dial(t, addr)
+}
+
+func dial(t testing.TB, target string, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(target, append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func callContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func checkMoney(t *testing.T, what string, m *pb.Money, currency string) {
+	t.Helper()
+	switch {
+	case m == nil:
+		t.Errorf("%s is missing", what)
+	case m.GetCurrencyCode() != currency:
+		t.Errorf("%s is in %q, want %q", what, m.GetCurrencyCode(), currency)
+	case m.GetUnits() < 0 || m.GetNanos() < 0 || m.GetNanos() > 999999999:
+		t.Errorf("%s = %v, want a valid non-negative amount", what, m)
+	}
+}
+
+func moneyValue(m *pb.Money) float64 {
+	return float64(m.GetUnits()) + float64(m.GetNanos())/1e9
+}
+
+// ProductCatalog checks a hipstershop.ProductCatalogService: every product
+// has an ID, a name and a price in USD, and can be fetched by ID or found by
+// searching for its name.
+func ProductCatalog(t *testing.T, c pb.ProductCatalogServiceClient) {
+	list, err := c.ListProducts(callContext(t), &pb.ListProductsRequest{})
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	if len(list.GetProducts()) == 0 {
+		t.Fatal("ListProducts returned no products")
+	}
+	for _, p := range list.GetProducts() {
+		if p.GetId() == "" || p.GetName() == "" {
+			t.Errorf("product %v lacks an ID or a name", p)
+		}
+		checkMoney(t, fmt.Sprintf("price of %s", p.GetId()), p.GetPriceUsd(), "USD")
+	}
+	first := list.GetProducts()[0]
+
+	t.Run("GetProduct", func(t *testing.T) {
+		got, err := c.GetProduct(callContext(t), &pb.GetProductRequest{Id: first.GetId()})
+		if err != nil {
+			t.Fatalf("GetProduct(%q): %v", first.GetId(), err)
+		}
+		if !proto.Equal(got, first) {
+			t.Errorf("GetProduct(%q) = %v, want the listed %v", first.GetId(), got, first)
+		}
+	})
+	t.Run("ListProducts paged", func(t *testing.T) {
+		page, err := c.ListProducts(callContext(t), &pb.ListProductsRequest{PageSize: 1, Page: 2})
+		if err != nil {
+			t.Fatalf("ListProducts page 2: %v", err)
+		}
+		if int(page.GetTotalSize()) != len(list.GetProducts()) {
+			t.Errorf("ListProducts total size = %d, want %d", page.GetTotalSize(), len(list.GetProducts()))
+		}
+		if len(list.GetProducts()) > 1 && (len(page.GetProducts()) != 1 || !proto.Equal(page.GetProducts()[0], list.GetProducts()[1])) {
+			t.Errorf("ListProducts page 2 of size 1 = %v, want the second product %v", page.GetProducts(), list.GetProducts()[1])
+		}
+	})
+	t.Run("ListProducts by price", func(t *testing.T) {
+		sorted, err := c.ListProducts(callContext(t), &pb.ListProductsRequest{Sort: pb.ProductSort_PRODUCT_SORT_PRICE_ASC})
+		if err != nil {
+			t.Fatalf("ListProducts by price: %v", err)
+		}
+		if len(sorted.GetProducts()) != len(list.GetProducts()) {
+			t.Fatalf("ListProducts by price = %d products, want %d", len(sorted.GetProducts()), len(list.GetProducts()))
+		}
+		for i := 1; i < len(sorted.GetProducts()); i++ {
+			a, b := sorted.GetProducts()[i-1].GetPriceUsd(), sorted.GetProducts()[i].GetPriceUsd()
+			if a.GetUnits() > b.GetUnits() || a.GetUnits() == b.GetUnits() && a.GetNanos() > b.GetNanos() {
+				t.Errorf("ListProducts by price lists %v before %v", a, b)
+			}
+		}
+	})
+	t.Run("GetProduct unknown", func(t *testing.T) {
+		if _, err := c.GetProduct(callContext(t), &pb.GetProductRequest{Id: "NO-SUCH-PRODUCT"}); err == nil {
+			t.Error("GetProduct of an unknown ID succeeded")
+		}
+	})
+	t.Run("SearchProducts", func(t *testing.T) {
+		resp, err := c.SearchProducts(callContext(t), &pb.SearchProductsRequest{Query: strings.ToUpper(first.GetName())})
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		for _, p := range resp.GetResults() {
+			if p.GetId() == first.GetId() {
+				return
+			}
+		}
+		t.Errorf("searching for %q did not find %s", strings.ToUpper(first.GetName()), first.GetId())
+	})
+}
+
+// Cart checks a hipstershop.CartService: carts start empty, adding a product
+// twice adds up its quantities, as does merging carts with a product in
+// common, and emptying a cart empties it.
+func Cart(t *testing.T, c pb.CartServiceClient) {
+	ctx := callContext(t)
+	user := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+	cart, err := c.GetCart(ctx, &pb.GetCartRequest{UserId: user})
+	if err != nil {
+		t.Fatalf("GetCart: %v", err)
+	}
+	if len(cart.GetItems()) != 0 {
+		t.Fatalf("new cart has items %v", cart.GetItems())
+	}
+
+	for _, it := range []*pb.CartItem{
+		{ProductId: "OLJCESPC7Z", Quantity: 1},
+		{ProductId: "66VCHSJNUP", Quantity: 3},
+		{ProductId: "OLJCESPC7Z", Quantity: 2},
+	} {
+		if _, err := c.AddItem(ctx, &pb.AddItemRequest{UserId: user, Item: it}); err != nil {
+			t.Fatalf("AddItem(%v): %v", it, err)
+		}
+	}
+	cart, err = c.GetCart(ctx, &pb.GetCartRequest{UserId: user})
+	if err != nil {
+		t.Fatalf("GetCart: %v", err)
+	}
+	got := make(map[string]int32)
+	for _, it := range cart.GetItems() {
+		got[it.GetProductId()] += it.GetQuantity()
+	}
+	if len(cart.GetItems()) != 2 || got["OLJCESPC7Z"] != 3 || got["66VCHSJNUP"] != 3 {
+		t.Errorf("cart items = %v, want OLJCESPC7Z x3 and 66VCHSJNUP x3", cart.GetItems())
+	}
+
+	other := user + "-other"
+	if _, err := c.AddItem(ctx, &pb.AddItemRequest{UserId: other, Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 4}}); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	merged, err := c.MergeCart(ctx, &pb.MergeCartRequest{FromUserId: other, ToUserId: user})
+	if err != nil {
+		t.Fatalf("MergeCart: %v", err)
+	}
+	clear(got)
+	for _, it := range merged.GetItems() {
+		got[it.GetProductId()] += it.GetQuantity()
+	}
+	if len(merged.GetItems()) != 2 || got["OLJCESPC7Z"] != 7 || got["66VCHSJNUP"] != 3 {
+		t.Errorf("merged cart items = %v, want OLJCESPC7Z x7 and 66VCHSJNUP x3", merged.GetItems())
+	}
+	if cart, err := c.GetCart(ctx, &pb.GetCartRequest{UserId: other}); err != nil {
+		t.Fatalf("GetCart: %v", err)
+	} else if len(cart.GetItems()) != 0 {
+		t.Errorf("merged-from cart has items %v", cart.GetItems())
+	}
+	if _, err := c.MergeCart(ctx, &pb.MergeCartRequest{FromUserId: user, ToUserId: user}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("MergeCart of a cart into itself: got %v, want InvalidArgument", err)
+	}
+
+	if _, err := c.EmptyCart(ctx, &pb.EmptyCartRequest{UserId: user}); err != nil {
+		t.Fatalf("EmptyCart: %v", err)
+	}
+	cart, err = c.GetCart(ctx, &pb.GetCartRequest{UserId: user})
+	if err != nil {
+		t.Fatalf("GetCart: %v", err)
+	}
+	if len(cart.GetItems()) != 0 {
+		t.Errorf("emptied cart has items %v", cart.GetItems())
+	}
+}
+
+// Currency checks a hipstershop.CurrencyService: USD and EUR are supported,
+// Convert converts into the requested currency, and WatchRates starts with a
+// rate for every supported currency that agrees with Convert.
+func Currency(t *testing.T, c pb.CurrencyServiceClient) {
+	supported, err := c.GetSupportedCurrencies(callContext(t), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("GetSupportedCurrencies: %v", err)
+	}
+	codes := make(map[string]bool)
+	for _, code := range supported.GetCurrencyCodes() {
+		codes[code] = true
+	}
+	if !codes["USD"] || !codes["EUR"] {
+		t.Fatalf("supported currencies %v lack USD or EUR", supported.GetCurrencyCodes())
+	}
+
+	convert := func(t *testing.T, from *pb.Money, to string) *pb.Money {
+		t.Helper()
+		got, err := c.Convert(callContext(t), &pb.CurrencyConversionRequest{From: from, ToCode: to})
+		if err != nil {
+			t.Fatalf("Convert(%v, %s): %v", from, to, err)
+		}
+		checkMoney(t, fmt.Sprintf("Convert(%v, %s)", from, to), got, to)
+		return got
+	}
+	t.Run("Convert", func(t *testing.T) {
+		same := convert(t, &pb.Money{CurrencyCode: "EUR", Units: 12, Nanos: 340000000}, "EUR")
+		if same.GetUnits() != 12 || same.GetNanos() != 340000000 {
+			t.Errorf("EUR 12.34 converted to EUR = %v", same)
+		}
+		if zero := convert(t, &pb.Money{CurrencyCode: "USD"}, "EUR"); zero.GetUnits() != 0 || zero.GetNanos() != 0 {
+			t.Errorf("USD 0 converted to EUR = %v", zero)
+		}
+	})
+	t.Run("WatchRates", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+		stream, err := c.WatchRates(ctx, &pb.WatchRatesRequest{})
+		if err != nil {
+			t.Fatalf("WatchRates: %v", err)
+		}
+		first, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("WatchRates: %v", err)
+		}
+		if !first.GetFull() {
+			t.Error("first WatchRates message is not full")
+		}
+		rates := first.GetRates()
+		for code := range codes {
+			if rates[code] <= 0 {
+				t.Errorf("WatchRates lacks a rate for supported currency %s", code)
+			}
+		}
+		if rates["EUR"] != 1 {
+			t.Errorf("EUR rate = %v, want 1", rates["EUR"])
+		}
+		got := moneyValue(convert(t, &pb.Money{CurrencyCode: "EUR", Units: 100}, "USD"))
+		if want := 100 * rates["USD"]; math.Abs(got-want) > 1e-6*want {
+			t.Errorf("Convert(EUR 100, USD) = %v, but WatchRates says %v", got, want)
+		}
+	})
+}
+
+// Shipping checks a hipstershop.ShippingService: quotes are in USD and
+// depend only on the request, shipped orders get a tracking ID that can be
+// tracked, and only shipments that exist can be cancelled, any number of
+// times.
+func Shipping(t *testing.T, c pb.ShippingServiceClient) {
+	req := &pb.GetQuoteRequest{
+		Address: &pb.Address{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", State: "CA", Country: "United States", ZipCode: 94043},
+		Items:   []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}},
+	}
+	quote, err := c.GetQuote(callContext(t), req)
+	if err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	checkMoney(t, "quote", quote.GetCostUsd(), "USD")
+	again, err := c.GetQuote(callContext(t), req)
+	if err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	if !proto.Equal(quote, again) {
+		t.Errorf("quotes for the same request differ: %v and %v", quote, again)
+	}
+
+	shipped, err := c.ShipOrder(callContext(t), &pb.ShipOrderRequest{Address: req.Address, Items: req.Items})
+	if err != nil {
+		t.Fatalf("ShipOrder: %v", err)
+	}
+	if shipped.GetTrackingId() == "" {
+		t.Error("ShipOrder returned no tracking ID")
+	}
+	got, err := c.GetShipment(callContext(t), &pb.GetShipmentRequest{TrackingId: shipped.GetTrackingId()})
+	if err != nil {
+		t.Errorf("GetShipment(%s): %v", shipped.GetTrackingId(), err)
+	} else if got.GetStatus() == pb.ShipmentStatus_SHIPMENT_STATUS_UNSPECIFIED || got.GetStatus() == pb.ShipmentStatus_SHIPMENT_STATUS_CANCELLED {
+		t.Errorf("GetShipment of a new shipment: status %v", got.GetStatus())
+	}
+
+	for range 2 {
+		if _, err := c.CancelShipment(callContext(t), &pb.CancelShipmentRequest{TrackingId: shipped.GetTrackingId()}); err != nil {
+			t.Errorf("CancelShipment(%s): %v", shipped.GetTrackingId(), err)
+		}
+	}
+	if got, err := c.GetShipment(callContext(t), &pb.GetShipmentRequest{TrackingId: shipped.GetTrackingId()}); err != nil {
+		t.Errorf("GetShipment(%s): %v", shipped.GetTrackingId(), err)
+	} else if got.GetStatus() != pb.ShipmentStatus_SHIPMENT_STATUS_CANCELLED {
+		t.Errorf("GetShipment of a cancelled shipment: status %v, want CANCELLED", got.GetStatus())
+	}
+	if _, err := c.CancelShipment(callContext(t), &pb.CancelShipmentRequest{TrackingId: "no-such-shipment"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CancelShipment of an unknown shipment: got %v, want NotFound", err)
+	}
+	if _, err := c.GetShipment(callContext(t), &pb.GetShipmentRequest{TrackingId: "no-such-shipment"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetShipment of an unknown shipment: got %v, want NotFound", err)
+	}
+}
+
+// ValidCard returns a VISA test card that expires next year.
+func ValidCard() *pb.CreditCardInfo {
+	return &pb.CreditCardInfo{
+		CreditCardNumber:          "4432-8015-6152-0454",
+		CreditCardCvv:             672,
+		CreditCardExpirationYear:  int32(time.Now().Year() + 1),
+		CreditCardExpirationMonth: 1,
+	}
+}
+
+// Payment checks a hipstershop.PaymentService: valid VISA cards are charged
+// and get a transaction ID, once per idempotency key, while invalid, expired
+// and other cards are refused, and charges can be refunded in full, with the
+// same refund each time.
+func Payment(t *testing.T, c pb.PaymentServiceClient) {
+	amount := &pb.Money{CurrencyCode: "USD", Units: 42}
+	resp, err := c.Charge(callContext(t), &pb.ChargeRequest{Amount: amount, CreditCard: ValidCard()})
+	if err != nil {
+		t.Fatalf("Charge with a valid card: %v", err)
+	}
+	if resp.GetTransactionId() == "" {
+		t.Error("Charge returned no transaction ID")
+	}
+	key := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+	keyed, err := c.Charge(callContext(t), &pb.ChargeRequest{Amount: amount, CreditCard: ValidCard(), IdempotencyKey: key})
+	if err != nil {
+		t.Fatalf("Charge with an idempotency key: %v", err)
+	}
+	if again, err := c.Charge(callContext(t), &pb.ChargeRequest{Amount: amount, CreditCard: ValidCard(), IdempotencyKey: key}); err != nil || again.GetTransactionId() != keyed.GetTransactionId() {
+		t.Errorf("Charge again with idempotency key %s = %v, %v; want transaction %s again", key, again, err, keyed.GetTransactionId())
+	}
+	if keyed.GetTransactionId() == resp.GetTransactionId() {
+		t.Error("Charges with and without an idempotency key got the same transaction ID")
+	}
+
+	refund, err := c.Refund(callContext(t), &pb.RefundRequest{TransactionId: resp.GetTransactionId(), Amount: amount})
+	if err != nil {
+		t.Fatalf("Refund of a charge: %v", err)
+	}
+	if again, err := c.Refund(callContext(t), &pb.RefundRequest{TransactionId: resp.GetTransactionId(), Amount: amount}); err != nil || again.GetRefundId() != refund.GetRefundId() {
+		t.Errorf("Refund of a refunded charge = %v, %v; want refund %s again", again, err, refund.GetRefundId())
+	}
+	if _, err := c.Refund(callContext(t), &pb.RefundRequest{TransactionId: "no-such-transaction", Amount: amount}); status.Code(err) != codes.NotFound {
+		t.Errorf("Refund of an unknown charge: got %v, want NotFound", err)
+	}
+
+	invalid := ValidCard()
+	invalid.CreditCardNumber = "4432-8015-6152-0455"
+	expired := ValidCard()
+	expired.CreditCardExpirationYear = int32(time.Now().Year() - 1)
+	amex := ValidCard()
+	amex.CreditCardNumber = "3782-822463-10005"
+	for name, card := range map[string]*pb.CreditCardInfo{"invalid": invalid, "expired": expired, "AMEX": amex} {
+		if _, err := c.Charge(callContext(t), &pb.ChargeRequest{Amount: amount, CreditCard: card}); err == nil {
+			t.Errorf("Charge with an %s card succeeded", name)
+		}
+	}
+}
+
+// Inventory checks a hipstershop.InventoryService: products without a
+// reported stock level can be reserved, reserving again for an order returns
+// its reservation, and a reservation can be confirmed once but released any
+// number of times.
+func Inventory(t *testing.T, c pb.InventoryServiceClient) {
+	items := []*pb.CartItem{{ProductId: "contract-unstocked", Quantity: 2}}
+	orderID := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+	r, err := c.ReserveStock(callContext(t), &pb.ReserveStockRequest{OrderId: orderID, Items: items})
+	if err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+	if r.GetOrderId() != orderID || !r.GetExpiresAt().AsTime().After(time.Now()) {
+		t.Errorf("ReserveStock = %v, want a reservation for %s that has not expired", r, orderID)
+	}
+	if again, err := c.ReserveStock(callContext(t), &pb.ReserveStockRequest{OrderId: orderID, Items: items}); err != nil || !proto.Equal(again, r) {
+		t.Errorf("ReserveStock again = %v, %v; want %v", again, err, r)
+	}
+	if _, err := c.ConfirmReservation(callContext(t), &pb.ReservationRequest{OrderId: orderID}); err != nil {
+		t.Errorf("ConfirmReservation: %v", err)
+	}
+	if _, err := c.ConfirmReservation(callContext(t), &pb.ReservationRequest{OrderId: orderID}); status.Code(err) != codes.NotFound {
+		t.Errorf("ConfirmReservation of a confirmed reservation: got %v, want NotFound", err)
+	}
+
+	released := orderID + "-released"
+	if _, err := c.ReserveStock(callContext(t), &pb.ReserveStockRequest{OrderId: released, Items: items}); err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+	for range 2 {
+		if _, err := c.ReleaseReservation(callContext(t), &pb.ReservationRequest{OrderId: released}); err != nil {
+			t.Errorf("ReleaseReservation(%s): %v", released, err)
+		}
+	}
+	if _, err := c.ConfirmReservation(callContext(t), &pb.ReservationRequest{OrderId: released}); status.Code(err) != codes.NotFound {
+		t.Errorf("ConfirmReservation of a released reservation: got %v, want NotFound", err)
+	}
+	if _, err := c.ReserveStock(callContext(t), &pb.ReserveStockRequest{Items: items}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ReserveStock without an order ID: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/logging"
+)
+
+// serve registers c on a new in-memory server and returns a client for it.
+func serve(t *testing.T, c *Checker) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	c.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func checkStatus(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return resp.GetStatus()
+}
+
+func TestDependencyStatuses(t *testing.T) {
+	c := New(logging.New("test"), "hipstershop.TestService")
+	dbErr := errors.New("connection refused")
+	c.Add("db", func(context.Context) error { return dbErr })
+	c.Add("payment", func(context.Context) error { return nil })
+	client := healthpb.NewHealthClient(serve(t, c))
+
+	if got := checkStatus(t, client, DependencyPrefix+"db"); got != healthpb.HealthCheckResponse_UNKNOWN {
+		t.Errorf("db before first check = %v, want UNKNOWN", got)
+	}
+	c.CheckNow(context.Background())
+	for service, want := range map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                           healthpb.HealthCheckResponse_SERVING,
+		"hipstershop.TestService":    healthpb.HealthCheckResponse_SERVING,
+		DependencyPrefix + "db":      healthpb.HealthCheckResponse_NOT_SERVING,
+		DependencyPrefix + "payment": healthpb.HealthCheckResponse_SERVING,
+	} {
+		if got := checkStatus(t, client, service); got != want {
+			t.Errorf("Check(%q) = %v, want %v", service, got, want)
+		}
+	}
+
+	dbErr = nil
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, DependencyPrefix+"db"); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("db after recovery = %v, want SERVING", got)
+	}
+}
+
+func TestCriticalDependency(t *testing.T) {
+	t.Setenv("HEALTH_CRITICAL_DEPENDENCIES", "db")
+	c := New(logging.New("test"))
+	dbErr := errors.New("connection refused")
+	c.Add("db", func(context.Context) error { return dbErr })
+	c.Add("email", func(context.Context) error { return errors.New("unavailable") })
+	client := healthpb.NewHealthClient(serve(t, c))
+
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("overall before first check = %v, want NOT_SERVING", got)
+	}
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("overall with db down = %v, want NOT_SERVING", got)
+	}
+	dbErr = nil
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("overall with only email down = %v, want SERVING", got)
+	}
+}
+
+func TestAddCritical(t *testing.T) {
+	c := New(logging.New("test"))
+	dbErr := errors.New("connection refused")
+	c.AddCritical("db", func(context.Context) error { return dbErr })
+	c.Add("email", func(context.Context) error { return errors.New("unavailable") })
+	client := healthpb.NewHealthClient(serve(t, c))
+
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("overall with db down = %v, want NOT_SERVING", got)
+	}
+	if got := checkStatus(t, client, LivenessService); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("liveness with db down = %v, want SERVING", got)
+	}
+	dbErr = nil
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("overall with only email down = %v, want SERVING", got)
+	}
+
+	// an explicit list replaces the defaults
+	t.Setenv("HEALTH_CRITICAL_DEPENDENCIES", "")
+	c = New(logging.New("test"))
+	c.AddCritical("db", func(context.Context) error { return errors.New("connection refused") })
+	client = healthpb.NewHealthClient(serve(t, c))
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("overall with db down and no critical dependencies = %v, want SERVING", got)
+	}
+}
+
+func TestHold(t *testing.T) {
+	c := New(logging.New("test"))
+	c.Add("db", func(context.Context) error { return nil })
+	client := healthpb.NewHealthClient(serve(t, c))
+
+	release := c.Hold()
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("overall while held = %v, want NOT_SERVING", got)
+	}
+	if got := checkStatus(t, client, DependencyPrefix+"db"); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("db while held = %v, want SERVING", got)
+	}
+	release()
+	release()
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("overall after release = %v, want SERVING", got)
+	}
+}
+
+func TestWatchAndShutdown(t *testing.T) {
+	c := New(logging.New("test"))
+	client := healthpb.NewHealthClient(serve(t, c))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetStatus(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("first Watch status = %v, want SERVING", got)
+	}
+
+	c.Shutdown()
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetStatus(); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Watch status after Shutdown = %v, want NOT_SERVING", got)
+	}
+	c.CheckNow(context.Background())
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status after Shutdown and CheckNow = %v, want NOT_SERVING", got)
+	}
+}
+
+func TestConn(t *testing.T) {
+	downstream := New(logging.New("test"))
+	conn := serve(t, downstream)
+	check := Conn(conn)
+
+	if err := check(context.Background()); err != nil {
+		t.Errorf("check of serving downstream: %v", err)
+	}
+	downstream.Shutdown()
+	if err := check(context.Background()); err == nil {
+		t.Error("check of downstream that is shutting down succeeded, want error")
+	}
+
+	// A server without the health service still answers, so it is healthy.
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+	bare, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bare.Close()
+	if err := Conn(bare)(context.Background()); err != nil {
+		t.Errorf("check of server without health service: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Baggage members describing who a request is served for. The frontend sets
+// them and every service copies them onto its spans, so that latency can be
+// broken down by cohort. User and session IDs are hashed with HashID since
+// baggage travels in the headers of every downstream request.
+const (
+	BaggageUserHash         = "app.user.hash"
+	BaggageSessionHash      = "app.session.hash"
+	BaggageExperimentBucket = "app.experiment.bucket"
+)
+
+// BaggageOrderID is the baggage member carrying the ID of the order a request
+// places, set by checkout once it generates the ID. It is copied onto spans
+// like the members above and logged as order_id by package logging, so that
+// the ID finds every span and log line of a checkout.
+const BaggageOrderID = "app.order.id"
+
+// baggageAttributes are the baggage members copied onto spans.
+var baggageAttributes = []string{BaggageUserHash, BaggageSessionHash, BaggageExperimentBucket, BaggageOrderID}
+
+// HashID pseudonymizes id for telemetry. It returns the first 16 hex digits
+// of the HMAC-SHA256 of id keyed with TELEMETRY_HASH_KEY, which should be set
+// so that known IDs cannot be matched by hashing them.
+func HashID(id string) string {
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(os.Getenv("TELEMETRY_HASH_KEY")))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// WithBaggage returns a copy of ctx whose baggage also holds members, which
+// map keys to values. Empty values and members that are not valid baggage
+// are skipped.
+func WithBaggage(ctx context.Context, members map[string]string) context.Context {
+	b := baggage.FromContext(ctx)
+	for k, v := range members {
+		if v == "" {
+			continue
+		}
+		m, err := baggage.NewMember(k, v)
+		if err != nil {
+			continue
+		}
+		if nb, err := b.SetMember(m); err == nil {
+			b = nb
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// BaggageAttributes returns the span attributes for the request baggage in
+// ctx.
+func BaggageAttributes(ctx context.Context) []attribute.KeyValue {
+	b := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, k := range baggageAttributes {
+		if v := b.Member(k).Value(); v != "" {
+			attrs = append(attrs, attribute.String(k, v))
+		}
+	}
+	return attrs
+}
+
+// baggageSpanProcessor sets the attributes of BaggageAttributes on every
+// span started in a context that carries them.
+type baggageSpanProcessor struct{}
+
+func (baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(BaggageAttributes(parent)...)
+}
+
+func (baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (baggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	logLevel := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "info\n")
+	})
+	audit := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"entries":[]}`)
+	})
+	srv := httptest.NewServer(DebugHandler(logLevel, audit))
+	defer srv.Close()
+
+	for path, want := range map[string]string{
+		"/debug/pprof/":     "goroutine",
+		"/debug/vars":       `"memstats"`,
+		"/debug/goroutines": "goroutine ",
+		"/debug/loglevel":   "info",
+		"/debug/audit":      "entries",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+		if !strings.Contains(string(body), want) {
+			t.Errorf("GET %s: body is missing %q", path, want)
+		}
+	}
+}
+
+func TestDebugAddr(t *testing.T) {
+	t.Setenv("DEBUG_PORT", "")
+	if got, want := DebugAddr(), "127.0.0.1:"+DefaultDebugPort; got != want {
+		t.Errorf("DebugAddr() = %q, want %q", got, want)
+	}
+	t.Setenv("DEBUG_PORT", "7070")
+	if got, want := DebugAddr(), "127.0.0.1:7070"; got != want {
+		t.Errorf("DebugAddr() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedactingProcessor(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(redactingProcessor{rec}))
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "notify someone@example.com",
+		trace.WithAttributes(
+			attribute.String("email", "someone@example.com"),
+			attribute.Int("zip_code", 94043),
+			attribute.String("note", "card 4432-8015-6152-0454"),
+			attribute.StringSlice("addresses", []string{"1600 Amphitheatre Parkway"}),
+			attribute.String(BaggageUserHash, "0123456789abcdef"),
+			attribute.String("rpc.service", "hipstershop.CheckoutService"),
+		))
+	span.RecordError(errors.New("failed to email someone@example.com"))
+	span.SetStatus(codes.Error, "card 4432801561520454 declined")
+	span.End()
+
+	s := rec.Ended()[0]
+	dump := fmt.Sprint(s.Name(), s.Attributes(), s.Events(), s.Status())
+	for _, pii := range []string{"someone@example.com", "94043", "4432", "Amphitheatre"} {
+		if strings.Contains(dump, pii) {
+			t.Errorf("exported span contains %q: %s", pii, dump)
+		}
+	}
+	got := map[attribute.Key]string{}
+	for _, kv := range s.Attributes() {
+		got[kv.Key] = kv.Value.Emit()
+	}
+	if got[BaggageUserHash] != "0123456789abcdef" || got["rpc.service"] != "hipstershop.CheckoutService" {
+		t.Errorf("attributes without personal data were changed: %v", got)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	moderationQueueSize   = 100
+	moderationMaxAttempts = 3
+)
+
+// defaultBlocklist is deliberately short; extend it with MODERATION_BLOCKLIST.
+var defaultBlocklist = []string{"crap", "damn", "idiot", "stupid"}
+
+// classifier decides whether a review may be published. A rejection comes
+// with a reason; an error means no decision could be made and moderation
+// should be retried.
+type classifier interface {
+	classify(ctx context.Context, r *pb.Review) (approved bool, reason string, err error)
+}
+
+// blocklistClassifier rejects reviews containing any blocked word.
+type blocklistClassifier struct {
+	words map[string]bool
+}
+
+func newBlocklistClassifier(words []string) *blocklistClassifier {
+	c := &blocklistClassifier{words: make(map[string]bool)}
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			c.words[w] = true
+		}
+	}
+	return c
+}
+
+func (c *blocklistClassifier) classify(ctx context.Context, r *pb.Review) (bool, string, error) {
+	for _, w := range strings.FieldsFunc(strings.ToLower(r.GetText()+" "+r.GetAuthorName()), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	}) {
+		if c.words[w] {
+			return false, "contains inappropriate language", nil
+		}
+	}
+	return true, "", nil
+}
+
+// spamClassifier rejects reviews that look like link spam, shouting or
+// copies of an existing review.
+type spamClassifier struct {
+	store    *reviewStore
+	maxLinks int
+}
+
+func (c *spamClassifier) classify(ctx context.Context, r *pb.Review) (bool, string, error) {
+	text := strings.ToLower(r.GetText())
+	links := strings.Count(text, "http://") + strings.Count(text, "https://") + strings.Count(text, "www.")
+	if links > c.maxLinks {
+		return false, "contains too many links", nil
+	}
+	if isShouting(r.GetText()) {
+		return false, "written in capitals", nil
+	}
+	if c.store != nil && c.store.hasDuplicate(r) {
+		return false, "duplicates an existing review", nil
+	}
+	return true, "", nil
+}
+
+func isShouting(text string) bool {
+	var letters, upper int
+	for _, c := range text {
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				upper++
+			}
+		}
+	}
+	return letters >= 20 && upper*10 >= letters*9
+}
+
+// httpClassifier delegates the decision to an external service. It POSTs
+// {"product_id", "rating", "text"} and expects {"approved", "reason"} back.
+type httpClassifier struct {
+	url    string
+	client *http.Client
+}
+
+func (c *httpClassifier) classify(ctx context.Context, r *pb.Review) (bool, string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"product_id": r.GetProductId(),
+		"rating":     r.GetRating(),
+		"text":       r.GetText(),
+	})
+	if err != nil {
+		return false, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("classifier request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("classifier returned %s", resp.Status)
+	}
+	var out struct {
+		Approved bool   `json:"approved"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, "", fmt.Errorf("failed to decode classifier response: %w", err)
+	}
+	if !out.Approved && out.Reason == "" {
+		out.Reason = "rejected by classifier"
+	}
+	return out.Approved, out.Reason, nil
+}
+
+type moderationJob struct {
+	reviewID string
+	attempt  int
+}
+
+// moderator consumes the moderation queue. Classifiers run in order and the
+// first rejection wins; a review is approved only if all of them approve.
+// Reviews whose classification keeps failing stay PENDING.
+type moderator struct {
+	store       *reviewStore
+	classifiers []classifier
+	queue       chan moderationJob
+	retryDelay  time.Duration
+}
+
+func newModerator(store *reviewStore, classifiers ...classifier) *moderator {
+	return &moderator{
+		store:       store,
+		classifiers: classifiers,
+		queue:       make(chan moderationJob, moderationQueueSize),
+		retryDelay:  5 * time.Second,
+	}
+}
+
+// moderationClassifiersFromEnv builds the rule-based classifiers, followed by
+// the external classifier at MODERATION_CLASSIFIER_URL if one is set.
+func moderationClassifiersFromEnv(store *reviewStore) []classifier {
+	words := defaultBlocklist
+	if v := os.Getenv("MODERATION_BLOCKLIST"); v != "" {
+		words = append(words, strings.Split(v, ",")...)
+	}
+	cs := []classifier{
+		newBlocklistClassifier(words),
+		&spamClassifier{store: store, maxLinks: 1},
+	}
+	if url := os.Getenv("MODERATION_CLASSIFIER_URL"); url != "" {
+		log.Infof("using external review classifier at %s", url)
+		cs = append(cs, &httpClassifier{url: url, client: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		}})
+	}
+	return cs
+}
+
+func (m *moderator) enqueue(ctx context.Context, reviewID string) error {
+	select {
+	case m.queue <- moderationJob{reviewID: reviewID}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *moderator) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-m.queue:
+			m.process(ctx, job)
+		}
+	}
+}
+
+func (m *moderator) process(ctx context.Context, job moderationJob) {
+	r, err := m.store.get(job.reviewID)
+	if err != nil {
+		log.Warnf("skipping moderation of review %s: %v", job.reviewID, err)
+		return
+	}
+	approved, reason, err := m.classify(ctx, r)
+	if err != nil {
+		job.attempt++
+		if job.attempt >= moderationMaxAttempts {
+			log.Errorf("giving up moderating review %s after %d attempts, leaving it pending: %v", r.GetId(), job.attempt, err)
+			return
+		}
+		log.Warnf("moderation of review %s failed (attempt %d), retrying: %v", r.GetId(), job.attempt, err)
+		time.AfterFunc(m.retryDelay*time.Duration(job.attempt), func() {
+			select {
+			case m.queue <- job:
+			case <-ctx.Done():
+			}
+		})
+		return
+	}
+
+	st := pb.Review_APPROVED
+	if !approved {
+		st = pb.Review_REJECTED
+	}
+	if err := m.store.setStatus(r.GetId(), st, reason); err != nil {
+		log.Warnf("failed to record moderation of review %s: %v", r.GetId(), err)
+		return
+	}
+	if reason != "" {
+		log.Infof("review %s %s: %s", r.GetId(), st, reason)
+	} else {
+		log.Infof("review %s %s", r.GetId(), st)
+	}
+}
+
+func (m *moderator) classify(ctx context.Context, r *pb.Review) (bool, string, error) {
+	for _, c := range m.classifiers {
+		approved, reason, err := c.classify(ctx, r)
+		if err != nil || !approved {
+			return approved, reason, err
+		}
+	}
+	return true, "", nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type failingClassifier struct{ calls int }
+
+func (c *failingClassifier) classify(ctx context.Context, r *pb.Review) (bool, string, error) {
+	c.calls++
+	return false, "", errors.New("classifier unavailable")
+}
+
+func newTestReviewService(cs ...classifier) *reviewService {
+	store := newReviewStore()
+	if cs == nil {
+		cs = []classifier{newBlocklistClassifier(defaultBlocklist), &spamClassifier{store: store, maxLinks: 1}}
+	}
+	return &reviewService{
+		catalog:   mockProductCatalog,
+		store:     store,
+		moderator: newModerator(store, cs...),
+	}
+}
+
+func submitAndModerate(t *testing.T, s *reviewService, text string) *pb.Review {
+	t.Helper()
+	r, err := s.SubmitReview(context.Background(), &pb.SubmitReviewRequest{
+		ProductId:  "abc001",
+		AuthorName: "Sam",
+		Rating:     4,
+		Text:       text,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetStatus() != pb.Review_PENDING {
+		t.Fatalf("submitted review is %s, want PENDING", r.GetStatus())
+	}
+	s.moderator.process(context.Background(), <-s.moderator.queue)
+	got, err := s.GetReview(context.Background(), &pb.GetReviewRequest{Id: r.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestModerationStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want pb.Review_Status
+	}{
+		{"clean", "Sturdy and comfortable, would buy again.", pb.Review_APPROVED},
+		{"profanity", "What a stupid design.", pb.Review_REJECTED},
+		{"links", "Cheaper at http://a.example and https://b.example", pb.Review_REJECTED},
+		{"shouting", "THIS IS THE BEST PRODUCT I HAVE EVER OWNED", pb.Review_REJECTED},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := submitAndModerate(t, newTestReviewService(), tt.text)
+			if got.GetStatus() != tt.want {
+				t.Errorf("status = %s (%q), want %s", got.GetStatus(), got.GetModerationReason(), tt.want)
+			}
+		})
+	}
+}
+
+func TestModerationRejectsDuplicates(t *testing.T) {
+	s := newTestReviewService()
+	if got := submitAndModerate(t, s, "Great mug."); got.GetStatus() != pb.Review_APPROVED {
+		t.Fatalf("first review is %s, want APPROVED", got.GetStatus())
+	}
+	if got := submitAndModerate(t, s, "great mug. "); got.GetStatus() != pb.Review_REJECTED {
+		t.Errorf("duplicate review is %s, want REJECTED", got.GetStatus())
+	}
+}
+
+func TestListReviewsOnlyApproved(t *testing.T) {
+	s := newTestReviewService()
+	submitAndModerate(t, s, "Lovely colour.")
+	submitAndModerate(t, s, "Damn expensive.")
+
+	resp, err := s.ListReviews(context.Background(), &pb.ListReviewsRequest{ProductId: "abc001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetReviews()) != 1 || resp.GetReviews()[0].GetText() != "Lovely colour." {
+		t.Errorf("ListReviews = %v, want only the approved review", resp.GetReviews())
+	}
+}
+
+func TestListReviewsRating(t *testing.T) {
+	s := newTestReviewService()
+	for _, r := range []struct {
+		rating int32
+		text   string
+	}{
+		{5, "Keeps my coffee hot."},
+		{2, "Chipped after a week."},
+		{4, "Nice handle."},
+		{1, "Stupid lid."}, // rejected, so not counted
+	} {
+		if _, err := s.SubmitReview(context.Background(), &pb.SubmitReviewRequest{ProductId: "abc001", AuthorName: "Sam", Rating: r.rating, Text: r.text}); err != nil {
+			t.Fatal(err)
+		}
+		s.moderator.process(context.Background(), <-s.moderator.queue)
+	}
+
+	resp, err := s.ListReviews(context.Background(), &pb.ListReviewsRequest{ProductId: "abc001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetRating(); got.GetReviewCount() != 3 || got.GetAverageRating() != 11.0/3 {
+		t.Errorf("rating = %v, want 3 reviews averaging %v", got, 11.0/3)
+	}
+
+	resp, err = s.ListReviews(context.Background(), &pb.ListReviewsRequest{ProductId: "abc002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetRating(); got.GetReviewCount() != 0 || got.GetAverageRating() != 0 {
+		t.Errorf("rating of a product without reviews = %v, want none", got)
+	}
+}
+
+func TestModerationErrorLeavesReviewPending(t *testing.T) {
+	c := &failingClassifier{}
+	s := newTestReviewService(c)
+	got := submitAndModerate(t, s, "Fine.")
+	if got.GetStatus() != pb.Review_PENDING {
+		t.Errorf("status = %s after classifier error, want PENDING", got.GetStatus())
+	}
+}
+
+func TestSubmitReviewValidation(t *testing.T) {
+	s := newTestReviewService()
+	_, err := s.SubmitReview(context.Background(), &pb.SubmitReviewRequest{ProductId: "abc001", AuthorName: "Sam", Rating: 6, Text: "ok"})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("bad rating: got %s, want %s", got, want)
+	}
+	_, err = s.SubmitReview(context.Background(), &pb.SubmitReviewRequest{ProductId: "nope", AuthorName: "Sam", Rating: 5, Text: "ok"})
+	if got, want := status.Code(err), codes.NotFound; got != want {
+		t.Errorf("unknown product: got %s, want %s", got, want)
+	}
+}
+
+func TestHTTPClassifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		json.NewEncoder(w).Encode(map[string]interface{}{"approved": in.Text != "spam", "reason": "spam"})
+	}))
+	defer srv.Close()
+
+	c := &httpClassifier{url: srv.URL, client: srv.Client()}
+	if ok, _, err := c.classify(context.Background(), &pb.Review{Text: "fine"}); err != nil || !ok {
+		t.Errorf("classify(fine) = %v, %v; want approved", ok, err)
+	}
+	if ok, reason, err := c.classify(context.Background(), &pb.Review{Text: "spam"}); err != nil || ok || reason != "spam" {
+		t.Errorf("classify(spam) = %v, %q, %v; want rejected as spam", ok, reason, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/logging"
+)
+
+// writeCerts writes a CA and a key pair for localhost signed by it to dir.
+func writeCerts(t *testing.T, dir string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "testservice"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		"ca.crt":  {Type: "CERTIFICATE", Bytes: caDER},
+		"tls.crt": {Type: "CERTIFICATE", Bytes: leafDER},
+		"tls.key": {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newCredentials(t *testing.T, mode Mode) *Credentials {
+	t.Helper()
+	dir := t.TempDir()
+	writeCerts(t, dir)
+	t.Setenv("MTLS_MODE", string(mode))
+	t.Setenv("MTLS_PEERS", "localhost")
+	t.Setenv("MTLS_SOURCE", "file")
+	t.Setenv("MTLS_CERT_FILE", filepath.Join(dir, "tls.crt"))
+	t.Setenv("MTLS_KEY_FILE", filepath.Join(dir, "tls.key"))
+	t.Setenv("MTLS_CA_FILE", filepath.Join(dir, "ca.crt"))
+	log := logging.New("testservice")
+	c, err := FromEnv(context.Background(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPermissiveAcceptsBoth(t *testing.T) {
+	creds := newCredentials(t, Permissive)
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(creds.ServerOption())
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	addr := net.JoinHostPort("localhost", port)
+	var plaintext *Credentials
+	for name, opt := range map[string]grpc.DialOption{
+		"plaintext": plaintext.DialOption(addr),
+		"mTLS":      creds.DialOption(addr),
+	} {
+		conn, err := grpc.NewClient(addr, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p peer.Peer
+		_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Peer(&p))
+		conn.Close()
+		if err != nil {
+			t.Errorf("%s: Check failed: %v", name, err)
+			continue
+		}
+		if _, isTLS := p.AuthInfo.(credentials.TLSInfo); isTLS != (name == "mTLS") {
+			t.Errorf("%s: server used %s", name, p.AuthInfo.AuthType())
+		}
+	}
+}
+
+func TestStrictRejectsPlaintext(t *testing.T) {
+	creds := newCredentials(t, Strict)
+	cert, err := tls.LoadX509KeyPair(os.Getenv("MTLS_CERT_FILE"), os.Getenv("MTLS_KEY_FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: plaintextInfo{}})
+	mutual := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}},
+	}})
+
+	for _, tc := range []struct {
+		ctx    context.Context
+		method string
+		want   codes.Code
+	}{
+		{plaintext, "/hipstershop.ShippingService/GetQuote", codes.Unauthenticated},
+		{plaintext, "/grpc.health.v1.Health/Check", codes.OK},
+		{mutual, "/hipstershop.ShippingService/GetQuote", codes.OK},
+	} {
+		if got := status.Code(creds.authorize(tc.ctx, tc.method)); got != tc.want {
+			t.Errorf("authorize(%s) = %v, want %v", tc.method, got, tc.want)
+		}
+	}
+	if got := Identity(credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}}); got != "testservice" {
+		t.Errorf("Identity() = %q, want testservice", got)
+	}
+}
+
+func TestDialOptionPeers(t *testing.T) {
+	creds := newCredentials(t, Permissive)
+	// cartservice is not a Go service and is not listed in MTLS_PEERS, so
+	// calls to it stay in plaintext.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(conn, b); err == nil && b[0] == recordTypeHandshake {
+			t.Errorf("client to a host outside MTLS_PEERS started a TLS handshake")
+		}
+	}()
+	conn, err := grpc.NewClient(lis.Addr().String(), creds.DialOption(lis.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+}
@@ -15,13 +15,14 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"strings"
 	"time"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
 	"google.golang.org/grpc/codes"
-	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 )
 
@@ -30,18 +31,66 @@ type productCatalog struct {
 	catalog pb.ListProductsResponse
 }
 
-func (p *productCatalog) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
-	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+func (p *productCatalog) ListProducts(_ context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	time.Sleep(extraLatency)
+
+	if req.GetPageSize() < 0 || req.GetPage() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size and page must not be negative")
+	}
+	products := p.parseCatalog()
+	products, err := sortProducts(products, req.GetSort())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListProductsResponse{
+		Products:  pageOf(products, int(req.GetPageSize()), int(req.GetPage())),
+		TotalSize: int32(len(products)),
+	}, nil
 }
 
-func (p *productCatalog) Watch(req *healthpb.HealthCheckRequest, ws healthpb.Health_WatchServer) error {
-	return status.Errorf(codes.Unimplemented, "health check via Watch not implemented")
+// sortProducts returns products sorted by by, leaving products as it is.
+// Products that sort the same stay in catalog order.
+func sortProducts(products []*pb.Product, by pb.ProductSort) ([]*pb.Product, error) {
+	var compare func(a, b *pb.Product) int
+	switch by {
+	case pb.ProductSort_PRODUCT_SORT_UNSPECIFIED:
+		return products, nil
+	case pb.ProductSort_PRODUCT_SORT_NAME:
+		compare = func(a, b *pb.Product) int {
+			return strings.Compare(strings.ToLower(a.GetName()), strings.ToLower(b.GetName()))
+		}
+	case pb.ProductSort_PRODUCT_SORT_PRICE_ASC:
+		compare = comparePrices
+	case pb.ProductSort_PRODUCT_SORT_PRICE_DESC:
+		compare = func(a, b *pb.Product) int { return comparePrices(b, a) }
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown sort %v", by)
+	}
+	sorted := slices.Clone(products)
+	slices.SortStableFunc(sorted, compare)
+	return sorted, nil
 }
 
-func (p *productCatalog) ListProducts(context.Context, *pb.Empty) (*pb.ListProductsResponse, error) {
-	time.Sleep(extraLatency)
+// comparePrices compares the prices of a and b, which are all in USD.
+func comparePrices(a, b *pb.Product) int {
+	x, y := a.GetPriceUsd(), b.GetPriceUsd()
+	if c := cmp.Compare(x.GetUnits(), y.GetUnits()); c != 0 {
+		return c
+	}
+	return cmp.Compare(x.GetNanos(), y.GetNanos())
+}
 
-	return &pb.ListProductsResponse{Products: p.parseCatalog()}, nil
+// pageOf returns the products on page of the pages of size products, the
+// first when page is 0, or all of them when size is 0.
+func pageOf(products []*pb.Product, size, page int) []*pb.Product {
+	if size == 0 {
+		return products
+	}
+	start := (max(page, 1) - 1) * size
+	if start >= len(products) {
+		return nil
+	}
+	return products[start:min(start+size, len(products))]
 }
 
 func (p *productCatalog) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
@@ -17,9 +17,12 @@ package main
 import (
 	"context"
 	"os"
+	"slices"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/contract"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -78,7 +81,7 @@ func TestGetProductNotFound(t *testing.T) {
 
 func TestListProducts(t *testing.T) {
 	products, err := mockProductCatalog.ListProducts(context.Background(),
-		&pb.Empty{},
+		&pb.ListProductsRequest{},
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -88,6 +91,58 @@ func TestListProducts(t *testing.T) {
 	}
 }
 
+func TestListProductsPaged(t *testing.T) {
+	price := func(units int64, nanos int32) *pb.Money {
+		return &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+	}
+	catalog := &productCatalog{catalog: pb.ListProductsResponse{Products: []*pb.Product{
+		{Id: "p1", Name: "mug", PriceUsd: price(8, 990000000)},
+		{Id: "p2", Name: "Candle", PriceUsd: price(18, 990000000)},
+		{Id: "p3", Name: "apron", PriceUsd: price(8, 500000000)},
+		{Id: "p4", Name: "Tank Top", PriceUsd: price(18, 990000000)},
+		{Id: "p5", Name: "jar", PriceUsd: price(5, 490000000)},
+	}}}
+	ids := func(products []*pb.Product) []string {
+		var ids []string
+		for _, p := range products {
+			ids = append(ids, p.GetId())
+		}
+		return ids
+	}
+
+	for _, tc := range []struct {
+		req  *pb.ListProductsRequest
+		want []string
+	}{
+		{&pb.ListProductsRequest{PageSize: 2}, []string{"p1", "p2"}},
+		{&pb.ListProductsRequest{PageSize: 2, Page: 3}, []string{"p5"}},
+		{&pb.ListProductsRequest{PageSize: 2, Page: 4}, nil},
+		{&pb.ListProductsRequest{Sort: pb.ProductSort_PRODUCT_SORT_NAME}, []string{"p3", "p2", "p5", "p1", "p4"}},
+		{&pb.ListProductsRequest{Sort: pb.ProductSort_PRODUCT_SORT_PRICE_ASC}, []string{"p5", "p3", "p1", "p2", "p4"}},
+		{&pb.ListProductsRequest{Sort: pb.ProductSort_PRODUCT_SORT_PRICE_DESC, PageSize: 3}, []string{"p2", "p4", "p1"}},
+	} {
+		resp, err := catalog.ListProducts(context.Background(), tc.req)
+		if err != nil {
+			t.Fatalf("ListProducts(%v): %v", tc.req, err)
+		}
+		if got := ids(resp.GetProducts()); !slices.Equal(got, tc.want) {
+			t.Errorf("ListProducts(%v) = %v, want %v", tc.req, got, tc.want)
+		}
+		if resp.GetTotalSize() != 5 {
+			t.Errorf("ListProducts(%v) total size = %d, want 5", tc.req, resp.GetTotalSize())
+		}
+	}
+	if ids(catalog.catalog.Products)[0] != "p1" {
+		t.Error("sorting reordered the catalog")
+	}
+
+	for _, req := range []*pb.ListProductsRequest{{PageSize: -1}, {Page: -1}, {Sort: 42}} {
+		if _, err := catalog.ListProducts(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListProducts(%v) = %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
 func TestSearchProducts(t *testing.T) {
 	products, err := mockProductCatalog.SearchProducts(context.Background(),
 		&pb.SearchProductsRequest{Query: "alpha"},
@@ -99,3 +154,12 @@ func TestSearchProducts(t *testing.T) {
 		t.Errorf("got %d, want %d", got, want)
 	}
 }
+
+// TestContract checks the catalog in products.json against what the callers
+// of the service rely on.
+func TestContract(t *testing.T) {
+	conn := contract.Serve(t, func(srv *grpc.Server) {
+		pb.RegisterProductCatalogServiceServer(srv, &productCatalog{})
+	})
+	contract.ProductCatalog(t, pb.NewProductCatalogServiceClient(conn))
+}
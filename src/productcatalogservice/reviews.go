@@ -0,0 +1,228 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
+)
+
+const maxReviewLength = 2000
+
+var errReviewNotFound = errors.New("review not found")
+
+// reviewStore keeps reviews in memory, along with the totals of the ratings
+// of each product's approved reviews. Callers always receive copies.
+type reviewStore struct {
+	mu      sync.Mutex
+	reviews map[string]*pb.Review
+	ratings map[string]ratingTotals
+}
+
+// ratingTotals are the number and sum of the ratings of a product's approved
+// reviews.
+type ratingTotals struct {
+	count, sum int
+}
+
+func newReviewStore() *reviewStore {
+	return &reviewStore{
+		reviews: make(map[string]*pb.Review),
+		ratings: make(map[string]ratingTotals),
+	}
+}
+
+func (s *reviewStore) add(r *pb.Review) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.reviews[r.GetId()]; ok {
+		s.count(old, -1)
+	}
+	r = proto.Clone(r).(*pb.Review)
+	s.reviews[r.GetId()] = r
+	s.count(r, 1)
+}
+
+// count adds the rating of r to, or with n of -1 removes it from, the totals
+// of its product if it is approved. s.mu must be held.
+func (s *reviewStore) count(r *pb.Review, n int) {
+	if r.GetStatus() != pb.Review_APPROVED {
+		return
+	}
+	t := s.ratings[r.GetProductId()]
+	t.count += n
+	t.sum += n * int(r.GetRating())
+	if t.count == 0 {
+		delete(s.ratings, r.GetProductId())
+		return
+	}
+	s.ratings[r.GetProductId()] = t
+}
+
+// rating summarizes the ratings of the approved reviews of a product.
+func (s *reviewStore) rating(productID string) *pb.RatingSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.ratings[productID]
+	if t.count == 0 {
+		return &pb.RatingSummary{}
+	}
+	return &pb.RatingSummary{
+		ReviewCount:   int32(t.count),
+		AverageRating: float64(t.sum) / float64(t.count),
+	}
+}
+
+func (s *reviewStore) get(id string) (*pb.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reviews[id]
+	if !ok {
+		return nil, errReviewNotFound
+	}
+	return proto.Clone(r).(*pb.Review), nil
+}
+
+// setStatus records a moderation decision for a pending review.
+func (s *reviewStore) setStatus(id string, st pb.Review_Status, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reviews[id]
+	if !ok {
+		return errReviewNotFound
+	}
+	s.count(r, -1)
+	r.Status = st
+	r.ModerationReason = reason
+	s.count(r, 1)
+	return nil
+}
+
+// list returns the reviews of a product with the given status, newest first.
+func (s *reviewStore) list(productID string, st pb.Review_Status) []*pb.Review {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*pb.Review
+	for _, r := range s.reviews {
+		if r.GetProductId() == productID && r.GetStatus() == st {
+			out = append(out, proto.Clone(r).(*pb.Review))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].GetCreatedAt().AsTime().After(out[j].GetCreatedAt().AsTime())
+	})
+	return out
+}
+
+// hasDuplicate reports whether another review of the same product already
+// carries text, which is a common sign of copy-pasted spam.
+func (s *reviewStore) hasDuplicate(r *pb.Review) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text := strings.ToLower(strings.TrimSpace(r.GetText()))
+	for _, other := range s.reviews {
+		if other.GetId() != r.GetId() && other.GetProductId() == r.GetProductId() &&
+			other.GetStatus() != pb.Review_REJECTED &&
+			strings.ToLower(strings.TrimSpace(other.GetText())) == text {
+			return true
+		}
+	}
+	return false
+}
+
+type reviewService struct {
+	pb.UnimplementedReviewServiceServer
+
+	catalog   *productCatalog
+	store     *reviewStore
+	moderator *moderator
+}
+
+// SubmitReview stores a review as PENDING and queues it for moderation.
+func (s *reviewService) SubmitReview(ctx context.Context, req *pb.SubmitReviewRequest) (*pb.Review, error) {
+	if err := validateReview(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if _, err := s.catalog.GetProduct(ctx, &pb.GetProductRequest{Id: req.GetProductId()}); err != nil {
+		return nil, err
+	}
+
+	r := &pb.Review{
+		Id:         uuid.NewString(),
+		ProductId:  req.GetProductId(),
+		UserId:     req.GetUserId(),
+		AuthorName: strings.TrimSpace(req.GetAuthorName()),
+		Rating:     req.GetRating(),
+		Text:       strings.TrimSpace(req.GetText()),
+		Status:     pb.Review_PENDING,
+		CreatedAt:  timestamppb.New(time.Now()),
+	}
+	s.store.add(r)
+	if err := s.moderator.enqueue(ctx, r.GetId()); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "could not queue review for moderation: %v", err)
+	}
+	log.WithContext(ctx).Infof("[SubmitReview] review %s for product %s queued for moderation", r.GetId(), r.GetProductId())
+	return r, nil
+}
+
+func validateReview(req *pb.SubmitReviewRequest) error {
+	switch {
+	case req.GetProductId() == "":
+		return errors.New("product_id is required")
+	case strings.TrimSpace(req.GetAuthorName()) == "":
+		return errors.New("author_name is required")
+	case req.GetRating() < 1 || req.GetRating() > 5:
+		return errors.New("rating must be between 1 and 5")
+	case strings.TrimSpace(req.GetText()) == "":
+		return errors.New("text is required")
+	case len(req.GetText()) > maxReviewLength:
+		return errors.New("text is too long")
+	}
+	return nil
+}
+
+// GetReview returns a review in any state, so that its author can follow
+// moderation.
+func (s *reviewService) GetReview(ctx context.Context, req *pb.GetReviewRequest) (*pb.Review, error) {
+	r, err := s.store.get(req.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "no review with ID %s", req.GetId())
+	}
+	return r, nil
+}
+
+// ListReviews returns the approved reviews of a product and the summary of
+// their ratings.
+func (s *reviewService) ListReviews(ctx context.Context, req *pb.ListReviewsRequest) (*pb.ListReviewsResponse, error) {
+	if req.GetProductId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "product_id is required")
+	}
+	return &pb.ListReviewsResponse{
+		Reviews: s.store.list(req.GetProductId(), pb.Review_APPROVED),
+		Rating:  s.store.rating(req.GetProductId()),
+	}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcerrors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/logging"
+)
+
+var info = &grpc.UnaryServerInfo{FullMethod: "/hipstershop.TestService/Do"}
+
+func TestUnaryServerInterceptorRecoversPanics(t *testing.T) {
+	intercept := UnaryServerInterceptor(logging.New("test"))
+	_, err := intercept(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		var m map[string]int
+		m["boom"]++
+		return nil, nil
+	})
+	c := Classify(err)
+	if c.Code != codes.Internal || c.Reason != ReasonPanic || c.Retryable || c.Fault != FaultSystem {
+		t.Errorf("Classify(panic) = %+v, want terminal system Internal with reason %s", c, ReasonPanic)
+	}
+	if msg := status.Convert(err).Message(); msg != "internal error" {
+		t.Errorf("panic message = %q, want the panic value kept out of the response", msg)
+	}
+}
+
+func TestUnaryServerInterceptorAnnotatesErrors(t *testing.T) {
+	intercept := UnaryServerInterceptor(logging.New("test"))
+	for _, tt := range []struct {
+		name      string
+		err       error
+		want      Classification
+		wantDelay bool
+	}{
+		{
+			name: "user error",
+			err:  status.Error(codes.InvalidArgument, "bad currency"),
+			want: Classification{Code: codes.InvalidArgument, Reason: "InvalidArgument", Fault: FaultUser},
+		},
+		{
+			name:      "transient system error",
+			err:       status.Error(codes.Unavailable, "payment is down"),
+			want:      Classification{Code: codes.Unavailable, Reason: "Unavailable", Retryable: true, Fault: FaultSystem, RetryDelay: retryDelay},
+			wantDelay: true,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("oops"),
+			want: Classification{Code: codes.Unknown, Reason: "Unknown", Fault: FaultSystem},
+		},
+		{
+			name: "explicit reason",
+			err:  Errorf(codes.FailedPrecondition, "CART_EMPTY", "cart is empty"),
+			want: Classification{Code: codes.FailedPrecondition, Reason: "CART_EMPTY", Fault: FaultUser},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := intercept(context.Background(), nil, info, func(context.Context, any) (any, error) {
+				return nil, tt.err
+			})
+			if got := Classify(err); got != tt.want {
+				t.Errorf("Classify = %+v, want %+v", got, tt.want)
+			}
+			if n := len(status.Convert(err).Details()); (n == 2) != tt.wantDelay {
+				t.Errorf("got %d details, want RetryInfo only for retryable errors", n)
+			}
+		})
+	}
+}
+
+func TestClassifyWithoutDetails(t *testing.T) {
+	if c := Classify(nil); c.Code != codes.OK || c.Reason != "" {
+		t.Errorf("Classify(nil) = %+v, want OK", c)
+	}
+	// Errors from services that do not use this package are classified by
+	// code alone.
+	c := Classify(status.Error(codes.ResourceExhausted, "quota"))
+	if !c.Retryable || c.Fault != FaultUser {
+		t.Errorf("Classify(ResourceExhausted) = %+v, want retryable user error", c)
+	}
+}
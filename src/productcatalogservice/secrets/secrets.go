@@ -0,0 +1,174 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets resolves secret references such as database passwords and
+// API keys against environment variables, files, HashiCorp Vault or Google
+// Cloud Secret Manager, and caches the values until their lease runs out.
+//
+// A reference is a URI whose scheme picks the backend:
+//
+//	env://DB_PASSWORD
+//	file:///var/run/secrets/db/password
+//	vault://secret/data/checkoutservice#db_password
+//	gcpsm://projects/my-project/secrets/db-password/versions/latest
+//
+// Services read a setting with Manager.Value, which resolves the reference in
+// <KEY>_SECRET when it is set and falls back to the plain <KEY> variable, so
+// existing deployments that pass values in the environment keep working.
+//
+// This package is duplicated in every Go service that needs it since they do
+// not share packages.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/logging"
+)
+
+// DefaultTTL is how long values are cached when the backend does not say,
+// unless SECRETS_CACHE_TTL is set.
+const DefaultTTL = 5 * time.Minute
+
+// ErrNotFound is returned for references that do not resolve to a value.
+var ErrNotFound = errors.New("secret not found")
+
+// backend fetches secrets of one scheme. path is the reference without its
+// scheme. A zero ttl means the backend has no lease of its own.
+type backend interface {
+	fetch(ctx context.Context, path string) (value string, ttl time.Duration, err error)
+}
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// Manager resolves references and caches their values.
+type Manager struct {
+	log      *logging.Logger
+	ttl      time.Duration
+	backends map[string]backend
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+// FromEnv returns a Manager with every backend. Vault is configured with
+// VAULT_ADDR and either VAULT_TOKEN, VAULT_TOKEN_FILE or, in Kubernetes,
+// VAULT_ROLE; Secret Manager uses the application default credentials.
+func FromEnv(log *logging.Logger) (*Manager, error) {
+	ttl := DefaultTTL
+	if v := os.Getenv("SECRETS_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SECRETS_CACHE_TTL %q", v)
+		}
+		ttl = d
+	}
+	return newManager(log, ttl, map[string]backend{
+		"env":   envBackend{},
+		"file":  fileBackend{},
+		"vault": newVaultFromEnv(),
+		"gcpsm": &secretManagerBackend{},
+	}), nil
+}
+
+func newManager(log *logging.Logger, ttl time.Duration, backends map[string]backend) *Manager {
+	return &Manager{
+		log:      log,
+		ttl:      ttl,
+		backends: backends,
+		now:      time.Now,
+		cache:    make(map[string]entry),
+	}
+}
+
+// Get returns the value of ref, from the cache while its lease lasts. When a
+// refresh fails, the last known value is returned for up to another lease so
+// that a backend outage does not take the service down with it.
+func (m *Manager) Get(ctx context.Context, ref string) (string, error) {
+	scheme, path, ok := strings.Cut(ref, "://")
+	if !ok {
+		return "", fmt.Errorf("secret reference %q has no scheme", ref)
+	}
+	b, ok := m.backends[scheme]
+	if !ok {
+		return "", fmt.Errorf("secret reference %q: unknown scheme %q", ref, scheme)
+	}
+
+	m.mu.Lock()
+	cached, hit := m.cache[ref]
+	m.mu.Unlock()
+	now := m.now()
+	if hit && now.Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	value, ttl, err := b.fetch(ctx, path)
+	if err != nil {
+		if hit && now.Before(cached.expires.Add(m.ttl)) {
+			m.log.Warnf("failed to refresh secret %s, using cached value: %v", ref, err)
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("failed to read secret %s: %w", ref, err)
+	}
+	if ttl <= 0 || ttl > m.ttl {
+		ttl = m.ttl
+	}
+	m.mu.Lock()
+	m.cache[ref] = entry{value: value, expires: now.Add(ttl)}
+	m.mu.Unlock()
+	return value, nil
+}
+
+// Value returns the setting key: the secret referenced by <key>_SECRET if set,
+// or else the value of the environment variable key, which may be empty.
+func (m *Manager) Value(ctx context.Context, key string) (string, error) {
+	if ref := os.Getenv(key + "_SECRET"); ref != "" {
+		return m.Get(ctx, ref)
+	}
+	return os.Getenv(key), nil
+}
+
+type envBackend struct{}
+
+func (envBackend) fetch(_ context.Context, name string) (string, time.Duration, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", 0, fmt.Errorf("environment variable %s: %w", name, ErrNotFound)
+	}
+	return v, 0, nil
+}
+
+// fileBackend reads files such as mounted Kubernetes Secrets, which the
+// kubelet updates in place when the Secret changes.
+type fileBackend struct{}
+
+func (fileBackend) fetch(_ context.Context, path string) (string, time.Duration, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", 0, fmt.Errorf("%s: %w", path, ErrNotFound)
+	} else if err != nil {
+		return "", 0, err
+	}
+	return strings.TrimRight(string(b), "\r\n"), 0, nil
+}
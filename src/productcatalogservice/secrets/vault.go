@@ -0,0 +1,219 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default location of the service account token used to log in to Vault
+// with the Kubernetes auth method.
+const defaultServiceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// vaultBackend reads secrets over the Vault HTTP API. A reference path is the
+// API path of the secret followed by '#' and the field to return; both KV
+// version 1 and version 2 engines are supported.
+type vaultBackend struct {
+	addr      string
+	token     string
+	tokenFile string
+	role      string
+	authPath  string
+	jwtFile   string
+	client    *http.Client
+
+	mu       sync.Mutex
+	login    string
+	loginExp time.Time
+	// leases holds the data read from each path, so that the fields of one
+	// dynamic secret (e.g. a database username and password) come from the
+	// same lease.
+	leases map[string]vaultLease
+}
+
+type vaultLease struct {
+	data    map[string]any
+	ttl     time.Duration
+	expires time.Time
+}
+
+func newVaultFromEnv() *vaultBackend {
+	return &vaultBackend{
+		addr:      strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		tokenFile: os.Getenv("VAULT_TOKEN_FILE"),
+		role:      os.Getenv("VAULT_ROLE"),
+		authPath:  envOr("VAULT_AUTH_PATH", "kubernetes"),
+		jwtFile:   envOr("VAULT_SERVICE_ACCOUNT_TOKEN", defaultServiceAccountToken),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+type vaultResponse struct {
+	LeaseDuration int             `json:"lease_duration"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+func (v *vaultBackend) fetch(ctx context.Context, ref string) (string, time.Duration, error) {
+	if v.addr == "" {
+		return "", 0, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", 0, fmt.Errorf("vault reference %q has no #field", ref)
+	}
+	lease, err := v.read(ctx, path)
+	if err != nil {
+		return "", 0, err
+	}
+	value, ok := lease.data[field].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("vault %s: field %s: %w", path, field, ErrNotFound)
+	}
+	return value, lease.ttl, nil
+}
+
+// read returns the data at path, reusing the previous read while its lease
+// lasts.
+func (v *vaultBackend) read(ctx context.Context, path string) (vaultLease, error) {
+	v.mu.Lock()
+	lease, ok := v.leases[path]
+	v.mu.Unlock()
+	if ok && time.Now().Before(lease.expires) {
+		return lease, nil
+	}
+
+	token, err := v.clientToken(ctx)
+	if err != nil {
+		return vaultLease{}, err
+	}
+	resp, err := v.do(ctx, http.MethodGet, path, token, nil)
+	if err != nil {
+		return vaultLease{}, err
+	}
+	var data map[string]any
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return vaultLease{}, fmt.Errorf("vault %s: unexpected response: %w", path, err)
+	}
+	// KV version 2 nests the secret under data.data.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = inner
+		}
+	}
+	lease = vaultLease{data: data, ttl: time.Duration(resp.LeaseDuration) * time.Second}
+	if lease.ttl > 0 {
+		lease.expires = time.Now().Add(lease.ttl)
+		v.mu.Lock()
+		if v.leases == nil {
+			v.leases = make(map[string]vaultLease)
+		}
+		v.leases[path] = lease
+		v.mu.Unlock()
+	}
+	return lease, nil
+}
+
+// clientToken returns the token to authenticate with: VAULT_TOKEN, the
+// contents of VAULT_TOKEN_FILE, or a token obtained by logging in with the
+// pod's service account as VAULT_ROLE, renewed when its lease ends.
+func (v *vaultBackend) clientToken(ctx context.Context) (string, error) {
+	switch {
+	case v.token != "":
+		return v.token, nil
+	case v.tokenFile != "":
+		b, err := os.ReadFile(v.tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read VAULT_TOKEN_FILE: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	case v.role == "":
+		return "", fmt.Errorf("no Vault credentials: set VAULT_TOKEN, VAULT_TOKEN_FILE or VAULT_ROLE")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.login != "" && time.Now().Before(v.loginExp) {
+		return v.login, nil
+	}
+	jwt, err := os.ReadFile(v.jwtFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+	body, _ := json.Marshal(map[string]string{"role": v.role, "jwt": strings.TrimSpace(string(jwt))})
+	resp, err := v.do(ctx, http.MethodPost, "auth/"+v.authPath+"/login", "", body)
+	if err != nil {
+		return "", err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault login as %s returned no token", v.role)
+	}
+	lease := time.Duration(resp.Auth.LeaseDuration) * time.Second
+	v.login = resp.Auth.ClientToken
+	// Log in again a little before the token expires.
+	v.loginExp = time.Now().Add(lease - lease/10)
+	return v.login, nil
+}
+
+func (v *vaultBackend) do(ctx context.Context, method, path, token string, body []byte) (*vaultResponse, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+"/v1/"+strings.TrimLeft(path, "/"), r)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	var out vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("vault %s: unexpected response: %w", path, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("vault %s: %w", path, ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("vault %s: %s %s", path, resp.Status, strings.Join(out.Errors, "; "))
+	}
+	return &out, nil
+}
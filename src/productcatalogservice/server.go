@@ -21,28 +21,44 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/audit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/dedup"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/genproto"
-	"google.golang.org/grpc/credentials/insecure"
-	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/grpcconfig"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/healthcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/lifecycle"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/productcatalogservice/secrets"
 
 	"cloud.google.com/go/profiler"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
 )
 
 var (
 	catalogMutex *sync.Mutex
-	log          *logrus.Logger
+	// catalogErr is the result of the last catalog load, guarded by
+	// catalogMutex.
+	catalogErr   error
+	log          *logging.Logger
+	peerCreds    *mtls.Credentials
+	grpcSettings *grpcconfig.Config
+	admit        *admission.Controller
+	secretStore  *secrets.Manager
 	extraLatency time.Duration
 
 	port = "3550"
@@ -51,28 +67,32 @@ var (
 )
 
 func init() {
-	log = logrus.New()
-	log.Formatter = &logrus.JSONFormatter{
-		FieldMap: logrus.FieldMap{
-			logrus.FieldKeyTime:  "timestamp",
-			logrus.FieldKeyLevel: "severity",
-			logrus.FieldKeyMsg:   "message",
-		},
-		TimestampFormat: time.RFC3339Nano,
-	}
-	log.Out = os.Stdout
+	log = logging.New("productcatalogservice")
 	catalogMutex = &sync.Mutex{}
 }
 
 func main() {
-	if os.Getenv("ENABLE_TRACING") == "1" {
-		err := initTracing()
-		if err != nil {
-			log.Warnf("warn: failed to start tracer: %+v", err)
+	if err := validateConfig(); err != nil {
+		if configcheck.FailFast() {
+			log.Fatal(err)
 		}
+		log.Warn(err)
+	}
+
+	if os.Getenv("ENABLE_TRACING") == "1" {
+		log.Info("Tracing enabled.")
 	} else {
 		log.Info("Tracing disabled.")
 	}
+	tel, err := instrumentation.Init(context.Background(), "productcatalogservice", os.Getenv("ENABLE_TRACING") == "1")
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	if err := tel.ServeMetrics(instrumentation.MetricsAddr()); err != nil {
+		log.Fatal(err)
+	}
+	life := lifecycle.New(log)
+	life.OnClose("telemetry", tel.Shutdown)
 
 	if os.Getenv("DISABLE_PROFILER") == "" {
 		log.Info("Profiling enabled.")
@@ -81,6 +101,48 @@ func main() {
 		log.Info("Profiling disabled.")
 	}
 
+	auditLog, err := audit.FromEnv("productcatalogservice", log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	life.OnClose("audit log", auditLog.Close)
+
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		log.Info("Debug endpoints enabled.")
+		levelHandler := auditLog.HTTPHandler("logging.SetLevel", logging.LevelHandler())
+		if err := instrumentation.ServeDebug(instrumentation.DebugAddr(), instrumentation.DebugHandler(levelHandler, auditLog.Handler())); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Info("Debug endpoints disabled.")
+	}
+
+	creds, err := mtls.FromEnv(context.Background(), log)
+	if err != nil {
+		log.Fatalf("failed to set up mTLS: %v", err)
+	}
+	peerCreds = creds
+	log.Infof("mTLS mode: %s", peerCreds.Mode())
+
+	settings, err := grpcconfig.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcSettings = settings
+	log.Infof("gRPC settings: %s", grpcSettings)
+
+	admit, err = admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Load shedding: %s", admit)
+	go admit.Run(life.Context())
+
+	secretStore, err = secrets.FromEnv(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	flag.Parse()
 
 	// set injected latency
@@ -100,7 +162,7 @@ func main() {
 	go func() {
 		for {
 			sig := <-sigs
-			log.Printf("Received signal: %s", sig)
+			log.Infof("Received signal: %s", sig)
 			if sig == syscall.SIGUSR1 {
 				reloadCatalog = true
 				log.Infof("Enable catalog reloading")
@@ -108,6 +170,14 @@ func main() {
 				reloadCatalog = false
 				log.Infof("Disable catalog reloading")
 			}
+			if err := auditLog.Record(context.Background(), audit.Entry{
+				Actor:     "signal",
+				Operation: "catalog.SetReloading",
+				Details:   map[string]string{"signal": sig.String(), "reload_catalog": strconv.FormatBool(reloadCatalog)},
+				Outcome:   "OK",
+			}); err != nil {
+				log.Errorf("failed to audit catalog reloading change: %v", err)
+			}
 		}
 	}()
 
@@ -115,11 +185,11 @@ func main() {
 		port = os.Getenv("PORT")
 	}
 	log.Infof("starting grpc server at :%s", port)
-	run(port)
-	select {}
+	run(life, port)
+	life.Wait()
 }
 
-func run(port string) string {
+func run(life *lifecycle.Manager, port string) string {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		log.Fatal(err)
@@ -130,9 +200,18 @@ func run(port string) string {
 		propagation.NewCompositeTextMapPropagator(
 			propagation.TraceContext{}, propagation.Baggage{}))
 	var srv *grpc.Server
-	srv = grpc.NewServer(
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()))
+	// catalog reads do not depend on the caller, so identical ones are
+	// coalesced even without an idempotency key
+	dedupInterceptor := dedup.UnaryServerInterceptor(
+		pb.ProductCatalogService_ListProducts_FullMethodName,
+		pb.ProductCatalogService_GetProduct_FullMethodName,
+		pb.ProductCatalogService_SearchProducts_FullMethodName)
+	srv = grpc.NewServer(append([]grpc.ServerOption{
+		peerCreds.ServerOption(),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(peerCreds.UnaryServerInterceptor(), requestid.UnaryServerInterceptor(), admit.UnaryServerInterceptor(admission.Browse), dedupInterceptor, rpcerrors.UnaryServerInterceptor(log)),
+		grpc.ChainStreamInterceptor(peerCreds.StreamServerInterceptor(), requestid.StreamServerInterceptor(), admit.StreamServerInterceptor(admission.Browse), rpcerrors.StreamServerInterceptor(log)),
+	}, grpcSettings.ServerOptions()...)...)
 
 	svc := &productCatalog{}
 	err = loadCatalog(&svc.catalog)
@@ -140,8 +219,25 @@ func run(port string) string {
 		log.Fatalf("could not parse product catalog: %v", err)
 	}
 
+	reviews := newReviewStore()
+	reviewSvc := &reviewService{
+		catalog:   svc,
+		store:     reviews,
+		moderator: newModerator(reviews, moderationClassifiersFromEnv(reviews)...),
+	}
+	go reviewSvc.moderator.run(life.Context())
+
 	pb.RegisterProductCatalogServiceServer(srv, svc)
-	healthpb.RegisterHealthServer(srv, svc)
+	pb.RegisterReviewServiceServer(srv, reviewSvc)
+	health := healthcheck.New(log,
+		pb.ProductCatalogService_ServiceDesc.ServiceName,
+		pb.ReviewService_ServiceDesc.ServiceName)
+	health.Add("catalog", checkCatalog)
+	health.Register(srv)
+	grpcSettings.RegisterDiagnostics(srv)
+	go health.Run(life.Context())
+	life.OnUnready(health.Shutdown)
+	life.OnDrain("grpc server", lifecycle.GRPCServer(srv))
 	go srv.Serve(listener)
 
 	return listener.Addr().String()
@@ -151,30 +247,6 @@ func initStats() {
 	// TODO(drewbr) Implement OpenTelemetry stats
 }
 
-func initTracing() error {
-	var (
-		collectorAddr string
-		collectorConn *grpc.ClientConn
-	)
-
-	ctx := context.Background()
-
-	mustMapEnv(&collectorAddr, "COLLECTOR_SERVICE_ADDR")
-	mustConnGRPC(ctx, &collectorConn, collectorAddr)
-
-	exporter, err := otlptracegrpc.New(
-		ctx,
-		otlptracegrpc.WithGRPCConn(collectorConn))
-	if err != nil {
-		log.Warnf("warn: Failed to create trace exporter: %v", err)
-	}
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()))
-	otel.SetTracerProvider(tp)
-	return err
-}
-
 func initProfiling(service, version string) {
 	for i := 1; i <= 3; i++ {
 		if err := profiler.Start(profiler.Config{
@@ -207,10 +279,12 @@ func mustConnGRPC(ctx context.Context, conn **grpc.ClientConn, addr string) {
 	var err error
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
-	*conn, err = grpc.DialContext(ctx, addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()))
+	*conn, err = grpc.DialContext(ctx, addr, append([]grpc.DialOption{
+		peerCreds.DialOption(addr),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(requestid.UnaryClientInterceptor(), admission.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(requestid.StreamClientInterceptor(), admission.StreamClientInterceptor()),
+	}, grpcSettings.DialOptions()...)...)
 	if err != nil {
 		panic(errors.Wrapf(err, "grpc: failed to connect %s", addr))
 	}
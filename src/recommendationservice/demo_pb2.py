@@ -27,95 +27,173 @@ from google.protobuf import symbol_database as _symbol_database
 _sym_db = _symbol_database.Default()
 
 
+from google.protobuf import timestamp_pb2 as google_dot_protobuf_dot_timestamp__pb2
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\ndemo.proto\x12\x0bhipstershop\"0\n\x08\x43\x61rtItem\x12\x12\n\nproduct_id\x18\x01 \x01(\t\x12\x10\n\x08quantity\x18\x02 \x01(\x05\"F\n\x0e\x41\x64\x64ItemRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12#\n\x04item\x18\x02 \x01(\x0b\x32\x15.hipstershop.CartItem\"#\n\x10\x45mptyCartRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\"!\n\x0eGetCartRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\"=\n\x04\x43\x61rt\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"\x07\n\x05\x45mpty\"B\n\x1aListRecommendationsRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12\x13\n\x0bproduct_ids\x18\x02 \x03(\t\"2\n\x1bListRecommendationsResponse\x12\x13\n\x0bproduct_ids\x18\x01 \x03(\t\"\x84\x01\n\x07Product\x12\n\n\x02id\x18\x01 \x01(\t\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x03 \x01(\t\x12\x0f\n\x07picture\x18\x04 \x01(\t\x12%\n\tprice_usd\x18\x05 \x01(\x0b\x32\x12.hipstershop.Money\x12\x12\n\ncategories\x18\x06 \x03(\t\">\n\x14ListProductsResponse\x12&\n\x08products\x18\x01 \x03(\x0b\x32\x14.hipstershop.Product\"\x1f\n\x11GetProductRequest\x12\n\n\x02id\x18\x01 \x01(\t\"&\n\x15SearchProductsRequest\x12\r\n\x05query\x18\x01 \x01(\t\"?\n\x16SearchProductsResponse\x12%\n\x07results\x18\x01 \x03(\x0b\x32\x14.hipstershop.Product\"^\n\x0fGetQuoteRequest\x12%\n\x07\x61\x64\x64ress\x18\x01 \x01(\x0b\x32\x14.hipstershop.Address\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"8\n\x10GetQuoteResponse\x12$\n\x08\x63ost_usd\x18\x01 \x01(\x0b\x32\x12.hipstershop.Money\"_\n\x10ShipOrderRequest\x12%\n\x07\x61\x64\x64ress\x18\x01 \x01(\x0b\x32\x14.hipstershop.Address\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"(\n\x11ShipOrderResponse\x12\x13\n\x0btracking_id\x18\x01 \x01(\t\"a\n\x07\x41\x64\x64ress\x12\x16\n\x0estreet_address\x18\x01 \x01(\t\x12\x0c\n\x04\x63ity\x18\x02 \x01(\t\x12\r\n\x05state\x18\x03 \x01(\t\x12\x0f\n\x07\x63ountry\x18\x04 \x01(\t\x12\x10\n\x08zip_code\x18\x05 \x01(\x05\"<\n\x05Money\x12\x15\n\rcurrency_code\x18\x01 \x01(\t\x12\r\n\x05units\x18\x02 \x01(\x03\x12\r\n\x05nanos\x18\x03 \x01(\x05\"8\n\x1eGetSupportedCurrenciesResponse\x12\x16\n\x0e\x63urrency_codes\x18\x01 \x03(\t\"N\n\x19\x43urrencyConversionRequest\x12 \n\x04\x66rom\x18\x01 \x01(\x0b\x32\x12.hipstershop.Money\x12\x0f\n\x07to_code\x18\x02 \x01(\t\"\x90\x01\n\x0e\x43reditCardInfo\x12\x1a\n\x12\x63redit_card_number\x18\x01 \x01(\t\x12\x17\n\x0f\x63redit_card_cvv\x18\x02 \x01(\x05\x12#\n\x1b\x63redit_card_expiration_year\x18\x03 \x01(\x05\x12$\n\x1c\x63redit_card_expiration_month\x18\x04 \x01(\x05\"e\n\rChargeRequest\x12\"\n\x06\x61mount\x18\x01 \x01(\x0b\x32\x12.hipstershop.Money\x12\x30\n\x0b\x63redit_card\x18\x02 \x01(\x0b\x32\x1b.hipstershop.CreditCardInfo\"(\n\x0e\x43hargeResponse\x12\x16\n\x0etransaction_id\x18\x01 \x01(\t\"R\n\tOrderItem\x12#\n\x04item\x18\x01 \x01(\x0b\x32\x15.hipstershop.CartItem\x12 \n\x04\x63ost\x18\x02 \x01(\x0b\x32\x12.hipstershop.Money\"\xbf\x01\n\x0bOrderResult\x12\x10\n\x08order_id\x18\x01 \x01(\t\x12\x1c\n\x14shipping_tracking_id\x18\x02 \x01(\t\x12)\n\rshipping_cost\x18\x03 \x01(\x0b\x32\x12.hipstershop.Money\x12.\n\x10shipping_address\x18\x04 \x01(\x0b\x32\x14.hipstershop.Address\x12%\n\x05items\x18\x05 \x03(\x0b\x32\x16.hipstershop.OrderItem\"V\n\x1cSendOrderConfirmationRequest\x12\r\n\x05\x65mail\x18\x01 \x01(\t\x12\'\n\x05order\x18\x02 \x01(\x0b\x32\x18.hipstershop.OrderResult\"\xa3\x01\n\x11PlaceOrderRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12\x15\n\ruser_currency\x18\x02 \x01(\t\x12%\n\x07\x61\x64\x64ress\x18\x03 \x01(\x0b\x32\x14.hipstershop.Address\x12\r\n\x05\x65mail\x18\x05 \x01(\t\x12\x30\n\x0b\x63redit_card\x18\x06 \x01(\x0b\x32\x1b.hipstershop.CreditCardInfo\"=\n\x12PlaceOrderResponse\x12\'\n\x05order\x18\x01 \x01(\x0b\x32\x18.hipstershop.OrderResult\"!\n\tAdRequest\x12\x14\n\x0c\x63ontext_keys\x18\x01 \x03(\t\"*\n\nAdResponse\x12\x1c\n\x03\x61\x64s\x18\x01 \x03(\x0b\x32\x0f.hipstershop.Ad\"(\n\x02\x41\x64\x12\x14\n\x0credirect_url\x18\x01 \x01(\t\x12\x0c\n\x04text\x18\x02 \x01(\t2\xca\x01\n\x0b\x43\x61rtService\x12<\n\x07\x41\x64\x64Item\x12\x1b.hipstershop.AddItemRequest\x1a\x12.hipstershop.Empty\"\x00\x12;\n\x07GetCart\x12\x1b.hipstershop.GetCartRequest\x1a\x11.hipstershop.Cart\"\x00\x12@\n\tEmptyCart\x12\x1d.hipstershop.EmptyCartRequest\x1a\x12.hipstershop.Empty\"\x00\x32\x83\x01\n\x15RecommendationService\x12j\n\x13ListRecommendations\x12\'.hipstershop.ListRecommendationsRequest\x1a(.hipstershop.ListRecommendationsResponse\"\x00\x32\x83\x02\n\x15ProductCatalogService\x12G\n\x0cListProducts\x12\x12.hipstershop.Empty\x1a!.hipstershop.ListProductsResponse\"\x00\x12\x44\n\nGetProduct\x12\x1e.hipstershop.GetProductRequest\x1a\x14.hipstershop.Product\"\x00\x12[\n\x0eSearchProducts\x12\".hipstershop.SearchProductsRequest\x1a#.hipstershop.SearchProductsResponse\"\x00\x32\xaa\x01\n\x0fShippingService\x12I\n\x08GetQuote\x12\x1c.hipstershop.GetQuoteRequest\x1a\x1d.hipstershop.GetQuoteResponse\"\x00\x12L\n\tShipOrder\x12\x1d.hipstershop.ShipOrderRequest\x1a\x1e.hipstershop.ShipOrderResponse\"\x00\x32\xb7\x01\n\x0f\x43urrencyService\x12[\n\x16GetSupportedCurrencies\x12\x12.hipstershop.Empty\x1a+.hipstershop.GetSupportedCurrenciesResponse\"\x00\x12G\n\x07\x43onvert\x12&.hipstershop.CurrencyConversionRequest\x1a\x12.hipstershop.Money\"\x00\x32U\n\x0ePaymentService\x12\x43\n\x06\x43harge\x12\x1a.hipstershop.ChargeRequest\x1a\x1b.hipstershop.ChargeResponse\"\x00\x32h\n\x0c\x45mailService\x12X\n\x15SendOrderConfirmation\x12).hipstershop.SendOrderConfirmationRequest\x1a\x12.hipstershop.Empty\"\x00\x32\x62\n\x0f\x43heckoutService\x12O\n\nPlaceOrder\x12\x1e.hipstershop.PlaceOrderRequest\x1a\x1f.hipstershop.PlaceOrderResponse\"\x00\x32H\n\tAdService\x12;\n\x06GetAds\x12\x16.hipstershop.AdRequest\x1a\x17.hipstershop.AdResponse\"\x00\x62\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\ndemo.proto\x12\x0bhipstershop\x1a\x1fgoogle/protobuf/timestamp.proto\"0\n\x08\x43\x61rtItem\x12\x12\n\nproduct_id\x18\x01 \x01(\t\x12\x10\n\x08quantity\x18\x02 \x01(\x05\"F\n\x0e\x41\x64\x64ItemRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12#\n\x04item\x18\x02 \x01(\x0b\x32\x15.hipstershop.CartItem\"#\n\x10\x45mptyCartRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\"!\n\x0eGetCartRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\"<\n\x10MergeCartRequest\x12\x14\n\x0c\x66rom_user_id\x18\x01 \x01(\t\x12\x12\n\nto_user_id\x18\x02 \x01(\t\"=\n\x04\x43\x61rt\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"\x07\n\x05\x45mpty\"B\n\x1aListRecommendationsRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12\x13\n\x0bproduct_ids\x18\x02 \x03(\t\"2\n\x1bListRecommendationsResponse\x12\x13\n\x0bproduct_ids\x18\x01 \x03(\t\"\x84\x01\n\x07Product\x12\n\n\x02id\x18\x01 \x01(\t\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x03 \x01(\t\x12\x0f\n\x07picture\x18\x04 \x01(\t\x12%\n\tprice_usd\x18\x05 \x01(\x0b\x32\x12.hipstershop.Money\x12\x12\n\ncategories\x18\x06 \x03(\t\"^\n\x13ListProductsRequest\x12\x11\n\tpage_size\x18\x01 \x01(\x05\x12\x0c\n\x04page\x18\x02 \x01(\x05\x12&\n\x04sort\x18\x03 \x01(\x0e\x32\x18.hipstershop.ProductSort\"R\n\x14ListProductsResponse\x12&\n\x08products\x18\x01 \x03(\x0b\x32\x14.hipstershop.Product\x12\x12\n\ntotal_size\x18\x02 \x01(\x05\"\x1f\n\x11GetProductRequest\x12\n\n\x02id\x18\x01 \x01(\t\"&\n\x15SearchProductsRequest\x12\r\n\x05query\x18\x01 \x01(\t\"?\n\x16SearchProductsResponse\x12%\n\x07results\x18\x01 \x03(\x0b\x32\x14.hipstershop.Product\"\xae\x02\n\x06Review\x12\n\n\x02id\x18\x01 \x01(\t\x12\x12\n\nproduct_id\x18\x02 \x01(\t\x12\x0f\n\x07user_id\x18\x03 \x01(\t\x12\x13\n\x0b\x61uthor_name\x18\x04 \x01(\t\x12\x0e\n\x06rating\x18\x05 \x01(\x05\x12\x0c\n\x04text\x18\x06 \x01(\t\x12*\n\x06status\x18\x07 \x01(\x0e\x32\x1a.hipstershop.Review.Status\x12\x19\n\x11moderation_reason\x18\x08 \x01(\t\x12.\n\ncreated_at\x18\t \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"I\n\x06Status\x12\x16\n\x12STATUS_UNSPECIFIED\x10\x00\x12\x0b\n\x07PENDING\x10\x01\x12\x0c\n\x08\x41PPROVED\x10\x02\x12\x0c\n\x08REJECTED\x10\x03\"m\n\x13SubmitReviewRequest\x12\x12\n\nproduct_id\x18\x01 \x01(\t\x12\x0f\n\x07user_id\x18\x02 \x01(\t\x12\x13\n\x0b\x61uthor_name\x18\x03 \x01(\t\x12\x0e\n\x06rating\x18\x04 \x01(\x05\x12\x0c\n\x04text\x18\x05 \x01(\t\"\x1e\n\x10GetReviewRequest\x12\n\n\x02id\x18\x01 \x01(\t\"(\n\x12ListReviewsRequest\x12\x12\n\nproduct_id\x18\x01 \x01(\t\"g\n\x13ListReviewsResponse\x12$\n\x07reviews\x18\x01 \x03(\x0b\x32\x13.hipstershop.Review\x12*\n\x06rating\x18\x02 \x01(\x0b\x32\x1a.hipstershop.RatingSummary\"=\n\rRatingSummary\x12\x14\n\x0creview_count\x18\x01 \x01(\x05\x12\x16\n\x0e\x61verage_rating\x18\x02 \x01(\x01\"^\n\x0fGetQuoteRequest\x12%\n\x07\x61\x64\x64ress\x18\x01 \x01(\x0b\x32\x14.hipstershop.Address\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"8\n\x10GetQuoteResponse\x12$\n\x08\x63ost_usd\x18\x01 \x01(\x0b\x32\x12.hipstershop.Money\"_\n\x10ShipOrderRequest\x12%\n\x07\x61\x64\x64ress\x18\x01 \x01(\x0b\x32\x14.hipstershop.Address\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"(\n\x11ShipOrderResponse\x12\x13\n\x0btracking_id\x18\x01 \x01(\t\",\n\x15\x43\x61ncelShipmentRequest\x12\x13\n\x0btracking_id\x18\x01 \x01(\t\")\n\x12GetShipmentRequest\x12\x13\n\x0btracking_id\x18\x01 \x01(\t\"\xb4\x01\n\x08Shipment\x12\x13\n\x0btracking_id\x18\x01 \x01(\t\x12+\n\x06status\x18\x02 \x01(\x0e\x32\x1b.hipstershop.ShipmentStatus\x12.\n\nshipped_at\x18\x03 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12\x36\n\x12\x65stimated_delivery\x18\x04 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"a\n\x07\x41\x64\x64ress\x12\x16\n\x0estreet_address\x18\x01 \x01(\t\x12\x0c\n\x04\x63ity\x18\x02 \x01(\t\x12\r\n\x05state\x18\x03 \x01(\t\x12\x0f\n\x07\x63ountry\x18\x04 \x01(\t\x12\x10\n\x08zip_code\x18\x05 \x01(\x05\"<\n\x05Money\x12\x15\n\rcurrency_code\x18\x01 \x01(\t\x12\r\n\x05units\x18\x02 \x01(\x03\x12\r\n\x05nanos\x18\x03 \x01(\x05\"8\n\x1eGetSupportedCurrenciesResponse\x12\x16\n\x0e\x63urrency_codes\x18\x01 \x03(\t\"N\n\x19\x43urrencyConversionRequest\x12 \n\x04\x66rom\x18\x01 \x01(\x0b\x32\x12.hipstershop.Money\x12\x0f\n\x07to_code\x18\x02 \x01(\t\"\x13\n\x11WatchRatesRequest\"\x81\x01\n\rCurrencyRates\x12\x34\n\x05rates\x18\x01 \x03(\x0b\x32%.hipstershop.CurrencyRates.RatesEntry\x12\x0c\n\x04\x66ull\x18\x02 \x01(\x08\x1a,\n\nRatesEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x01:\x02\x38\x01\"\x90\x01\n\x0e\x43reditCardInfo\x12\x1a\n\x12\x63redit_card_number\x18\x01 \x01(\t\x12\x17\n\x0f\x63redit_card_cvv\x18\x02 \x01(\x05\x12#\n\x1b\x63redit_card_expiration_year\x18\x03 \x01(\x05\x12$\n\x1c\x63redit_card_expiration_month\x18\x04 \x01(\x05\"~\n\rChargeRequest\x12\"\n\x06\x61mount\x18\x01 \x01(\x0b\x32\x12.hipstershop.Money\x12\x30\n\x0b\x63redit_card\x18\x02 \x01(\x0b\x32\x1b.hipstershop.CreditCardInfo\x12\x17\n\x0fidempotency_key\x18\x03 \x01(\t\"(\n\x0e\x43hargeResponse\x12\x16\n\x0etransaction_id\x18\x01 \x01(\t\"K\n\rRefundRequest\x12\x16\n\x0etransaction_id\x18\x01 \x01(\t\x12\"\n\x06\x61mount\x18\x02 \x01(\x0b\x32\x12.hipstershop.Money\"#\n\x0eRefundResponse\x12\x11\n\trefund_id\x18\x01 \x01(\t\"R\n\tOrderItem\x12#\n\x04item\x18\x01 \x01(\x0b\x32\x15.hipstershop.CartItem\x12 \n\x04\x63ost\x18\x02 \x01(\x0b\x32\x12.hipstershop.Money\"\xbf\x01\n\x0bOrderResult\x12\x10\n\x08order_id\x18\x01 \x01(\t\x12\x1c\n\x14shipping_tracking_id\x18\x02 \x01(\t\x12)\n\rshipping_cost\x18\x03 \x01(\x0b\x32\x12.hipstershop.Money\x12.\n\x10shipping_address\x18\x04 \x01(\x0b\x32\x14.hipstershop.Address\x12%\n\x05items\x18\x05 \x03(\x0b\x32\x16.hipstershop.OrderItem\"\x9d\x01\n\x1cSendOrderConfirmationRequest\x12\r\n\x05\x65mail\x18\x01 \x01(\t\x12\'\n\x05order\x18\x02 \x01(\x0b\x32\x18.hipstershop.OrderResult\x12\x0f\n\x07subject\x18\x03 \x01(\t\x12\x11\n\thtml_body\x18\x04 \x01(\t\x12\x11\n\ttext_body\x18\x05 \x01(\t\x12\x0e\n\x06locale\x18\x06 \x01(\t\"_\n\x17SendNotificationRequest\x12\r\n\x05\x65mail\x18\x01 \x01(\t\x12\x0f\n\x07subject\x18\x02 \x01(\t\x12\x11\n\ttext_body\x18\x03 \x01(\t\x12\x11\n\thtml_body\x18\x04 \x01(\t\"\x8f\x02\n\x11PlaceOrderRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12\x15\n\ruser_currency\x18\x02 \x01(\t\x12%\n\x07\x61\x64\x64ress\x18\x03 \x01(\x0b\x32\x14.hipstershop.Address\x12\r\n\x05\x65mail\x18\x05 \x01(\t\x12\x30\n\x0b\x63redit_card\x18\x06 \x01(\x0b\x32\x1b.hipstershop.CreditCardInfo\x12\x0e\n\x06locale\x18\x07 \x01(\t\x12-\n\x0f\x62illing_address\x18\x08 \x01(\x0b\x32\x14.hipstershop.Address\x12\x14\n\x0cgift_message\x18\t \x01(\t\x12\x15\n\rcustomer_note\x18\n \x01(\t\"=\n\x12PlaceOrderResponse\x12\'\n\x05order\x18\x01 \x01(\x0b\x32\x18.hipstershop.OrderResult\"!\n\tAdRequest\x12\x14\n\x0c\x63ontext_keys\x18\x01 \x03(\t\"*\n\nAdResponse\x12\x1c\n\x03\x61\x64s\x18\x01 \x03(\x0b\x32\x0f.hipstershop.Ad\"(\n\x02\x41\x64\x12\x14\n\x0credirect_url\x18\x01 \x01(\t\x12\x0c\n\x04text\x18\x02 \x01(\t\"\xe9\x03\n\x0cSubscription\x12\n\n\x02id\x18\x01 \x01(\t\x12\x0f\n\x07user_id\x18\x02 \x01(\t\x12\r\n\x05\x65mail\x18\x03 \x01(\t\x12$\n\x05items\x18\x04 \x03(\x0b\x32\x15.hipstershop.CartItem\x12\x15\n\rinterval_days\x18\x05 \x01(\x05\x12%\n\x07\x61\x64\x64ress\x18\x06 \x01(\x0b\x32\x14.hipstershop.Address\x12\x15\n\ruser_currency\x18\x07 \x01(\t\x12\x15\n\rpayment_token\x18\x08 \x01(\t\x12\x30\n\x06status\x18\t \x01(\x0e\x32 .hipstershop.Subscription.Status\x12,\n\x08next_run\x18\n \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12\x17\n\x0f\x66\x61iled_attempts\x18\x0b \x01(\x05\x12\x15\n\rlast_order_id\x18\x0c \x01(\t\x12\x12\n\nlast_error\x18\r \x01(\t\x12.\n\ncreated_at\x18\x0e \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"G\n\x06Status\x12\x16\n\x12STATUS_UNSPECIFIED\x10\x00\x12\n\n\x06\x41\x43TIVE\x10\x01\x12\n\n\x06PAUSED\x10\x02\x12\r\n\tCANCELLED\x10\x03\"\x97\x02\n\x19\x43reateSubscriptionRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\x12\r\n\x05\x65mail\x18\x02 \x01(\t\x12$\n\x05items\x18\x03 \x03(\x0b\x32\x15.hipstershop.CartItem\x12\x15\n\rinterval_days\x18\x04 \x01(\x05\x12%\n\x07\x61\x64\x64ress\x18\x05 \x01(\x0b\x32\x14.hipstershop.Address\x12\x15\n\ruser_currency\x18\x06 \x01(\t\x12\x30\n\x0b\x63redit_card\x18\x07 \x01(\x0b\x32\x1b.hipstershop.CreditCardInfo\x12-\n\tfirst_run\x18\x08 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"$\n\x16GetSubscriptionRequest\x12\n\n\x02id\x18\x01 \x01(\t\"+\n\x18ListSubscriptionsRequest\x12\x0f\n\x07user_id\x18\x01 \x01(\t\"M\n\x19ListSubscriptionsResponse\x12\x30\n\rsubscriptions\x18\x01 \x03(\x0b\x32\x19.hipstershop.Subscription\"\xa8\x01\n\x17\x42\x61\x63kInStockSubscription\x12\n\n\x02id\x18\x01 \x01(\t\x12\x12\n\nproduct_id\x18\x02 \x01(\t\x12\r\n\x05\x65mail\x18\x03 \x01(\t\x12.\n\ncreated_at\x18\x04 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12.\n\nexpires_at\x18\x05 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"@\n\x1bSubscribeBackInStockRequest\x12\x12\n\nproduct_id\x18\x01 \x01(\t\x12\r\n\x05\x65mail\x18\x02 \x01(\t\",\n\x16GetAvailabilityRequest\x12\x12\n\nproduct_id\x18\x01 \x01(\t\"C\n\x0c\x41vailability\x12\x12\n\nproduct_id\x18\x01 \x01(\t\x12\r\n\x05known\x18\x02 \x01(\x08\x12\x10\n\x08quantity\x18\x03 \x01(\x05\"g\n\x0eInventoryEvent\x12\x12\n\nproduct_id\x18\x01 \x01(\t\x12\x10\n\x08quantity\x18\x02 \x01(\x05\x12/\n\x0boccurred_at\x18\x03 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"M\n\x13ReserveStockRequest\x12\x10\n\x08order_id\x18\x01 \x01(\t\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\"z\n\x10StockReservation\x12\x10\n\x08order_id\x18\x01 \x01(\t\x12$\n\x05items\x18\x02 \x03(\x0b\x32\x15.hipstershop.CartItem\x12.\n\nexpires_at\x18\x03 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\"&\n\x12ReservationRequest\x12\x10\n\x08order_id\x18\x01 \x01(\t*{\n\x0bProductSort\x12\x1c\n\x18PRODUCT_SORT_UNSPECIFIED\x10\x00\x12\x15\n\x11PRODUCT_SORT_NAME\x10\x01\x12\x1a\n\x16PRODUCT_SORT_PRICE_ASC\x10\x02\x12\x1b\n\x17PRODUCT_SORT_PRICE_DESC\x10\x03*\xd8\x01\n\x0eShipmentStatus\x12\x1f\n\x1bSHIPMENT_STATUS_UNSPECIFIED\x10\x00\x12!\n\x1dSHIPMENT_STATUS_LABEL_CREATED\x10\x01\x12\x1e\n\x1aSHIPMENT_STATUS_IN_TRANSIT\x10\x02\x12$\n SHIPMENT_STATUS_OUT_FOR_DELIVERY\x10\x03\x12\x1d\n\x19SHIPMENT_STATUS_DELIVERED\x10\x04\x12\x1d\n\x19SHIPMENT_STATUS_CANCELLED\x10\x05\x32\x8b\x02\n\x0b\x43\x61rtService\x12<\n\x07\x41\x64\x64Item\x12\x1b.hipstershop.AddItemRequest\x1a\x12.hipstershop.Empty\"\x00\x12;\n\x07GetCart\x12\x1b.hipstershop.GetCartRequest\x1a\x11.hipstershop.Cart\"\x00\x12@\n\tEmptyCart\x12\x1d.hipstershop.EmptyCartRequest\x1a\x12.hipstershop.Empty\"\x00\x12?\n\tMergeCart\x12\x1d.hipstershop.MergeCartRequest\x1a\x11.hipstershop.Cart\"\x00\x32\x83\x01\n\x15RecommendationService\x12j\n\x13ListRecommendations\x12\'.hipstershop.ListRecommendationsRequest\x1a(.hipstershop.ListRecommendationsResponse\"\x00\x32\x91\x02\n\x15ProductCatalogService\x12U\n\x0cListProducts\x12 .hipstershop.ListProductsRequest\x1a!.hipstershop.ListProductsResponse\"\x00\x12\x44\n\nGetProduct\x12\x1e.hipstershop.GetProductRequest\x1a\x14.hipstershop.Product\"\x00\x12[\n\x0eSearchProducts\x12\".hipstershop.SearchProductsRequest\x1a#.hipstershop.SearchProductsResponse\"\x00\x32\xef\x01\n\rReviewService\x12G\n\x0cSubmitReview\x12 .hipstershop.SubmitReviewRequest\x1a\x13.hipstershop.Review\"\x00\x12\x41\n\tGetReview\x12\x1d.hipstershop.GetReviewRequest\x1a\x13.hipstershop.Review\"\x00\x12R\n\x0bListReviews\x12\x1f.hipstershop.ListReviewsRequest\x1a .hipstershop.ListReviewsResponse\"\x00\x32\xbf\x02\n\x0fShippingService\x12I\n\x08GetQuote\x12\x1c.hipstershop.GetQuoteRequest\x1a\x1d.hipstershop.GetQuoteResponse\"\x00\x12L\n\tShipOrder\x12\x1d.hipstershop.ShipOrderRequest\x1a\x1e.hipstershop.ShipOrderResponse\"\x00\x12J\n\x0e\x43\x61ncelShipment\x12\".hipstershop.CancelShipmentRequest\x1a\x12.hipstershop.Empty\"\x00\x12G\n\x0bGetShipment\x12\x1f.hipstershop.GetShipmentRequest\x1a\x15.hipstershop.Shipment\"\x00\x32\x85\x02\n\x0f\x43urrencyService\x12[\n\x16GetSupportedCurrencies\x12\x12.hipstershop.Empty\x1a+.hipstershop.GetSupportedCurrenciesResponse\"\x00\x12G\n\x07\x43onvert\x12&.hipstershop.CurrencyConversionRequest\x1a\x12.hipstershop.Money\"\x00\x12L\n\nWatchRates\x12\x1e.hipstershop.WatchRatesRequest\x1a\x1a.hipstershop.CurrencyRates\"\x00\x30\x01\x32\x9a\x01\n\x0ePaymentService\x12\x43\n\x06\x43harge\x12\x1a.hipstershop.ChargeRequest\x1a\x1b.hipstershop.ChargeResponse\"\x00\x12\x43\n\x06Refund\x12\x1a.hipstershop.RefundRequest\x1a\x1b.hipstershop.RefundResponse\"\x00\x32\xb8\x01\n\x0c\x45mailService\x12X\n\x15SendOrderConfirmation\x12).hipstershop.SendOrderConfirmationRequest\x1a\x12.hipstershop.Empty\"\x00\x12N\n\x10SendNotification\x12$.hipstershop.SendNotificationRequest\x1a\x12.hipstershop.Empty\"\x00\x32\x62\n\x0f\x43heckoutService\x12O\n\nPlaceOrder\x12\x1e.hipstershop.PlaceOrderRequest\x1a\x1f.hipstershop.PlaceOrderResponse\"\x00\x32H\n\tAdService\x12;\n\x06GetAds\x12\x16.hipstershop.AdRequest\x1a\x17.hipstershop.AdResponse\"\x00\x32\xb2\x04\n\x13SubscriptionService\x12Y\n\x12\x43reateSubscription\x12&.hipstershop.CreateSubscriptionRequest\x1a\x19.hipstershop.Subscription\"\x00\x12S\n\x0fGetSubscription\x12#.hipstershop.GetSubscriptionRequest\x1a\x19.hipstershop.Subscription\"\x00\x12\x64\n\x11ListSubscriptions\x12%.hipstershop.ListSubscriptionsRequest\x1a&.hipstershop.ListSubscriptionsResponse\"\x00\x12U\n\x11PauseSubscription\x12#.hipstershop.GetSubscriptionRequest\x1a\x19.hipstershop.Subscription\"\x00\x12V\n\x12ResumeSubscription\x12#.hipstershop.GetSubscriptionRequest\x1a\x19.hipstershop.Subscription\"\x00\x12V\n\x12\x43\x61ncelSubscription\x12#.hipstershop.GetSubscriptionRequest\x1a\x19.hipstershop.Subscription\"\x00\x32\xa0\x02\n\x13NotificationService\x12h\n\x14SubscribeBackInStock\x12(.hipstershop.SubscribeBackInStockRequest\x1a$.hipstershop.BackInStockSubscription\"\x00\x12S\n\x0fGetAvailability\x12#.hipstershop.GetAvailabilityRequest\x1a\x19.hipstershop.Availability\"\x00\x12J\n\x15PublishInventoryEvent\x12\x1b.hipstershop.InventoryEvent\x1a\x12.hipstershop.Empty\"\x00\x32\xff\x01\n\x10InventoryService\x12Q\n\x0cReserveStock\x12 .hipstershop.ReserveStockRequest\x1a\x1d.hipstershop.StockReservation\"\x00\x12K\n\x12\x43onfirmReservation\x12\x1f.hipstershop.ReservationRequest\x1a\x12.hipstershop.Empty\"\x00\x12K\n\x12ReleaseReservation\x12\x1f.hipstershop.ReservationRequest\x1a\x12.hipstershop.Empty\"\x00\x42?Z=github.com/GoogleCloudPlatform/microservices-demo/hipstershopb\x06proto3')
 
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, globals())
 _builder.BuildTopDescriptorsAndMessages(DESCRIPTOR, 'demo_pb2', globals())
 if _descriptor._USE_C_DESCRIPTORS == False:
 
   DESCRIPTOR._options = None
-  _CARTITEM._serialized_start=27
-  _CARTITEM._serialized_end=75
-  _ADDITEMREQUEST._serialized_start=77
-  _ADDITEMREQUEST._serialized_end=147
-  _EMPTYCARTREQUEST._serialized_start=149
-  _EMPTYCARTREQUEST._serialized_end=184
-  _GETCARTREQUEST._serialized_start=186
-  _GETCARTREQUEST._serialized_end=219
-  _CART._serialized_start=221
-  _CART._serialized_end=282
-  _EMPTY._serialized_start=284
-  _EMPTY._serialized_end=291
-  _LISTRECOMMENDATIONSREQUEST._serialized_start=293
-  _LISTRECOMMENDATIONSREQUEST._serialized_end=359
-  _LISTRECOMMENDATIONSRESPONSE._serialized_start=361
-  _LISTRECOMMENDATIONSRESPONSE._serialized_end=411
-  _PRODUCT._serialized_start=414
-  _PRODUCT._serialized_end=546
-  _LISTPRODUCTSRESPONSE._serialized_start=548
-  _LISTPRODUCTSRESPONSE._serialized_end=610
-  _GETPRODUCTREQUEST._serialized_start=612
-  _GETPRODUCTREQUEST._serialized_end=643
-  _SEARCHPRODUCTSREQUEST._serialized_start=645
-  _SEARCHPRODUCTSREQUEST._serialized_end=683
-  _SEARCHPRODUCTSRESPONSE._serialized_start=685
-  _SEARCHPRODUCTSRESPONSE._serialized_end=748
-  _GETQUOTEREQUEST._serialized_start=750
-  _GETQUOTEREQUEST._serialized_end=844
-  _GETQUOTERESPONSE._serialized_start=846
-  _GETQUOTERESPONSE._serialized_end=902
-  _SHIPORDERREQUEST._serialized_start=904
-  _SHIPORDERREQUEST._serialized_end=999
-  _SHIPORDERRESPONSE._serialized_start=1001
-  _SHIPORDERRESPONSE._serialized_end=1041
-  _ADDRESS._serialized_start=1043
-  _ADDRESS._serialized_end=1140
-  _MONEY._serialized_start=1142
-  _MONEY._serialized_end=1202
-  _GETSUPPORTEDCURRENCIESRESPONSE._serialized_start=1204
-  _GETSUPPORTEDCURRENCIESRESPONSE._serialized_end=1260
-  _CURRENCYCONVERSIONREQUEST._serialized_start=1262
-  _CURRENCYCONVERSIONREQUEST._serialized_end=1340
-  _CREDITCARDINFO._serialized_start=1343
-  _CREDITCARDINFO._serialized_end=1487
-  _CHARGEREQUEST._serialized_start=1489
-  _CHARGEREQUEST._serialized_end=1590
-  _CHARGERESPONSE._serialized_start=1592
-  _CHARGERESPONSE._serialized_end=1632
-  _ORDERITEM._serialized_start=1634
-  _ORDERITEM._serialized_end=1716
-  _ORDERRESULT._serialized_start=1719
-  _ORDERRESULT._serialized_end=1910
-  _SENDORDERCONFIRMATIONREQUEST._serialized_start=1912
-  _SENDORDERCONFIRMATIONREQUEST._serialized_end=1998
-  _PLACEORDERREQUEST._serialized_start=2001
-  _PLACEORDERREQUEST._serialized_end=2164
-  _PLACEORDERRESPONSE._serialized_start=2166
-  _PLACEORDERRESPONSE._serialized_end=2227
-  _ADREQUEST._serialized_start=2229
-  _ADREQUEST._serialized_end=2262
-  _ADRESPONSE._serialized_start=2264
-  _ADRESPONSE._serialized_end=2306
-  _AD._serialized_start=2308
-  _AD._serialized_end=2348
-  _CARTSERVICE._serialized_start=2351
-  _CARTSERVICE._serialized_end=2553
-  _RECOMMENDATIONSERVICE._serialized_start=2556
-  _RECOMMENDATIONSERVICE._serialized_end=2687
-  _PRODUCTCATALOGSERVICE._serialized_start=2690
-  _PRODUCTCATALOGSERVICE._serialized_end=2949
-  _SHIPPINGSERVICE._serialized_start=2952
-  _SHIPPINGSERVICE._serialized_end=3122
-  _CURRENCYSERVICE._serialized_start=3125
-  _CURRENCYSERVICE._serialized_end=3308
-  _PAYMENTSERVICE._serialized_start=3310
-  _PAYMENTSERVICE._serialized_end=3395
-  _EMAILSERVICE._serialized_start=3397
-  _EMAILSERVICE._serialized_end=3501
-  _CHECKOUTSERVICE._serialized_start=3503
-  _CHECKOUTSERVICE._serialized_end=3601
-  _ADSERVICE._serialized_start=3603
-  _ADSERVICE._serialized_end=3675
+  DESCRIPTOR._serialized_options = b'Z=github.com/GoogleCloudPlatform/microservices-demo/hipstershop'
+  _CARTITEM._serialized_start=60
+  _CARTITEM._serialized_end=108
+  _ADDITEMREQUEST._serialized_start=110
+  _ADDITEMREQUEST._serialized_end=180
+  _EMPTYCARTREQUEST._serialized_start=182
+  _EMPTYCARTREQUEST._serialized_end=217
+  _GETCARTREQUEST._serialized_start=219
+  _GETCARTREQUEST._serialized_end=252
+  _MERGECARTREQUEST._serialized_start=254
+  _MERGECARTREQUEST._serialized_end=314
+  _CART._serialized_start=316
+  _CART._serialized_end=377
+  _EMPTY._serialized_start=379
+  _EMPTY._serialized_end=386
+  _LISTRECOMMENDATIONSREQUEST._serialized_start=388
+  _LISTRECOMMENDATIONSREQUEST._serialized_end=454
+  _LISTRECOMMENDATIONSRESPONSE._serialized_start=456
+  _LISTRECOMMENDATIONSRESPONSE._serialized_end=506
+  _PRODUCT._serialized_start=509
+  _PRODUCT._serialized_end=641
+  _LISTPRODUCTSREQUEST._serialized_start=643
+  _LISTPRODUCTSREQUEST._serialized_end=737
+  _LISTPRODUCTSRESPONSE._serialized_start=739
+  _LISTPRODUCTSRESPONSE._serialized_end=821
+  _GETPRODUCTREQUEST._serialized_start=823
+  _GETPRODUCTREQUEST._serialized_end=854
+  _SEARCHPRODUCTSREQUEST._serialized_start=856
+  _SEARCHPRODUCTSREQUEST._serialized_end=894
+  _SEARCHPRODUCTSRESPONSE._serialized_start=896
+  _SEARCHPRODUCTSRESPONSE._serialized_end=959
+  _REVIEW._serialized_start=962
+  _REVIEW._serialized_end=1264
+  _REVIEW_STATUS._serialized_start=1191
+  _REVIEW_STATUS._serialized_end=1264
+  _SUBMITREVIEWREQUEST._serialized_start=1266
+  _SUBMITREVIEWREQUEST._serialized_end=1375
+  _GETREVIEWREQUEST._serialized_start=1377
+  _GETREVIEWREQUEST._serialized_end=1407
+  _LISTREVIEWSREQUEST._serialized_start=1409
+  _LISTREVIEWSREQUEST._serialized_end=1449
+  _LISTREVIEWSRESPONSE._serialized_start=1451
+  _LISTREVIEWSRESPONSE._serialized_end=1554
+  _RATINGSUMMARY._serialized_start=1556
+  _RATINGSUMMARY._serialized_end=1617
+  _GETQUOTEREQUEST._serialized_start=1619
+  _GETQUOTEREQUEST._serialized_end=1713
+  _GETQUOTERESPONSE._serialized_start=1715
+  _GETQUOTERESPONSE._serialized_end=1771
+  _SHIPORDERREQUEST._serialized_start=1773
+  _SHIPORDERREQUEST._serialized_end=1868
+  _SHIPORDERRESPONSE._serialized_start=1870
+  _SHIPORDERRESPONSE._serialized_end=1910
+  _CANCELSHIPMENTREQUEST._serialized_start=1912
+  _CANCELSHIPMENTREQUEST._serialized_end=1956
+  _GETSHIPMENTREQUEST._serialized_start=1958
+  _GETSHIPMENTREQUEST._serialized_end=1999
+  _SHIPMENT._serialized_start=2002
+  _SHIPMENT._serialized_end=2182
+  _ADDRESS._serialized_start=2184
+  _ADDRESS._serialized_end=2281
+  _MONEY._serialized_start=2283
+  _MONEY._serialized_end=2343
+  _GETSUPPORTEDCURRENCIESRESPONSE._serialized_start=2345
+  _GETSUPPORTEDCURRENCIESRESPONSE._serialized_end=2401
+  _CURRENCYCONVERSIONREQUEST._serialized_start=2403
+  _CURRENCYCONVERSIONREQUEST._serialized_end=2481
+  _WATCHRATESREQUEST._serialized_start=2483
+  _WATCHRATESREQUEST._serialized_end=2502
+  _CURRENCYRATES._serialized_start=2505
+  _CURRENCYRATES._serialized_end=2634
+  _CURRENCYRATES_RATESENTRY._serialized_start=2590
+  _CURRENCYRATES_RATESENTRY._serialized_end=2634
+  _CREDITCARDINFO._serialized_start=2637
+  _CREDITCARDINFO._serialized_end=2781
+  _CHARGEREQUEST._serialized_start=2783
+  _CHARGEREQUEST._serialized_end=2909
+  _CHARGERESPONSE._serialized_start=2911
+  _CHARGERESPONSE._serialized_end=2951
+  _REFUNDREQUEST._serialized_start=2953
+  _REFUNDREQUEST._serialized_end=3028
+  _REFUNDRESPONSE._serialized_start=3030
+  _REFUNDRESPONSE._serialized_end=3065
+  _ORDERITEM._serialized_start=3067
+  _ORDERITEM._serialized_end=3149
+  _ORDERRESULT._serialized_start=3152
+  _ORDERRESULT._serialized_end=3343
+  _SENDORDERCONFIRMATIONREQUEST._serialized_start=3346
+  _SENDORDERCONFIRMATIONREQUEST._serialized_end=3503
+  _SENDNOTIFICATIONREQUEST._serialized_start=3505
+  _SENDNOTIFICATIONREQUEST._serialized_end=3600
+  _PLACEORDERREQUEST._serialized_start=3603
+  _PLACEORDERREQUEST._serialized_end=3874
+  _PLACEORDERRESPONSE._serialized_start=3876
+  _PLACEORDERRESPONSE._serialized_end=3937
+  _ADREQUEST._serialized_start=3939
+  _ADREQUEST._serialized_end=3972
+  _ADRESPONSE._serialized_start=3974
+  _ADRESPONSE._serialized_end=4016
+  _AD._serialized_start=4018
+  _AD._serialized_end=4058
+  _SUBSCRIPTION._serialized_start=4061
+  _SUBSCRIPTION._serialized_end=4550
+  _SUBSCRIPTION_STATUS._serialized_start=4479
+  _SUBSCRIPTION_STATUS._serialized_end=4550
+  _CREATESUBSCRIPTIONREQUEST._serialized_start=4553
+  _CREATESUBSCRIPTIONREQUEST._serialized_end=4832
+  _GETSUBSCRIPTIONREQUEST._serialized_start=4834
+  _GETSUBSCRIPTIONREQUEST._serialized_end=4870
+  _LISTSUBSCRIPTIONSREQUEST._serialized_start=4872
+  _LISTSUBSCRIPTIONSREQUEST._serialized_end=4915
+  _LISTSUBSCRIPTIONSRESPONSE._serialized_start=4917
+  _LISTSUBSCRIPTIONSRESPONSE._serialized_end=4994
+  _BACKINSTOCKSUBSCRIPTION._serialized_start=4997
+  _BACKINSTOCKSUBSCRIPTION._serialized_end=5165
+  _SUBSCRIBEBACKINSTOCKREQUEST._serialized_start=5167
+  _SUBSCRIBEBACKINSTOCKREQUEST._serialized_end=5231
+  _GETAVAILABILITYREQUEST._serialized_start=5233
+  _GETAVAILABILITYREQUEST._serialized_end=5277
+  _AVAILABILITY._serialized_start=5279
+  _AVAILABILITY._serialized_end=5346
+  _INVENTORYEVENT._serialized_start=5348
+  _INVENTORYEVENT._serialized_end=5451
+  _RESERVESTOCKREQUEST._serialized_start=5453
+  _RESERVESTOCKREQUEST._serialized_end=5530
+  _STOCKRESERVATION._serialized_start=5532
+  _STOCKRESERVATION._serialized_end=5654
+  _RESERVATIONREQUEST._serialized_start=5656
+  _RESERVATIONREQUEST._serialized_end=5694
+  _PRODUCTSORT._serialized_start=5696
+  _PRODUCTSORT._serialized_end=5819
+  _SHIPMENTSTATUS._serialized_start=5822
+  _SHIPMENTSTATUS._serialized_end=6038
+  _CARTSERVICE._serialized_start=6041
+  _CARTSERVICE._serialized_end=6308
+  _RECOMMENDATIONSERVICE._serialized_start=6311
+  _RECOMMENDATIONSERVICE._serialized_end=6442
+  _PRODUCTCATALOGSERVICE._serialized_start=6445
+  _PRODUCTCATALOGSERVICE._serialized_end=6718
+  _REVIEWSERVICE._serialized_start=6721
+  _REVIEWSERVICE._serialized_end=6960
+  _SHIPPINGSERVICE._serialized_start=6963
+  _SHIPPINGSERVICE._serialized_end=7282
+  _CURRENCYSERVICE._serialized_start=7285
+  _CURRENCYSERVICE._serialized_end=7546
+  _PAYMENTSERVICE._serialized_start=7549
+  _PAYMENTSERVICE._serialized_end=7703
+  _EMAILSERVICE._serialized_start=7706
+  _EMAILSERVICE._serialized_end=7890
+  _CHECKOUTSERVICE._serialized_start=7892
+  _CHECKOUTSERVICE._serialized_end=7990
+  _ADSERVICE._serialized_start=7992
+  _ADSERVICE._serialized_end=8064
+  _SUBSCRIPTIONSERVICE._serialized_start=8067
+  _SUBSCRIPTIONSERVICE._serialized_end=8629
+  _NOTIFICATIONSERVICE._serialized_start=8632
+  _NOTIFICATIONSERVICE._serialized_end=8920
+  _INVENTORYSERVICE._serialized_start=8923
+  _INVENTORYSERVICE._serialized_end=9178
 # @@protoc_insertion_point(module_scope)
@@ -47,6 +47,11 @@ class CartServiceStub(object):
                 request_serializer=demo__pb2.EmptyCartRequest.SerializeToString,
                 response_deserializer=demo__pb2.Empty.FromString,
                 )
+        self.MergeCart = channel.unary_unary(
+                '/hipstershop.CartService/MergeCart',
+                request_serializer=demo__pb2.MergeCartRequest.SerializeToString,
+                response_deserializer=demo__pb2.Cart.FromString,
+                )
 
 
 class CartServiceServicer(object):
@@ -72,6 +77,15 @@ class CartServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def MergeCart(self, request, context):
+        """MergeCart moves the items in the cart of one user into the cart of
+        another, adding up the quantities of the products in both, empties the
+        former and returns the latter.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_CartServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -90,6 +104,11 @@ def add_CartServiceServicer_to_server(servicer, server):
                     request_deserializer=demo__pb2.EmptyCartRequest.FromString,
                     response_serializer=demo__pb2.Empty.SerializeToString,
             ),
+            'MergeCart': grpc.unary_unary_rpc_method_handler(
+                    servicer.MergeCart,
+                    request_deserializer=demo__pb2.MergeCartRequest.FromString,
+                    response_serializer=demo__pb2.Cart.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'hipstershop.CartService', rpc_method_handlers)
@@ -153,6 +172,23 @@ class CartService(object):
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
+    @staticmethod
+    def MergeCart(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.CartService/MergeCart',
+            demo__pb2.MergeCartRequest.SerializeToString,
+            demo__pb2.Cart.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
 
 class RecommendationServiceStub(object):
     """---------------Recommendation service----------
@@ -234,7 +270,7 @@ class ProductCatalogServiceStub(object):
         """
         self.ListProducts = channel.unary_unary(
                 '/hipstershop.ProductCatalogService/ListProducts',
-                request_serializer=demo__pb2.Empty.SerializeToString,
+                request_serializer=demo__pb2.ListProductsRequest.SerializeToString,
                 response_deserializer=demo__pb2.ListProductsResponse.FromString,
                 )
         self.GetProduct = channel.unary_unary(
@@ -277,7 +313,7 @@ def add_ProductCatalogServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
             'ListProducts': grpc.unary_unary_rpc_method_handler(
                     servicer.ListProducts,
-                    request_deserializer=demo__pb2.Empty.FromString,
+                    request_deserializer=demo__pb2.ListProductsRequest.FromString,
                     response_serializer=demo__pb2.ListProductsResponse.SerializeToString,
             ),
             'GetProduct': grpc.unary_unary_rpc_method_handler(
@@ -314,7 +350,7 @@ class ProductCatalogService(object):
             timeout=None,
             metadata=None):
         return grpc.experimental.unary_unary(request, target, '/hipstershop.ProductCatalogService/ListProducts',
-            demo__pb2.Empty.SerializeToString,
+            demo__pb2.ListProductsRequest.SerializeToString,
             demo__pb2.ListProductsResponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
@@ -354,6 +390,145 @@ class ProductCatalogService(object):
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
 
+class ReviewServiceStub(object):
+    """---------------Product reviews----------------
+
+    ReviewService is served by productcatalogservice. Submitted reviews are
+    moderated asynchronously; only approved reviews are ever listed.
+    """
+
+    def __init__(self, channel):
+        """Constructor.
+
+        Args:
+            channel: A grpc.Channel.
+        """
+        self.SubmitReview = channel.unary_unary(
+                '/hipstershop.ReviewService/SubmitReview',
+                request_serializer=demo__pb2.SubmitReviewRequest.SerializeToString,
+                response_deserializer=demo__pb2.Review.FromString,
+                )
+        self.GetReview = channel.unary_unary(
+                '/hipstershop.ReviewService/GetReview',
+                request_serializer=demo__pb2.GetReviewRequest.SerializeToString,
+                response_deserializer=demo__pb2.Review.FromString,
+                )
+        self.ListReviews = channel.unary_unary(
+                '/hipstershop.ReviewService/ListReviews',
+                request_serializer=demo__pb2.ListReviewsRequest.SerializeToString,
+                response_deserializer=demo__pb2.ListReviewsResponse.FromString,
+                )
+
+
+class ReviewServiceServicer(object):
+    """---------------Product reviews----------------
+
+    ReviewService is served by productcatalogservice. Submitted reviews are
+    moderated asynchronously; only approved reviews are ever listed.
+    """
+
+    def SubmitReview(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetReview(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ListReviews(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+
+def add_ReviewServiceServicer_to_server(servicer, server):
+    rpc_method_handlers = {
+            'SubmitReview': grpc.unary_unary_rpc_method_handler(
+                    servicer.SubmitReview,
+                    request_deserializer=demo__pb2.SubmitReviewRequest.FromString,
+                    response_serializer=demo__pb2.Review.SerializeToString,
+            ),
+            'GetReview': grpc.unary_unary_rpc_method_handler(
+                    servicer.GetReview,
+                    request_deserializer=demo__pb2.GetReviewRequest.FromString,
+                    response_serializer=demo__pb2.Review.SerializeToString,
+            ),
+            'ListReviews': grpc.unary_unary_rpc_method_handler(
+                    servicer.ListReviews,
+                    request_deserializer=demo__pb2.ListReviewsRequest.FromString,
+                    response_serializer=demo__pb2.ListReviewsResponse.SerializeToString,
+            ),
+    }
+    generic_handler = grpc.method_handlers_generic_handler(
+            'hipstershop.ReviewService', rpc_method_handlers)
+    server.add_generic_rpc_handlers((generic_handler,))
+
+
+ # This class is part of an EXPERIMENTAL API.
+class ReviewService(object):
+    """---------------Product reviews----------------
+
+    ReviewService is served by productcatalogservice. Submitted reviews are
+    moderated asynchronously; only approved reviews are ever listed.
+    """
+
+    @staticmethod
+    def SubmitReview(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.ReviewService/SubmitReview',
+            demo__pb2.SubmitReviewRequest.SerializeToString,
+            demo__pb2.Review.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetReview(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.ReviewService/GetReview',
+            demo__pb2.GetReviewRequest.SerializeToString,
+            demo__pb2.Review.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ListReviews(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.ReviewService/ListReviews',
+            demo__pb2.ListReviewsRequest.SerializeToString,
+            demo__pb2.ListReviewsResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+
 class ShippingServiceStub(object):
     """---------------Shipping Service----------
 
@@ -375,6 +550,16 @@ class ShippingServiceStub(object):
                 request_serializer=demo__pb2.ShipOrderRequest.SerializeToString,
                 response_deserializer=demo__pb2.ShipOrderResponse.FromString,
                 )
+        self.CancelShipment = channel.unary_unary(
+                '/hipstershop.ShippingService/CancelShipment',
+                request_serializer=demo__pb2.CancelShipmentRequest.SerializeToString,
+                response_deserializer=demo__pb2.Empty.FromString,
+                )
+        self.GetShipment = channel.unary_unary(
+                '/hipstershop.ShippingService/GetShipment',
+                request_serializer=demo__pb2.GetShipmentRequest.SerializeToString,
+                response_deserializer=demo__pb2.Shipment.FromString,
+                )
 
 
 class ShippingServiceServicer(object):
@@ -394,6 +579,22 @@ class ShippingServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def CancelShipment(self, request, context):
+        """CancelShipment cancels a shipment that ShipOrder created. Cancelling a
+        cancelled shipment succeeds.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetShipment(self, request, context):
+        """GetShipment returns where a shipment that ShipOrder created is on its
+        way.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_ShippingServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -407,6 +608,16 @@ def add_ShippingServiceServicer_to_server(servicer, server):
                     request_deserializer=demo__pb2.ShipOrderRequest.FromString,
                     response_serializer=demo__pb2.ShipOrderResponse.SerializeToString,
             ),
+            'CancelShipment': grpc.unary_unary_rpc_method_handler(
+                    servicer.CancelShipment,
+                    request_deserializer=demo__pb2.CancelShipmentRequest.FromString,
+                    response_serializer=demo__pb2.Empty.SerializeToString,
+            ),
+            'GetShipment': grpc.unary_unary_rpc_method_handler(
+                    servicer.GetShipment,
+                    request_deserializer=demo__pb2.GetShipmentRequest.FromString,
+                    response_serializer=demo__pb2.Shipment.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'hipstershop.ShippingService', rpc_method_handlers)
@@ -453,6 +664,40 @@ class ShippingService(object):
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
+    @staticmethod
+    def CancelShipment(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.ShippingService/CancelShipment',
+            demo__pb2.CancelShipmentRequest.SerializeToString,
+            demo__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetShipment(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.ShippingService/GetShipment',
+            demo__pb2.GetShipmentRequest.SerializeToString,
+            demo__pb2.Shipment.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
 
 class CurrencyServiceStub(object):
     """-----------------Currency service-----------------
@@ -475,6 +720,11 @@ class CurrencyServiceStub(object):
                 request_serializer=demo__pb2.CurrencyConversionRequest.SerializeToString,
                 response_deserializer=demo__pb2.Money.FromString,
                 )
+        self.WatchRates = channel.unary_stream(
+                '/hipstershop.CurrencyService/WatchRates',
+                request_serializer=demo__pb2.WatchRatesRequest.SerializeToString,
+                response_deserializer=demo__pb2.CurrencyRates.FromString,
+                )
 
 
 class CurrencyServiceServicer(object):
@@ -494,6 +744,14 @@ class CurrencyServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def WatchRates(self, request, context):
+        """WatchRates streams the exchange rates: every rate first, then the
+        rates that changed whenever some do, with empty heartbeats in between.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_CurrencyServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -507,6 +765,11 @@ def add_CurrencyServiceServicer_to_server(servicer, server):
                     request_deserializer=demo__pb2.CurrencyConversionRequest.FromString,
                     response_serializer=demo__pb2.Money.SerializeToString,
             ),
+            'WatchRates': grpc.unary_stream_rpc_method_handler(
+                    servicer.WatchRates,
+                    request_deserializer=demo__pb2.WatchRatesRequest.FromString,
+                    response_serializer=demo__pb2.CurrencyRates.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'hipstershop.CurrencyService', rpc_method_handlers)
@@ -553,6 +816,23 @@ class CurrencyService(object):
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
+    @staticmethod
+    def WatchRates(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/hipstershop.CurrencyService/WatchRates',
+            demo__pb2.WatchRatesRequest.SerializeToString,
+            demo__pb2.CurrencyRates.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
 
 class PaymentServiceStub(object):
     """-------------Payment service-----------------
@@ -570,6 +850,11 @@ class PaymentServiceStub(object):
                 request_serializer=demo__pb2.ChargeRequest.SerializeToString,
                 response_deserializer=demo__pb2.ChargeResponse.FromString,
                 )
+        self.Refund = channel.unary_unary(
+                '/hipstershop.PaymentService/Refund',
+                request_serializer=demo__pb2.RefundRequest.SerializeToString,
+                response_deserializer=demo__pb2.RefundResponse.FromString,
+                )
 
 
 class PaymentServiceServicer(object):
@@ -583,6 +868,14 @@ class PaymentServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def Refund(self, request, context):
+        """Refund refunds a charge in full. Refunding a refunded charge returns
+        the same refund.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_PaymentServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -591,6 +884,11 @@ def add_PaymentServiceServicer_to_server(servicer, server):
                     request_deserializer=demo__pb2.ChargeRequest.FromString,
                     response_serializer=demo__pb2.ChargeResponse.SerializeToString,
             ),
+            'Refund': grpc.unary_unary_rpc_method_handler(
+                    servicer.Refund,
+                    request_deserializer=demo__pb2.RefundRequest.FromString,
+                    response_serializer=demo__pb2.RefundResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'hipstershop.PaymentService', rpc_method_handlers)
@@ -620,6 +918,23 @@ class PaymentService(object):
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
+    @staticmethod
+    def Refund(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.PaymentService/Refund',
+            demo__pb2.RefundRequest.SerializeToString,
+            demo__pb2.RefundResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
 
 class EmailServiceStub(object):
     """-------------Email service-----------------
@@ -637,6 +952,11 @@ class EmailServiceStub(object):
                 request_serializer=demo__pb2.SendOrderConfirmationRequest.SerializeToString,
                 response_deserializer=demo__pb2.Empty.FromString,
                 )
+        self.SendNotification = channel.unary_unary(
+                '/hipstershop.EmailService/SendNotification',
+                request_serializer=demo__pb2.SendNotificationRequest.SerializeToString,
+                response_deserializer=demo__pb2.Empty.FromString,
+                )
 
 
 class EmailServiceServicer(object):
@@ -650,6 +970,12 @@ class EmailServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def SendNotification(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_EmailServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -658,6 +984,11 @@ def add_EmailServiceServicer_to_server(servicer, server):
                     request_deserializer=demo__pb2.SendOrderConfirmationRequest.FromString,
                     response_serializer=demo__pb2.Empty.SerializeToString,
             ),
+            'SendNotification': grpc.unary_unary_rpc_method_handler(
+                    servicer.SendNotification,
+                    request_deserializer=demo__pb2.SendNotificationRequest.FromString,
+                    response_serializer=demo__pb2.Empty.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'hipstershop.EmailService', rpc_method_handlers)
@@ -687,6 +1018,23 @@ class EmailService(object):
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
 
+    @staticmethod
+    def SendNotification(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.EmailService/SendNotification',
+            demo__pb2.SendNotificationRequest.SerializeToString,
+            demo__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
 
 class CheckoutServiceStub(object):
     """-------------Checkout service-----------------
@@ -820,3 +1168,521 @@ class AdService(object):
             demo__pb2.AdResponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+
+class SubscriptionServiceStub(object):
+    """------------Subscription service------------------
+
+    """
+
+    def __init__(self, channel):
+        """Constructor.
+
+        Args:
+            channel: A grpc.Channel.
+        """
+        self.CreateSubscription = channel.unary_unary(
+                '/hipstershop.SubscriptionService/CreateSubscription',
+                request_serializer=demo__pb2.CreateSubscriptionRequest.SerializeToString,
+                response_deserializer=demo__pb2.Subscription.FromString,
+                )
+        self.GetSubscription = channel.unary_unary(
+                '/hipstershop.SubscriptionService/GetSubscription',
+                request_serializer=demo__pb2.GetSubscriptionRequest.SerializeToString,
+                response_deserializer=demo__pb2.Subscription.FromString,
+                )
+        self.ListSubscriptions = channel.unary_unary(
+                '/hipstershop.SubscriptionService/ListSubscriptions',
+                request_serializer=demo__pb2.ListSubscriptionsRequest.SerializeToString,
+                response_deserializer=demo__pb2.ListSubscriptionsResponse.FromString,
+                )
+        self.PauseSubscription = channel.unary_unary(
+                '/hipstershop.SubscriptionService/PauseSubscription',
+                request_serializer=demo__pb2.GetSubscriptionRequest.SerializeToString,
+                response_deserializer=demo__pb2.Subscription.FromString,
+                )
+        self.ResumeSubscription = channel.unary_unary(
+                '/hipstershop.SubscriptionService/ResumeSubscription',
+                request_serializer=demo__pb2.GetSubscriptionRequest.SerializeToString,
+                response_deserializer=demo__pb2.Subscription.FromString,
+                )
+        self.CancelSubscription = channel.unary_unary(
+                '/hipstershop.SubscriptionService/CancelSubscription',
+                request_serializer=demo__pb2.GetSubscriptionRequest.SerializeToString,
+                response_deserializer=demo__pb2.Subscription.FromString,
+                )
+
+
+class SubscriptionServiceServicer(object):
+    """------------Subscription service------------------
+
+    """
+
+    def CreateSubscription(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetSubscription(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ListSubscriptions(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PauseSubscription(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ResumeSubscription(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def CancelSubscription(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+
+def add_SubscriptionServiceServicer_to_server(servicer, server):
+    rpc_method_handlers = {
+            'CreateSubscription': grpc.unary_unary_rpc_method_handler(
+                    servicer.CreateSubscription,
+                    request_deserializer=demo__pb2.CreateSubscriptionRequest.FromString,
+                    response_serializer=demo__pb2.Subscription.SerializeToString,
+            ),
+            'GetSubscription': grpc.unary_unary_rpc_method_handler(
+                    servicer.GetSubscription,
+                    request_deserializer=demo__pb2.GetSubscriptionRequest.FromString,
+                    response_serializer=demo__pb2.Subscription.SerializeToString,
+            ),
+            'ListSubscriptions': grpc.unary_unary_rpc_method_handler(
+                    servicer.ListSubscriptions,
+                    request_deserializer=demo__pb2.ListSubscriptionsRequest.FromString,
+                    response_serializer=demo__pb2.ListSubscriptionsResponse.SerializeToString,
+            ),
+            'PauseSubscription': grpc.unary_unary_rpc_method_handler(
+                    servicer.PauseSubscription,
+                    request_deserializer=demo__pb2.GetSubscriptionRequest.FromString,
+                    response_serializer=demo__pb2.Subscription.SerializeToString,
+            ),
+            'ResumeSubscription': grpc.unary_unary_rpc_method_handler(
+                    servicer.ResumeSubscription,
+                    request_deserializer=demo__pb2.GetSubscriptionRequest.FromString,
+                    response_serializer=demo__pb2.Subscription.SerializeToString,
+            ),
+            'CancelSubscription': grpc.unary_unary_rpc_method_handler(
+                    servicer.CancelSubscription,
+                    request_deserializer=demo__pb2.GetSubscriptionRequest.FromString,
+                    response_serializer=demo__pb2.Subscription.SerializeToString,
+            ),
+    }
+    generic_handler = grpc.method_handlers_generic_handler(
+            'hipstershop.SubscriptionService', rpc_method_handlers)
+    server.add_generic_rpc_handlers((generic_handler,))
+
+
+ # This class is part of an EXPERIMENTAL API.
+class SubscriptionService(object):
+    """------------Subscription service------------------
+
+    """
+
+    @staticmethod
+    def CreateSubscription(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.SubscriptionService/CreateSubscription',
+            demo__pb2.CreateSubscriptionRequest.SerializeToString,
+            demo__pb2.Subscription.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetSubscription(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.SubscriptionService/GetSubscription',
+            demo__pb2.GetSubscriptionRequest.SerializeToString,
+            demo__pb2.Subscription.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ListSubscriptions(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.SubscriptionService/ListSubscriptions',
+            demo__pb2.ListSubscriptionsRequest.SerializeToString,
+            demo__pb2.ListSubscriptionsResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def PauseSubscription(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.SubscriptionService/PauseSubscription',
+            demo__pb2.GetSubscriptionRequest.SerializeToString,
+            demo__pb2.Subscription.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ResumeSubscription(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.SubscriptionService/ResumeSubscription',
+            demo__pb2.GetSubscriptionRequest.SerializeToString,
+            demo__pb2.Subscription.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def CancelSubscription(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.SubscriptionService/CancelSubscription',
+            demo__pb2.GetSubscriptionRequest.SerializeToString,
+            demo__pb2.Subscription.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+
+class NotificationServiceStub(object):
+    """------------Notification service------------------
+
+    """
+
+    def __init__(self, channel):
+        """Constructor.
+
+        Args:
+            channel: A grpc.Channel.
+        """
+        self.SubscribeBackInStock = channel.unary_unary(
+                '/hipstershop.NotificationService/SubscribeBackInStock',
+                request_serializer=demo__pb2.SubscribeBackInStockRequest.SerializeToString,
+                response_deserializer=demo__pb2.BackInStockSubscription.FromString,
+                )
+        self.GetAvailability = channel.unary_unary(
+                '/hipstershop.NotificationService/GetAvailability',
+                request_serializer=demo__pb2.GetAvailabilityRequest.SerializeToString,
+                response_deserializer=demo__pb2.Availability.FromString,
+                )
+        self.PublishInventoryEvent = channel.unary_unary(
+                '/hipstershop.NotificationService/PublishInventoryEvent',
+                request_serializer=demo__pb2.InventoryEvent.SerializeToString,
+                response_deserializer=demo__pb2.Empty.FromString,
+                )
+
+
+class NotificationServiceServicer(object):
+    """------------Notification service------------------
+
+    """
+
+    def SubscribeBackInStock(self, request, context):
+        """SubscribeBackInStock asks to be emailed once an out-of-stock product is
+        restocked. Subscribing twice with the same email is a no-op.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetAvailability(self, request, context):
+        """GetAvailability returns the last stock level reported for a product.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PublishInventoryEvent(self, request, context):
+        """PublishInventoryEvent ingests stock level changes from inventory systems.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+
+def add_NotificationServiceServicer_to_server(servicer, server):
+    rpc_method_handlers = {
+            'SubscribeBackInStock': grpc.unary_unary_rpc_method_handler(
+                    servicer.SubscribeBackInStock,
+                    request_deserializer=demo__pb2.SubscribeBackInStockRequest.FromString,
+                    response_serializer=demo__pb2.BackInStockSubscription.SerializeToString,
+            ),
+            'GetAvailability': grpc.unary_unary_rpc_method_handler(
+                    servicer.GetAvailability,
+                    request_deserializer=demo__pb2.GetAvailabilityRequest.FromString,
+                    response_serializer=demo__pb2.Availability.SerializeToString,
+            ),
+            'PublishInventoryEvent': grpc.unary_unary_rpc_method_handler(
+                    servicer.PublishInventoryEvent,
+                    request_deserializer=demo__pb2.InventoryEvent.FromString,
+                    response_serializer=demo__pb2.Empty.SerializeToString,
+            ),
+    }
+    generic_handler = grpc.method_handlers_generic_handler(
+            'hipstershop.NotificationService', rpc_method_handlers)
+    server.add_generic_rpc_handlers((generic_handler,))
+
+
+ # This class is part of an EXPERIMENTAL API.
+class NotificationService(object):
+    """------------Notification service------------------
+
+    """
+
+    @staticmethod
+    def SubscribeBackInStock(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.NotificationService/SubscribeBackInStock',
+            demo__pb2.SubscribeBackInStockRequest.SerializeToString,
+            demo__pb2.BackInStockSubscription.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetAvailability(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.NotificationService/GetAvailability',
+            demo__pb2.GetAvailabilityRequest.SerializeToString,
+            demo__pb2.Availability.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def PublishInventoryEvent(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.NotificationService/PublishInventoryEvent',
+            demo__pb2.InventoryEvent.SerializeToString,
+            demo__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+
+class InventoryServiceStub(object):
+    """------------Inventory service------------------
+
+    InventoryService holds stock for orders while they are placed, so that
+    concurrent orders cannot sell more than is in stock. The notification
+    service serves it against the stock levels it is sent.
+    """
+
+    def __init__(self, channel):
+        """Constructor.
+
+        Args:
+            channel: A grpc.Channel.
+        """
+        self.ReserveStock = channel.unary_unary(
+                '/hipstershop.InventoryService/ReserveStock',
+                request_serializer=demo__pb2.ReserveStockRequest.SerializeToString,
+                response_deserializer=demo__pb2.StockReservation.FromString,
+                )
+        self.ConfirmReservation = channel.unary_unary(
+                '/hipstershop.InventoryService/ConfirmReservation',
+                request_serializer=demo__pb2.ReservationRequest.SerializeToString,
+                response_deserializer=demo__pb2.Empty.FromString,
+                )
+        self.ReleaseReservation = channel.unary_unary(
+                '/hipstershop.InventoryService/ReleaseReservation',
+                request_serializer=demo__pb2.ReservationRequest.SerializeToString,
+                response_deserializer=demo__pb2.Empty.FromString,
+                )
+
+
+class InventoryServiceServicer(object):
+    """------------Inventory service------------------
+
+    InventoryService holds stock for orders while they are placed, so that
+    concurrent orders cannot sell more than is in stock. The notification
+    service serves it against the stock levels it is sent.
+    """
+
+    def ReserveStock(self, request, context):
+        """ReserveStock holds the quantities of the items of an order, failing
+        with FailedPrecondition if a product has less stock available.
+        Reserving again for the same order returns its reservation.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ConfirmReservation(self, request, context):
+        """ConfirmReservation takes the quantities it holds out of stock, once
+        the order is recorded.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ReleaseReservation(self, request, context):
+        """ReleaseReservation gives the quantities it holds back to stock, when
+        the order fails. Releasing an unknown reservation is a no-op.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+
+def add_InventoryServiceServicer_to_server(servicer, server):
+    rpc_method_handlers = {
+            'ReserveStock': grpc.unary_unary_rpc_method_handler(
+                    servicer.ReserveStock,
+                    request_deserializer=demo__pb2.ReserveStockRequest.FromString,
+                    response_serializer=demo__pb2.StockReservation.SerializeToString,
+            ),
+            'ConfirmReservation': grpc.unary_unary_rpc_method_handler(
+                    servicer.ConfirmReservation,
+                    request_deserializer=demo__pb2.ReservationRequest.FromString,
+                    response_serializer=demo__pb2.Empty.SerializeToString,
+            ),
+            'ReleaseReservation': grpc.unary_unary_rpc_method_handler(
+                    servicer.ReleaseReservation,
+                    request_deserializer=demo__pb2.ReservationRequest.FromString,
+                    response_serializer=demo__pb2.Empty.SerializeToString,
+            ),
+    }
+    generic_handler = grpc.method_handlers_generic_handler(
+            'hipstershop.InventoryService', rpc_method_handlers)
+    server.add_generic_rpc_handlers((generic_handler,))
+
+
+ # This class is part of an EXPERIMENTAL API.
+class InventoryService(object):
+    """------------Inventory service------------------
+
+    InventoryService holds stock for orders while they are placed, so that
+    concurrent orders cannot sell more than is in stock. The notification
+    service serves it against the stock levels it is sent.
+    """
+
+    @staticmethod
+    def ReserveStock(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.InventoryService/ReserveStock',
+            demo__pb2.ReserveStockRequest.SerializeToString,
+            demo__pb2.StockReservation.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ConfirmReservation(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.InventoryService/ConfirmReservation',
+            demo__pb2.ReservationRequest.SerializeToString,
+            demo__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ReleaseReservation(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/hipstershop.InventoryService/ReleaseReservation',
+            demo__pb2.ReservationRequest.SerializeToString,
+            demo__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxAuditedBody is how much of a request body HTTPHandler records.
+const maxAuditedBody = 256
+
+// HTTPHandler records the requests to h that may change state, that is
+// anything but GET, HEAD and OPTIONS, as operation, with their method, path
+// and the start of their body as details and their status code as the
+// outcome.
+func (l *Log) HTTPHandler(operation string, h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			h.ServeHTTP(w, r)
+			return
+		}
+		var body bytes.Buffer
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, &limitedWriter{&body, maxAuditedBody}), r.Body}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+		e := Entry{
+			Actor:     r.RemoteAddr,
+			Operation: operation,
+			Details: map[string]string{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"body":   string(bytes.TrimSpace(body.Bytes())),
+			},
+			Outcome: strconv.Itoa(sw.status),
+		}
+		if err := l.Record(r.Context(), e); err != nil {
+			l.log.WithContext(r.Context()).Errorf("failed to audit %s: %v", operation, err)
+		}
+	})
+}
+
+// Handler serves the retained entries as JSON, along with whether they form
+// an unbroken chain. The after, operation, actor and limit query parameters
+// select entries as Query does; limit defaults to 100.
+//
+//	curl 'localhost:6060/debug/audit?after=10&limit=5'
+func (l *Log) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := Query{
+			Operation: r.URL.Query().Get("operation"),
+			Actor:     r.URL.Query().Get("actor"),
+			Limit:     100,
+		}
+		if v := r.URL.Query().Get("after"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "after must be a sequence number", http.StatusBadRequest)
+				return
+			}
+			q.After = n
+		}
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+		resp := struct {
+			Entries           []Entry `json:"entries"`
+			Verified          bool    `json:"verified"`
+			VerificationError string  `json:"verification_error,omitempty"`
+		}{Entries: l.Entries(q), Verified: true}
+		if resp.Entries == nil {
+			resp.Entries = []Entry{}
+		}
+		if err := l.Verify(); err != nil {
+			resp.Verified = false
+			resp.VerificationError = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// limitedWriter writes up to n bytes to w and discards the rest.
+type limitedWriter struct {
+	w *bytes.Buffer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if rest := w.n - w.w.Len(); rest > 0 {
+		if len(p) > rest {
+			w.w.Write(p[:rest])
+		} else {
+			w.w.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/redact"
+)
+
+// redactingProcessor masks personal data in the spans it passes on to next,
+// in span names, attributes, events and status descriptions.
+type redactingProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+func (p redactingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p redactingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.next.OnEnd(redactedSpan{s})
+}
+
+func (p redactingProcessor) Shutdown(ctx context.Context) error   { return p.next.Shutdown(ctx) }
+func (p redactingProcessor) ForceFlush(ctx context.Context) error { return p.next.ForceFlush(ctx) }
+
+// redactedSpan is a ReadOnlySpan whose data has personal data masked.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s redactedSpan) Name() string {
+	return redact.String(s.ReadOnlySpan.Name())
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return redactAttributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	redacted := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Name = redact.String(e.Name)
+		e.Attributes = redactAttributes(e.Attributes)
+		redacted[i] = e
+	}
+	return redacted
+}
+
+func (s redactedSpan) Status() sdktrace.Status {
+	st := s.ReadOnlySpan.Status()
+	if st.Code == codes.Error {
+		st.Description = redact.String(st.Description)
+	}
+	return st
+}
+
+// redactAttributes returns a copy of attrs with personal data masked. The
+// cohort baggage attributes are already pseudonymized and kept as they are.
+func redactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		switch {
+		case isBaggageAttribute(string(kv.Key)):
+		case kv.Value.Type() == attribute.STRING:
+			kv.Value = attribute.StringValue(redact.Value(string(kv.Key), kv.Value.AsString()))
+		case kv.Value.Type() == attribute.STRINGSLICE:
+			vs := kv.Value.AsStringSlice()
+			for j, v := range vs {
+				vs[j] = redact.Value(string(kv.Key), v)
+			}
+			kv.Value = attribute.StringSliceValue(vs)
+		case redact.SensitiveKey(string(kv.Key)):
+			kv.Value = attribute.StringValue(redact.Mask)
+		}
+		redacted[i] = kv
+	}
+	return redacted
+}
+
+func isBaggageAttribute(key string) bool {
+	for _, k := range baggageAttributes {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
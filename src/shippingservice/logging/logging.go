@@ -0,0 +1,379 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging writes structured JSON logs on top of log/slog.
+//
+// Every entry carries timestamp, severity and message fields that Cloud
+// Logging understands, the service name and host it came from, and the
+// request_id, trace_id and span_id of the request, and the order_id of the
+// order it places, when the logger is bound to a request context with
+// WithContext. The level is read from LOG_LEVEL and can be changed at
+// runtime through LevelHandler, and debug entries are sampled so that a busy
+// service does not flood the log pipeline. Email addresses, postal addresses
+// and card details are masked in messages and fields by the redact package.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/redact"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/requestid"
+)
+
+// Keys of the request correlation fields.
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+	OrderIDKey   = "order_id"
+)
+
+// Keys of fields that entries about orders and downstream calls share, so
+// that they can be filtered on across services. Durations logged under
+// DurationKey are written in milliseconds.
+const (
+	UserIDKey     = "user_id"
+	DurationKey   = "duration_ms"
+	DownstreamKey = "downstream"
+)
+
+// orderIDBaggage is the baggage member carrying the ID of the order a request
+// places, instrumentation.BaggageOrderID.
+const orderIDBaggage = "app.order.id"
+
+// DefaultLevel is used when LOG_LEVEL is unset.
+const DefaultLevel = slog.LevelDebug
+
+const levelFatal = slog.LevelError + 4
+
+// level is shared by every logger of the process so that LevelHandler
+// changes all of them at once.
+var level = func() *slog.LevelVar {
+	v := new(slog.LevelVar)
+	v.Set(DefaultLevel)
+	return v
+}()
+
+// Debug entries are sampled by message: each second, the first
+// DefaultSampleFirst entries with a given message are logged, and then one in
+// every DefaultSampleThereafter, unless LOG_SAMPLE_FIRST and
+// LOG_SAMPLE_THEREAFTER say otherwise.
+const (
+	DefaultSampleFirst      = 100
+	DefaultSampleThereafter = 100
+)
+
+// sampling is shared by every logger of the process, like level.
+var sampling = newSampler(DefaultSampleFirst, DefaultSampleThereafter)
+
+// sampler decides which debug entries to log.
+type sampler struct {
+	first, thereafter int
+
+	mu     sync.Mutex
+	second int64
+	counts map[string]int
+}
+
+func newSampler(first, thereafter int) *sampler {
+	return &sampler{first: first, thereafter: thereafter, counts: make(map[string]int)}
+}
+
+// keep reports whether to log an entry with msg written at t.
+func (s *sampler) keep(msg string, t time.Time) bool {
+	if s.thereafter <= 1 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sec := t.Unix(); sec != s.second {
+		s.second = sec
+		clear(s.counts)
+	}
+	n := s.counts[msg]
+	s.counts[msg] = n + 1
+	return n < s.first || (n-s.first+1)%s.thereafter == 0
+}
+
+// Fields are key/value pairs added to log entries with WithFields.
+type Fields map[string]any
+
+// Logger writes leveled log entries. Loggers derived with WithField,
+// WithFields and WithContext share the output and level of their parent.
+type Logger struct {
+	l   *slog.Logger
+	ctx context.Context
+}
+
+// New returns a logger for service that writes to stdout at the level named
+// by LOG_LEVEL (debug, info, warn or error). LOG_SAMPLE_THEREAFTER=1 turns
+// off the sampling of debug entries.
+func New(service string) *Logger {
+	l := newLogger(os.Stdout, service)
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		lvl, err := ParseLevel(v)
+		if err != nil {
+			l.Warnf("ignoring LOG_LEVEL: %v", err)
+		} else {
+			level.Set(lvl)
+		}
+	}
+	first, thereafter := DefaultSampleFirst, DefaultSampleThereafter
+	for _, e := range []struct {
+		name string
+		v    *int
+		min  int
+	}{
+		{"LOG_SAMPLE_FIRST", &first, 0},
+		{"LOG_SAMPLE_THEREAFTER", &thereafter, 1},
+	} {
+		if v := os.Getenv(e.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < e.min {
+				l.Warnf("ignoring %s: invalid value %q", e.name, v)
+				continue
+			}
+			*e.v = n
+		}
+	}
+	sampling = newSampler(first, thereafter)
+	return l
+}
+
+func newLogger(w io.Writer, service string) *Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: replaceAttr,
+	})
+	attrs := []slog.Attr{slog.String("service", service)}
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, slog.String("host", host))
+	}
+	return &Logger{
+		l:   slog.New(contextHandler{h.WithAttrs(attrs)}),
+		ctx: context.Background(),
+	}
+}
+
+// replaceAttr renames the built-in fields to timestamp, severity and message
+// and masks personal data in the message and fields.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.TimeKey:
+			return slog.String("timestamp", a.Value.Time().Format(time.RFC3339Nano))
+		case slog.LevelKey:
+			return slog.String("severity", levelName(a.Value.Any().(slog.Level)))
+		case slog.MessageKey:
+			return slog.String("message", redact.String(a.Value.String()))
+		case RequestIDKey, TraceIDKey, SpanIDKey, "service", "host":
+			return a
+		case DurationKey:
+			if a.Value.Kind() == slog.KindDuration {
+				return slog.Float64(DurationKey, float64(a.Value.Duration().Microseconds())/1000)
+			}
+		}
+	}
+	return redactAttr(a)
+}
+
+// redactAttr masks a's value if its key names a sensitive field, or the
+// personal data found in it otherwise.
+func redactAttr(a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redact.Value(a.Key, a.Value.String()))
+	case slog.KindAny:
+		var s string
+		if err, ok := a.Value.Any().(error); ok {
+			s = err.Error()
+		} else {
+			s = fmt.Sprintf("%+v", a.Value.Any())
+		}
+		if r := redact.Value(a.Key, s); r != s {
+			a.Value = slog.StringValue(r)
+		}
+	default:
+		if redact.SensitiveKey(a.Key) {
+			a.Value = slog.StringValue(redact.Mask)
+		}
+	}
+	return a
+}
+
+func levelName(l slog.Level) string {
+	switch {
+	case l >= levelFatal:
+		return "CRITICAL"
+	case l >= slog.LevelError:
+		return "ERROR"
+	case l >= slog.LevelWarn:
+		return "WARNING"
+	case l >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// ParseLevel parses a level name: debug, info, warn (or warning) or error.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// contextHandler adds the request ID, the IDs of the span and the order ID in
+// the record's context, and drops the debug entries left out by sampling.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelInfo && !sampling.keep(r.Message, r.Time) {
+		return nil
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String(TraceIDKey, sc.TraceID().String()),
+			slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	if id := baggage.FromContext(ctx).Member(orderIDBaggage).Value(); id != "" {
+		r.AddAttrs(slog.String(OrderIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// WithField returns a logger that adds key to every entry.
+func (l *Logger) WithField(key string, value any) *Logger {
+	return &Logger{l: l.l.With(key, value), ctx: l.ctx}
+}
+
+// WithFields returns a logger that adds fields to every entry.
+func (l *Logger) WithFields(fields Fields) *Logger {
+	args := make([]any, 0, 2*len(fields))
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	return &Logger{l: l.l.With(args...), ctx: l.ctx}
+}
+
+// WithContext returns a logger that adds the request ID, the trace and span
+// IDs of the span and the order ID in ctx, if any, to every entry.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return &Logger{l: l.l, ctx: ctx}
+}
+
+func (l *Logger) log(lvl slog.Level, msg string) {
+	l.l.Log(l.ctx, lvl, msg)
+}
+
+// Debug, Info, Warn and Error log their arguments formatted as by fmt.Sprint.
+func (l *Logger) Debug(args ...any) { l.log(slog.LevelDebug, fmt.Sprint(args...)) }
+func (l *Logger) Info(args ...any)  { l.log(slog.LevelInfo, fmt.Sprint(args...)) }
+func (l *Logger) Warn(args ...any)  { l.log(slog.LevelWarn, fmt.Sprint(args...)) }
+func (l *Logger) Error(args ...any) { l.log(slog.LevelError, fmt.Sprint(args...)) }
+
+// Debugf, Infof, Warnf and Errorf log their arguments formatted as by
+// fmt.Sprintf.
+func (l *Logger) Debugf(format string, args ...any) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Infof(format string, args ...any) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...any) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Errorf(format string, args ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs at CRITICAL severity and exits the process.
+func (l *Logger) Fatal(args ...any) {
+	l.log(levelFatal, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+// Fatalf logs at CRITICAL severity and exits the process.
+func (l *Logger) Fatalf(format string, args ...any) {
+	l.log(levelFatal, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// LevelHandler reports the current log level on GET and changes it on PUT,
+// with the new level name as the request body:
+//
+//	curl -X PUT -d info localhost:6060/debug/loglevel
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			lvl, err := ParseLevel(string(body))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level.Set(lvl)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, strings.ToLower(levelName(level.Level())))
+	})
+}
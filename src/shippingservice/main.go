@@ -18,48 +18,76 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/profiler"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/audit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/dedup"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/genproto"
-	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/grpcconfig"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/healthcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/lifecycle"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/rpcerrors"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const (
 	defaultPort = "50051"
 )
 
-var log *logrus.Logger
+var (
+	log *logging.Logger
+
+	// peerCreds secure gRPC traffic with the other Go services.
+	peerCreds *mtls.Credentials
+	// grpcSettings tune gRPC traffic and enable diagnostic services.
+	grpcSettings *grpcconfig.Config
+	// admit sheds low-priority requests when the service is overloaded.
+	admit *admission.Controller
+)
 
 func init() {
-	log = logrus.New()
-	log.Level = logrus.DebugLevel
-	log.Formatter = &logrus.JSONFormatter{
-		FieldMap: logrus.FieldMap{
-			logrus.FieldKeyTime:  "timestamp",
-			logrus.FieldKeyLevel: "severity",
-			logrus.FieldKeyMsg:   "message",
-		},
-		TimestampFormat: time.RFC3339Nano,
-	}
-	log.Out = os.Stdout
+	log = logging.New("shippingservice")
 }
 
 func main() {
-	if os.Getenv("DISABLE_TRACING") == "" {
-		log.Info("Tracing enabled, but temporarily unavailable")
-		log.Info("See https://github.com/GoogleCloudPlatform/microservices-demo/issues/422 for more info.")
-		go initTracing()
+	if err := validateConfig(); err != nil {
+		if configcheck.FailFast() {
+			log.Fatal(err)
+		}
+		log.Warn(err)
+	}
+
+	if os.Getenv("ENABLE_TRACING") == "1" {
+		log.Info("Tracing enabled.")
 	} else {
 		log.Info("Tracing disabled.")
 	}
+	tel, err := instrumentation.Init(context.Background(), "shippingservice", os.Getenv("ENABLE_TRACING") == "1")
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	if err := tel.ServeMetrics(instrumentation.MetricsAddr()); err != nil {
+		log.Fatal(err)
+	}
+	life := lifecycle.New(log)
+	life.OnClose("telemetry", tel.Shutdown)
 
 	if os.Getenv("DISABLE_PROFILER") == "" {
 		log.Info("Profiling enabled.")
@@ -68,6 +96,43 @@ func main() {
 		log.Info("Profiling disabled.")
 	}
 
+	auditLog, err := audit.FromEnv("shippingservice", log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	life.OnClose("audit log", auditLog.Close)
+
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		log.Info("Debug endpoints enabled.")
+		levelHandler := auditLog.HTTPHandler("logging.SetLevel", logging.LevelHandler())
+		if err := instrumentation.ServeDebug(instrumentation.DebugAddr(), instrumentation.DebugHandler(levelHandler, auditLog.Handler())); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Info("Debug endpoints disabled.")
+	}
+
+	creds, err := mtls.FromEnv(context.Background(), log)
+	if err != nil {
+		log.Fatalf("failed to set up mTLS: %v", err)
+	}
+	peerCreds = creds
+	log.Infof("mTLS mode: %s", peerCreds.Mode())
+
+	settings, err := grpcconfig.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcSettings = settings
+	log.Infof("gRPC settings: %s", grpcSettings)
+
+	admit, err = admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Load shedding: %s", admit)
+	go admit.Run(life.Context())
+
 	port := defaultPort
 	if value, ok := os.LookupEnv("PORT"); ok {
 		port = value
@@ -79,44 +144,59 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	var srv *grpc.Server
-	if os.Getenv("DISABLE_STATS") == "" {
-		log.Info("Stats enabled, but temporarily unavailable")
-		srv = grpc.NewServer()
-	} else {
-		log.Info("Stats disabled.")
-		srv = grpc.NewServer()
-	}
+	// Propagate trace context always
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{}, propagation.Baggage{}))
+	// quotes only depend on the request, so identical ones are coalesced
+	// even without an idempotency key
+	dedupInterceptor := dedup.UnaryServerInterceptor(pb.ShippingService_GetQuote_FullMethodName)
+	srv := grpc.NewServer(append([]grpc.ServerOption{
+		peerCreds.ServerOption(),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(peerCreds.UnaryServerInterceptor(), requestid.UnaryServerInterceptor(), admit.UnaryServerInterceptor(admission.Cart), dedupInterceptor, rpcerrors.UnaryServerInterceptor(log)),
+		grpc.ChainStreamInterceptor(peerCreds.StreamServerInterceptor(), requestid.StreamServerInterceptor(), admit.StreamServerInterceptor(admission.Cart), rpcerrors.StreamServerInterceptor(log)),
+	}, grpcSettings.ServerOptions()...)...)
 	svc := &server{}
 	pb.RegisterShippingServiceServer(srv, svc)
-	healthpb.RegisterHealthServer(srv, svc)
+	health := healthcheck.New(log, pb.ShippingService_ServiceDesc.ServiceName)
+	health.Register(srv)
+	grpcSettings.RegisterDiagnostics(srv)
+	life.OnUnready(health.Shutdown)
+	life.OnDrain("grpc server", lifecycle.GRPCServer(srv))
 	log.Infof("Shipping Service listening on port %s", port)
 
-	// Register reflection service on gRPC server.
-	reflection.Register(srv)
-	if err := srv.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+	life.Wait()
 }
 
 // server controls RPC service responses.
 type server struct {
 	pb.UnimplementedShippingServiceServer
-}
 
-// Check is for health checking.
-func (s *server) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
-	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	mu sync.Mutex
+	// shipments records each shipment by tracking ID. It is kept in memory,
+	// so shipments are forgotten on restart.
+	shipments map[string]*shipment
+	// now returns the current time; nil means time.Now.
+	now func() time.Time
 }
 
-func (s *server) Watch(req *healthpb.HealthCheckRequest, ws healthpb.Health_WatchServer) error {
-	return status.Errorf(codes.Unimplemented, "health check via Watch not implemented")
+func (s *server) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
 }
 
 // GetQuote produces a shipping quote (cost) in USD.
 func (s *server) GetQuote(ctx context.Context, in *pb.GetQuoteRequest) (*pb.GetQuoteResponse, error) {
-	log.Info("[GetQuote] received request")
-	defer log.Info("[GetQuote] completed request")
+	log.WithContext(ctx).Info("[GetQuote] received request")
+	defer log.WithContext(ctx).Info("[GetQuote] completed request")
 
 	// 1. Generate a quote based on the total number of items to be shipped.
 	quote := CreateQuoteFromCount(0)
@@ -134,11 +214,17 @@ func (s *server) GetQuote(ctx context.Context, in *pb.GetQuoteRequest) (*pb.GetQ
 // ShipOrder mocks that the requested items will be shipped.
 // It supplies a tracking ID for notional lookup of shipment delivery status.
 func (s *server) ShipOrder(ctx context.Context, in *pb.ShipOrderRequest) (*pb.ShipOrderResponse, error) {
-	log.Info("[ShipOrder] received request")
-	defer log.Info("[ShipOrder] completed request")
+	log.WithContext(ctx).Info("[ShipOrder] received request")
+	defer log.WithContext(ctx).Info("[ShipOrder] completed request")
 	// 1. Create a Tracking ID
 	baseAddress := fmt.Sprintf("%s, %s, %s", in.Address.StreetAddress, in.Address.City, in.Address.State)
 	id := CreateTrackingId(baseAddress)
+	s.mu.Lock()
+	if s.shipments == nil {
+		s.shipments = make(map[string]*shipment)
+	}
+	s.shipments[id] = &shipment{shippedAt: s.clock()}
+	s.mu.Unlock()
 
 	// 2. Generate a response.
 	return &pb.ShipOrderResponse{
@@ -146,12 +232,40 @@ func (s *server) ShipOrder(ctx context.Context, in *pb.ShipOrderRequest) (*pb.Sh
 	}, nil
 }
 
-func initStats() {
-	//TODO(arbrown) Implement OpenTelemetry stats
+// CancelShipment mocks that a shipment is called off before it leaves.
+func (s *server) CancelShipment(ctx context.Context, in *pb.CancelShipmentRequest) (*pb.Empty, error) {
+	log.WithContext(ctx).Info("[CancelShipment] received request")
+	defer log.WithContext(ctx).Info("[CancelShipment] completed request")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sh, ok := s.shipments[in.GetTrackingId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no shipment with tracking ID %q", in.GetTrackingId())
+	}
+	sh.cancelled = true
+	return &pb.Empty{}, nil
 }
 
-func initTracing() {
-	// TODO(arbrown) Implement OpenTelemetry tracing
+// GetShipment mocks tracking a shipment: it moves along on a fixed schedule
+// from the moment ShipOrder created it.
+func (s *server) GetShipment(ctx context.Context, in *pb.GetShipmentRequest) (*pb.Shipment, error) {
+	log.WithContext(ctx).Info("[GetShipment] received request")
+	defer log.WithContext(ctx).Info("[GetShipment] completed request")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sh, ok := s.shipments[in.GetTrackingId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no shipment with tracking ID %q", in.GetTrackingId())
+	}
+	res := &pb.Shipment{
+		TrackingId: in.GetTrackingId(),
+		Status:     sh.status(s.clock()),
+		ShippedAt:  timestamppb.New(sh.shippedAt),
+	}
+	if !sh.cancelled {
+		res.EstimatedDelivery = timestamppb.New(sh.shippedAt.Add(deliveredAfter))
+	}
+	return res, nil
 }
 
 func initProfiling(service, version string) {
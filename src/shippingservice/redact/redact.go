@@ -0,0 +1,128 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact masks personal data (email addresses, postal addresses and
+// payment card details) before it is written to logs or traces.
+//
+// Values are masked by field-name rules, when the key they are logged or
+// recorded under names a sensitive field, and by structural detection, which
+// finds email addresses, card numbers, street addresses and the key/value
+// pairs of sensitive fields inside free text such as log messages, error
+// strings and printed protobuf messages.
+//
+// This package is duplicated in every Go service since they do not share
+// packages.
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mask replaces redacted values.
+const Mask = "[REDACTED]"
+
+// sensitiveKeys are the normalized field names that hold personal data.
+var sensitiveKeys = map[string]bool{
+	"email":         true,
+	"emailaddress":  true,
+	"streetaddress": true,
+	"zipcode":       true,
+	"postalcode":    true,
+	"cardnumber":    true,
+	"ccnumber":      true,
+	"cvv":           true,
+	"cvc":           true,
+	"password":      true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	// streetPattern matches addresses such as "1600 Amphitheatre Parkway".
+	streetPattern = regexp.MustCompile(`\b\d{1,6}(?: [A-Z][A-Za-z]*){1,4} (?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Way|Parkway|Pkwy|Court|Ct|Place|Pl|Terrace|Highway|Hwy)\b\.?`)
+	// fieldPattern matches key/value pairs as written by fmt's %+v, protobuf
+	// text and JSON: key:value, key=value and "key": "value".
+	fieldPattern = regexp.MustCompile(`"?([A-Za-z_][A-Za-z0-9_.\-]*)"?(\s*[:=]\s*)("(?:[^"\\]|\\.)*"|[^\s,;&{}\[\]()"]+)`)
+)
+
+// SensitiveKey reports whether key names a field holding personal data. The
+// last dot-separated segment of key is compared case-insensitively, ignoring
+// underscores and dashes, so "Email", "order.email" and "credit_card_number"
+// are all sensitive.
+func SensitiveKey(key string) bool {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	k := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[k] || strings.HasPrefix(k, "creditcard")
+}
+
+// Value returns v as it may be recorded under key: Mask if key is
+// sensitive, and v with any personal data found in it masked otherwise.
+func Value(key, v string) string {
+	if v != "" && SensitiveKey(key) {
+		return Mask
+	}
+	return String(v)
+}
+
+// String returns s with the personal data found in it masked.
+func String(s string) string {
+	if s == "" {
+		return s
+	}
+	s = fieldPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := fieldPattern.FindStringSubmatch(m)
+		if !SensitiveKey(sub[1]) {
+			return m
+		}
+		value := sub[3]
+		masked := Mask
+		if strings.HasPrefix(value, `"`) {
+			masked = `"` + Mask + `"`
+		}
+		return m[:len(m)-len(value)] + masked
+	})
+	s = emailPattern.ReplaceAllString(s, Mask)
+	s = streetPattern.ReplaceAllString(s, Mask)
+	return cardPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if luhn(m) {
+			return Mask
+		}
+		return m
+	})
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of payment
+// card numbers.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
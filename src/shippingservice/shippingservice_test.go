@@ -16,9 +16,15 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/contract"
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/genproto"
 )
 
@@ -88,3 +94,82 @@ func TestShipOrder(t *testing.T) {
 		t.Errorf("TestShipOrder: Tracking ID is malformed - has %d characters, %d expected", len(res.TrackingId), 18)
 	}
 }
+
+// TestCancelShipment cancels a shipment, and fails to cancel unknown ones.
+func TestCancelShipment(t *testing.T) {
+	s := server{}
+	ctx := context.Background()
+	res, err := s.ShipOrder(ctx, &pb.ShipOrderRequest{Address: &pb.Address{StreetAddress: "Muffin Man", City: "London"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := s.CancelShipment(ctx, &pb.CancelShipmentRequest{TrackingId: res.TrackingId}); err != nil {
+			t.Errorf("CancelShipment(%s) failed: %v", res.TrackingId, err)
+		}
+	}
+	if _, err := s.CancelShipment(ctx, &pb.CancelShipmentRequest{TrackingId: "XX-0000-0000000"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CancelShipment of an unknown shipment: got %v, want NotFound", err)
+	}
+}
+
+// TestGetShipment follows a shipment from its label to its delivery, and
+// fails to find unknown ones.
+func TestGetShipment(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	s := server{now: func() time.Time { return now }}
+	ctx := context.Background()
+	res, err := s.ShipOrder(ctx, &pb.ShipOrderRequest{Address: &pb.Address{StreetAddress: "Muffin Man", City: "London"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shippedAt := now
+	for _, tc := range []struct {
+		elapsed time.Duration
+		want    pb.ShipmentStatus
+	}{
+		{0, pb.ShipmentStatus_SHIPMENT_STATUS_LABEL_CREATED},
+		{pickedUpAfter, pb.ShipmentStatus_SHIPMENT_STATUS_IN_TRANSIT},
+		{outForDeliveryAfter, pb.ShipmentStatus_SHIPMENT_STATUS_OUT_FOR_DELIVERY},
+		{deliveredAfter, pb.ShipmentStatus_SHIPMENT_STATUS_DELIVERED},
+		{24 * time.Hour, pb.ShipmentStatus_SHIPMENT_STATUS_DELIVERED},
+	} {
+		now = shippedAt.Add(tc.elapsed)
+		got, err := s.GetShipment(ctx, &pb.GetShipmentRequest{TrackingId: res.TrackingId})
+		if err != nil {
+			t.Fatalf("GetShipment after %v failed: %v", tc.elapsed, err)
+		}
+		if got.Status != tc.want {
+			t.Errorf("GetShipment after %v: status %v, want %v", tc.elapsed, got.Status, tc.want)
+		}
+		if !got.ShippedAt.AsTime().Equal(shippedAt) {
+			t.Errorf("GetShipment after %v: shipped at %v, want %v", tc.elapsed, got.ShippedAt.AsTime(), shippedAt)
+		}
+		if want := shippedAt.Add(deliveredAfter); !got.EstimatedDelivery.AsTime().Equal(want) {
+			t.Errorf("GetShipment after %v: estimated delivery %v, want %v", tc.elapsed, got.EstimatedDelivery.AsTime(), want)
+		}
+	}
+
+	if _, err := s.CancelShipment(ctx, &pb.CancelShipmentRequest{TrackingId: res.TrackingId}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetShipment(ctx, &pb.GetShipmentRequest{TrackingId: res.TrackingId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != pb.ShipmentStatus_SHIPMENT_STATUS_CANCELLED || got.EstimatedDelivery != nil {
+		t.Errorf("GetShipment of a cancelled shipment = %v, want CANCELLED without an estimated delivery", got)
+	}
+
+	if _, err := s.GetShipment(ctx, &pb.GetShipmentRequest{TrackingId: "XX-0000-0000000"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetShipment of an unknown shipment: got %v, want NotFound", err)
+	}
+}
+
+// TestContract checks the service against what its callers rely on.
+func TestContract(t *testing.T) {
+	conn := contract.Serve(t, func(srv *grpc.Server) {
+		pb.RegisterShippingServiceServer(srv, &server{})
+	})
+	contract.Shipping(t, pb.NewShippingServiceClient(conn))
+}
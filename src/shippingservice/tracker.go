@@ -18,8 +18,42 @@ import (
 	"fmt"
 	"math/rand"
 	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/shippingservice/genproto"
+)
+
+// A shipment moves through each status at a demo-friendly pace: it is
+// picked up after pickedUpAfter, goes out for delivery after
+// outForDeliveryAfter and is delivered after deliveredAfter.
+const (
+	pickedUpAfter       = 2 * time.Minute
+	outForDeliveryAfter = 10 * time.Minute
+	deliveredAfter      = 15 * time.Minute
 )
 
+// shipment is a shipment ShipOrder created.
+type shipment struct {
+	shippedAt time.Time
+	cancelled bool
+}
+
+// status returns where the shipment is at now.
+func (s *shipment) status(now time.Time) pb.ShipmentStatus {
+	elapsed := now.Sub(s.shippedAt)
+	switch {
+	case s.cancelled:
+		return pb.ShipmentStatus_SHIPMENT_STATUS_CANCELLED
+	case elapsed >= deliveredAfter:
+		return pb.ShipmentStatus_SHIPMENT_STATUS_DELIVERED
+	case elapsed >= outForDeliveryAfter:
+		return pb.ShipmentStatus_SHIPMENT_STATUS_OUT_FOR_DELIVERY
+	case elapsed >= pickedUpAfter:
+		return pb.ShipmentStatus_SHIPMENT_STATUS_IN_TRANSIT
+	default:
+		return pb.ShipmentStatus_SHIPMENT_STATUS_LABEL_CREATED
+	}
+}
+
 // seeded determines if the random number generator is ready.
 var seeded bool = false
 
@@ -0,0 +1,41 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/instrumentation"
+)
+
+// validateConfig checks the service's environment before it starts.
+func validateConfig() error {
+	c := configcheck.New("subscriptionservice")
+	c.Common()
+	c.Port("PORT", listenPort)
+	c.Port("METRICS_PORT", instrumentation.DefaultMetricsPort)
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		c.Port("DEBUG_PORT", instrumentation.DefaultDebugPort)
+	}
+	c.Addr("CART_SERVICE_ADDR", true)
+	c.Addr("CHECKOUT_SERVICE_ADDR", true)
+	c.Addr("EMAIL_SERVICE_ADDR", true)
+	c.Int("MAX_ATTEMPTS", 1)
+	c.Duration("POLL_INTERVAL", time.Second)
+	c.Duration("HEALTH_CHECK_INTERVAL", time.Second)
+	return c.Err()
+}
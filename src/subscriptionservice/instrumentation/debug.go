@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	runtimepprof "runtime/pprof"
+)
+
+// DefaultDebugPort is where services serve debug endpoints unless DEBUG_PORT
+// says otherwise.
+const DefaultDebugPort = "6060"
+
+// DebugHandler serves runtime debug endpoints: pprof profiles under
+// /debug/pprof/, expvar variables at /debug/vars and a dump of every
+// goroutine's stack at /debug/goroutines. A non-nil logLevel is served at
+// /debug/loglevel to inspect and change the log level, and a non-nil audit at
+// /debug/audit to query the audit log.
+func DebugHandler(logLevel, audit http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	if logLevel != nil {
+		mux.Handle("/debug/loglevel", logLevel)
+	}
+	if audit != nil {
+		mux.Handle("/debug/audit", audit)
+	}
+	return mux
+}
+
+// ServeDebug serves h, usually a DebugHandler, on addr in the background. It
+// only fails if addr cannot be listened on.
+func ServeDebug(addr string, h http.Handler) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for debug endpoints on %s: %w", addr, err)
+	}
+	go http.Serve(lis, h)
+	return nil
+}
+
+// DebugAddr returns the address to serve debug endpoints on: DEBUG_PORT, or
+// DefaultDebugPort when unset, on the loopback interface only. Profiles expose
+// internals of the process, so they are reached with kubectl port-forward
+// rather than through a Service.
+func DebugAddr() string {
+	port := os.Getenv("DEBUG_PORT")
+	if port == "" {
+		port = DefaultDebugPort
+	}
+	return "127.0.0.1:" + port
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	p, err := Init(context.Background(), "testservice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+
+	counter, err := otel.Meter("test").Int64Counter("test.requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 3)
+
+	srv := httptest.NewServer(p.MetricsHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`test_requests_total{otel_scope_name="test"`,
+		"go_goroutines",
+		`service_name="testservice"`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
+}
+
+func TestMetricsAddr(t *testing.T) {
+	t.Setenv("METRICS_PORT", "")
+	if got, want := MetricsAddr(), ":"+DefaultMetricsPort; got != want {
+		t.Errorf("MetricsAddr() = %q, want %q", got, want)
+	}
+	t.Setenv("METRICS_PORT", "9100")
+	if got, want := MetricsAddr(), ":9100"; got != want {
+		t.Errorf("MetricsAddr() = %q, want %q", got, want)
+	}
+}
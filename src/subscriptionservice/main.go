@@ -0,0 +1,298 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/profiler"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/admission"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/audit"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/configcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/dedup"
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/grpcconfig"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/healthcheck"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/lifecycle"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/logging"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/mtls"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/requestid"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/rpcerrors"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/warmup"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	listenPort = "5050"
+
+	defaultPollInterval = time.Minute
+	defaultMaxAttempts  = 3
+)
+
+var (
+	log *logging.Logger
+
+	// peerCreds secure gRPC traffic with the other Go services.
+	peerCreds *mtls.Credentials
+	// grpcSettings tune gRPC traffic and enable diagnostic services.
+	grpcSettings *grpcconfig.Config
+	// admit sheds low-priority requests when the service is overloaded.
+	admit *admission.Controller
+)
+
+func init() {
+	log = logging.New("subscriptionservice")
+}
+
+func main() {
+	if err := validateConfig(); err != nil {
+		if configcheck.FailFast() {
+			log.Fatal(err)
+		}
+		log.Warn(err)
+	}
+
+	ctx := context.Background()
+	if os.Getenv("ENABLE_TRACING") == "1" {
+		log.Info("Tracing enabled.")
+	} else {
+		log.Info("Tracing disabled.")
+	}
+	tel, err := instrumentation.Init(ctx, "subscriptionservice", os.Getenv("ENABLE_TRACING") == "1")
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	if err := tel.ServeMetrics(instrumentation.MetricsAddr()); err != nil {
+		log.Fatal(err)
+	}
+	life := lifecycle.New(log)
+	life.OnClose("telemetry", tel.Shutdown)
+
+	if os.Getenv("ENABLE_PROFILER") == "1" {
+		log.Info("Profiling enabled.")
+		go initProfiling("subscriptionservice", "1.0.0")
+	} else {
+		log.Info("Profiling disabled.")
+	}
+
+	auditLog, err := audit.FromEnv("subscriptionservice", log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	life.OnClose("audit log", auditLog.Close)
+
+	if os.Getenv("ENABLE_DEBUG") == "1" {
+		log.Info("Debug endpoints enabled.")
+		levelHandler := auditLog.HTTPHandler("logging.SetLevel", logging.LevelHandler())
+		if err := instrumentation.ServeDebug(instrumentation.DebugAddr(), instrumentation.DebugHandler(levelHandler, auditLog.Handler())); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Info("Debug endpoints disabled.")
+	}
+
+	creds, err := mtls.FromEnv(ctx, log)
+	if err != nil {
+		log.Fatalf("failed to set up mTLS: %v", err)
+	}
+	peerCreds = creds
+	log.Infof("mTLS mode: %s", peerCreds.Mode())
+
+	settings, err := grpcconfig.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	grpcSettings = settings
+	log.Infof("gRPC settings: %s", grpcSettings)
+
+	admit, err = admission.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Load shedding: %s", admit)
+	go admit.Run(life.Context())
+
+	port := listenPort
+	if os.Getenv("PORT") != "" {
+		port = os.Getenv("PORT")
+	}
+
+	var (
+		cartSvcAddr, checkoutSvcAddr, emailSvcAddr string
+		cartSvcConn, checkoutSvcConn, emailSvcConn *grpc.ClientConn
+	)
+	mustMapEnv(&cartSvcAddr, "CART_SERVICE_ADDR")
+	mustMapEnv(&checkoutSvcAddr, "CHECKOUT_SERVICE_ADDR")
+	mustMapEnv(&emailSvcAddr, "EMAIL_SERVICE_ADDR")
+	mustConnGRPC(ctx, &cartSvcConn, cartSvcAddr)
+	mustConnGRPC(ctx, &checkoutSvcConn, checkoutSvcAddr)
+	mustConnGRPC(ctx, &emailSvcConn, emailSvcAddr)
+
+	warm, err := warmup.New(log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	warm.Add("cart", warmup.Conn(cartSvcConn))
+	warm.Add("checkout", warmup.Conn(checkoutSvcConn))
+	warm.Add("email", warmup.Conn(emailSvcConn))
+	log.Infof("Warm-up: %s", warm)
+
+	svc := &subscriptionService{
+		store: newSubscriptionStore(),
+		vault: newCardVault(),
+	}
+	sched := &scheduler{
+		store:       svc.store,
+		vault:       svc.vault,
+		cart:        pb.NewCartServiceClient(cartSvcConn),
+		checkout:    pb.NewCheckoutServiceClient(checkoutSvcConn),
+		email:       pb.NewEmailServiceClient(emailSvcConn),
+		maxAttempts: defaultMaxAttempts,
+		retryDelay:  defaultPollInterval,
+	}
+	if v := os.Getenv("MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid MAX_ATTEMPTS %q", v)
+		}
+		sched.maxAttempts = n
+	}
+	pollInterval := defaultPollInterval
+	if v := os.Getenv("POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid POLL_INTERVAL %q", v)
+		}
+		pollInterval = d
+		sched.retryDelay = d
+	}
+	schedDone := make(chan struct{})
+	go func() {
+		sched.run(life.Context(), pollInterval)
+		close(schedDone)
+	}()
+	life.OnDrain("scheduler", func(ctx context.Context) error {
+		select {
+		case <-schedDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Propagate trace context always
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{}, propagation.Baggage{}))
+	srv := grpc.NewServer(append([]grpc.ServerOption{
+		peerCreds.ServerOption(),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(peerCreds.UnaryServerInterceptor(), requestid.UnaryServerInterceptor(), admit.UnaryServerInterceptor(admission.Checkout), dedup.UnaryServerInterceptor(), rpcerrors.UnaryServerInterceptor(log)),
+		grpc.ChainStreamInterceptor(peerCreds.StreamServerInterceptor(), requestid.StreamServerInterceptor(), admit.StreamServerInterceptor(admission.Checkout), rpcerrors.StreamServerInterceptor(log)),
+	}, grpcSettings.ServerOptions()...)...)
+
+	pb.RegisterSubscriptionServiceServer(srv, svc)
+	health := healthcheck.New(log, pb.SubscriptionService_ServiceDesc.ServiceName)
+	health.Add("cart", healthcheck.Conn(cartSvcConn))
+	health.Add("checkout", healthcheck.Conn(checkoutSvcConn))
+	health.Add("email", healthcheck.Conn(emailSvcConn))
+	health.Register(srv)
+	grpcSettings.RegisterDiagnostics(srv)
+	go health.Run(life.Context())
+	life.OnUnready(health.Shutdown)
+	life.OnDrain("grpc server", lifecycle.GRPCServer(srv))
+	release := health.Hold()
+	go func() {
+		warm.Run(life.Context())
+		release()
+	}()
+
+	log.Infof("starting to listen on tcp: %q", lis.Addr().String())
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	life.Wait()
+}
+
+func initProfiling(service, version string) {
+	// TODO(ahmetb) this method is duplicated in other microservices using Go
+	// since they are not sharing packages.
+	for i := 1; i <= 3; i++ {
+		if err := profiler.Start(profiler.Config{
+			Service:        service,
+			ServiceVersion: version,
+			// ProjectID must be set if not running on GCP.
+			// ProjectID: "my-project",
+		}); err != nil {
+			log.Warnf("failed to start profiler: %+v", err)
+		} else {
+			log.Info("started Stackdriver profiler")
+			return
+		}
+		d := time.Second * 10 * time.Duration(i)
+		log.Infof("sleeping %v to retry initializing Stackdriver profiler", d)
+		time.Sleep(d)
+	}
+	log.Warn("could not initialize Stackdriver profiler after retrying, giving up")
+}
+
+func mustMapEnv(target *string, envKey string) {
+	v := os.Getenv(envKey)
+	if v == "" {
+		panic(fmt.Sprintf("environment variable %q not set", envKey))
+	}
+	*target = v
+}
+
+func mustConnGRPC(ctx context.Context, conn **grpc.ClientConn, addr string) {
+	var err error
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	*conn, err = grpc.DialContext(ctx, addr, append([]grpc.DialOption{
+		peerCreds.DialOption(addr),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(requestid.UnaryClientInterceptor(), admission.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(requestid.StreamClientInterceptor(), admission.StreamClientInterceptor()),
+	}, grpcSettings.DialOptions()...)...)
+	if err != nil {
+		panic(errors.Wrapf(err, "grpc: failed to connect %s", addr))
+	}
+}
+
+type subscriptionService struct {
+	pb.UnimplementedSubscriptionServiceServer
+
+	store *subscriptionStore
+	vault *cardVault
+}
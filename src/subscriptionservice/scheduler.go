@@ -0,0 +1,160 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/instrumentation"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/requestid"
+)
+
+// scheduler places orders for subscriptions as they fall due. Each run fills a
+// dedicated cart with the subscription's items and checks it out through
+// checkoutservice; failed runs are retried with exponential backoff until
+// maxAttempts, after which the subscription is paused and the user notified.
+type scheduler struct {
+	store *subscriptionStore
+	vault *cardVault
+
+	cart     pb.CartServiceClient
+	checkout pb.CheckoutServiceClient
+	email    pb.EmailServiceClient
+
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+// run places due orders every interval until ctx is done. A run in progress
+// when ctx is done finishes the order it is placing, so that no user is
+// charged without their order being recorded, and then returns.
+func (s *scheduler) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+// runDue places orders for every subscription due at now, stopping early if
+// ctx is done.
+func (s *scheduler) runDue(ctx context.Context, now time.Time) {
+	for _, sub := range s.store.due(now) {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runOne(context.WithoutCancel(ctx), sub, now)
+	}
+}
+
+func (s *scheduler) runOne(ctx context.Context, sub *pb.Subscription, now time.Time) {
+	// Each run starts a request of its own, like a shopper at the frontend.
+	ctx = requestid.NewContext(ctx, requestid.New())
+	ctx = instrumentation.WithBaggage(ctx, map[string]string{
+		instrumentation.BaggageUserHash: instrumentation.HashID(sub.GetUserId()),
+	})
+	orderID, err := s.placeOrder(ctx, sub)
+	var paused bool
+	updated, uerr := s.store.update(sub.GetId(), func(cur *pb.Subscription) error {
+		if err == nil {
+			cur.LastOrderId = orderID
+			cur.LastError = ""
+			cur.FailedAttempts = 0
+			cur.NextRun = timestamppb.New(now.AddDate(0, 0, int(cur.GetIntervalDays())))
+			return nil
+		}
+		cur.LastError = err.Error()
+		// The user may have paused or cancelled while the order was in flight.
+		if cur.GetStatus() != pb.Subscription_ACTIVE {
+			return nil
+		}
+		cur.FailedAttempts++
+		if int(cur.GetFailedAttempts()) >= s.maxAttempts {
+			cur.Status = pb.Subscription_PAUSED
+			paused = true
+			return nil
+		}
+		backoff := s.retryDelay << (cur.GetFailedAttempts() - 1)
+		cur.NextRun = timestamppb.New(now.Add(backoff))
+		return nil
+	})
+	if uerr != nil {
+		log.WithContext(ctx).Warnf("failed to record run of subscription %s: %v", sub.GetId(), uerr)
+		return
+	}
+
+	if err == nil {
+		log.WithContext(ctx).Infof("subscription %s placed order %s, next run at %s", sub.GetId(), orderID, updated.GetNextRun().AsTime().Format(time.RFC3339))
+		return
+	}
+	log.WithContext(ctx).Warnf("subscription %s run failed (attempt %d of %d): %v", sub.GetId(), updated.GetFailedAttempts(), s.maxAttempts, err)
+	if paused {
+		s.notifyPaused(ctx, updated)
+	}
+}
+
+func (s *scheduler) placeOrder(ctx context.Context, sub *pb.Subscription) (string, error) {
+	card, ok := s.vault.get(sub.GetPaymentToken())
+	if !ok {
+		return "", fmt.Errorf("no card on file for payment token")
+	}
+
+	// Every subscription fills its own cart so that runs never pick up or
+	// clear items the user has in their regular shopping cart.
+	cartID := "subscription-" + sub.GetId()
+	if _, err := s.cart.EmptyCart(ctx, &pb.EmptyCartRequest{UserId: cartID}); err != nil {
+		return "", fmt.Errorf("failed to empty cart: %+v", err)
+	}
+	for _, item := range sub.GetItems() {
+		if _, err := s.cart.AddItem(ctx, &pb.AddItemRequest{UserId: cartID, Item: item}); err != nil {
+			return "", fmt.Errorf("failed to add %s to cart: %+v", item.GetProductId(), err)
+		}
+	}
+
+	resp, err := s.checkout.PlaceOrder(ctx, &pb.PlaceOrderRequest{
+		UserId:       cartID,
+		UserCurrency: sub.GetUserCurrency(),
+		Address:      sub.GetAddress(),
+		Email:        sub.GetEmail(),
+		CreditCard:   card,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to place order: %+v", err)
+	}
+	return resp.GetOrder().GetOrderId(), nil
+}
+
+func (s *scheduler) notifyPaused(ctx context.Context, sub *pb.Subscription) {
+	body := fmt.Sprintf("We could not place the order for your subscription %s after %d attempts, so it has been paused.\n\n"+
+		"Last error: %s\n\nResume the subscription once the problem is fixed to continue deliveries.\n",
+		sub.GetId(), sub.GetFailedAttempts(), sub.GetLastError())
+	if _, err := s.email.SendNotification(ctx, &pb.SendNotificationRequest{
+		Email:    sub.GetEmail(),
+		Subject:  "Your subscription has been paused",
+		TextBody: body,
+	}); err != nil {
+		log.Warnf("failed to notify %s about paused subscription %s: %v", sub.GetEmail(), sub.GetId(), err)
+	}
+}
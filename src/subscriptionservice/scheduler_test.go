@@ -0,0 +1,168 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/genproto"
+)
+
+type fakeCart struct {
+	pb.CartServiceClient
+	items map[string][]*pb.CartItem
+}
+
+func (c *fakeCart) EmptyCart(ctx context.Context, in *pb.EmptyCartRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	delete(c.items, in.GetUserId())
+	return &pb.Empty{}, nil
+}
+
+func (c *fakeCart) AddItem(ctx context.Context, in *pb.AddItemRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	c.items[in.GetUserId()] = append(c.items[in.GetUserId()], in.GetItem())
+	return &pb.Empty{}, nil
+}
+
+type fakeCheckout struct {
+	pb.CheckoutServiceClient
+	err  error
+	reqs []*pb.PlaceOrderRequest
+}
+
+func (c *fakeCheckout) PlaceOrder(ctx context.Context, in *pb.PlaceOrderRequest, opts ...grpc.CallOption) (*pb.PlaceOrderResponse, error) {
+	c.reqs = append(c.reqs, in)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &pb.PlaceOrderResponse{Order: &pb.OrderResult{OrderId: "order-1"}}, nil
+}
+
+type fakeEmail struct {
+	pb.EmailServiceClient
+	sent []*pb.SendNotificationRequest
+}
+
+func (e *fakeEmail) SendNotification(ctx context.Context, in *pb.SendNotificationRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	e.sent = append(e.sent, in)
+	return &pb.Empty{}, nil
+}
+
+func newTestScheduler(t *testing.T, checkoutErr error) (*scheduler, *pb.Subscription, *fakeCart, *fakeCheckout, *fakeEmail) {
+	t.Helper()
+	cart := &fakeCart{items: make(map[string][]*pb.CartItem)}
+	checkout := &fakeCheckout{err: checkoutErr}
+	email := &fakeEmail{}
+	sched := &scheduler{
+		store:       newSubscriptionStore(),
+		vault:       newCardVault(),
+		cart:        cart,
+		checkout:    checkout,
+		email:       email,
+		maxAttempts: 3,
+		retryDelay:  time.Minute,
+	}
+	sub := &pb.Subscription{
+		Id:           "sub-1",
+		UserId:       "user-1",
+		Email:        "someone@example.com",
+		Items:        []*pb.CartItem{{ProductId: "OLJCESPC7Z", Quantity: 2}},
+		IntervalDays: 30,
+		UserCurrency: "USD",
+		PaymentToken: sched.vault.put(&pb.CreditCardInfo{CreditCardNumber: "4432-8015-6152-0454"}),
+		Status:       pb.Subscription_ACTIVE,
+		NextRun:      timestamppb.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	sched.store.create(sub)
+	return sched, sub, cart, checkout, email
+}
+
+func TestRunDuePlacesOrder(t *testing.T) {
+	sched, sub, cart, checkout, _ := newTestScheduler(t, nil)
+	now := sub.GetNextRun().AsTime()
+
+	sched.runDue(context.Background(), now)
+
+	if len(checkout.reqs) != 1 {
+		t.Fatalf("got %d PlaceOrder calls, want 1", len(checkout.reqs))
+	}
+	req := checkout.reqs[0]
+	if req.GetUserId() != "subscription-sub-1" {
+		t.Errorf("order placed for cart %q, want the subscription's own cart", req.GetUserId())
+	}
+	if got := cart.items[req.GetUserId()]; len(got) != 1 || got[0].GetQuantity() != 2 {
+		t.Errorf("cart = %v, want the subscription's items", got)
+	}
+	if req.GetCreditCard().GetCreditCardNumber() != "4432-8015-6152-0454" {
+		t.Errorf("order was not paid with the card on file")
+	}
+
+	got, _ := sched.store.get(sub.GetId())
+	if got.GetLastOrderId() != "order-1" {
+		t.Errorf("last_order_id = %q, want order-1", got.GetLastOrderId())
+	}
+	if want := now.AddDate(0, 0, 30); !got.GetNextRun().AsTime().Equal(want) {
+		t.Errorf("next_run = %v, want %v", got.GetNextRun().AsTime(), want)
+	}
+}
+
+func TestRunDueSkipsFutureRuns(t *testing.T) {
+	sched, sub, _, checkout, _ := newTestScheduler(t, nil)
+
+	sched.runDue(context.Background(), sub.GetNextRun().AsTime().Add(-time.Second))
+
+	if len(checkout.reqs) != 0 {
+		t.Errorf("got %d PlaceOrder calls before the subscription was due", len(checkout.reqs))
+	}
+}
+
+func TestRunDueRetriesThenPauses(t *testing.T) {
+	sched, sub, _, checkout, email := newTestScheduler(t, errors.New("payment declined"))
+	now := sub.GetNextRun().AsTime()
+
+	for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		sched.runDue(context.Background(), now)
+		got, _ := sched.store.get(sub.GetId())
+		if got.GetStatus() != pb.Subscription_ACTIVE {
+			t.Fatalf("attempt %d: status = %s, want ACTIVE", attempt+1, got.GetStatus())
+		}
+		if want := now.Add(backoff); !got.GetNextRun().AsTime().Equal(want) {
+			t.Fatalf("attempt %d: next_run = %v, want %v", attempt+1, got.GetNextRun().AsTime(), want)
+		}
+		now = got.GetNextRun().AsTime()
+	}
+	if len(email.sent) != 0 {
+		t.Fatalf("user notified before the subscription was paused")
+	}
+
+	sched.runDue(context.Background(), now)
+	got, _ := sched.store.get(sub.GetId())
+	if got.GetStatus() != pb.Subscription_PAUSED {
+		t.Errorf("status = %s after %d failures, want PAUSED", got.GetStatus(), len(checkout.reqs))
+	}
+	if len(email.sent) != 1 || email.sent[0].GetEmail() != sub.GetEmail() {
+		t.Errorf("notifications = %v, want one to %s", email.sent, sub.GetEmail())
+	}
+
+	sched.runDue(context.Background(), now.Add(time.Hour))
+	if len(checkout.reqs) != 3 {
+		t.Errorf("got %d PlaceOrder calls, want none after pausing", len(checkout.reqs)-3)
+	}
+}
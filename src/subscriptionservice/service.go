@@ -0,0 +1,155 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/genproto"
+)
+
+const defaultCurrency = "USD"
+
+func (s *subscriptionService) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+	if err := validateCreateRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	now := time.Now()
+	firstRun := now
+	if req.GetFirstRun() != nil {
+		firstRun = req.GetFirstRun().AsTime()
+	}
+	currency := req.GetUserCurrency()
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	sub := &pb.Subscription{
+		Id:           uuid.NewString(),
+		UserId:       req.GetUserId(),
+		Email:        req.GetEmail(),
+		Items:        req.GetItems(),
+		IntervalDays: req.GetIntervalDays(),
+		Address:      req.GetAddress(),
+		UserCurrency: currency,
+		PaymentToken: s.vault.put(req.GetCreditCard()),
+		Status:       pb.Subscription_ACTIVE,
+		NextRun:      timestamppb.New(firstRun),
+		CreatedAt:    timestamppb.New(now),
+	}
+	s.store.create(sub)
+	log.WithContext(ctx).Infof("[CreateSubscription] subscription %s created for user %s, every %d days", sub.GetId(), sub.GetUserId(), sub.GetIntervalDays())
+	return sub, nil
+}
+
+func validateCreateRequest(req *pb.CreateSubscriptionRequest) error {
+	switch {
+	case req.GetUserId() == "":
+		return errors.New("user_id is required")
+	case req.GetEmail() == "":
+		return errors.New("email is required")
+	case len(req.GetItems()) == 0:
+		return errors.New("at least one item is required")
+	case req.GetIntervalDays() <= 0:
+		return errors.New("interval_days must be positive")
+	case req.GetAddress() == nil:
+		return errors.New("address is required")
+	case req.GetCreditCard().GetCreditCardNumber() == "":
+		return errors.New("credit_card is required")
+	}
+	for _, item := range req.GetItems() {
+		if item.GetProductId() == "" || item.GetQuantity() <= 0 {
+			return errors.New("items need a product_id and a positive quantity")
+		}
+	}
+	return nil
+}
+
+func (s *subscriptionService) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	sub, err := s.store.get(req.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "subscription %q not found", req.GetId())
+	}
+	return sub, nil
+}
+
+func (s *subscriptionService) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	return &pb.ListSubscriptionsResponse{Subscriptions: s.store.list(req.GetUserId())}, nil
+}
+
+func (s *subscriptionService) PauseSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	return s.transition(req.GetId(), func(sub *pb.Subscription) error {
+		if sub.GetStatus() != pb.Subscription_ACTIVE {
+			return status.Errorf(codes.FailedPrecondition, "cannot pause a %s subscription", sub.GetStatus())
+		}
+		sub.Status = pb.Subscription_PAUSED
+		return nil
+	})
+}
+
+// ResumeSubscription reactivates a paused subscription. Failed attempts are
+// forgotten, and a run that fell due while paused happens on the next poll.
+func (s *subscriptionService) ResumeSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	return s.transition(req.GetId(), func(sub *pb.Subscription) error {
+		if sub.GetStatus() != pb.Subscription_PAUSED {
+			return status.Errorf(codes.FailedPrecondition, "cannot resume a %s subscription", sub.GetStatus())
+		}
+		sub.Status = pb.Subscription_ACTIVE
+		sub.FailedAttempts = 0
+		sub.LastError = ""
+		if now := time.Now(); sub.GetNextRun().AsTime().Before(now) {
+			sub.NextRun = timestamppb.New(now)
+		}
+		return nil
+	})
+}
+
+// CancelSubscription stops a subscription for good and forgets its card.
+func (s *subscriptionService) CancelSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	sub, err := s.transition(req.GetId(), func(sub *pb.Subscription) error {
+		if sub.GetStatus() == pb.Subscription_CANCELLED {
+			return status.Error(codes.FailedPrecondition, "subscription is already cancelled")
+		}
+		sub.Status = pb.Subscription_CANCELLED
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.vault.remove(sub.GetPaymentToken())
+	return sub, nil
+}
+
+func (s *subscriptionService) transition(id string, fn func(*pb.Subscription) error) (*pb.Subscription, error) {
+	sub, err := s.store.update(id, fn)
+	if errors.Is(err, errSubscriptionNotFound) {
+		return nil, status.Errorf(codes.NotFound, "subscription %q not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("subscription %s is now %s", sub.GetId(), sub.GetStatus())
+	return sub, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/genproto"
+)
+
+var errSubscriptionNotFound = errors.New("subscription not found")
+
+// subscriptionStore keeps subscription definitions in memory. Callers always
+// receive copies, so a subscription is only ever modified through update.
+type subscriptionStore struct {
+	mu   sync.Mutex
+	subs map[string]*pb.Subscription
+}
+
+func newSubscriptionStore() *subscriptionStore {
+	return &subscriptionStore{subs: make(map[string]*pb.Subscription)}
+}
+
+func (s *subscriptionStore) create(sub *pb.Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.GetId()] = proto.Clone(sub).(*pb.Subscription)
+}
+
+func (s *subscriptionStore) get(id string) (*pb.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return nil, errSubscriptionNotFound
+	}
+	return proto.Clone(sub).(*pb.Subscription), nil
+}
+
+// list returns the subscriptions of a user ordered by creation time.
+func (s *subscriptionStore) list(userID string) []*pb.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*pb.Subscription
+	for _, sub := range s.subs {
+		if sub.GetUserId() == userID {
+			out = append(out, proto.Clone(sub).(*pb.Subscription))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].GetCreatedAt().AsTime().Before(out[j].GetCreatedAt().AsTime())
+	})
+	return out
+}
+
+// update applies fn to the stored subscription under the store lock and
+// returns a copy of the result. Nothing is written if fn returns an error.
+func (s *subscriptionStore) update(id string, fn func(*pb.Subscription) error) (*pb.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return nil, errSubscriptionNotFound
+	}
+	next := proto.Clone(sub).(*pb.Subscription)
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	s.subs[id] = next
+	return proto.Clone(next).(*pb.Subscription), nil
+}
+
+// due returns the active subscriptions whose next run is at or before now.
+func (s *subscriptionStore) due(now time.Time) []*pb.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*pb.Subscription
+	for _, sub := range s.subs {
+		if sub.GetStatus() == pb.Subscription_ACTIVE && !sub.GetNextRun().AsTime().After(now) {
+			out = append(out, proto.Clone(sub).(*pb.Subscription))
+		}
+	}
+	return out
+}
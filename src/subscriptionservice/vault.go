@@ -0,0 +1,60 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/subscriptionservice/genproto"
+)
+
+// cardVault exchanges card details for opaque payment tokens so that
+// subscriptions never carry card numbers. Cards are only resolved again when
+// an order is placed on the user's behalf.
+type cardVault struct {
+	mu    sync.Mutex
+	cards map[string]*pb.CreditCardInfo
+}
+
+func newCardVault() *cardVault {
+	return &cardVault{cards: make(map[string]*pb.CreditCardInfo)}
+}
+
+func (v *cardVault) put(card *pb.CreditCardInfo) string {
+	token := "tok_" + uuid.NewString()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.cards[token] = proto.Clone(card).(*pb.CreditCardInfo)
+	return token
+}
+
+func (v *cardVault) get(token string) (*pb.CreditCardInfo, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	card, ok := v.cards[token]
+	if !ok {
+		return nil, false
+	}
+	return proto.Clone(card).(*pb.CreditCardInfo), true
+}
+
+func (v *cardVault) remove(token string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.cards, token)
+}